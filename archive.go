@@ -0,0 +1,168 @@
+package hargo
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Archive formats Extract can write instead of a directory tree.
+const (
+	ArchiveZip   = "zip"
+	ArchiveTarGz = "tar.gz"
+)
+
+// ArchiveFormat returns the archive format matching the extension of path
+// (.zip, .tar.gz or .tgz), or "" for other paths.
+func ArchiveFormat(path string) string {
+	path = strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(path, ".zip"):
+		return ArchiveZip
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		return ArchiveTarGz
+	}
+	return ""
+}
+
+// extractTarget receives the directories and files written by Extract.
+type extractTarget interface {
+	mkdirAll(dir string) error
+	// writeFile writes a file, with the current time if modTime is zero.
+	writeFile(path string, data []byte, modTime time.Time) error
+	close() error
+}
+
+// newExtractTarget returns the target configured by opts: the file system
+// or an archive.
+func newExtractTarget(opts ExtractOptions) (extractTarget, error) {
+	if opts.Archive == nil {
+		return dirTarget{opts}, nil
+	}
+
+	switch opts.ArchiveFormat {
+	case ArchiveZip:
+		return &zipTarget{w: zip.NewWriter(opts.Archive), opts: opts}, nil
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(opts.Archive)
+		return &tarTarget{gz: gz, w: tar.NewWriter(gz), opts: opts, dirs: make(map[string]bool)}, nil
+	default:
+		return nil, fmt.Errorf("unknown archive format %q", opts.ArchiveFormat)
+	}
+}
+
+// dirTarget writes to the file system.
+type dirTarget struct {
+	opts ExtractOptions
+}
+
+func (d dirTarget) mkdirAll(dir string) error {
+	return d.opts.mkdirAll(dir)
+}
+
+func (d dirTarget) writeFile(path string, data []byte, modTime time.Time) error {
+	if err := d.opts.writeFile(path, data); err != nil {
+		return err
+	}
+	if modTime.IsZero() {
+		return nil
+	}
+	return os.Chtimes(path, modTime, modTime)
+}
+
+func (d dirTarget) close() error {
+	return nil
+}
+
+// archiveName returns the slash-separated name of path in an archive.
+func archiveName(path string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "/")
+}
+
+// archiveTime returns modTime, or now if it is zero.
+func archiveTime(modTime time.Time) time.Time {
+	if modTime.IsZero() {
+		return time.Now()
+	}
+	return modTime
+}
+
+// zipTarget writes a zip archive. Directories are implied by file names.
+type zipTarget struct {
+	w    *zip.Writer
+	opts ExtractOptions
+}
+
+func (z *zipTarget) mkdirAll(dir string) error {
+	return nil
+}
+
+func (z *zipTarget) writeFile(path string, data []byte, modTime time.Time) error {
+	header := &zip.FileHeader{Name: archiveName(path), Method: zip.Deflate, Modified: archiveTime(modTime)}
+	header.SetMode(z.opts.fileMode())
+	w, err := z.w.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (z *zipTarget) close() error {
+	return z.w.Close()
+}
+
+// tarTarget writes a gzip compressed tar archive.
+type tarTarget struct {
+	gz   *gzip.Writer
+	w    *tar.Writer
+	opts ExtractOptions
+	dirs map[string]bool
+}
+
+func (t *tarTarget) mkdirAll(dir string) error {
+	name := archiveName(dir)
+	if name == "." || name == "" || t.dirs[name] {
+		return nil
+	}
+	if err := t.mkdirAll(filepath.Dir(dir)); err != nil {
+		return err
+	}
+	t.dirs[name] = true
+	return t.w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name + "/",
+		Mode:     int64(t.opts.dirMode()),
+		ModTime:  time.Now(),
+	})
+}
+
+func (t *tarTarget) writeFile(path string, data []byte, modTime time.Time) error {
+	if err := t.mkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+	err := t.w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     archiveName(path),
+		Mode:     int64(t.opts.fileMode()),
+		Size:     int64(len(data)),
+		ModTime:  archiveTime(modTime),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = t.w.Write(data)
+	return err
+}
+
+func (t *tarTarget) close() error {
+	if err := t.w.Close(); err != nil {
+		return err
+	}
+	return t.gz.Close()
+}
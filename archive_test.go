@@ -0,0 +1,140 @@
+package hargo
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestArchiveFormat(t *testing.T) {
+	for path, expected := range map[string]string{
+		"out.zip":        ArchiveZip,
+		"out.tar.gz":     ArchiveTarGz,
+		"dir/OUT.TGZ":    ArchiveTarGz,
+		"out.tar":        "",
+		"zip":            "",
+		"archive.zip.gz": "",
+	} {
+		if got := ArchiveFormat(path); got != expected {
+			t.Errorf("ArchiveFormat(%s) = %q, expected %q", path, got, expected)
+		}
+	}
+}
+
+func TestExtractArchive(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	harData, _ := json.Marshal(Har{Log: Log{Entries: []Entry{
+		{
+			StartedDateTime: "2024-01-02T10:00:00.000Z",
+			Request:         Request{Method: "GET", URL: "https://example.com/css/site.css"},
+			Response:        Response{Status: 200, Content: Content{MimeType: "text/css", Text: "body{}"}},
+		},
+		{
+			StartedDateTime: "2024-01-02T10:00:01.000Z",
+			Request:         Request{Method: "GET", URL: "https://example.com/logo.png"},
+			Response:        Response{Status: 200, Content: Content{MimeType: "image/png", Text: "iVBORw==", Encoding: "base64"}},
+		},
+	}}})
+
+	readers := map[string]func(t *testing.T, data []byte) map[string]string{
+		ArchiveZip: func(t *testing.T, data []byte) map[string]string {
+			zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			files := make(map[string]string)
+			for _, f := range zr.File {
+				rc, err := f.Open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				content, _ := io.ReadAll(rc)
+				rc.Close()
+				files[f.Name] = string(content)
+				if strings.HasSuffix(f.Name, ".css") && f.Modified.UTC().Format("2006-01-02T15:04:05") != "2024-01-02T10:00:00" {
+					t.Errorf("%s modified %s", f.Name, f.Modified)
+				}
+			}
+			return files
+		},
+		ArchiveTarGz: func(t *testing.T, data []byte) map[string]string {
+			gz, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr := tar.NewReader(gz)
+			files := make(map[string]string)
+			for {
+				header, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if header.Typeflag == tar.TypeDir {
+					continue
+				}
+				content, _ := io.ReadAll(tr)
+				files[header.Name] = string(content)
+				if header.Mode != 0640 {
+					t.Errorf("%s has mode %o, expected 640", header.Name, header.Mode)
+				}
+			}
+			return files
+		},
+	}
+
+	for format, read := range readers {
+		var out bytes.Buffer
+		opts := ExtractOptions{Archive: &out, ArchiveFormat: format, FileMode: 0640, ModTime: ModTimeStarted}
+		if err := ExtractWithOptions(bufio.NewReader(bytes.NewReader(harData)), opts); err != nil {
+			t.Fatalf("%s: Extract failed: %v", format, err)
+		}
+		if matches, _ := filepath.Glob("hargo-extract-*"); len(matches) != 0 {
+			t.Errorf("%s: extraction directory created: %v", format, matches)
+		}
+
+		files := read(t, out.Bytes())
+		if len(files) != 3 {
+			t.Errorf("%s: expected 3 files, got %v", format, files)
+		}
+		for name, content := range files {
+			if !strings.HasPrefix(name, "hargo-extract-") {
+				t.Errorf("%s: unexpected path %s", format, name)
+			}
+			switch {
+			case strings.HasSuffix(name, "/example.com/css/site.css"):
+				if content != "body{}" {
+					t.Errorf("%s: site.css = %q", format, content)
+				}
+			case strings.HasSuffix(name, "/example.com/logo.png"):
+				if content != "\x89PNG" {
+					t.Errorf("%s: logo.png = %q", format, content)
+				}
+			case strings.HasSuffix(name, "/extraction_manifest.csv"):
+				if !strings.Contains(content, "https://example.com/logo.png") {
+					t.Errorf("%s: manifest missing entries:\n%s", format, content)
+				}
+			default:
+				t.Errorf("%s: unexpected file %s", format, name)
+			}
+		}
+	}
+}
+
+func TestExtractArchiveUnknownFormat(t *testing.T) {
+	var out bytes.Buffer
+	opts := ExtractOptions{Archive: &out, ArchiveFormat: "rar"}
+	if err := ExtractWithOptions(bufio.NewReader(strings.NewReader(createTestHAR())), opts); err == nil {
+		t.Error("expected an error for an unknown archive format")
+	}
+}
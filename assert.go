@@ -0,0 +1,264 @@
+package hargo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Body comparison modes of an Assertion.
+const (
+	// BodyExact requires the live body to equal the recorded body.
+	BodyExact = "exact"
+	// BodyJSON requires the live body to have the same JSON structure (keys
+	// and value types) as the recorded body, ignoring the values.
+	BodyJSON = "json"
+	// BodyRegex requires the live body to match Assertion.Pattern.
+	BodyRegex = "regex"
+)
+
+// Assertion compares live responses with the recorded ones during replay.
+type Assertion struct {
+	// URL is a regular expression restricting the assertion to matching
+	// request URLs. An empty URL applies to every request.
+	URL string `json:"url"`
+	// Status requires the live status code to equal the recorded one.
+	Status bool `json:"status"`
+	// Headers lists response headers whose values must equal the recorded
+	// ones.
+	Headers []string `json:"headers"`
+	// Body selects how response bodies are compared: BodyExact, BodyJSON,
+	// BodyRegex or empty for no comparison.
+	Body string `json:"body"`
+	// Pattern is the regular expression used by BodyRegex.
+	Pattern string `json:"pattern"`
+}
+
+// AssertionResult is the outcome of all assertions for one replayed entry.
+type AssertionResult struct {
+	Index    int      `json:"index"`
+	Method   string   `json:"method"`
+	URL      string   `json:"url"`
+	Passed   bool     `json:"passed"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+// needsBody reports whether any assertion compares response bodies.
+func needsBody(assertions []Assertion) bool {
+	for _, a := range assertions {
+		if a.Body != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAssertions evaluates the assertions that apply to a replayed entry.
+// It returns nil if none apply.
+func checkAssertions(index int, entry Entry, resp *http.Response, body []byte, assertions []Assertion) (*AssertionResult, error) {
+	var result *AssertionResult
+
+	for _, a := range assertions {
+		if a.URL != "" {
+			matched, err := regexp.MatchString(a.URL, entry.Request.URL)
+			if err != nil {
+				return nil, fmt.Errorf("invalid assertion URL pattern %q: %v", a.URL, err)
+			}
+			if !matched {
+				continue
+			}
+		}
+
+		if result == nil {
+			result = &AssertionResult{Index: index, Method: entry.Request.Method, URL: entry.Request.URL, Passed: true}
+		}
+
+		failures, err := a.check(entry, resp, body)
+		if err != nil {
+			return nil, err
+		}
+		result.Failures = append(result.Failures, failures...)
+	}
+
+	if result != nil {
+		result.Passed = len(result.Failures) == 0
+	}
+	return result, nil
+}
+
+// check returns a description of every difference between the live
+// response and the recording.
+func (a Assertion) check(entry Entry, resp *http.Response, body []byte) ([]string, error) {
+	var failures []string
+
+	if a.Status && resp.StatusCode != entry.Response.Status {
+		failures = append(failures, fmt.Sprintf("status: expected %d, got %d", entry.Response.Status, resp.StatusCode))
+	}
+
+	for _, name := range a.Headers {
+		expected := recordedHeader(entry.Response.Headers, name)
+		if got := resp.Header.Get(name); got != expected {
+			failures = append(failures, fmt.Sprintf("header %s: expected %q, got %q", name, expected, got))
+		}
+	}
+
+	switch a.Body {
+	case "":
+	case BodyExact:
+		recorded, err := entry.DecodedBody()
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(recorded, body) {
+			failures = append(failures, "body: "+describeBodyDiff(recorded, body))
+		}
+	case BodyJSON:
+		recorded, err := entry.DecodedBody()
+		if err != nil {
+			return nil, err
+		}
+		var expected, got interface{}
+		if err := json.Unmarshal(recorded, &expected); err != nil {
+			failures = append(failures, fmt.Sprintf("body: recorded body is not JSON: %v", err))
+			break
+		}
+		if err := json.Unmarshal(body, &got); err != nil {
+			failures = append(failures, fmt.Sprintf("body: live body is not JSON: %v", err))
+			break
+		}
+		for _, diff := range jsonStructureDiff("$", expected, got) {
+			failures = append(failures, "body: "+diff)
+		}
+	case BodyRegex:
+		re, err := regexp.Compile(a.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid assertion body pattern %q: %v", a.Pattern, err)
+		}
+		if !re.Match(body) {
+			failures = append(failures, fmt.Sprintf("body: does not match %q", a.Pattern))
+		}
+	default:
+		return nil, fmt.Errorf("unknown body assertion mode: %s", a.Body)
+	}
+
+	return failures, nil
+}
+
+// recordedHeader returns the first recorded value of a header.
+func recordedHeader(headers []NVP, name string) string {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// describeBodyDiff describes where two bodies first differ.
+func describeBodyDiff(expected, got []byte) string {
+	i := 0
+	for i < len(expected) && i < len(got) && expected[i] == got[i] {
+		i++
+	}
+	return fmt.Sprintf("differs at byte %d (expected %d bytes, got %d): expected %q, got %q",
+		i, len(expected), len(got), snippet(expected, i), snippet(got, i))
+}
+
+// snippet returns up to 40 bytes of b starting at offset.
+func snippet(b []byte, offset int) string {
+	if offset >= len(b) {
+		return ""
+	}
+	end := offset + 40
+	if end > len(b) {
+		end = len(b)
+	}
+	return string(b[offset:end])
+}
+
+// jsonStructureDiff compares the structure of two decoded JSON values,
+// returning one description per differing path.
+func jsonStructureDiff(path string, expected, got interface{}) []string {
+	if jsonType(expected) != jsonType(got) {
+		return []string{fmt.Sprintf("%s: expected %s, got %s", path, jsonType(expected), jsonType(got))}
+	}
+
+	var diffs []string
+
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		g := got.(map[string]interface{})
+		keys := make([]string, 0, len(e)+len(g))
+		for k := range e {
+			keys = append(keys, k)
+		}
+		for k := range g {
+			if _, ok := e[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ev, inExpected := e[k]
+			gv, inGot := g[k]
+			switch {
+			case !inGot:
+				diffs = append(diffs, fmt.Sprintf("%s.%s: missing", path, k))
+			case !inExpected:
+				diffs = append(diffs, fmt.Sprintf("%s.%s: unexpected", path, k))
+			default:
+				diffs = append(diffs, jsonStructureDiff(path+"."+k, ev, gv)...)
+			}
+		}
+	case []interface{}:
+		// arrays are compared by the structure of their first element, as
+		// their length usually depends on the data
+		g := got.([]interface{})
+		if len(e) > 0 && len(g) > 0 {
+			diffs = append(diffs, jsonStructureDiff(path+"[0]", e[0], g[0])...)
+		}
+	}
+
+	return diffs
+}
+
+// jsonType names the JSON type of a decoded value.
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// WriteAssertionReport writes a pass/fail report of assertion results to w.
+func WriteAssertionReport(w io.Writer, results []AssertionResult) {
+	failed := 0
+	for _, r := range results {
+		if r.Passed {
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "FAIL [%d] %s %s\n", r.Index, r.Method, r.URL)
+		for _, f := range r.Failures {
+			fmt.Fprintf(w, "\t%s\n", f)
+		}
+	}
+	fmt.Fprintf(w, "Assertions: %d passed, %d failed\n", len(results)-failed, failed)
+}
@@ -0,0 +1,53 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReplayAssertions(t *testing.T) {
+	recorded := createMockHAR()
+
+	// the live response differs in structure from the recording
+	changed := createMockHAR()
+	changed.Log.Entries[0].Response.Content.Text = `{"id":"1","name":"x"}`
+
+	liveServer := httptest.NewServer(NewMockServer(changed))
+	defer liveServer.Close()
+
+	cfg := Config{
+		Rewrites:   []Rewrite{{BaseURL: liveServer.URL}},
+		Assertions: []Assertion{{URL: "/api/", Status: true, Body: BodyJSON}},
+	}
+
+	var h bytes.Buffer
+	if err := json.NewEncoder(&h).Encode(recorded); err != nil {
+		t.Fatal(err)
+	}
+
+	var failures []string
+	unsubscribe := Subscribe(func(e Event) {
+		if e.Type == EventAssertionFailed {
+			failures = append(failures, e.Message)
+		}
+	})
+	defer unsubscribe()
+
+	err := Replay(bufio.NewReader(&h), cfg)
+	if err == nil {
+		t.Fatal("Replay should fail when assertions fail")
+	}
+
+	if len(failures) != 1 {
+		t.Fatalf("got %d assertion failures, expected 1: %v", len(failures), failures)
+	}
+	for _, expected := range []string{"$.id: expected number, got string", "$.name: unexpected"} {
+		if !strings.Contains(failures[0], expected) {
+			t.Errorf("failure %q does not mention %q", failures[0], expected)
+		}
+	}
+}
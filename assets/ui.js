@@ -0,0 +1,160 @@
+// hargo web UI: lists the entries of a .har file and shows their details.
+// Works offline, no dependencies.
+(function () {
+  var maxBodyText = 1 << 20;
+  var entries = [];
+  var current = null;
+  var tabName = "headers";
+
+  function el(tag, attrs, children) {
+    var e = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (k) { e.setAttribute(k, attrs[k]); });
+    (children || []).forEach(function (c) {
+      e.appendChild(typeof c === "string" ? document.createTextNode(c) : c);
+    });
+    return e;
+  }
+
+  function pairs(title, list) {
+    var rows = (list || []).map(function (p) {
+      return el("tr", {}, [el("td", {}, [p.name]), el("td", {}, [String(p.value)])]);
+    });
+    if (!rows.length) return el("div", {}, []);
+    return el("div", {}, [el("h2", {}, [title]), el("table", { "class": "pairs" }, [el("tbody", {}, rows)])]);
+  }
+
+  function isText(mimeType) {
+    mimeType = (mimeType || "").toLowerCase();
+    return mimeType.indexOf("text/") === 0 || /json|javascript|xml/.test(mimeType);
+  }
+
+  function renderList() {
+    var q = document.getElementById("filter").value.toLowerCase();
+    var end = 0;
+    entries.forEach(function (e) { end = Math.max(end, e.offset + Math.max(e.time, 0)); });
+    var body = document.querySelector("#entries tbody");
+    body.textContent = "";
+    var shown = 0;
+    entries.forEach(function (e) {
+      var text = [e.index, e.method, e.url, e.status, e.mimeType].join(" ").toLowerCase();
+      if (q && text.indexOf(q) < 0) return;
+      shown++;
+      var left = end > 0 ? 100 * e.offset / end : 0;
+      var width = end > 0 ? 100 * Math.max(e.time, 0) / end : 0;
+      var bar = el("span", { style: "left: " + left.toFixed(2) + "%; width: " + width.toFixed(2) + "%" });
+      var row = el("tr", { "class": "status-" + Math.floor(e.status / 100) + (current && current.index === e.index ? " selected" : "") }, [
+        el("td", { "class": "num" }, [String(e.index)]),
+        el("td", {}, [e.method]),
+        el("td", { "class": "url", title: e.url }, [e.url]),
+        el("td", { "class": "num" }, [String(e.status)]),
+        el("td", {}, [e.mimeType]),
+        el("td", { "class": "num" }, [String(e.size)]),
+        el("td", { "class": "num" }, [e.time.toFixed(1)]),
+        el("td", { "class": "bar" }, [el("div", { "class": "track" }, [bar])])
+      ]);
+      row.addEventListener("click", function () { select(e.index); });
+      body.appendChild(row);
+    });
+    document.getElementById("count").textContent = shown + " of " + entries.length + " entries";
+  }
+
+  function renderTab() {
+    var tab = document.getElementById("tab");
+    tab.textContent = "";
+    document.querySelectorAll("nav button[data-tab]").forEach(function (b) {
+      b.classList.toggle("active", b.getAttribute("data-tab") === tabName);
+    });
+    var e = current.entry, req = e.request, resp = e.response;
+    switch (tabName) {
+    case "headers":
+      tab.appendChild(pairs("General", [
+        { name: "URL", value: req.url },
+        { name: "Method", value: req.method },
+        { name: "Status", value: resp.status + " " + (resp.statusText || "") },
+        { name: "HTTP version", value: resp.httpVersion || req.httpVersion || "" },
+        { name: "Started", value: e.startedDateTime },
+        { name: "Server IP", value: e.serverIPAddress || "" }
+      ]));
+      tab.appendChild(pairs("Request headers", req.headers));
+      tab.appendChild(pairs("Response headers", resp.headers));
+      break;
+    case "request":
+      tab.appendChild(pairs("Query string", req.queryString));
+      tab.appendChild(pairs("Cookies", req.cookies));
+      if (req.postData && (req.postData.text || (req.postData.params || []).length)) {
+        tab.appendChild(el("h2", {}, ["Body (" + (req.postData.mimeType || "unknown type") + ")"]));
+        if (req.postData.text) tab.appendChild(el("pre", {}, [req.postData.text]));
+        tab.appendChild(pairs("Parameters", req.postData.params));
+      }
+      break;
+    case "response":
+      tab.appendChild(pairs("Cookies", resp.cookies));
+      var content = resp.content || {};
+      tab.appendChild(el("h2", {}, ["Body (" + (content.mimeType || "unknown type") + ", " + (content.size || 0) + " bytes)"]));
+      var src = "api/entries/" + current.index + "/body";
+      var decoder = entries[current.index].decoder;
+      if (decoder) {
+        var decoded = el("pre", {}, ["Decoding..."]);
+        tab.appendChild(el("h2", {}, ["Decoded (" + decoder + ")"]));
+        tab.appendChild(decoded);
+        fetch("api/entries/" + current.index + "/decoded").then(function (r) { return r.text(); }).then(function (text) {
+          try { text = JSON.stringify(JSON.parse(text), null, 2); } catch (err) { }
+          decoded.textContent = text;
+        });
+      }
+      if (!content.text) {
+        tab.appendChild(el("p", { "class": "meta" }, ["No content recorded."]));
+      } else if ((content.mimeType || "").indexOf("image/") === 0) {
+        tab.appendChild(el("img", { src: src, alt: req.url }));
+      } else if (isText(content.mimeType)) {
+        var pre = el("pre", {}, ["Loading..."]);
+        tab.appendChild(pre);
+        fetch(src).then(function (r) { return r.text(); }).then(function (text) {
+          if (text.length > maxBodyText) text = text.slice(0, maxBodyText) + "\n... (truncated)";
+          if (/json/.test(content.mimeType)) {
+            try { text = JSON.stringify(JSON.parse(text), null, 2); } catch (err) { }
+          }
+          pre.textContent = text;
+        });
+      } else {
+        tab.appendChild(el("a", { href: src, download: "" }, ["Download body"]));
+      }
+      break;
+    case "timings":
+      var t = e.timings || {};
+      tab.appendChild(pairs("Timings (ms)", ["blocked", "dns", "connect", "ssl", "send", "wait", "receive"].map(function (k) {
+        return { name: k, value: t[k] === undefined ? "" : t[k] };
+      }).concat([{ name: "total", value: e.time }])));
+      break;
+    }
+  }
+
+  function select(index) {
+    fetch("api/entries/" + index).then(function (r) { return r.json(); }).then(function (entry) {
+      current = { index: index, entry: entry };
+      document.getElementById("details").hidden = false;
+      renderList();
+      renderTab();
+    });
+  }
+
+  document.getElementById("filter").addEventListener("input", renderList);
+  document.querySelectorAll("nav button[data-tab]").forEach(function (b) {
+    b.addEventListener("click", function () {
+      tabName = b.getAttribute("data-tab");
+      renderTab();
+    });
+  });
+  document.getElementById("close").addEventListener("click", function () {
+    current = null;
+    document.getElementById("details").hidden = true;
+    renderList();
+  });
+
+  fetch("api/entries").then(function (r) { return r.json(); }).then(function (data) {
+    document.getElementById("title").textContent = data.title;
+    document.title = data.title + " - hargo";
+    entries = data.entries;
+    renderList();
+  });
+})();
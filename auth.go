@@ -0,0 +1,86 @@
+package hargo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AuthProvider returns a header to set on the request replaying entry, e.g.
+// "Authorization" with a freshly minted bearer token in place of the
+// expired one recorded. An empty headerName leaves the request unchanged.
+// Load tests call it from all workers at once, so it must be safe for
+// concurrent use.
+type AuthProvider func(ctx context.Context, entry Entry) (headerName, value string)
+
+// CommandAuth returns an AuthProvider running a command, e.g. a script
+// logging in to an identity provider, and setting its output on every
+// request: as the Authorization header, e.g. "Bearer eyJ...", or if it has
+// the form "Name: value", as that header. The command is run on first use
+// and again once refresh has passed; a zero refresh runs it only once. If
+// it fails, the previous credential is kept.
+func CommandAuth(command string, refresh time.Duration) AuthProvider {
+	args := strings.Fields(command)
+	var mu sync.Mutex
+	var name, value string
+	var minted time.Time
+
+	return func(ctx context.Context, entry Entry) (string, string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(args) == 0 || !minted.IsZero() && (refresh <= 0 || time.Since(minted) < refresh) {
+			return name, value
+		}
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			log.Errorf("Auth command %s failed: %v %s", args[0], err, strings.TrimSpace(stderr.String()))
+			return name, value
+		}
+		minted = time.Now()
+
+		credential := strings.TrimSpace(stdout.String())
+		name, value = "Authorization", credential
+		if n, v, ok := strings.Cut(credential, ":"); ok && !strings.ContainsAny(n, " \t") && n != "" {
+			name, value = n, strings.TrimSpace(v)
+		}
+		return name, value
+	}
+}
+
+// authProvider returns the Auth provider of cfg, or if it is not set, a
+// CommandAuth provider for its AuthCommand, or nil.
+func (cfg Config) authProvider() (AuthProvider, error) {
+	if cfg.Auth != nil || cfg.AuthCommand == "" {
+		return cfg.Auth, nil
+	}
+	var refresh time.Duration
+	if cfg.AuthRefresh != "" {
+		var err error
+		if refresh, err = time.ParseDuration(cfg.AuthRefresh); err != nil || refresh < 0 {
+			return nil, fmt.Errorf("invalid auth refresh %q", cfg.AuthRefresh)
+		}
+	}
+	return CommandAuth(cfg.AuthCommand, refresh), nil
+}
+
+// applyAuth sets the header returned by the Auth provider of cfg, if any,
+// on the request replaying entry.
+func applyAuth(ctx context.Context, req *http.Request, entry Entry, cfg Config) {
+	if cfg.Auth == nil {
+		return
+	}
+	if name, value := cfg.Auth(ctx, entry); name != "" {
+		req.Header.Set(name, value)
+	}
+}
@@ -0,0 +1,137 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplayAuthProvider(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	har := Har{Log: Log{Entries: []Entry{
+		{
+			StartedDateTime: "2020-01-01T00:00:00.000Z",
+			Request: Request{
+				Method:  "GET",
+				URL:     "https://api.example.com/orders",
+				Headers: []NVP{{Name: "Authorization", Value: "Bearer expired"}},
+			},
+		},
+		{
+			StartedDateTime: "2020-01-01T00:00:00.000Z",
+			Request: Request{
+				Method:  "GET",
+				URL:     "https://cdn.example.com/logo.png",
+				Headers: []NVP{{Name: "Authorization", Value: "Bearer expired"}},
+			},
+		},
+	}}}
+
+	var h bytes.Buffer
+	if err := json.NewEncoder(&h).Encode(har); err != nil {
+		t.Fatal(err)
+	}
+
+	minted := 0
+	cfg := Config{
+		Rewrites: []Rewrite{{BaseURL: server.URL}},
+		Auth: func(ctx context.Context, entry Entry) (string, string) {
+			if entry.Request.URL != "https://api.example.com/orders" {
+				return "", ""
+			}
+			minted++
+			return "Authorization", "Bearer fresh"
+		},
+	}
+	if err := Replay(bufio.NewReader(&h), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"Bearer fresh", "Bearer expired"}
+	if !reflect.DeepEqual(received, want) {
+		t.Errorf("received %q, want %q", received, want)
+	}
+	if minted != 1 {
+		t.Errorf("minted %d tokens", minted)
+	}
+}
+
+func TestCommandAuth(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the auth command is a shell script")
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "login.sh")
+	counter := filepath.Join(dir, "count")
+	os.WriteFile(script, []byte("#!/bin/sh\nn=$(($(cat "+counter+" 2>/dev/null || echo 0) + 1))\necho $n > "+counter+"\necho \"X-Api-Key: key-$n\"\n"), 0755)
+
+	tests := []struct {
+		refresh  time.Duration
+		expected []string
+	}{
+		{0, []string{"key-1", "key-1"}},
+		{time.Nanosecond, []string{"key-2", "key-3"}},
+	}
+	for i, test := range tests {
+		auth := CommandAuth(script, test.refresh)
+		for j, expected := range test.expected {
+			if name, value := auth(context.Background(), Entry{}); name != "X-Api-Key" || value != expected {
+				t.Errorf("%d/%d: got %s: %s, want %s", i, j, name, value, expected)
+			}
+		}
+	}
+
+	// a failing command leaves requests unchanged
+	if name, _ := CommandAuth("false", 0)(context.Background(), Entry{}); name != "" {
+		t.Errorf("got header %s", name)
+	}
+	if _, err := (Config{AuthCommand: "true", AuthRefresh: "soon"}).authProvider(); err == nil {
+		t.Error("expected an error for an invalid refresh")
+	}
+}
+
+func TestFetchAuthCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("echo is not a program on Windows")
+	}
+	defer func() {
+		matches, _ := filepath.Glob("./hargo-fetch-*")
+		for _, match := range matches {
+			os.RemoveAll(match)
+		}
+	}()
+
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	data, err := os.ReadFile("test/websocket.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	har := strings.ReplaceAll(string(data), "http://example.com", server.URL)
+	cfg := Config{AuthCommand: "echo Bearer t0k3n"}
+	if err := FetchConfig(context.Background(), bufio.NewReader(strings.NewReader(har)), cfg); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if len(received) != 1 || received[0] != "Bearer t0k3n" {
+		t.Errorf("received %q", received)
+	}
+}
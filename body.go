@@ -0,0 +1,65 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"io"
+	"strings"
+)
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// DecodedBody returns the response body of the entry as read by
+// BodyReader.
+func (e *Entry) DecodedBody() ([]byte, error) {
+	r, err := e.BodyReader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// BodyReader returns a reader of the response body of the entry, decoding
+// the content text while it is read if it was stored base64 encoded.
+// Bodies recorded as sent on the wire with a gzip or deflate
+// Content-Encoding are decompressed too; since most recorders store bodies
+// decoded despite the header, only bodies that start like compressed data
+// are.
+func (e *Entry) BodyReader() (io.ReadCloser, error) {
+	var r io.Reader = strings.NewReader(e.Response.Content.Text)
+	if e.Response.Content.Encoding == "base64" {
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	}
+	return decompressBody(bufio.NewReader(r), recordedHeader(e.Response.Headers, "Content-Encoding"))
+}
+
+// decompressBody wraps r in a decompressor for a gzip or deflate
+// Content-Encoding if r starts with a header of the format.
+func decompressBody(r *bufio.Reader, encoding string) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		if magic, _ := r.Peek(2); bytes.Equal(magic, gzipMagic) {
+			zr, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return zr, nil
+		}
+	case "deflate":
+		// HTTP deflate is a zlib stream: compression method 8 and a header
+		// checksum divisible by 31
+		if header, _ := r.Peek(2); len(header) == 2 && header[0]&0x0f == 8 && (int(header[0])<<8|int(header[1]))%31 == 0 {
+			zr, err := zlib.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return zr, nil
+		}
+	}
+	return io.NopCloser(r), nil
+}
@@ -0,0 +1,70 @@
+package hargo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"io"
+	"testing"
+)
+
+func TestDecodedBody(t *testing.T) {
+	var gz, zl bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte("hello"))
+	gw.Close()
+	zw := zlib.NewWriter(&zl)
+	zw.Write([]byte("hello"))
+	zw.Close()
+
+	tests := []struct {
+		text, encoding, contentEncoding string
+		expected                        string
+	}{
+		{"hello", "", "", "hello"},
+		{base64.StdEncoding.EncodeToString([]byte("hello")), "base64", "", "hello"},
+		// recorded decoded despite the header
+		{"hello", "", "gzip", "hello"},
+		{base64.StdEncoding.EncodeToString([]byte("\x89PNG")), "base64", "deflate", "\x89PNG"},
+		// recorded as sent on the wire
+		{base64.StdEncoding.EncodeToString(gz.Bytes()), "base64", "gzip", "hello"},
+		{base64.StdEncoding.EncodeToString(zl.Bytes()), "base64", "Deflate", "hello"},
+		{base64.StdEncoding.EncodeToString(gz.Bytes()), "base64", "br", string(gz.Bytes())},
+	}
+	for i, test := range tests {
+		entry := Entry{Response: Response{
+			Headers: []NVP{{Name: "content-encoding", Value: test.contentEncoding}},
+			Content: Content{Text: test.text, Encoding: test.encoding},
+		}}
+		body, err := entry.DecodedBody()
+		if err != nil || string(body) != test.expected {
+			t.Errorf("%d: got %q, %v, want %q", i, body, err, test.expected)
+		}
+	}
+}
+
+func TestBodyReaderErrors(t *testing.T) {
+	entry := Entry{Response: Response{Content: Content{Text: "not base64!", Encoding: "base64"}}}
+	r, err := entry.BodyReader()
+	if err == nil {
+		_, err = io.ReadAll(r)
+		r.Close()
+	}
+	if err == nil {
+		t.Error("expected an error for invalid base64")
+	}
+
+	// a truncated gzip stream
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write(bytes.Repeat([]byte("hello"), 100))
+	gw.Close()
+	entry = Entry{Response: Response{
+		Headers: []NVP{{Name: "Content-Encoding", Value: "gzip"}},
+		Content: Content{Text: base64.StdEncoding.EncodeToString(gz.Bytes()[:20]), Encoding: "base64"},
+	}}
+	if _, err := entry.DecodedBody(); err == nil {
+		t.Error("expected an error for a truncated gzip body")
+	}
+}
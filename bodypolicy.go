@@ -0,0 +1,83 @@
+package hargo
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// BodyPolicy limits the bodies stored by the Recorder, to keep the .har
+// files of long captures manageable. Omitted bodies keep their metadata:
+// MIME type, size and a comment saying why the body is missing.
+type BodyPolicy struct {
+	// OmitOver omits bodies larger than this many bytes. Zero keeps them.
+	OmitOver int
+	// Truncate stores at most this many bytes of a body. Zero keeps them
+	// whole.
+	Truncate int
+	// OmitMimeTypes omits bodies of these media types, e.g. video/mp4, or
+	// of a whole type with a wildcard, e.g. video/* or font/*.
+	OmitMimeTypes []string
+}
+
+// IsZero reports whether the policy stores every body as is.
+func (p BodyPolicy) IsZero() bool {
+	return p.OmitOver <= 0 && p.Truncate <= 0 && len(p.OmitMimeTypes) == 0
+}
+
+// omitsMimeType reports whether bodies of mimeType are omitted.
+func (p BodyPolicy) omitsMimeType(mimeType string) bool {
+	for _, pattern := range p.OmitMimeTypes {
+		if matchMimeType(pattern, mimeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply returns the part of body to store, and a comment describing what
+// was left out, if anything.
+func (p BodyPolicy) apply(mimeType string, body []byte) ([]byte, string) {
+	switch {
+	case len(body) == 0:
+		return body, ""
+	case p.omitsMimeType(mimeType):
+		return nil, fmt.Sprintf("hargo: %d byte %s body omitted", len(body), mimeType)
+	case p.OmitOver > 0 && len(body) > p.OmitOver:
+		return nil, fmt.Sprintf("hargo: %d byte body omitted, over %d bytes", len(body), p.OmitOver)
+	case p.Truncate > 0 && len(body) > p.Truncate:
+		return body[:p.Truncate], fmt.Sprintf("hargo: body truncated to %d of %d bytes", p.Truncate, len(body))
+	}
+	return body, ""
+}
+
+// applyResponse stores the response body of entry as allowed by the
+// policy. The content size remains that of the whole body.
+func (p BodyPolicy) applyResponse(entry *Entry, body []byte) {
+	content := &entry.Response.Content
+	stored, comment := p.apply(content.MimeType, body)
+	if comment == "" {
+		return
+	}
+
+	content.Text, content.Encoding = "", ""
+	if isTextMimeType(content.MimeType) {
+		content.Text = string(stored)
+	} else if len(stored) > 0 {
+		content.Text = base64.StdEncoding.EncodeToString(stored)
+		content.Encoding = "base64"
+	}
+	content.Comment = comment
+}
+
+// applyRequest stores the posted body of entry as allowed by the policy.
+// The request body size remains that of the whole body.
+func (p BodyPolicy) applyRequest(entry *Entry) {
+	postData := &entry.Request.PostData
+	stored, comment := p.apply(postData.MimeType, []byte(postData.Text))
+	if comment == "" {
+		return
+	}
+	postData.Text = string(stored)
+	postData.Params = nil
+	postData.Comment = comment
+}
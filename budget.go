@@ -0,0 +1,231 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// ByteSize is a number of bytes, written in budgets as a number or a
+// string with a decimal or binary unit, e.g. "500KB" or "1.5 MiB".
+type ByteSize int64
+
+var byteUnits = map[string]float64{
+	"": 1, "b": 1,
+	"kb": 1e3, "mb": 1e6, "gb": 1e9,
+	"kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30,
+}
+
+// ParseByteSize parses a size like "1024", "500KB" or "1.5 MiB".
+func ParseByteSize(s string) (ByteSize, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	unit, ok := byteUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if err != nil || !ok {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return ByteSize(n * unit), nil
+}
+
+// UnmarshalJSON accepts a number of bytes or a string with a unit.
+func (s *ByteSize) UnmarshalJSON(b []byte) error {
+	var n float64
+	if err := json.Unmarshal(b, &n); err == nil {
+		*s = ByteSize(n)
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return fmt.Errorf("invalid size %s", b)
+	}
+	size, err := ParseByteSize(str)
+	*s = size
+	return err
+}
+
+// String formats s with a binary unit, e.g. 1.5 MiB.
+func (s ByteSize) String() string {
+	return formatBytes(int64(s))
+}
+
+// TypeBudget limits the bytes received for one content type, named like
+// the types of hargo stats: html, css, javascript, json, images, fonts,
+// videos, audio, events, text or other.
+type TypeBudget struct {
+	Type  string   `json:"type"`
+	Bytes ByteSize `json:"bytes"`
+}
+
+// Budget is a performance budget for the traffic of a .har file. Sizes are
+// the bytes received, response headers included, as accounted by hargo
+// stats. Zero limits are not checked.
+type Budget struct {
+	// Site is the first-party domain used to count third-party requests,
+	// by default the domain of the first request of each page.
+	Site               string       `json:"site"`
+	TotalBytes         ByteSize     `json:"totalBytes"`
+	Requests           int          `json:"requests"`
+	ThirdPartyRequests int          `json:"thirdPartyRequests"`
+	MaxAssetBytes      ByteSize     `json:"maxAssetBytes"`
+	Types              []TypeBudget `json:"types"`
+}
+
+// budgetTypes are the content types a TypeBudget can limit.
+var budgetTypes = []string{"html", "css", "javascript", "json", "images", "fonts", "videos", "audio", "events", "text", "other"}
+
+// LoadBudget reads a budget from a JSON file or a YAML file using block
+// mappings and sequences only:
+//
+//	totalBytes: 2MB
+//	requests: 80
+//	thirdPartyRequests: 20
+//	maxAssetBytes: 500KB
+//	types:
+//	  - type: javascript
+//	    bytes: 400KB
+//	  - type: images
+//	    bytes: 1MB
+func LoadBudget(path string) (Budget, error) {
+	var budget Budget
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return budget, err
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		if b, err = yamlToJSON(b, &budget); err != nil {
+			return budget, fmt.Errorf("invalid budget %s: %v", path, err)
+		}
+	}
+
+	if err := json.Unmarshal(b, &budget); err != nil {
+		return budget, fmt.Errorf("invalid budget %s: %v", path, err)
+	}
+	for _, t := range budget.Types {
+		known := false
+		for _, name := range budgetTypes {
+			known = known || t.Type == name
+		}
+		if !known {
+			return budget, fmt.Errorf("invalid budget %s: unknown type %q, expected one of %s", path, t.Type, strings.Join(budgetTypes, ", "))
+		}
+	}
+	return budget, nil
+}
+
+// BudgetCheck is the result of checking one limit of a budget.
+type BudgetCheck struct {
+	Name   string `json:"name"`
+	Limit  int64  `json:"limit"`
+	Actual int64  `json:"actual"`
+	// Bytes is set if Limit and Actual are sizes rather than counts.
+	Bytes bool `json:"bytes"`
+	// URL of the largest asset, for the maxAssetBytes limit.
+	URL      string `json:"url,omitempty"`
+	Exceeded bool   `json:"exceeded"`
+}
+
+// BudgetReport is the result of checking a .har file against a budget.
+type BudgetReport struct {
+	Checks []BudgetCheck `json:"checks"`
+}
+
+// Passed reports whether no limit was exceeded.
+func (r BudgetReport) Passed() bool {
+	for _, c := range r.Checks {
+		if c.Exceeded {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckBudget checks the traffic of har against the limits of budget.
+func CheckBudget(har Har, budget Budget) BudgetReport {
+	var report BudgetReport
+	check := func(name string, limit, actual int64, bytes bool, url string) {
+		if limit > 0 {
+			report.Checks = append(report.Checks, BudgetCheck{Name: name, Limit: limit, Actual: actual, Bytes: bytes, URL: url, Exceeded: actual > limit})
+		}
+	}
+
+	stats := ComputeStats(har)
+	check("total bytes", int64(budget.TotalBytes), stats.Total.Received, true, "")
+	for _, t := range budget.Types {
+		var received int64
+		if c := stats.ByType[t.Type]; c != nil {
+			received = c.Received
+		}
+		check(t.Type+" bytes", int64(t.Bytes), received, true, "")
+	}
+	check("requests", int64(budget.Requests), int64(stats.Total.Requests), false, "")
+
+	if budget.ThirdPartyRequests > 0 {
+		parties := AnalyzeThirdParties(har, ThirdPartyOptions{Site: budget.Site})
+		check("third-party requests", int64(budget.ThirdPartyRequests), int64(parties.ThirdParty.Requests), false, "")
+	}
+
+	var largest int64
+	var largestURL string
+	for _, entry := range har.Log.Entries {
+		if received := responseBytes(entry.Response); received > largest {
+			largest, largestURL = received, entry.Request.URL
+		}
+	}
+	check("max asset bytes", int64(budget.MaxAssetBytes), largest, true, largestURL)
+
+	return report
+}
+
+// CheckBudgetFile checks a .har file against budget, writes the result to
+// w and reports whether the budget was met.
+func CheckBudgetFile(r *bufio.Reader, w io.Writer, budget Budget) (bool, error) {
+	har, err := Decode(r)
+	if err != nil {
+		return false, err
+	}
+	report := CheckBudget(har, budget)
+	if err := WriteBudgetReport(w, report); err != nil {
+		return false, err
+	}
+	return report.Passed(), nil
+}
+
+// WriteBudgetReport writes a human readable budget check to w.
+func WriteBudgetReport(w io.Writer, report BudgetReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", "", "Budget", "Limit", "Actual")
+	exceeded := 0
+	for _, c := range report.Checks {
+		state := "ok"
+		if c.Exceeded {
+			state = "EXCEEDED"
+			exceeded++
+		}
+		limit, actual := strconv.FormatInt(c.Limit, 10), strconv.FormatInt(c.Actual, 10)
+		if c.Bytes {
+			limit, actual = ByteSize(c.Limit).String(), ByteSize(c.Actual).String()
+		}
+		if c.URL != "" {
+			actual += " (" + c.URL + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", state, c.Name, limit, actual)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "Budget: %d checks, %d exceeded\n", len(report.Checks), exceeded)
+	return err
+}
@@ -0,0 +1,106 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want ByteSize
+		err  bool
+	}{
+		{in: "1024", want: 1024},
+		{in: "500KB", want: 500000},
+		{in: "1.5 MiB", want: 1572864},
+		{in: "2gb", want: 2000000000},
+		{in: "10 B", want: 10},
+		{in: "10 TB", err: true},
+		{in: "KB", err: true},
+		{in: "", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseByteSize(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("%q: got %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestLoadBudget(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "budget.yaml")
+	os.WriteFile(path, []byte("totalBytes: 2MB\nrequests: 80\nmaxAssetBytes: 500000\ntypes:\n  - type: javascript\n    bytes: 400KiB\n"), 0644)
+
+	budget, err := LoadBudget(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if budget.TotalBytes != 2000000 || budget.Requests != 80 || budget.MaxAssetBytes != 500000 ||
+		len(budget.Types) != 1 || budget.Types[0].Type != "javascript" || budget.Types[0].Bytes != 409600 {
+		t.Errorf("got %+v", budget)
+	}
+
+	for i, invalid := range []string{
+		`{"totalBytes": "lots"}`,
+		`{"types": [{"type": "scripts", "bytes": 1}]}`,
+	} {
+		os.WriteFile(path, []byte(invalid), 0644)
+		if _, err := LoadBudget(path); err == nil {
+			t.Errorf("%d: expected an error", i)
+		}
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	entry := func(url, mimeType string, size int) Entry {
+		return Entry{
+			Pageref:  "page_1",
+			Request:  Request{Method: "GET", URL: url},
+			Response: Response{HeadersSize: 100, BodySize: size, Content: Content{MimeType: mimeType}},
+		}
+	}
+	har := Har{Log: Log{Entries: []Entry{
+		entry("https://www.example.com/", "text/html", 900),
+		entry("https://static.example.com/app.js", "application/javascript", 4900),
+		entry("https://cdn.tracker.net/t.js", "application/javascript", 1900),
+		entry("https://www.example.com/logo.png", "image/png", 400),
+	}}}
+
+	report := CheckBudget(har, Budget{
+		TotalBytes:         10000,
+		Requests:           3,
+		ThirdPartyRequests: 1,
+		MaxAssetBytes:      4000,
+		Types:              []TypeBudget{{Type: "javascript", Bytes: 7000}, {Type: "fonts", Bytes: 1}},
+	})
+	got, _ := json.Marshal(report.Checks)
+	expected := `[{"name":"total bytes","limit":10000,"actual":8500,"bytes":true,"exceeded":false},` +
+		`{"name":"javascript bytes","limit":7000,"actual":7000,"bytes":true,"exceeded":false},` +
+		`{"name":"fonts bytes","limit":1,"actual":0,"bytes":true,"exceeded":false},` +
+		`{"name":"requests","limit":3,"actual":4,"bytes":false,"exceeded":true},` +
+		`{"name":"third-party requests","limit":1,"actual":1,"bytes":false,"exceeded":false},` +
+		`{"name":"max asset bytes","limit":4000,"actual":5000,"bytes":true,"url":"https://static.example.com/app.js","exceeded":true}]`
+	if string(got) != expected {
+		t.Errorf("got %s, want %s", got, expected)
+	}
+	if report.Passed() {
+		t.Error("expected the budget to be exceeded")
+	}
+
+	data, _ := json.Marshal(har)
+	var out bytes.Buffer
+	passed, err := CheckBudgetFile(bufio.NewReader(bytes.NewReader(data)), &out, Budget{TotalBytes: 1 << 20})
+	if err != nil || !passed {
+		t.Errorf("got %v, %v", passed, err)
+	}
+	if !strings.Contains(out.String(), "ok  total bytes  1.0 MiB  8.3 KiB") || !strings.Contains(out.String(), "Budget: 1 checks, 0 exceeded") {
+		t.Errorf("got %s", out.String())
+	}
+}
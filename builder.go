@@ -0,0 +1,310 @@
+package hargo
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Builder constructs a Har programmatically:
+//
+//	har := hargo.NewHar().
+//		Page("page_1", "Home", started).
+//		Entry(started, req, resp, timings).
+//		Har()
+//
+// Entries added after Page belong to that page.
+type Builder struct {
+	har     Har
+	pageref string
+}
+
+// NewHar returns a Builder for an empty HAR 1.2 log created by hargo.
+func NewHar() *Builder {
+	return &Builder{har: Har{Log: Log{
+		Version: "1.2",
+		Creator: Creator{Name: "hargo"},
+		Entries: []Entry{},
+	}}}
+}
+
+// Creator sets the name and version of the application creating the log.
+func (b *Builder) Creator(name, version string) *Builder {
+	b.har.Log.Creator = Creator{Name: name, Version: version}
+	return b
+}
+
+// Browser sets the name and version of the browser that made the requests.
+func (b *Builder) Browser(name, version string) *Builder {
+	b.har.Log.Browser = Browser{Name: name, Version: version}
+	return b
+}
+
+// Page adds a page that started at started. Its onLoad timing is unknown
+// (-1) until set with PageTimings.
+func (b *Builder) Page(id, title string, started time.Time) *Builder {
+	b.har.Log.Pages = append(b.har.Log.Pages, Page{
+		StartedDateTime: started.Format(harDateTimeLayout),
+		ID:              id,
+		Title:           title,
+		PageTiming:      PageTiming{OnContentLoad: -1, OnLoad: -1},
+	})
+	b.pageref = id
+	return b
+}
+
+// PageTimings sets the onContentLoad and onLoad timings of the current page,
+// relative to its start.
+func (b *Builder) PageTimings(onContentLoad, onLoad time.Duration) *Builder {
+	if n := len(b.har.Log.Pages); n > 0 {
+		b.har.Log.Pages[n-1].PageTiming = PageTiming{OnContentLoad: milliseconds(onContentLoad), OnLoad: milliseconds(onLoad)}
+	}
+	return b
+}
+
+// Entry adds a request made at started and its response. The total time of
+// the entry is computed from timings.
+func (b *Builder) Entry(started time.Time, req Request, resp Response, timings PageTimings) *Builder {
+	return b.AddEntry(Entry{
+		StartedDateTime: started.Format(harDateTimeLayout),
+		Time:            totalTime(timings),
+		Request:         req,
+		Response:        resp,
+		Timings:         timings,
+	})
+}
+
+// AddEntry adds a complete entry. Its pageref is set to the current page
+// unless it has one.
+func (b *Builder) AddEntry(entry Entry) *Builder {
+	if entry.Pageref == "" {
+		entry.Pageref = b.pageref
+	}
+	b.har.Log.Entries = append(b.har.Log.Entries, entry)
+	return b
+}
+
+// Do sends req with client, adds it as an entry with timings measured by a
+// RoundTripTimer, including the request body if req.GetBody is set (as by
+// http.NewRequest for in-memory bodies), and returns the response, whose body has been read and
+// closed, and the body.
+func (b *Builder) Do(client *http.Client, req *http.Request) (*http.Response, []byte, error) {
+	reqBody := requestBody(req)
+	entry, body, resp, err := captureEntry(client, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry.Request = NewRequest(req, reqBody)
+	b.AddEntry(entry)
+	return resp, body, nil
+}
+
+// Har returns the constructed Har.
+func (b *Builder) Har() Har {
+	return b.har
+}
+
+// totalTime sums the timings that apply, as required for Entry.Time. The
+// ssl time is included in connect.
+func totalTime(t PageTimings) float32 {
+	return float32(positive(t.Blocked) + positive(t.DNS) + positive(t.Connect) +
+		positive(t.Send) + positive(t.Wait) + positive(t.Receive))
+}
+
+// NewRequest converts an http.Request and its body to a Request. Header
+// sizes are unknown (-1).
+func NewRequest(req *http.Request, body []byte) Request {
+	r := Request{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     harCookies(req.Cookies()),
+		Headers:     headerNVPs(req.Header),
+		QueryString: queryNVPs(req.URL.Query()),
+		HeaderSize:  -1,
+		BodySize:    len(body),
+	}
+	if r.HTTPVersion == "" {
+		r.HTTPVersion = "HTTP/1.1"
+	}
+	if len(body) > 0 {
+		r.PostData = PostData{MimeType: req.Header.Get("Content-Type"), Text: string(body)}
+	}
+	return r
+}
+
+// requestBody returns a copy of the body of req if req.GetBody is set.
+func requestBody(req *http.Request) []byte {
+	if req.GetBody == nil {
+		return nil
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	defer rc.Close()
+	body, _ := io.ReadAll(rc)
+	return body
+}
+
+// NewResponse converts an http.Response and its body, as read from
+// resp.Body, to a Response. Textual bodies are stored as text, others
+// base64 encoded.
+func NewResponse(resp *http.Response, body []byte) Response {
+	mimeType := resp.Header.Get("Content-Type")
+	content := Content{Size: len(body), MimeType: mimeType}
+	if isTextMimeType(mimeType) {
+		content.Text = string(body)
+	} else if len(body) > 0 {
+		content.Text = base64.StdEncoding.EncodeToString(body)
+		content.Encoding = "base64"
+	}
+
+	redirectURL := ""
+	if location, err := resp.Location(); err == nil {
+		redirectURL = location.String()
+	}
+
+	return Response{
+		Status:      resp.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode))),
+		HTTPVersion: resp.Proto,
+		Cookies:     harCookies(resp.Cookies()),
+		Headers:     headerNVPs(resp.Header),
+		Content:     content,
+		RedirectURL: redirectURL,
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+}
+
+// RoundTripTimer measures the phases of an HTTP round trip with httptrace:
+//
+//	var timer hargo.RoundTripTimer
+//	resp, err := client.Do(timer.Trace(req))
+//	body, err := io.ReadAll(resp.Body)
+//	timer.Done()
+//	b.Entry(timer.Started(), hargo.NewRequest(req, nil), hargo.NewResponse(resp, body), timer.Timings())
+type RoundTripTimer struct {
+	start, end                time.Time
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	gotConn, wroteRequest     time.Time
+	firstByte                 time.Time
+	remoteAddr                string
+}
+
+// Trace starts the timer and returns a copy of req that reports to it.
+func (t *RoundTripTimer) Trace(req *http.Request) *http.Request {
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { t.dnsStart = time.Now() },
+		DNSDone:           func(httptrace.DNSDoneInfo) { t.dnsDone = time.Now() },
+		ConnectStart:      func(string, string) { t.connectStart = time.Now() },
+		ConnectDone:       func(string, string, error) { t.connectDone = time.Now() },
+		TLSHandshakeStart: func() { t.tlsStart = time.Now() },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.tlsDone = time.Now() },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.gotConn = time.Now()
+			if info.Conn != nil {
+				t.remoteAddr = info.Conn.RemoteAddr().String()
+			}
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.wroteRequest = time.Now() },
+		GotFirstResponseByte: func() { t.firstByte = time.Now() },
+	}
+	t.start = time.Now()
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// Done stops the timer once the response body has been read.
+func (t *RoundTripTimer) Done() {
+	t.end = time.Now()
+}
+
+// Started returns the time the request was started.
+func (t *RoundTripTimer) Started() time.Time {
+	return t.start
+}
+
+// ServerIPAddress returns the IP address of the server connected to, or ""
+// if unknown.
+func (t *RoundTripTimer) ServerIPAddress() string {
+	return serverIP(t.remoteAddr)
+}
+
+// Timings returns the measured phases. Phases that did not happen, like
+// DNS and connect on a reused connection, are -1.
+func (t *RoundTripTimer) Timings() PageTimings {
+	end := t.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+	firstByte, wroteRequest, gotConn := t.firstByte, t.wroteRequest, t.gotConn
+	if firstByte.IsZero() {
+		firstByte = end
+	}
+	if wroteRequest.IsZero() {
+		wroteRequest = firstByte
+	}
+	if gotConn.IsZero() {
+		gotConn = wroteRequest
+	}
+
+	timings := PageTimings{DNS: -1, Connect: -1, Ssl: -1}
+	if !t.dnsStart.IsZero() && !t.dnsDone.IsZero() {
+		timings.DNS = milliseconds(t.dnsDone.Sub(t.dnsStart))
+	}
+	if !t.connectStart.IsZero() && !t.connectDone.IsZero() {
+		// connect includes the TLS handshake for HAR 1.1 compatibility
+		connectEnd := t.connectDone
+		if t.tlsDone.After(connectEnd) {
+			connectEnd = t.tlsDone
+		}
+		timings.Connect = milliseconds(connectEnd.Sub(t.connectStart))
+	}
+	if !t.tlsStart.IsZero() && !t.tlsDone.IsZero() {
+		timings.Ssl = milliseconds(t.tlsDone.Sub(t.tlsStart))
+	}
+	timings.Blocked = milliseconds(gotConn.Sub(t.start)) - positive(timings.DNS) - positive(timings.Connect)
+	if timings.Blocked < 0 {
+		timings.Blocked = 0
+	}
+	timings.Send = milliseconds(wroteRequest.Sub(gotConn))
+	timings.Wait = milliseconds(firstByte.Sub(wroteRequest))
+	timings.Receive = milliseconds(end.Sub(firstByte))
+	return timings
+}
+
+// captureEntry executes req and records it as an Entry, measuring the
+// phases of the round trip with a RoundTripTimer.
+func captureEntry(client *http.Client, req *http.Request) (Entry, []byte, *http.Response, error) {
+	var timer RoundTripTimer
+	resp, err := client.Do(timer.Trace(req))
+	if err != nil {
+		return Entry{}, nil, nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return Entry{}, nil, nil, err
+	}
+	timer.Done()
+
+	timings := timer.Timings()
+	entry := Entry{
+		StartedDateTime: timer.Started().Format(harDateTimeLayout),
+		Time:            totalTime(timings),
+		Request:         NewRequest(req, nil),
+		Response:        NewResponse(resp, body),
+		Timings:         timings,
+		ServerIPAddress: timer.ServerIPAddress(),
+	}
+
+	return entry, body, resp, nil
+}
@@ -0,0 +1,93 @@
+package hargo
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuilder(t *testing.T) {
+	started := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	req := Request{Method: "GET", URL: "https://example.com/", HTTPVersion: "HTTP/1.1", HeaderSize: -1, BodySize: 0}
+	resp := Response{Status: 200, StatusText: "OK", HTTPVersion: "HTTP/1.1", HeadersSize: -1, BodySize: 5,
+		Content: Content{Size: 5, MimeType: "text/html", Text: "hello"}}
+	timings := PageTimings{Blocked: 1, DNS: -1, Connect: -1, Ssl: -1, Send: 2, Wait: 30, Receive: 4}
+
+	har := NewHar().
+		Creator("tool", "1.0").
+		Page("page_1", "Home", started).
+		PageTimings(50*time.Millisecond, 120*time.Millisecond).
+		Entry(started, req, resp, timings).
+		AddEntry(Entry{Pageref: "other", StartedDateTime: "2024-01-02T10:00:01.000Z"}).
+		Har()
+
+	if har.Log.Version != "1.2" || har.Log.Creator.Name != "tool" || len(har.Log.Pages) != 1 {
+		t.Errorf("unexpected log %+v", har.Log)
+	}
+	if p := har.Log.Pages[0]; p.StartedDateTime != "2024-01-02T10:00:00.000Z" || p.PageTiming.OnLoad != 120 {
+		t.Errorf("unexpected page %+v", p)
+	}
+
+	entry := har.Log.Entries[0]
+	if entry.Pageref != "page_1" || entry.Time != 37 || entry.StartedDateTime != "2024-01-02T10:00:00.000Z" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	if har.Log.Entries[1].Pageref != "other" {
+		t.Error("explicit pageref overwritten")
+	}
+}
+
+func TestBuilderFromHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte{0, 1, 2})
+	}))
+	defer server.Close()
+
+	b := NewHar().Page("page_1", "Test", time.Now())
+
+	req, _ := http.NewRequest("POST", server.URL+"/upload?x=1", strings.NewReader("data"))
+	req.Header.Set("Content-Type", "text/plain")
+	resp, body, err := b.Do(http.DefaultClient, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || len(body) != 3 {
+		t.Errorf("unexpected response %d %v", resp.StatusCode, body)
+	}
+
+	// manual timing
+	var timer RoundTripTimer
+	req, _ = http.NewRequest("GET", server.URL+"/", nil)
+	resp, err = http.DefaultClient.Do(timer.Trace(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	timer.Done()
+	b.Entry(timer.Started(), NewRequest(req, nil), NewResponse(resp, body), timer.Timings())
+
+	har := b.Har()
+	if len(har.Log.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(har.Log.Entries))
+	}
+	for _, entry := range har.Log.Entries {
+		if entry.Pageref != "page_1" || entry.Response.Status != 200 || entry.Response.Content.Encoding != "base64" ||
+			entry.Response.Content.Text != "AAEC" || len(entry.Response.Cookies) != 1 {
+			t.Errorf("unexpected entry %+v", entry)
+		}
+		if entry.Timings.Wait < 0 || entry.Timings.Receive < 0 || entry.Time < 0 {
+			t.Errorf("invalid timings %+v", entry.Timings)
+		}
+	}
+
+	first := har.Log.Entries[0]
+	if first.Request.Method != "POST" || first.Request.PostData.Text != "data" || len(first.Request.QueryString) != 1 || first.ServerIPAddress != "127.0.0.1" {
+		t.Errorf("unexpected request %+v", first)
+	}
+}
@@ -0,0 +1,294 @@
+package hargo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Cache classes of a response.
+const (
+	CacheCacheable   = "cacheable"
+	CacheShortLived  = "short-lived"
+	CacheUncacheable = "uncacheable"
+)
+
+// CachingOptions control how responses are classified.
+type CachingOptions struct {
+	// MinTTL is the freshness lifetime below which a response is short-lived
+	// (default 7 days).
+	MinTTL time.Duration
+	// RepeatAfter is when the repeat view is assumed to happen (default 24h).
+	RepeatAfter time.Duration
+}
+
+// DefaultCachingOptions are used for zero fields of CachingOptions.
+var DefaultCachingOptions = CachingOptions{MinTTL: 7 * 24 * time.Hour, RepeatAfter: 24 * time.Hour}
+
+// CachedResponse is the cache policy of one response.
+type CachedResponse struct {
+	// Entry is the index of the entry in the .har file.
+	Entry int    `json:"entry"`
+	URL   string `json:"url"`
+	Type  string `json:"type"`
+	Class string `json:"class"`
+	// TTL is the freshness lifetime in seconds.
+	TTL float64 `json:"ttl"`
+	// Validator is set if the response has an ETag or Last-Modified header,
+	// so expired copies can be revalidated with a 304.
+	Validator bool `json:"validator"`
+	// Bytes received on the first view and saved on the repeat view.
+	Bytes int64 `json:"bytes"`
+	Saved int64 `json:"saved"`
+}
+
+// CachingReport summarizes how well the responses of a .har file can be
+// cached by a browser.
+type CachingReport struct {
+	Responses []CachedResponse      `json:"responses"`
+	Classes   map[string]*ByteCount `json:"classes"`
+	// Bytes received on the first view and estimated savings on a repeat view.
+	Bytes int64 `json:"bytes"`
+	Saved int64 `json:"saved"`
+}
+
+// Offenders returns the responses losing the most bytes on a repeat view,
+// largest first.
+func (c CachingReport) Offenders() []CachedResponse {
+	var offenders []CachedResponse
+	for _, r := range c.Responses {
+		if r.Bytes > r.Saved {
+			offenders = append(offenders, r)
+		}
+	}
+	sort.SliceStable(offenders, func(i, j int) bool {
+		return offenders[i].Bytes-offenders[i].Saved > offenders[j].Bytes-offenders[j].Saved
+	})
+	return offenders
+}
+
+// AnalyzeCaching evaluates Cache-Control, Expires, ETag and Last-Modified on
+// every GET response that browsers may cache. Responses fresh for at least
+// MinTTL are cacheable, fresher ones short-lived and the rest (no-store,
+// no-cache, max-age=0, no freshness information) uncacheable. The repeat
+// view saves the whole response if it is still fresh after RepeatAfter, and
+// its body if it can be revalidated.
+func AnalyzeCaching(har Har, opts CachingOptions) CachingReport {
+	if opts.MinTTL <= 0 {
+		opts.MinTTL = DefaultCachingOptions.MinTTL
+	}
+	if opts.RepeatAfter <= 0 {
+		opts.RepeatAfter = DefaultCachingOptions.RepeatAfter
+	}
+
+	report := CachingReport{Classes: make(map[string]*ByteCount)}
+	for _, class := range []string{CacheCacheable, CacheShortLived, CacheUncacheable} {
+		report.Classes[class] = &ByteCount{}
+	}
+
+	for i, entry := range har.Log.Entries {
+		if !strings.EqualFold(entry.Request.Method, "GET") || !heuristicallyCacheable(entry.Response.Status) {
+			continue
+		}
+
+		ttl, storable := freshnessLifetime(entry)
+		headers := entry.Response.Headers
+		r := CachedResponse{
+			Entry:     i,
+			URL:       entry.Request.URL,
+			Type:      getTypeDirectory(entry.Response.Content.MimeType),
+			TTL:       ttl.Seconds(),
+			Validator: recordedHeader(headers, "ETag") != "" || recordedHeader(headers, "Last-Modified") != "",
+			Bytes:     responseBytes(entry.Response),
+		}
+
+		switch {
+		case !storable || ttl <= 0:
+			r.Class = CacheUncacheable
+		case ttl < opts.MinTTL:
+			r.Class = CacheShortLived
+		default:
+			r.Class = CacheCacheable
+		}
+
+		switch {
+		case storable && ttl >= opts.RepeatAfter:
+			r.Saved = r.Bytes
+		case storable && r.Validator:
+			r.Saved = r.Bytes - responseHeaderBytes(entry.Response)
+		}
+
+		report.Responses = append(report.Responses, r)
+		report.Classes[r.Class].add(requestBytes(entry.Request), r.Bytes)
+		report.Bytes += r.Bytes
+		report.Saved += r.Saved
+	}
+
+	return report
+}
+
+// heuristicallyCacheable reports whether responses with the status code may
+// be stored by a cache (RFC 9110 section 15.1).
+func heuristicallyCacheable(status int) bool {
+	switch status {
+	case 200, 203, 204, 206, 300, 301, 308, 404, 405, 410, 414, 501:
+		return true
+	}
+	return false
+}
+
+// freshnessLifetime returns how long a browser may reuse a response without
+// revalidation, and whether it may store it at all.
+func freshnessLifetime(entry Entry) (time.Duration, bool) {
+	var directives []string
+	for _, h := range entry.Response.Headers {
+		if strings.EqualFold(h.Name, "Cache-Control") {
+			directives = append(directives, strings.Split(h.Value, ",")...)
+		}
+	}
+
+	maxAge := -1
+	for _, d := range directives {
+		name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			return 0, false
+		case "no-cache":
+			return 0, true
+		case "max-age":
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && maxAge < 0 {
+				maxAge = seconds
+			}
+		}
+	}
+	if maxAge >= 0 {
+		return time.Duration(maxAge) * time.Second, true
+	}
+
+	headers := entry.Response.Headers
+	date, err := http.ParseTime(recordedHeader(headers, "Date"))
+	if err != nil {
+		if date, err = parseStartedDateTime(entry.StartedDateTime); err != nil {
+			return 0, true
+		}
+	}
+
+	if expires := recordedHeader(headers, "Expires"); expires != "" {
+		// invalid dates, like "0", mean already expired
+		t, err := http.ParseTime(expires)
+		if err != nil || t.Before(date) {
+			return 0, true
+		}
+		return t.Sub(date), true
+	}
+
+	// heuristic freshness: 10% of the time since the last modification
+	if lastModified, err := http.ParseTime(recordedHeader(headers, "Last-Modified")); err == nil && lastModified.Before(date) {
+		return date.Sub(lastModified) / 10, true
+	}
+
+	return 0, true
+}
+
+// CachingHTML writes the cache classes, the repeat-view savings and the top
+// offenders of a .har file to w as a self-contained HTML report.
+func CachingHTML(r *bufio.Reader, w io.Writer, opts CachingOptions, top int) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	return WriteAnalysisReport(w, CachingHTMLReport(reportTitle(har), AnalyzeCaching(har, opts), top))
+}
+
+// CachingHTMLReport returns the HTML report of a caching analysis, with at
+// most top offenders.
+func CachingHTMLReport(title string, report CachingReport, top int) AnalysisReport {
+	percent := 0.0
+	if report.Bytes > 0 {
+		percent = float64(report.Saved) / float64(report.Bytes) * 100
+	}
+
+	html := AnalysisReport{
+		Title: title,
+		Figures: []ReportFigure{
+			{"bytes received", strconv.FormatInt(report.Bytes, 10)},
+			{"bytes saved on a repeat view", strconv.FormatInt(report.Saved, 10)},
+			{"saved", fmt.Sprintf("%.1f%%", percent)},
+		},
+	}
+
+	chart := ReportChart{Title: "Received by cache class"}
+	classes := ReportSection{
+		Title:   "Cache classes",
+		Columns: []string{"Class", "Requests", "Received (bytes)"},
+		Numeric: []bool{false, true, true},
+	}
+	for _, class := range []string{CacheCacheable, CacheShortLived, CacheUncacheable} {
+		c := report.Classes[class]
+		chart.Bars = append(chart.Bars, ReportBar{Label: class, Value: float64(c.Received), Text: formatBytes(c.Received)})
+		classes.Rows = append(classes.Rows, []string{class, strconv.Itoa(c.Requests), strconv.FormatInt(c.Received, 10)})
+	}
+	html.Charts = append(html.Charts, chart)
+	html.Sections = append(html.Sections, classes)
+
+	offenders := ReportSection{
+		Title:   "Offenders",
+		Columns: []string{"URL", "Type", "Class", "TTL (s)", "Lost (bytes)"},
+		Numeric: []bool{false, false, false, true, true},
+	}
+	for i, o := range report.Offenders() {
+		if i == top {
+			break
+		}
+		offenders.Rows = append(offenders.Rows, []string{o.URL, o.Type, o.Class, strconv.FormatFloat(o.TTL, 'f', 0, 64), strconv.FormatInt(o.Bytes-o.Saved, 10)})
+	}
+	if len(offenders.Rows) > 0 {
+		html.Sections = append(html.Sections, offenders)
+	}
+	return html
+}
+
+// Caching prints the cache classes, the repeat-view savings and the top
+// offenders of a .har file to w.
+func Caching(r *bufio.Reader, w io.Writer, opts CachingOptions, top int) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	report := AnalyzeCaching(har, opts)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t\n", "Class", "Requests", "Received (bytes)")
+	for _, class := range []string{CacheCacheable, CacheShortLived, CacheUncacheable} {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t\n", class, report.Classes[class].Requests, report.Classes[class].Received)
+	}
+	fmt.Fprintln(tw)
+
+	percent := 0.0
+	if report.Bytes > 0 {
+		percent = float64(report.Saved) / float64(report.Bytes) * 100
+	}
+	fmt.Fprintf(tw, "Repeat view saves\t%d of %d bytes (%.1f%%)\t\n", report.Saved, report.Bytes, percent)
+
+	if offenders := report.Offenders(); len(offenders) > 0 && top > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", "URL", "Type", "Class", "TTL", "Lost (bytes)")
+		for i, o := range offenders {
+			if i == top {
+				break
+			}
+			ttl := time.Duration(o.TTL) * time.Second
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t\n", shortURL(o.URL), o.Type, o.Class, ttl, o.Bytes-o.Saved)
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,97 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnalyzeCaching(t *testing.T) {
+	entry := func(url string, status int, headers ...NVP) Entry {
+		return Entry{
+			StartedDateTime: "2024-01-02T10:00:00.000Z",
+			Request:         Request{Method: "GET", URL: url},
+			Response:        Response{Status: status, Headers: headers, HeadersSize: 100, BodySize: 900},
+		}
+	}
+	date := NVP{Name: "Date", Value: "Tue, 02 Jan 2024 10:00:00 GMT"}
+
+	har := Har{Log: Log{Entries: []Entry{
+		entry("https://example.com/app.js", 200, NVP{Name: "Cache-Control", Value: "public, max-age=31536000, immutable"}),
+		entry("https://example.com/style.css", 200, date, NVP{Name: "Expires", Value: "Wed, 03 Jan 2024 22:00:00 GMT"}),
+		entry("https://example.com/logo.png", 200, NVP{Name: "cache-control", Value: "no-cache"}, NVP{Name: "ETag", Value: `"abc"`}),
+		entry("https://example.com/api", 200, NVP{Name: "Cache-Control", Value: "private, no-store"}, NVP{Name: "ETag", Value: `"def"`}),
+		entry("https://example.com/old.gif", 200, date, NVP{Name: "Last-Modified", Value: "Fri, 02 Dec 2022 10:00:00 GMT"}),
+		entry("https://example.com/moved", 302, NVP{Name: "Cache-Control", Value: "max-age=3600"}),
+	}}}
+
+	report := AnalyzeCaching(har, CachingOptions{})
+
+	var got []string
+	for _, r := range report.Responses {
+		got = append(got, r.Class+" "+time.Duration(r.TTL*float64(time.Second)).String())
+	}
+	expected := []string{
+		"cacheable 8760h0m0s",
+		"short-lived 36h0m0s",
+		"uncacheable 0s",
+		"uncacheable 0s",
+		"cacheable 950h24m0s",
+	}
+	if strings.Join(got, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("classes %v, expected %v", got, expected)
+	}
+
+	if report.Bytes != 5000 || report.Saved != 3900 {
+		t.Errorf("saved %d of %d bytes, expected 3900 of 5000", report.Saved, report.Bytes)
+	}
+	if c := report.Classes[CacheUncacheable]; c.Requests != 2 || c.Received != 2000 {
+		t.Errorf("unexpected uncacheable count %+v", c)
+	}
+
+	offenders := report.Offenders()
+	if len(offenders) != 2 || offenders[0].URL != "https://example.com/api" || offenders[1].Bytes-offenders[1].Saved != 100 {
+		t.Errorf("unexpected offenders %+v", offenders)
+	}
+}
+
+func TestCaching(t *testing.T) {
+	data, err := json.Marshal(Har{Log: Log{Entries: []Entry{{
+		Request:  Request{Method: "GET", URL: "https://example.com/"},
+		Response: Response{Status: 200, HeadersSize: 100, BodySize: 900},
+	}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := Caching(bufio.NewReader(bytes.NewReader(data)), &out, CachingOptions{}, 10); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "0 of 1000 bytes (0.0%)") || !strings.Contains(out.String(), "https://example.com/") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestCachingHTML(t *testing.T) {
+	data, err := json.Marshal(Har{Log: Log{Entries: []Entry{{
+		Request:  Request{Method: "GET", URL: "https://example.com/"},
+		Response: Response{Status: 200, HeadersSize: 100, BodySize: 900},
+	}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := CachingHTML(bufio.NewReader(bytes.NewReader(data)), &out, CachingOptions{}, 10); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"<h2>Offenders</h2>", "<b>0.0%</b>saved", "1000 B"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("output does not contain %q", expected)
+		}
+	}
+}
@@ -0,0 +1,674 @@
+package hargo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/websocket"
+)
+
+// CaptureOptions controls a DevTools Protocol capture.
+type CaptureOptions struct {
+	// Wait is how long the network must be idle after the load event before
+	// the capture ends, so late requests are included.
+	Wait time.Duration
+	// Timeout bounds the whole navigation.
+	Timeout time.Duration
+}
+
+// DefaultCaptureOptions waits two seconds of network idle and gives up after
+// one minute.
+var DefaultCaptureOptions = CaptureOptions{Wait: 2 * time.Second, Timeout: time.Minute}
+
+// Capture connects to a Chrome instance via the DevTools Protocol, navigates
+// a new tab to pageURL and builds a Har from the Network domain events,
+// including detailed timings, initiators and response bodies. endpoint is the
+// browser or page WebSocket debugger URL (ws://host:9222/devtools/browser/...)
+// or the HTTP address of the debugging port (http://host:9222), from which the
+// browser URL is discovered.
+func Capture(endpoint string, pageURL string, opts CaptureOptions) (Har, error) {
+	if err := requireNetwork("capture"); err != nil {
+		return Har{}, err
+	}
+
+	wsURL, err := cdpWebSocketURL(endpoint)
+	if err != nil {
+		return Har{}, err
+	}
+
+	c, err := dialCDP(wsURL)
+	if err != nil {
+		return Har{}, err
+	}
+	defer c.close()
+
+	emitPhase(EventPhaseStarted, "capture")
+	defer emitPhase(EventPhaseFinished, "capture")
+
+	if strings.Contains(wsURL, "/devtools/browser/") {
+		var target struct {
+			TargetID string `json:"targetId"`
+		}
+		if err := c.call("Target.createTarget", map[string]interface{}{"url": "about:blank"}, &target); err != nil {
+			return Har{}, err
+		}
+		defer c.callBrowser("Target.closeTarget", map[string]interface{}{"targetId": target.TargetID}, nil)
+
+		var session struct {
+			SessionID string `json:"sessionId"`
+		}
+		if err := c.call("Target.attachToTarget", map[string]interface{}{"targetId": target.TargetID, "flatten": true}, &session); err != nil {
+			return Har{}, err
+		}
+		c.sessionID = session.SessionID
+	}
+
+	for _, method := range []string{"Network.enable", "Page.enable"} {
+		if err := c.call(method, nil, nil); err != nil {
+			return Har{}, err
+		}
+	}
+
+	recorder := newCDPRecorder()
+	started := time.Now()
+
+	var nav struct {
+		ErrorText string `json:"errorText"`
+	}
+	if err := c.call("Page.navigate", map[string]interface{}{"url": pageURL}, &nav); err != nil {
+		return Har{}, err
+	}
+	if nav.ErrorText != "" {
+		return Har{}, fmt.Errorf("navigation to %s failed: %s", pageURL, nav.ErrorText)
+	}
+
+	deadline := started.Add(opts.Timeout)
+	for {
+		wait := time.Until(deadline)
+		if recorder.loaded && recorder.idle() {
+			if idle := opts.Wait - time.Since(recorder.lastActivity); idle < wait {
+				wait = idle
+			}
+		}
+		if wait <= 0 {
+			break
+		}
+
+		msg, ok, err := c.next(wait)
+		if err != nil {
+			return Har{}, err
+		}
+		if !ok {
+			if !recorder.loaded {
+				log.Warnf("Load event of %s not fired within %v", pageURL, opts.Timeout)
+				break
+			}
+			continue
+		}
+		recorder.handle(msg)
+	}
+
+	var title struct {
+		Result struct {
+			Value string `json:"value"`
+		} `json:"result"`
+	}
+	if err := c.call("Runtime.evaluate", map[string]interface{}{"expression": "document.title", "returnByValue": true}, &title); err != nil {
+		log.Warnf("Cannot read page title: %v", err)
+	}
+
+	for i, r := range recorder.requests {
+		if !r.finished || r.response == nil || r.entry.Response.Status == 204 || (r.entry.Response.Status >= 300 && r.entry.Response.Status < 400) {
+			continue
+		}
+		var body struct {
+			Body          string `json:"body"`
+			Base64Encoded bool   `json:"base64Encoded"`
+		}
+		if err := c.call("Network.getResponseBody", map[string]interface{}{"requestId": r.id}, &body); err != nil {
+			log.Debugf("No body for %s: %v", r.entry.Request.URL, err)
+			continue
+		}
+		content := &recorder.requests[i].entry.Response.Content
+		content.Text = body.Body
+		if body.Base64Encoded {
+			content.Encoding = "base64"
+		}
+		if data, err := decodeContent(*content); err == nil {
+			content.Size = len(data)
+		}
+	}
+
+	har := recorder.har(started, title.Result.Value)
+	if har.Log.Pages[0].Title == "" {
+		har.Log.Pages[0].Title = pageURL
+	}
+
+	for i, entry := range har.Log.Entries {
+		emit(Event{Type: EventEntryFetched, Phase: "capture", Index: i, URL: entry.Request.URL, Method: entry.Request.Method,
+			Status: entry.Response.Status, Bytes: int64(entry.Response.Content.Size),
+			Latency: time.Duration(float64(entry.Time) * float64(time.Millisecond))})
+	}
+
+	return har, nil
+}
+
+// CaptureFile captures pageURL via the DevTools Protocol and writes the
+// resulting Har to w.
+func CaptureFile(endpoint string, pageURL string, w io.Writer, opts CaptureOptions) error {
+	har, err := Capture(endpoint, pageURL, opts)
+	if err != nil {
+		return err
+	}
+
+	return Encode(w, har)
+}
+
+// cdpWebSocketURL returns the WebSocket debugger URL for endpoint, querying
+// /json/version for HTTP endpoints.
+func cdpWebSocketURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	switch u.Scheme {
+	case "ws", "wss":
+		return endpoint, nil
+	case "http", "https":
+		u.Path = "/json/version"
+		resp, err := http.Get(u.String())
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+
+		var version struct {
+			WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+			return "", fmt.Errorf("invalid response from %s: %v", u, err)
+		}
+		if version.WebSocketDebuggerURL == "" {
+			return "", fmt.Errorf("no webSocketDebuggerUrl at %s", u)
+		}
+		return version.WebSocketDebuggerURL, nil
+	default:
+		return "", fmt.Errorf("unsupported DevTools endpoint: %s", endpoint)
+	}
+}
+
+// cdpMessage is a DevTools Protocol command response or event.
+type cdpMessage struct {
+	ID        int             `json:"id,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// cdpCommand is a DevTools Protocol command.
+type cdpCommand struct {
+	ID        int         `json:"id"`
+	SessionID string      `json:"sessionId,omitempty"`
+	Method    string      `json:"method"`
+	Params    interface{} `json:"params,omitempty"`
+}
+
+// cdpClient is a minimal DevTools Protocol client for a single session.
+// Messages are read by a background goroutine; events received while waiting
+// for a command response are queued for next.
+type cdpClient struct {
+	ws        *websocket.Conn
+	sessionID string
+	nextID    int
+	messages  chan cdpMessage
+	done      chan struct{}
+	readErr   error
+	queue     []cdpMessage
+}
+
+func dialCDP(wsURL string) (*cdpClient, error) {
+	// Chrome only accepts this origin when started with
+	// --remote-allow-origins
+	ws, err := websocket.Dial(wsURL, "", "http://localhost/")
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to %s: %v", wsURL, err)
+	}
+	ws.MaxPayloadBytes = 256 << 20
+
+	c := &cdpClient{ws: ws, messages: make(chan cdpMessage, 256), done: make(chan struct{})}
+	go func() {
+		defer close(c.messages)
+		for {
+			var msg cdpMessage
+			if err := websocket.JSON.Receive(ws, &msg); err != nil {
+				c.readErr = err
+				return
+			}
+			select {
+			case c.messages <- msg:
+			case <-c.done:
+				return
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+func (c *cdpClient) close() {
+	close(c.done)
+	c.ws.Close()
+}
+
+// call sends a command to the attached session and decodes its result.
+func (c *cdpClient) call(method string, params interface{}, result interface{}) error {
+	return c.send(c.sessionID, method, params, result)
+}
+
+// callBrowser sends a command to the browser rather than the session.
+func (c *cdpClient) callBrowser(method string, params interface{}, result interface{}) error {
+	return c.send("", method, params, result)
+}
+
+func (c *cdpClient) send(sessionID string, method string, params interface{}, result interface{}) error {
+	c.nextID++
+	id := c.nextID
+
+	if err := websocket.JSON.Send(c.ws, cdpCommand{ID: id, SessionID: sessionID, Method: method, Params: params}); err != nil {
+		return fmt.Errorf("%s: %v", method, err)
+	}
+
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				return fmt.Errorf("%s: connection closed: %v", method, c.readErr)
+			}
+			if msg.ID != id {
+				if msg.Method != "" {
+					c.queue = append(c.queue, msg)
+				}
+				continue
+			}
+			if msg.Error != nil {
+				return fmt.Errorf("%s: %s", method, msg.Error.Message)
+			}
+			if result != nil && len(msg.Result) > 0 {
+				return json.Unmarshal(msg.Result, result)
+			}
+			return nil
+		case <-timeout:
+			return fmt.Errorf("%s: no response", method)
+		}
+	}
+}
+
+// next returns the next event of the attached session, waiting at most
+// timeout. ok is false if no event arrived in time.
+func (c *cdpClient) next(timeout time.Duration) (msg cdpMessage, ok bool, err error) {
+	for {
+		if len(c.queue) > 0 {
+			msg, c.queue = c.queue[0], c.queue[1:]
+		} else {
+			timer := time.NewTimer(timeout)
+			select {
+			case msg, ok = <-c.messages:
+				timer.Stop()
+				if !ok {
+					return msg, false, fmt.Errorf("connection closed: %v", c.readErr)
+				}
+			case <-timer.C:
+				return msg, false, nil
+			}
+		}
+		if msg.Method != "" && msg.SessionID == c.sessionID {
+			return msg, true, nil
+		}
+	}
+}
+
+// cdpResponse is the Network.Response object of the DevTools Protocol.
+type cdpResponse struct {
+	URL               string            `json:"url"`
+	Status            int               `json:"status"`
+	StatusText        string            `json:"statusText"`
+	Headers           map[string]string `json:"headers"`
+	MimeType          string            `json:"mimeType"`
+	RequestHeaders    map[string]string `json:"requestHeaders"`
+	ConnectionID      float64           `json:"connectionId"`
+	RemoteIPAddress   string            `json:"remoteIPAddress"`
+	FromDiskCache     bool              `json:"fromDiskCache"`
+	EncodedDataLength float64           `json:"encodedDataLength"`
+	Protocol          string            `json:"protocol"`
+	Timing            *struct {
+		RequestTime       float64 `json:"requestTime"`
+		DNSStart          float64 `json:"dnsStart"`
+		DNSEnd            float64 `json:"dnsEnd"`
+		ConnectStart      float64 `json:"connectStart"`
+		ConnectEnd        float64 `json:"connectEnd"`
+		SslStart          float64 `json:"sslStart"`
+		SslEnd            float64 `json:"sslEnd"`
+		SendStart         float64 `json:"sendStart"`
+		SendEnd           float64 `json:"sendEnd"`
+		ReceiveHeadersEnd float64 `json:"receiveHeadersEnd"`
+	} `json:"timing"`
+}
+
+// cdpRequest tracks one request (one hop of a redirect chain).
+type cdpRequest struct {
+	id        string
+	entry     Entry
+	timestamp float64
+	response  *cdpResponse
+	respondAt float64
+	finishAt  float64
+	finished  bool
+}
+
+// cdpRecorder builds HAR entries from Network and Page domain events.
+type cdpRecorder struct {
+	requests      []cdpRequest
+	active        map[string]int
+	firstTs       float64
+	contentLoadTs float64
+	loadTs        float64
+	loaded        bool
+	lastActivity  time.Time
+}
+
+func newCDPRecorder() *cdpRecorder {
+	return &cdpRecorder{active: make(map[string]int), lastActivity: time.Now()}
+}
+
+// idle reports whether no request is in flight.
+func (r *cdpRecorder) idle() bool {
+	return len(r.active) == 0
+}
+
+func (r *cdpRecorder) handle(msg cdpMessage) {
+	switch msg.Method {
+	case "Network.requestWillBeSent":
+		var p struct {
+			RequestID string  `json:"requestId"`
+			Timestamp float64 `json:"timestamp"`
+			WallTime  float64 `json:"wallTime"`
+			Type      string  `json:"type"`
+			Request   struct {
+				URL      string            `json:"url"`
+				Method   string            `json:"method"`
+				Headers  map[string]string `json:"headers"`
+				PostData string            `json:"postData"`
+			} `json:"request"`
+			Initiator        *Initiator   `json:"initiator"`
+			RedirectResponse *cdpResponse `json:"redirectResponse"`
+		}
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return
+		}
+		if p.RedirectResponse != nil {
+			if i, ok := r.active[p.RequestID]; ok {
+				r.respond(i, p.RedirectResponse, p.Timestamp)
+				r.finish(i, p.Timestamp)
+			}
+		}
+		if r.firstTs == 0 {
+			r.firstTs = p.Timestamp
+		}
+
+		entry := Entry{
+			Pageref:         "page_1",
+			StartedDateTime: cdpWallTime(p.WallTime).Format(harDateTimeLayout),
+			Request: Request{
+				Method:      p.Request.Method,
+				URL:         p.Request.URL,
+				HTTPVersion: "HTTP/1.1",
+				Headers:     cdpHeaders(p.Request.Headers),
+				HeaderSize:  -1,
+				BodySize:    len(p.Request.PostData),
+			},
+			Initiator:    p.Initiator,
+			ResourceType: strings.ToLower(p.Type),
+		}
+		if u, err := url.Parse(p.Request.URL); err == nil {
+			entry.Request.QueryString = queryNVPs(u.Query())
+		}
+		if p.Request.PostData != "" {
+			entry.Request.PostData = PostData{MimeType: recordedHeader(entry.Request.Headers, "Content-Type"), Text: p.Request.PostData}
+		}
+		entry.Request.Cookies = harCookies((&http.Request{Header: nvpHeader(entry.Request.Headers)}).Cookies())
+
+		r.requests = append(r.requests, cdpRequest{id: p.RequestID, entry: entry, timestamp: p.Timestamp})
+		r.active[p.RequestID] = len(r.requests) - 1
+	case "Network.responseReceived":
+		var p struct {
+			RequestID string      `json:"requestId"`
+			Timestamp float64     `json:"timestamp"`
+			Response  cdpResponse `json:"response"`
+		}
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return
+		}
+		if i, ok := r.active[p.RequestID]; ok {
+			r.respond(i, &p.Response, p.Timestamp)
+		}
+	case "Network.loadingFinished", "Network.loadingFailed":
+		var p struct {
+			RequestID         string  `json:"requestId"`
+			Timestamp         float64 `json:"timestamp"`
+			EncodedDataLength float64 `json:"encodedDataLength"`
+			ErrorText         string  `json:"errorText"`
+		}
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return
+		}
+		if i, ok := r.active[p.RequestID]; ok {
+			if p.ErrorText != "" {
+				r.requests[i].entry.Response.Comment = p.ErrorText
+			} else if p.EncodedDataLength > 0 {
+				r.requests[i].entry.Response.BodySize = int(p.EncodedDataLength)
+			}
+			r.finish(i, p.Timestamp)
+		}
+	case "Page.domContentEventFired", "Page.loadEventFired":
+		var p struct {
+			Timestamp float64 `json:"timestamp"`
+		}
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return
+		}
+		if msg.Method == "Page.loadEventFired" {
+			r.loadTs = p.Timestamp
+			r.loaded = true
+		} else {
+			r.contentLoadTs = p.Timestamp
+		}
+	default:
+		return
+	}
+
+	r.lastActivity = time.Now()
+}
+
+// respond records the response of request i.
+func (r *cdpRecorder) respond(i int, resp *cdpResponse, ts float64) {
+	req := &r.requests[i]
+	req.response = resp
+	req.respondAt = ts
+
+	if len(resp.RequestHeaders) > 0 {
+		req.entry.Request.Headers = cdpHeaders(resp.RequestHeaders)
+	}
+
+	httpVersion := cdpHTTPVersion(resp.Protocol)
+	req.entry.Request.HTTPVersion = httpVersion
+
+	headers := cdpHeaders(resp.Headers)
+	req.entry.Response = Response{
+		Status:      resp.Status,
+		StatusText:  resp.StatusText,
+		HTTPVersion: httpVersion,
+		Headers:     headers,
+		Cookies:     harCookies((&http.Response{Header: nvpHeader(headers)}).Cookies()),
+		Content:     Content{MimeType: resp.MimeType},
+		RedirectURL: recordedHeader(headers, "Location"),
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+	req.entry.Protocol = normalizeProtocol(resp.Protocol)
+	req.entry.ServerIPAddress = strings.Trim(resp.RemoteIPAddress, "[]")
+	if resp.ConnectionID > 0 {
+		req.entry.Connection = strconv.FormatFloat(resp.ConnectionID, 'f', -1, 64)
+	}
+}
+
+// finish computes the timings of request i once it completed.
+func (r *cdpRecorder) finish(i int, ts float64) {
+	req := &r.requests[i]
+	req.finished = true
+	req.finishAt = ts
+	delete(r.active, req.id)
+
+	t := PageTimings{DNS: -1, Connect: -1, Ssl: -1}
+
+	if req.response != nil && req.response.Timing != nil {
+		timing := req.response.Timing
+		queued := math.Max(0, (timing.RequestTime-req.timestamp)*1000)
+
+		blockedEnd := timing.SendStart
+		for _, start := range []float64{timing.ConnectStart, timing.DNSStart} {
+			if start >= 0 {
+				blockedEnd = start
+			}
+		}
+		t.Blocked = queued + math.Max(0, blockedEnd)
+
+		if timing.DNSStart >= 0 {
+			t.DNS = timing.DNSEnd - timing.DNSStart
+		}
+		if timing.ConnectStart >= 0 {
+			t.Connect = timing.ConnectEnd - timing.ConnectStart
+		}
+		if timing.SslStart >= 0 {
+			t.Ssl = timing.SslEnd - timing.SslStart
+		}
+		t.Send = timing.SendEnd - timing.SendStart
+		t.Wait = timing.ReceiveHeadersEnd - timing.SendEnd
+		t.Receive = math.Max(0, (ts-timing.RequestTime)*1000-timing.ReceiveHeadersEnd)
+	} else if req.response != nil {
+		t.Wait = math.Max(0, (req.respondAt-req.timestamp)*1000)
+		t.Receive = math.Max(0, (ts-req.respondAt)*1000)
+	} else {
+		t.Blocked = math.Max(0, (ts-req.timestamp)*1000)
+	}
+
+	req.entry.Timings = t
+	req.entry.Time = float32(positive(t.Blocked) + positive(t.DNS) + positive(t.Connect) +
+		positive(t.Send) + positive(t.Wait) + positive(t.Receive))
+}
+
+// har assembles the recorded requests into a Har with a single page.
+func (r *cdpRecorder) har(started time.Time, title string) Har {
+	pageTiming := PageTiming{OnContentLoad: -1, OnLoad: -1}
+	if r.contentLoadTs > 0 {
+		pageTiming.OnContentLoad = (r.contentLoadTs - r.firstTs) * 1000
+	}
+	if r.loadTs > 0 {
+		pageTiming.OnLoad = (r.loadTs - r.firstTs) * 1000
+	}
+
+	pageStart := started
+	entries := make([]Entry, 0, len(r.requests))
+	for i, req := range r.requests {
+		if i == 0 {
+			if t, err := parseStartedDateTime(req.entry.StartedDateTime); err == nil {
+				pageStart = t
+			}
+		}
+		if !req.finished {
+			// still in flight when the capture ended
+			r.finish(i, req.timestamp)
+			req = r.requests[i]
+		}
+		entries = append(entries, req.entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime < entries[j].StartedDateTime
+	})
+
+	return Har{
+		Log: Log{
+			Version: "1.2",
+			Creator: Creator{Name: "hargo"},
+			Browser: Browser{Name: "Chrome"},
+			Pages: []Page{{
+				StartedDateTime: pageStart.Format(harDateTimeLayout),
+				ID:              "page_1",
+				Title:           title,
+				PageTiming:      pageTiming,
+			}},
+			Entries: entries,
+		},
+	}
+}
+
+// cdpHeaders converts a DevTools headers object, where repeated headers are
+// joined by newlines, to sorted name/value pairs.
+func cdpHeaders(headers map[string]string) []NVP {
+	h := http.Header{}
+	for name, value := range headers {
+		for _, v := range strings.Split(value, "\n") {
+			h[name] = append(h[name], v)
+		}
+	}
+	return headerNVPs(h)
+}
+
+// nvpHeader converts name/value pairs to http.Header.
+func nvpHeader(nvps []NVP) http.Header {
+	h := http.Header{}
+	for _, nvp := range nvps {
+		h.Add(nvp.Name, nvp.Value)
+	}
+	return h
+}
+
+// cdpHTTPVersion maps DevTools protocol names to HAR httpVersion values.
+func cdpHTTPVersion(protocol string) string {
+	switch strings.ToLower(protocol) {
+	case "h2", "http/2.0":
+		return "HTTP/2.0"
+	case "h3", "http/3", "quic":
+		return "HTTP/3"
+	case "http/1.0":
+		return "HTTP/1.0"
+	case "":
+		return "HTTP/1.1"
+	default:
+		return strings.ToUpper(protocol)
+	}
+}
+
+// cdpWallTime converts DevTools wall time (seconds since the epoch) to
+// time.Time.
+func cdpWallTime(wallTime float64) time.Time {
+	if wallTime == 0 {
+		return time.Now()
+	}
+	return epochSeconds(wallTime)
+}
@@ -0,0 +1,110 @@
+package hargo
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// fakeChrome answers DevTools Protocol commands like a browser loading a page
+// with one script.
+func fakeChrome(ws *websocket.Conn) {
+	send := func(v interface{}) { websocket.JSON.Send(ws, v) }
+	event := func(method string, params string) {
+		send(map[string]interface{}{"sessionId": "S1", "method": method, "params": json.RawMessage(params)})
+	}
+
+	for {
+		var cmd cdpCommand
+		if err := websocket.JSON.Receive(ws, &cmd); err != nil {
+			return
+		}
+
+		result := "{}"
+		var load func()
+		switch cmd.Method {
+		case "Target.createTarget":
+			result = `{"targetId":"T1"}`
+		case "Target.attachToTarget":
+			result = `{"sessionId":"S1"}`
+		case "Page.navigate":
+			result = `{"frameId":"F1"}`
+			load = func() {
+				event("Network.requestWillBeSent", `{"requestId":"1","timestamp":100.0,"wallTime":1700000000.5,"type":"Document",
+					"request":{"url":"https://example.com/","method":"GET","headers":{"Accept":"text/html"}},"initiator":{"type":"other"}}`)
+				event("Network.responseReceived", `{"requestId":"1","timestamp":100.2,"response":{"url":"https://example.com/","status":200,
+					"statusText":"OK","headers":{"Content-Type":"text/html","Set-Cookie":"a=1\nb=2"},"mimeType":"text/html","protocol":"h2",
+					"remoteIPAddress":"93.184.216.34","connectionId":7,"timing":{"requestTime":100.01,"dnsStart":0,"dnsEnd":10,
+					"connectStart":10,"connectEnd":50,"sslStart":20,"sslEnd":50,"sendStart":50,"sendEnd":51,"receiveHeadersEnd":180}}}`)
+				event("Network.requestWillBeSent", `{"requestId":"2","timestamp":100.25,"wallTime":1700000000.75,"type":"Script",
+					"request":{"url":"https://example.com/app.js","method":"GET","headers":{}},"initiator":{"type":"parser","url":"https://example.com/","lineNumber":3}}`)
+				event("Network.loadingFinished", `{"requestId":"1","timestamp":100.3,"encodedDataLength":1234}`)
+				event("Network.responseReceived", `{"requestId":"2","timestamp":100.4,"response":{"url":"https://example.com/app.js","status":200,
+					"statusText":"OK","headers":{},"mimeType":"application/javascript","protocol":"h2"}}`)
+				event("Network.loadingFinished", `{"requestId":"2","timestamp":100.45,"encodedDataLength":20}`)
+				event("Page.domContentEventFired", `{"timestamp":100.5}`)
+				event("Page.loadEventFired", `{"timestamp":100.6}`)
+			}
+		case "Runtime.evaluate":
+			result = `{"result":{"type":"string","value":"Example"}}`
+		case "Network.getResponseBody":
+			if strings.Contains(string(mustJSON(cmd.Params)), `"1"`) {
+				result = `{"body":"<html></html>","base64Encoded":false}`
+			} else {
+				result = `{"body":"Y29uc29sZS5sb2coMSk=","base64Encoded":true}`
+			}
+		}
+		send(map[string]interface{}{"id": cmd.ID, "sessionId": cmd.SessionID, "result": json.RawMessage(result)})
+		if load != nil {
+			load()
+		}
+	}
+}
+
+func mustJSON(v interface{}) []byte {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+func TestCapture(t *testing.T) {
+	server := httptest.NewServer(websocket.Handler(fakeChrome))
+	defer server.Close()
+
+	endpoint := "ws" + strings.TrimPrefix(server.URL, "http") + "/devtools/browser/fake"
+	har, err := Capture(endpoint, "https://example.com/", CaptureOptions{Wait: 50 * time.Millisecond, Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+
+	if len(har.Log.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(har.Log.Entries))
+	}
+
+	page := har.Log.Pages[0]
+	if page.Title != "Example" || page.PageTiming.OnLoad < 599 || page.PageTiming.OnLoad > 601 {
+		t.Errorf("unexpected page %+v", page)
+	}
+
+	doc := har.Log.Entries[0]
+	if doc.Response.Content.Text != "<html></html>" || doc.Response.HTTPVersion != "HTTP/2.0" || len(doc.Response.Cookies) != 2 {
+		t.Errorf("unexpected document response %+v", doc.Response)
+	}
+	if doc.Timings.DNS != 10 || doc.Timings.Connect != 40 || doc.Timings.Ssl != 30 || doc.Timings.Wait != 129 {
+		t.Errorf("unexpected document timings %+v", doc.Timings)
+	}
+	if doc.ServerIPAddress != "93.184.216.34" || doc.Connection != "7" || doc.ResourceType != "document" {
+		t.Errorf("unexpected document entry %+v", doc)
+	}
+
+	script := har.Log.Entries[1]
+	if script.Initiator == nil || script.Initiator.Type != "parser" || script.Initiator.URL != "https://example.com/" {
+		t.Errorf("unexpected initiator %+v", script.Initiator)
+	}
+	if body, _ := decodeContent(script.Response.Content); string(body) != "console.log(1)" {
+		t.Errorf("unexpected script body %q", body)
+	}
+}
@@ -0,0 +1,397 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrCharlesBinary is returned for binary Charles sessions (.chls), which
+// must be exported from Charles as a JSON (.chlsj) or XML (.chlsx) session.
+var ErrCharlesBinary = errors.New("binary Charles session (.chls) is not supported, use File > Export Session as JSON or XML")
+
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// charlesTransaction is a request/response pair of a Charles session, in
+// the layout of the JSON session export.
+type charlesTransaction struct {
+	Status          string          `json:"status"`
+	Method          string          `json:"method"`
+	ProtocolVersion string          `json:"protocolVersion"`
+	Scheme          string          `json:"scheme"`
+	Host            string          `json:"host"`
+	Port            int             `json:"actualPort"`
+	Path            string          `json:"path"`
+	Query           string          `json:"query"`
+	Tunnel          bool            `json:"tunnel"`
+	RemoteAddress   string          `json:"remoteAddress"`
+	ClientPort      int             `json:"clientPort"`
+	Times           charlesTimes    `json:"times"`
+	Durations       charlesDuration `json:"durations"`
+	ErrorMessage    string          `json:"errorMessage"`
+	Request         charlesMessage  `json:"request"`
+	Response        charlesMessage  `json:"response"`
+}
+
+type charlesTimes struct {
+	Start string `json:"start"`
+}
+
+// charlesDuration holds the phase durations of a transaction in
+// milliseconds. Phases that did not happen are null.
+type charlesDuration struct {
+	Total    *float64 `json:"total"`
+	DNS      *float64 `json:"dns"`
+	Connect  *float64 `json:"connect"`
+	Ssl      *float64 `json:"ssl"`
+	Request  *float64 `json:"request"`
+	Latency  *float64 `json:"latency"`
+	Response *float64 `json:"response"`
+}
+
+type charlesMessage struct {
+	Status          int           `json:"status"`
+	Sizes           charlesSizes  `json:"sizes"`
+	MimeType        string        `json:"mimeType"`
+	ContentEncoding string        `json:"contentEncoding"`
+	Header          charlesHeader `json:"header"`
+	Body            charlesBody   `json:"body"`
+}
+
+type charlesSizes struct {
+	Headers int `json:"headers"`
+	Body    int `json:"body"`
+}
+
+type charlesHeader struct {
+	FirstLine string `json:"firstLine"`
+	Headers   []NVP  `json:"headers"`
+}
+
+// charlesBody is either decoded text or base64 encoded raw bytes.
+type charlesBody struct {
+	Text    string `json:"text"`
+	Encoded string `json:"encoded"`
+	Decoded bool   `json:"decoded"`
+}
+
+// CharlesToHar converts a Charles Proxy session exported as JSON (.chlsj)
+// or XML (.chlsx) to a Har.
+func CharlesToHar(r io.Reader) (Har, error) {
+	br := bufio.NewReader(r)
+	transactions, err := readCharles(br)
+	if err != nil {
+		return Har{}, err
+	}
+
+	var entries []Entry
+	for _, t := range transactions {
+		// CONNECT tunnels of unproxied HTTPS carry no HTTP exchange
+		if t.Tunnel || t.Method == "" {
+			continue
+		}
+		entries = append(entries, t.entry())
+	}
+
+	return Har{
+		Log: Log{
+			Version: "1.2",
+			Creator: Creator{Name: "hargo", Comment: "converted from Charles Proxy session"},
+			Entries: entries,
+		},
+	}, nil
+}
+
+// CharlesToHarFile converts a Charles Proxy session to a .har file written to w.
+func CharlesToHarFile(r io.Reader, w io.Writer) error {
+	har, err := CharlesToHar(r)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Converted %d Charles transactions", len(har.Log.Entries))
+	return Encode(w, har)
+}
+
+// isCharles reports whether r holds a Charles session rather than a HAR:
+// JSON sessions are a top-level array, XML sessions start with a tag, and
+// binary sessions are serialized Java objects.
+func isCharles(r *bufio.Reader) bool {
+	peek, _ := r.Peek(64)
+	peek = bytes.TrimLeft(bytes.TrimPrefix(peek, utf8BOM), " \t\r\n")
+	return len(peek) > 0 && (peek[0] == 0xac || peek[0] == '[' || peek[0] == '<')
+}
+
+// readCharles reads the transactions of a JSON, XML or binary session.
+func readCharles(r *bufio.Reader) ([]charlesTransaction, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte{0xac, 0xed}) {
+		return nil, ErrCharlesBinary
+	}
+
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, utf8BOM), " \t\r\n")
+	if bytes.HasPrefix(trimmed, []byte("<")) {
+		return readCharlesXML(trimmed)
+	}
+
+	var transactions []charlesTransaction
+	err = json.Unmarshal(trimmed, &transactions)
+	return transactions, err
+}
+
+// charlesXMLSession is the layout of a Charles XML session export.
+type charlesXMLSession struct {
+	Transactions []struct {
+		Status          string            `xml:"status,attr"`
+		Method          string            `xml:"method,attr"`
+		ProtocolVersion string            `xml:"protocolVersion,attr"`
+		Protocol        string            `xml:"protocol,attr"`
+		Host            string            `xml:"host,attr"`
+		Port            int               `xml:"actualPort,attr"`
+		Path            string            `xml:"path,attr"`
+		Query           string            `xml:"query,attr"`
+		RemoteAddress   string            `xml:"remoteAddress,attr"`
+		ClientPort      int               `xml:"clientPort,attr"`
+		StartTime       string            `xml:"startTime,attr"`
+		StartTimeMillis int64             `xml:"startTimeMillis,attr"`
+		DNS             string            `xml:"dnsDuration,attr"`
+		Connect         string            `xml:"connectDuration,attr"`
+		Ssl             string            `xml:"sslDuration,attr"`
+		Request         string            `xml:"requestDuration,attr"`
+		Latency         string            `xml:"latency,attr"`
+		Response        string            `xml:"responseDuration,attr"`
+		ErrorMessage    string            `xml:"error,attr"`
+		RequestMessage  charlesXMLMessage `xml:"request"`
+		ResponseMessage charlesXMLMessage `xml:"response"`
+	} `xml:"transaction"`
+}
+
+type charlesXMLMessage struct {
+	Status          int    `xml:"status,attr"`
+	MimeType        string `xml:"mime-type,attr"`
+	ContentEncoding string `xml:"content-encoding,attr"`
+	FirstLine       string `xml:"headers>first-line"`
+	Headers         []struct {
+		Name  string `xml:"name"`
+		Value string `xml:"value"`
+	} `xml:"headers>header"`
+	Body struct {
+		Encoding string `xml:"encoding,attr"`
+		Text     string `xml:",chardata"`
+	} `xml:"body"`
+}
+
+// readCharlesXML reads an XML session into the JSON session layout.
+func readCharlesXML(data []byte) ([]charlesTransaction, error) {
+	var session charlesXMLSession
+	if err := xml.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+
+	duration := func(s string) *float64 {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v < 0 {
+			return nil
+		}
+		return &v
+	}
+	message := func(m charlesXMLMessage) charlesMessage {
+		msg := charlesMessage{
+			Status:          m.Status,
+			MimeType:        m.MimeType,
+			ContentEncoding: m.ContentEncoding,
+			Header:          charlesHeader{FirstLine: m.FirstLine},
+		}
+		for _, h := range m.Headers {
+			msg.Header.Headers = append(msg.Header.Headers, NVP{Name: h.Name, Value: h.Value})
+		}
+		if m.Body.Encoding == "base64" {
+			msg.Body.Encoded = strings.TrimSpace(m.Body.Text)
+		} else {
+			msg.Body.Text = m.Body.Text
+			msg.Body.Decoded = true
+		}
+		return msg
+	}
+
+	var transactions []charlesTransaction
+	for _, x := range session.Transactions {
+		t := charlesTransaction{
+			Status:          x.Status,
+			Method:          x.Method,
+			ProtocolVersion: x.ProtocolVersion,
+			Scheme:          x.Protocol,
+			Host:            x.Host,
+			Port:            x.Port,
+			Path:            x.Path,
+			Query:           x.Query,
+			RemoteAddress:   x.RemoteAddress,
+			ClientPort:      x.ClientPort,
+			Times:           charlesTimes{Start: x.StartTime},
+			Durations: charlesDuration{
+				DNS:      duration(x.DNS),
+				Connect:  duration(x.Connect),
+				Ssl:      duration(x.Ssl),
+				Request:  duration(x.Request),
+				Latency:  duration(x.Latency),
+				Response: duration(x.Response),
+			},
+			ErrorMessage: x.ErrorMessage,
+			Request:      message(x.RequestMessage),
+			Response:     message(x.ResponseMessage),
+		}
+		if t.Times.Start == "" && x.StartTimeMillis > 0 {
+			t.Times.Start = time.UnixMilli(x.StartTimeMillis).UTC().Format(time.RFC3339Nano)
+		}
+		transactions = append(transactions, t)
+	}
+
+	return transactions, nil
+}
+
+// entry converts a Charles transaction to a HAR entry.
+func (t charlesTransaction) entry() Entry {
+	u := url.URL{Scheme: t.Scheme, Host: t.Host, Path: t.Path, RawQuery: t.Query}
+	if u.Scheme == "" {
+		u.Scheme = "http"
+	}
+	if t.Port > 0 && !(u.Scheme == "http" && t.Port == 80) && !(u.Scheme == "https" && t.Port == 443) {
+		u.Host = net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+	}
+
+	reqHeader := nvpHeader(t.Request.Header.Headers)
+	reqBody := t.Request.Body.bytes()
+
+	entry := Entry{
+		StartedDateTime: charlesTime(t.Times.Start),
+		Request: Request{
+			Method:      t.Method,
+			URL:         u.String(),
+			HTTPVersion: t.ProtocolVersion,
+			Cookies:     harCookies((&http.Request{Header: reqHeader}).Cookies()),
+			Headers:     t.Request.Header.Headers,
+			QueryString: queryNVPs(u.Query()),
+			HeaderSize:  t.Request.Sizes.Headers,
+			BodySize:    len(reqBody),
+		},
+		Timings: PageTimings{
+			Blocked: -1,
+			DNS:     charlesPhase(t.Durations.DNS),
+			Connect: charlesPhase(t.Durations.Connect),
+			Ssl:     charlesPhase(t.Durations.Ssl),
+			Send:    positive(charlesPhase(t.Durations.Request)),
+			Wait:    positive(charlesPhase(t.Durations.Latency)),
+			Receive: positive(charlesPhase(t.Durations.Response)),
+		},
+	}
+	// HAR includes the TLS handshake in the connect time
+	if entry.Timings.Ssl > 0 && entry.Timings.Connect >= 0 {
+		entry.Timings.Connect += entry.Timings.Ssl
+	}
+	entry.Time = float32(positive(entry.Timings.DNS) + positive(entry.Timings.Connect) +
+		entry.Timings.Send + entry.Timings.Wait + entry.Timings.Receive)
+	if t.Durations.Total != nil && *t.Durations.Total > 0 {
+		entry.Time = float32(*t.Durations.Total)
+	}
+	if i := strings.LastIndex(t.RemoteAddress, "/"); i >= 0 {
+		entry.ServerIPAddress = t.RemoteAddress[i+1:]
+	}
+	if t.ClientPort > 0 {
+		entry.Connection = strconv.Itoa(t.ClientPort)
+	}
+	if len(reqBody) > 0 {
+		entry.Request.PostData = PostData{MimeType: reqHeader.Get("Content-Type"), Text: string(reqBody)}
+	}
+
+	if t.Response.Header.FirstLine == "" && t.Response.Status == 0 {
+		entry.Response = Response{HeadersSize: -1, BodySize: -1, Comment: t.ErrorMessage}
+		return entry
+	}
+
+	status, statusText := t.Response.Status, ""
+	if fields := strings.SplitN(t.Response.Header.FirstLine, " ", 3); len(fields) >= 2 {
+		if code, err := strconv.Atoi(fields[1]); err == nil && status == 0 {
+			status = code
+		}
+		if len(fields) == 3 {
+			statusText = fields[2]
+		}
+	}
+
+	respHeader := nvpHeader(t.Response.Header.Headers)
+	body := t.Response.Body.bytes()
+	if !t.Response.Body.Decoded {
+		if decoded, err := decodeContentEncoding(t.Response.ContentEncoding, body); err == nil {
+			body = decoded
+		}
+	}
+
+	mimeType := respHeader.Get("Content-Type")
+	if mimeType == "" {
+		mimeType = t.Response.MimeType
+	}
+	content := Content{Size: len(body), MimeType: mimeType}
+	if isTextMimeType(mimeType) {
+		content.Text = string(body)
+	} else if len(body) > 0 {
+		content.Text = base64.StdEncoding.EncodeToString(body)
+		content.Encoding = "base64"
+	}
+
+	entry.Response = Response{
+		Status:      status,
+		StatusText:  statusText,
+		HTTPVersion: t.ProtocolVersion,
+		Cookies:     harCookies((&http.Response{Header: respHeader}).Cookies()),
+		Headers:     t.Response.Header.Headers,
+		Content:     content,
+		RedirectURL: respHeader.Get("Location"),
+		HeadersSize: t.Response.Sizes.Headers,
+		BodySize:    t.Response.Sizes.Body,
+	}
+
+	return entry
+}
+
+// bytes returns the raw body.
+func (b charlesBody) bytes() []byte {
+	if b.Encoded != "" {
+		if data, err := base64.StdEncoding.DecodeString(b.Encoded); err == nil {
+			return data
+		}
+	}
+	return []byte(b.Text)
+}
+
+// charlesPhase converts an optional Charles duration to a HAR timing.
+func charlesPhase(d *float64) float64 {
+	if d == nil {
+		return -1
+	}
+	return *d
+}
+
+// charlesTime converts a Charles timestamp to the HAR date format.
+func charlesTime(s string) string {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.000-0700", "2006-01-02T15:04:05.000-07:00"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(harDateTimeLayout)
+		}
+	}
+	return s
+}
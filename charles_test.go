@@ -0,0 +1,109 @@
+package hargo
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+const charlesJSON = `[
+  {
+    "status": "COMPLETE", "method": "GET", "protocolVersion": "HTTP/1.1",
+    "scheme": "https", "host": "example.com", "actualPort": 443,
+    "path": "/api/items", "query": "page=2", "tunnel": false,
+    "remoteAddress": "example.com/93.184.216.34", "clientPort": 53012,
+    "times": {"start": "2024-03-01T10:00:00.123+01:00"},
+    "durations": {"total": 60, "dns": 5, "connect": 10, "ssl": 15, "request": 1, "response": 4, "latency": 25},
+    "request": {
+      "sizes": {"headers": 120, "body": 0},
+      "header": {"firstLine": "GET /api/items?page=2 HTTP/1.1", "headers": [
+        {"name": "Host", "value": "example.com"}, {"name": "Cookie", "value": "session=abc"}]}
+    },
+    "response": {
+      "status": 200, "sizes": {"headers": 80, "body": 11}, "mimeType": "application/json",
+      "header": {"firstLine": "HTTP/1.1 200 OK", "headers": [{"name": "Content-Type", "value": "application/json"}]},
+      "body": {"text": "{\"items\":[]}", "decoded": true}
+    }
+  },
+  {"status": "COMPLETE", "method": "CONNECT", "tunnel": true, "host": "example.com"}
+]`
+
+const charlesXML = `<?xml version="1.0" encoding="UTF-8"?>
+<?charles serialisation-version='2.0' ?>
+<charles-session>
+  <transaction status="COMPLETE" method="POST" protocolVersion="HTTP/1.1" protocol="http" host="example.com" actualPort="8080" path="/login" startTimeMillis="1709283600000" dnsDuration="-1" connectDuration="3" requestDuration="1" latency="20" responseDuration="2">
+    <request headers="true" body="true">
+      <headers>
+        <first-line><![CDATA[POST /login HTTP/1.1]]></first-line>
+        <header><name>Content-Type</name><value>application/x-www-form-urlencoded</value></header>
+      </headers>
+      <body>user=bob</body>
+    </request>
+    <response status="302" headers="true">
+      <headers>
+        <first-line><![CDATA[HTTP/1.1 302 Found]]></first-line>
+        <header><name>Location</name><value>/home</value></header>
+      </headers>
+    </response>
+  </transaction>
+</charles-session>`
+
+func TestCharlesJSON(t *testing.T) {
+	har, err := Decode(bufio.NewReader(strings.NewReader(charlesJSON)))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(har.Log.Entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(har.Log.Entries))
+	}
+
+	e := har.Log.Entries[0]
+	if e.Request.URL != "https://example.com/api/items?page=2" {
+		t.Errorf("unexpected URL %s", e.Request.URL)
+	}
+	if e.StartedDateTime != "2024-03-01T10:00:00.123+01:00" {
+		t.Errorf("unexpected startedDateTime %s", e.StartedDateTime)
+	}
+	if len(e.Request.Cookies) != 1 || e.Request.Cookies[0].Value != "abc" {
+		t.Errorf("unexpected cookies %+v", e.Request.Cookies)
+	}
+	if e.Response.Status != 200 || e.Response.StatusText != "OK" || e.Response.Content.Text != `{"items":[]}` {
+		t.Errorf("unexpected response %+v", e.Response)
+	}
+	if e.Timings.Connect != 25 || e.Timings.Ssl != 15 || e.Timings.Wait != 25 || e.Time != 60 {
+		t.Errorf("unexpected timings %+v (%v)", e.Timings, e.Time)
+	}
+	if e.ServerIPAddress != "93.184.216.34" {
+		t.Errorf("unexpected server address %s", e.ServerIPAddress)
+	}
+}
+
+func TestCharlesXML(t *testing.T) {
+	har, err := CharlesToHar(strings.NewReader(charlesXML))
+	if err != nil {
+		t.Fatalf("CharlesToHar failed: %v", err)
+	}
+	if len(har.Log.Entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(har.Log.Entries))
+	}
+
+	e := har.Log.Entries[0]
+	if e.Request.URL != "http://example.com:8080/login" || e.Request.PostData.Text != "user=bob" {
+		t.Errorf("unexpected request %s %q", e.Request.URL, e.Request.PostData.Text)
+	}
+	if e.Response.Status != 302 || e.Response.RedirectURL != "/home" {
+		t.Errorf("unexpected response %d %s", e.Response.Status, e.Response.RedirectURL)
+	}
+	if e.Timings.DNS != -1 || e.Timings.Connect != 3 || e.Timings.Wait != 20 {
+		t.Errorf("unexpected timings %+v", e.Timings)
+	}
+	if e.StartedDateTime != "2024-03-01T09:00:00.000Z" {
+		t.Errorf("unexpected startedDateTime %s", e.StartedDateTime)
+	}
+}
+
+func TestCharlesBinary(t *testing.T) {
+	if _, err := CharlesToHar(strings.NewReader("\xac\xed\x00\x05sr")); err != ErrCharlesBinary {
+		t.Errorf("expected ErrCharlesBinary, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package hargo
+
+import (
+	"bytes"
+	"strings"
+	"time"
+)
+
+// BodyChunk is a piece of a response body together with the delay after the
+// previous chunk at which it was received.
+type BodyChunk struct {
+	Data  []byte
+	Delay time.Duration
+}
+
+// IsStreamed reports whether an entry's response was delivered as a stream:
+// it carries recorded chunk timings, was sent with chunked transfer encoding,
+// or is a Server-Sent Events stream.
+func (e Entry) IsStreamed() bool {
+	if len(e.Chunks) > 1 {
+		return true
+	}
+	if strings.Contains(strings.ToLower(e.Response.Content.MimeType), "text/event-stream") {
+		return true
+	}
+	for _, h := range e.Response.Headers {
+		if strings.EqualFold(h.Name, "Transfer-Encoding") && strings.Contains(strings.ToLower(h.Value), "chunked") {
+			return true
+		}
+	}
+	return false
+}
+
+// BodyChunks reconstructs the chunk boundaries of an entry's response body.
+// Recorded _chunks are used when present; since they count bytes on the wire
+// (which may be compressed) their sizes are scaled to the decoded body. Event
+// streams without chunk data are split at event boundaries. Any other body is
+// returned as a single chunk.
+func (e Entry) BodyChunks() ([]BodyChunk, error) {
+	body, err := e.DecodedBody()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(e.Chunks) > 0 {
+		return splitRecordedChunks(body, e.Chunks), nil
+	}
+
+	if strings.Contains(strings.ToLower(e.Response.Content.MimeType), "text/event-stream") {
+		var chunks []BodyChunk
+		for _, event := range splitEvents(body) {
+			chunks = append(chunks, BodyChunk{Data: event})
+		}
+		return chunks, nil
+	}
+
+	return []BodyChunk{{Data: body}}, nil
+}
+
+// splitRecordedChunks divides body proportionally to the recorded chunk sizes.
+func splitRecordedChunks(body []byte, recorded []Chunk) []BodyChunk {
+	total := 0
+	for _, c := range recorded {
+		total += c.Bytes
+	}
+	if total <= 0 {
+		return []BodyChunk{{Data: body}}
+	}
+
+	chunks := make([]BodyChunk, 0, len(recorded))
+	offset, seen := 0, 0
+	for i, c := range recorded {
+		seen += c.Bytes
+		end := len(body) * seen / total
+		if i == len(recorded)-1 {
+			end = len(body)
+		}
+
+		var delay time.Duration
+		if i > 0 && c.Ts > recorded[i-1].Ts {
+			delay = time.Duration((c.Ts - recorded[i-1].Ts) * float64(time.Millisecond))
+		}
+
+		chunks = append(chunks, BodyChunk{Data: body[offset:end], Delay: delay})
+		offset = end
+	}
+
+	return chunks
+}
+
+// splitEvents splits a text/event-stream body after each blank line, keeping
+// the separators so the chunks concatenate back to the original body.
+func splitEvents(body []byte) [][]byte {
+	var events [][]byte
+	for len(body) > 0 {
+		i := bytes.Index(body, []byte("\n\n"))
+		if i < 0 {
+			events = append(events, body)
+			break
+		}
+		events = append(events, body[:i+2])
+		body = body[i+2:]
+	}
+	return events
+}
@@ -0,0 +1,268 @@
+package hargo
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Metrics compared by CompareResults besides latency percentiles such as
+// "p99" or "p99.9".
+const (
+	MetricMean   = "mean"
+	MetricMax    = "max"
+	MetricErrors = "errors"
+)
+
+// compareMetrics are the metrics a comparison always reports.
+var compareMetrics = []string{"p50", "p90", "p99", "p99.9", MetricMean, MetricMax, MetricErrors}
+
+// RegressionThreshold is how much a metric may get worse between a baseline
+// and a current result before the comparison fails.
+type RegressionThreshold struct {
+	// Metric is a latency percentile like "p99", MetricMean, MetricMax or
+	// MetricErrors.
+	Metric string
+	// Percent is the allowed increase of a latency in percent of the
+	// baseline, or of the error rate in percentage points.
+	Percent float64
+	// Delta is the allowed increase of a latency, used instead of Percent
+	// when set.
+	Delta time.Duration
+}
+
+// ParseRegressionThreshold parses thresholds like "p99>10%", "mean>20ms" or
+// "errors>1%".
+func ParseRegressionThreshold(s string) (RegressionThreshold, error) {
+	var t RegressionThreshold
+	i := strings.Index(s, ">")
+	if i < 0 {
+		return t, fmt.Errorf("invalid threshold %q, expected metric>limit", s)
+	}
+	t.Metric = strings.ToLower(strings.TrimSpace(s[:i]))
+	limit := strings.TrimSpace(s[i+1:])
+	if _, err := metricPercentile(t.Metric); err != nil {
+		return t, err
+	}
+
+	if strings.HasSuffix(limit, "%") {
+		p, err := strconv.ParseFloat(strings.TrimSuffix(limit, "%"), 64)
+		if err != nil || p < 0 {
+			return t, fmt.Errorf("invalid threshold %q", s)
+		}
+		t.Percent = p
+		return t, nil
+	}
+	if t.Metric == MetricErrors {
+		return t, fmt.Errorf("invalid threshold %q, the error rate needs a limit in %%", s)
+	}
+	d, err := time.ParseDuration(limit)
+	if err != nil || d <= 0 {
+		return t, fmt.Errorf("invalid threshold %q, expected a limit like 10%% or 20ms", s)
+	}
+	t.Delta = d
+	return t, nil
+}
+
+// String formats t as ParseRegressionThreshold reads it.
+func (t RegressionThreshold) String() string {
+	if t.Delta > 0 {
+		return fmt.Sprintf("%s>%v", t.Metric, t.Delta)
+	}
+	return fmt.Sprintf("%s>%g%%", t.Metric, t.Percent)
+}
+
+// metricPercentile returns the percentile of a metric like "p99", or -1 for
+// the other metrics.
+func metricPercentile(metric string) (float64, error) {
+	switch metric {
+	case MetricMean, MetricMax, MetricErrors:
+		return -1, nil
+	}
+	if strings.HasPrefix(metric, "p") {
+		p, err := strconv.ParseFloat(metric[1:], 64)
+		if err == nil && p > 0 && p <= 100 {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown metric %q", metric)
+}
+
+// MetricComparison compares one metric of a baseline and a current result.
+// Latencies are in milliseconds, the error rate in percent.
+type MetricComparison struct {
+	Metric     string   `json:"metric"`
+	Baseline   float64  `json:"baseline"`
+	Current    float64  `json:"current"`
+	Thresholds []string `json:"thresholds,omitempty"`
+	Regressed  bool     `json:"regressed"`
+}
+
+// Change describes the difference between the current and the baseline
+// value: relative for latencies, in percentage points for the error rate.
+func (m MetricComparison) Change() string {
+	if m.Metric == MetricErrors {
+		return fmt.Sprintf("%+.2f pp", m.Current-m.Baseline)
+	}
+	if m.Baseline == 0 {
+		if m.Current == 0 {
+			return "+0.0%"
+		}
+		return "new"
+	}
+	return fmt.Sprintf("%+.1f%%", (m.Current-m.Baseline)/m.Baseline*100)
+}
+
+// CompareReport is the result of comparing a current result with a
+// baseline.
+type CompareReport struct {
+	Baseline LoadResult         `json:"-"`
+	Current  LoadResult         `json:"-"`
+	Metrics  []MetricComparison `json:"metrics"`
+}
+
+// Passed reports whether no metric regressed beyond its thresholds.
+func (r CompareReport) Passed() bool {
+	for _, m := range r.Metrics {
+		if m.Regressed {
+			return false
+		}
+	}
+	return true
+}
+
+// CompareResults compares the latencies and error rates of a current result
+// with a baseline. A metric regressed when it exceeds the baseline by more
+// than any of its thresholds allows.
+func CompareResults(baseline, current LoadResult, thresholds []RegressionThreshold) CompareReport {
+	report := CompareReport{Baseline: baseline, Current: current}
+
+	metrics := append([]string{}, compareMetrics...)
+	for _, t := range thresholds {
+		known := false
+		for _, m := range metrics {
+			known = known || m == t.Metric
+		}
+		if !known {
+			metrics = append(metrics, t.Metric)
+		}
+	}
+
+	for _, metric := range metrics {
+		m := MetricComparison{Metric: metric, Baseline: metricValue(baseline, metric), Current: metricValue(current, metric)}
+		for _, t := range thresholds {
+			if t.Metric != metric {
+				continue
+			}
+			m.Thresholds = append(m.Thresholds, t.String())
+			if exceeds(m, t) {
+				m.Regressed = true
+			}
+		}
+		report.Metrics = append(report.Metrics, m)
+	}
+	return report
+}
+
+// exceeds reports whether the current value of m is worse than t allows.
+func exceeds(m MetricComparison, t RegressionThreshold) bool {
+	increase := m.Current - m.Baseline
+	switch {
+	case t.Delta > 0:
+		return increase > float64(t.Delta)/float64(time.Millisecond)
+	case m.Metric == MetricErrors:
+		return increase > t.Percent
+	case m.Baseline == 0:
+		return m.Current > 0
+	}
+	return increase/m.Baseline*100 > t.Percent
+}
+
+// metricValue returns a metric of r, latencies in milliseconds and the error
+// rate in percent.
+func metricValue(r LoadResult, metric string) float64 {
+	latencies := r.Latencies
+	if latencies == nil {
+		latencies = NewLatencyHistogram()
+	}
+
+	var d time.Duration
+	switch metric {
+	case MetricErrors:
+		if r.Requests == 0 {
+			return 0
+		}
+		return float64(r.Failures) / float64(r.Requests) * 100
+	case MetricMean:
+		d = latencies.Mean()
+	case MetricMax:
+		d = latencies.Max()
+	default:
+		p, _ := metricPercentile(metric)
+		d = latencies.Percentile(p)
+	}
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
+
+// CompareFiles compares the result files written by replays or load tests
+// with Config.Results against thresholds like "p99>10%", writes the
+// comparison to w and reports whether no metric regressed.
+func CompareFiles(baselineFile, currentFile string, w io.Writer, thresholds []string) (bool, error) {
+	var limits []RegressionThreshold
+	for _, s := range thresholds {
+		t, err := ParseRegressionThreshold(s)
+		if err != nil {
+			return false, err
+		}
+		limits = append(limits, t)
+	}
+
+	baseline, err := LoadResultFile(baselineFile)
+	if err != nil {
+		return false, fmt.Errorf("baseline: %v", err)
+	}
+	current, err := LoadResultFile(currentFile)
+	if err != nil {
+		return false, fmt.Errorf("current: %v", err)
+	}
+
+	report := CompareResults(baseline, current, limits)
+	if err := WriteCompareReport(w, report); err != nil {
+		return false, err
+	}
+	return report.Passed(), nil
+}
+
+// WriteCompareReport writes a human readable comparison to w.
+func WriteCompareReport(w io.Writer, report CompareReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", "", "Metric", "Baseline", "Current", "Change")
+	regressed := 0
+	for _, m := range report.Metrics {
+		state := "ok"
+		if m.Regressed {
+			state = "REGRESSED"
+			regressed++
+		}
+		unit := "ms"
+		if m.Metric == MetricErrors {
+			unit = "%"
+		}
+		change := m.Change()
+		if len(m.Thresholds) > 0 {
+			change += " (limit " + strings.Join(m.Thresholds, ", ") + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.3f%s\t%.3f%s\t%s\t\n", state, m.Metric, m.Baseline, unit, m.Current, unit, change)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Requests: baseline %d, current %d\n", report.Baseline.Requests, report.Current.Requests)
+	_, err := fmt.Fprintf(w, "Comparison: %d metrics, %d regressed\n", len(report.Metrics), regressed)
+	return err
+}
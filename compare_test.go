@@ -0,0 +1,155 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRegressionThreshold(t *testing.T) {
+	tests := []struct {
+		in   string
+		want RegressionThreshold
+		err  bool
+	}{
+		{in: "p99>10%", want: RegressionThreshold{Metric: "p99", Percent: 10}},
+		{in: "P99.9 > 5.5%", want: RegressionThreshold{Metric: "p99.9", Percent: 5.5}},
+		{in: "mean>20ms", want: RegressionThreshold{Metric: MetricMean, Delta: 20 * time.Millisecond}},
+		{in: "errors>1%", want: RegressionThreshold{Metric: MetricErrors, Percent: 1}},
+		{in: "errors>1ms", err: true},
+		{in: "p99", err: true},
+		{in: "p0>1%", err: true},
+		{in: "median>1%", err: true},
+		{in: "p99>-1%", err: true},
+		{in: "p99>fast", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRegressionThreshold(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: got error %v", tt.in, err)
+			continue
+		}
+		if !tt.err && got != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// loadResult returns a result of requests with the given latencies in
+// milliseconds.
+func loadResult(failures int, latencies ...int) LoadResult {
+	h := NewLatencyHistogram()
+	for _, ms := range latencies {
+		h.Record(time.Duration(ms) * time.Millisecond)
+	}
+	return LoadResult{Requests: len(latencies) + failures, Failures: failures, Latencies: h}
+}
+
+func TestCompareResults(t *testing.T) {
+	baseline := loadResult(1, 10, 10, 10, 100)
+	current := loadResult(4, 10, 10, 10, 120)
+
+	tests := []struct {
+		threshold string
+		regressed bool
+	}{
+		{"p99>10%", true},
+		{"p99>25%", false},
+		{"p50>1%", false},
+		{"max>15ms", true},
+		{"max>25ms", false},
+		{"errors>20%", true},
+		{"errors>50%", false},
+		{"p75>1%", false},
+	}
+	for _, tt := range tests {
+		threshold, err := ParseRegressionThreshold(tt.threshold)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report := CompareResults(baseline, current, []RegressionThreshold{threshold})
+		if report.Passed() == tt.regressed {
+			t.Errorf("%s: got passed %v, want %v", tt.threshold, report.Passed(), !tt.regressed)
+		}
+	}
+
+	report := CompareResults(baseline, current, nil)
+	if !report.Passed() || len(report.Metrics) != len(compareMetrics) {
+		t.Errorf("got %+v", report)
+	}
+	errors := report.Metrics[len(report.Metrics)-1]
+	if errors.Metric != MetricErrors || errors.Baseline != 20 || errors.Current != 50 || errors.Change() != "+30.00 pp" {
+		t.Errorf("got %+v, change %s", errors, errors.Change())
+	}
+}
+
+func TestCompareFiles(t *testing.T) {
+	dir := t.TempDir()
+	baselineFile := filepath.Join(dir, "baseline.json")
+	currentFile := filepath.Join(dir, "current.json")
+	if err := loadResult(0, 10, 20).WriteFile(baselineFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadResult(0, 10, 40).WriteFile(currentFile); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	passed, err := CompareFiles(baselineFile, currentFile, &out, []string{"p99>50%", "p50>50%"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passed {
+		t.Error("expected a regression")
+	}
+	for _, want := range []string{"REGRESSED  p99", "+100.0% (limit p99>50%)", "ok         p50", "Comparison: 7 metrics, 1 regressed"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output misses %q:\n%s", want, out.String())
+		}
+	}
+
+	if _, err := CompareFiles(baselineFile, currentFile, &out, []string{"p99"}); err == nil {
+		t.Error("expected an error for an invalid threshold")
+	}
+	if _, err := CompareFiles(filepath.Join(dir, "missing.json"), currentFile, &out, nil); err == nil {
+		t.Error("expected an error for a missing baseline")
+	}
+}
+
+func TestReplayResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	har := Har{Log: Log{Entries: []Entry{
+		{Request: Request{Method: "GET", URL: "https://example.com/up"}},
+		{Request: Request{Method: "GET", URL: "https://example.com/down"}},
+	}}}
+	var h bytes.Buffer
+	if err := json.NewEncoder(&h).Encode(har); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "results.json")
+	cfg := Config{Rewrites: []Rewrite{{BaseURL: server.URL}}, Retry: RetryPolicy{Statuses: []int{503}}, Results: path}
+	if err := Replay(bufio.NewReader(&h), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := LoadResultFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Requests != 2 || result.Failures != 1 || result.Latencies.Count() != 2 {
+		t.Errorf("got %+v with %d latencies", result, result.Latencies.Count())
+	}
+}
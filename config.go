@@ -0,0 +1,238 @@
+package hargo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Config holds the settings of a replay scenario.
+//
+// Scenario files are JSON documents containing the base settings, an
+// optional "extends" path to a parent scenario file whose settings are
+// applied first, and an "environments" object with named overlays such as
+// "staging" or "prod". Overlays and child files are deep-merged over their
+// parents: objects are merged key by key, any other value replaces the
+// inherited one. String values may reference environment variables as
+// $NAME or ${NAME} so credentials don't need to be stored in the file.
+type Config struct {
+	// IgnoreHarCookies drops the cookies recorded in the .har file.
+	IgnoreHarCookies bool `json:"ignoreHarCookies"`
+	// LiveCookies drops the recorded cookies and Cookie headers and sends
+	// only the cookies the replayed responses set, so a replayed login
+	// starts a fresh session that later requests carry forward.
+	LiveCookies bool `json:"liveCookies"`
+	// InsecureSkipVerify skips TLS certificate verification.
+	InsecureSkipVerify bool `json:"insecureSkipVerify"`
+	// Transport tunes connection pooling, keep-alives and TLS.
+	Transport TransportOptions `json:"transport"`
+	// Rewrites are applied in order to the URL of every request.
+	Rewrites []Rewrite `json:"rewrites"`
+	// SetHeaders are set on every request, replacing any recorded value.
+	SetHeaders map[string]string `json:"setHeaders"`
+	// RemoveHeaders are removed from every request.
+	RemoveHeaders []string `json:"removeHeaders"`
+	// Assertions compare live responses with the recording.
+	Assertions []Assertion `json:"assertions"`
+	// Correlations capture dynamic values from responses and substitute
+	// them into later requests.
+	Correlations []Correlation `json:"correlations"`
+	// Pacing schedules the requests of a replay: PacingGaps, PacingRecorded
+	// or PacingNone. Empty means PacingGaps.
+	Pacing string `json:"pacing"`
+	// Speed divides the recorded gaps between requests, e.g. 2 replays
+	// twice as fast. Zero means 1.
+	Speed float64 `json:"speed"`
+	// Retry resends requests that fail or get a retried status.
+	Retry RetryPolicy `json:"retry"`
+	// OnFailure is FailureContinue or FailureAbort. Empty means
+	// FailureContinue.
+	OnFailure string `json:"onFailure"`
+	// MaxFailures aborts after this many failed requests. Zero means no
+	// limit.
+	MaxFailures int `json:"maxFailures"`
+	// Profile shapes the request rate of load tests.
+	Profile LoadProfile `json:"profile"`
+	// Histogram is a file load tests write their latency distribution to,
+	// as CSV if it ends in .csv and in the HdrHistogram .hgrm format
+	// otherwise.
+	Histogram string `json:"histogram"`
+	// Dashboard shows live statistics of load tests on stdout instead of a
+	// log line per request.
+	Dashboard bool `json:"dashboard"`
+	// Data holds rows of variables for {{name}} placeholders in URLs,
+	// headers and bodies. Replay uses the first row, load test workers
+	// take one row each in turn.
+	Data []map[string]string `json:"data"`
+	// DataFile is a CSV or JSON file of rows appended to Data, relative to
+	// the scenario file.
+	DataFile string `json:"dataFile"`
+	// Capture is a .har file replay writes the live requests and responses
+	// to, with the timings measured during the replay, for comparison with
+	// the recording.
+	Capture string `json:"capture"`
+	// Results is a JSON file replays and load tests write their request
+	// counts and latency distribution to, for comparison with hargo compare.
+	Results string `json:"results"`
+	// HTMLReport is a file load tests write a self-contained HTML report of
+	// their results to.
+	HTMLReport string `json:"htmlReport"`
+	// MetricsAddr is an address load tests expose Prometheus metrics of
+	// their requests on while they run. It can only be set from code or the
+	// command line.
+	MetricsAddr string `json:"-"`
+	// Selection restricts load tests to the selected entries of the .har
+	// file. It can only be set from code or the command line.
+	Selection Selection `json:"-"`
+	// Redaction is applied to the entries before they are sent. It can
+	// only be set from code or the command line.
+	Redaction *RedactionProfile `json:"-"`
+	// Auth is called before every request to supply a fresh credential
+	// header. It can only be set from code.
+	Auth AuthProvider `json:"-"`
+	// AuthCommand supplies the credential header when Auth is not set: it
+	// is run as by CommandAuth, e.g. "./login.sh staging".
+	AuthCommand string `json:"authCommand"`
+	// AuthRefresh is how often AuthCommand is run again, e.g. "5m". Empty
+	// runs it once.
+	AuthRefresh string `json:"authRefresh"`
+}
+
+// LoadConfig reads a scenario file and returns its settings with the named
+// environment overlay applied. An empty env selects the base settings.
+func LoadConfig(path string, env string) (Config, error) {
+	var cfg Config
+
+	settings, err := loadConfigLayer(path, nil)
+	if err != nil {
+		return cfg, err
+	}
+
+	environments, _ := settings["environments"].(map[string]interface{})
+	delete(settings, "environments")
+
+	if env != "" {
+		overlay, ok := environments[env].(map[string]interface{})
+		if !ok {
+			return cfg, fmt.Errorf("environment %q not defined in %s", env, path)
+		}
+		settings = mergeConfig(settings, overlay)
+	}
+
+	expandConfigEnv(settings)
+
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return cfg, err
+	}
+	if err = json.Unmarshal(b, &cfg); err != nil {
+		return cfg, err
+	}
+
+	for i, ca := range cfg.Transport.RootCAs {
+		cfg.Transport.RootCAs[i] = configPath(path, ca)
+	}
+	cfg.Transport.ClientCert = configPath(path, cfg.Transport.ClientCert)
+	cfg.Transport.ClientKey = configPath(path, cfg.Transport.ClientKey)
+
+	if cfg.DataFile != "" {
+		data, err := LoadDataSet(configPath(path, cfg.DataFile))
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Data = append(cfg.Data, data...)
+	}
+	return cfg, nil
+}
+
+// configPath resolves a path set in the scenario file at configFile
+// relative to the directory of that file.
+func configPath(configFile, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(configFile), path)
+}
+
+// loadConfigLayer reads a scenario file and merges it over the file it
+// extends, if any. Environment overlays of parent and child are merged too.
+func loadConfigLayer(path string, seen map[string]bool) (map[string]interface{}, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if seen == nil {
+		seen = make(map[string]bool)
+	}
+	if seen[abs] {
+		return nil, fmt.Errorf("config inheritance cycle at %s", path)
+	}
+	seen[abs] = true
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var settings map[string]interface{}
+	err = json.Unmarshal(b, &settings)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %s: %v", path, err)
+	}
+
+	parentPath, _ := settings["extends"].(string)
+	delete(settings, "extends")
+	if parentPath == "" {
+		return settings, nil
+	}
+
+	if !filepath.IsAbs(parentPath) {
+		parentPath = filepath.Join(filepath.Dir(path), parentPath)
+	}
+
+	parent, err := loadConfigLayer(parentPath, seen)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeConfig(parent, settings), nil
+}
+
+// mergeConfig deep-merges overlay into base and returns the result.
+func mergeConfig(base, overlay map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(overlay))
+	for k, v := range base {
+		merged[k] = v
+	}
+
+	for k, v := range overlay {
+		baseMap, baseIsMap := merged[k].(map[string]interface{})
+		overlayMap, overlayIsMap := v.(map[string]interface{})
+		if baseIsMap && overlayIsMap {
+			merged[k] = mergeConfig(baseMap, overlayMap)
+		} else {
+			merged[k] = v
+		}
+	}
+
+	return merged
+}
+
+// expandConfigEnv replaces environment variable references in all string
+// values of a decoded config.
+func expandConfigEnv(v interface{}) interface{} {
+	switch value := v.(type) {
+	case string:
+		return os.ExpandEnv(value)
+	case map[string]interface{}:
+		for k, item := range value {
+			value[k] = expandConfigEnv(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = expandConfigEnv(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,69 @@
+package hargo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	common := `{"ignoreHarCookies": true, "insecureSkipVerify": false}`
+	scenario := `{
+		"extends": "common.json",
+		"environments": {
+			"staging": {"insecureSkipVerify": true},
+			"prod": {"ignoreHarCookies": false}
+		}
+	}`
+
+	if err := os.WriteFile(filepath.Join(dir, "common.json"), []byte(common), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "scenario.json")
+	if err := os.WriteFile(path, []byte(scenario), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		env      string
+		expected Config
+	}{
+		{"", Config{IgnoreHarCookies: true}},
+		{"staging", Config{IgnoreHarCookies: true, InsecureSkipVerify: true}},
+		{"prod", Config{}},
+	}
+
+	for _, test := range tests {
+		cfg, err := LoadConfig(path, test.env)
+		if err != nil {
+			t.Fatalf("LoadConfig(%q) failed: %v", test.env, err)
+		}
+		if cfg.IgnoreHarCookies != test.expected.IgnoreHarCookies || cfg.InsecureSkipVerify != test.expected.InsecureSkipVerify {
+			t.Errorf("LoadConfig(%q) = %+v, expected %+v", test.env, cfg, test.expected)
+		}
+	}
+
+	if _, err := LoadConfig(path, "missing"); err == nil {
+		t.Error("LoadConfig should fail for an undefined environment")
+	}
+}
+
+func TestMergeConfig(t *testing.T) {
+	base := map[string]interface{}{
+		"a":      "base",
+		"nested": map[string]interface{}{"x": 1.0, "y": 2.0},
+	}
+	overlay := map[string]interface{}{
+		"nested": map[string]interface{}{"y": 3.0},
+		"b":      "overlay",
+	}
+
+	merged := mergeConfig(base, overlay)
+	nested := merged["nested"].(map[string]interface{})
+
+	if merged["a"] != "base" || merged["b"] != "overlay" || nested["x"] != 1.0 || nested["y"] != 3.0 {
+		t.Errorf("unexpected merge result: %v", merged)
+	}
+}
@@ -0,0 +1,181 @@
+package hargo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// HostConnections accounts the connection setup cost of one host. Times
+// are in milliseconds.
+type HostConnections struct {
+	Host           string  `json:"host"`
+	Requests       int     `json:"requests"`
+	DNSLookups     int     `json:"dnsLookups"`
+	DNSTime        float64 `json:"dnsTime"`
+	NewConnections int     `json:"newConnections"`
+	Reused         int     `json:"reused"`
+	// ConnectTime includes TLSTime, as in the HAR timings.
+	ConnectTime   float64 `json:"connectTime"`
+	TLSHandshakes int     `json:"tlsHandshakes"`
+	TLSTime       float64 `json:"tlsTime"`
+}
+
+// PreconnectCandidate is a third-party origin whose connection setup
+// delayed the page; <link rel="preconnect"> would start it early.
+type PreconnectCandidate struct {
+	Origin string `json:"origin"`
+	// Offset of the first request to the origin since the page started.
+	Offset float64 `json:"offset"`
+	// Savings is the DNS, TCP and TLS time of that first request.
+	Savings float64 `json:"savings"`
+}
+
+// ConnectionReport summarizes DNS lookups and connection reuse per host.
+type ConnectionReport struct {
+	Hosts      []*HostConnections    `json:"hosts"`
+	Total      HostConnections       `json:"total"`
+	Preconnect []PreconnectCandidate `json:"preconnect"`
+}
+
+// AnalyzeConnections reports DNS lookup, TCP connect and TLS handshake cost
+// per host and how often connections were reused. A request opened a new
+// connection if it has a connect timing or, when exporters record the
+// connection ID, the ID was not used before. Origins other than the page's
+// own whose first request of a page paid for connection setup are
+// preconnect candidates.
+func AnalyzeConnections(har Har) ConnectionReport {
+	var report ConnectionReport
+	hosts := make(map[string]*HostConnections)
+	connections := make(map[string]bool)
+	pageOrigins := make(map[string]string)
+	pageStarts := make(map[string]time.Time)
+	seenOrigins := make(map[string]bool)
+	candidates := make(map[string]*PreconnectCandidate)
+
+	for _, entry := range har.Log.Entries {
+		u := entryURL(entry)
+		host := u.Hostname()
+		if host == "" {
+			host = "unknown"
+		}
+		origin := u.Scheme + "://" + u.Host
+
+		h := hosts[host]
+		if h == nil {
+			h = &HostConnections{Host: host}
+			hosts[host] = h
+			report.Hosts = append(report.Hosts, h)
+		}
+
+		t := entry.Timings
+		h.Requests++
+		if t.DNS > 0 {
+			h.DNSLookups++
+			h.DNSTime += t.DNS
+		}
+		newConnection := t.Connect > 0
+		if entry.Connection != "" {
+			id := host + " " + entry.Connection
+			newConnection = !connections[id]
+			connections[id] = true
+		}
+		if newConnection {
+			h.NewConnections++
+			h.ConnectTime += positive(t.Connect)
+		} else {
+			h.Reused++
+		}
+		if t.Ssl > 0 {
+			h.TLSHandshakes++
+			h.TLSTime += t.Ssl
+		}
+
+		started, _ := parseStartedDateTime(entry.StartedDateTime)
+		pageOrigin, ok := pageOrigins[entry.Pageref]
+		if !ok {
+			pageOrigin = origin
+			pageOrigins[entry.Pageref] = origin
+			pageStarts[entry.Pageref] = started
+		}
+		key := entry.Pageref + " " + origin
+		if origin == pageOrigin || seenOrigins[key] {
+			continue
+		}
+		seenOrigins[key] = true
+
+		setup := positive(t.DNS) + positive(t.Connect)
+		if setup <= 0 {
+			continue
+		}
+		if c := candidates[origin]; c == nil || setup > c.Savings {
+			c = &PreconnectCandidate{Origin: origin, Savings: setup}
+			if pageStart := pageStarts[entry.Pageref]; !started.IsZero() && !pageStart.IsZero() {
+				c.Offset = milliseconds(started.Sub(pageStart))
+			}
+			candidates[origin] = c
+		}
+	}
+
+	for _, h := range report.Hosts {
+		report.Total.Requests += h.Requests
+		report.Total.DNSLookups += h.DNSLookups
+		report.Total.DNSTime += h.DNSTime
+		report.Total.NewConnections += h.NewConnections
+		report.Total.Reused += h.Reused
+		report.Total.ConnectTime += h.ConnectTime
+		report.Total.TLSHandshakes += h.TLSHandshakes
+		report.Total.TLSTime += h.TLSTime
+	}
+	sort.SliceStable(report.Hosts, func(i, j int) bool {
+		return report.Hosts[i].DNSTime+report.Hosts[i].ConnectTime > report.Hosts[j].DNSTime+report.Hosts[j].ConnectTime
+	})
+
+	for _, c := range candidates {
+		report.Preconnect = append(report.Preconnect, *c)
+	}
+	sort.Slice(report.Preconnect, func(i, j int) bool {
+		if report.Preconnect[i].Savings != report.Preconnect[j].Savings {
+			return report.Preconnect[i].Savings > report.Preconnect[j].Savings
+		}
+		return report.Preconnect[i].Origin < report.Preconnect[j].Origin
+	})
+
+	return report
+}
+
+// Connections prints the DNS, connection and TLS cost per host and the
+// preconnect candidates of a .har file to w.
+func Connections(r *bufio.Reader, w io.Writer) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	report := AnalyzeConnections(har)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", "Host", "Requests", "DNS lookups", "DNS (ms)",
+		"New connections", "Reused", "Connect (ms)", "TLS handshakes", "TLS (ms)")
+	for _, h := range append(report.Hosts, &report.Total) {
+		host := h.Host
+		if h == &report.Total {
+			host = "Total"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.0f\t%d\t%d\t%.0f\t%d\t%.0f\t\n", host, h.Requests, h.DNSLookups, h.DNSTime,
+			h.NewConnections, h.Reused, h.ConnectTime, h.TLSHandshakes, h.TLSTime)
+	}
+
+	if len(report.Preconnect) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t\n", "Preconnect candidate", "First request (ms)", "Savings (ms)")
+		for _, c := range report.Preconnect {
+			fmt.Fprintf(tw, "%s\t%.0f\t%.0f\t\n", c.Origin, c.Offset, c.Savings)
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,77 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeConnections(t *testing.T) {
+	entry := func(started, url, connection string, timings PageTimings) Entry {
+		return Entry{Pageref: "page_1", StartedDateTime: started, Request: Request{URL: url}, Connection: connection, Timings: timings}
+	}
+
+	har := Har{Log: Log{Entries: []Entry{
+		entry("2024-01-02T10:00:00.000Z", "https://example.com/", "", PageTimings{DNS: 20, Connect: 50, Ssl: 30, Wait: 100}),
+		entry("2024-01-02T10:00:00.200Z", "https://example.com/app.js", "", PageTimings{DNS: -1, Connect: -1, Ssl: -1, Wait: 10}),
+		entry("2024-01-02T10:00:00.300Z", "https://fonts.example.net/font.woff2", "", PageTimings{DNS: 15, Connect: 40, Ssl: 25, Wait: 10}),
+		entry("2024-01-02T10:00:00.400Z", "https://fonts.example.net/other.woff2", "", PageTimings{Wait: 10}),
+		entry("2024-01-02T10:00:00.500Z", "https://cdn.example.org/a.js", "7", PageTimings{Connect: 30, Wait: 10}),
+		// recorded connection ID wins over the timings
+		entry("2024-01-02T10:00:00.600Z", "https://cdn.example.org/b.js", "7", PageTimings{Connect: 30, Wait: 10}),
+		entry("2024-01-02T10:00:00.700Z", "https://cdn.example.org/c.js", "8", PageTimings{Wait: 10}),
+	}}}
+
+	report := AnalyzeConnections(har)
+
+	got := make(map[string]HostConnections)
+	for _, h := range report.Hosts {
+		got[h.Host] = *h
+	}
+	expected := map[string]HostConnections{
+		"example.com":       {Host: "example.com", Requests: 2, DNSLookups: 1, DNSTime: 20, NewConnections: 1, Reused: 1, ConnectTime: 50, TLSHandshakes: 1, TLSTime: 30},
+		"fonts.example.net": {Host: "fonts.example.net", Requests: 2, DNSLookups: 1, DNSTime: 15, NewConnections: 1, Reused: 1, ConnectTime: 40, TLSHandshakes: 1, TLSTime: 25},
+		"cdn.example.org":   {Host: "cdn.example.org", Requests: 3, NewConnections: 2, Reused: 1, ConnectTime: 30},
+	}
+	for host, e := range expected {
+		if got[host] != e {
+			t.Errorf("%s: got %+v, expected %+v", host, got[host], e)
+		}
+	}
+	if report.Hosts[0].Host != "example.com" {
+		t.Errorf("hosts not sorted by setup cost: %s first", report.Hosts[0].Host)
+	}
+	if report.Total.Requests != 7 || report.Total.NewConnections != 4 || report.Total.Reused != 3 {
+		t.Errorf("unexpected total %+v", report.Total)
+	}
+
+	if len(report.Preconnect) != 2 {
+		t.Fatalf("expected 2 preconnect candidates, got %+v", report.Preconnect)
+	}
+	if c := report.Preconnect[0]; c.Origin != "https://fonts.example.net" || c.Savings != 55 || c.Offset != 300 {
+		t.Errorf("unexpected candidate %+v", c)
+	}
+	if c := report.Preconnect[1]; c.Origin != "https://cdn.example.org" || c.Savings != 30 {
+		t.Errorf("unexpected candidate %+v", c)
+	}
+}
+
+func TestConnections(t *testing.T) {
+	data, err := json.Marshal(Har{Log: Log{Entries: []Entry{{
+		Request: Request{URL: "https://example.com/"},
+		Timings: PageTimings{DNS: 12, Connect: 34},
+	}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := Connections(bufio.NewReader(bytes.NewReader(data)), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "example.com") || !strings.Contains(out.String(), "Total") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,337 @@
+package hargo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Correlation captures a dynamic value, like a CSRF token or session ID,
+// from responses so it can be substituted into later requests of a replay
+// or load test.
+//
+// The value is extracted from both the recorded and the live response.
+// Later requests then send the live value wherever the recorded one occurs
+// in their URL, headers or body, and wherever they contain the placeholder
+// {{name}}. Recorded values shorter than four characters are only
+// substituted for placeholders, as they would match by accident.
+type Correlation struct {
+	// Name identifies the value in {{name}} placeholders.
+	Name string `json:"name"`
+	// URL is a regular expression restricting the rule to responses of
+	// matching request URLs. An empty URL applies to every response.
+	URL string `json:"url"`
+	// Header extracts from the values of this response header instead of
+	// the body.
+	Header string `json:"header"`
+	// JSONPath selects the value in a JSON body, e.g. $.data.token or
+	// $.items[0]['id'].
+	JSONPath string `json:"jsonPath"`
+	// Regex selects the value by its first capture group, or the whole
+	// match if it has none. It is applied to the header value or body, or
+	// to the value selected by JSONPath.
+	Regex string `json:"regex"`
+}
+
+// minCorrelatedLength is the minimum length of recorded values replaced
+// without a placeholder.
+const minCorrelatedLength = 4
+
+type correlationRule struct {
+	Correlation
+	url   *regexp.Regexp
+	regex *regexp.Regexp
+	path  []jsonPathStep
+}
+
+// Correlator applies correlation rules to the requests and responses of a
+// single replayed session.
+type Correlator struct {
+	rules    []correlationRule
+	recorded map[string]string
+	live     map[string]string
+	vars     map[string]string
+}
+
+// NewCorrelator compiles correlation rules.
+func NewCorrelator(correlations []Correlation) (*Correlator, error) {
+	c := &Correlator{recorded: make(map[string]string), live: make(map[string]string)}
+	for _, corr := range correlations {
+		if corr.Name == "" {
+			return nil, fmt.Errorf("correlation without name")
+		}
+		if corr.Header == "" && corr.JSONPath == "" && corr.Regex == "" {
+			return nil, fmt.Errorf("correlation %s: one of header, jsonPath or regex is required", corr.Name)
+		}
+		rule := correlationRule{Correlation: corr}
+		var err error
+		if corr.URL != "" {
+			if rule.url, err = regexp.Compile(corr.URL); err != nil {
+				return nil, fmt.Errorf("correlation %s: %v", corr.Name, err)
+			}
+		}
+		if corr.Regex != "" {
+			if rule.regex, err = regexp.Compile(corr.Regex); err != nil {
+				return nil, fmt.Errorf("correlation %s: %v", corr.Name, err)
+			}
+		}
+		if corr.JSONPath != "" {
+			if rule.path, err = parseJSONPath(corr.JSONPath); err != nil {
+				return nil, fmt.Errorf("correlation %s: %v", corr.Name, err)
+			}
+		}
+		c.rules = append(c.rules, rule)
+	}
+	return c, nil
+}
+
+// NeedsBody reports whether response bodies must be read for Extract.
+func (c *Correlator) NeedsBody() bool {
+	for _, rule := range c.rules {
+		if rule.Header == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// SetVariables sets the values of {{name}} placeholders that are not
+// captured from responses, e.g. a row of a data set.
+func (c *Correlator) SetVariables(vars map[string]string) {
+	c.vars = vars
+}
+
+// Value returns the live value captured for name, or the variable of that
+// name.
+func (c *Correlator) Value(name string) (string, bool) {
+	if v, ok := c.live[name]; ok {
+		return v, ok
+	}
+	v, ok := c.vars[name]
+	return v, ok
+}
+
+// Extract captures the values of the matching rules from the recorded
+// response of entry and the live response resp with body.
+func (c *Correlator) Extract(entry Entry, resp *http.Response, body []byte) {
+	for _, rule := range c.rules {
+		if rule.url != nil && !rule.url.MatchString(entry.Request.URL) {
+			continue
+		}
+
+		var recordedBody []byte
+		var recordedHeader []string
+		if rule.Header != "" {
+			for _, h := range entry.Response.Headers {
+				if strings.EqualFold(h.Name, rule.Header) {
+					recordedHeader = append(recordedHeader, h.Value)
+				}
+			}
+		} else if b, err := entry.DecodedBody(); err == nil {
+			recordedBody = b
+		}
+		if v, ok := rule.extract(recordedHeader, recordedBody); ok {
+			c.recorded[rule.Name] = v
+		}
+
+		if resp == nil {
+			continue
+		}
+		if v, ok := rule.extract(resp.Header.Values(rule.Header), body); ok {
+			if c.live[rule.Name] != v {
+				log.Debugf("Correlated %s = %q", rule.Name, v)
+			}
+			c.live[rule.Name] = v
+		}
+	}
+}
+
+// extract applies the rule to the values of its header or to body.
+func (rule correlationRule) extract(header []string, body []byte) (string, bool) {
+	text := string(body)
+	if rule.Header != "" {
+		if len(header) == 0 {
+			return "", false
+		}
+		text = strings.Join(header, "\n")
+	}
+
+	if rule.path != nil {
+		var doc interface{}
+		dec := json.NewDecoder(strings.NewReader(text))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			return "", false
+		}
+		v, ok := evalJSONPath(doc, rule.path)
+		if !ok {
+			return "", false
+		}
+		text = jsonPathString(v)
+	}
+
+	if rule.regex != nil {
+		m := rule.regex.FindStringSubmatch(text)
+		if m == nil {
+			return "", false
+		}
+		if len(m) > 1 {
+			return m[1], true
+		}
+		return m[0], true
+	}
+	return text, true
+}
+
+// replacer returns the substitutions of placeholders and recorded values
+// by live values and variables.
+func (c *Correlator) replacer() *strings.Replacer {
+	names := make([]string, 0, len(c.live)+len(c.vars))
+	for name := range c.live {
+		names = append(names, name)
+	}
+	for name := range c.vars {
+		if _, ok := c.live[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var pairs []string
+	for _, name := range names {
+		live, ok := c.live[name]
+		if !ok {
+			live = c.vars[name]
+		}
+		// URL paths escape the braces of placeholders
+		pairs = append(pairs, "{{"+name+"}}", live, "%7B%7B"+name+"%7D%7D", live)
+		if !ok {
+			continue
+		}
+		if recorded := c.recorded[name]; len(recorded) >= minCorrelatedLength && recorded != live {
+			pairs = append(pairs, recorded, live)
+		}
+	}
+	if len(pairs) == 0 {
+		return nil
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// Apply substitutes the captured values into the URL, headers and body of
+// req.
+func (c *Correlator) Apply(req *http.Request) error {
+	r := c.replacer()
+	if r == nil {
+		return nil
+	}
+
+	if rawURL := req.URL.String(); r.Replace(rawURL) != rawURL {
+		u, err := url.Parse(r.Replace(rawURL))
+		if err != nil {
+			return err
+		}
+		if req.Host == req.URL.Host {
+			req.Host = u.Host
+		}
+		req.URL = u
+	}
+
+	for name, values := range req.Header {
+		for i, v := range values {
+			values[i] = r.Replace(v)
+		}
+		req.Header[name] = values
+	}
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	body = []byte(r.Replace(string(body)))
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	return nil
+}
+
+// jsonPathStep is an object key or, if key is empty, an array index.
+type jsonPathStep struct {
+	key   string
+	index int
+}
+
+var jsonPathToken = regexp.MustCompile(`^(?:\.([^.\[\]]+)|\[(\d+)\]|\['([^']*)'\]|\["([^"]*)"\])`)
+
+// parseJSONPath parses the subset of JSONPath made of $, .key, [index] and
+// ['key'] steps.
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSONPath %q must start with $", path)
+	}
+	steps := []jsonPathStep{}
+	for rest := path[1:]; rest != ""; {
+		m := jsonPathToken.FindStringSubmatch(rest)
+		if m == nil {
+			return nil, fmt.Errorf("unsupported JSONPath %q at %q", path, rest)
+		}
+		switch {
+		case m[2] != "":
+			index, _ := strconv.Atoi(m[2])
+			steps = append(steps, jsonPathStep{index: index})
+		default:
+			steps = append(steps, jsonPathStep{key: m[1] + m[3] + m[4]})
+		}
+		rest = rest[len(m[0]):]
+	}
+	return steps, nil
+}
+
+// evalJSONPath returns the value at path in doc.
+func evalJSONPath(doc interface{}, path []jsonPathStep) (interface{}, bool) {
+	for _, step := range path {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			if step.key == "" {
+				return nil, false
+			}
+			var ok bool
+			if doc, ok = v[step.key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			if step.key != "" || step.index >= len(v) {
+				return nil, false
+			}
+			doc = v[step.index]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// jsonPathString formats a value selected by a JSONPath: strings as is,
+// other values as JSON.
+func jsonPathString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,230 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONPath(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(`{"data":{"token":"abc","items":[{"id":7},{"my key":true}]}}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"$.data.token", "abc", true},
+		{"$.data.items[0].id", "7", true},
+		{"$.data.items[1]['my key']", "true", true},
+		{`$["data"]["token"]`, "abc", true},
+		{"$.data.items[2]", "", false},
+		{"$.data.missing", "", false},
+		{"$.data.token.length", "", false},
+	}
+	for _, tt := range tests {
+		path, err := parseJSONPath(tt.path)
+		if err != nil {
+			t.Errorf("parseJSONPath(%q): %v", tt.path, err)
+			continue
+		}
+		v, ok := evalJSONPath(doc, path)
+		if ok != tt.ok || (ok && jsonPathString(v) != tt.want) {
+			t.Errorf("%s = %v, %v; want %q, %v", tt.path, v, ok, tt.want, tt.ok)
+		}
+	}
+
+	for _, path := range []string{"data.token", "$..token", "$.items[*]"} {
+		if _, err := parseJSONPath(path); err == nil {
+			t.Errorf("parseJSONPath(%q) succeeded", path)
+		}
+	}
+}
+
+func TestNewCorrelatorErrors(t *testing.T) {
+	for _, corr := range []Correlation{
+		{Regex: "x"},
+		{Name: "a"},
+		{Name: "a", Regex: "("},
+		{Name: "a", URL: "(", Regex: "x"},
+		{Name: "a", JSONPath: "token"},
+	} {
+		if _, err := NewCorrelator([]Correlation{corr}); err == nil {
+			t.Errorf("NewCorrelator(%+v) succeeded", corr)
+		}
+	}
+}
+
+func TestCorrelatorApply(t *testing.T) {
+	c, err := NewCorrelator([]Correlation{
+		{Name: "csrf", URL: "/login$", Regex: `name="csrf" value="([^"]+)"`},
+		{Name: "session", JSONPath: "$.session"},
+		{Name: "trace", Header: "X-Trace"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := Entry{
+		Request: Request{URL: "https://example.com/login"},
+		Response: Response{Content: Content{
+			Text: `<input name="csrf" value="recorded-csrf">`,
+		}},
+	}
+	c.Extract(login, &http.Response{Header: http.Header{}}, []byte(`<input name="csrf" value="live-csrf">`))
+
+	// the rule's URL does not match, so the second form is ignored
+	other := Entry{
+		Request:  Request{URL: "https://example.com/other"},
+		Response: Response{Content: Content{Text: `<input name="csrf" value="other">`}},
+	}
+	c.Extract(other, &http.Response{Header: http.Header{}}, []byte(`<input name="csrf" value="other-live">`))
+
+	api := Entry{
+		Request: Request{URL: "https://example.com/api"},
+		Response: Response{
+			Headers: []NVP{{Name: "X-Trace", Value: "t1"}},
+			Content: Content{Text: `{"session":"recorded-session"}`},
+		},
+	}
+	c.Extract(api, &http.Response{Header: http.Header{"X-Trace": {"t2"}}}, []byte(`{"session":"live-session"}`))
+
+	if v, _ := c.Value("csrf"); v != "live-csrf" {
+		t.Errorf("csrf = %q", v)
+	}
+	if v, _ := c.Value("trace"); v != "t2" {
+		t.Errorf("trace = %q", v)
+	}
+
+	body := "csrf=recorded-csrf&trace=t1&s={{session}}&t={{trace}}"
+	req, err := http.NewRequest("POST", "https://example.com/submit?session=recorded-session", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Csrf-Token", "recorded-csrf")
+	if err := c.Apply(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := req.URL.String(); got != "https://example.com/submit?session=live-session" {
+		t.Errorf("URL = %s", got)
+	}
+	if got := req.Header.Get("X-Csrf-Token"); got != "live-csrf" {
+		t.Errorf("X-Csrf-Token = %s", got)
+	}
+	got, _ := io.ReadAll(req.Body)
+	// the recorded trace value is too short to be replaced by itself
+	want := "csrf=live-csrf&trace=t1&s=live-session&t=t2"
+	if string(got) != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+	if req.ContentLength != int64(len(want)) {
+		t.Errorf("ContentLength = %d", req.ContentLength)
+	}
+}
+
+func TestReplayCorrelation(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			fmt.Fprint(w, `{"token":"live-token-2"}`)
+		default:
+			b, _ := io.ReadAll(r.Body)
+			received = append(received, r.Header.Get("Authorization")+" "+string(b))
+		}
+	}))
+	defer server.Close()
+
+	har := Har{Log: Log{Entries: []Entry{
+		{
+			StartedDateTime: "2020-01-01T00:00:00.000Z",
+			Request:         Request{Method: "GET", URL: "https://example.com/login"},
+			Response:        Response{Status: 200, Content: Content{Text: `{"token":"recorded-token-1"}`}},
+		},
+		{
+			StartedDateTime: "2020-01-01T00:00:00.000Z",
+			Request: Request{
+				Method:   "POST",
+				URL:      "https://example.com/orders",
+				Headers:  []NVP{{Name: "Authorization", Value: "Bearer recorded-token-1"}},
+				PostData: PostData{MimeType: "text/plain", Text: "token={{token}}"},
+			},
+			Response: Response{Status: 200},
+		},
+	}}}
+
+	var h bytes.Buffer
+	if err := json.NewEncoder(&h).Encode(har); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{
+		Rewrites:     []Rewrite{{BaseURL: server.URL}},
+		Correlations: []Correlation{{Name: "token", URL: "/login", JSONPath: "$.token"}},
+	}
+	if err := Replay(bufio.NewReader(&h), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"Bearer live-token-2 token=live-token-2"}
+	if !reflect.DeepEqual(received, want) {
+		t.Errorf("received %q, want %q", received, want)
+	}
+}
+
+func TestReplayLiveCookies(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "live-session", Path: "/"})
+		default:
+			received = append(received, r.Header.Get("Cookie"))
+		}
+	}))
+	defer server.Close()
+
+	har := Har{Log: Log{Entries: []Entry{
+		{
+			StartedDateTime: "2020-01-01T00:00:00.000Z",
+			Request:         Request{Method: "POST", URL: "https://example.com/login"},
+			Response:        Response{Status: 200},
+		},
+		{
+			StartedDateTime: "2020-01-01T00:00:00.000Z",
+			Request: Request{
+				Method:  "GET",
+				URL:     "https://example.com/account",
+				Headers: []NVP{{Name: "cookie", Value: "session=recorded-session"}},
+				Cookies: []Cookie{{Name: "session", Value: "recorded-session"}},
+			},
+			Response: Response{Status: 200},
+		},
+	}}}
+
+	var h bytes.Buffer
+	if err := json.NewEncoder(&h).Encode(har); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{Rewrites: []Rewrite{{BaseURL: server.URL}}, LiveCookies: true}
+	if err := Replay(bufio.NewReader(&h), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"session=live-session"}
+	if !reflect.DeepEqual(received, want) {
+		t.Errorf("received %q, want %q", received, want)
+	}
+}
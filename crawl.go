@@ -0,0 +1,280 @@
+package hargo
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
+)
+
+// harDateTimeLayout is the ISO 8601 format used for startedDateTime values
+// written by hargo.
+const harDateTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// maxCrawlRedirects limits the redirects followed for a single resource.
+const maxCrawlRedirects = 10
+
+// Crawl requests the page at pageURL, fetches the images, scripts,
+// stylesheets and other resources referenced by its HTML and returns the
+// traffic as a Har with timings measured via httptrace. It is a lightweight
+// capture path that does not need a browser, so scripts are not executed and
+// resources loaded by them are not captured.
+func Crawl(pageURL string, insecureSkipVerify bool) (Har, error) {
+	if err := requireNetwork("crawl"); err != nil {
+		return Har{}, err
+	}
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{
+		// redirects are followed by captureResource so every hop is recorded
+		CheckRedirect: func(r *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureSkipVerify},
+		},
+	}
+
+	const pageID = "page_1"
+
+	emitPhase(EventPhaseStarted, "crawl")
+	defer emitPhase(EventPhaseFinished, "crawl")
+
+	start := time.Now()
+	entries, body, finalURL, err := captureResource(client, jar, pageURL, pageID)
+	if err != nil {
+		return Har{}, err
+	}
+	contentLoaded := time.Since(start)
+
+	base, _ := url.Parse(finalURL)
+	title, resources := parseSubresources(body, base)
+
+	for _, resource := range resources {
+		resourceEntries, _, _, err := captureResource(client, jar, resource, pageID)
+		if err != nil {
+			log.Warnf("Failed to fetch %s: %v", resource, err)
+			emit(Event{Type: EventError, Phase: "crawl", Index: -1, URL: resource, Method: "GET", Err: err})
+			continue
+		}
+		entries = append(entries, resourceEntries...)
+	}
+
+	loaded := time.Since(start)
+
+	if title == "" {
+		title = finalURL
+	}
+
+	return Har{
+		Log: Log{
+			Version: "1.2",
+			Creator: Creator{Name: "hargo"},
+			Pages: []Page{{
+				StartedDateTime: start.Format(harDateTimeLayout),
+				ID:              pageID,
+				Title:           title,
+				PageTiming: PageTiming{
+					OnContentLoad: milliseconds(contentLoaded),
+					OnLoad:        milliseconds(loaded),
+				},
+			}},
+			Entries: entries,
+		},
+	}, nil
+}
+
+// CrawlFile crawls pageURL and writes the resulting Har to w.
+func CrawlFile(pageURL string, w io.Writer, insecureSkipVerify bool) error {
+	har, err := Crawl(pageURL, insecureSkipVerify)
+	if err != nil {
+		return err
+	}
+
+	return Encode(w, har)
+}
+
+// captureResource GETs rawURL, following redirects, and returns one entry per
+// hop together with the final body and URL. Cookies are managed through jar
+// rather than the client so they are visible in the recorded requests.
+func captureResource(client *http.Client, jar http.CookieJar, rawURL string, pageref string) ([]Entry, []byte, string, error) {
+	var entries []Entry
+
+	for i := 0; i <= maxCrawlRedirects; i++ {
+		req, err := http.NewRequest("GET", rawURL, nil)
+		if err != nil {
+			return entries, nil, rawURL, err
+		}
+		req.Header.Set("User-Agent", "hargo")
+		for _, c := range jar.Cookies(req.URL) {
+			req.AddCookie(c)
+		}
+
+		entry, body, resp, err := captureEntry(client, req)
+		if err != nil {
+			return entries, nil, rawURL, err
+		}
+		jar.SetCookies(req.URL, resp.Cookies())
+		entry.Pageref = pageref
+		entries = append(entries, entry)
+
+		emit(Event{Type: EventEntryFetched, Phase: "crawl", Index: len(entries) - 1, URL: rawURL, Method: req.Method,
+			Status: resp.StatusCode, Bytes: int64(len(body)), Latency: time.Duration(float64(entry.Time) * float64(time.Millisecond))})
+
+		location, err := resp.Location()
+		if err != nil || resp.StatusCode < 300 || resp.StatusCode >= 400 {
+			return entries, body, rawURL, nil
+		}
+		rawURL = location.String()
+	}
+
+	return entries, nil, rawURL, fmt.Errorf("stopped after %d redirects", maxCrawlRedirects)
+}
+
+// parseSubresources returns the page title and the absolute URLs of the
+// images, scripts and linked resources (stylesheets, icons, preloads)
+// referenced by an HTML document, in document order without duplicates.
+func parseSubresources(body []byte, base *url.URL) (string, []string) {
+	var (
+		title     string
+		inTitle   bool
+		resources []string
+	)
+	seen := make(map[string]bool)
+
+	add := func(ref string) {
+		ref = strings.TrimSpace(ref)
+		if ref == "" || base == nil {
+			return
+		}
+		u, err := base.Parse(ref)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return
+		}
+		u.Fragment = ""
+		if s := u.String(); !seen[s] {
+			seen[s] = true
+			resources = append(resources, s)
+		}
+	}
+
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return title, resources
+		case html.TextToken:
+			if inTitle && title == "" {
+				title = strings.TrimSpace(string(z.Text()))
+			}
+		case html.EndTagToken:
+			inTitle = false
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			attrs := make(map[string]string, len(t.Attr))
+			for _, a := range t.Attr {
+				attrs[strings.ToLower(a.Key)] = a.Val
+			}
+			switch t.Data {
+			case "title":
+				inTitle = tt == html.StartTagToken
+			case "base":
+				if href, ok := attrs["href"]; ok && base != nil {
+					if u, err := base.Parse(href); err == nil {
+						base = u
+					}
+				}
+			case "img", "script":
+				add(attrs["src"])
+			case "link":
+				if linkFetched(attrs["rel"]) {
+					add(attrs["href"])
+				}
+			}
+		}
+	}
+}
+
+// linkFetched reports whether a browser would load a <link> with the given
+// rel attribute as part of the page.
+func linkFetched(rel string) bool {
+	for _, r := range strings.Fields(strings.ToLower(rel)) {
+		switch r {
+		case "stylesheet", "icon", "preload", "modulepreload", "manifest", "apple-touch-icon":
+			return true
+		}
+	}
+	return false
+}
+
+// headerNVPs converts http.Header to sorted name/value pairs.
+func headerNVPs(h http.Header) []NVP {
+	nvps := []NVP{}
+	for name, values := range h {
+		for _, v := range values {
+			nvps = append(nvps, NVP{Name: name, Value: v})
+		}
+	}
+	sort.SliceStable(nvps, func(i, j int) bool { return nvps[i].Name < nvps[j].Name })
+	return nvps
+}
+
+// queryNVPs converts url.Values to sorted name/value pairs.
+func queryNVPs(q url.Values) []NVP {
+	nvps := []NVP{}
+	for name, values := range q {
+		for _, v := range values {
+			nvps = append(nvps, NVP{Name: name, Value: v})
+		}
+	}
+	sort.SliceStable(nvps, func(i, j int) bool { return nvps[i].Name < nvps[j].Name })
+	return nvps
+}
+
+// harCookies converts http.Cookies to HAR cookies.
+func harCookies(cookies []*http.Cookie) []Cookie {
+	hc := []Cookie{}
+	for _, c := range cookies {
+		cookie := Cookie{Name: c.Name, Value: c.Value, Path: c.Path, Domain: c.Domain, HTTPOnly: c.HttpOnly, Secure: c.Secure}
+		if !c.Expires.IsZero() {
+			cookie.Expires = c.Expires.Format(harDateTimeLayout)
+		}
+		hc = append(hc, cookie)
+	}
+	return hc
+}
+
+// serverIP strips the port from a remote address.
+func serverIP(addr string) string {
+	if addr == "" {
+		return ""
+	}
+	if i := strings.LastIndex(addr, ":"); i >= 0 {
+		addr = addr[:i]
+	}
+	return strings.Trim(addr, "[]")
+}
+
+// milliseconds converts a duration to fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// positive returns t, or 0 for timings that do not apply (-1).
+func positive(t float64) float64 {
+	if t < 0 {
+		return 0
+	}
+	return t
+}
@@ -0,0 +1,79 @@
+package hargo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCrawl(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/index.html", http.StatusFound)
+	})
+	mux.HandleFunc("/index.html", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>Test page</title>
+<link rel="stylesheet" href="/style.css"><link rel="canonical" href="/index.html">
+<script src="app.js"></script></head>
+<body><img src="/logo.png"><img src="/logo.png#again"><a href="/other.html">other</a></body></html>`)
+	})
+	mux.HandleFunc("/style.css", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		fmt.Fprint(w, "body { color: black }")
+	})
+	mux.HandleFunc("/app.js", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("session"); err != nil {
+			http.Error(w, "no session", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/javascript")
+		fmt.Fprint(w, "console.log(1)")
+	})
+	mux.HandleFunc("/logo.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte{0x89, 'P', 'N', 'G'})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	har, err := Crawl(server.URL+"/", false)
+	if err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	expected := []struct {
+		path   string
+		status int
+	}{
+		{"/", 302},
+		{"/index.html", 200},
+		{"/style.css", 200},
+		{"/app.js", 200},
+		{"/logo.png", 200},
+	}
+	if len(har.Log.Entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %d", len(expected), len(har.Log.Entries))
+	}
+	for i, e := range expected {
+		entry := har.Log.Entries[i]
+		if entry.Request.URL != server.URL+e.path || entry.Response.Status != e.status {
+			t.Errorf("entry %d: %s %d, expected %s %d", i, entry.Request.URL, entry.Response.Status, e.path, e.status)
+		}
+		if entry.Time <= 0 || entry.Timings.Wait <= 0 {
+			t.Errorf("entry %d: missing timings %+v", i, entry.Timings)
+		}
+	}
+
+	if har.Log.Pages[0].Title != "Test page" {
+		t.Errorf("unexpected page title %q", har.Log.Pages[0].Title)
+	}
+	if har.Log.Entries[0].Response.RedirectURL != server.URL+"/index.html" {
+		t.Errorf("unexpected redirect URL %q", har.Log.Entries[0].Response.RedirectURL)
+	}
+	if logo := har.Log.Entries[4].Response.Content; logo.Encoding != "base64" || logo.Size != 4 {
+		t.Errorf("unexpected image content %+v", logo)
+	}
+}
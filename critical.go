@@ -0,0 +1,207 @@
+package hargo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// CriticalChain is the chain of requests, each caused by the one before,
+// that ended last before a page loaded: shortening any of them, or the
+// time between them, loads the page sooner. Times are in milliseconds
+// since the page started loading.
+type CriticalChain struct {
+	Page  string `json:"page"`
+	Title string `json:"title"`
+	// OnLoad is the page's onLoad timing, -1 if not recorded.
+	OnLoad float64 `json:"onLoad"`
+	// End is when the last request of the chain finished.
+	End float64 `json:"end"`
+	// Busy is the time on the chain spent in requests and Idle the time
+	// between them, e.g. parsing or running the script that sent the next
+	// request. Together they add up to End.
+	Busy     float64           `json:"busy"`
+	Idle     float64           `json:"idle"`
+	Requests []CriticalRequest `json:"requests"`
+}
+
+// CriticalRequest is a request of a critical chain.
+type CriticalRequest struct {
+	// Entry is the 0-based position of the entry in the .har file as
+	// recorded, before Decode sorts entries by startedDateTime.
+	Entry  int     `json:"entry"`
+	Method string  `json:"method"`
+	URL    string  `json:"url"`
+	Type   string  `json:"type"`
+	Start  float64 `json:"start"`
+	Time   float64 `json:"time"`
+	// Gap is how long the chain was idle before this request started.
+	Gap float64 `json:"gap"`
+}
+
+// criticalTolerance allows requests ending up to a millisecond after
+// onLoad, as times are rounded differently by browsers.
+const criticalTolerance = 1.0
+
+// AnalyzeCriticalChains returns the critical chain of every page of har,
+// following the dependency graph of BuildDependencyGraph back from the
+// request that finished last before the page's onLoad event, or last of
+// all if onLoad was not recorded. Entries without a page, as in captures
+// from tools without page grouping, form a page of their own. Chains do
+// not cross pages.
+func AnalyzeCriticalChains(har Har) []CriticalChain {
+	graph := BuildDependencyGraph(har)
+	parents := make(map[int]int, len(graph.Edges))
+	for _, edge := range graph.Edges {
+		parents[edge.To] = edge.From
+	}
+
+	// pages in order, with the entries of each
+	var chains []*CriticalChain
+	byID := make(map[string]*CriticalChain)
+	starts := make(map[string]time.Time)
+	members := make(map[string][]int)
+	for _, page := range har.Log.Pages {
+		chain := &CriticalChain{Page: page.ID, Title: page.Title, OnLoad: timingOrUnknown(page.PageTiming.OnLoad)}
+		if t, err := parseStartedDateTime(page.StartedDateTime); err == nil {
+			starts[page.ID] = t
+		}
+		chains = append(chains, chain)
+		byID[page.ID] = chain
+	}
+	for i, entry := range har.Log.Entries {
+		if byID[entry.Pageref] == nil {
+			chain := &CriticalChain{Page: entry.Pageref, Title: entry.Request.URL, OnLoad: -1}
+			chains = append(chains, chain)
+			byID[entry.Pageref] = chain
+		}
+		members[entry.Pageref] = append(members[entry.Pageref], i)
+	}
+
+	result := make([]CriticalChain, 0, len(chains))
+	for _, chain := range chains {
+		entries := members[chain.Page]
+		if len(entries) == 0 {
+			continue
+		}
+
+		// offsets of the entries relative to the page start
+		start, ok := starts[chain.Page]
+		begins := make(map[int]float64, len(entries))
+		for _, i := range entries {
+			if t, err := parseStartedDateTime(har.Log.Entries[i].StartedDateTime); err == nil && (!ok || t.Before(start)) {
+				start, ok = t, true
+			}
+		}
+		for _, i := range entries {
+			if t, err := parseStartedDateTime(har.Log.Entries[i].StartedDateTime); err == nil {
+				begins[i] = milliseconds(t.Sub(start))
+			}
+		}
+		end := func(i int) float64 {
+			return begins[i] + positive(float64(har.Log.Entries[i].Time))
+		}
+
+		// the chain ends with the request that finished last before onLoad
+		last := -1
+		for _, i := range entries {
+			if chain.OnLoad >= 0 && end(i) > chain.OnLoad+criticalTolerance {
+				continue
+			}
+			if last < 0 || end(i) > end(last) {
+				last = i
+			}
+		}
+		if last < 0 {
+			continue
+		}
+
+		var links []int
+		inPage := make(map[int]bool, len(entries))
+		for _, i := range entries {
+			inPage[i] = true
+		}
+		for i, ok := last, true; ok && inPage[i]; i, ok = parents[i] {
+			links = append([]int{i}, links...)
+		}
+
+		// reached is the end of the chain so far; requests overlapping
+		// the one before them only count for the time after it
+		reached := 0.0
+		for _, i := range links {
+			entry := har.Log.Entries[i]
+			request := CriticalRequest{Entry: i, Method: entry.Request.Method, URL: entry.Request.URL,
+				Type: resourceType(entry), Start: begins[i], Time: positive(float64(entry.Time))}
+			if request.Start > reached {
+				request.Gap = request.Start - reached
+				chain.Idle += request.Gap
+			}
+			if e := end(i); e > reached {
+				chain.Busy += e - reached - request.Gap
+				reached = e
+			}
+			chain.Requests = append(chain.Requests, request)
+		}
+		chain.End = reached
+		result = append(result, *chain)
+	}
+	return result
+}
+
+// CriticalPath writes the critical chain of every page of a .har file to
+// w, as a table, or with format "json".
+func CriticalPath(r *bufio.Reader, w io.Writer, format string) error {
+	har, err := readHar(r)
+	if err != nil {
+		return err
+	}
+
+	chains := AnalyzeCriticalChains(har)
+	switch format {
+	case "", "text":
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(chains)
+	default:
+		return fmt.Errorf("unknown format %s", format)
+	}
+
+	// the summary and the requests of a chain are aligned separately
+	for i, chain := range chains {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "Page\t%s\t\n", chain.Title)
+		fmt.Fprintf(tw, "onLoad (ms)\t%s\t\n", formatTiming(chain.OnLoad))
+		fmt.Fprintf(tw, "Chain end (ms)\t%s\t\n", formatTiming(chain.End))
+		fmt.Fprintf(tw, "In requests (ms)\t%s\t%s\t\n", formatTiming(chain.Busy), percentOf(chain.Busy, chain.End))
+		fmt.Fprintf(tw, "Between requests (ms)\t%s\t%s\t\n", formatTiming(chain.Idle), percentOf(chain.Idle, chain.End))
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", "#", "Method", "URL", "Type", "Start (ms)", "Time (ms)", "Gap (ms)")
+		for _, r := range chain.Requests {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t\n", r.Entry, r.Method, shortURL(r.URL), r.Type, formatTiming(r.Start), formatTiming(r.Time), formatTiming(r.Gap))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// percentOf formats part as a percentage of total.
+func percentOf(part, total float64) string {
+	if total <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", 100*part/total)
+}
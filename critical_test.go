@@ -0,0 +1,78 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeCriticalChains(t *testing.T) {
+	chains := AnalyzeCriticalChains(readTestHar(t, "critical.har"))
+	if len(chains) != 2 {
+		t.Fatalf("got %d chains", len(chains))
+	}
+
+	chain := chains[0]
+	if chain.Page != "page_1" || chain.OnLoad != 500 || chain.End != 280 || chain.Busy != 230 || chain.Idle != 50 {
+		t.Errorf("got %+v", chain)
+	}
+	expected := []CriticalRequest{
+		{Entry: 0, Method: "GET", URL: "https://example.com/", Type: "document", Start: 0, Time: 100},
+		{Entry: 1, Method: "GET", URL: "https://example.com/app.js", Type: "script", Start: 90, Time: 60},
+		{Entry: 2, Method: "GET", URL: "https://example.com/api/items", Type: "fetch", Start: 200, Time: 80, Gap: 50},
+	}
+	if len(chain.Requests) != len(expected) {
+		t.Fatalf("got %+v", chain.Requests)
+	}
+	for i, r := range chain.Requests {
+		if r != expected[i] {
+			t.Errorf("%d: got %+v, want %+v", i, r, expected[i])
+		}
+	}
+
+	if chain := chains[1]; chain.OnLoad != -1 || chain.End != 20 || len(chain.Requests) != 1 || chain.Requests[0].Entry != 5 {
+		t.Errorf("got %+v", chain)
+	}
+}
+
+func TestCriticalPath(t *testing.T) {
+	harData, err := os.ReadFile("test/critical.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := CriticalPath(bufio.NewReader(bytes.NewReader(harData)), &out, "text"); err != nil {
+		t.Fatal(err)
+	}
+	// the summary and the requests of each chain are separate tables
+	tables := strings.Split(out.String(), "\n\n")
+	if len(tables) != 4 {
+		t.Fatalf("got %s", out.String())
+	}
+	summary, requests := tableRows(tables[0]), tableRows(tables[1])
+	if len(summary) != 5 || !reflect.DeepEqual(summary[2], []string{"Chain end (ms)", "280"}) ||
+		!reflect.DeepEqual(summary[3], []string{"In requests (ms)", "230", "82%"}) {
+		t.Errorf("got summary %q", summary)
+	}
+	if expected := []string{"2", "GET", "https://example.com/api/items", "fetch", "200", "80", "50"}; len(requests) != 4 || !reflect.DeepEqual(requests[3], expected) {
+		t.Errorf("got requests %q", requests)
+	}
+
+	out.Reset()
+	if err := CriticalPath(bufio.NewReader(bytes.NewReader(harData)), &out, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var chains []CriticalChain
+	if err := json.Unmarshal(out.Bytes(), &chains); err != nil || len(chains) != 2 {
+		t.Errorf("got %+v, %v", chains, err)
+	}
+
+	if err := CriticalPath(bufio.NewReader(bytes.NewReader(harData)), &out, "csv"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -2,7 +2,6 @@ package hargo
 
 import (
 	"bufio"
-	"encoding/json"
 	"net/url"
 	"strings"
 
@@ -13,9 +12,7 @@ import (
 // ToCurl converts a HAR Entry to a curl command line
 // curl -X <method> -b "<name=value&name=value...>" -H <name: value> ... -d "<postData>" <url>
 func ToCurl(r *bufio.Reader) (string, error) {
-	dec := json.NewDecoder(r)
-	var har Har
-	err := dec.Decode(&har)
+	har, err := readHar(r)
 
 	if err != nil {
 		log.Error(err)
@@ -24,6 +21,9 @@ func ToCurl(r *bufio.Reader) (string, error) {
 	var command string
 
 	for _, entry := range har.Log.Entries {
+		if entry.IsWebSocket() {
+			continue
+		}
 		cmd, err := fromEntry(entry)
 
 		if err != nil {
@@ -0,0 +1,242 @@
+package hargo
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Job actions.
+const (
+	// JobReplay sends the requests of the .har file and records their
+	// live responses. It is the action of a Job without one.
+	JobReplay = "replay"
+	// JobAnalyze records the responses stored in the .har file, e.g. one
+	// that a record proxy keeps appending to, without sending requests.
+	JobAnalyze = "analyze"
+)
+
+// Job is a recurring replay or analysis of a stored .har file.
+type Job struct {
+	// HarFile is the path or URL of the .har file to replay, opened by
+	// OpenSource on every run.
+	HarFile string
+	// Schedule determines when the job runs.
+	Schedule Schedule
+	// Action is JobReplay or JobAnalyze, JobReplay if empty.
+	Action string
+}
+
+// Daemon runs the given jobs on their schedules until ctx is done, aborting
+// the replays in progress. Every replayed or analyzed request produces a
+// TestResult which is written to InfluxDB when u is set, turning recorded
+// traffic into lightweight synthetic monitoring. A job with any other
+// action than JobReplay or JobAnalyze is an error.
+func Daemon(ctx context.Context, jobs []Job, u url.URL, ignoreHarCookies bool, insecureSkipVerify bool) error {
+	replays := false
+	for _, job := range jobs {
+		switch job.Action {
+		case "", JobReplay:
+			replays = true
+		case JobAnalyze:
+		default:
+			return fmt.Errorf("unknown action %q for %s, must be %s or %s", job.Action, job.HarFile, JobReplay, JobAnalyze)
+		}
+	}
+	if replays {
+		if err := requireNetwork("daemon"); err != nil {
+			return err
+		}
+	}
+
+	results := make(chan TestResult)
+
+	if (url.URL{}) != u {
+		go WritePoint(u, results)
+	} else {
+		go func(results chan TestResult) {
+			for {
+				<-results
+			}
+		}(results)
+	}
+
+	for _, job := range jobs {
+		go scheduleJob(ctx, job, results, ignoreHarCookies, insecureSkipVerify)
+	}
+
+	<-ctx.Done()
+	log.Info("Stopping daemon")
+	return nil
+}
+
+// scheduleJob sleeps until the next activation of the job and runs it,
+// until ctx is done. Runs are never overlapped: an activation that is
+// missed while a run is still in progress is skipped.
+func scheduleJob(ctx context.Context, job Job, results chan TestResult, ignoreHarCookies bool, insecureSkipVerify bool) {
+	for {
+		next := job.Schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Warnf("Schedule for %s never fires again", job.HarFile)
+			return
+		}
+
+		log.Infof("Next run of %s at %s", job.HarFile, next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		var err error
+		if job.Action == JobAnalyze {
+			err = analyzeJob(ctx, job, results)
+		} else {
+			err = replayJob(ctx, job, results, ignoreHarCookies, insecureSkipVerify)
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Errorf("Run of %s failed: %v", job.HarFile, err)
+		}
+	}
+}
+
+// replayJob executes every entry of the job's .har file once, in order.
+func replayJob(ctx context.Context, job Job, results chan TestResult, ignoreHarCookies bool, insecureSkipVerify bool) error {
+	file, err := OpenSource(ctx, job.HarFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	har, err := Decode(NewReader(file))
+	if err != nil {
+		return err
+	}
+
+	jar, _ := cookiejar.New(nil)
+
+	client := http.Client{
+		CheckRedirect: func(r *http.Request, via []*http.Request) error {
+			r.URL.Opaque = r.URL.Path
+			return nil
+		},
+		Jar: jar,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureSkipVerify},
+		},
+	}
+
+	harfile := filepath.Base(job.HarFile)
+	failures := 0
+
+	emitPhase(EventPhaseStarted, "daemon:"+harfile)
+	defer emitPhase(EventPhaseFinished, "daemon:"+harfile)
+
+	for i, entry := range har.Log.Entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsWebSocket() {
+			continue
+		}
+
+		req, err := EntryToRequest(&entry, ignoreHarCookies)
+		if err != nil {
+			return err
+		}
+		req = req.WithContext(ctx)
+
+		jar.SetCookies(req.URL, req.Cookies())
+
+		startTime := time.Now()
+		resp, err := client.Do(req)
+		endTime := time.Now()
+
+		tr := TestResult{
+			URL:       req.URL.String(),
+			StartTime: startTime,
+			EndTime:   endTime,
+			Latency:   int(endTime.Sub(startTime) / time.Millisecond),
+			Method:    req.Method,
+			HarFile:   harfile}
+
+		if err != nil {
+			log.Error(err)
+			failures++
+			emit(Event{Type: EventError, Phase: "daemon:" + harfile, Index: i, URL: tr.URL, Method: tr.Method,
+				Latency: endTime.Sub(startTime), Err: err})
+		} else {
+			tr.Status = resp.StatusCode
+			resp.Body.Close()
+			if resp.StatusCode >= 400 {
+				failures++
+			}
+			emit(Event{Type: EventRequestReplayed, Phase: "daemon:" + harfile, Index: i, URL: tr.URL, Method: tr.Method,
+				Status: tr.Status, Latency: endTime.Sub(startTime)})
+		}
+
+		results <- tr
+	}
+
+	log.Infof("Replayed %s: %d requests, %d failures", harfile, len(har.Log.Entries), failures)
+	return nil
+}
+
+// analyzeJob records the status and timing of every entry of the job's .har
+// file as it was captured.
+func analyzeJob(ctx context.Context, job Job, results chan TestResult) error {
+	file, err := OpenSource(ctx, job.HarFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	har, err := Decode(NewReader(file))
+	if err != nil {
+		return err
+	}
+
+	harfile := filepath.Base(job.HarFile)
+	failures := 0
+
+	emitPhase(EventPhaseStarted, "daemon:"+harfile)
+	defer emitPhase(EventPhaseFinished, "daemon:"+harfile)
+
+	for _, entry := range har.Log.Entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		startTime, err := parseStartedDateTime(entry.StartedDateTime)
+		if err != nil {
+			log.Warnf("Skipping entry of %s with invalid startedDateTime %q", harfile, entry.StartedDateTime)
+			continue
+		}
+		latency := time.Duration(float64(entry.Time) * float64(time.Millisecond))
+		if entry.Response.Status == 0 || entry.Response.Status >= 400 {
+			failures++
+		}
+
+		results <- TestResult{
+			URL:       entry.Request.URL,
+			Status:    entry.Response.Status,
+			StartTime: startTime,
+			EndTime:   startTime.Add(latency),
+			Latency:   int(latency / time.Millisecond),
+			Method:    entry.Request.Method,
+			HarFile:   harfile}
+	}
+
+	log.Infof("Analyzed %s: %d requests, %d failures", harfile, len(har.Log.Entries), failures)
+	return nil
+}
@@ -0,0 +1,55 @@
+package hargo
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDaemonUnknownAction(t *testing.T) {
+	err := Daemon(context.Background(), []Job{{HarFile: "foo.har", Action: "delete"}}, url.URL{}, false, false)
+	if err == nil || !strings.Contains(err.Error(), `unknown action "delete"`) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestAnalyzeJob(t *testing.T) {
+	harData, _ := json.Marshal(Har{Log: Log{Entries: []Entry{
+		{StartedDateTime: "2024-01-02T10:00:00.000Z", Time: 120, Request: Request{Method: "GET", URL: "https://example.com/"},
+			Response: Response{Status: 200}},
+		{StartedDateTime: "invalid", Request: Request{Method: "GET", URL: "https://example.com/skipped"}},
+		{StartedDateTime: "2024-01-02T10:00:01.000Z", Time: 30, Request: Request{Method: "POST", URL: "https://example.com/api"},
+			Response: Response{Status: 502}},
+	}}})
+	harFile := filepath.Join(t.TempDir(), "stored.har")
+	if err := os.WriteFile(harFile, harData, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(chan TestResult, 10)
+	if err := analyzeJob(context.Background(), Job{HarFile: harFile, Action: JobAnalyze}, results); err != nil {
+		t.Fatal(err)
+	}
+	close(results)
+
+	var got []TestResult
+	for tr := range results {
+		got = append(got, tr)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d results, want 2", len(got))
+	}
+	start := time.Date(2024, time.January, 2, 10, 0, 0, 0, time.UTC)
+	if got[0].URL != "https://example.com/" || got[0].Status != 200 || got[0].Latency != 120 ||
+		!got[0].StartTime.Equal(start) || !got[0].EndTime.Equal(start.Add(120*time.Millisecond)) || got[0].HarFile != "stored.har" {
+		t.Errorf("got %+v", got[0])
+	}
+	if got[1].Method != "POST" || got[1].Status != 502 || got[1].Latency != 30 {
+		t.Errorf("got %+v", got[1])
+	}
+}
@@ -0,0 +1,196 @@
+package hargo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dashboardWindow is the span of the rolling rate, error rate and latency
+// percentiles shown by a LoadDashboard.
+const dashboardWindow = 10 * time.Second
+
+// dashboardInterval is how often a LoadDashboard redraws.
+const dashboardInterval = 500 * time.Millisecond
+
+// LoadDashboard renders live statistics of a load test to a terminal,
+// redrawing them in place: the current request rate, error rate, requests
+// in flight and latency percentiles over the last 10 seconds. A nil
+// *LoadDashboard ignores all observations.
+type LoadDashboard struct {
+	w     io.Writer
+	start time.Time
+
+	mu       sync.Mutex
+	inFlight int
+	total    int
+	errors   int
+	statuses map[int]int
+	samples  []dashboardSample
+	lines    int
+}
+
+// dashboardSample is a completed request within the dashboard window.
+type dashboardSample struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+// NewLoadDashboard returns a LoadDashboard drawing to w, typically a
+// terminal's stdout.
+func NewLoadDashboard(w io.Writer) *LoadDashboard {
+	return &LoadDashboard{w: w, start: time.Now(), statuses: make(map[int]int)}
+}
+
+// Started records a request being sent.
+func (d *LoadDashboard) Started() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.inFlight++
+	d.mu.Unlock()
+}
+
+// Observe records a completed request with its status code and latency.
+func (d *LoadDashboard) Observe(status int, latency time.Duration) {
+	d.done(status, latency, false)
+}
+
+// ObserveError records a request that failed without a response.
+func (d *LoadDashboard) ObserveError(latency time.Duration) {
+	d.done(0, latency, true)
+}
+
+func (d *LoadDashboard) done(status int, latency time.Duration, failed bool) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.inFlight--
+	d.total++
+	if failed {
+		d.errors++
+	} else {
+		d.statuses[status]++
+	}
+	d.samples = append(d.samples, dashboardSample{at: time.Now(), latency: latency, failed: failed})
+}
+
+// Run redraws the dashboard until ctx is done, then draws it a last time.
+func (d *LoadDashboard) Run(ctx context.Context) {
+	if d == nil {
+		return
+	}
+	ticker := time.NewTicker(dashboardInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			d.Draw(time.Now())
+			return
+		case now := <-ticker.C:
+			d.Draw(now)
+		}
+	}
+}
+
+// Draw renders the statistics as of now over the previous drawing.
+func (d *LoadDashboard) Draw(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lines := d.render(now)
+	var b strings.Builder
+	if d.lines > 0 {
+		// move to the start of the previous drawing
+		fmt.Fprintf(&b, "\x1b[%dA", d.lines)
+	}
+	for _, line := range lines {
+		b.WriteString("\r\x1b[2K" + line + "\n")
+	}
+	d.lines = len(lines)
+	io.WriteString(d.w, b.String())
+}
+
+// render prunes the samples outside the window ending at now and returns
+// the lines of the dashboard.
+func (d *LoadDashboard) render(now time.Time) []string {
+	cutoff := now.Add(-dashboardWindow)
+	i := sort.Search(len(d.samples), func(i int) bool { return d.samples[i].at.After(cutoff) })
+	d.samples = d.samples[i:]
+
+	window := dashboardWindow
+	if elapsed := now.Sub(d.start); elapsed < window {
+		window = elapsed
+	}
+
+	failed := 0
+	latencies := make([]time.Duration, 0, len(d.samples))
+	for _, s := range d.samples {
+		if s.failed {
+			failed++
+		}
+		latencies = append(latencies, s.latency)
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	rate, errorRate := 0.0, 0.0
+	if window > 0 {
+		rate = float64(len(d.samples)) / window.Seconds()
+	}
+	if len(d.samples) > 0 {
+		errorRate = float64(failed) / float64(len(d.samples)) * 100
+	}
+
+	lines := []string{
+		fmt.Sprintf("Elapsed    %v", now.Sub(d.start).Round(time.Second)),
+		fmt.Sprintf("Requests   %d total, %.1f/s, %d in flight", d.total, rate, d.inFlight),
+		fmt.Sprintf("Errors     %d total, %.1f%%", d.errors, errorRate),
+	}
+
+	if len(latencies) == 0 {
+		lines = append(lines, "Latency    -")
+	} else {
+		var parts []string
+		for _, p := range []float64{50, 90, 99, 99.9} {
+			parts = append(parts, fmt.Sprintf("p%g %v", p, sortedPercentile(latencies, p).Round(time.Microsecond*100)))
+		}
+		parts = append(parts, fmt.Sprintf("max %v", latencies[len(latencies)-1].Round(time.Microsecond*100)))
+		lines = append(lines, "Latency    "+strings.Join(parts, ", "))
+	}
+
+	statuses := make([]int, 0, len(d.statuses))
+	for status := range d.statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	var parts []string
+	for _, status := range statuses {
+		parts = append(parts, fmt.Sprintf("%d: %d", status, d.statuses[status]))
+	}
+	lines = append(lines, "Status     "+strings.Join(parts, ", "))
+
+	return lines
+}
+
+// sortedPercentile returns the value at percentile p of sorted values by
+// the nearest-rank method.
+func sortedPercentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
@@ -0,0 +1,47 @@
+package hargo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadDashboard(t *testing.T) {
+	var b bytes.Buffer
+	d := NewLoadDashboard(&b)
+
+	for i := 1; i <= 10; i++ {
+		d.Started()
+		d.Observe(200, time.Duration(i)*time.Millisecond)
+	}
+	d.Started()
+	d.Started()
+	d.Observe(404, 20*time.Millisecond)
+	d.Started()
+	d.ObserveError(30 * time.Millisecond)
+
+	lines := d.render(d.start.Add(4 * time.Second))
+	want := []string{
+		"Elapsed    4s",
+		"Requests   12 total, 3.0/s, 1 in flight",
+		"Errors     1 total, 8.3%",
+		"Latency    p50 6ms, p90 20ms, p99 30ms, p99.9 30ms, max 30ms",
+		"Status     200: 10, 404: 1",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	// the rolling window forgets old requests but not the totals
+	lines = d.render(d.start.Add(time.Minute))
+	if lines[1] != "Requests   12 total, 0.0/s, 1 in flight" || lines[3] != "Latency    -" {
+		t.Errorf("after the window: %q", lines)
+	}
+
+	d.Draw(time.Now())
+	d.Draw(time.Now())
+	if out := b.String(); strings.Count(out, "\x1b[5A") != 1 || strings.Count(out, "Elapsed") != 2 {
+		t.Errorf("redraw output %q", out)
+	}
+}
@@ -0,0 +1,225 @@
+package hargo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// BodyDecoder converts the response body of an entry in a binary format,
+// like protobuf, MessagePack, CBOR or Avro, to readable text, usually
+// JSON. The body is already decoded from base64 and the content encoding.
+type BodyDecoder interface {
+	DecodeBody(entry Entry, body []byte) ([]byte, error)
+}
+
+// BodyDecoderFunc adapts a function to the BodyDecoder interface.
+type BodyDecoderFunc func(entry Entry, body []byte) ([]byte, error)
+
+// DecodeBody calls f.
+func (f BodyDecoderFunc) DecodeBody(entry Entry, body []byte) ([]byte, error) {
+	return f(entry, body)
+}
+
+// bodyDecoderRule selects the entries a decoder applies to, by response
+// MIME type or by URL.
+type bodyDecoderRule struct {
+	name     string
+	mimeType string
+	url      *regexp.Regexp
+	decoder  BodyDecoder
+}
+
+// matches reports whether the rule applies to entry.
+func (r bodyDecoderRule) matches(entry Entry) bool {
+	if r.url != nil {
+		return r.url.MatchString(entry.Request.URL)
+	}
+	return matchMimeType(r.mimeType, entry.Response.Content.MimeType)
+}
+
+// BodyDecoders is a registry of body decoders used by extract, grep and the
+// web UI. Decoders registered later take precedence, so users can replace
+// the defaults. A nil *BodyDecoders has no decoders.
+type BodyDecoders struct {
+	rules []bodyDecoderRule
+}
+
+// AddMimeType registers dec for responses of a media type, e.g.
+// application/msgpack, or of several with a trailing wildcard, e.g.
+// application/grpc*. The name labels the decoded bodies, e.g. in the
+// names of extracted files.
+func (d *BodyDecoders) AddMimeType(name, pattern string, dec BodyDecoder) {
+	d.rules = append(d.rules, bodyDecoderRule{name: name, mimeType: pattern, decoder: dec})
+}
+
+// AddURL registers dec for entries whose URL matches pattern.
+func (d *BodyDecoders) AddURL(name string, pattern *regexp.Regexp, dec BodyDecoder) {
+	d.rules = append(d.rules, bodyDecoderRule{name: name, url: pattern, decoder: dec})
+}
+
+// Lookup returns the name and the decoder of the last rule that applies to
+// entry.
+func (d *BodyDecoders) Lookup(entry Entry) (string, BodyDecoder, bool) {
+	if d == nil {
+		return "", nil, false
+	}
+	for i := len(d.rules) - 1; i >= 0; i-- {
+		if d.rules[i].matches(entry) {
+			return d.rules[i].name, d.rules[i].decoder, true
+		}
+	}
+	return "", nil, false
+}
+
+// Decode decodes the response body of entry with the decoder that applies
+// to it, and returns the decoder name with the result. The name is empty
+// if no decoder applies.
+func (d *BodyDecoders) Decode(entry Entry) (string, []byte, error) {
+	name, dec, ok := d.Lookup(entry)
+	if !ok {
+		return "", nil, nil
+	}
+	body, err := entry.DecodedBody()
+	if err != nil {
+		return name, nil, err
+	}
+	decoded, err := dec.DecodeBody(entry, body)
+	if err != nil {
+		return name, nil, fmt.Errorf("%s decoder: %v", name, err)
+	}
+	return name, decoded, nil
+}
+
+// merge returns the rules of d followed by those of other, which take
+// precedence.
+func (d *BodyDecoders) merge(other *BodyDecoders) *BodyDecoders {
+	merged := &BodyDecoders{}
+	if d != nil {
+		merged.rules = append(merged.rules, d.rules...)
+	}
+	if other != nil {
+		merged.rules = append(merged.rules, other.rules...)
+	}
+	return merged
+}
+
+// DefaultBodyDecoders returns the built-in decoders: gRPC and gRPC-Web
+// calls as with DecodeGRPC, and application/x-protobuf bodies with the type
+// named by a messageType or proto parameter of the MIME type if protos
+// defines it, and without schema otherwise.
+func DefaultBodyDecoders(protos *ProtoRegistry) *BodyDecoders {
+	d := &BodyDecoders{}
+	d.AddMimeType("grpc", "application/grpc*", GRPCDecoder(protos))
+	for _, mimeType := range []string{"application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf"} {
+		d.AddMimeType("protobuf", mimeType, ProtobufDecoder(protos))
+	}
+	return d
+}
+
+// GRPCDecoder returns a decoder writing the messages of gRPC calls as
+// indented JSON, decoded with the types of protos if set.
+func GRPCDecoder(protos *ProtoRegistry) BodyDecoder {
+	return BodyDecoderFunc(func(entry Entry, body []byte) ([]byte, error) {
+		call, err := DecodeGRPC(entry, protos)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.MarshalIndent(call, "", "  ")
+		return append(data, '\n'), err
+	})
+}
+
+// ProtobufDecoder returns a decoder of single protobuf messages. The type
+// is taken from the messageType or proto parameter of the MIME type, e.g.
+// application/x-protobuf; messageType=shop.Cart.
+func ProtobufDecoder(protos *ProtoRegistry) BodyDecoder {
+	return BodyDecoderFunc(func(entry Entry, body []byte) ([]byte, error) {
+		_, params, _ := mime.ParseMediaType(entry.Response.Content.MimeType)
+		messageType := params["messagetype"]
+		if messageType == "" {
+			messageType = params["proto"]
+		}
+		if _, known := protos.messageType(messageType); known {
+			return protos.DecodeJSON(messageType, body)
+		}
+		return DecodeProtoSchemaless(body)
+	})
+}
+
+// CommandDecoder returns a decoder running a command, with the body on its
+// standard input and the decoded body read from its standard output, e.g.
+// msgpack2json or protoc --decode_raw.
+func CommandDecoder(name string, args ...string) BodyDecoder {
+	return BodyDecoderFunc(func(entry Entry, body []byte) ([]byte, error) {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(name, args...)
+		cmd.Stdin = bytes.NewReader(body)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("%s: %v %s", name, err, strings.TrimSpace(stderr.String()))
+		}
+		return stdout.Bytes(), nil
+	})
+}
+
+// ParseDecoderRule parses a command decoder of the form
+// mime/type=command args, or url:regexp=command args for URLs, and adds it
+// to d.
+func (d *BodyDecoders) ParseDecoderRule(rule string) error {
+	pattern, command, ok := strings.Cut(rule, "=")
+	args := strings.Fields(command)
+	pattern = strings.TrimSpace(pattern)
+	if !ok || pattern == "" || len(args) == 0 {
+		return fmt.Errorf("invalid decoder %q, expected mime/type=command or url:regexp=command", rule)
+	}
+	dec := CommandDecoder(args[0], args[1:]...)
+	if expr, isURL := strings.CutPrefix(pattern, "url:"); isURL {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("invalid decoder %q: %v", rule, err)
+		}
+		d.AddURL("decoded", re, dec)
+		return nil
+	}
+	d.AddMimeType("decoded", pattern, dec)
+	return nil
+}
+
+// matchMimeType reports whether mimeType has the media type of pattern, or
+// starts with it if it ends in a wildcard, e.g. video/*.
+func matchMimeType(pattern, mimeType string) bool {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(mimeType))
+	}
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+		return strings.HasPrefix(mediaType, prefix)
+	}
+	return mediaType == pattern
+}
+
+// writeDecodedBody writes the body decoded by the named decoder next to
+// the extracted file at fullPath and returns its manifest entry.
+func writeDecodedBody(fullPath, name string, decoded []byte, entry Entry, target extractTarget, paths *pathAllocator, modTime time.Time) (ManifestEntry, error) {
+	ext, mimeType := ".txt", "text/plain"
+	if json.Valid(decoded) {
+		ext, mimeType = ".json", "application/json"
+	}
+	m := ManifestEntry{
+		OriginalURL:   entry.Request.URL,
+		ExtractedPath: paths.allocate(fullPath + "." + name + ext),
+		MimeType:      mimeType,
+		Size:          len(decoded),
+		Method:        entry.Request.Method,
+		Status:        entry.Response.Status,
+	}
+	return m, target.writeFile(m.ExtractedPath, decoded, modTime)
+}
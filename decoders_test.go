@@ -0,0 +1,148 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestBodyDecoders(t *testing.T) {
+	upper := BodyDecoderFunc(func(entry Entry, body []byte) ([]byte, error) {
+		return bytes.ToUpper(body), nil
+	})
+	var d BodyDecoders
+	d.AddMimeType("any", "application/*", BodyDecoderFunc(func(entry Entry, body []byte) ([]byte, error) { return []byte("any"), nil }))
+	d.AddMimeType("upper", "application/msgpack", upper)
+	d.AddURL("api", regexp.MustCompile(`/api/`), upper)
+
+	tests := []struct {
+		url, mimeType string
+		name          string
+		decoded       string
+	}{
+		{"https://example.com/a", "application/msgpack; charset=binary", "upper", "BODY"},
+		{"https://example.com/a", "Application/Msgpack", "upper", "BODY"},
+		{"https://example.com/a", "application/cbor", "any", "any"},
+		// later rules take precedence
+		{"https://example.com/api/a", "application/msgpack", "api", "BODY"},
+		{"https://example.com/api/a", "text/plain", "api", "BODY"},
+		{"https://example.com/a", "text/plain", "", ""},
+	}
+
+	for i, test := range tests {
+		entry := Entry{Request: Request{URL: test.url}, Response: Response{Content: Content{MimeType: test.mimeType, Text: "body"}}}
+		name, decoded, err := d.Decode(entry)
+		if err != nil || name != test.name || string(decoded) != test.decoded {
+			t.Errorf("%d: got %s %q %v, want %s %q", i, name, decoded, err, test.name, test.decoded)
+		}
+	}
+
+	var none *BodyDecoders
+	if name, _, err := none.Decode(Entry{}); name != "" || err != nil {
+		t.Errorf("got %s %v", name, err)
+	}
+}
+
+func TestDefaultBodyDecoders(t *testing.T) {
+	reg, err := ParseProtoDescriptors(testDescriptorSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := base64.StdEncoding.EncodeToString(pbJoin(pbString(1, "hi"), pbVarint(2, 3)))
+
+	tests := []struct {
+		mimeType string
+		reg      *ProtoRegistry
+		expected string
+	}{
+		{"application/x-protobuf", reg, `{"1":"hi","2":3}`},
+		{"application/x-protobuf; messageType=demo.HelloReply", reg, `{"message":"hi","delta":-2}`},
+		{`application/protobuf; proto="demo.HelloReply"`, reg, `{"message":"hi","delta":-2}`},
+		{"application/x-protobuf; messageType=demo.HelloReply", nil, `{"1":"hi","2":3}`},
+	}
+
+	for i, test := range tests {
+		entry := Entry{Response: Response{Content: Content{MimeType: test.mimeType, Text: body, Encoding: "base64"}}}
+		name, decoded, err := DefaultBodyDecoders(test.reg).Decode(entry)
+		if err != nil || name != "protobuf" || string(decoded) != test.expected {
+			t.Errorf("%d: got %s %s %v, want %s", i, name, decoded, err, test.expected)
+		}
+	}
+
+	entry := grpcTestEntry("application/grpc-web+proto", "application/grpc-web+proto",
+		grpcFrameBytes(0, pbString(1, "ann")), grpcFrameBytes(0, pbString(1, "hi")), nil)
+	if name, decoded, err := DefaultBodyDecoders(reg).Decode(entry); err != nil || name != "grpc" || !strings.Contains(string(decoded), `"message": "hi"`) {
+		t.Errorf("got %s %s %v", name, decoded, err)
+	}
+}
+
+func TestParseDecoderRule(t *testing.T) {
+	var d BodyDecoders
+	for _, rule := range []string{"", "application/msgpack", "application/msgpack=", "=cat", "url:(=cat"} {
+		if err := d.ParseDecoderRule(rule); err == nil {
+			t.Errorf("%q: expected an error", rule)
+		}
+	}
+
+	if _, err := exec.LookPath("tr"); err != nil {
+		t.Skip("tr not available")
+	}
+	if err := d.ParseDecoderRule("url:\\.bin$=tr a-z A-Z"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ParseDecoderRule("application/x-fail=tr"); err != nil {
+		t.Fatal(err)
+	}
+	entry := Entry{Request: Request{URL: "https://example.com/x.bin"}, Response: Response{Content: Content{Text: "body"}}}
+	if name, decoded, err := d.Decode(entry); err != nil || name != "decoded" || string(decoded) != "BODY" {
+		t.Errorf("got %s %q %v", name, decoded, err)
+	}
+	// errors of the command are reported with its output
+	entry = Entry{Request: Request{URL: "https://example.com/x"}, Response: Response{Content: Content{MimeType: "application/x-fail", Text: "body"}}}
+	if _, _, err := d.Decode(entry); err == nil || !strings.Contains(err.Error(), "tr:") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestExtractDecoders(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	har := Har{Log: Log{Entries: []Entry{
+		{Request: Request{Method: "GET", URL: "https://example.com/data.bin"},
+			Response: Response{Status: 200, Content: Content{MimeType: "application/msgpack", Text: "payload"}}},
+		{Request: Request{Method: "GET", URL: "https://example.com/cart"},
+			Response: Response{Status: 200, Content: Content{MimeType: "application/x-protobuf", Text: base64.StdEncoding.EncodeToString(pbString(1, "hi")), Encoding: "base64"}}},
+	}}}
+	data, _ := json.Marshal(har)
+	var d BodyDecoders
+	d.AddMimeType("msgpack", "application/msgpack", BodyDecoderFunc(func(entry Entry, body []byte) ([]byte, error) {
+		return bytes.ToUpper(body), nil
+	}))
+
+	// GRPC adds the default decoders, like the protobuf one
+	_, err := ExtractContext(context.Background(), bufio.NewReader(bytes.NewReader(data)), ExtractOptions{Decoders: &d, GRPC: true})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	for pattern, expected := range map[string]string{
+		"./hargo-extract-*/example.com/data.bin.msgpack.txt": "PAYLOAD",
+		"./hargo-extract-*/example.com/cart*.protobuf.json":  `{"1":"hi"}`,
+	} {
+		matches, _ := filepath.Glob(pattern)
+		if len(matches) != 1 {
+			t.Errorf("%s: got %v", pattern, matches)
+			continue
+		}
+		if content, _ := os.ReadFile(matches[0]); string(content) != expected {
+			t.Errorf("%s: got %q, want %q", pattern, content, expected)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package hargo
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// DependencyNode is a request of a dependency graph.
+type DependencyNode struct {
+	// Entry is the 0-based position of the entry in the .har file as
+	// recorded, before Decode sorts entries by startedDateTime.
+	Entry  int    `json:"entry"`
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+	// Type is Chrome's resource type, or one derived from the MIME type
+	// (document, script, stylesheet, image, font or other).
+	Type string `json:"type"`
+	// Start is when the request started, in milliseconds since the first
+	// request of the file, and Time how long it took.
+	Start float64 `json:"start"`
+	Time  float64 `json:"time"`
+}
+
+// DependencyEdge records that request From caused request To.
+type DependencyEdge struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+	// Via is how the dependency was found: "initiator" for the URL of
+	// Chrome's _initiator, "stack" for the top of its JavaScript stack,
+	// "redirect" for the redirectURL of another response and "referer" for
+	// the Referer header.
+	Via string `json:"via"`
+	// Initiator is the type of Chrome's _initiator, e.g. parser or script.
+	Initiator string `json:"initiator,omitempty"`
+}
+
+// DependencyGraph is the graph of which request caused which. Every
+// request has at most one parent, which started before it, so the graph
+// is a forest.
+type DependencyGraph struct {
+	Nodes []DependencyNode `json:"nodes"`
+	Edges []DependencyEdge `json:"edges"`
+}
+
+// BuildDependencyGraph returns the dependency graph of the entries of har,
+// numbered by their position in har.Log.Entries. The parent of a request is
+// the latest earlier request of the URL of its _initiator, of the script at
+// the top of the initiator's stack, of the response redirecting to it, or
+// of its Referer, in that order.
+func BuildDependencyGraph(har Har) DependencyGraph {
+	return buildDependencyGraph(har, Selection{})
+}
+
+func buildDependencyGraph(har Har, selection Selection) DependencyGraph {
+	entries := har.Log.Entries
+	starts := make([]time.Time, len(entries))
+	var first time.Time
+	for i, entry := range entries {
+		if t, err := parseStartedDateTime(entry.StartedDateTime); err == nil {
+			starts[i] = t
+			if first.IsZero() || t.Before(first) {
+				first = t
+			}
+		}
+	}
+
+	graph := DependencyGraph{Nodes: []DependencyNode{}, Edges: []DependencyEdge{}}
+	byURL := make(map[string][]int)
+	redirects := make(map[string][]int)
+	for i, entry := range entries {
+		if !selection.Includes(i, entry) {
+			continue
+		}
+		u := withoutFragment(entry.Request.URL)
+		byURL[u] = append(byURL[u], i)
+		if location := entry.Response.RedirectURL; location != "" {
+			target := resolveReference(entry.Request.URL, location)
+			redirects[target] = append(redirects[target], i)
+		}
+		node := DependencyNode{Entry: i, Method: entry.Request.Method, URL: entry.Request.URL,
+			Status: entry.Response.Status, Type: resourceType(entry), Time: float64(entry.Time)}
+		if !starts[i].IsZero() {
+			node.Start = milliseconds(starts[i].Sub(first))
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+
+	// before orders requests by start time, then position, so a request
+	// cannot depend on itself or on one of its dependents
+	before := func(i, j int) bool {
+		if !starts[i].Equal(starts[j]) {
+			return starts[i].Before(starts[j])
+		}
+		return i < j
+	}
+	// parent returns the latest of candidates started before entry i
+	parent := func(candidates []int, i int) (int, bool) {
+		found := -1
+		for _, c := range candidates {
+			if before(c, i) && (found < 0 || before(found, c)) {
+				found = c
+			}
+		}
+		return found, found >= 0
+	}
+
+	// requests returns the selected requests of a URL
+	requests := func(u string) []int {
+		if u == "" {
+			return nil
+		}
+		return byURL[withoutFragment(u)]
+	}
+
+	for _, node := range graph.Nodes {
+		entry := entries[node.Entry]
+		var initiator Initiator
+		if entry.Initiator != nil {
+			initiator = *entry.Initiator
+		}
+		sources := []struct {
+			via        string
+			candidates []int
+		}{
+			{"initiator", requests(initiator.URL)},
+			{"stack", requests(stackURL(initiator.Stack))},
+			{"redirect", redirects[withoutFragment(entry.Request.URL)]},
+			{"referer", requests(recordedHeader(entry.Request.Headers, "Referer"))},
+		}
+		for _, source := range sources {
+			if from, ok := parent(source.candidates, node.Entry); ok {
+				graph.Edges = append(graph.Edges, DependencyEdge{From: from, To: node.Entry, Via: source.via, Initiator: initiator.Type})
+				break
+			}
+		}
+	}
+	return graph
+}
+
+// withoutFragment removes the fragment of a URL, which is not sent.
+func withoutFragment(u string) string {
+	u, _, _ = strings.Cut(u, "#")
+	return u
+}
+
+// stackURL returns the URL of the innermost script of a Chrome stack trace,
+// looking into the async parents if the synchronous frames have none.
+func stackURL(stack json.RawMessage) string {
+	type frames struct {
+		CallFrames []struct {
+			URL string `json:"url"`
+		} `json:"callFrames"`
+		Parent *json.RawMessage `json:"parent"`
+	}
+	for len(stack) > 0 {
+		var s frames
+		if err := json.Unmarshal(stack, &s); err != nil {
+			return ""
+		}
+		for _, frame := range s.CallFrames {
+			if frame.URL != "" {
+				return frame.URL
+			}
+		}
+		if s.Parent == nil {
+			return ""
+		}
+		stack = *s.Parent
+	}
+	return ""
+}
+
+// resourceType returns Chrome's resource type of an entry, or derives one
+// from its MIME type.
+func resourceType(e Entry) string {
+	if e.ResourceType != "" {
+		return e.ResourceType
+	}
+	if kind := blockingType(e); kind != "" {
+		return kind
+	}
+	mimeType := strings.ToLower(e.Response.Content.MimeType)
+	switch {
+	case strings.Contains(mimeType, "html"):
+		return "document"
+	case strings.HasPrefix(mimeType, "image/"):
+		return "image"
+	case strings.HasPrefix(mimeType, "font/"), strings.Contains(mimeType, "font-"):
+		return "font"
+	}
+	return "other"
+}
+
+// Dependencies writes the dependency graph of a .har file to w, in format
+// "dot" for Graphviz, "graphml" or "json".
+func Dependencies(r *bufio.Reader, w io.Writer, format string) error {
+	return DependenciesWithSelection(r, w, format, Selection{})
+}
+
+// DependenciesWithSelection is Dependencies with only the entries selected
+// by selection, still numbered in the order of the whole file. Requests
+// caused by a request that is not selected have no parent.
+func DependenciesWithSelection(r *bufio.Reader, w io.Writer, format string, selection Selection) error {
+	har, err := readHar(r)
+	if err != nil {
+		return err
+	}
+	return WriteDependencyGraph(w, buildDependencyGraph(har, selection), format)
+}
+
+// WriteDependencyGraph writes graph to w in format "dot", "graphml" or
+// "json".
+func WriteDependencyGraph(w io.Writer, graph DependencyGraph, format string) error {
+	switch format {
+	case "", "dot":
+		return writeDependencyDOT(w, graph)
+	case "graphml":
+		return writeDependencyGraphML(w, graph)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(graph)
+	}
+	return fmt.Errorf("unknown format %s", format)
+}
+
+// dependencyColors fills the nodes of a DOT graph by resource type.
+var dependencyColors = map[string]string{
+	"document":   "#c6dbef",
+	"script":     "#fdd0a2",
+	"stylesheet": "#c7e9c0",
+	"image":      "#dadaeb",
+	"font":       "#fcbba1",
+	"xhr":        "#fff5b1",
+	"fetch":      "#fff5b1",
+}
+
+func writeDependencyDOT(w io.Writer, graph DependencyGraph) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph requests {")
+	fmt.Fprintln(bw, "  rankdir=LR;")
+	fmt.Fprintln(bw, `  node [shape=box, style=filled, fillcolor="#ffffff", fontname="Helvetica", fontsize=10];`)
+	fmt.Fprintln(bw, `  edge [fontname="Helvetica", fontsize=9];`)
+	for _, node := range graph.Nodes {
+		label := fmt.Sprintf("%s %s\n%d %s, %.0f ms", node.Method, shortURL(node.URL), node.Status, node.Type, node.Time)
+		fmt.Fprintf(bw, "  n%d [label=%s, tooltip=%s", node.Entry, dotQuote(label), dotQuote(node.URL))
+		if color, ok := dependencyColors[node.Type]; ok {
+			fmt.Fprintf(bw, `, fillcolor="%s"`, color)
+		}
+		fmt.Fprintln(bw, "];")
+	}
+	for _, edge := range graph.Edges {
+		label := edge.Initiator
+		if label == "" {
+			label = edge.Via
+		}
+		fmt.Fprintf(bw, "  n%d -> n%d [label=%s];\n", edge.From, edge.To, dotQuote(label))
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// dotQuote returns s as a quoted DOT string, with newlines as line breaks.
+func dotQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", "").Replace(s)
+	return `"` + s + `"`
+}
+
+func writeDependencyGraphML(w io.Writer, graph DependencyGraph) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(bw, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	for _, key := range []struct{ id, domain, kind string }{
+		{"method", "node", "string"}, {"url", "node", "string"}, {"status", "node", "int"}, {"type", "node", "string"},
+		{"start", "node", "double"}, {"time", "node", "double"}, {"via", "edge", "string"}, {"initiator", "edge", "string"},
+	} {
+		fmt.Fprintf(bw, "  <key id=%q for=%q attr.name=%q attr.type=%q/>\n", key.id, key.domain, key.id, key.kind)
+	}
+	fmt.Fprintln(bw, `  <graph id="requests" edgedefault="directed">`)
+	data := func(key, value string) {
+		fmt.Fprintf(bw, `      <data key="%s">`, key)
+		xml.EscapeText(bw, []byte(value))
+		fmt.Fprintln(bw, "</data>")
+	}
+	for _, node := range graph.Nodes {
+		fmt.Fprintf(bw, "    <node id=\"n%d\">\n", node.Entry)
+		data("method", node.Method)
+		data("url", node.URL)
+		data("status", fmt.Sprint(node.Status))
+		data("type", node.Type)
+		data("start", fmt.Sprintf("%.3f", node.Start))
+		data("time", fmt.Sprintf("%.3f", node.Time))
+		fmt.Fprintln(bw, "    </node>")
+	}
+	for i, edge := range graph.Edges {
+		fmt.Fprintf(bw, "    <edge id=\"e%d\" source=\"n%d\" target=\"n%d\">\n", i, edge.From, edge.To)
+		data("via", edge.Via)
+		if edge.Initiator != "" {
+			data("initiator", edge.Initiator)
+		}
+		fmt.Fprintln(bw, "    </edge>")
+	}
+	fmt.Fprintln(bw, "  </graph>")
+	fmt.Fprintln(bw, "</graphml>")
+	return bw.Flush()
+}
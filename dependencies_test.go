@@ -0,0 +1,91 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBuildDependencyGraph(t *testing.T) {
+	graph := BuildDependencyGraph(readTestHar(t, "dependencies.har"))
+
+	expected := []DependencyEdge{
+		{From: 0, To: 1, Via: "initiator", Initiator: "parser"},
+		{From: 1, To: 2, Via: "stack", Initiator: "script"},
+		{From: 0, To: 3, Via: "referer"},
+		{From: 0, To: 4, Via: "referer"},
+		{From: 4, To: 5, Via: "redirect"},
+	}
+	if len(graph.Edges) != len(expected) {
+		t.Fatalf("got edges %+v", graph.Edges)
+	}
+	for i, edge := range graph.Edges {
+		if edge != expected[i] {
+			t.Errorf("%d: got %+v, want %+v", i, edge, expected[i])
+		}
+	}
+
+	types := []string{"document", "script", "fetch", "image", "other", "other", "other"}
+	for i, node := range graph.Nodes {
+		if node.Entry != i || node.Type != types[i] {
+			t.Errorf("%d: got %+v", i, node)
+		}
+	}
+	if graph.Nodes[1].Start != 1110 || graph.Nodes[6].Start != 0 {
+		t.Errorf("got starts %v and %v", graph.Nodes[1].Start, graph.Nodes[6].Start)
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	harData, err := os.ReadFile("test/dependencies.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format    string
+		selection Selection
+		contains  []string
+	}{
+		{"dot", Selection{}, []string{"digraph requests {", `n0 -> n1 [label="parser"];`, `n4 -> n5 [label="redirect"];`,
+			`n2 [label="POST https://example.com/api/data\n201 fetch, 80 ms", tooltip="https://example.com/api/data", fillcolor="#fff5b1"];`}},
+		{"graphml", Selection{}, []string{`<edge id="e1" source="n1" target="n2">`, `<data key="url">https://example.com/api/data</data>`}},
+		{"json", Selection{Last: 4}, nil},
+	}
+	for i, test := range tests {
+		var out bytes.Buffer
+		if err := DependenciesWithSelection(bufio.NewReader(bytes.NewReader(harData)), &out, test.format, test.selection); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		for _, s := range test.contains {
+			if !strings.Contains(out.String(), s) {
+				t.Errorf("%d: %s not in %s", i, s, out.String())
+			}
+		}
+		if test.format == "graphml" {
+			for dec := xml.NewDecoder(&out); ; {
+				if _, err := dec.Token(); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("invalid GraphML: %v", err)
+				}
+			}
+		}
+		if test.format == "json" {
+			var graph DependencyGraph
+			if err := json.Unmarshal(out.Bytes(), &graph); err != nil || len(graph.Nodes) != 4 || len(graph.Edges) != 3 ||
+				graph.Edges[0] != (DependencyEdge{From: 0, To: 1, Via: "initiator", Initiator: "parser"}) {
+				t.Errorf("got %+v, %v", graph, err)
+			}
+		}
+	}
+
+	if err := Dependencies(bufio.NewReader(bytes.NewReader(harData)), io.Discard, "svg"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
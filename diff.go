@@ -0,0 +1,208 @@
+package hargo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Journey step states reported by Diff.
+const (
+	StepOK        = "ok"
+	StepMissing   = "missing"
+	StepReordered = "reordered"
+	StepSlower    = "slower"
+)
+
+// DiffOptions controls when a matched journey step counts as significantly
+// slower than its golden counterpart: the capture must exceed the golden time
+// by both SlowerPercent percent and MinDelta milliseconds.
+type DiffOptions struct {
+	SlowerPercent float64
+	MinDelta      float64
+}
+
+// DefaultDiffOptions flags steps that take 50% and 100ms longer than golden.
+var DefaultDiffOptions = DiffOptions{SlowerPercent: 50, MinDelta: 100}
+
+// JourneyStep is the comparison of one golden entry with the capture.
+type JourneyStep struct {
+	Index    int     `json:"index"`
+	Method   string  `json:"method"`
+	Template string  `json:"template"`
+	State    string  `json:"state"`
+	Match    int     `json:"match"`
+	Golden   float64 `json:"golden"`
+	Captured float64 `json:"captured"`
+}
+
+// DiffReport is the result of comparing a capture with a golden journey.
+type DiffReport struct {
+	Steps []JourneyStep `json:"steps"`
+	// Extra lists the templates of captured requests that are not part of the
+	// golden journey. They are informational and do not fail the diff.
+	Extra []string `json:"extra,omitempty"`
+}
+
+// Passed reports whether every journey step was found in order and in time.
+func (d DiffReport) Passed() bool {
+	for _, s := range d.Steps {
+		if s.State != StepOK {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	templateUUID   = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	templateNumber = regexp.MustCompile(`^[0-9]+$`)
+	templateHash   = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+	templateParam  = regexp.MustCompile(`^\{[^}]*\}$`)
+)
+
+// URLTemplate reduces a URL to a template that identifies the same journey
+// step across captures: host and path with ids, UUIDs and hashes replaced by
+// placeholders, and the sorted query parameter names without their values.
+// Path segments of the form {name} are kept as placeholders, so golden HARs
+// can spell out their templates explicitly.
+func URLTemplate(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	segments := strings.Split(u.Path, "/")
+	for i, s := range segments {
+		if templateParam.MatchString(s) || templateUUID.MatchString(s) ||
+			templateNumber.MatchString(s) || templateHash.MatchString(s) {
+			segments[i] = "{}"
+		}
+	}
+
+	t := strings.ToLower(u.Host) + strings.Join(segments, "/")
+
+	if u.RawQuery != "" {
+		var keys []string
+		for k := range u.Query() {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		t += "?" + strings.Join(keys, "&")
+	}
+
+	return t
+}
+
+// Diff compares a captured Har against a golden journey Har. Each golden
+// entry is matched by method and URLTemplate to the first unused captured
+// entry; steps are reported as missing when there is no match, reordered when
+// the match comes before the previous step's match, and slower when the
+// captured time exceeds the golden time by the thresholds of opts.
+func Diff(golden Har, capture Har, opts DiffOptions) DiffReport {
+	var report DiffReport
+
+	used := make([]bool, len(capture.Log.Entries))
+	keys := make([]string, len(capture.Log.Entries))
+	for i, entry := range capture.Log.Entries {
+		keys[i] = entry.Request.Method + " " + URLTemplate(entry.Request.URL)
+	}
+
+	last := -1
+	for i, entry := range golden.Log.Entries {
+		step := JourneyStep{
+			Index:    i,
+			Method:   entry.Request.Method,
+			Template: URLTemplate(entry.Request.URL),
+			State:    StepOK,
+			Match:    -1,
+			Golden:   float64(entry.Time),
+		}
+		key := step.Method + " " + step.Template
+
+		for j := range capture.Log.Entries {
+			if !used[j] && keys[j] == key {
+				step.Match = j
+				break
+			}
+		}
+
+		switch {
+		case step.Match < 0:
+			step.State = StepMissing
+		default:
+			used[step.Match] = true
+			step.Captured = float64(capture.Log.Entries[step.Match].Time)
+
+			delta := step.Captured - step.Golden
+			switch {
+			case step.Match < last:
+				step.State = StepReordered
+			case delta > opts.MinDelta && delta > step.Golden*opts.SlowerPercent/100:
+				step.State = StepSlower
+			}
+			if step.Match > last {
+				last = step.Match
+			}
+		}
+
+		report.Steps = append(report.Steps, step)
+	}
+
+	for j, entry := range capture.Log.Entries {
+		if !used[j] {
+			report.Extra = append(report.Extra, entry.Request.Method+" "+URLTemplate(entry.Request.URL))
+		}
+	}
+
+	return report
+}
+
+// DiffFiles compares a captured .har file against a golden journey .har file
+// and writes a report to w. It returns false if the journey regressed.
+func DiffFiles(golden *bufio.Reader, capture *bufio.Reader, w io.Writer, opts DiffOptions) (bool, error) {
+	g, err := Decode(golden)
+	if err != nil {
+		return false, fmt.Errorf("golden: %v", err)
+	}
+
+	c, err := Decode(capture)
+	if err != nil {
+		return false, fmt.Errorf("capture: %v", err)
+	}
+
+	report := Diff(g, c, opts)
+	WriteDiffReport(w, report)
+
+	return report.Passed(), nil
+}
+
+// WriteDiffReport writes a human readable journey comparison to w.
+func WriteDiffReport(w io.Writer, report DiffReport) {
+	failed := 0
+	for _, s := range report.Steps {
+		switch s.State {
+		case StepOK:
+			fmt.Fprintf(w, "ok        [%d] %s %s\n", s.Index, s.Method, s.Template)
+		case StepMissing:
+			failed++
+			fmt.Fprintf(w, "MISSING   [%d] %s %s\n", s.Index, s.Method, s.Template)
+		case StepReordered:
+			failed++
+			fmt.Fprintf(w, "REORDERED [%d] %s %s (found at capture entry %d)\n", s.Index, s.Method, s.Template, s.Match)
+		case StepSlower:
+			failed++
+			fmt.Fprintf(w, "SLOWER    [%d] %s %s (%.0fms, golden %.0fms)\n", s.Index, s.Method, s.Template, s.Captured, s.Golden)
+		}
+	}
+
+	for _, e := range report.Extra {
+		fmt.Fprintf(w, "extra         %s\n", e)
+	}
+
+	fmt.Fprintf(w, "Journey: %d steps, %d failed, %d extra requests\n", len(report.Steps), failed, len(report.Extra))
+}
@@ -0,0 +1,63 @@
+package hargo
+
+import "testing"
+
+func journeyHAR(steps ...Entry) Har {
+	return Har{Log: Log{Entries: steps}}
+}
+
+func journeyStep(method string, url string, time float32) Entry {
+	return Entry{Time: time, Request: Request{Method: method, URL: url}}
+}
+
+func TestURLTemplate(t *testing.T) {
+	tests := []struct {
+		url      string
+		template string
+	}{
+		{"https://Example.com/users/42/orders", "example.com/users/{}/orders"},
+		{"https://example.com/users/{id}/orders", "example.com/users/{}/orders"},
+		{"https://example.com/static/app.3f2a9c1e5b7d8f01.js", "example.com/static/app.3f2a9c1e5b7d8f01.js"},
+		{"https://example.com/static/3f2a9c1e5b7d8f01/app.js", "example.com/static/{}/app.js"},
+		{"https://example.com/o/123e4567-e89b-12d3-a456-426614174000", "example.com/o/{}"},
+		{"https://example.com/search?q=shoes&page=2", "example.com/search?page&q"},
+	}
+
+	for _, test := range tests {
+		if got := URLTemplate(test.url); got != test.template {
+			t.Errorf("URLTemplate(%q) = %q, expected %q", test.url, got, test.template)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	golden := journeyHAR(
+		journeyStep("GET", "https://shop.example.com/", 100),
+		journeyStep("GET", "https://shop.example.com/products/{id}", 200),
+		journeyStep("POST", "https://shop.example.com/cart", 100),
+		journeyStep("GET", "https://shop.example.com/checkout", 100),
+		journeyStep("GET", "https://shop.example.com/confirmation", 100),
+	)
+	capture := journeyHAR(
+		journeyStep("GET", "https://shop.example.com/", 120),
+		journeyStep("GET", "https://cdn.example.com/app.js", 10),
+		journeyStep("GET", "https://shop.example.com/checkout", 90),
+		journeyStep("GET", "https://shop.example.com/products/17", 250),
+		journeyStep("POST", "https://shop.example.com/cart", 400),
+	)
+
+	report := Diff(golden, capture, DefaultDiffOptions)
+
+	expected := []string{StepOK, StepOK, StepSlower, StepReordered, StepMissing}
+	for i, state := range expected {
+		if report.Steps[i].State != state {
+			t.Errorf("step %d: state %s, expected %s", i, report.Steps[i].State, state)
+		}
+	}
+	if len(report.Extra) != 1 || report.Extra[0] != "GET cdn.example.com/app.js" {
+		t.Errorf("unexpected extra requests: %v", report.Extra)
+	}
+	if report.Passed() {
+		t.Error("expected diff to fail")
+	}
+}
@@ -0,0 +1,343 @@
+package hargo
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// LoadResult counts the requests of a load test and their latencies.
+type LoadResult struct {
+	Requests  int               `json:"requests"`
+	Retries   int               `json:"retries"`
+	Failures  int               `json:"failures"`
+	Latencies *LatencyHistogram `json:"latencies"`
+}
+
+// Summary describes the result for the final report.
+func (r LoadResult) Summary() string {
+	latencies := r.Latencies
+	if latencies == nil {
+		latencies = NewLatencyHistogram()
+	}
+	return requestSummary(r.Requests, r.Retries, r.Failures) + "\n" + latencies.Summary()
+}
+
+// merge adds the counts and latencies of other.
+func (r *LoadResult) merge(other LoadResult) {
+	r.Requests += other.Requests
+	r.Retries += other.Retries
+	r.Failures += other.Failures
+	if r.Latencies == nil {
+		r.Latencies = NewLatencyHistogram()
+	}
+	r.Latencies.Merge(other.Latencies)
+}
+
+// LoadResultFile reads a result written by WriteFile.
+func LoadResultFile(path string) (LoadResult, error) {
+	var result LoadResult
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return result, err
+	}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return result, fmt.Errorf("invalid result file %s: %v", path, err)
+	}
+	if result.Latencies == nil {
+		result.Latencies = NewLatencyHistogram()
+	}
+	return result, nil
+}
+
+// WriteFile writes the result as JSON to path.
+func (r LoadResult) WriteFile(path string) error {
+	if r.Latencies == nil {
+		r.Latencies = NewLatencyHistogram()
+	}
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// LoadJob is the share of a distributed load test a coordinator sends to
+// an agent.
+type LoadJob struct {
+	// Har is the .har file, or the segment of its entries, to replay.
+	Har json.RawMessage `json:"har"`
+	// Workers is the number of workers the agent runs.
+	Workers int `json:"workers"`
+	// Duration is how long the agent runs the test in seconds.
+	Duration float64 `json:"duration"`
+	// Config holds the scenario settings, with the load profile scaled to
+	// the agent's share.
+	Config Config `json:"config"`
+}
+
+// agentPath is the endpoint of an agent that runs a LoadJob.
+const agentPath = "/load"
+
+// maxLoadJobSize bounds the request body of a LoadJob, .har file included.
+const maxLoadJobSize = 512 << 20
+
+// AgentHandler returns the HTTP handler of a load agent: a POST to /load
+// with a LoadJob runs it and responds with its LoadResult once done. The
+// job is cancelled when the coordinator disconnects. An agent runs one job
+// at a time. Requests must send token as a bearer token; with an empty
+// token every request is refused.
+func AgentHandler(token string) http.Handler {
+	var busy sync.Mutex
+	mux := http.NewServeMux()
+	mux.HandleFunc(agentPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !busy.TryLock() {
+			http.Error(w, "agent is busy", http.StatusConflict)
+			return
+		}
+		defer busy.Unlock()
+
+		var job LoadJob
+		body := http.MaxBytesReader(w, r.Body, maxLoadJobSize)
+		if err := json.NewDecoder(body).Decode(&job); err != nil {
+			http.Error(w, "invalid job: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := checkAgentConfig(job.Config); err != nil {
+			http.Error(w, "invalid job: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := runAgentJob(r.Context(), job)
+		if err != nil && result.Latencies == nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if err != nil {
+			log.Warnf("Load job ended early: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+	return mux
+}
+
+// checkAgentConfig rejects the settings of a LoadJob naming files on the
+// agent, which a coordinator must not be able to read or overwrite.
+func checkAgentConfig(cfg Config) error {
+	var files []string
+	for name, path := range map[string]string{
+		"histogram":            cfg.Histogram,
+		"results":              cfg.Results,
+		"htmlReport":           cfg.HTMLReport,
+		"capture":              cfg.Capture,
+		"dataFile":             cfg.DataFile,
+		"transport.clientCert": cfg.Transport.ClientCert,
+		"transport.clientKey":  cfg.Transport.ClientKey,
+	} {
+		if path != "" {
+			files = append(files, name)
+		}
+	}
+	if len(cfg.Transport.RootCAs) > 0 {
+		files = append(files, "transport.rootCAs")
+	}
+	if len(files) > 0 {
+		sort.Strings(files)
+		return fmt.Errorf("files cannot be used by agents: %s", strings.Join(files, ", "))
+	}
+	return nil
+}
+
+// runAgentJob runs job as a local load test.
+func runAgentJob(ctx context.Context, job LoadJob) (LoadResult, error) {
+	file, err := os.CreateTemp("", "hargo-agent-*.har")
+	if err != nil {
+		return LoadResult{}, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if _, err := file.Write(job.Har); err != nil {
+		return LoadResult{}, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return LoadResult{}, err
+	}
+
+	timeout := time.Duration(job.Duration * float64(time.Second))
+	log.Infof("Running load job: %d workers for %v", job.Workers, timeout)
+	return runLoadTest(ctx, "agent", file, job.Workers, timeout, url.URL{}, job.Config)
+}
+
+// ServeAgent runs a load agent on addr until ctx is done. Coordinators must
+// send token, which cannot be empty.
+func ServeAgent(ctx context.Context, addr string, token string) error {
+	if token == "" {
+		return fmt.Errorf("agents require a token")
+	}
+	srv := &http.Server{Addr: addr, Handler: AgentHandler(token)}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	log.Infof("Load agent listening on %s", addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Coordinate runs a load test distributed over the agents at the given base
+// URLs and returns their combined result. The workers and the rates of the
+// load profile of cfg are divided among the agents. Each agent replays the
+// whole .har file, or with segment set its own contiguous share of the
+// entries. The Histogram, Results and HTMLReport files of cfg are written
+// from the combined result. Agents cannot use the certificate, CA or data
+// files of cfg.
+func Coordinate(ctx context.Context, agents []string, har Har, workers int, timeout time.Duration, cfg Config, segment bool, token string) (LoadResult, error) {
+	if err := requireNetwork("load"); err != nil {
+		return LoadResult{}, err
+	}
+	if len(agents) == 0 {
+		return LoadResult{}, fmt.Errorf("no agents")
+	}
+	if workers < len(agents) {
+		return LoadResult{}, fmt.Errorf("%d workers cannot be divided among %d agents", workers, len(agents))
+	}
+	if segment && len(har.Log.Entries) < len(agents) {
+		return LoadResult{}, fmt.Errorf("%d entries cannot be divided among %d agents", len(har.Log.Entries), len(agents))
+	}
+
+	agentCfg := cfg
+	agentCfg.Profile = cfg.Profile.scaled(1 / float64(len(agents)))
+	agentCfg.Histogram = ""
+	agentCfg.Dashboard = false
+	agentCfg.Capture = ""
+	agentCfg.Results = ""
+	agentCfg.HTMLReport = ""
+	// the rows of a data file were read along with the scenario
+	agentCfg.DataFile = ""
+	if err := checkAgentConfig(agentCfg); err != nil {
+		return LoadResult{}, err
+	}
+
+	emitPhase(EventPhaseStarted, "coordinate")
+	defer emitPhase(EventPhaseFinished, "coordinate")
+
+	results := make([]LoadResult, len(agents))
+	errs := make([]error, len(agents))
+	var wg sync.WaitGroup
+	for i, agent := range agents {
+		part := har
+		if segment {
+			n := len(har.Log.Entries)
+			part.Log.Entries = har.Log.Entries[i*n/len(agents) : (i+1)*n/len(agents)]
+		}
+		b, err := json.Marshal(part)
+		if err != nil {
+			return LoadResult{}, err
+		}
+		job := LoadJob{
+			Har:      b,
+			Workers:  workers*(i+1)/len(agents) - workers*i/len(agents),
+			Duration: timeout.Seconds(),
+			Config:   agentCfg,
+		}
+
+		wg.Add(1)
+		go func(i int, agent string, job LoadJob) {
+			defer wg.Done()
+			results[i], errs[i] = sendLoadJob(ctx, agent, job, token)
+			if errs[i] != nil {
+				emit(Event{Type: EventError, Phase: "coordinate", Index: -1, URL: agent, Err: errs[i]})
+			}
+		}(i, agent, job)
+	}
+	wg.Wait()
+
+	var result LoadResult
+	result.Latencies = NewLatencyHistogram()
+	var failed []string
+	for i, r := range results {
+		if errs[i] != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", agents[i], errs[i]))
+			continue
+		}
+		result.merge(r)
+	}
+
+	if cfg.Histogram != "" {
+		if err := result.Latencies.WriteHistogramFile(cfg.Histogram); err != nil {
+			return result, err
+		}
+	}
+	if cfg.Results != "" {
+		if err := result.WriteFile(cfg.Results); err != nil {
+			return result, err
+		}
+	}
+	if cfg.HTMLReport != "" {
+		if err := WriteAnalysisReportFile(cfg.HTMLReport, LoadReport("Distributed load test of "+reportTitle(har), result)); err != nil {
+			return result, err
+		}
+	}
+	if len(failed) > 0 {
+		return result, fmt.Errorf("%d of %d agents failed: %s", len(failed), len(agents), strings.Join(failed, "; "))
+	}
+	return result, ctx.Err()
+}
+
+// sendLoadJob runs job on the agent at baseURL and returns its result.
+func sendLoadJob(ctx context.Context, baseURL string, job LoadJob, token string) (LoadResult, error) {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return LoadResult{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+agentPath, bytes.NewReader(b))
+	if err != nil {
+		return LoadResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return LoadResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return LoadResult{}, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var result LoadResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return LoadResult{}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,191 @@
+package hargo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCoordinate(t *testing.T) {
+	var mu sync.Mutex
+	paths := make(map[string]int)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path]++
+		mu.Unlock()
+	}))
+	defer target.Close()
+
+	var agents []string
+	for i := 0; i < 2; i++ {
+		agent := httptest.NewServer(AgentHandler("secret"))
+		defer agent.Close()
+		agents = append(agents, agent.URL)
+	}
+
+	har := Har{Log: Log{Entries: []Entry{
+		{Request: Request{Method: "GET", URL: "https://example.com/a"}},
+		{Request: Request{Method: "GET", URL: "https://example.com/b"}},
+	}}}
+	cfg := Config{Rewrites: []Rewrite{{BaseURL: target.URL}}, Profile: LoadProfile{RPS: 40}}
+
+	result, err := Coordinate(context.Background(), agents, har, 2, time.Second, cfg, true, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Requests == 0 || result.Failures != 0 {
+		t.Fatalf("got %+v", result)
+	}
+	// requests cut off by the end of the test have no latency
+	if got := result.Latencies.Count(); got == 0 || got > uint64(result.Requests) {
+		t.Errorf("got %d latencies for %d requests", got, result.Requests)
+	}
+	// 40 requests per second in total, shared by the agents
+	if result.Requests > 60 {
+		t.Errorf("got %d requests, want at most 60", result.Requests)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if paths["/a"] == 0 || paths["/b"] == 0 {
+		t.Errorf("got requests %v, want both segments replayed", paths)
+	}
+}
+
+func TestCoordinateErrors(t *testing.T) {
+	agent := httptest.NewServer(AgentHandler("secret"))
+	defer agent.Close()
+
+	har := Har{Log: Log{Entries: []Entry{{Request: Request{Method: "GET", URL: "https://example.com/"}}}}}
+
+	tests := []struct {
+		name    string
+		agents  []string
+		workers int
+		segment bool
+		token   string
+		cfg     Config
+		want    string
+	}{
+		{"no agents", nil, 1, false, "secret", Config{}, "no agents"},
+		{"too few workers", []string{agent.URL, agent.URL}, 1, false, "secret", Config{}, "1 workers"},
+		{"too few entries", []string{agent.URL, agent.URL}, 2, true, "secret", Config{}, "1 entries"},
+		{"wrong token", []string{agent.URL}, 1, false, "wrong", Config{}, "401"},
+		{"invalid job", []string{agent.URL}, 1, false, "secret", Config{OnFailure: "retry"}, "422"},
+		{"certificate files", []string{agent.URL}, 1, false, "secret", Config{Transport: TransportOptions{RootCAs: []string{"ca.pem"}}}, "transport.rootCAs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Coordinate(context.Background(), tt.agents, har, tt.workers, time.Second, tt.cfg, tt.segment, tt.token)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAgentHandlerBusy(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { close(started) })
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer target.Close()
+	defer close(release)
+
+	agent := httptest.NewServer(AgentHandler("secret"))
+	defer agent.Close()
+
+	har, _ := json.Marshal(Har{Log: Log{Entries: []Entry{{Request: Request{Method: "GET", URL: target.URL}}}}})
+	job := LoadJob{Har: har, Workers: 1, Duration: 10}
+
+	// cancelling the first job disconnects from the agent, which ends it
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sendLoadJob(ctx, agent.URL, job, "secret")
+	<-started
+
+	if _, err := sendLoadJob(context.Background(), agent.URL, job, "secret"); err == nil || !strings.Contains(err.Error(), "409") {
+		t.Errorf("got error %v, want 409", err)
+	}
+}
+
+func TestAgentHandlerFiles(t *testing.T) {
+	har, _ := json.Marshal(Har{Log: Log{Entries: []Entry{{Request: Request{Method: "GET", URL: "https://example.com/"}}}}})
+
+	tests := []struct {
+		token string
+		cfg   Config
+		want  string
+	}{
+		// an agent without a token refuses every job
+		{"", Config{}, "401"},
+		{"secret", Config{Results: "/etc/passwd"}, "results"},
+		{"secret", Config{Histogram: "h.hgrm", HTMLReport: "r.html", Capture: "c.har"}, "capture, histogram, htmlReport"},
+		{"secret", Config{Transport: TransportOptions{ClientCert: "/root/cert.pem", ClientKey: "/root/key.pem"}}, "transport.clientCert, transport.clientKey"},
+	}
+	for i, test := range tests {
+		agent := httptest.NewServer(AgentHandler(test.token))
+		job := LoadJob{Har: har, Workers: 1, Duration: 1, Config: test.cfg}
+		_, err := sendLoadJob(context.Background(), agent.URL, job, test.token)
+		agent.Close()
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%d: got error %v, want %q", i, err, test.want)
+		}
+	}
+
+	if err := ServeAgent(context.Background(), "localhost:0", ""); err == nil {
+		t.Error("expected an error for an agent without a token")
+	}
+}
+
+func TestLatencyHistogramJSON(t *testing.T) {
+	h := NewLatencyHistogram()
+	for _, ms := range []int{1, 2, 3, 100} {
+		h.Record(time.Duration(ms) * time.Millisecond)
+	}
+
+	b, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := NewLatencyHistogram()
+	if err := json.Unmarshal(b, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Summary() != h.Summary() {
+		t.Errorf("got %q, want %q", decoded.Summary(), h.Summary())
+	}
+
+	merged := NewLatencyHistogram()
+	merged.Record(500 * time.Millisecond)
+	merged.Merge(decoded)
+	if merged.Count() != 5 || merged.Min() != time.Millisecond || merged.Max() != 500*time.Millisecond {
+		t.Errorf("got count %d, min %v, max %v", merged.Count(), merged.Min(), merged.Max())
+	}
+
+	if err := json.Unmarshal([]byte(`{"counts":{"-1":1}}`), decoded); err == nil {
+		t.Error("expected an error for an invalid index")
+	}
+}
+
+func TestLoadProfileScaled(t *testing.T) {
+	p := LoadProfile{RPS: 100, SpikeRPS: 300, Stages: []LoadStage{{Duration: "1m", RPS: 50}}}
+	scaled := p.scaled(0.5)
+	if scaled.RPS != 50 || scaled.SpikeRPS != 150 || scaled.Stages[0].RPS != 25 || scaled.Stages[0].Duration != "1m" {
+		t.Errorf("got %+v", scaled)
+	}
+	if p.Stages[0].RPS != 50 {
+		t.Errorf("scaled modified the original profile")
+	}
+}
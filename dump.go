@@ -2,8 +2,6 @@ package hargo
 
 import (
 	"bufio"
-	"encoding/json"
-
 	"fmt"
 
 	log "github.com/sirupsen/logrus"
@@ -13,9 +11,7 @@ import (
 func Dump(r *bufio.Reader) {
 	//_, err := Validate(r)
 
-	dec := json.NewDecoder(r)
-	var har Har
-	err := dec.Decode(&har)
+	har, err := readHar(r)
 
 	if err != nil {
 		log.Error(err)
@@ -0,0 +1,192 @@
+package hargo
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// EndpointStats summarizes the requests of one endpoint, a method and a
+// URLTemplate, so /users/42 and /users/43 count as the same endpoint.
+// Wait times are the server's time to first byte in milliseconds, of the
+// requests that recorded one.
+type EndpointStats struct {
+	Method   string `json:"method"`
+	Endpoint string `json:"endpoint"`
+	Requests int    `json:"requests"`
+	// Errors counts responses with status 400 or above, or without status
+	// as for aborted requests.
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"errorRate"`
+	MeanWait  float64 `json:"meanWait"`
+	P95Wait   float64 `json:"p95Wait"`
+	MaxWait   float64 `json:"maxWait"`
+	// MeanTime is the mean total time of the requests.
+	MeanTime float64 `json:"meanTime"`
+}
+
+// EndpointOptions controls AnalyzeEndpoints.
+type EndpointOptions struct {
+	// Sort orders the endpoints: "p95" (the default), "mean", "requests"
+	// or "errors", highest first.
+	Sort string
+	// Top limits the report to that many endpoints, all if zero.
+	Top int
+	// MinRequests leaves out endpoints with fewer requests.
+	MinRequests int
+	// APIOnly only counts XHR and fetch requests, or where the resource
+	// type is not recorded, JSON, XML, gRPC and GraphQL responses.
+	APIOnly bool
+	// Selection limits the report to the selected entries.
+	Selection Selection
+}
+
+// AnalyzeEndpoints groups the entries of har by endpoint and returns the
+// request count, error rate and wait times of each, sorted by opts.Sort.
+func AnalyzeEndpoints(har Har, opts EndpointOptions) ([]EndpointStats, error) {
+	var less func(a, b EndpointStats) bool
+	switch opts.Sort {
+	case "", "p95":
+		less = func(a, b EndpointStats) bool { return a.P95Wait > b.P95Wait }
+	case "mean":
+		less = func(a, b EndpointStats) bool { return a.MeanWait > b.MeanWait }
+	case "requests":
+		less = func(a, b EndpointStats) bool { return a.Requests > b.Requests }
+	case "errors":
+		less = func(a, b EndpointStats) bool {
+			if a.ErrorRate != b.ErrorRate {
+				return a.ErrorRate > b.ErrorRate
+			}
+			return a.Errors > b.Errors
+		}
+	default:
+		return nil, fmt.Errorf("unknown sort order %s", opts.Sort)
+	}
+
+	type endpoint struct {
+		stats EndpointStats
+		waits []time.Duration
+		time  float64
+	}
+	var endpoints []*endpoint
+	byKey := make(map[string]*endpoint)
+	for i, entry := range har.Log.Entries {
+		if !opts.Selection.Includes(i, entry) || opts.APIOnly && !isAPIRequest(entry) {
+			continue
+		}
+		method := strings.ToUpper(entry.Request.Method)
+		template := URLTemplate(entry.Request.URL)
+		e := byKey[method+" "+template]
+		if e == nil {
+			e = &endpoint{stats: EndpointStats{Method: method, Endpoint: template}}
+			endpoints = append(endpoints, e)
+			byKey[method+" "+template] = e
+		}
+		e.stats.Requests++
+		if status := entry.Response.Status; status == 0 || status >= 400 {
+			e.stats.Errors++
+		}
+		if wait := entry.Timings.Wait; wait >= 0 {
+			e.waits = append(e.waits, time.Duration(wait*float64(time.Millisecond)))
+		}
+		e.time += positive(float64(entry.Time))
+	}
+
+	result := make([]EndpointStats, 0, len(endpoints))
+	for _, e := range endpoints {
+		s := e.stats
+		if s.Requests < opts.MinRequests {
+			continue
+		}
+		s.ErrorRate = float64(s.Errors) / float64(s.Requests)
+		s.MeanTime = e.time / float64(s.Requests)
+		if len(e.waits) > 0 {
+			sort.Slice(e.waits, func(i, j int) bool { return e.waits[i] < e.waits[j] })
+			var total time.Duration
+			for _, wait := range e.waits {
+				total += wait
+			}
+			s.MeanWait = milliseconds(total) / float64(len(e.waits))
+			s.P95Wait = milliseconds(sortedPercentile(e.waits, 95))
+			s.MaxWait = milliseconds(e.waits[len(e.waits)-1])
+		}
+		result = append(result, s)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return less(result[i], result[j]) })
+	if opts.Top > 0 && len(result) > opts.Top {
+		result = result[:opts.Top]
+	}
+	return result, nil
+}
+
+// isAPIRequest reports whether an entry is a call of a web API rather than
+// a page or one of its resources.
+func isAPIRequest(e Entry) bool {
+	switch e.ResourceType {
+	case "xhr", "fetch":
+		return true
+	case "":
+	default:
+		return false
+	}
+	mimeType := strings.ToLower(e.Response.Content.MimeType)
+	for _, api := range []string{"json", "xml", "grpc", "graphql", "protobuf"} {
+		if strings.Contains(mimeType, api) {
+			return true
+		}
+	}
+	return false
+}
+
+// Endpoints writes the endpoints of a .har file, slowest first, to w as a
+// table, or with format "csv" or "json".
+func Endpoints(r *bufio.Reader, w io.Writer, format string, opts EndpointOptions) error {
+	har, err := readHar(r)
+	if err != nil {
+		return err
+	}
+	endpoints, err := AnalyzeEndpoints(har, opts)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "", "text":
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(endpoints)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"method", "endpoint", "requests", "errors", "error_rate", "mean_wait_ms", "p95_wait_ms", "max_wait_ms", "mean_time_ms"})
+		for _, e := range endpoints {
+			cw.Write([]string{e.Method, e.Endpoint, strconv.Itoa(e.Requests), strconv.Itoa(e.Errors),
+				strconv.FormatFloat(e.ErrorRate, 'f', 3, 64), strconv.FormatFloat(e.MeanWait, 'f', 3, 64),
+				strconv.FormatFloat(e.P95Wait, 'f', 3, 64), strconv.FormatFloat(e.MaxWait, 'f', 3, 64),
+				strconv.FormatFloat(e.MeanTime, 'f', 3, 64)})
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unknown format %s", format)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", "Method", "Endpoint", "Requests", "Errors", "Mean wait (ms)", "p95 wait (ms)", "Max wait (ms)", "Mean time (ms)")
+	for _, e := range endpoints {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d (%s)\t%s\t%s\t%s\t%s\t\n", e.Method, e.Endpoint, e.Requests, e.Errors, percentOf(float64(e.Errors), float64(e.Requests)),
+			formatTiming(e.MeanWait), formatTiming(e.P95Wait), formatTiming(e.MaxWait), formatTiming(e.MeanTime))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%d endpoints\n", len(endpoints))
+	return err
+}
@@ -0,0 +1,83 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeEndpoints(t *testing.T) {
+	har := readTestHar(t, "endpoints.har")
+	tests := []struct {
+		opts     EndpointOptions
+		expected []EndpointStats
+	}{
+		{EndpointOptions{APIOnly: true}, []EndpointStats{
+			{Method: "GET", Endpoint: "api.example.com/search?q", Requests: 2, Errors: 1, ErrorRate: 0.5, MeanWait: 1000, P95Wait: 1000, MaxWait: 1000, MeanTime: 509.5},
+			{Method: "GET", Endpoint: "api.example.com/users/{}", Requests: 4, Errors: 1, ErrorRate: 0.25, MeanWait: 250, P95Wait: 400, MaxWait: 400, MeanTime: 260},
+			{Method: "DELETE", Endpoint: "api.example.com/users/{}", Requests: 1, Errors: 1, ErrorRate: 1, MeanWait: 20, P95Wait: 20, MaxWait: 20, MeanTime: 30},
+		}},
+		{EndpointOptions{Sort: "requests", Top: 2}, []EndpointStats{
+			{Method: "GET", Endpoint: "api.example.com/users/{}", Requests: 4, Errors: 1, ErrorRate: 0.25, MeanWait: 250, P95Wait: 400, MaxWait: 400, MeanTime: 260},
+			{Method: "GET", Endpoint: "api.example.com/search?q", Requests: 2, Errors: 1, ErrorRate: 0.5, MeanWait: 1000, P95Wait: 1000, MaxWait: 1000, MeanTime: 509.5},
+		}},
+		{EndpointOptions{Sort: "errors", MinRequests: 2, Selection: Selection{Last: 4}}, []EndpointStats{
+			{Method: "GET", Endpoint: "api.example.com/users/{}", Requests: 3, Errors: 1, ErrorRate: 1.0 / 3, MeanWait: 200, P95Wait: 300, MaxWait: 300, MeanTime: 210},
+		}},
+	}
+	for i, test := range tests {
+		got, err := AnalyzeEndpoints(har, test.opts)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if len(got) != len(test.expected) {
+			t.Fatalf("%d: got %+v", i, got)
+		}
+		for j := range got {
+			if got[j] != test.expected[j] {
+				t.Errorf("%d: got %+v, want %+v", i, got[j], test.expected[j])
+			}
+		}
+	}
+
+	if _, err := AnalyzeEndpoints(har, EndpointOptions{Sort: "name"}); err == nil {
+		t.Error("expected an error for an unknown sort order")
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	harData, err := os.ReadFile("test/endpoints.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(format string) string {
+		t.Helper()
+		var out bytes.Buffer
+		if err := Endpoints(bufio.NewReader(bytes.NewReader(harData)), &out, format, EndpointOptions{}); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		return out.String()
+	}
+
+	rows := tableRows(run("text"))
+	if expected := []string{"GET", "api.example.com/users/{}", "4", "1 (25%)", "250", "400", "400", "260"}; len(rows) != 6 || !reflect.DeepEqual(rows[2], expected) {
+		t.Errorf("got %q", rows)
+	} else if !reflect.DeepEqual(rows[5], []string{"4 endpoints"}) {
+		t.Errorf("got summary %q", rows[5])
+	}
+
+	records, err := csv.NewReader(strings.NewReader(run("csv"))).ReadAll()
+	if err != nil || len(records) != 5 || strings.Join(records[2], ",") != "GET,api.example.com/users/{},4,1,0.250,250.000,400.000,400.000,260.000" {
+		t.Errorf("got %v, %v", records, err)
+	}
+
+	var endpoints []EndpointStats
+	if err := json.Unmarshal([]byte(run("json")), &endpoints); err != nil || len(endpoints) != 4 {
+		t.Errorf("got %+v, %v", endpoints, err)
+	}
+}
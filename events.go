@@ -0,0 +1,119 @@
+package hargo
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies the kind of an Event.
+type EventType string
+
+// Event types emitted by long-running operations.
+const (
+	// EventPhaseStarted is emitted when an operation (Phase) begins.
+	EventPhaseStarted EventType = "phaseStarted"
+	// EventPhaseFinished is emitted when an operation (Phase) ends.
+	EventPhaseFinished EventType = "phaseFinished"
+	// EventEntryExtracted is emitted for every file written by Extract.
+	EventEntryExtracted EventType = "entryExtracted"
+	// EventEntryFetched is emitted for every resource downloaded by Fetch.
+	EventEntryFetched EventType = "entryFetched"
+	// EventRequestReplayed is emitted for every request sent by Replay,
+	// LoadTest and Daemon.
+	EventRequestReplayed EventType = "requestReplayed"
+	// EventAssertionFailed is emitted when a replayed response does not
+	// match the recording.
+	EventAssertionFailed EventType = "assertionFailed"
+	// EventError is emitted when an entry cannot be processed.
+	EventError EventType = "error"
+)
+
+// Event describes progress of a long-running operation. Fields that do not
+// apply to an event type are left at their zero value.
+type Event struct {
+	Type EventType
+	Time time.Time
+	// Phase names the operation emitting the event, e.g. "extract".
+	Phase string
+	// Index is the position of the entry in the .har file, or -1.
+	Index   int
+	URL     string
+	Method  string
+	Status  int
+	Path    string
+	Bytes   int64
+	Latency time.Duration
+	Message string
+	Err     error
+}
+
+// EventHandler receives events. Handlers are called synchronously from the
+// goroutine performing the work and must not block for long.
+type EventHandler func(Event)
+
+var (
+	eventMu       sync.RWMutex
+	eventHandlers = make(map[int]EventHandler)
+	eventNextID   int
+)
+
+// Subscribe registers a handler for all events emitted by the package and
+// returns a function that removes it again.
+func Subscribe(handler EventHandler) (unsubscribe func()) {
+	eventMu.Lock()
+	id := eventNextID
+	eventNextID++
+	eventHandlers[id] = handler
+	eventMu.Unlock()
+
+	return func() {
+		eventMu.Lock()
+		delete(eventHandlers, id)
+		eventMu.Unlock()
+	}
+}
+
+// SubscribeChan returns a channel receiving all events emitted by the
+// package. Operations block while the channel's buffer is full, so it must
+// be drained. The returned function unsubscribes and closes the channel.
+func SubscribeChan(buffer int) (<-chan Event, func()) {
+	events := make(chan Event, buffer)
+	done := make(chan struct{})
+
+	unsubscribe := Subscribe(func(e Event) {
+		select {
+		case events <- e:
+		case <-done:
+		}
+	})
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			close(done)
+			unsubscribe()
+			close(events)
+		})
+	}
+}
+
+// emit delivers an event to all subscribers.
+func emit(e Event) {
+	eventMu.RLock()
+	defer eventMu.RUnlock()
+
+	if len(eventHandlers) == 0 {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	for _, handler := range eventHandlers {
+		handler(e)
+	}
+}
+
+// emitPhase emits a phase started or finished event.
+func emitPhase(t EventType, phase string) {
+	emit(Event{Type: t, Phase: phase, Index: -1})
+}
@@ -2,15 +2,20 @@ package hargo
 
 import (
 	"bufio"
-	"encoding/base64"
+	"bytes"
+	"context"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -25,6 +30,230 @@ type ManifestEntry struct {
 	Size          int    `json:"size"`
 	Method        string `json:"method"`
 	Status        int    `json:"status"`
+	// Sanitized is set when the file name had to be changed from the URL
+	// to be safe, valid on all platforms or unique.
+	Sanitized bool `json:"sanitized"`
+	// SHA256 is the hex SHA-256 of the file, used to resume extractions.
+	SHA256 string `json:"sha256"`
+}
+
+// ExtractOptions controls how ExtractWithOptions organizes the extracted
+// content and which additional artifacts it writes.
+type ExtractOptions struct {
+	// SortByType groups files by content type (images/, json/, etc.) instead
+	// of preserving the original domain structure from URLs.
+	SortByType bool
+	// GroupByPage first groups files by the page of the HAR pages array
+	// they were loaded for, one directory per page named after its
+	// position and title, and organizes each by domain or type inside.
+	// Entries of no page go to no-page/.
+	GroupByPage bool
+	// WebSocketMessages writes the recorded messages of every WebSocket
+	// connection to a JSONL transcript in websockets/.
+	WebSocketMessages bool
+	// SplitEvents additionally writes each event of a Server-Sent Events
+	// stream to its own file in a directory next to the extracted stream.
+	SplitEvents bool
+	// DirMode and FileMode are the permissions of created directories and
+	// files, 0777 and 0644 if zero. Like any file creation they are
+	// restricted by the process umask.
+	DirMode  os.FileMode
+	FileMode os.FileMode
+	// IgnoreUmask sets DirMode and FileMode exactly, regardless of the umask.
+	IgnoreUmask bool
+	// QueryFilenames adds the query string of a URL to its file name, so
+	// /api/items?id=1 and ?id=2 do not overwrite each other: QueryHash adds
+	// a short hash of the query, QuerySanitized the sanitized query itself.
+	// Empty ignores query strings.
+	QueryFilenames string
+	// ModTime sets the modification time of extracted files to the time
+	// the entry was captured (ModTimeStarted) or to its Last-Modified
+	// header, falling back to the capture time (ModTimeLastModified).
+	// Empty leaves the time of extraction.
+	ModTime string
+	// Archive, if set, receives the extracted files and the manifest as a
+	// single ArchiveFormat (ArchiveZip or ArchiveTarGz) stream instead of
+	// a directory tree. Paths in the archive start with the name of the
+	// directory Extract would have created.
+	Archive       io.Writer
+	ArchiveFormat string
+	// SourceMaps writes the source map of every script that refers to one,
+	// recorded in the .har file or inline as a data: URI, next to the
+	// script as <script>.map. SourceMapSources additionally writes the
+	// original sources of the maps to sources/.
+	SourceMaps       bool
+	SourceMapSources bool
+	// MultipartParts writes each field and file of multipart/form-data
+	// request bodies to its own file in uploads/, with a parts.json
+	// manifest per request, so uploaded files can be recovered.
+	MultipartParts bool
+	// PrettyJSON indents JSON response bodies, and SortJSONKeys
+	// additionally sorts the keys of their objects, so the extracted trees
+	// of two captures of the same flow can be diffed. Bodies that are not
+	// valid JSON are written unchanged.
+	PrettyJSON   bool
+	SortJSONKeys bool
+	// Decoders writes the bodies they decode next to the extracted
+	// response, as <file>.<decoder name>.json if the result is JSON and
+	// .txt otherwise.
+	Decoders *BodyDecoders
+	// GRPC adds the DefaultBodyDecoders to Decoders, so the messages of
+	// gRPC and gRPC-Web calls are written to <file>.grpc.json, decoded with
+	// the message types of ProtoDescriptors if set and without schema
+	// otherwise.
+	GRPC             bool
+	ProtoDescriptors *ProtoRegistry
+	// SniffTypes chooses type directories, default file names and whether
+	// PrettyJSON applies by the media type sniffed from the body where it
+	// disagrees with the declared one, see SniffContentType. The manifest
+	// keeps the declared type.
+	SniffTypes bool
+	// MinSize and MaxSize skip response bodies smaller or larger than
+	// that many bytes, once decoded from base64. Zero is no limit.
+	MinSize ByteSize
+	MaxSize ByteSize
+	// OutputDir is the directory to extract to, created if needed, instead
+	// of a new timestamped one in the working directory.
+	OutputDir string
+	// Resume continues an interrupted extraction in this existing output
+	// directory instead of creating a new one. Files that are already
+	// there with the content they would get, by the hashes of its manifest
+	// or of the files themselves, are not written again. The options must
+	// be those of the interrupted extraction for files to get the same
+	// paths.
+	Resume string
+	// Progress, if set, is called after every processed entry.
+	Progress ProgressFunc
+	// Output receives a human readable line for every extracted file, nil
+	// discards them. Errors are logged with logrus either way.
+	Output io.Writer
+}
+
+// ExtractResult describes what ExtractWithResult did.
+type ExtractResult struct {
+	// Entries is the number of entries in the .har file.
+	Entries int
+	// Written is the number of files written, not counting the manifest.
+	Written int
+	// Kept is the number of files of a resumed extraction that already
+	// existed with the same content and were not written again. They are
+	// counted in Written.
+	Kept int
+	// Skipped lists the entries that were not extracted and why.
+	Skipped []SkipReason
+	// Manifest describes every file written, as in extraction_manifest.csv.
+	Manifest []ManifestEntry
+	// OutputDir is the directory the files were written to, or their
+	// common prefix inside an archive.
+	OutputDir string
+}
+
+// SkipReason explains why an entry was not extracted.
+type SkipReason struct {
+	Index  int
+	URL    string
+	Reason string
+	// Err is the underlying error, nil for entries skipped on purpose,
+	// e.g. because they have no response content.
+	Err error
+}
+
+// Sources of the modification time of extracted files.
+const (
+	ModTimeStarted      = "started"
+	ModTimeLastModified = "last-modified"
+)
+
+// Ways to add query strings to extracted file names.
+const (
+	QueryHash      = "hash"
+	QuerySanitized = "sanitized"
+)
+
+// dirMode returns the permissions of extracted directories.
+func (o ExtractOptions) dirMode() os.FileMode {
+	if o.DirMode == 0 {
+		return 0777
+	}
+	return o.DirMode
+}
+
+// fileMode returns the permissions of extracted files.
+func (o ExtractOptions) fileMode() os.FileMode {
+	if o.FileMode == 0 {
+		return 0644
+	}
+	return o.FileMode
+}
+
+// mkdir creates a directory with the configured permissions.
+func (o ExtractOptions) mkdir(dir string) error {
+	if err := os.Mkdir(dir, o.dirMode()); err != nil {
+		return err
+	}
+	if o.IgnoreUmask {
+		return os.Chmod(dir, o.dirMode())
+	}
+	return nil
+}
+
+// mkdirAll creates a directory and any missing parents with the configured
+// permissions.
+func (o ExtractOptions) mkdirAll(dir string) error {
+	if info, err := os.Stat(dir); err == nil {
+		if !info.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: dir, Err: syscall.ENOTDIR}
+		}
+		return nil
+	}
+	if parent := filepath.Dir(dir); parent != dir {
+		if err := o.mkdirAll(parent); err != nil {
+			return err
+		}
+	}
+	if err := o.mkdir(dir); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return nil
+}
+
+// modTime returns the modification time of files extracted from entry as
+// configured by ModTime, or the zero time to leave the time of extraction.
+func (o ExtractOptions) modTime(entry Entry) (time.Time, error) {
+	var t time.Time
+	var err error
+	switch o.ModTime {
+	case ModTimeLastModified:
+		t, err = http.ParseTime(recordedHeader(entry.Response.Headers, "Last-Modified"))
+		if err == nil {
+			break
+		}
+		fallthrough
+	case ModTimeStarted:
+		t, err = parseStartedDateTime(entry.StartedDateTime)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t, nil
+}
+
+// writeFile writes data to a file with the configured permissions.
+func (o ExtractOptions) writeFile(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, o.fileMode())
+	if err != nil {
+		return err
+	}
+	if o.IgnoreUmask {
+		err = file.Chmod(o.fileMode())
+	}
+	if err == nil {
+		_, err = file.Write(data)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
 }
 
 // Extract extracts response content from .har file to filesystem.
@@ -32,65 +261,295 @@ type ManifestEntry struct {
 // sortByType=true groups files by content type (images/, json/, etc.),
 // sortByType=false preserves original domain structure from URLs.
 // Returns error if HAR parsing fails or file system operations fail.
+// Progress is printed to stdout.
 func Extract(r *bufio.Reader, sortByType bool) error {
+	return ExtractWithOptions(r, ExtractOptions{SortByType: sortByType, Output: os.Stdout})
+}
+
+// ExtractWithOptions extracts response content from .har file to filesystem
+// as configured by opts. Unlike Extract it prints nothing unless
+// opts.Output is set.
+func ExtractWithOptions(r *bufio.Reader, opts ExtractOptions) error {
+	_, err := ExtractWithResult(r, opts)
+	return err
+}
+
+// ExtractWithResult is like ExtractWithOptions, but also returns what was
+// extracted and skipped.
+func ExtractWithResult(r *bufio.Reader, opts ExtractOptions) (ExtractResult, error) {
+	return ExtractContext(context.Background(), r, opts)
+}
+
+// ExtractContext is like ExtractWithResult, but stops when ctx is done. The
+// files extracted until then are still listed in the manifest, and the
+// error is ctx.Err().
+func ExtractContext(ctx context.Context, r *bufio.Reader, opts ExtractOptions) (ExtractResult, error) {
+	var result ExtractResult
 	har, err := Decode(r)
 	if err != nil {
-		return err
+		return result, err
 	}
+	result.Entries = len(har.Log.Entries)
 
 	// Create timestamped output directory to avoid conflicts with previous extractions
 	datestring := time.Now().Format("20060102150405")
 	outdir := "." + string(filepath.Separator) + "hargo-extract-" + datestring
+	if opts.OutputDir != "" {
+		outdir = opts.OutputDir
+	}
+	if opts.Resume != "" {
+		outdir = opts.Resume
+	}
 
-	err = os.Mkdir(outdir, 0777)
+	// Write to the file system or to an archive, hashing every file
+	result.OutputDir = outdir
+	extractTarget, err := newExtractTarget(opts)
 	if err != nil {
-		return err
+		return result, err
 	}
+	target := newHashingTarget(extractTarget)
+	switch {
+	case opts.Resume != "" && opts.Archive != nil:
+		return result, fmt.Errorf("cannot resume an extraction to an archive")
+	case opts.Resume != "":
+		if info, err := os.Stat(outdir); err != nil || !info.IsDir() {
+			return result, fmt.Errorf("cannot resume extraction: %s is not a directory", outdir)
+		}
+		target.resume = true
+		target.previous, err = readManifestHashes(filepath.Join(outdir, "extraction_manifest.csv"))
+		if err != nil {
+			// interrupted before the manifest was written, so every
+			// existing file is hashed instead
+			log.Warnf("Resuming without manifest: %v", err)
+		}
+	case opts.Archive == nil && opts.OutputDir != "":
+		if err = opts.mkdirAll(outdir); err != nil {
+			return result, err
+		}
+	case opts.Archive == nil:
+		err = opts.mkdir(outdir)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	emitPhase(EventPhaseStarted, "extract")
+	defer emitPhase(EventPhaseFinished, "extract")
 
-	fmt.Printf("Extracting HAR content to: %s\n", outdir)
-	if sortByType {
-		fmt.Println("Organizing files by content type...")
+	out := opts.Output
+	if out == nil {
+		out = io.Discard
+	}
+	if opts.Archive != nil {
+		fmt.Fprintf(out, "Extracting HAR content to %s archive: %s\n", opts.ArchiveFormat, outdir)
+	} else if opts.Resume != "" {
+		fmt.Fprintf(out, "Resuming extraction to: %s\n", outdir)
+	} else {
+		fmt.Fprintf(out, "Extracting HAR content to: %s\n", outdir)
+	}
+	if opts.SortByType {
+		fmt.Fprintln(out, "Organizing files by content type...")
 	} else {
-		fmt.Println("Organizing files by domain...")
+		fmt.Fprintln(out, "Organizing files by domain...")
+	}
+	if opts.GroupByPage {
+		fmt.Fprintln(out, "Grouping files by page...")
 	}
 
 	// Track filenames to avoid collisions when multiple entries have same name.
 	// filenameCount maps filename -> occurrence count for collision handling.
 	// manifest accumulates metadata for all successfully extracted files.
-	filenameCount := make(map[string]int)
+	filenameCounts := make(map[string]map[string]int)
 	var manifest []ManifestEntry
 
-	// Process each HAR entry, extracting response content if present
+	// paths makes sure no file overwrites another, in either mode
+	manifestPath := filepath.Join(outdir, "extraction_manifest.csv")
+	paths := newPathAllocator()
+	paths.allocate(manifestPath)
+
+	// progress counts the files in manifest as they are added
+	start := time.Now()
+	progress := Progress{Total: len(har.Log.Entries)}
+	reportProgress := func(entries int) {
+		for _, m := range manifest[progress.Files:] {
+			progress.Bytes += int64(m.Size)
+		}
+		progress.Entries, progress.Files, progress.Elapsed = entries, len(manifest), time.Since(start)
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+	extractError := func(e Event) {
+		progress.Errors++
+		emit(e)
+	}
+	// skip records why entry e.Index was not extracted, and the error if any
+	skip := func(e Event, reason string) {
+		result.Skipped = append(result.Skipped, SkipReason{Index: e.Index, URL: e.URL, Reason: reason, Err: e.Err})
+		if e.Err != nil {
+			extractError(e)
+		}
+	}
+
+	// Source maps referred to by scripts are written next to them instead
+	// of at their own URL
+	var sourceMaps sourceMapIndex
+	var scriptMaps map[string]bool
+	if opts.SourceMaps || opts.SourceMapSources {
+		sourceMaps = newSourceMapIndex(har)
+		scriptMaps = referencedSourceMaps(har, sourceMaps)
+	}
+
+	decoders := opts.Decoders
+	if opts.GRPC {
+		decoders = DefaultBodyDecoders(opts.ProtoDescriptors).merge(decoders)
+	}
+
+	var pageDirs map[string]string
+	if opts.GroupByPage {
+		pageDirs = pageDirectories(har.Log.Pages)
+	}
+
+	// Process each HAR entry, extracting response content if present.
+	// Progress is reported at the start of the next entry, since entries
+	// can be skipped at any point.
+	var cancelled error
 	for i, entry := range har.Log.Entries {
+		if i > 0 {
+			reportProgress(i)
+		}
+		if cancelled = ctx.Err(); cancelled != nil {
+			log.Warnf("Extraction cancelled after %d of %d entries", i, len(har.Log.Entries))
+			break
+		}
+
+		modTime, err := opts.modTime(entry)
+		if err != nil {
+			log.Warnf("Failed to determine modification time of %s: %v", entry.Request.URL, err)
+		}
+
+		// base is the directory of the entry's page when grouping by page,
+		// and file names only need to be unique within it
+		base := outdir
+		if pageDirs != nil {
+			pageDir, ok := pageDirs[entry.Pageref]
+			if !ok {
+				pageDir = "no-page"
+			}
+			base = filepath.Join(outdir, pageDir)
+		}
+		filenameCount := filenameCounts[base]
+		if filenameCount == nil {
+			filenameCount = make(map[string]int)
+			filenameCounts[base] = filenameCount
+		}
+
+		if opts.WebSocketMessages && len(entry.WebSocketMessages) > 0 {
+			transcript, err := writeWebSocketTranscript(base, i, entry, target, modTime)
+			if err != nil {
+				log.Errorf("Failed to write WebSocket transcript for %s: %v", entry.Request.URL, err)
+				extractError(Event{Type: EventError, Phase: "extract", Index: i, URL: entry.Request.URL, Err: err})
+			} else {
+				manifest = append(manifest, transcript)
+				fmt.Fprintf(out, "Extracted %d WebSocket messages of %s -> %s\n",
+					len(entry.WebSocketMessages), entry.Request.URL, transcript.ExtractedPath)
+			}
+		}
+
+		if opts.MultipartParts && entry.Request.IsMultipart() {
+			parts, err := writeMultipartParts(base, i, entry, target, paths, modTime)
+			manifest = append(manifest, parts...)
+			if err != nil {
+				log.Errorf("Failed to extract multipart body of %s: %v", entry.Request.URL, err)
+				extractError(Event{Type: EventError, Phase: "extract", Index: i, URL: entry.Request.URL, Err: err})
+			} else if len(parts) > 0 {
+				fmt.Fprintf(out, "Extracted %d parts of %s request body -> %s\n",
+					len(parts), entry.Request.URL, filepath.Dir(parts[0].ExtractedPath))
+			}
+		}
+
 		if entry.Response.Content.Text == "" {
 			log.Debugf("Skipping entry %d: no response content", i)
+			skip(Event{Index: i, URL: entry.Request.URL}, "no response content")
+			continue
+		}
+
+		if scriptMaps[resolveReference(entry.Request.URL, "")] {
+			log.Debugf("Skipping entry %d: source map extracted next to its script", i)
+			skip(Event{Index: i, URL: entry.Request.URL}, "source map extracted next to its script")
+			continue
+		}
+
+		// Decode response content. HAR format stores binary content as
+		// base64, text content as plain text, either possibly compressed.
+		decodedContent, err := entry.DecodedBody()
+		if err != nil {
+			log.Errorf("Failed to decode content for %s: %v", entry.Request.URL, err)
+			skip(Event{Type: EventError, Phase: "extract", Index: i, URL: entry.Request.URL, Err: err}, "invalid content")
+			continue
+		}
+
+		// Skip bodies outside the size limits, e.g. tracking pixels or videos
+		if size := ByteSize(len(decodedContent)); opts.MinSize > 0 && size < opts.MinSize {
+			log.Debugf("Skipping entry %d: %s is smaller than %s", i, size, opts.MinSize)
+			skip(Event{Index: i, URL: entry.Request.URL}, "smaller than minimum size")
+			continue
+		} else if opts.MaxSize > 0 && size > opts.MaxSize {
+			log.Debugf("Skipping entry %d: %s is larger than %s", i, size, opts.MaxSize)
+			skip(Event{Index: i, URL: entry.Request.URL}, "larger than maximum size")
 			continue
 		}
 
 		parsedURL, err := url.Parse(entry.Request.URL)
 		if err != nil {
 			log.Errorf("Failed to parse URL %s: %v", entry.Request.URL, err)
+			skip(Event{Type: EventError, Phase: "extract", Index: i, URL: entry.Request.URL, Err: err}, "invalid URL")
 			continue
 		}
 
 		var fullPath string
 		var filename string
+		// sanitized records whether the file name differs from the URL
+		var sanitized bool
+
+		mimeType := entry.Response.Content.MimeType
+		if opts.SniffTypes {
+			if sniffed := sniffedMimeType(entry); sniffed != "" {
+				log.Debugf("%s is declared as %s but looks like %s", entry.Request.URL, mimeType, sniffed)
+				mimeType = sniffed
+			}
+		}
 
-		if sortByType {
+		if opts.SortByType {
 			// Organize files into type-based directories (images/, json/, css/, etc.)
 			// This mode groups similar content together for easier browsing
-			typeDir := getTypeDirectory(entry.Response.Content.MimeType)
-			fullTypeDir := filepath.Join(outdir, typeDir)
-			err = os.MkdirAll(fullTypeDir, 0777)
+			typeDir := getTypeDirectory(mimeType)
+			if entry.IsEventStream() {
+				typeDir = "events"
+			}
+			fullTypeDir := filepath.Join(base, typeDir)
+			err = target.mkdirAll(fullTypeDir)
 			if err != nil {
 				log.Errorf("Failed to create type directory %s: %v", fullTypeDir, err)
+				skip(Event{Type: EventError, Phase: "extract", Index: i, URL: entry.Request.URL, Err: err}, "cannot create directory")
 				continue
 			}
 
 			// Smart filename generation extracts meaningful names from URLs
 			// and handles collisions by appending sequence numbers
-			filename = generateSmartFilename(parsedURL, entry.Response.Content.MimeType, filenameCount)
-			fullPath = filepath.Join(fullTypeDir, filename)
+			filename = generateSmartFilename(parsedURL, mimeType, filenameCount)
+			safeName := sanitizeFilename(filename)
+			sanitized = safeName != filename
+			safeName = withQuery(safeName, parsedURL.RawQuery, opts.QueryFilenames)
+			// GraphQL responses are named after their operation instead
+			// of the shared endpoint
+			if ops := GraphQLOperations(entry); len(ops) > 0 {
+				safeName = graphQLFilename(ops, mimeType)
+			}
+			fullPath = filepath.Join(fullTypeDir, safeName)
+			if allocated := paths.allocate(fullPath); allocated != fullPath {
+				fullPath, sanitized = allocated, true
+			}
 		} else {
 			// Preserve original domain structure from URLs to maintain site organization.
 			// This mode recreates the website's directory structure locally.
@@ -99,42 +558,60 @@ func Extract(r *bufio.Reader, sortByType bool) error {
 				domain = "unknown"
 			}
 
-			domainDir := filepath.Join(outdir, domain)
-			err = os.MkdirAll(domainDir, 0777)
+			// URL paths may contain dot segments, encoded separators and
+			// characters that are invalid on some platforms, so every
+			// segment is sanitized and the result must stay inside outdir
+			filename = determineFilename(parsedURL, mimeType)
+			urlPath, safePath := strings.Trim(parsedURL.Path, "/"), sanitizeURLPath(parsedURL.Path)
+			if urlPath == "" {
+				urlPath = filename
+			}
+			if safePath == "" {
+				safePath = sanitizeFilename(filename)
+			}
+			safeDomain := sanitizeFilename(domain)
+			sanitized = safeDomain != domain || filepath.ToSlash(safePath) != urlPath
+			safePath = withQuery(safePath, parsedURL.RawQuery, opts.QueryFilenames)
+			// GraphQL responses go below the endpoint, named after their
+			// operation, e.g. example.com/graphql/GetUser.json
+			if ops := GraphQLOperations(entry); len(ops) > 0 {
+				safePath = filepath.Join(sanitizeURLPath(parsedURL.Path), graphQLFilename(ops, mimeType))
+			}
+
+			fullPath, err = safeJoin(base, filepath.Join(safeDomain, safePath))
 			if err != nil {
-				log.Errorf("Failed to create domain directory %s: %v", domainDir, err)
+				log.Errorf("Refusing to extract %s: %v", entry.Request.URL, err)
+				skip(Event{Type: EventError, Phase: "extract", Index: i, URL: entry.Request.URL, Err: err}, "unsafe path")
 				continue
 			}
+			if allocated := paths.allocate(fullPath); allocated != fullPath {
+				log.Debugf("%s already extracted, writing %s to %s", fullPath, entry.Request.URL, allocated)
+				fullPath, sanitized = allocated, true
+			}
 
-			filename = determineFilename(parsedURL, entry.Response.Content.MimeType)
-			urlPath := strings.TrimPrefix(parsedURL.Path, "/")
-			if urlPath != "" {
-				fullPath = filepath.Join(domainDir, urlPath)
-			} else {
-				fullPath = filepath.Join(domainDir, filename)
+			dir := filepath.Dir(fullPath)
+			err = target.mkdirAll(dir)
+			if err != nil {
+				log.Errorf("Failed to create domain directory %s: %v", dir, err)
+				skip(Event{Type: EventError, Phase: "extract", Index: i, URL: entry.Request.URL, Err: err}, "cannot create directory")
+				continue
 			}
 		}
 
-		// Decode response content, handling base64 encoding for binary files.
-		// HAR format stores binary content as base64, text content as plain text.
-		content := entry.Response.Content.Text
-		var decodedContent []byte
-
-		// Check encoding type and decode accordingly
-		if entry.Response.Content.Encoding == "base64" {
-			decodedContent, err = base64.StdEncoding.DecodeString(content)
+		if (opts.PrettyJSON || opts.SortJSONKeys) && strings.Contains(strings.ToLower(mimeType), "json") {
+			formatted, err := formatJSON(decodedContent, opts.SortJSONKeys)
 			if err != nil {
-				log.Errorf("Failed to decode base64 content for %s: %v", entry.Request.URL, err)
-				continue
+				log.Debugf("Not formatting %s: %v", entry.Request.URL, err)
+			} else {
+				decodedContent = formatted
 			}
-		} else {
-			decodedContent = []byte(content)
 		}
 
 		// Write decoded content to filesystem with appropriate permissions
-		err = os.WriteFile(fullPath, decodedContent, 0644)
+		err = target.writeFile(fullPath, decodedContent, modTime)
 		if err != nil {
 			log.Errorf("Failed to write file %s: %v", fullPath, err)
+			skip(Event{Type: EventError, Phase: "extract", Index: i, URL: entry.Request.URL, Path: fullPath, Err: err}, "cannot write file")
 			continue
 		}
 
@@ -146,23 +623,140 @@ func Extract(r *bufio.Reader, sortByType bool) error {
 			Size: len(decodedContent),
 			Method: entry.Request.Method,
 			Status: entry.Response.Status,
+			Sanitized: sanitized,
 		})
 
-		fmt.Printf("Extracted %s -> %s [%d bytes]\n", 
+		emit(Event{Type: EventEntryExtracted, Phase: "extract", Index: i, URL: entry.Request.URL,
+			Method: entry.Request.Method, Status: entry.Response.Status, Path: fullPath, Bytes: int64(len(decodedContent))})
+
+		// Split event streams into one file per event so individual
+		// messages can be inspected and diffed
+		if opts.SplitEvents && entry.IsEventStream() {
+			eventsDir := splitEventsDir(fullPath, paths)
+			events, err := writeEventFiles(eventsDir, entry, decodedContent, target, modTime)
+			manifest = append(manifest, events...)
+			if err != nil {
+				log.Errorf("Failed to split events of %s: %v", entry.Request.URL, err)
+				extractError(Event{Type: EventError, Phase: "extract", Index: i, URL: entry.Request.URL, Path: eventsDir, Err: err})
+			} else if len(events) > 0 {
+				fmt.Fprintf(out, "Split %d events of %s -> %s\n", len(events), entry.Request.URL, eventsDir)
+			}
+		}
+
+		if name, decoded, err := decoders.Decode(entry); err != nil {
+			log.Errorf("Failed to decode %s: %v", entry.Request.URL, err)
+			extractError(Event{Type: EventError, Phase: "extract", Index: i, URL: entry.Request.URL, Err: err})
+		} else if name != "" {
+			m, err := writeDecodedBody(fullPath, name, decoded, entry, target, paths, modTime)
+			if err != nil {
+				log.Errorf("Failed to write decoded body of %s: %v", entry.Request.URL, err)
+				extractError(Event{Type: EventError, Phase: "extract", Index: i, URL: entry.Request.URL, Path: m.ExtractedPath, Err: err})
+			} else {
+				manifest = append(manifest, m)
+				fmt.Fprintf(out, "Decoded %s -> %s\n", entry.Request.URL, m.ExtractedPath)
+			}
+		}
+
+		if sourceMaps != nil && isJavaScript(entry) {
+			maps, err := writeSourceMap(base, fullPath, entry, decodedContent, sourceMaps, opts.SourceMapSources, target, paths, modTime)
+			manifest = append(manifest, maps...)
+			if err != nil {
+				log.Errorf("Failed to extract source map of %s: %v", entry.Request.URL, err)
+				extractError(Event{Type: EventError, Phase: "extract", Index: i, URL: entry.Request.URL, Err: err})
+			} else if len(maps) > 0 {
+				fmt.Fprintf(out, "Extracted source map of %s with %d sources -> %s\n", entry.Request.URL, len(maps)-1, maps[0].ExtractedPath)
+			}
+		}
+
+		fmt.Fprintf(out, "Extracted %s -> %s [%d bytes]\n", 
 			entry.Request.URL, fullPath, len(decodedContent))
 	}
 
+	if cancelled == nil {
+		reportProgress(len(har.Log.Entries))
+	}
+
 	// Write CSV manifest documenting all extracted files with metadata.
 	// This provides a complete audit trail of the extraction process.
-	manifestPath := filepath.Join(outdir, "extraction_manifest.csv")
-	err = writeManifest(manifest, manifestPath)
+	target.addHashes(manifest)
+	result.Kept = target.kept
+	if target.resume {
+		fmt.Fprintf(out, "%d files already extracted were kept\n", result.Kept)
+	}
+	err = writeManifest(manifest, manifestPath, target)
 	if err != nil {
 		log.Errorf("Failed to write manifest: %v", err)
 	} else {
-		fmt.Printf("\nExtraction manifest written to: %s\n", manifestPath)
+		fmt.Fprintf(out, "\nExtraction manifest written to: %s\n", manifestPath)
 	}
 
-	return nil
+	// Finish the archive; a truncated archive is an error
+	result.Written, result.Manifest = len(manifest), manifest
+	if err := target.close(); err != nil {
+		return result, err
+	}
+	return result, cancelled
+}
+
+// pageDirectories returns the directory name of every page by its id,
+// numbered in the order of pages so pages with the same title stay apart
+// and sort as they were visited, e.g. 01-golang.org.
+func pageDirectories(pages []Page) map[string]string {
+	dirs := make(map[string]string, len(pages))
+	width := len(strconv.Itoa(len(pages)))
+	for i, page := range pages {
+		title := strings.TrimSpace(page.Title)
+		if title == "" {
+			title = page.ID
+		}
+		// page titles are often the URL of the page
+		title = strings.TrimPrefix(strings.TrimPrefix(title, "https://"), "http://")
+		title = strings.Trim(title, "/")
+		if r := []rune(title); len(r) > maxPageDirTitle {
+			title = string(r[:maxPageDirTitle])
+		}
+		name := fmt.Sprintf("%0*d", width, i+1)
+		if title != "" {
+			name += "-" + title
+		}
+		dirs[page.ID] = sanitizeFilename(name)
+	}
+	return dirs
+}
+
+// maxPageDirTitle is how many characters of a page title are kept in the
+// name of its directory.
+const maxPageDirTitle = 60
+
+// formatJSON indents a JSON document by two spaces, sorting the keys of
+// all objects if sortKeys is set. Numbers keep their original text.
+func formatJSON(data []byte, sortKeys bool) ([]byte, error) {
+	var buf bytes.Buffer
+	if !sortKeys {
+		if err := json.Indent(&buf, bytes.TrimSpace(data), "", "  "); err != nil {
+			return nil, err
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	}
+
+	// maps are encoded with sorted keys
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid character after top-level value")
+	}
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 // determineFilename extracts filename from URL path or generates sensible default.
@@ -223,6 +817,8 @@ func getTypeDirectory(mimeType string) string {
 		return "javascript"
 	case strings.Contains(mimeType, "font") || strings.Contains(mimeType, "woff"):
 		return "fonts"
+	case strings.Contains(mimeType, "text/event-stream"):
+		return "events"
 	case strings.Contains(mimeType, "text/"):
 		return "text"
 	case strings.Contains(mimeType, "video/"):
@@ -322,6 +918,8 @@ func getExtensionFromMimeType(mimeType string) string {
 		return ".webp"
 	case strings.Contains(mimeType, "text/plain"):
 		return ".txt"
+	case strings.Contains(mimeType, "text/event-stream"):
+		return ".sse"
 	case strings.Contains(mimeType, "application/pdf"):
 		return ".pdf"
 	case strings.Contains(mimeType, "font/woff2"):
@@ -338,19 +936,13 @@ func getExtensionFromMimeType(mimeType string) string {
 // writeManifest creates CSV file documenting all extracted files with complete metadata.
 // Includes original URLs, extraction paths, content types, sizes, and HTTP details.
 // Provides audit trail and enables post-extraction analysis and verification.
-func writeManifest(manifest []ManifestEntry, manifestPath string) error {
-	file, err := os.Create(manifestPath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+func writeManifest(manifest []ManifestEntry, manifestPath string, target extractTarget) error {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
 
 	// Write CSV header with descriptive column names for easy parsing
-	// Example row: "https://example.com/image.png","./images/image.png","image/png","1024","GET","200"
-	header := []string{"Original URL", "Extracted Path", "MIME Type", "Size (bytes)", "HTTP Method", "Status Code"}
+	// Example row: "https://example.com/image.png","./images/image.png","image/png","1024","GET","200","false","9f86d081..."
+	header := []string{"Original URL", "Extracted Path", "MIME Type", "Size (bytes)", "HTTP Method", "Status Code", "Sanitized", "SHA-256"}
 	if err := writer.Write(header); err != nil {
 		return err
 	}
@@ -364,11 +956,17 @@ func writeManifest(manifest []ManifestEntry, manifestPath string) error {
 			strconv.Itoa(entry.Size),
 			entry.Method,
 			strconv.Itoa(entry.Status),
+			strconv.FormatBool(entry.Sanitized),
+			entry.SHA256,
 		}
 		if err := writer.Write(record); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return target.writeFile(manifestPath, buf.Bytes(), time.Time{})
 }
\ No newline at end of file
@@ -2,12 +2,18 @@ package hargo
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 )
 
 // createTestHAR creates a minimal HAR structure for testing
@@ -259,4 +265,653 @@ func parseURL(t *testing.T, urlStr string) *url.URL {
 		t.Fatalf("Failed to parse URL %s: %v", urlStr, err)
 	}
 	return parsedURL
-}
\ No newline at end of file
+}
+func TestExtractEmitsEvents(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	var extracted, phases int
+	unsubscribe := Subscribe(func(e Event) {
+		switch e.Type {
+		case EventEntryExtracted:
+			extracted++
+			if e.Phase != "extract" || e.Path == "" || e.Bytes == 0 {
+				t.Errorf("incomplete extracted event: %+v", e)
+			}
+		case EventPhaseStarted, EventPhaseFinished:
+			phases++
+		}
+	})
+	defer unsubscribe()
+
+	reader := bufio.NewReader(strings.NewReader(createTestHAR()))
+	if err := Extract(reader, true); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	if extracted != 3 {
+		t.Errorf("got %d entry extracted events, expected 3", extracted)
+	}
+	if phases != 2 {
+		t.Errorf("got %d phase events, expected 2", phases)
+	}
+}
+
+func TestExtractWebSocketMessages(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	testHAR := `{"log": {"version": "1.2", "creator": {"name": "test", "version": "1.0"}, "entries": [{
+		"startedDateTime": "2024-01-01T00:00:00.000Z", "time": 10,
+		"request": {"method": "GET", "url": "wss://example.com/socket", "httpVersion": "HTTP/1.1", "headers": [], "queryString": [], "cookies": [], "headersSize": -1, "bodySize": 0},
+		"response": {"status": 101, "statusText": "Switching Protocols", "httpVersion": "HTTP/1.1", "headers": [{"name": "Upgrade", "value": "websocket"}], "cookies": [], "content": {"size": 0, "mimeType": ""}, "redirectURL": "", "headersSize": -1, "bodySize": 0},
+		"cache": {}, "timings": {"send": 0, "wait": 10, "receive": 0},
+		"_resourceType": "websocket",
+		"_webSocketMessages": [
+			{"type": "send", "time": 1704067200.5, "opcode": 1, "data": "{\"subscribe\":\"prices\"}"},
+			{"type": "receive", "time": 1704067200.75, "opcode": 2, "data": "AAEC"}
+		]}]}}`
+
+	har, err := Decode(bufio.NewReader(strings.NewReader(testHAR)))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !har.Log.Entries[0].IsWebSocket() || len(har.Log.Entries[0].WebSocketMessages) != 2 {
+		t.Fatalf("WebSocket messages not decoded: %+v", har.Log.Entries[0])
+	}
+
+	var buf strings.Builder
+	if err := Encode(&buf, har); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"_webSocketMessages"`) {
+		t.Error("WebSocket messages not preserved by Encode")
+	}
+
+	err = ExtractWithOptions(bufio.NewReader(strings.NewReader(testHAR)), ExtractOptions{WebSocketMessages: true})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	matches, _ := filepath.Glob("./hargo-extract-*/websockets/*.jsonl")
+	if len(matches) != 1 {
+		t.Fatalf("expected one transcript, got %v", matches)
+	}
+	data, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"direction":"send","opcode":1,"time":"2024-01-01T00:00:00.5Z","payload":"{\"subscribe\":\"prices\"}"}
+{"direction":"receive","opcode":2,"time":"2024-01-01T00:00:00.75Z","payload":"AAEC","encoding":"base64"}
+`
+	if string(data) != expected {
+		t.Errorf("unexpected transcript:\n%s", data)
+	}
+}
+
+func TestExtractPlainWebSockets(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	longPath := "/" + strings.Repeat("segment/", 40)
+	entry := func(started, u string) Entry {
+		return Entry{
+			StartedDateTime: started,
+			Request:         Request{Method: "GET", URL: u},
+			Response:        Response{Status: 101, Headers: []NVP{{Name: "Upgrade", Value: "websocket"}}},
+			WebSocketMessages: []WebSocketMessage{
+				{Type: "send", Time: 1704067200.5, Opcode: OpcodeText, Data: "ping"},
+			},
+		}
+	}
+	harData, _ := json.Marshal(Har{Log: Log{Entries: []Entry{
+		entry("2024-01-01T00:00:00.000Z", "ws://localhost:8080/socket"),
+		entry("2024-01-01T00:00:01.000Z", "ws://localhost:8080"+longPath),
+		entry("2024-01-01T00:00:02.000Z", "ws://localhost:8080"+longPath+"other"),
+	}}})
+
+	// Decode keeps every ws:// connection and its messages
+	har, err := Decode(bufio.NewReader(bytes.NewReader(harData)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(har.Log.Entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(har.Log.Entries))
+	}
+	for _, e := range har.Log.Entries {
+		if !strings.HasPrefix(e.Request.URL, "ws://") || len(e.WebSocketMessages) != 1 {
+			t.Errorf("got entry %+v", e)
+		}
+	}
+
+	err = ExtractWithOptions(bufio.NewReader(bytes.NewReader(harData)), ExtractOptions{WebSocketMessages: true})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	matches, _ := filepath.Glob("./hargo-extract-*/websockets/*.jsonl")
+	if len(matches) != 3 {
+		t.Fatalf("expected three transcripts, got %v", matches)
+	}
+	var names []string
+	for _, match := range matches {
+		names = append(names, filepath.Base(match))
+	}
+	sort.Strings(names)
+	// long URLs are shortened to the longest file name, keeping the index
+	if len(names[0]) != maxFilenameLength || !strings.HasSuffix(names[0], "-1.jsonl") ||
+		len(names[1]) != maxFilenameLength || !strings.HasSuffix(names[1], "-2.jsonl") ||
+		names[2] != "localhost_8080_socket-0.jsonl" {
+		t.Errorf("got transcripts %v", names)
+	}
+}
+
+func TestExtractSplitEvents(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	testHAR := `{"log": {"version": "1.2", "creator": {"name": "test", "version": "1.0"}, "entries": [{
+		"startedDateTime": "2024-01-01T00:00:00.000Z", "time": 10,
+		"request": {"method": "GET", "url": "https://example.com/stream", "httpVersion": "HTTP/1.1", "headers": [], "queryString": [], "cookies": [], "headersSize": -1, "bodySize": 0},
+		"response": {"status": 200, "statusText": "OK", "httpVersion": "HTTP/1.1", "headers": [], "cookies": [],
+			"content": {"size": 0, "mimeType": "text/event-stream", "text": ": keep-alive\n\nid: 1\ndata: {\"price\":1}\n\nevent: note\ndata: line one\ndata: line two\n\nevent: ` + strings.Repeat("x", 300) + `\ndata: long\n\n"},
+			"redirectURL": "", "headersSize": -1, "bodySize": 0},
+		"cache": {}, "timings": {"send": 0, "wait": 10, "receive": 0},
+		"_resourceType": "eventsource"}]}}`
+
+	har, err := Decode(bufio.NewReader(strings.NewReader(testHAR)))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	entry := har.Log.Entries[0]
+	if entry.ResourceType != "eventsource" || !entry.IsEventStream() {
+		t.Fatalf("event stream not recognized: %+v", entry)
+	}
+
+	body, _ := decodeContent(entry.Response.Content)
+	events := ParseEvents(body)
+	if len(events) != 3 || events[0].ID != "1" || events[1].Event != "note" || events[1].Data != "line one\nline two" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+
+	err = ExtractWithOptions(bufio.NewReader(strings.NewReader(testHAR)), ExtractOptions{SortByType: true, SplitEvents: true})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	for _, pattern := range []string{
+		"./hargo-extract-*/events/stream.sse",
+		"./hargo-extract-*/events/stream_events/0001-message.json",
+		"./hargo-extract-*/events/stream_events/0002-note.txt",
+	} {
+		if matches, _ := filepath.Glob(pattern); len(matches) != 1 {
+			t.Errorf("expected %s to exist", pattern)
+		}
+	}
+	// long event types are shortened, keeping the sequence number and extension
+	matches, _ := filepath.Glob("./hargo-extract-*/events/stream_events/0003-x*.txt")
+	if len(matches) != 1 || len(filepath.Base(matches[0])) != maxFilenameLength {
+		t.Errorf("got %v", matches)
+	}
+
+	// the directory of events is shortened and does not replace a file
+	paths := newPathAllocator()
+	long := filepath.Join("out", strings.Repeat("a", 250)+".sse")
+	if dir := splitEventsDir(long, paths); filepath.Base(dir) != strings.Repeat("a", 248)+"_events" {
+		t.Errorf("got %s", dir)
+	}
+	paths.allocate(filepath.Join("out", "stream_events"))
+	if dir := splitEventsDir(filepath.Join("out", "stream.sse"), paths); dir != filepath.Join("out", "stream_events_1") {
+		t.Errorf("got %s", dir)
+	}
+}
+
+func TestExtractModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on Windows")
+	}
+	defer cleanupExtractDirs()
+
+	opts := ExtractOptions{SortByType: true, DirMode: 0750, FileMode: 0600, IgnoreUmask: true}
+	err := ExtractWithOptions(bufio.NewReader(strings.NewReader(createTestHAR())), opts)
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	matches, _ := filepath.Glob("./hargo-extract-*")
+	if len(matches) == 0 {
+		t.Fatal("No extraction directory created")
+	}
+
+	err = filepath.Walk(matches[0], func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		expected := os.FileMode(0600)
+		if info.IsDir() {
+			expected = 0750
+		}
+		if info.Mode().Perm() != expected {
+			t.Errorf("%s has mode %v, expected %v", path, info.Mode().Perm(), expected)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractSanitizesPaths(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	entry := func(rawURL string) Entry {
+		return Entry{
+			Request:  Request{Method: "GET", URL: rawURL},
+			Response: Response{Status: 200, Content: Content{MimeType: "text/plain", Text: rawURL}},
+		}
+	}
+	harData, _ := json.Marshal(Har{Log: Log{Entries: []Entry{
+		entry("https://example.com/../../escape.txt"),
+		entry("https://example.com/a/%2e%2e/%2e%2e/%2e%2e/escape2.txt"),
+		entry("https://example.com/dir/sub/file.txt"),
+		entry("https://example.com/what%3F%3Cx%3E.txt"),
+		entry("https://example.com/aux"),
+	}}})
+
+	if err := Extract(bufio.NewReader(strings.NewReader(string(harData))), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	matches, _ := filepath.Glob("./hargo-extract-*")
+	if len(matches) == 0 {
+		t.Fatal("No extraction directory created")
+	}
+	outdir := matches[0]
+
+	for _, name := range []string{"escape.txt", "escape2.txt"} {
+		if _, err := os.Stat(name); err == nil {
+			os.Remove(name)
+			t.Errorf("%s written outside the output directory", name)
+		}
+	}
+
+	for _, rel := range []string{"escape.txt", "escape2.txt", "dir/sub/file.txt", "what__x_.txt", "_aux"} {
+		if _, err := os.Stat(filepath.Join(outdir, "example.com", filepath.FromSlash(rel))); err != nil {
+			t.Errorf("expected %s to be extracted: %v", rel, err)
+		}
+	}
+
+	manifest, err := os.ReadFile(filepath.Join(outdir, "extraction_manifest.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"https://example.com/dir/sub/file.txt," + filepath.Join(outdir, "example.com", "dir", "sub", "file.txt") + ",text/plain,36,GET,200,false",
+		"https://example.com/what%3F%3Cx%3E.txt," + filepath.Join(outdir, "example.com", "what__x_.txt") + ",text/plain,38,GET,200,true",
+	} {
+		if !strings.Contains(string(manifest), line) {
+			t.Errorf("manifest does not contain %q:\n%s", line, manifest)
+		}
+	}
+}
+
+func TestExtractQueryFilenames(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	entry := func(rawURL string) Entry {
+		return Entry{
+			Request:  Request{Method: "GET", URL: rawURL},
+			Response: Response{Status: 200, Content: Content{MimeType: "application/json", Text: `"` + rawURL + `"`}},
+		}
+	}
+	harData, _ := json.Marshal(Har{Log: Log{Entries: []Entry{
+		entry("https://example.com/api/items?id=1"),
+		entry("https://example.com/api/items?id=2"),
+	}}})
+
+	opts := ExtractOptions{QueryFilenames: QuerySanitized}
+	if err := ExtractWithOptions(bufio.NewReader(strings.NewReader(string(harData))), opts); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	for _, name := range []string{"items_id=1", "items_id=2"} {
+		matches, _ := filepath.Glob(filepath.Join("hargo-extract-*", "example.com", "api", name))
+		if len(matches) != 1 {
+			t.Errorf("expected %s to be extracted", name)
+			continue
+		}
+		data, _ := os.ReadFile(matches[0])
+		if !strings.HasSuffix(string(data), strings.TrimPrefix(name, "items_")+`"`) {
+			t.Errorf("%s has content %s", name, data)
+		}
+	}
+}
+
+func TestExtractDomainCollisions(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	entry := func(rawURL, text string) Entry {
+		return Entry{
+			Request:  Request{Method: "GET", URL: rawURL},
+			Response: Response{Status: 200, Content: Content{MimeType: "text/plain", Text: text}},
+		}
+	}
+	harData, _ := json.Marshal(Har{Log: Log{Entries: []Entry{
+		entry("https://example.com/api/items?id=1", "one"),
+		entry("https://example.com/api/items?id=2", "two"),
+		entry("https://example.com/api/items/3", "three"),
+	}}})
+
+	if err := Extract(bufio.NewReader(strings.NewReader(string(harData))), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	for rel, expected := range map[string]string{
+		"api/items":     "one",
+		"api/items_1":   "two",
+		"api/items_2/3": "three",
+	} {
+		matches, _ := filepath.Glob(filepath.Join("hargo-extract-*", "example.com", filepath.FromSlash(rel)))
+		if len(matches) != 1 {
+			t.Errorf("expected %s to be extracted", rel)
+			continue
+		}
+		if data, _ := os.ReadFile(matches[0]); string(data) != expected {
+			t.Errorf("%s has content %q, expected %q", rel, data, expected)
+		}
+	}
+}
+
+func TestExtractModTime(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	harData, _ := json.Marshal(Har{Log: Log{Entries: []Entry{
+		{
+			StartedDateTime: "2024-01-02T10:00:00.000Z",
+			Request:         Request{Method: "GET", URL: "https://example.com/a.txt"},
+			Response:        Response{Status: 200, Content: Content{MimeType: "text/plain", Text: "a"}},
+		},
+		{
+			StartedDateTime: "2024-01-02T10:00:01.000Z",
+			Request:         Request{Method: "GET", URL: "https://example.com/b.txt"},
+			Response: Response{Status: 200, Content: Content{MimeType: "text/plain", Text: "b"},
+				Headers: []NVP{{Name: "Last-Modified", Value: "Mon, 01 May 2023 08:00:00 GMT"}}},
+		},
+	}}})
+
+	opts := ExtractOptions{ModTime: ModTimeLastModified}
+	if err := ExtractWithOptions(bufio.NewReader(strings.NewReader(string(harData))), opts); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	for name, expected := range map[string]string{
+		"a.txt": "2024-01-02T10:00:00Z",
+		"b.txt": "2023-05-01T08:00:00Z",
+	} {
+		matches, _ := filepath.Glob(filepath.Join("hargo-extract-*", "example.com", name))
+		if len(matches) != 1 {
+			t.Fatalf("expected %s to be extracted", name)
+		}
+		info, err := os.Stat(matches[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.ModTime().UTC().Format(time.RFC3339); got != expected {
+			t.Errorf("%s modified %s, expected %s", name, got, expected)
+		}
+	}
+}
+
+func TestExtractOutput(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	var out strings.Builder
+	if err := ExtractWithOptions(bufio.NewReader(strings.NewReader(createTestHAR())), ExtractOptions{Output: &out}); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Extracted ") || !strings.Contains(out.String(), "Extraction manifest written to") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestExtractWithResult(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	harData, _ := json.Marshal(Har{Log: Log{Entries: []Entry{
+		{Request: Request{Method: "GET", URL: "https://example.com/a.txt"},
+			Response: Response{Status: 200, Content: Content{MimeType: "text/plain", Text: "abc"}}},
+		{Request: Request{Method: "GET", URL: "https://example.com/empty"}},
+		{Request: Request{Method: "GET", URL: "https://example.com/bad.png"},
+			Response: Response{Status: 200, Content: Content{MimeType: "image/png", Text: "!", Encoding: "base64"}}},
+	}}})
+
+	result, err := ExtractWithResult(bufio.NewReader(strings.NewReader(string(harData))), ExtractOptions{})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	if result.Entries != 3 || result.Written != 1 || len(result.Manifest) != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if !strings.HasPrefix(filepath.Base(result.OutputDir), "hargo-extract-") {
+		t.Errorf("unexpected output directory %s", result.OutputDir)
+	}
+	if _, err := os.Stat(result.Manifest[0].ExtractedPath); err != nil {
+		t.Errorf("manifest path not written: %v", err)
+	}
+
+	if len(result.Skipped) != 2 {
+		t.Fatalf("expected 2 skipped entries, got %+v", result.Skipped)
+	}
+	if s := result.Skipped[0]; s.Index != 1 || s.Reason != "no response content" || s.Err != nil {
+		t.Errorf("unexpected skip %+v", s)
+	}
+	if s := result.Skipped[1]; s.Index != 2 || s.URL != "https://example.com/bad.png" || s.Err == nil {
+		t.Errorf("unexpected skip %+v", s)
+	}
+}
+
+func TestExtractSizeLimits(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	harData, _ := json.Marshal(Har{Log: Log{Entries: []Entry{
+		{Request: Request{Method: "GET", URL: "https://example.com/pixel.gif"},
+			Response: Response{Status: 200, Content: Content{MimeType: "image/gif", Text: base64.StdEncoding.EncodeToString([]byte("GIF89a")), Encoding: "base64"}}},
+		{Request: Request{Method: "GET", URL: "https://example.com/page.html"},
+			Response: Response{Status: 200, Content: Content{MimeType: "text/html", Text: strings.Repeat("x", 100)}}},
+		{Request: Request{Method: "GET", URL: "https://example.com/video.mp4"},
+			Response: Response{Status: 200, Content: Content{MimeType: "video/mp4", Text: base64.StdEncoding.EncodeToString(make([]byte, 1000)), Encoding: "base64"}}},
+	}}})
+
+	result, err := ExtractWithResult(bufio.NewReader(strings.NewReader(string(harData))), ExtractOptions{MinSize: 43, MaxSize: 100})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if len(result.Manifest) != 1 || filepath.Base(result.Manifest[0].ExtractedPath) != "page.html" {
+		t.Errorf("unexpected manifest %+v", result.Manifest)
+	}
+	// sizes are those of the decoded bodies
+	if len(result.Skipped) != 2 || result.Skipped[0].Reason != "smaller than minimum size" || result.Skipped[1].Reason != "larger than maximum size" {
+		t.Errorf("unexpected skips %+v", result.Skipped)
+	}
+}
+
+func TestExtractOutputDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "captures", "checkout")
+
+	// extracting twice into the same directory, created the first time
+	for i := 0; i < 2; i++ {
+		result, err := ExtractWithResult(bufio.NewReader(strings.NewReader(createTestHAR())), ExtractOptions{OutputDir: dir})
+		if err != nil {
+			t.Fatalf("%d: Extract failed: %v", i, err)
+		}
+		if result.OutputDir != dir || result.Written == 0 {
+			t.Errorf("%d: unexpected result %+v", i, result)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "extraction_manifest.csv")); err != nil {
+		t.Errorf("manifest not written: %v", err)
+	}
+	if dirs, _ := filepath.Glob("./hargo-extract-*"); len(dirs) != 0 {
+		t.Errorf("expected no timestamped directory, got %v", dirs)
+	}
+}
+
+func TestExtractGroupByPage(t *testing.T) {
+	const har = `{"log": {"pages": [
+		{"id": "page_1", "title": "https://example.com/"},
+		{"id": "page_2", "title": "Checkout: Step 2/3"},
+		{"id": "page_3", "title": "https://example.com/"}
+	], "entries": [
+		{"pageref": "page_1", "request": {"method": "GET", "url": "https://example.com/"}, "response": {"status": 200, "content": {"mimeType": "text/html", "text": "home"}}},
+		{"pageref": "page_2", "request": {"method": "GET", "url": "https://example.com/"}, "response": {"status": 200, "content": {"mimeType": "text/html", "text": "checkout"}}},
+		{"pageref": "page_3", "request": {"method": "GET", "url": "https://cdn.example.com/app.js"}, "response": {"status": 200, "content": {"mimeType": "application/javascript", "text": "app"}}},
+		{"request": {"method": "GET", "url": "https://example.com/ping"}, "response": {"status": 200, "content": {"mimeType": "text/plain", "text": "pong"}}}
+	]}}`
+
+	tests := []struct {
+		sortByType bool
+		expected   map[string]string
+	}{
+		{false, map[string]string{
+			"1-example.com/example.com/index.html":        "home",
+			"2-Checkout_ Step 2_3/example.com/index.html": "checkout",
+			"3-example.com/cdn.example.com/app.js":        "app",
+			"no-page/example.com/ping":                    "pong",
+		}},
+		{true, map[string]string{
+			"1-example.com/html/page.html":        "home",
+			"2-Checkout_ Step 2_3/html/page.html": "checkout",
+			"3-example.com/javascript/app.js":     "app",
+			"no-page/text/ping.txt":               "pong",
+		}},
+	}
+	for i, test := range tests {
+		dir := t.TempDir()
+		result, err := ExtractWithResult(bufio.NewReader(strings.NewReader(har)), ExtractOptions{OutputDir: dir, GroupByPage: true, SortByType: test.sortByType})
+		if err != nil {
+			t.Fatalf("%d: Extract failed: %v", i, err)
+		}
+		if result.Written != len(test.expected) {
+			t.Errorf("%d: got %d files, want %d", i, result.Written, len(test.expected))
+		}
+		for path, content := range test.expected {
+			if data, err := os.ReadFile(filepath.Join(dir, path)); err != nil || string(data) != content {
+				t.Errorf("%d: %s: got %q, %v", i, path, data, err)
+			}
+		}
+	}
+}
+
+func TestExtractContextCancelled(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := ExtractOptions{Progress: func(p Progress) {
+		// cancel after the first entry
+		cancel()
+	}}
+	result, err := ExtractContext(ctx, bufio.NewReader(strings.NewReader(createTestHAR())), opts)
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if result.Written != 1 {
+		t.Errorf("expected 1 file before cancellation, got %d", result.Written)
+	}
+	if _, err := os.Stat(filepath.Join(result.OutputDir, "extraction_manifest.csv")); err != nil {
+		t.Errorf("manifest not written after cancellation: %v", err)
+	}
+}
+
+func TestExtractGraphQLFilenames(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	entry := func(body, text string) Entry {
+		return Entry{
+			Request: Request{Method: "POST", URL: "https://example.com/graphql",
+				PostData: PostData{MimeType: "application/json", Text: body}},
+			Response: Response{Status: 200, Content: Content{MimeType: "application/json", Text: text}},
+		}
+	}
+	harData, _ := json.Marshal(Har{Log: Log{Entries: []Entry{
+		entry(`{"query":"query GetUser { user { id } }"}`, `{"data":{"user":{"id":1}}}`),
+		entry(`{"query":"mutation { save }","operationName":"SaveUser"}`, `{"data":{"save":true}}`),
+	}}})
+
+	if err := Extract(bufio.NewReader(strings.NewReader(string(harData))), false); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	for _, name := range []string{"GetUser.json", "SaveUser.json"} {
+		matches, _ := filepath.Glob(filepath.Join("hargo-extract-*", "example.com", "graphql", name))
+		if len(matches) != 1 {
+			t.Errorf("expected %s to be extracted", name)
+		}
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	input := []byte(`{"b":1.50,"a":{"d":"<x>","c":[2,1]}}`)
+
+	pretty, err := formatJSON(input, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "{\n  \"b\": 1.50,\n  \"a\": {\n    \"d\": \"<x>\",\n    \"c\": [\n      2,\n      1\n    ]\n  }\n}\n"; string(pretty) != expected {
+		t.Errorf("unexpected pretty JSON:\n%s", pretty)
+	}
+
+	sorted, err := formatJSON(input, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "{\n  \"a\": {\n    \"c\": [\n      2,\n      1\n    ],\n    \"d\": \"<x>\"\n  },\n  \"b\": 1.50\n}\n"; string(sorted) != expected {
+		t.Errorf("unexpected sorted JSON:\n%s", sorted)
+	}
+
+	for _, invalid := range []string{`{"a":`, `{} {}`, `not json`} {
+		if _, err := formatJSON([]byte(invalid), true); err == nil {
+			t.Errorf("%s: expected an error", invalid)
+		}
+	}
+}
+
+func TestExtractSourceMaps(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	entry := func(rawURL, mimeType, text string) Entry {
+		return Entry{
+			Request:  Request{Method: "GET", URL: rawURL},
+			Response: Response{Status: 200, Content: Content{MimeType: mimeType, Text: text}},
+		}
+	}
+	sourceMap := `{"version":3,"sources":["webpack:///./src/app.js","../src/util.ts"],"sourcesContent":["console.log(1)",null],"mappings":""}`
+	inline := base64.StdEncoding.EncodeToString([]byte(`{"version":3,"sources":["inline.js"],"sourcesContent":["inline()"]}`))
+	harData, _ := json.Marshal(Har{Log: Log{Entries: []Entry{
+		entry("https://example.com/static/app.js.map", "application/json", sourceMap),
+		entry("https://example.com/static/app.js", "application/javascript", "console.log(1)\n//# sourceMappingURL=app.js.map"),
+		entry("https://example.com/src/util.ts", "text/plain", "export {}"),
+		entry("https://example.com/static/inline.js", "text/javascript", "inline()\n//# sourceMappingURL=data:application/json;base64,"+inline),
+	}}})
+
+	result, err := ExtractWithResult(bufio.NewReader(strings.NewReader(string(harData))), ExtractOptions{SourceMapSources: true})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].URL != "https://example.com/static/app.js.map" {
+		t.Errorf("unexpected skipped entries %+v", result.Skipped)
+	}
+
+	for rel, expected := range map[string]string{
+		"example.com/static/app.js.map":        sourceMap,
+		"example.com/static/inline.js.map":     "",
+		"sources/webpack/src/app.js":           "console.log(1)",
+		"sources/example.com/src/util.ts":      "export {}",
+		"sources/example.com/static/inline.js": "inline()",
+	} {
+		matches, _ := filepath.Glob(filepath.Join("hargo-extract-*", filepath.FromSlash(rel)))
+		if len(matches) != 1 {
+			t.Errorf("expected %s to be extracted", rel)
+			continue
+		}
+		if data, _ := os.ReadFile(matches[0]); expected != "" && string(data) != expected {
+			t.Errorf("%s has content %q, expected %q", rel, data, expected)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package hargo
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// Every HAR object keeps the fields hargo does not model, such as vendor
+// extensions (_-prefixed) or fields of newer spec versions, in its Extras
+// map, so that Decode followed by Encode never drops data. The
+// UnmarshalJSON and MarshalJSON methods below convert through an alias
+// type without methods to avoid recursion.
+
+// unmarshalExtras decodes data into v, a pointer to a struct, and stores
+// the fields not known to v's type in extras.
+func unmarshalExtras(data []byte, v interface{}, extras *map[string]json.RawMessage) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		// null, which v accepted
+		return nil
+	}
+
+	known := jsonFields(reflect.TypeOf(v).Elem())
+	for name := range fields {
+		if _, ok := known[name]; ok {
+			delete(fields, name)
+		}
+	}
+	if len(fields) == 0 {
+		fields = nil
+	}
+	*extras = fields
+	return nil
+}
+
+// unmarshalLegacyField decodes the field that older versions of hargo wrote
+// as legacy instead of current into v, and removes it from extras. Data
+// with a current field keeps legacy as an extra.
+func unmarshalLegacyField(data []byte, extras *map[string]json.RawMessage, legacy string, current string, v interface{}) error {
+	value, ok := (*extras)[legacy]
+	if !ok {
+		return nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if _, ok := fields[current]; ok {
+		return nil
+	}
+	delete(*extras, legacy)
+	if len(*extras) == 0 {
+		*extras = nil
+	}
+	return json.Unmarshal(value, v)
+}
+
+// marshalExtras encodes v, a struct, and appends the fields in extras that
+// v does not set itself, sorted by name. A field of v omitted as empty is
+// written from extras, which lets Normalize fill in absent comments.
+func marshalExtras(v interface{}, extras map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extras) == 0 {
+		return data, err
+	}
+
+	known := jsonFields(reflect.TypeOf(v))
+	var set map[string]json.RawMessage
+	names := make([]string, 0, len(extras))
+	for name := range extras {
+		if _, ok := known[name]; ok {
+			if set == nil {
+				if err := json.Unmarshal(data, &set); err != nil {
+					return nil, err
+				}
+			}
+			if _, ok := set[name]; ok {
+				continue
+			}
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.Write(data[:len(data)-1])
+	for _, name := range names {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(extras[name])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields.
+func (h *Har) UnmarshalJSON(data []byte) error {
+	type har Har
+	return unmarshalExtras(data, (*har)(h), &h.Extras)
+}
+
+// MarshalJSON implements json.Marshaler, writing unknown fields back.
+func (h Har) MarshalJSON() ([]byte, error) {
+	type har Har
+	return marshalExtras(har(h), h.Extras)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields.
+func (l *Log) UnmarshalJSON(data []byte) error {
+	type log Log
+	return unmarshalExtras(data, (*log)(l), &l.Extras)
+}
+
+// MarshalJSON implements json.Marshaler, writing unknown fields back.
+func (l Log) MarshalJSON() ([]byte, error) {
+	type log Log
+	return marshalExtras(log(l), l.Extras)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields.
+func (c *Creator) UnmarshalJSON(data []byte) error {
+	type creator Creator
+	return unmarshalExtras(data, (*creator)(c), &c.Extras)
+}
+
+// MarshalJSON implements json.Marshaler, writing unknown fields back.
+func (c Creator) MarshalJSON() ([]byte, error) {
+	type creator Creator
+	return marshalExtras(creator(c), c.Extras)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields.
+func (b *Browser) UnmarshalJSON(data []byte) error {
+	type browser Browser
+	return unmarshalExtras(data, (*browser)(b), &b.Extras)
+}
+
+// MarshalJSON implements json.Marshaler, writing unknown fields back.
+func (b Browser) MarshalJSON() ([]byte, error) {
+	type browser Browser
+	return marshalExtras(browser(b), b.Extras)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields.
+// Files written before hargo used the spec's "pageTimings" key have their
+// page timings in "pageTiming".
+func (p *Page) UnmarshalJSON(data []byte) error {
+	type page Page
+	if err := unmarshalExtras(data, (*page)(p), &p.Extras); err != nil {
+		return err
+	}
+	return unmarshalLegacyField(data, &p.Extras, "pageTiming", "pageTimings", &p.PageTiming)
+}
+
+// MarshalJSON implements json.Marshaler, writing unknown fields back.
+func (p Page) MarshalJSON() ([]byte, error) {
+	type page Page
+	return marshalExtras(page(p), p.Extras)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields.
+func (p *PageTiming) UnmarshalJSON(data []byte) error {
+	type pageTiming PageTiming
+	return unmarshalExtras(data, (*pageTiming)(p), &p.Extras)
+}
+
+// MarshalJSON implements json.Marshaler, writing unknown fields back.
+func (p PageTiming) MarshalJSON() ([]byte, error) {
+	type pageTiming PageTiming
+	return marshalExtras(pageTiming(p), p.Extras)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields.
+// Files written before hargo used the spec's "timings" key have the
+// timings of entries in "pageTimings".
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	type entry Entry
+	if err := unmarshalExtras(data, (*entry)(e), &e.Extras); err != nil {
+		return err
+	}
+	return unmarshalLegacyField(data, &e.Extras, "pageTimings", "timings", &e.Timings)
+}
+
+// MarshalJSON implements json.Marshaler, writing unknown fields back.
+func (e Entry) MarshalJSON() ([]byte, error) {
+	type entry Entry
+	return marshalExtras(entry(e), e.Extras)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields.
+func (i *Initiator) UnmarshalJSON(data []byte) error {
+	type initiator Initiator
+	return unmarshalExtras(data, (*initiator)(i), &i.Extras)
+}
+
+// MarshalJSON implements json.Marshaler, writing unknown fields back.
+func (i Initiator) MarshalJSON() ([]byte, error) {
+	type initiator Initiator
+	return marshalExtras(initiator(i), i.Extras)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields.
+func (r *Request) UnmarshalJSON(data []byte) error {
+	type request Request
+	return unmarshalExtras(data, (*request)(r), &r.Extras)
+}
+
+// MarshalJSON implements json.Marshaler, writing unknown fields back.
+func (r Request) MarshalJSON() ([]byte, error) {
+	type request Request
+	return marshalExtras(request(r), r.Extras)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields.
+func (r *Response) UnmarshalJSON(data []byte) error {
+	type response Response
+	return unmarshalExtras(data, (*response)(r), &r.Extras)
+}
+
+// MarshalJSON implements json.Marshaler, writing unknown fields back.
+func (r Response) MarshalJSON() ([]byte, error) {
+	type response Response
+	return marshalExtras(response(r), r.Extras)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields.
+func (c *Cookie) UnmarshalJSON(data []byte) error {
+	type cookie Cookie
+	return unmarshalExtras(data, (*cookie)(c), &c.Extras)
+}
+
+// MarshalJSON implements json.Marshaler, writing unknown fields back.
+func (c Cookie) MarshalJSON() ([]byte, error) {
+	type cookie Cookie
+	return marshalExtras(cookie(c), c.Extras)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields.
+func (n *NVP) UnmarshalJSON(data []byte) error {
+	type nvp NVP
+	return unmarshalExtras(data, (*nvp)(n), &n.Extras)
+}
+
+// MarshalJSON implements json.Marshaler, writing unknown fields back.
+func (n NVP) MarshalJSON() ([]byte, error) {
+	type nvp NVP
+	return marshalExtras(nvp(n), n.Extras)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields.
+func (p *PostData) UnmarshalJSON(data []byte) error {
+	type postData PostData
+	return unmarshalExtras(data, (*postData)(p), &p.Extras)
+}
+
+// MarshalJSON implements json.Marshaler, writing unknown fields back.
+func (p PostData) MarshalJSON() ([]byte, error) {
+	type postData PostData
+	return marshalExtras(postData(p), p.Extras)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields.
+func (p *PostParam) UnmarshalJSON(data []byte) error {
+	type postParam PostParam
+	return unmarshalExtras(data, (*postParam)(p), &p.Extras)
+}
+
+// MarshalJSON implements json.Marshaler, writing unknown fields back.
+func (p PostParam) MarshalJSON() ([]byte, error) {
+	type postParam PostParam
+	return marshalExtras(postParam(p), p.Extras)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields.
+func (c *Content) UnmarshalJSON(data []byte) error {
+	type content Content
+	return unmarshalExtras(data, (*content)(c), &c.Extras)
+}
+
+// MarshalJSON implements json.Marshaler, writing unknown fields back.
+func (c Content) MarshalJSON() ([]byte, error) {
+	type content Content
+	return marshalExtras(content(c), c.Extras)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields.
+func (c *Cache) UnmarshalJSON(data []byte) error {
+	type cache Cache
+	return unmarshalExtras(data, (*cache)(c), &c.Extras)
+}
+
+// MarshalJSON implements json.Marshaler, writing unknown fields back.
+func (c Cache) MarshalJSON() ([]byte, error) {
+	type cache Cache
+	return marshalExtras(cache(c), c.Extras)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields.
+func (c *CacheObject) UnmarshalJSON(data []byte) error {
+	type cacheObject CacheObject
+	return unmarshalExtras(data, (*cacheObject)(c), &c.Extras)
+}
+
+// MarshalJSON implements json.Marshaler, writing unknown fields back.
+func (c CacheObject) MarshalJSON() ([]byte, error) {
+	type cacheObject CacheObject
+	return marshalExtras(cacheObject(c), c.Extras)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown fields.
+func (p *PageTimings) UnmarshalJSON(data []byte) error {
+	type pageTimings PageTimings
+	return unmarshalExtras(data, (*pageTimings)(p), &p.Extras)
+}
+
+// MarshalJSON implements json.Marshaler, writing unknown fields back.
+func (p PageTimings) MarshalJSON() ([]byte, error) {
+	type pageTimings PageTimings
+	return marshalExtras(pageTimings(p), p.Extras)
+}
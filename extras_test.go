@@ -0,0 +1,176 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const vendorHAR = `{
+  "log": {
+    "version": "1.2",
+    "creator": {"name": "WebInspector", "version": "537.36"},
+    "_origin": "devtools",
+    "entries": [
+      {
+        "startedDateTime": "2024-01-02T10:00:00.000Z",
+        "_priority": "VeryHigh",
+        "_fromCache": "disk",
+        "_connectionId": "42",
+        "_initiator": {"type": "script", "url": "https://example.com/app.js", "columnNumber": 7},
+        "request": {"method": "GET", "url": "https://example.com/", "headers": [{"name": "a", "value": "b", "_hidden": true}]},
+        "response": {"status": 200, "_error": "", "_fetchedViaServiceWorker": true, "_workerRespondWithSettled": 1.5,
+          "content": {"size": 0, "mimeType": "text/html", "_encodedDataLength": 123}},
+        "timings": {"send": 1, "wait": 2, "receive": 3, "_blocked_queueing": 0.5, "_workerStart": -1}
+      }
+    ]
+  },
+  "_signature": "abc"
+}`
+
+func TestExtrasRoundTrip(t *testing.T) {
+	har, err := Decode(bufio.NewReader(strings.NewReader(vendorHAR)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := har.Log.Entries[0]
+	if entry.Priority != "VeryHigh" || entry.FromCache != "disk" || !entry.Response.FetchedViaServiceWorker || entry.Timings.BlockedQueueing != 0.5 {
+		t.Errorf("typed extensions not decoded: %+v", entry)
+	}
+	if string(entry.Extras["_connectionId"]) != `"42"` || string(entry.Initiator.Extras["columnNumber"]) != "7" {
+		t.Errorf("unexpected extras %v, %v", entry.Extras, entry.Initiator.Extras)
+	}
+	if _, ok := entry.Extras["_priority"]; ok {
+		t.Error("typed extension also kept in extras")
+	}
+
+	// transform, then encode
+	har.Log.Entries[0].Request.URL = "https://example.org/"
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, har); err != nil {
+		t.Fatal(err)
+	}
+
+	var tree map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &tree); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	get := func(path ...interface{}) interface{} {
+		var v interface{} = tree
+		for _, p := range path {
+			switch p := p.(type) {
+			case string:
+				v = v.(map[string]interface{})[p]
+			case int:
+				v = v.([]interface{})[p]
+			}
+		}
+		return v
+	}
+
+	for _, test := range []struct {
+		path     []interface{}
+		expected interface{}
+	}{
+		{[]interface{}{"_signature"}, "abc"},
+		{[]interface{}{"log", "_origin"}, "devtools"},
+		{[]interface{}{"log", "entries", 0, "_connectionId"}, "42"},
+		{[]interface{}{"log", "entries", 0, "_priority"}, "VeryHigh"},
+		{[]interface{}{"log", "entries", 0, "_initiator", "columnNumber"}, 7.0},
+		{[]interface{}{"log", "entries", 0, "request", "url"}, "https://example.org/"},
+		{[]interface{}{"log", "entries", 0, "request", "headers", 0, "_hidden"}, true},
+		{[]interface{}{"log", "entries", 0, "response", "_workerRespondWithSettled"}, 1.5},
+		{[]interface{}{"log", "entries", 0, "response", "content", "_encodedDataLength"}, 123.0},
+		{[]interface{}{"log", "entries", 0, "timings", "_workerStart"}, -1.0},
+	} {
+		if got := get(test.path...); got != test.expected {
+			t.Errorf("%v = %v, expected %v", test.path, got, test.expected)
+		}
+	}
+}
+
+func TestMarshalExtrasKnownFieldsWin(t *testing.T) {
+	nvp := NVP{Name: "a", Value: "b", Extras: map[string]json.RawMessage{"name": json.RawMessage(`"stale"`), "_x": json.RawMessage(`1`)}}
+	data, err := json.Marshal(nvp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"name":"a","value":"b","_x":1}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+}
+
+func TestMarshalExtrasOmittedField(t *testing.T) {
+	nvp := NVP{Name: "a", Value: "b", Extras: map[string]json.RawMessage{"comment": json.RawMessage(`""`)}}
+	data, err := json.Marshal(nvp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"name":"a","value":"b","comment":""}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+}
+
+func TestPageTimingsRoundTrip(t *testing.T) {
+	var page Page
+	if err := json.Unmarshal([]byte(`{"id":"page_1","pageTimings":{"onContentLoad":120.5,"onLoad":-1}}`), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.PageTiming.OnContentLoad != 120.5 || page.PageTiming.OnLoad != -1 {
+		t.Errorf("got %+v", page.PageTiming)
+	}
+	data, err := json.Marshal(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"pageTimings":{"onContentLoad":120.5,"onLoad":-1,"comment":""}`) {
+		t.Errorf("unexpected JSON %s", data)
+	}
+
+	// written by hargo before it used the spec's key
+	page = Page{}
+	if err := json.Unmarshal([]byte(`{"id":"page_1","pageTiming":{"onContentLoad":100,"onLoad":200}}`), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.PageTiming.OnContentLoad != 100 || page.PageTiming.OnLoad != 200 || page.Extras != nil {
+		t.Errorf("got %+v", page)
+	}
+}
+
+func TestEntryTimingsRoundTrip(t *testing.T) {
+	var entry Entry
+	data := `{"startedDateTime":"2024-01-02T10:00:00.000Z","time":12.75,"timings":{"blocked":0.5,"dns":-1,"connect":-1,"send":0.25,"wait":10.5,"receive":1.5,"_blocked_queueing":0.25}}`
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		t.Fatal(err)
+	}
+	expected := PageTimings{Blocked: 0.5, DNS: -1, Connect: -1, Send: 0.25, Wait: 10.5, Receive: 1.5, BlockedQueueing: 0.25}
+	if !reflect.DeepEqual(entry.Timings, expected) {
+		t.Errorf("got %+v", entry.Timings)
+	}
+
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Entry
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Timings, expected) || !strings.Contains(string(encoded), `"timings":{"blocked":0.5,`) || strings.Contains(string(encoded), "pageTimings") {
+		t.Errorf("unexpected JSON %s", encoded)
+	}
+
+	// written by hargo before it used the spec's key, with whole milliseconds
+	entry = Entry{}
+	if err := json.Unmarshal([]byte(`{"pageTimings":{"send":1,"wait":20,"receive":3}}`), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Timings.Send != 1 || entry.Timings.Wait != 20 || entry.Timings.Receive != 3 || entry.Extras != nil {
+		t.Errorf("got %+v", entry)
+	}
+}
@@ -2,6 +2,7 @@ package hargo
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -17,6 +18,31 @@ import (
 
 // Fetch downloads all resources references in .har file
 func Fetch(r *bufio.Reader) error {
+	return FetchContext(context.Background(), r)
+}
+
+// FetchContext is like Fetch, but stops when ctx is done, aborting the
+// download in progress.
+func FetchContext(ctx context.Context, r *bufio.Reader) error {
+	return FetchConfig(ctx, r, Config{})
+}
+
+// FetchConfig is like FetchContext, but connects with the transport and
+// TLS settings of a scenario Config, e.g. through its proxy, and sets the
+// credential header of its Auth provider or AuthCommand.
+func FetchConfig(ctx context.Context, r *bufio.Reader, cfg Config) error {
+	if err := requireNetwork("fetch"); err != nil {
+		return err
+	}
+
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.Auth, err = cfg.authProvider(); err != nil {
+		return err
+	}
+
 	har, err := Decode(r)
 
 	check(err)
@@ -28,13 +54,25 @@ func Fetch(r *bufio.Reader) error {
 
 	check(err)
 
-	for _, entry := range har.Log.Entries {
+	emitPhase(EventPhaseStarted, "fetch")
+	defer emitPhase(EventPhaseFinished, "fetch")
+
+	for i, entry := range har.Log.Entries {
 
 		//TODO create goroutine here to parallelize requests
 
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// WebSocket connections cannot be fetched over HTTP
+		if entry.IsWebSocket() {
+			continue
+		}
+
 		fmt.Println("URL: " + entry.Request.URL)
 
-		req, _ := http.NewRequest(entry.Request.Method, entry.Request.URL, nil)
+		req, _ := http.NewRequestWithContext(ctx, entry.Request.Method, entry.Request.URL, nil)
 
 		for _, h := range entry.Request.Headers {
 			if !strings.HasPrefix(h.Name, ":") {
@@ -47,21 +85,27 @@ func Fetch(r *bufio.Reader) error {
 			req.AddCookie(cookie)
 		}
 
+		applyAuth(ctx, req, entry, cfg)
+
 		//cookie := &http.Cookie{Name: "_hargo", Value: "true", HttpOnly: false}
 		//req.AddCookie(cookie)
 
-		err = downloadFile(req, outdir)
+		fileName, size, err := downloadFile(req, outdir, transport)
 
 		if err != nil {
 			log.Error(err)
+			emit(Event{Type: EventError, Phase: "fetch", Index: i, URL: entry.Request.URL, Err: err})
 			return err
 		}
+
+		emit(Event{Type: EventEntryFetched, Phase: "fetch", Index: i, URL: entry.Request.URL,
+			Method: entry.Request.Method, Path: fileName, Bytes: size})
 	}
 
 	return nil
 }
 
-func downloadFile(req *http.Request, outdir string) error {
+func downloadFile(req *http.Request, outdir string, transport http.RoundTripper) (string, int64, error) {
 
 	fileName := path.Base(req.URL.Path)
 
@@ -72,14 +116,14 @@ func downloadFile(req *http.Request, outdir string) error {
 	fileName = outdir + string(filepath.Separator) + fileName
 
 	if len(fileName) == 0 {
-		return nil
+		return "", 0, nil
 	}
 
 	file, err := os.Create(fileName)
 
 	if err != nil {
 		log.Error(err)
-		return err
+		return fileName, 0, err
 	}
 	defer file.Close()
 
@@ -92,7 +136,8 @@ func downloadFile(req *http.Request, outdir string) error {
 			r.URL.Opaque = r.URL.Path
 			return nil
 		},
-		Jar: jar,
+		Jar:       jar,
+		Transport: transport,
 	}
 
 	// spew.Dump(client)
@@ -102,7 +147,7 @@ func downloadFile(req *http.Request, outdir string) error {
 
 	if err != nil {
 		log.Error(err)
-		return err
+		return fileName, 0, err
 	}
 	defer resp.Body.Close()
 
@@ -110,9 +155,9 @@ func downloadFile(req *http.Request, outdir string) error {
 
 	if err != nil {
 		log.Error(err)
-		return err
+		return fileName, 0, err
 	}
 
 	fmt.Printf("Downloaded %s [%v bytes]\n", fileName, size)
-	return nil
+	return fileName, size, nil
 }
@@ -0,0 +1,41 @@
+package hargo
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchSkipsWebSockets(t *testing.T) {
+	defer func() {
+		matches, _ := filepath.Glob("./hargo-fetch-*")
+		for _, match := range matches {
+			os.RemoveAll(match)
+		}
+	}()
+
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		io.WriteString(w, "[]")
+	}))
+	defer server.Close()
+
+	data, err := os.ReadFile("test/websocket.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	har := strings.ReplaceAll(string(data), "http://example.com", server.URL)
+	if err := FetchContext(context.Background(), bufio.NewReader(strings.NewReader(har))); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != "/messages" {
+		t.Errorf("got requests %v", paths)
+	}
+}
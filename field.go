@@ -0,0 +1,214 @@
+package hargo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// fieldStep is one step of a field path: an object member, an array
+// element or every element of an array.
+type fieldStep struct {
+	key   string
+	index int
+	// array is set for [N] and [] steps, each for [].
+	array bool
+	each  bool
+}
+
+// FieldPath selects values of a JSON document, like a jq path without
+// filters: object members separated by dots, [N] for the Nth element of an
+// array and [] for all of them, e.g. log.entries[].response.status.
+type FieldPath []fieldStep
+
+// ParseFieldPath parses a field path. A leading dot, as in jq, is optional.
+func ParseFieldPath(s string) (FieldPath, error) {
+	var path FieldPath
+	rest := strings.TrimPrefix(strings.TrimSpace(s), ".")
+	if rest == "" {
+		return nil, fmt.Errorf("empty field path")
+	}
+	for rest != "" {
+		switch {
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid field path %q: missing ]", s)
+			}
+			if inner := rest[1:end]; inner == "" {
+				path = append(path, fieldStep{array: true, each: true})
+			} else {
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("invalid field path %q: bad index %q", s, inner)
+				}
+				path = append(path, fieldStep{array: true, index: n})
+			}
+			rest = rest[end+1:]
+		case rest[0] == '.':
+			rest = rest[1:]
+			if rest == "" || rest[0] == '.' || rest[0] == '[' {
+				return nil, fmt.Errorf("invalid field path %q: missing name after .", s)
+			}
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			path = append(path, fieldStep{key: rest[:end]})
+			rest = rest[end:]
+		}
+	}
+	return path, nil
+}
+
+// String returns the path in the syntax ParseFieldPath accepts.
+func (p FieldPath) String() string {
+	var b strings.Builder
+	for i, step := range p {
+		switch {
+		case step.each:
+			b.WriteString("[]")
+		case step.array:
+			fmt.Fprintf(&b, "[%d]", step.index)
+		default:
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(step.key)
+		}
+	}
+	return b.String()
+}
+
+// Stream calls fn with every value the path selects in the JSON document
+// read from r, in document order. Only the selected values are decoded, so
+// fields of the entries of a large .har file can be read without holding
+// the file in memory. Numbers are json.Number.
+func (p FieldPath) Stream(r io.Reader, fn func(interface{}) error) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return streamField(dec, p, fn)
+}
+
+// streamField consumes the next value of dec, calling fn with the values
+// path selects in it. Values the path does not apply to, like a member of
+// a string, select nothing.
+func streamField(dec *json.Decoder, path FieldPath, fn func(interface{}) error) error {
+	if len(path) == 0 {
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		return fn(v)
+	}
+
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	step := path[0]
+	switch t {
+	case json.Delim('{'):
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if !step.array && key == step.key {
+				err = streamField(dec, path[1:], fn)
+			} else {
+				err = skipValue(dec)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return expectDelim(dec, '}')
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if step.array && (step.each || i == step.index) {
+				err = streamField(dec, path[1:], fn)
+			} else {
+				err = skipValue(dec)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return expectDelim(dec, ']')
+	}
+	return nil
+}
+
+// FieldOptions controls ExtractField.
+type FieldOptions struct {
+	// Count prints every distinct value once with the number of times it
+	// was selected, most frequent first, instead of every value.
+	Count bool
+}
+
+// ExtractField prints the values path selects in a .har file to w, one per
+// line, and returns their number. Strings are printed as is, other values
+// as compact JSON.
+func ExtractField(r *bufio.Reader, w io.Writer, path string, opts FieldOptions) (int, error) {
+	p, err := ParseFieldPath(path)
+	if err != nil {
+		return 0, err
+	}
+
+	bw := bufio.NewWriter(w)
+	counts := make(map[string]int)
+	n := 0
+	err = p.Stream(r, func(v interface{}) error {
+		n++
+		s, err := fieldString(v)
+		if err != nil {
+			return err
+		}
+		if opts.Count {
+			counts[s]++
+			return nil
+		}
+		_, err = fmt.Fprintln(bw, s)
+		return err
+	})
+	if err != nil {
+		return n, err
+	}
+
+	if opts.Count {
+		values := make([]string, 0, len(counts))
+		for s := range counts {
+			values = append(values, s)
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if counts[values[i]] != counts[values[j]] {
+				return counts[values[i]] > counts[values[j]]
+			}
+			return values[i] < values[j]
+		})
+		tw := tabwriter.NewWriter(bw, 0, 0, 2, ' ', 0)
+		for _, s := range values {
+			fmt.Fprintf(tw, "%d\t%s\n", counts[s], s)
+		}
+		if err := tw.Flush(); err != nil {
+			return n, err
+		}
+	}
+	return n, bw.Flush()
+}
+
+// fieldString formats a selected value for printing.
+func fieldString(v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
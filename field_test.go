@@ -0,0 +1,76 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseFieldPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+		valid    bool
+	}{
+		{"log.entries[].response.content.mimeType", "log.entries[].response.content.mimeType", true},
+		{".log.pages[0].id", "log.pages[0].id", true},
+		{"log.entries[]._initiator.type", "log.entries[]._initiator.type", true},
+		{"[2]", "[2]", true},
+		{"", "", false},
+		{"log..entries", "", false},
+		{"log.", "", false},
+		{"log.entries[", "", false},
+		{"log.entries[x]", "", false},
+		{"log.entries[-1]", "", false},
+	}
+
+	for i, test := range tests {
+		p, err := ParseFieldPath(test.path)
+		if (err == nil) != test.valid {
+			t.Errorf("%d: got error %v", i, err)
+			continue
+		}
+		if err == nil && p.String() != test.expected {
+			t.Errorf("%d: got %s, want %s", i, p, test.expected)
+		}
+	}
+}
+
+func TestExtractField(t *testing.T) {
+	const harData = `{"log": {"version": "1.2", "pages": [{"id": "p1"}], "entries": [
+		{"request": {"url": "https://example.com/"}, "response": {"status": 200, "content": {"mimeType": "text/html"}}, "_initiator": {"type": "parser"}},
+		{"request": {"url": "https://example.com/a.js"}, "response": {"status": 200, "content": {"mimeType": "application/javascript"}}},
+		{"request": {"url": "https://example.com/b.js"}, "response": {"status": 404, "content": {"mimeType": "application/javascript", "size": 1.5}}}]}}`
+
+	tests := []struct {
+		path     string
+		opts     FieldOptions
+		expected string
+		n        int
+	}{
+		{"log.entries[].response.content.mimeType", FieldOptions{}, "text/html\napplication/javascript\napplication/javascript\n", 3},
+		{"log.entries[].response.status", FieldOptions{Count: true}, "2  200\n1  404\n", 3},
+		{"log.entries[2].response.content", FieldOptions{}, `{"mimeType":"application/javascript","size":1.5}` + "\n", 1},
+		// members only some entries have, and paths into scalars, select nothing
+		{"log.entries[]._initiator.type", FieldOptions{}, "parser\n", 1},
+		{"log.version[].x", FieldOptions{}, "", 0},
+		{"log.pages[].id", FieldOptions{}, "p1\n", 1},
+	}
+
+	for i, test := range tests {
+		var buf bytes.Buffer
+		n, err := ExtractField(bufio.NewReader(strings.NewReader(harData)), &buf, test.path, test.opts)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if n != test.n || buf.String() != test.expected {
+			t.Errorf("%d: got %d values %q, want %d %q", i, n, buf.String(), test.n, test.expected)
+		}
+	}
+
+	if _, err := ExtractField(bufio.NewReader(strings.NewReader(`{"log": {"entries": [}}`)), &bytes.Buffer{}, "log.entries[].x", FieldOptions{}); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
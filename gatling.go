@@ -0,0 +1,166 @@
+package hargo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// GatlingOptions controls the simulation written by ToGatling.
+type GatlingOptions struct {
+	// NoThinkTime leaves out the pauses between requests.
+	NoThinkTime bool
+	// Users is the number of users injected at once, 1 if zero.
+	Users int
+	// ClassName is the name of the simulation class, RecordedSimulation if
+	// empty.
+	ClassName string
+}
+
+// gatlingMethods are the HTTP methods with their own Gatling DSL method.
+var gatlingMethods = map[string]string{
+	"GET": "get", "POST": "post", "PUT": "put", "PATCH": "patch",
+	"DELETE": "delete", "HEAD": "head", "OPTIONS": "options",
+}
+
+// scalaString quotes s as a Scala string literal. Gatling Expression
+// Language markers (#{ and ${) are escaped so the value is sent verbatim.
+func scalaString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i, r := range s {
+		switch {
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case (r == '#' || r == '$') && strings.HasPrefix(s[i+1:], "{"):
+			b.WriteString(`\\`)
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x2028 || r == 0x2029:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// ToGatling writes a Gatling (3.x) Scala simulation that replays the
+// requests of har in order, grouped by page, pausing for the recorded gaps
+// between requests and checking the recorded status codes. Redirects are
+// not followed, as the capture contains the redirected requests.
+func ToGatling(har Har, w io.Writer, opts GatlingOptions) error {
+	users := opts.Users
+	if users <= 0 {
+		users = 1
+	}
+	className := opts.ClassName
+	if className == "" {
+		className = "RecordedSimulation"
+	}
+
+	var b strings.Builder
+	b.WriteString("// Generated by hargo from a .har capture.\n")
+	b.WriteString("import scala.concurrent.duration._\n\n")
+	b.WriteString("import io.gatling.core.Predef._\n")
+	b.WriteString("import io.gatling.http.Predef._\n\n")
+	fmt.Fprintf(&b, "class %s extends Simulation {\n\n", className)
+	b.WriteString("  val httpProtocol = http\n    .disableFollowRedirect\n\n")
+	b.WriteString("  val scn = scenario(\"hargo\")\n")
+
+	// cookies that existed before the capture are added up front, the
+	// others are handled by Gatling's cookie jar
+	gaps := thinkTimes(har.Log.Entries)
+	setCookies := make(map[string]bool)
+	added := make(map[string]bool)
+	for _, entry := range har.Log.Entries {
+		if entry.IsWebSocket() {
+			continue
+		}
+		for _, c := range initialCookies(entry.Request, setCookies) {
+			if added[c.Name] {
+				continue
+			}
+			added[c.Name] = true
+			domain := ""
+			if u, err := url.Parse(entry.Request.URL); err == nil {
+				domain = u.Hostname()
+			}
+			fmt.Fprintf(&b, "    .exec(addCookie(Cookie(%s, %s).withDomain(%s)))\n",
+				scalaString(c.Name), scalaString(c.Value), scalaString(domain))
+		}
+		for _, c := range entry.Response.Cookies {
+			setCookies[c.Name] = true
+		}
+	}
+
+	for _, group := range scriptGroups(har) {
+		if first := group.entries[0]; !opts.NoThinkTime && gaps[first] > 0 {
+			fmt.Fprintf(&b, "    .pause(%d.milliseconds)\n", gaps[first].Milliseconds())
+		}
+		fmt.Fprintf(&b, "    .group(%s) {\n", scalaString(group.name))
+		for n, i := range group.entries {
+			entry := har.Log.Entries[i]
+			prefix := "      "
+			if n > 0 {
+				prefix = "        ."
+				if !opts.NoThinkTime && gaps[i] > 0 {
+					fmt.Fprintf(&b, "%spause(%d.milliseconds)\n", prefix, gaps[i].Milliseconds())
+				}
+			}
+
+			method := strings.ToUpper(entry.Request.Method)
+			request := fmt.Sprintf("http(%s)", scalaString("request_"+strconv.Itoa(i)))
+			if dsl, ok := gatlingMethods[method]; ok {
+				request += fmt.Sprintf(".%s(%s)", dsl, scalaString(entry.Request.URL))
+			} else {
+				request += fmt.Sprintf(".httpRequest(%s, %s)", scalaString(method), scalaString(entry.Request.URL))
+			}
+			fmt.Fprintf(&b, "%sexec(\n          %s\n", prefix, request)
+			if headers := scriptHeaders(entry.Request); len(headers) > 0 {
+				var pairs []string
+				for _, h := range headers {
+					pairs = append(pairs, fmt.Sprintf("              %s -> %s", scalaString(h.Name), scalaString(h.Value)))
+				}
+				fmt.Fprintf(&b, "            .headers(Map(\n%s\n            ))\n", strings.Join(pairs, ",\n"))
+			}
+			if body := scriptBody(entry.Request); body != "" {
+				fmt.Fprintf(&b, "            .body(StringBody(%s))\n", scalaString(body))
+			}
+			if entry.Response.Status > 0 {
+				fmt.Fprintf(&b, "            .check(status.is(%d))\n", entry.Response.Status)
+			}
+			b.WriteString("        )\n")
+		}
+		b.WriteString("    }\n")
+	}
+
+	fmt.Fprintf(&b, "\n  setUp(scn.inject(atOnceUsers(%d))).protocols(httpProtocol)\n}\n", users)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ToGatlingFile converts a .har file to a Gatling simulation written to w.
+func ToGatlingFile(r *bufio.Reader, w io.Writer, opts GatlingOptions) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Converted %d entries to a Gatling simulation", len(har.Log.Entries))
+	return ToGatling(har, w, opts)
+}
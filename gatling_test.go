@@ -0,0 +1,42 @@
+package hargo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestScalaString(t *testing.T) {
+	if s := scalaString("a\"b\\c\nd #{id} ${x} #"); s != `"a\"b\\c\nd \\#{id} \\${x} #"` {
+		t.Errorf("unexpected literal %s", s)
+	}
+}
+
+func TestToGatling(t *testing.T) {
+	var out bytes.Buffer
+	if err := ToGatling(scriptTestHar(), &out, GatlingOptions{Users: 5}); err != nil {
+		t.Fatal(err)
+	}
+	simulation := out.String()
+
+	for _, expected := range []string{
+		"class RecordedSimulation extends Simulation {",
+		".disableFollowRedirect",
+		`.exec(addCookie(Cookie("pref", "1").withDomain("example.com")))`,
+		`.group("Home") {`,
+		`http("request_0").get("https://example.com/")`,
+		`"Accept" -> "text/html"`,
+		".pause(1500.milliseconds)",
+		`http("request_1").post("https://example.com/api")`,
+		`.body(StringBody("{\"q\":\"</script>\"}"))`,
+		".check(status.is(201))",
+		"setUp(scn.inject(atOnceUsers(5))).protocols(httpProtocol)",
+	} {
+		if !strings.Contains(simulation, expected) {
+			t.Errorf("simulation does not contain %s:\n%s", expected, simulation)
+		}
+	}
+	if strings.Contains(simulation, "session") {
+		t.Errorf("simulation sets a cookie of the capture:\n%s", simulation)
+	}
+}
@@ -3,6 +3,7 @@ module github.com/mrichman/hargo
 go 1.22
 
 require (
+	github.com/alessio/shellescape v1.4.2
 	github.com/influxdata/influxdb1-client v0.0.0-20220302092344-a9ab5670611c
 	github.com/sirupsen/logrus v1.8.1
 	github.com/urfave/cli v1.21.0
@@ -10,7 +11,6 @@ require (
 )
 
 require (
-	github.com/alessio/shellescape v1.4.2 // indirect
 	github.com/blang/semver v3.5.1+incompatible // indirect
 	golang.org/x/sys v0.0.0-20220408201424-a24fb2fb8a0f // indirect
 	golang.org/x/text v0.3.7 // indirect
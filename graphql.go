@@ -0,0 +1,309 @@
+package hargo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// GraphQLOperation is a GraphQL operation sent in a request.
+type GraphQLOperation struct {
+	// Name is the operationName, or the name in the query document, or
+	// "anonymous".
+	Name string
+	// Type is query, mutation or subscription, or "" for persisted queries
+	// sent without a document.
+	Type  string
+	Query string
+}
+
+// graphQLRequest is the JSON body of a GraphQL request over HTTP.
+type graphQLRequest struct {
+	Query         *string         `json:"query"`
+	OperationName *string         `json:"operationName"`
+	Extensions    json.RawMessage `json:"extensions"`
+}
+
+var graphQLDefinition = regexp.MustCompile(`^(query|mutation|subscription)\b\s*([_A-Za-z][_0-9A-Za-z]*)?`)
+
+var graphQLComment = regexp.MustCompile(`#[^\n\r]*`)
+
+// GraphQLOperations returns the GraphQL operations of entry, one per
+// operation of a batched request, or nil if it is not a GraphQL request.
+// Operations are read from JSON bodies ({"query", "operationName"}, or an
+// array of them), application/graphql bodies and the query and
+// operationName parameters of GET requests.
+func GraphQLOperations(entry Entry) []GraphQLOperation {
+	req := entry.Request
+
+	if strings.EqualFold(req.Method, "GET") {
+		u, err := url.Parse(req.URL)
+		if err != nil {
+			return nil
+		}
+		query := u.Query()
+		if !query.Has("query") && !(query.Has("operationName") && query.Has("extensions")) {
+			return nil
+		}
+		return []GraphQLOperation{newGraphQLOperation(query.Get("query"), query.Get("operationName"))}
+	}
+
+	body := strings.TrimSpace(req.PostData.Text)
+	if body == "" {
+		return nil
+	}
+	if strings.Contains(strings.ToLower(req.PostData.MimeType), "application/graphql") {
+		return []GraphQLOperation{newGraphQLOperation(body, "")}
+	}
+
+	var requests []graphQLRequest
+	if strings.HasPrefix(body, "[") {
+		if err := json.Unmarshal([]byte(body), &requests); err != nil {
+			return nil
+		}
+	} else {
+		var single graphQLRequest
+		if err := json.Unmarshal([]byte(body), &single); err != nil {
+			return nil
+		}
+		requests = []graphQLRequest{single}
+	}
+
+	var ops []GraphQLOperation
+	for _, r := range requests {
+		persisted := r.OperationName != nil && len(r.Extensions) > 0
+		if r.Query == nil && !persisted {
+			return nil
+		}
+		var query, name string
+		if r.Query != nil {
+			query = *r.Query
+		}
+		if r.OperationName != nil {
+			name = *r.OperationName
+		}
+		ops = append(ops, newGraphQLOperation(query, name))
+	}
+	return ops
+}
+
+// newGraphQLOperation determines the type of the operation called name in
+// the query document, and its name if none is given. Without an
+// operationName the first operation of the document is the one executed.
+func newGraphQLOperation(query, name string) GraphQLOperation {
+	op := GraphQLOperation{Name: name, Query: query}
+	doc := strings.TrimSpace(graphQLComment.ReplaceAllString(query, ""))
+	if doc == "" {
+		if op.Name == "" {
+			op.Name = "anonymous"
+		}
+		return op
+	}
+
+	op.Type = "query"
+	for _, def := range graphQLDefinitions(doc) {
+		m := graphQLDefinition.FindStringSubmatch(def)
+		if m != nil && (name == "" || m[2] == name) {
+			op.Type = m[1]
+			if op.Name == "" {
+				op.Name = m[2]
+			}
+			break
+		}
+	}
+	if op.Name == "" {
+		op.Name = "anonymous"
+	}
+	return op
+}
+
+// graphQLDefinitions returns the text of every top level definition of a
+// query document, e.g. "query GetUser($id: ID!)" and "fragment F on User",
+// skipping selection sets and strings.
+func graphQLDefinitions(doc string) []string {
+	var defs []string
+	depth, start, inString := 0, 0, false
+	for i := 0; i < len(doc); i++ {
+		switch c := doc[i]; {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{':
+			if depth == 0 {
+				defs = append(defs, strings.TrimSpace(doc[start:i]))
+			}
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				start = i + 1
+			}
+		}
+	}
+	return defs
+}
+
+// graphQLFilename names the response of a GraphQL request after its
+// operations, e.g. GetUser.json, or GetUser+3.json for a batch of four.
+func graphQLFilename(ops []GraphQLOperation, mimeType string) string {
+	name := ops[0].Name
+	if len(ops) > 1 {
+		name += fmt.Sprintf("+%d", len(ops)-1)
+	}
+	return sanitizeFilename(name + getExtensionFromMimeType(mimeType))
+}
+
+// graphQLResponse is the JSON body of a GraphQL response.
+type graphQLResponse struct {
+	Errors []json.RawMessage `json:"errors"`
+}
+
+// graphQLErrors returns for each of n operations whether its response
+// reported errors, read from the (batched) JSON response of entry.
+func graphQLErrors(entry Entry, n int) []bool {
+	failed := make([]bool, n)
+	if entry.Response.Status >= 400 {
+		for i := range failed {
+			failed[i] = true
+		}
+		return failed
+	}
+
+	body, err := entry.DecodedBody()
+	if err != nil {
+		return failed
+	}
+	var responses []graphQLResponse
+	if err := json.Unmarshal(body, &responses); err != nil {
+		var single graphQLResponse
+		if json.Unmarshal(body, &single) != nil {
+			return failed
+		}
+		responses = []graphQLResponse{single}
+	}
+	for i := range failed {
+		if i < len(responses) {
+			failed[i] = len(responses[i].Errors) > 0
+		}
+	}
+	return failed
+}
+
+// GraphQLStats summarizes the requests of one GraphQL operation.
+type GraphQLStats struct {
+	Name     string
+	Type     string
+	Requests int
+	// Errors counts the responses with errors or an HTTP error status.
+	Errors int
+	// Endpoints lists the URLs (without query) the operation was sent to.
+	Endpoints []string
+	// TotalTime is the sum of the times of the entries, in milliseconds.
+	// Operations of a batch share the time of their entry.
+	TotalTime float64
+	// Received is the size of the responses, shared the same way.
+	Received int64
+}
+
+// AverageTime returns the mean time of the operation's requests in
+// milliseconds.
+func (s GraphQLStats) AverageTime() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return s.TotalTime / float64(s.Requests)
+}
+
+// AnalyzeGraphQL returns the GraphQL operations found in har, most
+// frequent first.
+func AnalyzeGraphQL(har Har) []GraphQLStats {
+	byOp := make(map[string]*GraphQLStats)
+	var stats []*GraphQLStats
+
+	for _, entry := range har.Log.Entries {
+		ops := GraphQLOperations(entry)
+		if len(ops) == 0 {
+			continue
+		}
+		failed := graphQLErrors(entry, len(ops))
+		endpoint := entry.Request.URL
+		if u, err := url.Parse(endpoint); err == nil {
+			u.RawQuery, u.Fragment = "", ""
+			endpoint = u.String()
+		}
+
+		for i, op := range ops {
+			key := op.Type + " " + op.Name
+			s := byOp[key]
+			if s == nil {
+				s = &GraphQLStats{Name: op.Name, Type: op.Type}
+				byOp[key] = s
+				stats = append(stats, s)
+			}
+			s.Requests++
+			if failed[i] {
+				s.Errors++
+			}
+			if !containsString(s.Endpoints, endpoint) {
+				s.Endpoints = append(s.Endpoints, endpoint)
+			}
+			s.TotalTime += float64(entry.Time) / float64(len(ops))
+			s.Received += responseBytes(entry.Response) / int64(len(ops))
+		}
+	}
+
+	result := make([]GraphQLStats, len(stats))
+	for i, s := range stats {
+		result[i] = *s
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Requests > result[j].Requests
+	})
+	return result
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// GraphQL prints a summary of the GraphQL operations of a .har file to w.
+func GraphQL(r *bufio.Reader, w io.Writer) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	stats := AnalyzeGraphQL(har)
+	if len(stats) == 0 {
+		fmt.Fprintln(w, "No GraphQL requests found")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", "Operation", "Type", "Requests", "Errors", "Avg time (ms)", "Received (bytes)", "Endpoint")
+	for _, s := range stats {
+		opType := s.Type
+		if opType == "" {
+			opType = "persisted"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.1f\t%d\t%s\t\n", s.Name, opType, s.Requests, s.Errors,
+			s.AverageTime(), s.Received, strings.Join(s.Endpoints, ", "))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,81 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func graphQLEntry(body, response string) Entry {
+	return Entry{
+		Time:     10,
+		Request:  Request{Method: "POST", URL: "https://example.com/graphql", PostData: PostData{MimeType: "application/json", Text: body}},
+		Response: Response{Status: 200, Content: Content{MimeType: "application/json", Text: response}},
+	}
+}
+
+func TestGraphQLOperations(t *testing.T) {
+	tests := []struct {
+		entry    Entry
+		expected []GraphQLOperation
+	}{
+		{graphQLEntry(`{"query":"# comment\nquery GetUser($id: ID!) { user(id: $id) { name } }"}`, ""),
+			[]GraphQLOperation{{Name: "GetUser", Type: "query"}}},
+		{graphQLEntry(`{"query":"fragment F on User { id } mutation Save { save { ...F } }","operationName":"Save"}`, ""),
+			[]GraphQLOperation{{Name: "Save", Type: "mutation"}}},
+		{graphQLEntry(`{"query":"{ viewer { login } }"}`, ""),
+			[]GraphQLOperation{{Name: "anonymous", Type: "query"}}},
+		{graphQLEntry(`[{"query":"query A { a }"},{"query":"subscription B { b }"}]`, ""),
+			[]GraphQLOperation{{Name: "A", Type: "query"}, {Name: "B", Type: "subscription"}}},
+		{graphQLEntry(`{"operationName":"Feed","extensions":{"persistedQuery":{"version":1}}}`, ""),
+			[]GraphQLOperation{{Name: "Feed"}}},
+		{Entry{Request: Request{Method: "GET", URL: "https://example.com/graphql?query=query%20Me%20%7B%20me%20%7D"}},
+			[]GraphQLOperation{{Name: "Me", Type: "query"}}},
+		{graphQLEntry(`{"name":"not graphql"}`, ""), nil},
+	}
+
+	for i, test := range tests {
+		ops := GraphQLOperations(test.entry)
+		if len(ops) != len(test.expected) {
+			t.Errorf("%d: expected %d operations, got %+v", i, len(test.expected), ops)
+			continue
+		}
+		for j, op := range ops {
+			if op.Name != test.expected[j].Name || op.Type != test.expected[j].Type {
+				t.Errorf("%d: expected %+v, got %+v", i, test.expected[j], op)
+			}
+		}
+	}
+
+	if name := graphQLFilename([]GraphQLOperation{{Name: "A"}, {Name: "B"}}, "application/json"); name != "A+1.json" {
+		t.Errorf("unexpected filename %s", name)
+	}
+}
+
+func TestGraphQLReport(t *testing.T) {
+	harData, _ := json.Marshal(Har{Log: Log{Entries: []Entry{
+		graphQLEntry(`{"query":"query GetUser { user }"}`, `{"data":{}}`),
+		graphQLEntry(`{"query":"query GetUser { user }"}`, `{"errors":[{"message":"boom"}]}`),
+		graphQLEntry(`{"query":"mutation Save { save }"}`, `{"data":{}}`),
+		{Request: Request{Method: "GET", URL: "https://example.com/"}},
+	}}})
+
+	har, _ := Decode(bufio.NewReader(bytes.NewReader(harData)))
+	stats := AnalyzeGraphQL(har)
+	if len(stats) != 2 || stats[0].Name != "GetUser" || stats[0].Requests != 2 || stats[0].Errors != 1 || stats[0].AverageTime() != 10 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if stats[1].Type != "mutation" || stats[1].Endpoints[0] != "https://example.com/graphql" {
+		t.Errorf("unexpected stats %+v", stats[1])
+	}
+
+	var out bytes.Buffer
+	if err := GraphQL(bufio.NewReader(bytes.NewReader(harData)), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "GetUser") || !strings.Contains(out.String(), "mutation") {
+		t.Errorf("unexpected report:\n%s", out.String())
+	}
+}
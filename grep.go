@@ -0,0 +1,166 @@
+package hargo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+// GrepOptions controls Grep.
+type GrepOptions struct {
+	// IgnoreCase matches the pattern regardless of case.
+	IgnoreCase bool
+	// Context is the number of characters shown before and after a match.
+	// Zero means 30.
+	Context int
+	// Matches, if set, receives a .har file of the matching entries and
+	// the pages they refer to.
+	Matches io.Writer
+	// Selection restricts the search to the selected entries, which are
+	// still numbered by their position in the file.
+	Selection Selection
+	// Decoders also searches the response bodies they decode, e.g. the
+	// messages of gRPC calls. Nil means the DefaultBodyDecoders.
+	Decoders *BodyDecoders
+}
+
+// GrepMatch locates a match of a Grep pattern in an entry.
+type GrepMatch struct {
+	// Entry is the index of the entry in the .har file.
+	Entry int    `json:"entry"`
+	URL   string `json:"url"`
+	// Location is the field containing the match, named as in
+	// SecretMatch, e.g. request.headers[Accept] or response.content.text.
+	Location string `json:"location"`
+	// Context is the match with the text around it, on a single line.
+	Context string `json:"context"`
+}
+
+// grepContext is the default number of characters around a match.
+const grepContext = 30
+
+// GrepEntry returns the matches of re in the URL, headers and decoded text
+// bodies of e, the entry at index i.
+func GrepEntry(i int, e Entry, re *regexp.Regexp, context int) []GrepMatch {
+	return grepEntry(i, e, re, context, nil)
+}
+
+// grepEntry is GrepEntry also searching the response body as decoded by
+// decoders, at response.content.decoded.
+func grepEntry(i int, e Entry, re *regexp.Regexp, context int, decoders *BodyDecoders) []GrepMatch {
+	if context <= 0 {
+		context = grepContext
+	}
+
+	var matches []GrepMatch
+	search := func(location, value string) {
+		for _, m := range re.FindAllStringIndex(value, -1) {
+			matches = append(matches, GrepMatch{Entry: i, URL: e.Request.URL, Location: location, Context: grepExcerpt(value, m[0], m[1], context)})
+		}
+	}
+
+	search("request.url", e.Request.URL)
+	for _, h := range e.Request.Headers {
+		search("request.headers["+h.Name+"]", h.Name+": "+h.Value)
+	}
+	search("request.postData.text", e.Request.PostData.Text)
+	for _, h := range e.Response.Headers {
+		search("response.headers["+h.Name+"]", h.Name+": "+h.Value)
+	}
+	if e.Response.Content.Text != "" && isTextMimeType(e.Response.Content.MimeType) {
+		if body, err := e.DecodedBody(); err == nil {
+			search("response.content.text", string(body))
+		}
+	}
+	if _, decoded, err := decoders.Decode(e); err == nil && len(decoded) > 0 {
+		search("response.content.decoded", string(decoded))
+	}
+	return matches
+}
+
+// grepExcerpt returns value[start:end] with up to context characters on
+// each side, with line breaks and tabs replaced by spaces.
+func grepExcerpt(value string, start, end, context int) string {
+	from, to := start, end
+	for n := 0; n < context && from > 0; n++ {
+		from--
+		for from > 0 && !utf8.RuneStart(value[from]) {
+			from--
+		}
+	}
+	for n := 0; n < context && to < len(value); n++ {
+		to++
+		for to < len(value) && !utf8.RuneStart(value[to]) {
+			to++
+		}
+	}
+
+	excerpt := strings.NewReplacer("\r", " ", "\n", " ", "\t", " ").Replace(value[from:to])
+	if from > 0 {
+		excerpt = "..." + excerpt
+	}
+	if to < len(value) {
+		excerpt += "..."
+	}
+	return excerpt
+}
+
+// Grep prints the entries of a .har file whose URL, headers or text bodies
+// match the regular expression pattern to w, with the entry index, where
+// the match occurred and the text around it, and returns the number of
+// matching entries.
+func Grep(r *bufio.Reader, w io.Writer, pattern string, opts GrepOptions) (int, error) {
+	if opts.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return 0, fmt.Errorf("invalid pattern: %v", err)
+	}
+
+	// entries are numbered in the order of the file, which Decode does
+	// not keep
+	har, err := readHar(r)
+	if err != nil {
+		return 0, err
+	}
+
+	decoders := opts.Decoders
+	if decoders == nil {
+		decoders = DefaultBodyDecoders(nil)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", "Entry", "URL", "Location", "Match")
+
+	matched := []Entry{}
+	for i, entry := range har.Log.Entries {
+		if !opts.Selection.Includes(i, entry) {
+			continue
+		}
+		matches := grepEntry(i, entry, re, opts.Context, decoders)
+		for _, m := range matches {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t\n", m.Entry, m.URL, m.Location, m.Context)
+		}
+		if len(matches) > 0 {
+			matched = append(matched, entry)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return 0, err
+	}
+	fmt.Fprintf(w, "\n%d of %d entries match\n", len(matched), len(har.Log.Entries))
+
+	if opts.Matches != nil {
+		har.Log.Entries = matched
+		har.Log.Pages = referencedPages(har.Log.Pages, matched)
+		if err := Encode(opts.Matches, har); err != nil {
+			return len(matched), err
+		}
+	}
+	return len(matched), nil
+}
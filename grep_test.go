@@ -0,0 +1,98 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGrepEntry(t *testing.T) {
+	entry := Entry{
+		Request: Request{URL: "https://example.com/users/42", Headers: []NVP{{Name: "Authorization", Value: "Bearer token42"}}},
+		Response: Response{
+			Headers: []NVP{{Name: "Content-Type", Value: "text/plain"}},
+			Content: Content{MimeType: "text/plain", Text: strings.Repeat("x", 50) + "user 42\nfound" + strings.Repeat("y", 50)},
+		},
+	}
+
+	matches := GrepEntry(3, entry, regexp.MustCompile(`42`), 5)
+	if len(matches) != 3 {
+		t.Fatalf("got %+v", matches)
+	}
+	if matches[0].Entry != 3 || matches[0].Location != "request.url" || matches[0].Context != "...sers/42" {
+		t.Errorf("got %+v", matches[0])
+	}
+	if matches[1].Location != "request.headers[Authorization]" || matches[1].Context != "...token42" {
+		t.Errorf("got %+v", matches[1])
+	}
+	if matches[2].Location != "response.content.text" || matches[2].Context != "...user 42 foun..." {
+		t.Errorf("got %+v", matches[2])
+	}
+
+	// multi-byte characters are not cut
+	if got := grepExcerpt("ééé match ééé", 7, 12, 2); got != "...é match é..." {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestGrep(t *testing.T) {
+	data, err := os.ReadFile("test/grep.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf, matches bytes.Buffer
+	count, err := Grep(bufio.NewReader(bytes.NewReader(data)), &buf, "ACME", GrepOptions{IgnoreCase: true, Matches: &matches})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the image is not searched, the search body is
+	if count != 2 || !strings.Contains(buf.String(), "2 of 3 entries match") {
+		t.Errorf("got %d matches: %s", count, buf.String())
+	}
+	expected := [][]string{
+		{"Entry", "URL", "Location", "Match"},
+		{"0", "https://example.com/api/orders", "request.headers[X-Tenant]", "X-Tenant: acme"},
+		{"0", "https://example.com/api/orders", "response.content.text", `...rder": "A-1001", "customer": "Acme Corp"}`},
+		{"2", "https://example.com/api/search", "request.postData.text", "q=acme limit=10"},
+	}
+	if rows := tableRows(buf.String()); !reflect.DeepEqual(rows, expected) {
+		t.Errorf("got %q, want %q", rows, expected)
+	}
+
+	var har Har
+	if err := json.Unmarshal(matches.Bytes(), &har); err != nil {
+		t.Fatal(err)
+	}
+	if len(har.Log.Entries) != 2 || len(har.Log.Pages) != 1 || har.Log.Pages[0].ID != "page_1" {
+		t.Errorf("got %+v", har.Log)
+	}
+
+	// entries keep their position in the file
+	buf.Reset()
+	count, err = Grep(bufio.NewReader(bytes.NewReader(data)), &buf, "search", GrepOptions{Selection: Selection{First: 2}})
+	if rows := tableRows(buf.String()); err != nil || count != 1 || len(rows) != 2 || rows[1][0] != "2" {
+		t.Errorf("got %d, %v: %q", count, err, rows)
+	}
+
+	if _, err := Grep(bufio.NewReader(bytes.NewReader(data)), &buf, "(", GrepOptions{}); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
+
+func TestGrepDecoded(t *testing.T) {
+	entry := grpcTestEntry("application/grpc-web+proto", "application/grpc-web+proto",
+		grpcFrameBytes(0, pbString(1, "ann")), grpcFrameBytes(0, pbString(1, "order A-1001 shipped")), nil)
+	data, _ := json.Marshal(Har{Log: Log{Entries: []Entry{entry}}})
+
+	var buf bytes.Buffer
+	count, err := Grep(bufio.NewReader(bytes.NewReader(data)), &buf, "A-1001", GrepOptions{})
+	if err != nil || count != 1 || !strings.Contains(buf.String(), "response.content.decoded") {
+		t.Errorf("got %d, %v: %s", count, err, buf.String())
+	}
+}
@@ -0,0 +1,227 @@
+package hargo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// GRPCCall is a gRPC or gRPC-Web call decoded from an entry.
+type GRPCCall struct {
+	// Method is the path of the method, e.g. /helloworld.Greeter/SayHello.
+	Method string `json:"method"`
+	// Requests and Responses are the messages sent and received, as JSON.
+	Requests  []json.RawMessage `json:"requests"`
+	Responses []json.RawMessage `json:"responses"`
+	// Trailers are the gRPC-Web trailers sent in the response body, like
+	// grpc-status and grpc-message.
+	Trailers map[string]string `json:"trailers,omitempty"`
+}
+
+// grpcContentType returns the gRPC content type of a MIME type, e.g.
+// application/grpc-web-text, or "" if it is not one.
+func grpcContentType(mimeType string) string {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0]))
+	if mimeType == "application/grpc" || strings.HasPrefix(mimeType, "application/grpc+") ||
+		strings.HasPrefix(mimeType, "application/grpc-web") {
+		return mimeType
+	}
+	return ""
+}
+
+// IsGRPC reports whether entry is a gRPC or gRPC-Web call.
+func IsGRPC(entry Entry) bool {
+	return grpcContentType(entry.Request.PostData.MimeType) != "" ||
+		grpcContentType(recordedHeader(entry.Request.Headers, "Content-Type")) != "" ||
+		grpcContentType(entry.Response.Content.MimeType) != ""
+}
+
+// grpcFrame is a length-prefixed message of a gRPC body.
+type grpcFrame struct {
+	compressed bool
+	trailers   bool
+	data       []byte
+}
+
+// splitGRPCFrames splits a gRPC body into its frames. Bodies of the
+// grpc-web-text content type are base64 encoded, possibly in several
+// padded chunks.
+func splitGRPCFrames(body []byte, contentType string) ([]grpcFrame, error) {
+	if strings.HasPrefix(contentType, "application/grpc-web-text") {
+		decoded, err := decodeBase64Chunks(body)
+		if err != nil {
+			return nil, err
+		}
+		body = decoded
+	}
+
+	var frames []grpcFrame
+	for len(body) > 0 {
+		if len(body) < 5 {
+			return nil, fmt.Errorf("truncated gRPC frame")
+		}
+		size := binary.BigEndian.Uint32(body[1:5])
+		if uint64(size) > uint64(len(body)-5) {
+			return nil, fmt.Errorf("truncated gRPC frame")
+		}
+		frames = append(frames, grpcFrame{compressed: body[0]&1 != 0, trailers: body[0]&0x80 != 0, data: body[5 : 5+size]})
+		body = body[5+size:]
+	}
+	return frames, nil
+}
+
+// decodeBase64Chunks decodes concatenated base64 strings, each of which
+// may end in padding.
+func decodeBase64Chunks(data []byte) ([]byte, error) {
+	text := strings.Join(strings.Fields(string(data)), "")
+	var out []byte
+	for text != "" {
+		end := strings.IndexByte(text, '=')
+		if end < 0 {
+			end = len(text)
+		} else {
+			for end < len(text) && text[end] == '=' {
+				end++
+			}
+		}
+		chunk, err := base64.StdEncoding.DecodeString(text[:end])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, chunk...)
+		text = text[end:]
+	}
+	return out, nil
+}
+
+// grpcMessages decodes the frames of a body to JSON messages of the named
+// type, or without schema if reg or the type is unknown, and returns them
+// with the trailers.
+func grpcMessages(body []byte, contentType, encoding, messageType string, reg *ProtoRegistry) ([]json.RawMessage, map[string]string, error) {
+	frames, err := splitGRPCFrames(body, contentType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	messages := []json.RawMessage{}
+	var trailers map[string]string
+	for _, frame := range frames {
+		data := frame.data
+		if frame.compressed {
+			if data, err = grpcDecompress(data, encoding); err != nil {
+				return nil, nil, err
+			}
+		}
+		if frame.trailers {
+			trailers = parseGRPCTrailers(data)
+			continue
+		}
+
+		var message json.RawMessage
+		if _, known := reg.messageType(messageType); known {
+			message, err = reg.DecodeJSON(messageType, data)
+		} else {
+			message, err = DecodeProtoSchemaless(data)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, trailers, nil
+}
+
+// messageType returns the definition of the named message, if reg is set
+// and has it.
+func (reg *ProtoRegistry) messageType(name string) (*protoMessageDesc, bool) {
+	if reg == nil || name == "" {
+		return nil, false
+	}
+	msg, ok := reg.messages[strings.TrimPrefix(name, ".")]
+	return msg, ok
+}
+
+// grpcDecompress decompresses a message compressed with the grpc-encoding.
+func grpcDecompress(data []byte, encoding string) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	case "", "identity":
+		return nil, fmt.Errorf("compressed gRPC message without grpc-encoding")
+	}
+	return nil, fmt.Errorf("unsupported grpc-encoding %s", encoding)
+}
+
+// parseGRPCTrailers parses the trailers of a gRPC-Web response, encoded as
+// HTTP/1 headers.
+func parseGRPCTrailers(data []byte) map[string]string {
+	trailers := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		name, value, ok := strings.Cut(strings.TrimRight(line, "\r"), ":")
+		if ok {
+			trailers[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+		}
+	}
+	return trailers
+}
+
+// DecodeGRPC decodes the request and response messages of a gRPC or
+// gRPC-Web entry to JSON. The message types are looked up in reg by the
+// method path of the URL; without reg, or for methods it does not define,
+// the messages are decoded without schema, keyed by field number.
+func DecodeGRPC(entry Entry, reg *ProtoRegistry) (GRPCCall, error) {
+	var call GRPCCall
+	if !IsGRPC(entry) {
+		return call, fmt.Errorf("%s is not a gRPC call", entry.Request.URL)
+	}
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return call, err
+	}
+	call.Method = u.Path
+	input, output, _ := reg.Method(u.Path)
+
+	requestType := grpcContentType(entry.Request.PostData.MimeType)
+	if requestType == "" {
+		requestType = grpcContentType(recordedHeader(entry.Request.Headers, "Content-Type"))
+	}
+	call.Requests, _, err = grpcMessages([]byte(entry.Request.PostData.Text), requestType,
+		recordedHeader(entry.Request.Headers, "grpc-encoding"), input, reg)
+	if err != nil {
+		return call, fmt.Errorf("request of %s: %v", call.Method, err)
+	}
+
+	body, err := entry.DecodedBody()
+	if err != nil {
+		return call, fmt.Errorf("response of %s: %v", call.Method, err)
+	}
+	responseType := grpcContentType(entry.Response.Content.MimeType)
+	if responseType == "" {
+		responseType = requestType
+	}
+	call.Responses, call.Trailers, err = grpcMessages(body, responseType,
+		recordedHeader(entry.Response.Headers, "grpc-encoding"), output, reg)
+	if err != nil {
+		return call, fmt.Errorf("response of %s: %v", call.Method, err)
+	}
+	// native gRPC sends the status in HTTP/2 trailers, which browsers
+	// record as headers
+	if status := recordedHeader(entry.Response.Headers, "grpc-status"); status != "" && call.Trailers == nil {
+		call.Trailers = map[string]string{"grpc-status": status}
+		if message := recordedHeader(entry.Response.Headers, "grpc-message"); message != "" {
+			call.Trailers["grpc-message"] = message
+		}
+	}
+	return call, nil
+}
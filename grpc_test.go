@@ -0,0 +1,118 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// grpcFrameBytes encodes a length-prefixed gRPC frame.
+func grpcFrameBytes(flags byte, data []byte) []byte {
+	b := []byte{flags, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[1:], uint32(len(data)))
+	return append(b, data...)
+}
+
+func grpcTestEntry(requestType, responseType string, request, response []byte, headers []NVP) Entry {
+	content := Content{MimeType: responseType, Text: base64.StdEncoding.EncodeToString(response), Encoding: "base64"}
+	if strings.HasPrefix(responseType, "application/grpc-web-text") {
+		content = Content{MimeType: responseType, Text: string(response)}
+	}
+	return Entry{
+		Request: Request{Method: "POST", URL: "https://api.example.com/demo.Greeter/SayHello",
+			PostData: PostData{MimeType: requestType, Text: string(request)}},
+		Response: Response{Status: 200, Headers: headers, Content: content},
+	}
+}
+
+func TestDecodeGRPC(t *testing.T) {
+	reg, err := ParseProtoDescriptors(testDescriptorSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := grpcFrameBytes(0, pbString(1, "ann"))
+	reply := grpcFrameBytes(0, pbJoin(pbString(1, "hi ann"), pbVarint(2, 1)))
+	trailers := grpcFrameBytes(0x80, []byte("grpc-status: 0\r\ngrpc-message: OK\r\n"))
+	var zipped bytes.Buffer
+	zw := gzip.NewWriter(&zipped)
+	zw.Write(pbString(1, "zipped"))
+	zw.Close()
+	// grpc-web-text bodies may be several base64 chunks, each padded
+	webText := []byte(base64.StdEncoding.EncodeToString(reply) + base64.StdEncoding.EncodeToString(trailers))
+
+	tests := []struct {
+		entry    Entry
+		reg      *ProtoRegistry
+		expected string
+	}{
+		{grpcTestEntry("application/grpc-web-text", "application/grpc-web-text", []byte(base64.StdEncoding.EncodeToString(request)), webText, nil), reg,
+			`{"method":"/demo.Greeter/SayHello","requests":[{"userName":"ann"}],"responses":[{"message":"hi ann","delta":-1}],"trailers":{"grpc-message":"OK","grpc-status":"0"}}`},
+		{grpcTestEntry("application/grpc-web-text", "application/grpc-web-text", []byte(base64.StdEncoding.EncodeToString(request)), webText, nil), nil,
+			`{"method":"/demo.Greeter/SayHello","requests":[{"1":"ann"}],"responses":[{"1":"hi ann","2":1}],"trailers":{"grpc-message":"OK","grpc-status":"0"}}`},
+		// native gRPC records the status trailer as a header
+		{grpcTestEntry("application/grpc", "application/grpc", nil, grpcFrameBytes(1, zipped.Bytes()),
+			[]NVP{{Name: "grpc-encoding", Value: "gzip"}, {Name: "grpc-status", Value: "0"}}), reg,
+			`{"method":"/demo.Greeter/SayHello","requests":[],"responses":[{"message":"zipped"}],"trailers":{"grpc-status":"0"}}`},
+	}
+
+	for i, test := range tests {
+		if !IsGRPC(test.entry) {
+			t.Errorf("%d: not recognized as gRPC", i)
+		}
+		call, err := DecodeGRPC(test.entry, test.reg)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		got, _ := json.Marshal(call)
+		if string(got) != test.expected {
+			t.Errorf("%d: got %s, want %s", i, got, test.expected)
+		}
+	}
+
+	for i, entry := range []Entry{
+		{Request: Request{URL: "https://example.com/"}, Response: Response{Content: Content{MimeType: "application/json"}}},
+		grpcTestEntry("application/grpc", "application/grpc", []byte{0, 0, 0, 0, 9, 1}, nil, nil),
+		grpcTestEntry("application/grpc", "application/grpc", nil, grpcFrameBytes(1, []byte("x")), nil),
+	} {
+		if _, err := DecodeGRPC(entry, nil); err == nil {
+			t.Errorf("%d: expected an error", i)
+		}
+	}
+}
+
+func TestExtractGRPC(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	entry := grpcTestEntry("application/grpc-web+proto", "application/grpc-web+proto",
+		grpcFrameBytes(0, pbString(1, "ann")), grpcFrameBytes(0, pbString(1, "hi")), nil)
+	data, _ := json.Marshal(Har{Log: Log{Entries: []Entry{entry}}})
+
+	if _, err := ExtractContext(context.Background(), bufio.NewReader(bytes.NewReader(data)), ExtractOptions{GRPC: true}); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	matches, _ := filepath.Glob("./hargo-extract-*/api.example.com/demo.Greeter/*.grpc.json")
+	if len(matches) != 1 {
+		t.Fatalf("got %v", matches)
+	}
+	decoded, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"method":"/demo.Greeter/SayHello","requests":[{"1":"ann"}],"responses":[{"1":"hi"}]}`; compact.String() != expected {
+		t.Errorf("got %s, want %s", compact.String(), expected)
+	}
+}
@@ -0,0 +1,361 @@
+package hargo
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolution of a LatencyHistogram: latencies are recorded in microseconds
+// with three significant digits, up to an hour.
+const (
+	hdrSubBucketHalfMagnitude = 10
+	hdrSubBucketHalfCount     = 1 << hdrSubBucketHalfMagnitude
+	hdrSubBucketCount         = 2 * hdrSubBucketHalfCount
+	hdrHighestTrackable       = int64(time.Hour / time.Microsecond)
+)
+
+// LatencyHistogram records latencies in the layout of an HDR histogram:
+// buckets are exponentially wider, each split into linear sub-buckets, so
+// every latency is kept with a relative error below 0.1% in a fixed amount
+// of memory. It is safe for concurrent use.
+type LatencyHistogram struct {
+	mu       sync.Mutex
+	counts   []uint64
+	total    uint64
+	min, max int64
+	sum      float64
+	sumSq    float64
+}
+
+// NewLatencyHistogram returns an empty LatencyHistogram.
+func NewLatencyHistogram() *LatencyHistogram {
+	buckets := 1
+	for smallestUntrackable := int64(hdrSubBucketCount); smallestUntrackable <= hdrHighestTrackable; smallestUntrackable <<= 1 {
+		buckets++
+	}
+	return &LatencyHistogram{
+		counts: make([]uint64, (buckets+1)*hdrSubBucketHalfCount),
+		min:    math.MaxInt64,
+	}
+}
+
+// hdrIndex returns the counts index of a value in microseconds.
+func hdrIndex(v int64) int {
+	bucket := 64 - bits.LeadingZeros64(uint64(v)|(hdrSubBucketCount-1)) - (hdrSubBucketHalfMagnitude + 1)
+	subBucket := int(v >> uint(bucket))
+	return (bucket+1)<<hdrSubBucketHalfMagnitude + subBucket - hdrSubBucketHalfCount
+}
+
+// hdrValue returns the highest value in microseconds counted at index i.
+func hdrValue(i int) int64 {
+	bucket := i>>hdrSubBucketHalfMagnitude - 1
+	subBucket := i&(hdrSubBucketHalfCount-1) + hdrSubBucketHalfCount
+	if bucket < 0 {
+		subBucket -= hdrSubBucketHalfCount
+		bucket = 0
+	}
+	return int64(subBucket+1)<<uint(bucket) - 1
+}
+
+// Record adds a latency. Latencies over an hour are counted as an hour.
+func (h *LatencyHistogram) Record(latency time.Duration) {
+	if h == nil {
+		return
+	}
+	v := int64(latency / time.Microsecond)
+	if v < 0 {
+		v = 0
+	}
+	if v > hdrHighestTrackable {
+		v = hdrHighestTrackable
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.counts[hdrIndex(v)]++
+	h.total++
+	if v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+	h.sum += float64(v)
+	h.sumSq += float64(v) * float64(v)
+}
+
+// Count returns the number of recorded latencies.
+func (h *LatencyHistogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.total
+}
+
+// Min returns the lowest recorded latency, or 0.
+func (h *LatencyHistogram) Min() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.min) * time.Microsecond
+}
+
+// Max returns the highest recorded latency.
+func (h *LatencyHistogram) Max() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return time.Duration(h.max) * time.Microsecond
+}
+
+// Mean returns the average of the recorded latencies.
+func (h *LatencyHistogram) Mean() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.sum / float64(h.total) * float64(time.Microsecond))
+}
+
+// Percentile returns the latency at or below which percentile percent of
+// the recorded latencies are, e.g. 99.9.
+func (h *LatencyHistogram) Percentile(percentile float64) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return time.Duration(h.percentile(percentile)) * time.Microsecond
+}
+
+func (h *LatencyHistogram) percentile(percentile float64) int64 {
+	if h.total == 0 {
+		return 0
+	}
+	target := uint64(math.Ceil(math.Min(percentile, 100) / 100 * float64(h.total)))
+	if target == 0 {
+		target = 1
+	}
+	var cumulative uint64
+	for i, count := range h.counts {
+		cumulative += count
+		if cumulative >= target {
+			return h.clamp(hdrValue(i))
+		}
+	}
+	return h.max
+}
+
+// clamp limits the highest value of a sub-bucket to the recorded maximum.
+func (h *LatencyHistogram) clamp(v int64) int64 {
+	if v > h.max {
+		return h.max
+	}
+	return v
+}
+
+// Summary describes the recorded latencies for the final report.
+func (h *LatencyHistogram) Summary() string {
+	if h.Count() == 0 {
+		return "Latency: no requests"
+	}
+	return fmt.Sprintf("Latency: min %v, p50 %v, p90 %v, p99 %v, p99.9 %v, max %v, mean %v",
+		h.Min(), h.Percentile(50), h.Percentile(90), h.Percentile(99), h.Percentile(99.9), h.Max(), h.Mean())
+}
+
+// distribution returns the number of latencies below each of the ascending
+// bounds and at or above the previous one, followed by the number from the
+// last bound on, within the precision of the histogram.
+func (h *LatencyHistogram) distribution(bounds []time.Duration) []uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	counts := make([]uint64, len(bounds)+1)
+	for i, count := range h.counts {
+		if count == 0 {
+			continue
+		}
+		v := time.Duration(hdrValue(i)) * time.Microsecond
+		bin := sort.Search(len(bounds), func(j int) bool { return v < bounds[j] })
+		counts[bin] += count
+	}
+	return counts
+}
+
+// hdrTicksPerHalfDistance is the number of percentile levels reported by
+// WriteHDR between 0% and 50%, 50% and 75% and so on.
+const hdrTicksPerHalfDistance = 5
+
+// WriteHDR writes the percentile distribution of the recorded latencies in
+// milliseconds in the .hgrm format of HdrHistogram, which its plotter and
+// other HDR tools read.
+func (h *LatencyHistogram) WriteHDR(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)")
+
+	level := 0.0
+	var cumulative uint64
+	for i, count := range h.counts {
+		if count == 0 {
+			continue
+		}
+		cumulative += count
+		value := float64(h.clamp(hdrValue(i))) / 1000
+		for level < 100 && float64(cumulative)/float64(h.total)*100 >= level {
+			fmt.Fprintf(bw, "%12.3f %2.12f %10d %14.2f\n", value, level/100, cumulative, 1/(1-level/100))
+			if cumulative == h.total {
+				// like HdrHistogram, the last value ends the distribution
+				break
+			}
+			halfDistance := math.Pow(2, math.Floor(math.Log2(100/(100-level)))+1)
+			level += 100 / (hdrTicksPerHalfDistance * halfDistance)
+		}
+		if cumulative == h.total {
+			fmt.Fprintf(bw, "%12.3f %2.12f %10d\n", value, 1.0, cumulative)
+			break
+		}
+	}
+
+	mean, stddev := 0.0, 0.0
+	if h.total > 0 {
+		mean = h.sum / float64(h.total)
+		stddev = math.Sqrt(math.Max(h.sumSq/float64(h.total)-mean*mean, 0))
+	}
+	fmt.Fprintf(bw, "#[Mean    = %12.3f, StdDeviation   = %12.3f]\n", mean/1000, stddev/1000)
+	fmt.Fprintf(bw, "#[Max     = %12.3f, Total count    = %12d]\n", float64(h.max)/1000, h.total)
+	fmt.Fprintf(bw, "#[Buckets = %12d, SubBuckets     = %12d]\n", len(h.counts)/hdrSubBucketHalfCount-1, hdrSubBucketCount)
+	return bw.Flush()
+}
+
+// WriteCSV writes the latency distribution as CSV with a row per recorded
+// sub-bucket: its highest latency in milliseconds, its count, the
+// cumulative count and the percentile reached.
+func (h *LatencyHistogram) WriteCSV(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"latency_ms", "count", "cumulative_count", "percentile"})
+	var cumulative uint64
+	for i, count := range h.counts {
+		if count == 0 {
+			continue
+		}
+		cumulative += count
+		cw.Write([]string{
+			strconv.FormatFloat(float64(h.clamp(hdrValue(i)))/1000, 'f', 3, 64),
+			strconv.FormatUint(count, 10),
+			strconv.FormatUint(cumulative, 10),
+			strconv.FormatFloat(float64(cumulative)/float64(h.total)*100, 'f', 4, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteHistogramFile writes the latency distribution to path, as CSV if it
+// ends in .csv and in the .hgrm format otherwise.
+func (h *LatencyHistogram) WriteHistogramFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		err = h.WriteCSV(file)
+	} else {
+		err = h.WriteHDR(file)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Merge adds the latencies recorded by other.
+func (h *LatencyHistogram) Merge(other *LatencyHistogram) {
+	if other == nil || h == other {
+		return
+	}
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, count := range other.counts {
+		h.counts[i] += count
+	}
+	h.total += other.total
+	if other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	h.sum += other.sum
+	h.sumSq += other.sumSq
+}
+
+// latencyHistogramJSON is the JSON form of a LatencyHistogram, with the
+// counts of the recorded sub-buckets only.
+type latencyHistogramJSON struct {
+	Counts map[int]uint64 `json:"counts"`
+	Min    int64          `json:"min"`
+	Max    int64          `json:"max"`
+	Sum    float64        `json:"sum"`
+	SumSq  float64        `json:"sumSq"`
+}
+
+// MarshalJSON encodes the recorded latencies, e.g. to send them from a load
+// agent to its coordinator.
+func (h *LatencyHistogram) MarshalJSON() ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	v := latencyHistogramJSON{Counts: make(map[int]uint64), Min: h.min, Max: h.max, Sum: h.sum, SumSq: h.sumSq}
+	for i, count := range h.counts {
+		if count > 0 {
+			v.Counts[i] = count
+		}
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes latencies encoded by MarshalJSON.
+func (h *LatencyHistogram) UnmarshalJSON(b []byte) error {
+	var v latencyHistogramJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	decoded := NewLatencyHistogram()
+	for i, count := range v.Counts {
+		if i < 0 || i >= len(decoded.counts) {
+			return fmt.Errorf("invalid latency histogram index %d", i)
+		}
+		decoded.counts[i] = count
+		decoded.total += count
+	}
+	if decoded.total > 0 {
+		decoded.min, decoded.max = v.Min, v.Max
+	}
+	decoded.sum, decoded.sumSq = v.Sum, v.SumSq
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts, h.total, h.min, h.max, h.sum, h.sumSq = decoded.counts, decoded.total, decoded.min, decoded.max, decoded.sum, decoded.sumSq
+	return nil
+}
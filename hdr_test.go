@@ -0,0 +1,104 @@
+package hargo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	h := NewLatencyHistogram()
+	if h.Percentile(50) != 0 || h.Min() != 0 || h.Count() != 0 {
+		t.Errorf("empty histogram: %s", h.Summary())
+	}
+
+	// 1ms to 1000ms
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	h.Record(2 * time.Hour)
+
+	if h.Count() != 1001 {
+		t.Errorf("count = %d", h.Count())
+	}
+	if h.Min() != time.Millisecond || h.Max() != time.Hour {
+		t.Errorf("min %v, max %v", h.Min(), h.Max())
+	}
+
+	tests := []struct {
+		percentile float64
+		expected   time.Duration
+	}{
+		{0, time.Millisecond},
+		{50, 501 * time.Millisecond},
+		{90, 901 * time.Millisecond},
+		{99.9, time.Second},
+		{100, time.Hour},
+	}
+	for _, test := range tests {
+		got := h.Percentile(test.percentile)
+		// three significant digits
+		if diff := got - test.expected; diff < 0 || diff > test.expected/1000 {
+			t.Errorf("p%g = %v, expected %v", test.percentile, got, test.expected)
+		}
+	}
+
+	for _, v := range []int64{0, 1, 2047, 2048, 2049, 123456, hdrHighestTrackable} {
+		i := hdrIndex(v)
+		if high := hdrValue(i); high < v || (i > 0 && hdrValue(i-1) >= v) {
+			t.Errorf("value %d at index %d, range up to %d", v, i, high)
+		}
+	}
+}
+
+func TestLatencyHistogramExport(t *testing.T) {
+	h := NewLatencyHistogram()
+	for _, ms := range []int{10, 20, 20, 40} {
+		h.Record(time.Duration(ms) * time.Millisecond)
+	}
+
+	// values are reported as the highest of their sub-bucket, like
+	// HdrHistogram does, except for the maximum
+	var b bytes.Buffer
+	if err := h.WriteHDR(&b); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if !strings.Contains(lines[0], "1/(1-Percentile)") {
+		t.Errorf("header %q", lines[0])
+	}
+	if want := "      10.007 0.000000000000          1           1.00"; lines[2] != want {
+		t.Errorf("first line %q, want %q", lines[2], want)
+	}
+	if want := "      40.000 1.000000000000          4"; lines[len(lines)-4] != want {
+		t.Errorf("last line %q, want %q", lines[len(lines)-4], want)
+	}
+	if !strings.HasPrefix(lines[len(lines)-2], "#[Max     =       40.000, Total count    =            4]") {
+		t.Errorf("footer %q", lines[len(lines)-2])
+	}
+
+	b.Reset()
+	if err := h.WriteCSV(&b); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"latency_ms", "count", "cumulative_count", "percentile"},
+		{"10.007", "1", "1", "25.0000"},
+		{"20.015", "2", "3", "75.0000"},
+		{"40.000", "1", "4", "100.0000"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("records %q", records)
+	}
+	for i := range want {
+		if strings.Join(records[i], ",") != strings.Join(want[i], ",") {
+			t.Errorf("record %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+}
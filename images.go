@@ -0,0 +1,196 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	_ "image/gif" // register decoders for image.DecodeConfig
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// imageConversions maps an image format to the modern format it should be
+// converted to and the typical fraction of bytes saved: lossless WebP is
+// about 26% smaller than PNG, AVIF about half the size of JPEG, and
+// animated GIFs shrink by more than half as WebP.
+var imageConversions = map[string]struct {
+	format  string
+	savings float64
+}{
+	"png":  {"webp", 0.26},
+	"jpeg": {"avif", 0.5},
+	"gif":  {"webp", 0.5},
+	"bmp":  {"webp", 0.9},
+	"tiff": {"webp", 0.9},
+	"webp": {"avif", 0.2},
+}
+
+// imageBytesPerPixel is the typical size of a well-compressed lossy image.
+// Larger images are probably saved at too high a quality.
+var imageBytesPerPixel = map[string]float64{
+	"jpeg": 0.25,
+	"webp": 0.2,
+}
+
+// ImageSaving is an estimated saving for one image URL.
+type ImageSaving struct {
+	URL    string `json:"url"`
+	Format string `json:"format"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Bytes  int64  `json:"bytes"`
+	// Savings is the estimated number of bytes that can be saved.
+	Savings    int64  `json:"savings"`
+	Suggestion string `json:"suggestion"`
+}
+
+// ImageReport lists the images of a .har file that can be made smaller,
+// largest savings first.
+type ImageReport struct {
+	Images  int           `json:"images"`
+	Bytes   int64         `json:"bytes"`
+	Savings int64         `json:"savings"`
+	Items   []ImageSaving `json:"items"`
+}
+
+// AnalyzeImages estimates the savings of converting legacy image formats to
+// WebP or AVIF, of recompressing lossy images larger than typical for their
+// dimensions, and of removing identical images fetched under different
+// URLs. Estimates are heuristics, not the result of actually re-encoding
+// the images. Formats are sniffed from the recorded content when available.
+func AnalyzeImages(har Har) ImageReport {
+	var report ImageReport
+	seenURLs := make(map[string]bool)
+	firstURL := make(map[[sha256.Size]byte]string)
+
+	for _, entry := range har.Log.Entries {
+		content := entry.Response.Content
+		if !strings.HasPrefix(strings.ToLower(content.MimeType), "image/") || seenURLs[entry.Request.URL] {
+			continue
+		}
+		seenURLs[entry.Request.URL] = true
+
+		body, _ := decodeContent(content)
+		item := ImageSaving{URL: entry.Request.URL, Format: imageFormat(content.MimeType, body), Bytes: int64(len(body))}
+		if item.Bytes == 0 {
+			item.Bytes = int64(bodySize(entry.Response))
+		}
+		if item.Bytes <= 0 {
+			continue
+		}
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(body)); err == nil {
+			item.Width, item.Height = cfg.Width, cfg.Height
+		}
+
+		report.Images++
+		report.Bytes += item.Bytes
+
+		if len(body) > 0 {
+			sum := sha256.Sum256(body)
+			if first, ok := firstURL[sum]; ok {
+				item.Savings = item.Bytes
+				item.Suggestion = "duplicate of " + first
+				report.Items = append(report.Items, item)
+				report.Savings += item.Savings
+				continue
+			}
+			firstURL[sum] = item.URL
+		}
+
+		optimized := float64(item.Bytes)
+		var suggestions []string
+		if bpp, ok := imageBytesPerPixel[item.Format]; ok && item.Width > 0 {
+			if target := float64(item.Width*item.Height) * bpp; target < optimized {
+				optimized = target
+				suggestions = append(suggestions, "recompress")
+			}
+		}
+		if conversion, ok := imageConversions[item.Format]; ok {
+			optimized *= 1 - conversion.savings
+			suggestions = append(suggestions, "convert to "+conversion.format)
+		}
+
+		if item.Savings = item.Bytes - int64(optimized); item.Savings > 0 {
+			item.Suggestion = strings.Join(suggestions, ", ")
+			report.Items = append(report.Items, item)
+			report.Savings += item.Savings
+		}
+	}
+
+	sort.SliceStable(report.Items, func(i, j int) bool {
+		return report.Items[i].Savings > report.Items[j].Savings
+	})
+
+	return report
+}
+
+// imageFormat returns the format of an image, sniffed from its content or
+// taken from its MIME type.
+func imageFormat(mimeType string, body []byte) string {
+	if len(body) > 0 {
+		switch {
+		case len(body) >= 12 && string(body[4:8]) == "ftyp" && (string(body[8:12]) == "avif" || string(body[8:12]) == "avis"):
+			return "avif"
+		case bytes.HasPrefix(body, []byte("II*\x00")), bytes.HasPrefix(body, []byte("MM\x00*")):
+			return "tiff"
+		}
+		if sniffed := http.DetectContentType(body); strings.HasPrefix(sniffed, "image/") {
+			mimeType = sniffed
+		}
+	}
+
+	format := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0])), "image/")
+	switch format {
+	case "jpg", "pjpeg":
+		return "jpeg"
+	case "x-png":
+		return "png"
+	case "x-ms-bmp":
+		return "bmp"
+	case "svg+xml":
+		return "svg"
+	case "x-icon", "vnd.microsoft.icon":
+		return "ico"
+	}
+	return format
+}
+
+// Images prints the prioritized image savings of a .har file to w, listing
+// at most top images.
+func Images(r *bufio.Reader, w io.Writer, top int) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	report := AnalyzeImages(har)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Images\t%d\t\n", report.Images)
+	fmt.Fprintf(tw, "Image bytes\t%d\t\n", report.Bytes)
+	fmt.Fprintf(tw, "Estimated savings\t%d\t\n", report.Savings)
+
+	if len(report.Items) > 0 && top > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t\n", "URL", "Format", "Dimensions", "Bytes", "Savings", "Suggestion")
+		for i, item := range report.Items {
+			if i == top {
+				break
+			}
+			dimensions := "-"
+			if item.Width > 0 {
+				dimensions = fmt.Sprintf("%dx%d", item.Width, item.Height)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\t\n", shortURL(item.URL), item.Format, dimensions, item.Bytes, item.Savings, item.Suggestion)
+		}
+	}
+
+	return tw.Flush()
+}
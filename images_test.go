@@ -0,0 +1,116 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func imageEntry(url, mimeType string, body []byte) Entry {
+	return Entry{
+		Request: Request{URL: url},
+		Response: Response{Status: 200, BodySize: len(body), Content: Content{
+			Size:     len(body),
+			MimeType: mimeType,
+			Text:     base64.StdEncoding.EncodeToString(body),
+			Encoding: "base64",
+		}},
+	}
+}
+
+func TestAnalyzeImages(t *testing.T) {
+	// random pixels defeat compression, making the images large
+	noise := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	rnd := rand.New(rand.NewSource(1))
+	for i := range noise.Pix {
+		noise.Pix[i] = byte(rnd.Intn(256))
+	}
+	var pngData, jpegData bytes.Buffer
+	if err := png.Encode(&pngData, noise); err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(&jpegData, noise, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	flat := image.NewGray(image.Rect(0, 0, 4, 4))
+	flat.Set(0, 0, color.White)
+	var iconData bytes.Buffer
+	if err := png.Encode(&iconData, flat); err != nil {
+		t.Fatal(err)
+	}
+
+	har := Har{Log: Log{Entries: []Entry{
+		imageEntry("https://example.com/photo.png", "image/png", pngData.Bytes()),
+		// mislabeled, sniffed as JPEG
+		imageEntry("https://example.com/photo.jpg", "image/png", jpegData.Bytes()),
+		imageEntry("https://cdn.example.com/photo.png", "image/png", pngData.Bytes()),
+		imageEntry("https://example.com/photo.png", "image/png", pngData.Bytes()),
+		imageEntry("https://example.com/icon.png", "image/png", iconData.Bytes()),
+		{Request: Request{URL: "https://example.com/app.js"}, Response: Response{Content: Content{MimeType: "application/javascript", Text: "x"}}},
+	}}}
+
+	report := AnalyzeImages(har)
+	if report.Images != 4 {
+		t.Errorf("expected 4 images, got %d", report.Images)
+	}
+
+	byURL := make(map[string]ImageSaving)
+	for _, item := range report.Items {
+		byURL[item.URL] = item
+	}
+
+	photo := byURL["https://example.com/photo.png"]
+	if photo.Format != "png" || photo.Width != 40 || photo.Height != 30 || photo.Suggestion != "convert to webp" {
+		t.Errorf("unexpected PNG saving %+v", photo)
+	}
+	if expected := photo.Bytes - int64(float64(photo.Bytes)*0.74); photo.Savings != expected {
+		t.Errorf("PNG savings %d, expected %d", photo.Savings, expected)
+	}
+
+	jpg := byURL["https://example.com/photo.jpg"]
+	if jpg.Format != "jpeg" || jpg.Suggestion != "recompress, convert to avif" {
+		t.Errorf("unexpected JPEG saving %+v", jpg)
+	}
+	if expected := jpg.Bytes - int64(40*30*0.25*0.5); jpg.Savings != expected {
+		t.Errorf("JPEG savings %d, expected %d", jpg.Savings, expected)
+	}
+
+	dup := byURL["https://cdn.example.com/photo.png"]
+	if dup.Savings != dup.Bytes || dup.Suggestion != "duplicate of https://example.com/photo.png" {
+		t.Errorf("unexpected duplicate %+v", dup)
+	}
+
+	for i := 1; i < len(report.Items); i++ {
+		if report.Items[i].Savings > report.Items[i-1].Savings {
+			t.Errorf("items not sorted by savings: %+v", report.Items)
+		}
+	}
+}
+
+func TestImages(t *testing.T) {
+	var data bytes.Buffer
+	if err := png.Encode(&data, image.NewGray(image.Rect(0, 0, 100, 100))); err != nil {
+		t.Fatal(err)
+	}
+	har, err := json.Marshal(Har{Log: Log{Entries: []Entry{imageEntry("https://example.com/a.png", "image/png", data.Bytes())}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := Images(bufio.NewReader(bytes.NewReader(har)), &out, 10); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "100x100") || !strings.Contains(out.String(), "convert to webp") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,368 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// indexSchema creates the tables of an index database, see BuildIndex.
+const indexSchema = `CREATE TABLE IF NOT EXISTS captures (
+  id INTEGER PRIMARY KEY,
+  path TEXT UNIQUE,
+  size INTEGER,
+  mod_time TEXT,
+  entries INTEGER,
+  started_ms INTEGER,
+  ended_ms INTEGER
+);
+CREATE TABLE IF NOT EXISTS requests (
+  capture_id INTEGER REFERENCES captures(id),
+  entry INTEGER,
+  started TEXT,
+  started_ms INTEGER,
+  method TEXT,
+  url TEXT,
+  host TEXT,
+  status INTEGER,
+  mime_type TEXT,
+  time REAL
+);
+CREATE INDEX IF NOT EXISTS requests_capture ON requests(capture_id);
+CREATE INDEX IF NOT EXISTS requests_host ON requests(host);
+CREATE INDEX IF NOT EXISTS requests_status ON requests(status);
+CREATE INDEX IF NOT EXISTS requests_started ON requests(started_ms);
+`
+
+// IndexResult describes what BuildIndex or IndexFiles did.
+type IndexResult struct {
+	// Indexed is the number of new or changed captures indexed, Unchanged
+	// the number of captures already indexed as they are, and Removed the
+	// number of captures dropped from the index because their file is gone.
+	Indexed   int
+	Unchanged int
+	Removed   int
+	// Requests is the number of requests of the indexed captures.
+	Requests int
+	// Failed lists the captures that could not be read.
+	Failed []string
+}
+
+// indexedCapture is a capture as recorded in an index database.
+type indexedCapture struct {
+	id      int
+	size    int64
+	modTime string
+}
+
+// BuildIndex indexes every .har file in dir and its subdirectories into
+// the SQLite database at dbPath, created if needed, so captures containing
+// a request can be found with SearchIndex across many files. Updating an
+// existing index only reads new and changed files, and drops the captures
+// whose file was removed. Like ToSQLite, it requires the sqlite3 command
+// line shell.
+func BuildIndex(dir, dbPath string) (IndexResult, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && strings.EqualFold(filepath.Ext(path), ".har") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return IndexResult{}, err
+	}
+	return indexCaptures(dbPath, paths, dir)
+}
+
+// IndexFiles adds .har files to the index database at dbPath, or updates
+// them if they changed, leaving all other captures in the index.
+func IndexFiles(dbPath string, paths []string) (IndexResult, error) {
+	return indexCaptures(dbPath, paths, "")
+}
+
+// indexCaptures indexes the files at paths, and removes the captures in
+// dir that are not among them if dir is set.
+func indexCaptures(dbPath string, paths []string, dir string) (IndexResult, error) {
+	var result IndexResult
+	existing, err := readIndexedCaptures(dbPath)
+	if err != nil {
+		return result, err
+	}
+	nextID := 1
+	for _, c := range existing {
+		nextID = max(nextID, c.id+1)
+	}
+
+	pr, pw := io.Pipe()
+	written := make(chan error, 1)
+	go func() {
+		err := func() error {
+			w := bufio.NewWriter(pw)
+			if _, err := io.WriteString(w, "BEGIN;\n"+indexSchema); err != nil {
+				return err
+			}
+			seen := make(map[string]bool)
+			for _, path := range paths {
+				abs, err := filepath.Abs(path)
+				if err != nil {
+					return err
+				}
+				seen[abs] = true
+				info, err := os.Stat(abs)
+				if err != nil {
+					log.Warnf("Cannot index %s: %v", path, err)
+					result.Failed = append(result.Failed, path)
+					continue
+				}
+				modTime := info.ModTime().UTC().Format(time.RFC3339Nano)
+				previous, indexed := existing[abs]
+				if indexed && previous.size == info.Size() && previous.modTime == modTime {
+					result.Unchanged++
+					continue
+				}
+				n, err := writeIndexCapture(w, nextID, previous.id, abs, info.Size(), modTime)
+				if err != nil {
+					log.Warnf("Cannot index %s: %v", path, err)
+					result.Failed = append(result.Failed, path)
+					continue
+				}
+				nextID++
+				result.Indexed++
+				result.Requests += n
+			}
+			if dir != "" {
+				absDir, err := filepath.Abs(dir)
+				if err != nil {
+					return err
+				}
+				for path, c := range existing {
+					if !seen[path] && strings.HasPrefix(path, absDir+string(filepath.Separator)) {
+						fmt.Fprintf(w, "DELETE FROM requests WHERE capture_id = %d;\nDELETE FROM captures WHERE id = %d;\n", c.id, c.id)
+						result.Removed++
+					}
+				}
+			}
+			if _, err := io.WriteString(w, "COMMIT;\n"); err != nil {
+				return err
+			}
+			return w.Flush()
+		}()
+		pw.CloseWithError(err)
+		written <- err
+	}()
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(SQLiteCommand, "-bail", dbPath)
+	cmd.Stdin = pr
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	// unblock the writer if sqlite3 exited early
+	pr.Close()
+	writeErr := <-written
+
+	if err != nil {
+		return result, fmt.Errorf("%s: %v %s", SQLiteCommand, err, strings.TrimSpace(stderr.String()))
+	}
+	if writeErr != nil {
+		return result, writeErr
+	}
+	log.Infof("Indexed %d captures with %d requests, %d unchanged, %d removed", result.Indexed, result.Requests, result.Unchanged, result.Removed)
+	return result, nil
+}
+
+// writeIndexCapture writes the statements indexing the .har file at path,
+// replacing the capture with previousID if not zero, and returns its
+// number of requests. The statements of a file that cannot be read are
+// rolled back, keeping the previous capture.
+func writeIndexCapture(w io.Writer, id, previousID int, path string, size int64, modTime string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	if _, err := io.WriteString(w, "SAVEPOINT capture;\n"); err != nil {
+		return 0, err
+	}
+	if previousID != 0 {
+		fmt.Fprintf(w, "DELETE FROM requests WHERE capture_id = %d;\nDELETE FROM captures WHERE id = %d;\n", previousID, previousID)
+	}
+	var b strings.Builder
+	var startedMs, endedMs interface{}
+	n := 0
+	err = forEachEntry(NewReader(file), func(e Entry) error {
+		n++
+		var ms interface{}
+		if t, err := parseStartedDateTime(e.StartedDateTime); err == nil {
+			ms = t.UnixMilli()
+			if startedMs == nil || t.UnixMilli() < startedMs.(int64) {
+				startedMs = t.UnixMilli()
+			}
+			if end := t.Add(time.Duration(float64(e.Time) * float64(time.Millisecond))).UnixMilli(); endedMs == nil || end > endedMs.(int64) {
+				endedMs = end
+			}
+		}
+		b.Reset()
+		sqlInsert(&b, "requests", id, n, e.StartedDateTime, ms, e.Request.Method, e.Request.URL,
+			strings.ToLower(entryURL(e).Hostname()), e.Response.Status, e.Response.Content.MimeType, float64(e.Time))
+		_, err := io.WriteString(w, b.String())
+		return err
+	})
+	if err != nil {
+		io.WriteString(w, "ROLLBACK TO capture;\nRELEASE capture;\n")
+		return 0, err
+	}
+	b.Reset()
+	sqlInsert(&b, "captures", id, path, size, modTime, n, startedMs, endedMs)
+	b.WriteString("RELEASE capture;\n")
+	_, err = io.WriteString(w, b.String())
+	return n, err
+}
+
+// readIndexedCaptures returns the captures of an index database by path,
+// none if the database does not exist yet.
+func readIndexedCaptures(dbPath string) (map[string]indexedCapture, error) {
+	captures := make(map[string]indexedCapture)
+	// sqlite3 creates databases with the first table
+	if info, err := os.Stat(dbPath); os.IsNotExist(err) || err == nil && info.Size() == 0 {
+		return captures, nil
+	}
+	if !isSQLite(dbPath) {
+		return nil, fmt.Errorf("%s is not a SQLite database", dbPath)
+	}
+
+	var out bytes.Buffer
+	query := "SELECT id, path, size, mod_time FROM captures"
+	if err := Query(dbPath, query, &out, QueryOptions{Mode: "csv"}); err != nil {
+		// an empty database without tables
+		if strings.Contains(err.Error(), "no such table") {
+			return captures, nil
+		}
+		return nil, err
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records[min(1, len(records)):] {
+		id, _ := strconv.Atoi(record[0])
+		size, _ := strconv.ParseInt(record[2], 10, 64)
+		captures[record[1]] = indexedCapture{id: id, size: size, modTime: record[3]}
+	}
+	return captures, nil
+}
+
+// IndexQuery selects the requests SearchIndex finds. Empty fields match
+// every request.
+type IndexQuery struct {
+	// URL is a part of the request URL, matched case insensitively.
+	URL string
+	// Host matches request hosts and their subdomains.
+	Host   string
+	Method string
+	// Status are status codes and classes like "2xx, 304".
+	Status StatusList
+	// From and To restrict the requests to those started within the
+	// window, inclusive.
+	From, To time.Time
+	// Captures lists every matching capture once, with its number of
+	// matching requests, instead of the requests.
+	Captures bool
+	// Limit is the maximum number of rows, unlimited if zero.
+	Limit int
+}
+
+// SearchIndex finds the requests matching q in an index database created
+// by BuildIndex and writes them to w with the path of their capture and
+// their 1-based entry number, or only the matching captures.
+func SearchIndex(dbPath string, q IndexQuery, w io.Writer, opts QueryOptions) error {
+	if !isSQLite(dbPath) {
+		return fmt.Errorf("%s is not an index database", dbPath)
+	}
+	query, err := q.sql()
+	if err != nil {
+		return err
+	}
+	return Query(dbPath, query, w, opts)
+}
+
+// sql returns the SQL query of q.
+func (q IndexQuery) sql() (string, error) {
+	var where []string
+	if q.URL != "" {
+		where = append(where, "instr(lower(r.url), "+sqlLiteral(strings.ToLower(q.URL))+") > 0")
+	}
+	if q.Host != "" {
+		host := strings.ToLower(q.Host)
+		where = append(where, "(r.host = "+sqlLiteral(host)+" OR r.host LIKE "+sqlLiteral("%."+host)+")")
+	}
+	if q.Method != "" {
+		where = append(where, "r.method = "+sqlLiteral(strings.ToUpper(q.Method)))
+	}
+	if q.Status != "" {
+		status, err := statusSQL("r.status", q.Status)
+		if err != nil {
+			return "", err
+		}
+		where = append(where, status)
+	}
+	if !q.From.IsZero() {
+		where = append(where, "r.started_ms >= "+sqlLiteral(q.From.UnixMilli()))
+	}
+	if !q.To.IsZero() {
+		where = append(where, "r.started_ms <= "+sqlLiteral(q.To.UnixMilli()))
+	}
+
+	query := "SELECT c.path AS capture, r.entry, r.started, r.method, r.status, r.url FROM requests r JOIN captures c ON c.id = r.capture_id"
+	if q.Captures {
+		query = "SELECT c.path AS capture, count(*) AS requests, min(r.started) AS first, max(r.started) AS last FROM requests r JOIN captures c ON c.id = r.capture_id"
+	}
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	if q.Captures {
+		query += " GROUP BY c.id ORDER BY c.path"
+	} else {
+		query += " ORDER BY c.path, r.entry"
+	}
+	if q.Limit > 0 {
+		query += " LIMIT " + strconv.Itoa(q.Limit)
+	}
+	return query, nil
+}
+
+// statusSQL returns a SQL condition on column matching status codes and
+// classes like "2xx, 304".
+func statusSQL(column string, statuses StatusList) (string, error) {
+	if _, err := matchStatus(statuses, 0); err != nil {
+		return "", err
+	}
+	var conditions []string
+	for _, s := range strings.Split(string(statuses), ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if class, ok := strings.CutSuffix(s, "xx"); ok && len(class) == 1 {
+			conditions = append(conditions, fmt.Sprintf("%s BETWEEN %s00 AND %s99", column, class, class))
+		} else if s != "" {
+			conditions = append(conditions, column+" = "+s)
+		}
+	}
+	if len(conditions) == 0 {
+		return "1", nil
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", nil
+}
@@ -0,0 +1,106 @@
+package hargo
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildIndexAndSearch(t *testing.T) {
+	if _, err := exec.LookPath(SQLiteCommand); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+
+	dir := t.TempDir()
+	captures := filepath.Join(dir, "captures")
+	os.MkdirAll(filepath.Join(captures, "monday"), 0755)
+	golang, _ := os.ReadFile("test/golang.org.har")
+	os.WriteFile(filepath.Join(captures, "monday", "golang.har"), golang, 0644)
+	os.WriteFile(filepath.Join(captures, "test.har"), []byte(createTestHAR()), 0644)
+	os.WriteFile(filepath.Join(captures, "notes.txt"), []byte("-"), 0644)
+	db := filepath.Join(dir, "index.db")
+
+	result, err := BuildIndex(captures, db)
+	if err != nil {
+		t.Fatalf("BuildIndex failed: %v", err)
+	}
+	if result.Indexed != 2 || result.Requests < 32 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	search := func(q IndexQuery) string {
+		t.Helper()
+		var out bytes.Buffer
+		if err := SearchIndex(db, q, &out, QueryOptions{Mode: "list"}); err != nil {
+			t.Fatalf("SearchIndex failed: %v", err)
+		}
+		return strings.ReplaceAll(out.String(), filepath.ToSlash(captures)+"/", "")
+	}
+	if got := search(IndexQuery{URL: "YOUTUBE.com/embed", Status: "2xx"}); got != "capture|entry|started|method|status|url\nmonday/golang.har|11|2019-09-02T11:49:07.421Z|GET|200|https://www.youtube.com/embed/cQ7STILAS0M\n" {
+		t.Errorf("got %q", got)
+	}
+	if got := search(IndexQuery{Host: "golang.org", Method: "get", Captures: true}); !strings.HasPrefix(got, "capture|requests|first|last\nmonday/golang.har|12|") {
+		t.Errorf("got %q", got)
+	}
+	if got := search(IndexQuery{From: time.Date(2019, 9, 2, 11, 49, 9, 0, time.UTC), Captures: true, Limit: 1}); !strings.HasPrefix(got, "capture|requests|first|last\nmonday/golang.har|") {
+		t.Errorf("got %q", got)
+	}
+	if got := search(IndexQuery{Host: "example.com", Status: "404"}); got != "" {
+		t.Errorf("got %q", got)
+	}
+
+	// only changed files are read again, and removed ones are dropped
+	os.Remove(filepath.Join(captures, "monday", "golang.har"))
+	os.WriteFile(filepath.Join(captures, "test.har"), []byte(`{"log": {"entries": []}}`), 0644)
+	os.WriteFile(filepath.Join(captures, "broken.har"), []byte(`{"log": {"entries": [{"request": 1}]}}`), 0644)
+	if result, err = BuildIndex(captures, db); err != nil {
+		t.Fatalf("BuildIndex failed: %v", err)
+	}
+	if result.Indexed != 1 || result.Removed != 1 || len(result.Failed) != 1 || result.Requests != 0 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if got := search(IndexQuery{Captures: true}); got != "" {
+		t.Errorf("got %q", got)
+	}
+
+	os.WriteFile(filepath.Join(dir, "other.har"), []byte(createTestHAR()), 0644)
+	if result, err = IndexFiles(db, []string{filepath.Join(dir, "other.har")}); err != nil || result.Indexed != 1 {
+		t.Errorf("got %+v, %v", result, err)
+	}
+	if result, err = BuildIndex(captures, db); err != nil || result.Removed != 0 || result.Unchanged != 1 {
+		t.Errorf("files outside the directory must stay indexed, got %+v, %v", result, err)
+	}
+
+	if err := SearchIndex(filepath.Join(captures, "test.har"), IndexQuery{}, &bytes.Buffer{}, QueryOptions{}); err == nil {
+		t.Error("expected an error for a .har file")
+	}
+}
+
+func TestIndexQuerySQL(t *testing.T) {
+	tests := []struct {
+		query    IndexQuery
+		expected string
+	}{
+		{IndexQuery{}, "SELECT c.path AS capture, r.entry, r.started, r.method, r.status, r.url FROM requests r JOIN captures c ON c.id = r.capture_id ORDER BY c.path, r.entry"},
+		{IndexQuery{URL: "/API/", Host: "Example.com", Status: "2xx, 304", Limit: 5},
+			"SELECT c.path AS capture, r.entry, r.started, r.method, r.status, r.url FROM requests r JOIN captures c ON c.id = r.capture_id " +
+				"WHERE instr(lower(r.url), '/api/') > 0 AND (r.host = 'example.com' OR r.host LIKE '%.example.com') AND (r.status BETWEEN 200 AND 299 OR r.status = 304) " +
+				"ORDER BY c.path, r.entry LIMIT 5"},
+		{IndexQuery{URL: "it's", Captures: true},
+			"SELECT c.path AS capture, count(*) AS requests, min(r.started) AS first, max(r.started) AS last FROM requests r JOIN captures c ON c.id = r.capture_id " +
+				"WHERE instr(lower(r.url), 'it''s') > 0 GROUP BY c.id ORDER BY c.path"},
+	}
+	for i, test := range tests {
+		if got, err := test.query.sql(); err != nil || got != test.expected {
+			t.Errorf("%d: got %s, %v", i, got, err)
+		}
+	}
+
+	if _, err := (IndexQuery{Status: "2xx; drop table"}).sql(); err == nil {
+		t.Error("expected an error for an invalid status")
+	}
+}
@@ -15,6 +15,10 @@ var db string
 // newInfluxDBClient returns a new InfluxDB client
 func newInfluxDBClient(u url.URL) (client.Client, error) {
 
+	if err := requireNetwork("influxdb"); err != nil {
+		return nil, err
+	}
+
 	addr := fmt.Sprintf("%s://%s:%s", u.Scheme, u.Hostname(), u.Port())
 	log.Print("Connecting to InfluxDB: ", addr)
 
@@ -66,8 +70,7 @@ func WritePoint(u url.URL, results chan TestResult) {
 		log.Info("Recording results to InfluxDB: ", u.String())
 	}
 
-	for {
-		result := <-results
+	for result := range results {
 
 		bp, err := client.NewBatchPoints(client.BatchPointsConfig{
 			Database:  db,
@@ -0,0 +1,61 @@
+package hargo
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// inventoryHeader lists the columns written by Inventory.
+var inventoryHeader = []string{"Started", "Method", "URL", "Status", "MIME Type", "Transfer Size (bytes)", "Body Size (bytes)", "Time (ms)"}
+
+// Inventory writes a CSV with one row per entry of a .har file: method,
+// URL, status, MIME type, transfer and body size and total time. Unlike
+// Extract it writes no content, giving a quick spreadsheet-friendly overview
+// of a capture. Unknown sizes are -1.
+func Inventory(r *bufio.Reader, w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(inventoryHeader); err != nil {
+		return err
+	}
+
+	err := forEachEntry(r, func(e Entry) error {
+		return writer.Write([]string{
+			e.StartedDateTime,
+			e.Request.Method,
+			e.Request.URL,
+			strconv.Itoa(e.Response.Status),
+			e.Response.Content.MimeType,
+			strconv.Itoa(transferSize(e.Response)),
+			strconv.Itoa(bodySize(e.Response)),
+			strconv.FormatFloat(float64(e.Time), 'f', -1, 32),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// transferSize returns the bytes received for a response, as recorded by
+// Chrome or computed from the header and body sizes.
+func transferSize(resp Response) int {
+	if resp.TransferSize > 0 {
+		return resp.TransferSize
+	}
+	if resp.HeadersSize < 0 || resp.BodySize < 0 {
+		return -1
+	}
+	return resp.HeadersSize + resp.BodySize
+}
+
+// bodySize returns the decoded size of a response body.
+func bodySize(resp Response) int {
+	if resp.Content.Size > 0 || resp.BodySize < 0 {
+		return resp.Content.Size
+	}
+	return resp.BodySize
+}
@@ -0,0 +1,44 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestInventory(t *testing.T) {
+	doc := `{"log": {"entries": [
+		{"startedDateTime": "2024-01-02T03:04:05.000Z", "time": 12.5,
+		 "request": {"method": "GET", "url": "https://example.com/"},
+		 "response": {"status": 200, "headersSize": 100, "bodySize": 400, "_transferSize": 520,
+		  "content": {"size": 1000, "mimeType": "text/html"}}},
+		{"startedDateTime": "2024-01-02T03:04:06.000Z", "time": 3,
+		 "request": {"method": "POST", "url": "https://example.com/api"},
+		 "response": {"status": 204, "headersSize": -1, "bodySize": 0, "content": {"size": 0}}}
+	]}}`
+
+	var out bytes.Buffer
+	if err := Inventory(bufio.NewReader(strings.NewReader(doc)), &out); err != nil {
+		t.Fatalf("Inventory failed: %v", err)
+	}
+
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		inventoryHeader,
+		{"2024-01-02T03:04:05.000Z", "GET", "https://example.com/", "200", "text/html", "520", "1000", "12.5"},
+		{"2024-01-02T03:04:06.000Z", "POST", "https://example.com/api", "204", "", "-1", "0", "3"},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d records, got %d", len(expected), len(records))
+	}
+	for i := range expected {
+		if strings.Join(records[i], "|") != strings.Join(expected[i], "|") {
+			t.Errorf("record %d: expected %v, got %v", i, expected[i], records[i])
+		}
+	}
+}
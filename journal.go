@@ -0,0 +1,248 @@
+package hargo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// JournalOptions controls the rotation of a Journal. Zero values disable
+// rotation.
+type JournalOptions struct {
+	// MaxSize rotates the journal once it holds this many bytes.
+	MaxSize int64
+	// MaxAge rotates the journal once its first entry is this old.
+	MaxAge time.Duration
+}
+
+// Journal records entries to disk as they are captured, so long capture
+// sessions are not lost on a crash. Entries are appended to a journal file
+// next to the .har file, one JSON entry per line, and the journal is
+// promoted to a valid .har file on rotation and on Close. A journal left
+// behind by a crash is promoted when the next Journal for the same .har
+// file is opened.
+//
+// Promotion never overwrites a file: if the .har file exists, the entries
+// are written to the first free name among foo-1.har, foo-2.har and so on.
+type Journal struct {
+	harPath string
+	opts    JournalOptions
+
+	mu      sync.Mutex
+	file    *os.File
+	w       *bufio.Writer
+	enc     *json.Encoder
+	size    int64
+	entries int
+	started time.Time
+}
+
+// JournalPath returns the path of the journal of the .har file harPath.
+func JournalPath(harPath string) string {
+	return harPath + ".journal"
+}
+
+// OpenJournal opens the journal of harPath for appending, promoting any
+// journal left behind by an earlier session first.
+func OpenJournal(harPath string, opts JournalOptions) (*Journal, error) {
+	if path, n, err := RecoverJournal(harPath); err != nil {
+		return nil, err
+	} else if n > 0 {
+		log.Warnf("Recovered %d entries of an interrupted capture to %s", n, path)
+	}
+
+	j := &Journal{harPath: harPath, opts: opts}
+	if err := j.open(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// open creates an empty journal file.
+func (j *Journal) open() error {
+	file, err := os.OpenFile(JournalPath(j.harPath), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	j.file = file
+	j.w = bufio.NewWriter(file)
+	j.enc = json.NewEncoder(&countingWriter{w: j.w, n: &j.size})
+	j.enc.SetEscapeHTML(false)
+	j.size, j.entries = 0, 0
+	return nil
+}
+
+// Append writes entry to the journal, rotating it first or afterwards if
+// it is due.
+func (j *Journal) Append(entry Entry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return fmt.Errorf("journal %s is closed", JournalPath(j.harPath))
+	}
+	if j.opts.MaxAge > 0 && j.entries > 0 && time.Since(j.started) >= j.opts.MaxAge {
+		if err := j.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if j.entries == 0 {
+		j.started = time.Now()
+	}
+	if err := j.enc.Encode(entry); err != nil {
+		return err
+	}
+	// each entry reaches the file before the next one is captured
+	if err := j.w.Flush(); err != nil {
+		return err
+	}
+	j.entries++
+
+	if j.opts.MaxSize > 0 && j.size >= j.opts.MaxSize {
+		return j.rotate()
+	}
+	return nil
+}
+
+// Rotate promotes the entries journaled so far to a .har file and starts
+// an empty journal.
+func (j *Journal) Rotate() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return fmt.Errorf("journal %s is closed", JournalPath(j.harPath))
+	}
+	return j.rotate()
+}
+
+func (j *Journal) rotate() error {
+	if j.entries == 0 {
+		return nil
+	}
+	if err := j.file.Close(); err != nil {
+		return err
+	}
+	j.file = nil
+	path, n, err := RecoverJournal(j.harPath)
+	if err != nil {
+		return err
+	}
+	log.Infof("Wrote %d entries to %s", n, path)
+	return j.open()
+}
+
+// Close promotes the journal to a .har file. An empty journal is removed.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	if err != nil {
+		return err
+	}
+	path, n, err := RecoverJournal(j.harPath)
+	if err == nil && n > 0 {
+		log.Infof("Wrote %d entries to %s", n, path)
+	}
+	return err
+}
+
+// RecoverJournal promotes the journal of harPath, if any, to a .har file
+// and removes it, returning the path written and the number of entries. A
+// partially written last line, as left by a crash, is dropped. An empty
+// journal is removed without writing a .har file.
+func RecoverJournal(harPath string) (string, int, error) {
+	journalPath := JournalPath(harPath)
+	file, err := os.Open(journalPath)
+	if os.IsNotExist(err) {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	var entries []Entry
+	dec := json.NewDecoder(bufio.NewReader(file))
+	for dec.More() {
+		var entry Entry
+		if err := dec.Decode(&entry); err != nil {
+			log.Warnf("Dropping the incomplete last entry of %s: %v", journalPath, err)
+			break
+		}
+		entries = append(entries, entry)
+	}
+	file.Close()
+
+	if len(entries) == 0 {
+		return "", 0, os.Remove(journalPath)
+	}
+
+	har := NewHar().Har()
+	har.Log.Entries = entries
+
+	path := freeHarPath(harPath)
+	tmp := path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return "", 0, err
+	}
+	w := bufio.NewWriter(out)
+	err = Encode(w, har)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", 0, err
+	}
+
+	return path, len(entries), os.Remove(journalPath)
+}
+
+// freeHarPath returns harPath, or if it exists the first of foo-1.har,
+// foo-2.har... that does not.
+func freeHarPath(harPath string) string {
+	if _, err := os.Stat(harPath); os.IsNotExist(err) {
+		return harPath
+	}
+	ext := filepath.Ext(harPath)
+	base := strings.TrimSuffix(harPath, ext)
+	for i := 1; ; i++ {
+		path := fmt.Sprintf("%s-%d%s", base, i, ext)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+	}
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,103 @@
+package hargo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// K6Options controls the k6 script written by ToK6.
+type K6Options struct {
+	// NoThinkTime leaves out the sleeps between requests.
+	NoThinkTime bool
+	// VUs and Iterations are the virtual users and total iterations of the
+	// script's options, 1 if zero.
+	VUs        int
+	Iterations int
+}
+
+// ToK6 writes a k6 script that replays the requests of har in order,
+// grouped by page, sleeping for the recorded gaps between requests and
+// checking the recorded status codes. Redirects are not followed, as the
+// capture contains the redirected requests.
+func ToK6(har Har, w io.Writer, opts K6Options) error {
+	vus, iterations := opts.VUs, opts.Iterations
+	if vus <= 0 {
+		vus = 1
+	}
+	if iterations <= 0 {
+		iterations = 1
+	}
+
+	var b strings.Builder
+	b.WriteString("// Generated by hargo from a .har capture.\n")
+	b.WriteString("import http from 'k6/http';\n")
+	b.WriteString("import { check, group, sleep } from 'k6';\n\n")
+	fmt.Fprintf(&b, "export const options = {\n  vus: %d,\n  iterations: %d,\n  maxRedirects: 0,\n};\n\n", vus, iterations)
+	b.WriteString("export default function () {\n  let res;\n")
+
+	gaps := thinkTimes(har.Log.Entries)
+	setCookies := make(map[string]bool)
+	for _, group := range scriptGroups(har) {
+		fmt.Fprintf(&b, "\n  group(%s, function () {\n", jsString(group.name))
+		for _, i := range group.entries {
+			entry := har.Log.Entries[i]
+			if !opts.NoThinkTime && gaps[i] > 0 {
+				fmt.Fprintf(&b, "    sleep(%g);\n", gaps[i].Seconds())
+			}
+
+			var params []string
+			if headers := scriptHeaders(entry.Request); len(headers) > 0 {
+				var lines []string
+				for _, h := range headers {
+					lines = append(lines, fmt.Sprintf("        %s: %s,", jsString(h.Name), jsString(h.Value)))
+				}
+				params = append(params, "      headers: {\n"+strings.Join(lines, "\n")+"\n      },")
+			}
+			if cookies := initialCookies(entry.Request, setCookies); len(cookies) > 0 {
+				var lines []string
+				for _, c := range cookies {
+					lines = append(lines, fmt.Sprintf("        %s: %s,", jsString(c.Name), jsString(c.Value)))
+				}
+				params = append(params, "      cookies: {\n"+strings.Join(lines, "\n")+"\n      },")
+			}
+			for _, c := range entry.Response.Cookies {
+				setCookies[c.Name] = true
+			}
+
+			body := "null"
+			if text := scriptBody(entry.Request); text != "" {
+				body = jsString(text)
+			}
+			paramsJS := "{}"
+			if len(params) > 0 {
+				paramsJS = "{\n" + strings.Join(params, "\n") + "\n    }"
+			}
+			fmt.Fprintf(&b, "    res = http.request(%s, %s, %s, %s);\n",
+				jsString(strings.ToUpper(entry.Request.Method)), jsString(entry.Request.URL), body, paramsJS)
+			if entry.Response.Status > 0 {
+				fmt.Fprintf(&b, "    check(res, { 'status is %d': (r) => r.status === %d });\n",
+					entry.Response.Status, entry.Response.Status)
+			}
+		}
+		b.WriteString("  });\n")
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ToK6File converts a .har file to a k6 script written to w.
+func ToK6File(r *bufio.Reader, w io.Writer, opts K6Options) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Converted %d entries to a k6 script", len(har.Log.Entries))
+	return ToK6(har, w, opts)
+}
@@ -0,0 +1,108 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// scriptTestHar is a capture of two requests of one page, 1.5s apart, for
+// the load test script exporters.
+func scriptTestHar() Har {
+	return Har{Log: Log{
+		Pages: []Page{{ID: "page_1", Title: "Home"}},
+		Entries: []Entry{
+			{
+				Pageref: "page_1", StartedDateTime: "2024-01-02T10:00:00.000Z", Time: 100,
+				Request: Request{Method: "GET", URL: "https://example.com/",
+					Headers: []NVP{{Name: ":authority", Value: "example.com"}, {Name: "Accept", Value: "text/html"}, {Name: "Cookie", Value: "pref=1"}},
+					Cookies: []Cookie{{Name: "pref", Value: "1"}}},
+				Response: Response{Status: 200, Cookies: []Cookie{{Name: "session", Value: "abc"}}},
+			},
+			{
+				Pageref: "page_1", StartedDateTime: "2024-01-02T10:00:01.600Z", Time: 50,
+				Request: Request{Method: "post", URL: "https://example.com/api",
+					Cookies:  []Cookie{{Name: "session", Value: "abc"}},
+					PostData: PostData{MimeType: "application/json", Text: `{"q":"</script>"}`}},
+				Response: Response{Status: 201},
+			},
+		},
+	}}
+}
+
+func TestToK6(t *testing.T) {
+	har := scriptTestHar()
+
+	var out bytes.Buffer
+	if err := ToK6(har, &out, K6Options{}); err != nil {
+		t.Fatal(err)
+	}
+	script := out.String()
+
+	for _, expected := range []string{
+		"import http from 'k6/http';",
+		"maxRedirects: 0,",
+		`group("Home", function () {`,
+		`res = http.request("GET", "https://example.com/", null, {`,
+		`"Accept": "text/html",`,
+		`"pref": "1",`,
+		"sleep(1.5);",
+		`res = http.request("POST", "https://example.com/api", "{\"q\":\"</script>\"}", {});`,
+		"check(res, { 'status is 201': (r) => r.status === 201 });",
+	} {
+		if !strings.Contains(script, expected) {
+			t.Errorf("script does not contain %s:\n%s", expected, script)
+		}
+	}
+	if strings.Contains(script, ":authority") || strings.Contains(script, `"Cookie"`) || strings.Contains(script, `"session"`) {
+		t.Errorf("script contains headers or cookies it should not:\n%s", script)
+	}
+
+	out.Reset()
+	ToK6(har, &out, K6Options{NoThinkTime: true})
+	if strings.Contains(out.String(), "sleep(") {
+		t.Error("unexpected sleep")
+	}
+}
+
+func TestScriptsSkipWebSockets(t *testing.T) {
+	data, err := os.ReadFile("test/websocket.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	har, err := Decode(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scripts := map[string]func(w io.Writer) error{
+		"k6":      func(w io.Writer) error { return ToK6(har, w, K6Options{}) },
+		"gatling": func(w io.Writer) error { return ToGatling(har, w, GatlingOptions{}) },
+		"locust":  func(w io.Writer) error { return ToLocust(har, w, LocustOptions{}) },
+		"curl": func(w io.Writer) error {
+			command, err := ToCurl(bufio.NewReader(bytes.NewReader(data)))
+			io.WriteString(w, command)
+			return err
+		},
+	}
+	for name, write := range scripts {
+		var out bytes.Buffer
+		if err := write(&out); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if strings.Contains(out.String(), "ws://") || strings.Contains(out.String(), "101") {
+			t.Errorf("%s: WebSocket entry in script:\n%s", name, out.String())
+		}
+		if !strings.Contains(out.String(), "http://example.com/messages") {
+			t.Errorf("%s: HTTP entry missing from script:\n%s", name, out.String())
+		}
+	}
+
+	// the WebSocket connection took the first second, it is no think time
+	if gaps := thinkTimes(har.Log.Entries); gaps[1] != 0 {
+		t.Errorf("got think time %v", gaps[1])
+	}
+}
@@ -0,0 +1,323 @@
+package hargo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var lenient atomic.Bool
+
+// SetLenient enables or disables lenient decoding. While enabled, Decode
+// repairs the deviations from the HAR spec handled by DecodeLenient and logs
+// a warning for each repair instead of failing.
+func SetLenient(enabled bool) {
+	lenient.Store(enabled)
+}
+
+// Lenient reports whether lenient decoding is enabled.
+func Lenient() bool {
+	return lenient.Load()
+}
+
+// Repair describes a deviation from the HAR spec fixed by DecodeLenient.
+type Repair struct {
+	// Path locates the repaired value, e.g. log.entries[3].timings.wait.
+	Path    string
+	Message string
+}
+
+func (r Repair) String() string {
+	return r.Path + ": " + r.Message
+}
+
+// DecodeLenient decodes a .har file like Decode, but tolerates deviations
+// seen in files written by Safari, Firefox, older HAR 1.1 tools and
+// proxies, normalizing them into the canonical model:
+//   - numbers written as strings ("12.5") and strings written as numbers
+//     are converted, booleans written as strings are parsed
+//   - values of the wrong type, e.g. vendor (_-prefixed) extensions that
+//     clash with the model, are dropped
+//   - missing required fields (version, creator, method, HTTP version,
+//     startedDateTime) are filled with defaults or the previous entry's
+//   - negative timings other than -1 become -1 for optional and 0 for
+//     required phases, and a negative total time is recomputed
+//
+// It returns every repair made. Only malformed JSON is an error.
+func DecodeLenient(r *bufio.Reader) (Har, []Repair, error) {
+	var har Har
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return har, nil, err
+	}
+
+	var repairs []Repair
+	tree = repairValue(tree, reflect.TypeOf(har), "", &repairs)
+
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return har, repairs, err
+	}
+	if err := json.Unmarshal(data, &har); err != nil {
+		return har, repairs, err
+	}
+
+	repairHar(&har, &repairs)
+	return har, repairs, nil
+}
+
+// dropValue is returned by repairValue for values that must be removed.
+var dropValue = new(struct{})
+
+// repairValue converts a generic JSON value to fit the Go type t, recording
+// every change in repairs. It returns dropValue for values that cannot be
+// converted.
+func repairValue(v interface{}, t reflect.Type, path string, repairs *[]Repair) interface{} {
+	if v == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(json.RawMessage{}) {
+		return v
+	}
+
+	mismatch := func() interface{} {
+		*repairs = append(*repairs, Repair{path, fmt.Sprintf("expected %s, dropped %s", t.Kind(), jsonKind(v))})
+		return dropValue
+	}
+	repaired := func(value interface{}, format string, args ...interface{}) interface{} {
+		*repairs = append(*repairs, Repair{path, fmt.Sprintf(format, args...)})
+		return value
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch()
+		}
+		fields := jsonFields(t)
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := object[key]
+			field, ok := fields[key]
+			if !ok {
+				continue
+			}
+			if value = repairValue(value, field, joinPath(path, key), repairs); value == dropValue {
+				delete(object, key)
+			} else {
+				object[key] = value
+			}
+		}
+		return object
+
+	case reflect.Slice:
+		array, ok := v.([]interface{})
+		if !ok {
+			return mismatch()
+		}
+		kept := array[:0]
+		for i, value := range array {
+			if value = repairValue(value, t.Elem(), fmt.Sprintf("%s[%d]", path, i), repairs); value != dropValue {
+				kept = append(kept, value)
+			}
+		}
+		return kept
+
+	case reflect.String:
+		switch value := v.(type) {
+		case string:
+			return value
+		case json.Number:
+			return repaired(value.String(), "number %s converted to string", value)
+		case bool:
+			return repaired(strconv.FormatBool(value), "boolean %t converted to string", value)
+		}
+		return mismatch()
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Float32, reflect.Float64:
+		var f float64
+		switch value := v.(type) {
+		case json.Number:
+			var err error
+			if f, err = value.Float64(); err != nil {
+				return mismatch()
+			}
+			if t.Kind() >= reflect.Float32 {
+				return value
+			}
+			if _, err := value.Int64(); err == nil {
+				return value
+			}
+			return repaired(json.Number(strconv.FormatFloat(math.Round(f), 'f', -1, 64)), "%s rounded to an integer", value)
+		case string:
+			s := strings.TrimSpace(value)
+			if s == "" {
+				return repaired(dropValue, "empty string dropped")
+			}
+			var err error
+			if f, err = strconv.ParseFloat(s, 64); err != nil {
+				return mismatch()
+			}
+			if t.Kind() < reflect.Float32 {
+				f = math.Round(f)
+			}
+			return repaired(json.Number(strconv.FormatFloat(f, 'f', -1, 64)), "string %q converted to number", value)
+		}
+		return mismatch()
+
+	case reflect.Bool:
+		switch value := v.(type) {
+		case bool:
+			return value
+		case string:
+			if b, err := strconv.ParseBool(value); err == nil {
+				return repaired(b, "string %q converted to boolean", value)
+			}
+		}
+		return mismatch()
+	}
+
+	return v
+}
+
+// jsonKind names the JSON type of a generic value.
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+var jsonFieldCache sync.Map
+
+// jsonFields maps the JSON names of the fields of struct type t to their
+// types.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	if fields, ok := jsonFieldCache.Load(t); ok {
+		return fields.(map[string]reflect.Type)
+	}
+
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	jsonFieldCache.Store(t, fields)
+	return fields
+}
+
+// repairHar fills missing required fields and fixes invalid timings.
+func repairHar(har *Har, repairs *[]Repair) {
+	repair := func(path, format string, args ...interface{}) {
+		*repairs = append(*repairs, Repair{path, fmt.Sprintf(format, args...)})
+	}
+
+	if har.Log.Version == "" {
+		har.Log.Version = "1.2"
+		repair("log.version", "missing, assumed 1.2")
+	}
+	if har.Log.Creator.Name == "" {
+		har.Log.Creator.Name = "unknown"
+		repair("log.creator.name", "missing, set to unknown")
+	}
+
+	previous := ""
+	for i := range har.Log.Entries {
+		entry := &har.Log.Entries[i]
+		path := fmt.Sprintf("log.entries[%d]", i)
+
+		if entry.StartedDateTime == "" && previous != "" {
+			entry.StartedDateTime = previous
+			repair(path+".startedDateTime", "missing, set to the previous entry's %s", previous)
+		}
+		previous = entry.StartedDateTime
+
+		if entry.Request.Method == "" {
+			entry.Request.Method = "GET"
+			repair(path+".request.method", "missing, assumed GET")
+		}
+		if entry.Request.HTTPVersion == "" {
+			entry.Request.HTTPVersion = "HTTP/1.1"
+			repair(path+".request.httpVersion", "missing, assumed HTTP/1.1")
+		}
+		if entry.Response.HTTPVersion == "" && entry.Response.Status > 0 {
+			entry.Response.HTTPVersion = entry.Request.HTTPVersion
+			repair(path+".response.httpVersion", "missing, set to the request's %s", entry.Request.HTTPVersion)
+		}
+
+		timings := &entry.Timings
+		for _, timing := range []struct {
+			name     string
+			value    *float64
+			optional bool
+		}{
+			{"blocked", &timings.Blocked, true},
+			{"dns", &timings.DNS, true},
+			{"connect", &timings.Connect, true},
+			{"ssl", &timings.Ssl, true},
+			{"send", &timings.Send, false},
+			{"wait", &timings.Wait, false},
+			{"receive", &timings.Receive, false},
+		} {
+			if *timing.value >= 0 || (timing.optional && *timing.value == -1) {
+				continue
+			}
+			invalid := *timing.value
+			if timing.optional {
+				*timing.value = -1
+			} else {
+				*timing.value = 0
+			}
+			repair(path+".timings."+timing.name, "negative value %g set to %g", invalid, *timing.value)
+		}
+
+		if entry.Time < 0 {
+			invalid := entry.Time
+			entry.Time = float32(positive(timings.Blocked) + positive(timings.DNS) + positive(timings.Connect) +
+				timings.Send + timings.Wait + timings.Receive)
+			repair(path+".time", "negative value %g recomputed as %g from the timings", invalid, entry.Time)
+		}
+	}
+}
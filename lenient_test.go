@@ -0,0 +1,105 @@
+package hargo
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+const quirkyHAR = `{
+  "log": {
+    "version": "1.1",
+    "creator": {"name": "WebKit", "version": 605},
+    "entries": [
+      {
+        "startedDateTime": "2024-01-02T10:00:00.000Z",
+        "time": "120.5",
+        "request": {"method": "GET", "url": "https://example.com/", "headersSize": "312", "bodySize": -1,
+          "cookies": [{"name": "a", "value": "b", "comment": "session cookie", "httpOnly": "true"}]},
+        "response": {"status": "200", "statusText": "OK", "httpVersion": "HTTP/2",
+          "content": {"size": 10.0, "mimeType": "text/html"}, "bodySize": 10.7, "_transferSize": "n/a"},
+        "timings": {"blocked": -0.5, "dns": -1, "connect": -1, "send": -1, "wait": "100", "receive": 20.5},
+        "_initiator": "https://example.com/app.js"
+      },
+      {
+        "time": -3,
+        "request": {"url": "https://example.com/app.js"},
+        "response": {"status": 200},
+        "timings": {"send": 1, "wait": 2, "receive": 3}
+      }
+    ]
+  }
+}`
+
+func TestDecodeLenient(t *testing.T) {
+	if _, err := readHar(bufio.NewReader(strings.NewReader(quirkyHAR))); err == nil {
+		t.Fatal("expected strict decoding to fail")
+	}
+
+	har, repairs, err := DecodeLenient(bufio.NewReader(strings.NewReader(quirkyHAR)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if har.Log.Version != "1.1" || har.Log.Creator.Version != "605" {
+		t.Errorf("unexpected log %+v", har.Log)
+	}
+
+	first := har.Log.Entries[0]
+	if first.Time != 120.5 || first.Request.HeaderSize != 312 || first.Response.Status != 200 || first.Response.BodySize != 11 {
+		t.Errorf("numbers not converted: %+v", first)
+	}
+	if first.Response.TransferSize != 0 || first.Initiator != nil {
+		t.Errorf("clashing vendor extensions not dropped: %+v", first)
+	}
+	if c := first.Request.Cookies[0]; c.Name != "a" || !c.HTTPOnly || c.Comment {
+		t.Errorf("unexpected cookie %+v", c)
+	}
+	if tm := first.Timings; tm.Blocked != -1 || tm.DNS != -1 || tm.Send != 0 || tm.Wait != 100 {
+		t.Errorf("timings not repaired: %+v", tm)
+	}
+
+	second := har.Log.Entries[1]
+	if second.StartedDateTime != first.StartedDateTime || second.Request.Method != "GET" || second.Request.HTTPVersion != "HTTP/1.1" {
+		t.Errorf("missing fields not filled: %+v", second)
+	}
+	if second.Time != 6 {
+		t.Errorf("negative time not recomputed: %g", second.Time)
+	}
+
+	got := make(map[string]bool)
+	for _, r := range repairs {
+		got[r.Path] = true
+	}
+	for _, path := range []string{
+		"log.creator.version",
+		"log.entries[0].time",
+		"log.entries[0].request.cookies[0].comment",
+		"log.entries[0].response._transferSize",
+		"log.entries[0]._initiator",
+		"log.entries[0].timings.blocked",
+		"log.entries[0].timings.send",
+		"log.entries[1].startedDateTime",
+		"log.entries[1].time",
+	} {
+		if !got[path] {
+			t.Errorf("no repair reported for %s", path)
+		}
+	}
+	if got["log.entries[0].timings.dns"] {
+		t.Error("dns -1 should not be repaired")
+	}
+}
+
+func TestDecodeLenientMode(t *testing.T) {
+	SetLenient(true)
+	defer SetLenient(false)
+
+	har, err := Decode(bufio.NewReader(strings.NewReader(quirkyHAR)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(har.Log.Entries) != 2 {
+		t.Errorf("expected 2 entries, got %d", len(har.Log.Entries))
+	}
+}
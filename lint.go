@@ -0,0 +1,330 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Severities of lint findings, from most to least severe.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+var lintSeverityRank = map[string]int{SeverityError: 3, SeverityWarning: 2, SeverityInfo: 1}
+
+// LintFinding is a violation of a lint rule by one entry.
+type LintFinding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	// Entry is the index of the entry in the .har file.
+	Entry   int    `json:"entry"`
+	URL     string `json:"url"`
+	Message string `json:"message"`
+}
+
+// LintRule checks the entries of a .har file, e.g. as a contract check in
+// CI. Rules are usually defined in a file, see LoadLintRules, but any
+// check can be added by implementing the interface.
+type LintRule interface {
+	Lint(index int, entry Entry) []LintFinding
+}
+
+// LintRuleFunc adapts a function to the LintRule interface.
+type LintRuleFunc func(index int, entry Entry) []LintFinding
+
+// Lint calls f.
+func (f LintRuleFunc) Lint(index int, entry Entry) []LintFinding {
+	return f(index, entry)
+}
+
+// LintRuleConfig is a lint rule of a lint file. The selectors URL,
+// Domain, Method and MimeType restrict it to matching entries, all entries
+// if none is set, and every check that is set must hold for them.
+type LintRuleConfig struct {
+	ID string `json:"id"`
+	// Severity is error (the default), warning or info.
+	Severity string `json:"severity"`
+	// Message replaces the description of the violated check.
+	Message string `json:"message"`
+
+	// URL is a regular expression matching request URLs.
+	URL string `json:"url"`
+	// Domain matches request hosts and their subdomains, and may contain
+	// wildcards, e.g. *.doubleclick.net.
+	Domain string `json:"domain"`
+	Method string `json:"method"`
+	// MimeType matches response MIME types, several with a trailing
+	// wildcard, e.g. image/*.
+	MimeType string `json:"mimeType"`
+
+	// Deny forbids matching entries altogether.
+	Deny bool `json:"deny"`
+	// ExpectStatus and DenyStatus are comma separated status codes and
+	// classes, e.g. "2xx, 304" or "5xx".
+	ExpectStatus StatusList `json:"expectStatus"`
+	DenyStatus   StatusList `json:"denyStatus"`
+	// ExpectMimeType is the required response MIME type, or several with
+	// a trailing wildcard.
+	ExpectMimeType string `json:"expectMimeType"`
+	// RequireHeader is a response header that must be present.
+	RequireHeader string `json:"requireHeader"`
+	// RequireHTTPS forbids plain http:// and ws:// requests.
+	RequireHTTPS bool `json:"requireHttps"`
+	// MaxTime is the longest allowed total time in milliseconds.
+	MaxTime float32 `json:"maxTime"`
+	// MaxSize is the largest allowed response body, e.g. 500KB.
+	MaxSize ByteSize `json:"maxSize"`
+
+	url *regexp.Regexp
+}
+
+// StatusList is a comma separated list of status codes and classes. It
+// unmarshals from a JSON string or a single number.
+type StatusList string
+
+// UnmarshalJSON accepts "2xx, 304" or 304.
+func (s *StatusList) UnmarshalJSON(b []byte) error {
+	var code int
+	if err := json.Unmarshal(b, &code); err == nil {
+		*s = StatusList(strconv.Itoa(code))
+		return nil
+	}
+	return json.Unmarshal(b, (*string)(s))
+}
+
+// LintConfig is the content of a lint file.
+type LintConfig struct {
+	Rules []LintRuleConfig `json:"rules"`
+}
+
+// LoadLintRules reads lint rules from a JSON file or a YAML file using
+// block mappings and sequences only:
+//
+//	rules:
+//	  - id: no-trackers
+//	    domain: doubleclick.net
+//	    deny: true
+//	  - id: api-json
+//	    severity: warning
+//	    url: /api/
+//	    expectMimeType: application/json
+//	  - id: no-5xx
+//	    denyStatus: 5xx
+func LoadLintRules(path string) ([]LintRule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		if b, err = yamlToJSON(b, &LintConfig{}); err != nil {
+			return nil, fmt.Errorf("invalid lint rules %s: %v", path, err)
+		}
+	}
+
+	var config LintConfig
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("invalid lint rules %s: %v", path, err)
+	}
+	var rules []LintRule
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		if err := rule.compile(i); err != nil {
+			return nil, fmt.Errorf("invalid lint rules %s: %v", path, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// compile checks the rule and fills in its defaults.
+func (r *LintRuleConfig) compile(i int) error {
+	if r.ID == "" {
+		r.ID = "rule-" + strconv.Itoa(i+1)
+	}
+	if r.Severity == "" {
+		r.Severity = SeverityError
+	}
+	if _, ok := lintSeverityRank[r.Severity]; !ok {
+		return fmt.Errorf("%s: unknown severity %q", r.ID, r.Severity)
+	}
+	if r.URL != "" {
+		re, err := regexp.Compile(r.URL)
+		if err != nil {
+			return fmt.Errorf("%s: %v", r.ID, err)
+		}
+		r.url = re
+	}
+	if _, err := path.Match(r.Domain, ""); err != nil {
+		return fmt.Errorf("%s: invalid domain %q", r.ID, r.Domain)
+	}
+	for _, statuses := range []StatusList{r.ExpectStatus, r.DenyStatus} {
+		if _, err := matchStatus(statuses, 0); err != nil {
+			return fmt.Errorf("%s: %v", r.ID, err)
+		}
+	}
+	if !r.Deny && r.ExpectStatus == "" && r.DenyStatus == "" && r.ExpectMimeType == "" && r.RequireHeader == "" && !r.RequireHTTPS && r.MaxTime <= 0 && r.MaxSize <= 0 {
+		return fmt.Errorf("%s: no check", r.ID)
+	}
+	return nil
+}
+
+// matches reports whether the rule applies to entry.
+func (r *LintRuleConfig) matches(entry Entry, u *url.URL) bool {
+	if r.url != nil && !r.url.MatchString(entry.Request.URL) {
+		return false
+	}
+	if r.Domain != "" {
+		domain, host := strings.ToLower(r.Domain), strings.ToLower(u.Hostname())
+		matched, _ := path.Match(domain, host)
+		if !matched && host != domain && !strings.HasSuffix(host, "."+domain) {
+			return false
+		}
+	}
+	if r.Method != "" && !strings.EqualFold(r.Method, entry.Request.Method) {
+		return false
+	}
+	return r.MimeType == "" || matchMimeType(r.MimeType, entry.Response.Content.MimeType)
+}
+
+// Lint checks an entry against the rule.
+func (r *LintRuleConfig) Lint(index int, entry Entry) []LintFinding {
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		u = &url.URL{}
+	}
+	if !r.matches(entry, u) {
+		return nil
+	}
+
+	var violations []string
+	if r.Deny {
+		violations = append(violations, "request is denied")
+	}
+	if r.ExpectStatus != "" {
+		if ok, _ := matchStatus(r.ExpectStatus, entry.Response.Status); !ok {
+			violations = append(violations, fmt.Sprintf("status %d, expected %s", entry.Response.Status, r.ExpectStatus))
+		}
+	}
+	if r.DenyStatus != "" {
+		if denied, _ := matchStatus(r.DenyStatus, entry.Response.Status); denied {
+			violations = append(violations, fmt.Sprintf("status %d is denied (%s)", entry.Response.Status, r.DenyStatus))
+		}
+	}
+	if r.ExpectMimeType != "" && !matchMimeType(r.ExpectMimeType, entry.Response.Content.MimeType) {
+		violations = append(violations, fmt.Sprintf("MIME type %q, expected %s", entry.Response.Content.MimeType, r.ExpectMimeType))
+	}
+	if r.RequireHeader != "" && recordedHeader(entry.Response.Headers, r.RequireHeader) == "" {
+		violations = append(violations, "missing response header "+r.RequireHeader)
+	}
+	if r.RequireHTTPS && (u.Scheme == "http" || u.Scheme == "ws") {
+		violations = append(violations, "insecure "+u.Scheme+":// request")
+	}
+	if r.MaxTime > 0 && entry.Time > r.MaxTime {
+		violations = append(violations, fmt.Sprintf("took %.0fms, limit %.0fms", entry.Time, r.MaxTime))
+	}
+	if r.MaxSize > 0 {
+		if body, err := entry.DecodedBody(); err == nil {
+			size := ByteSize(max(len(body), entry.Response.Content.Size))
+			if size > r.MaxSize {
+				violations = append(violations, fmt.Sprintf("response body of %s, limit %s", size, r.MaxSize))
+			}
+		}
+	}
+
+	var findings []LintFinding
+	for _, v := range violations {
+		if r.Message != "" {
+			v = r.Message
+		}
+		findings = append(findings, LintFinding{Rule: r.ID, Severity: r.Severity, Entry: index, URL: entry.Request.URL, Message: v})
+	}
+	return findings
+}
+
+// matchStatus reports whether status is in a comma separated list of
+// status codes and classes like "2xx, 304".
+func matchStatus(statuses StatusList, status int) (bool, error) {
+	matched := false
+	for _, s := range strings.Split(string(statuses), ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" {
+			continue
+		}
+		if class, ok := strings.CutSuffix(s, "xx"); ok && len(class) == 1 && class[0] >= '1' && class[0] <= '5' {
+			matched = matched || status/100 == int(class[0]-'0')
+			continue
+		}
+		code, err := strconv.Atoi(s)
+		if err != nil || code < 100 || code > 599 {
+			return false, fmt.Errorf("invalid status %q", s)
+		}
+		matched = matched || status == code
+	}
+	return matched, nil
+}
+
+// Lint checks every entry of a .har file against the rules and returns
+// the findings in entry order.
+func Lint(har Har, rules []LintRule) []LintFinding {
+	var findings []LintFinding
+	for i, entry := range har.Log.Entries {
+		for _, rule := range rules {
+			findings = append(findings, rule.Lint(i, entry)...)
+		}
+	}
+	return findings
+}
+
+// LintFile lints a .har file, writes the findings with a summary to w and
+// returns them.
+func LintFile(r *bufio.Reader, w io.Writer, rules []LintRule) ([]LintFinding, error) {
+	har, err := Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := Lint(har, rules)
+	counts := make(map[string]int)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if len(findings) > 0 {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", "Severity", "Rule", "Entry", "URL", "Message")
+	}
+	for _, f := range findings {
+		counts[f.Severity]++
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t\n", f.Severity, f.Rule, f.Entry, shortURL(f.URL), f.Message)
+	}
+	if err := tw.Flush(); err != nil {
+		return findings, err
+	}
+	_, err = fmt.Fprintf(w, "Lint: %d findings (%d errors, %d warnings, %d info)\n",
+		len(findings), counts[SeverityError], counts[SeverityWarning], counts[SeverityInfo])
+	return findings, err
+}
+
+// LintFailed reports whether any finding is at least as severe as
+// severity.
+func LintFailed(findings []LintFinding, severity string) bool {
+	rank, ok := lintSeverityRank[severity]
+	if !ok {
+		rank = lintSeverityRank[SeverityError]
+	}
+	for _, f := range findings {
+		if r, ok := lintSeverityRank[f.Severity]; !ok || r >= rank {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testLintRules = `# contract of the shop
+rules:
+  - id: no-trackers
+    domain: doubleclick.net
+    deny: true
+  - id: api-json
+    severity: warning
+    url: /api/
+    expectMimeType: application/json
+  - id: no-5xx
+    denyStatus: 5xx
+    message: server error
+  - id: cached-images
+    severity: info
+    mimeType: image/*
+    requireHeader: Cache-Control
+    maxSize: 1KiB
+`
+
+func TestLoadLintRules(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lint.yaml")
+	os.WriteFile(path, []byte(testLintRules), 0644)
+
+	rules, err := LoadLintRules(path)
+	if err != nil {
+		t.Fatalf("LoadLintRules failed: %v", err)
+	}
+	got, _ := json.Marshal(Lint(readTestHar(t, "lint.har"), rules))
+	expected := `[{"rule":"api-json","severity":"warning","entry":1,"url":"https://example.com/api/cart","message":"MIME type \"text/html\", expected application/json"},` +
+		`{"rule":"no-5xx","severity":"error","entry":1,"url":"https://example.com/api/cart","message":"server error"},` +
+		`{"rule":"no-trackers","severity":"error","entry":2,"url":"https://stats.g.doubleclick.net/collect","message":"request is denied"},` +
+		`{"rule":"cached-images","severity":"info","entry":3,"url":"https://example.com/logo.png","message":"missing response header Cache-Control"},` +
+		`{"rule":"cached-images","severity":"info","entry":3,"url":"https://example.com/logo.png","message":"response body of 2.0 KiB, limit 1.0 KiB"}]`
+	if string(got) != expected {
+		t.Errorf("got %s, want %s", got, expected)
+	}
+
+	tests := []string{
+		`{"rules": [{"id": "a", "severity": "fatal", "deny": true}]}`,
+		`{"rules": [{"id": "a", "url": "(", "deny": true}]}`,
+		`{"rules": [{"id": "a", "denyStatus": "6xx"}]}`,
+		`{"rules": [{"id": "a", "domain": "example.com"}]}`,
+		"rules:\n  - id: a\n    deny: maybe\n",
+	}
+	for i, test := range tests {
+		os.WriteFile(path, []byte(test), 0644)
+		if _, err := LoadLintRules(path); err == nil {
+			t.Errorf("%d: expected an error", i)
+		}
+	}
+}
+
+func TestMatchStatus(t *testing.T) {
+	tests := []struct {
+		statuses StatusList
+		status   int
+		matched  bool
+	}{
+		{"2xx, 304", 204, true},
+		{"2xx, 304", 304, true},
+		{"2xx, 304", 301, false},
+		{"5XX", 503, true},
+		{"404", 404, true},
+		{"", 200, false},
+	}
+	for i, test := range tests {
+		if got, err := matchStatus(test.statuses, test.status); err != nil || got != test.matched {
+			t.Errorf("%d: got %v, %v", i, got, err)
+		}
+	}
+
+	var statuses StatusList
+	if err := json.Unmarshal([]byte("304"), &statuses); err != nil || statuses != "304" {
+		t.Errorf("got %q, %v", statuses, err)
+	}
+}
+
+func TestLintFile(t *testing.T) {
+	rules := []LintRule{
+		&LintRuleConfig{ID: "no-5xx", Severity: SeverityError, DenyStatus: "5xx"},
+		LintRuleFunc(func(index int, entry Entry) []LintFinding {
+			if strings.Contains(entry.Request.URL, "doubleclick") {
+				return []LintFinding{{Rule: "custom", Severity: SeverityWarning, Entry: index, URL: entry.Request.URL, Message: "tracker"}}
+			}
+			return nil
+		}),
+	}
+	data, err := os.ReadFile("test/lint.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	findings, err := LintFile(bufio.NewReader(bytes.NewReader(data)), &out, rules)
+	if err != nil || len(findings) != 2 {
+		t.Fatalf("got %v, %v", findings, err)
+	}
+	if !strings.Contains(out.String(), "Lint: 2 findings (1 errors, 1 warnings, 0 info)") {
+		t.Errorf("unexpected report %s", out.String())
+	}
+
+	if !LintFailed(findings, SeverityError) {
+		t.Error("expected an error to fail")
+	}
+	if LintFailed(findings[1:], SeverityError) || !LintFailed(findings[1:], SeverityWarning) {
+		t.Error("a warning should only fail from --fail-on warning")
+	}
+}
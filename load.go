@@ -1,13 +1,15 @@
 package hargo
 
 import (
-	"crypto/tls"
+	"context"
 	"fmt"
-	"net"
+	"io"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -16,14 +18,74 @@ import (
 // LoadTest executes all HTTP requests in order concurrently
 // for a given number of workers.
 func LoadTest(harfile string, file *os.File, workers int, timeout time.Duration, u url.URL, ignoreHarCookies bool, insecureSkipVerify bool) error {
+	return LoadTestContext(context.Background(), harfile, file, workers, timeout, u, ignoreHarCookies, insecureSkipVerify)
+}
+
+// LoadTestContext is like LoadTest, but also stops when ctx is done before
+// the timeout, aborting the requests in progress, and then returns
+// ctx.Err().
+func LoadTestContext(ctx context.Context, harfile string, file *os.File, workers int, timeout time.Duration, u url.URL, ignoreHarCookies bool, insecureSkipVerify bool) error {
+	return LoadTestConfig(ctx, harfile, file, workers, timeout, u, Config{IgnoreHarCookies: ignoreHarCookies, InsecureSkipVerify: insecureSkipVerify})
+}
+
+// LoadTestConfig is like LoadTestContext, but uses the settings of a
+// scenario Config: its request rules are applied to every request, and each
+// worker correlates the dynamic values of the responses it receives and
+// fills placeholders from its own row of cfg.Data. The assertions of cfg
+// are not checked; its retry and failure policies apply to the requests of
+// all workers together, and its load profile limits their combined rate.
+// If cfg.MetricsAddr is set, Prometheus metrics of the requests are exposed
+// on it while the test runs.
+func LoadTestConfig(ctx context.Context, harfile string, file *os.File, workers int, timeout time.Duration, u url.URL, cfg Config) error {
+	_, err := runLoadTest(ctx, harfile, file, workers, timeout, u, cfg)
+	return err
+}
+
+// runLoadTest performs LoadTestConfig and returns its counts and latencies,
+// which are also returned when the test is aborted or cancelled.
+func runLoadTest(ctx context.Context, harfile string, file *os.File, workers int, timeout time.Duration, u url.URL, cfg Config) (LoadResult, error) {
+	if err := requireNetwork("load"); err != nil {
+		return LoadResult{}, err
+	}
+	if _, err := NewCorrelator(cfg.Correlations); err != nil {
+		return LoadResult{}, err
+	}
+	tracker, err := newFailureTracker(cfg)
+	if err != nil {
+		return LoadResult{}, err
+	}
+	if _, err := newTransport(cfg); err != nil {
+		return LoadResult{}, err
+	}
+	if cfg.Auth, err = cfg.authProvider(); err != nil {
+		return LoadResult{}, err
+	}
+	stages, err := cfg.Profile.stages(timeout)
+	if err != nil {
+		return LoadResult{}, err
+	}
+
+	var metrics *Metrics
+	if cfg.MetricsAddr != "" {
+		metrics = NewMetrics("load")
+		metricsCtx, stopMetrics := context.WithCancel(ctx)
+		defer stopMetrics()
+		if err := ServeMetrics(metricsCtx, cfg.MetricsAddr, metrics); err != nil {
+			return LoadResult{}, err
+		}
+	}
+
 	log.Infof("Starting load test with %d workers. Duration %v.", workers, timeout)
 
+	emitPhase(EventPhaseStarted, "load")
+	defer emitPhase(EventPhaseFinished, "load")
+
 	results := make(chan TestResult)
 	defer close(results)
 	stop := make(chan bool)
 	entries := make(chan Entry, workers)
 
-	go ReadStream(file, entries, stop)
+	go ReadStreamWithSelection(file, cfg.Selection, entries, stop)
 
 	// if a InfluxDB URL is given the metrics will be written to that instance
 	// if not the dummy consumer is initiated.
@@ -31,48 +93,179 @@ func LoadTest(harfile string, file *os.File, workers int, timeout time.Duration,
 		go WritePoint(u, results)
 	} else {
 		go func(results chan TestResult) {
-			for {
-				<-results
+			for range results {
 			}
 		}(results)
 	}
 
-	go wait(stop, timeout, workers)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ctx, abort := context.WithCancelCause(ctx)
+	defer abort(nil)
+	go wait(ctx, stop)
+
+	latencies := NewLatencyHistogram()
 
+	var dashboard *LoadDashboard
+	dashboardDone := make(chan struct{})
+	if cfg.Dashboard {
+		dashboard = NewLoadDashboard(os.Stdout)
+		go func() {
+			dashboard.Run(ctx)
+			close(dashboardDone)
+		}()
+	} else {
+		close(dashboardDone)
+	}
+
+	var tokens chan struct{}
+	if len(stages) > 0 {
+		tokens = make(chan struct{})
+		go paceLoad(ctx, stages, tokens)
+	}
+
+	var wg sync.WaitGroup
 	for i := 0; i < workers; i++ {
-		go processEntries(harfile, i, entries, results, ignoreHarCookies, insecureSkipVerify, stop)
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			processEntries(ctx, harfile, worker, entries, results, metrics, latencies, dashboard, cfg, tracker, abort, tokens, stop)
+		}(i)
 	}
 
-	for {
-		select {
-		case <-stop:
+	<-stop
+	// results is closed once the workers no longer send on it
+	wg.Wait()
+	<-dashboardDone
+	result := tracker.result(latencies)
+	fmt.Printf("\n%s\n", result.Summary())
+	if cfg.Histogram != "" {
+		if err := latencies.WriteHistogramFile(cfg.Histogram); err != nil {
+			return result, err
 		}
-		break
 	}
-	fmt.Printf("\nTimeout of %.1fs elapsed. Terminating load test.\n", timeout.Seconds())
-	return nil
+	if cfg.Results != "" {
+		if err := result.WriteFile(cfg.Results); err != nil {
+			return result, err
+		}
+	}
+	if cfg.HTMLReport != "" {
+		if err := WriteAnalysisReportFile(cfg.HTMLReport, LoadReport("Load test of "+harfile, result)); err != nil {
+			return result, err
+		}
+	}
+	if cause := context.Cause(ctx); cause != ctx.Err() {
+		return result, cause
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		fmt.Printf("\nTimeout of %.1fs elapsed. Terminating load test.\n", timeout.Seconds())
+		return result, nil
+	}
+	fmt.Printf("\nLoad test cancelled.\n")
+	return result, ctx.Err()
+}
+
+// loadReportBounds split latencies into the ranges of the distribution
+// shown by LoadReport.
+var loadReportBounds = []time.Duration{
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second,
 }
 
-// wait will close the stop chan when the timeout is hit.
-func wait(stop chan bool, timeout time.Duration, workers int) {
-	time.Sleep(timeout)
+// LoadReport returns the HTML report of a load test result: its request
+// counts, latency percentiles and latency distribution.
+func LoadReport(title string, result LoadResult) AnalysisReport {
+	latencies := result.Latencies
+	if latencies == nil {
+		latencies = NewLatencyHistogram()
+	}
+
+	errorRate := 0.0
+	if result.Requests > 0 {
+		errorRate = float64(result.Failures) / float64(result.Requests) * 100
+	}
+	report := AnalysisReport{
+		Title: title,
+		Figures: []ReportFigure{
+			{"requests", strconv.Itoa(result.Requests)},
+			{"retries", strconv.Itoa(result.Retries)},
+			{"failed", strconv.Itoa(result.Failures)},
+			{"error rate", fmt.Sprintf("%.2f%%", errorRate)},
+			{"mean latency", latencies.Mean().String()},
+			{"p99 latency", latencies.Percentile(99).String()},
+		},
+	}
+
+	percentiles := ReportChart{Title: "Latency percentiles"}
+	percentileTable := ReportSection{
+		Title:   "Latency percentiles",
+		Columns: []string{"Percentile", "Latency (ms)"},
+		Numeric: []bool{false, true},
+	}
+	for _, p := range []float64{50, 75, 90, 95, 99, 99.9, 100} {
+		label := fmt.Sprintf("p%g", p)
+		if p == 100 {
+			label = "max"
+		}
+		d := latencies.Percentile(p)
+		percentiles.Bars = append(percentiles.Bars, ReportBar{Label: label, Value: float64(d), Text: d.String()})
+		percentileTable.Rows = append(percentileTable.Rows, []string{label, fmt.Sprintf("%.3f", milliseconds(d))})
+	}
+
+	distribution := ReportChart{Title: "Latency distribution"}
+	distributionTable := ReportSection{
+		Title:   "Latency distribution",
+		Columns: []string{"Latency", "Requests", "Share (%)"},
+		Numeric: []bool{false, true, true},
+	}
+	counts := latencies.distribution(loadReportBounds)
+	first, last := len(counts), -1
+	for i, count := range counts {
+		if count > 0 {
+			first, last = min(first, i), i
+		}
+	}
+	for i := first; i <= last; i++ {
+		var label string
+		switch {
+		case i == 0:
+			label = fmt.Sprintf("< %v", loadReportBounds[0])
+		case i == len(loadReportBounds):
+			label = fmt.Sprintf(">= %v", loadReportBounds[i-1])
+		default:
+			label = fmt.Sprintf("%v - %v", loadReportBounds[i-1], loadReportBounds[i])
+		}
+		share := float64(counts[i]) / float64(latencies.Count()) * 100
+		distribution.Bars = append(distribution.Bars, ReportBar{Label: label, Value: float64(counts[i]), Text: strconv.FormatUint(counts[i], 10)})
+		distributionTable.Rows = append(distributionTable.Rows, []string{label, strconv.FormatUint(counts[i], 10), fmt.Sprintf("%.2f", share)})
+	}
+
+	report.Charts = append(report.Charts, percentiles, distribution)
+	report.Sections = append(report.Sections, percentileTable, distributionTable)
+	return report
+}
+
+// wait will close the stop chan when ctx is done, i.e. when the timeout is
+// hit or the load test is cancelled.
+func wait(ctx context.Context, stop chan bool) {
+	<-ctx.Done()
 	close(stop)
 }
 
-func processEntries(harfile string, worker int, entries chan Entry, results chan TestResult, ignoreHarCookies bool, insecureSkipVerify bool, stop chan bool) {
+func processEntries(ctx context.Context, harfile string, worker int, entries chan Entry, results chan TestResult, metrics *Metrics, latencies *LatencyHistogram, dashboard *LoadDashboard, cfg Config, tracker *failureTracker, abort context.CancelCauseFunc, tokens <-chan struct{}, stop chan bool) {
 	jar, _ := cookiejar.New(nil)
+	correlator, _ := NewCorrelator(cfg.Correlations)
+	correlator.SetVariables(dataRow(cfg.Data, worker))
+
+	transport, _ := newTransport(cfg)
+	transport.TLSHandshakeTimeout = 10 * time.Second
+	transport.ResponseHeaderTimeout = 10 * time.Second
+	transport.ExpectContinueTimeout = 1 * time.Second
 
 	httpClient := http.Client{
-		Transport: &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).Dial,
-			TLSClientConfig:       &tls.Config{InsecureSkipVerify: insecureSkipVerify},
-			TLSHandshakeTimeout:   10 * time.Second,
-			ResponseHeaderTimeout: 10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-		},
+		Transport: transport,
 		CheckRedirect: func(r *http.Request, via []*http.Request) error {
 			r.URL.Opaque = r.URL.Path
 			return nil
@@ -84,26 +277,58 @@ func processEntries(harfile string, worker int, entries chan Entry, results chan
 
 		select {
 		case <-stop:
-			break
+			return
 		case entry := <-entries:
+			cfg.Redaction.RedactEntry(&entry)
+			if tokens != nil {
+				select {
+				case <-stop:
+					return
+				case <-tokens:
+				}
+			}
 			msg := fmt.Sprintf("[%d,%d] %s", worker, iter, entry.Request.URL)
 
-			req, err := EntryToRequest(&entry, ignoreHarCookies)
-
-			check(err)
+			req, err := EntryToRequest(&entry, cfg.IgnoreHarCookies || cfg.LiveCookies)
+			if err == nil {
+				err = correlator.Apply(req)
+			}
+			if err == nil {
+				err = applyRequestRules(req, cfg)
+			}
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			req = req.WithContext(ctx)
+			applyAuth(ctx, req, entry, cfg)
 
-			jar.SetCookies(req.URL, req.Cookies())
+			if !cfg.LiveCookies {
+				jar.SetCookies(req.URL, req.Cookies())
+			}
 
+			tracker.request()
+			dashboard.Started()
 			startTime := time.Now()
-			resp, err := httpClient.Do(req)
+			resp, err := doWithRetry(ctx, &httpClient, req, cfg.Retry, tracker.retry)
 			endTime := time.Now()
 			latency := int(endTime.Sub(startTime) / time.Millisecond)
 			method := req.Method
 
+			if (err == nil && cfg.Retry.retryable(resp.StatusCode)) || (err != nil && ctx.Err() == nil) {
+				if err := tracker.fail(); err != nil {
+					abort(err)
+				}
+			}
+
 			if err != nil {
 
 				log.Error(err)
 				log.Error(entry)
+				metrics.ObserveError(endTime.Sub(startTime))
+				dashboard.ObserveError(endTime.Sub(startTime))
+				emit(Event{Type: EventError, Phase: "load", Index: -1, URL: req.URL.String(), Method: method,
+					Latency: endTime.Sub(startTime), Err: err})
 				tr := TestResult{
 					URL:       req.URL.String(),
 					Status:    0,
@@ -112,17 +337,33 @@ func processEntries(harfile string, worker int, entries chan Entry, results chan
 					Latency:   latency,
 					Method:    method,
 					HarFile:   harfile}
-				results <- tr
+				select {
+				case results <- tr:
+				case <-stop:
+					return
+				}
 				continue
 			}
 
-			if resp != nil {
-				resp.Body.Close()
+			var body []byte
+			if correlator.NeedsBody() {
+				body, _ = io.ReadAll(resp.Body)
 			}
+			resp.Body.Close()
+			correlator.Extract(entry, resp, body)
+
+			metrics.Observe(resp.StatusCode, endTime.Sub(startTime), resp.ContentLength)
+			latencies.Record(endTime.Sub(startTime))
+			dashboard.Observe(resp.StatusCode, endTime.Sub(startTime))
 
 			msg += fmt.Sprintf(" %d %dms", resp.StatusCode, latency)
 
-			log.Infoln(msg)
+			if dashboard == nil {
+				log.Infoln(msg)
+			}
+
+			emit(Event{Type: EventRequestReplayed, Phase: "load", Index: -1, URL: req.URL.String(), Method: method,
+				Status: resp.StatusCode, Latency: endTime.Sub(startTime)})
 
 			tr := TestResult{
 				URL:       req.URL.String(),
@@ -133,7 +374,11 @@ func processEntries(harfile string, worker int, entries chan Entry, results chan
 				Method:    method,
 				HarFile:   harfile}
 
-			results <- tr
+			select {
+			case results <- tr:
+			case <-stop:
+				return
+			}
 		}
 		iter++
 	}
@@ -0,0 +1,127 @@
+package hargo
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Helpers shared by the exporters of load test scripts: k6, Gatling and
+// Locust.
+
+// scriptHeaders are the request headers load test scripts send explicitly.
+// Headers the client computes itself, hop-by-hop headers and cookies, which
+// are handled by the client's cookie jar, are dropped.
+func scriptHeaders(req Request) []NVP {
+	var headers []NVP
+	for _, h := range req.Headers {
+		if strings.HasPrefix(h.Name, ":") {
+			continue
+		}
+		switch strings.ToLower(h.Name) {
+		case "host", "content-length", "cookie", "connection", "keep-alive", "transfer-encoding", "te", "upgrade":
+			continue
+		}
+		headers = append(headers, h)
+	}
+	return headers
+}
+
+// scriptBody returns the posted body of req, form encoding its params if no
+// text was recorded.
+func scriptBody(req Request) string {
+	if req.PostData.Text != "" || len(req.PostData.Params) == 0 {
+		return req.PostData.Text
+	}
+	form := url.Values{}
+	for _, p := range req.PostData.Params {
+		form.Add(p.Name, p.Value)
+	}
+	return form.Encode()
+}
+
+// initialCookies returns the cookies sent by req that no earlier response
+// of the capture set, i.e. that existed before the capture started.
+// Cookies set by responses are left to the client's cookie jar.
+func initialCookies(req Request, set map[string]bool) []Cookie {
+	var cookies []Cookie
+	for _, c := range req.Cookies {
+		if !set[c.Name] {
+			cookies = append(cookies, c)
+		}
+	}
+	return cookies
+}
+
+// thinkTimes returns for each entry the time between the end of the
+// previous entry and its start, 0 for the first entry, overlapping entries
+// and entries with an invalid startedDateTime. WebSocket entries, which
+// scripts leave out, are skipped.
+func thinkTimes(entries []Entry) []time.Duration {
+	gaps := make([]time.Duration, len(entries))
+	var end time.Time
+	for i, entry := range entries {
+		started, err := parseStartedDateTime(entry.StartedDateTime)
+		if err != nil || entry.IsWebSocket() {
+			continue
+		}
+		if !end.IsZero() && started.After(end) {
+			gaps[i] = started.Sub(end).Round(time.Millisecond)
+		}
+		if e := started.Add(time.Duration(float64(entry.Time) * float64(time.Millisecond))); e.After(end) {
+			end = e
+		}
+	}
+	return gaps
+}
+
+// scriptGroup is a sequence of entries of the same page.
+type scriptGroup struct {
+	name    string
+	entries []int
+}
+
+// scriptGroups groups consecutive entries by page, named after the page
+// title, or its ID if it has none. WebSocket entries are left out, as
+// scripts cannot replay them as HTTP requests.
+func scriptGroups(har Har) []scriptGroup {
+	titles := make(map[string]string)
+	for _, page := range har.Log.Pages {
+		titles[page.ID] = page.Title
+		if page.Title == "" {
+			titles[page.ID] = page.ID
+		}
+	}
+
+	var groups []scriptGroup
+	for i, entry := range har.Log.Entries {
+		if entry.IsWebSocket() {
+			continue
+		}
+		name := titles[entry.Pageref]
+		if name == "" {
+			name = entry.Pageref
+		}
+		if name == "" {
+			name = "requests"
+		}
+		if n := len(groups); n > 0 && groups[n-1].name == name {
+			groups[n-1].entries = append(groups[n-1].entries, i)
+			continue
+		}
+		groups = append(groups, scriptGroup{name: name, entries: []int{i}})
+	}
+	return groups
+}
+
+// jsString quotes s as a JavaScript string literal, which is also a valid
+// Python string literal.
+func jsString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
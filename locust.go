@@ -0,0 +1,108 @@
+package hargo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// LocustOptions controls the locustfile written by ToLocust.
+type LocustOptions struct {
+	// NoThinkTime leaves out the sleeps between requests.
+	NoThinkTime bool
+	// ClassName is the name of the user class, RecordedUser if empty.
+	ClassName string
+}
+
+// ToLocust writes a Locust locustfile whose user replays the requests of
+// har in order, with a comment per page, sleeping for the recorded gaps
+// between requests and marking responses with another status than the
+// recorded one as failures. Redirects are not followed, as the capture
+// contains the redirected requests.
+func ToLocust(har Har, w io.Writer, opts LocustOptions) error {
+	className := opts.ClassName
+	if className == "" {
+		className = "RecordedUser"
+	}
+
+	// requests use absolute URLs, host only satisfies Locust
+	groups := scriptGroups(har)
+	host := "http://localhost"
+	if len(groups) > 0 {
+		if u, err := url.Parse(har.Log.Entries[groups[0].entries[0]].Request.URL); err == nil && u.Host != "" {
+			host = u.Scheme + "://" + u.Host
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("# Generated by hargo from a .har capture.\n")
+	b.WriteString("import time\n\n")
+	b.WriteString("from locust import HttpUser, task\n\n\n")
+	fmt.Fprintf(&b, "class %s(HttpUser):\n", className)
+	fmt.Fprintf(&b, "    host = %s\n\n", jsString(host))
+	b.WriteString("    @task\n    def recorded(self):\n")
+	if len(groups) == 0 {
+		b.WriteString("        pass\n")
+	}
+
+	gaps := thinkTimes(har.Log.Entries)
+	setCookies := make(map[string]bool)
+	for _, group := range groups {
+		fmt.Fprintf(&b, "        # %s\n", strings.NewReplacer("\n", " ", "\r", " ").Replace(group.name))
+		for _, i := range group.entries {
+			entry := har.Log.Entries[i]
+			if !opts.NoThinkTime && gaps[i] > 0 {
+				fmt.Fprintf(&b, "        time.sleep(%g)\n", gaps[i].Seconds())
+			}
+
+			args := []string{jsString(strings.ToUpper(entry.Request.Method)), jsString(entry.Request.URL)}
+			if headers := scriptHeaders(entry.Request); len(headers) > 0 {
+				var pairs []string
+				for _, h := range headers {
+					pairs = append(pairs, fmt.Sprintf("                %s: %s,", jsString(h.Name), jsString(h.Value)))
+				}
+				args = append(args, "headers={\n"+strings.Join(pairs, "\n")+"\n            }")
+			}
+			if cookies := initialCookies(entry.Request, setCookies); len(cookies) > 0 {
+				var pairs []string
+				for _, c := range cookies {
+					pairs = append(pairs, fmt.Sprintf("                %s: %s,", jsString(c.Name), jsString(c.Value)))
+				}
+				args = append(args, "cookies={\n"+strings.Join(pairs, "\n")+"\n            }")
+			}
+			for _, c := range entry.Response.Cookies {
+				setCookies[c.Name] = true
+			}
+			if body := scriptBody(entry.Request); body != "" {
+				args = append(args, "data="+jsString(body))
+			}
+			args = append(args, "allow_redirects=False", "catch_response=True")
+
+			fmt.Fprintf(&b, "        with self.client.request(\n            %s,\n        ) as res:\n", strings.Join(args, ",\n            "))
+			if status := entry.Response.Status; status > 0 {
+				fmt.Fprintf(&b, "            if res.status_code != %d:\n", status)
+				fmt.Fprintf(&b, "                res.failure(f\"expected status %d, got {res.status_code}\")\n", status)
+			} else {
+				b.WriteString("            pass\n")
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ToLocustFile converts a .har file to a locustfile written to w.
+func ToLocustFile(r *bufio.Reader, w io.Writer, opts LocustOptions) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Converted %d entries to a locustfile", len(har.Log.Entries))
+	return ToLocust(har, w, opts)
+}
@@ -0,0 +1,38 @@
+package hargo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestToLocust(t *testing.T) {
+	var out bytes.Buffer
+	if err := ToLocust(scriptTestHar(), &out, LocustOptions{ClassName: "Shopper"}); err != nil {
+		t.Fatal(err)
+	}
+	locustfile := out.String()
+
+	for _, expected := range []string{
+		"class Shopper(HttpUser):",
+		`host = "https://example.com"`,
+		"# Home",
+		`"GET",`,
+		`"Accept": "text/html",`,
+		`"pref": "1",`,
+		"time.sleep(1.5)",
+		`data="{\"q\":\"</script>\"}",`,
+		"allow_redirects=False,",
+		"if res.status_code != 201:",
+	} {
+		if !strings.Contains(locustfile, expected) {
+			t.Errorf("locustfile does not contain %s:\n%s", expected, locustfile)
+		}
+	}
+
+	out.Reset()
+	ToLocust(Har{}, &out, LocustOptions{NoThinkTime: true})
+	if !strings.Contains(out.String(), "        pass\n") {
+		t.Errorf("unexpected empty locustfile:\n%s", out.String())
+	}
+}
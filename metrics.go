@@ -0,0 +1,189 @@
+package hargo
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// latencyBuckets are the upper bounds in seconds of the latency histogram.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics collects request counters and a latency histogram for a
+// long-running mode (mock server, load test, proxy recorder) and exposes them
+// in the Prometheus text exposition format. A nil *Metrics ignores all
+// observations.
+type Metrics struct {
+	subsystem string
+
+	mu       sync.Mutex
+	requests map[int]uint64
+	errors   uint64
+	bytes    uint64
+	buckets  []uint64
+	sum      float64
+	count    uint64
+}
+
+// NewMetrics returns Metrics whose names are prefixed with
+// hargo_<subsystem>_.
+func NewMetrics(subsystem string) *Metrics {
+	return &Metrics{
+		subsystem: subsystem,
+		requests:  make(map[int]uint64),
+		buckets:   make([]uint64, len(latencyBuckets)),
+	}
+}
+
+// Observe records a completed request with its status code, latency and the
+// number of body bytes transferred.
+func (m *Metrics) Observe(status int, latency time.Duration, bytes int64) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[status]++
+	if bytes > 0 {
+		m.bytes += uint64(bytes)
+	}
+	m.observeLatency(latency)
+}
+
+// ObserveError records a request that failed without a response.
+func (m *Metrics) ObserveError(latency time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.errors++
+	m.observeLatency(latency)
+}
+
+func (m *Metrics) observeLatency(latency time.Duration) {
+	seconds := latency.Seconds()
+	for i, le := range latencyBuckets {
+		if seconds <= le {
+			m.buckets[i]++
+		}
+	}
+	m.sum += seconds
+	m.count++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prefix := "hargo_" + m.subsystem + "_"
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	fmt.Fprintf(w, "# HELP %srequests_total Requests by HTTP status code.\n", prefix)
+	fmt.Fprintf(w, "# TYPE %srequests_total counter\n", prefix)
+	statuses := make([]int, 0, len(m.requests))
+	for status := range m.requests {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(w, "%srequests_total{status=\"%d\"} %d\n", prefix, status, m.requests[status])
+	}
+
+	fmt.Fprintf(w, "# HELP %serrors_total Requests that failed without a response.\n", prefix)
+	fmt.Fprintf(w, "# TYPE %serrors_total counter\n", prefix)
+	fmt.Fprintf(w, "%serrors_total %d\n", prefix, m.errors)
+
+	fmt.Fprintf(w, "# HELP %sbytes_total Response body bytes transferred.\n", prefix)
+	fmt.Fprintf(w, "# TYPE %sbytes_total counter\n", prefix)
+	fmt.Fprintf(w, "%sbytes_total %d\n", prefix, m.bytes)
+
+	fmt.Fprintf(w, "# HELP %srequest_duration_seconds Request latency.\n", prefix)
+	fmt.Fprintf(w, "# TYPE %srequest_duration_seconds histogram\n", prefix)
+	for i, le := range latencyBuckets {
+		fmt.Fprintf(w, "%srequest_duration_seconds_bucket{le=\"%s\"} %d\n", prefix, strconv.FormatFloat(le, 'g', -1, 64), m.buckets[i])
+	}
+	fmt.Fprintf(w, "%srequest_duration_seconds_bucket{le=\"+Inf\"} %d\n", prefix, m.count)
+	fmt.Fprintf(w, "%srequest_duration_seconds_sum %s\n", prefix, strconv.FormatFloat(m.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%srequest_duration_seconds_count %d\n", prefix, m.count)
+}
+
+// Instrument wraps an http.Handler, observing the status, latency and body
+// size of every response it serves.
+func (m *Metrics) Instrument(h http.Handler) http.Handler {
+	if m == nil {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &metricsRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		h.ServeHTTP(rec, r)
+		m.Observe(rec.status, time.Since(start), rec.bytes)
+	})
+}
+
+// metricsRecorder captures the status code and body size of a response.
+type metricsRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rec *metricsRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *metricsRecorder) Write(b []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Flush lets streamed responses pass through the recorder.
+func (rec *metricsRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// ServeMetrics exposes m on addr under /metrics in the background, until
+// ctx is done. It fails if addr cannot be listened on.
+func ServeMetrics(ctx context.Context, addr string, m *Metrics) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("metrics endpoint: %v", err)
+	}
+	srv := &http.Server{Handler: mux}
+
+	log.Infof("Serving metrics on http://%s/metrics", ln.Addr())
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Errorf("Metrics endpoint failed: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	return nil
+}
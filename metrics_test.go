@@ -0,0 +1,43 @@
+package hargo
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServeMetricsShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	// every run, like the load tests of an agent, listens on the same
+	// address once the previous one is done
+	for run := 0; run < 3; run++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		var err error
+		for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			if err = ServeMetrics(ctx, addr, NewMetrics("load")); err == nil || time.Now().After(deadline) {
+				break
+			}
+		}
+		if err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+
+		resp, err := http.Get("http://" + addr + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if err := ServeMetrics(ctx, addr, NewMetrics("load")); err == nil {
+			t.Error("expected an error for an address in use")
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,166 @@
+package hargo
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// MHTML converts the response content in a .har file to a single MHTML
+// (.mht) document written to w. The main document of the first page becomes
+// the root part, followed by one part per subresource, each carrying its
+// original URL in a Content-Location header so browsers can resolve it.
+func MHTML(r *bufio.Reader, w io.Writer) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	entries := mhtmlEntries(har)
+	if len(entries) == 0 {
+		return fmt.Errorf("no response content to convert to MHTML")
+	}
+
+	root := entries[0]
+	subject := root.Request.URL
+	if len(har.Log.Pages) > 0 && har.Log.Pages[0].Title != "" {
+		subject = har.Log.Pages[0].Title
+	}
+
+	mw := multipart.NewWriter(w)
+
+	// MHTML uses RFC 822 style top-level headers followed by a
+	// multipart/related body whose root part is the text/html document.
+	header := "From: <Saved by hargo>\r\n" +
+		"Snapshot-Content-Location: " + root.Request.URL + "\r\n" +
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/related;\r\n" +
+		"\ttype=\"text/html\";\r\n" +
+		"\tboundary=\"" + mw.Boundary() + "\"\r\n\r\n"
+
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := writeMHTMLPart(mw, entry); err != nil {
+			return err
+		}
+	}
+
+	return mw.Close()
+}
+
+// mhtmlEntries returns the entries that can be represented as MHTML parts,
+// with the main HTML document first and duplicate URLs removed.
+func mhtmlEntries(har Har) []Entry {
+	var entries []Entry
+	seen := make(map[string]bool)
+	rootIndex := -1
+
+	for _, entry := range har.Log.Entries {
+		if entry.Response.Content.Text == "" || seen[entry.Request.URL] {
+			continue
+		}
+		if entry.Response.Status >= 300 && entry.Response.Status < 400 {
+			continue
+		}
+		seen[entry.Request.URL] = true
+
+		if rootIndex < 0 && strings.Contains(entry.Response.Content.MimeType, "text/html") {
+			rootIndex = len(entries)
+		}
+		entries = append(entries, entry)
+	}
+
+	if rootIndex > 0 {
+		root := entries[rootIndex]
+		copy(entries[1:rootIndex+1], entries[:rootIndex])
+		entries[0] = root
+	}
+
+	return entries
+}
+
+// writeMHTMLPart writes a single entry as an MHTML part. Textual content is
+// quoted-printable encoded, everything else is base64 encoded.
+func writeMHTMLPart(mw *multipart.Writer, entry Entry) error {
+	content, err := entry.DecodedBody()
+	if err != nil {
+		log.Errorf("Failed to decode content for %s: %v", entry.Request.URL, err)
+		return nil
+	}
+
+	mimeType := entry.Response.Content.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	textual := isTextMimeType(mimeType)
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Type", mimeType)
+	if textual {
+		h.Set("Content-Transfer-Encoding", "quoted-printable")
+	} else {
+		h.Set("Content-Transfer-Encoding", "base64")
+	}
+	h.Set("Content-Location", entry.Request.URL)
+
+	part, err := mw.CreatePart(h)
+	if err != nil {
+		return err
+	}
+
+	if textual {
+		qp := quotedprintable.NewWriter(part)
+		if _, err := qp.Write(content); err != nil {
+			return err
+		}
+		return qp.Close()
+	}
+
+	return writeBase64Lines(part, content)
+}
+
+// writeBase64Lines writes base64 encoded data wrapped at 76 characters per
+// line as required for MIME bodies.
+func writeBase64Lines(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := io.WriteString(w, encoded[:76]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err := io.WriteString(w, encoded)
+	return err
+}
+
+// isTextMimeType reports whether content of the given MIME type is textual
+// and can be safely written as quoted-printable.
+func isTextMimeType(mimeType string) bool {
+	mimeType = strings.ToLower(mimeType)
+
+	switch {
+	case strings.HasPrefix(mimeType, "text/"):
+		return true
+	case strings.Contains(mimeType, "javascript"),
+		strings.Contains(mimeType, "json"),
+		strings.Contains(mimeType, "xml"):
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,107 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"testing"
+)
+
+func TestMHTML(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n" + string(make([]byte, 100)))
+	harData, _ := json.Marshal(Har{Log: Log{
+		Pages: []Page{{ID: "page_1", Title: "Home"}},
+		Entries: []Entry{
+			{StartedDateTime: "2024-01-02T10:00:00.000Z", Request: Request{URL: "https://example.com/app.css"},
+				Response: Response{Status: 200, Content: Content{MimeType: "text/css", Text: "body { color: red }"}}},
+			{StartedDateTime: "2024-01-02T10:00:01.000Z", Request: Request{URL: "https://example.com/"},
+				Response: Response{Status: 200, Content: Content{MimeType: "text/html; charset=utf-8", Text: "<html><p>café</p></html>"}}},
+			{StartedDateTime: "2024-01-02T10:00:02.000Z", Request: Request{URL: "https://example.com/logo.png"},
+				Response: Response{Status: 200, Content: Content{MimeType: "image/png", Encoding: "base64", Text: base64.StdEncoding.EncodeToString(png)}}},
+			// redirects, empty responses and repeated URLs are not parts
+			{StartedDateTime: "2024-01-02T10:00:03.000Z", Request: Request{URL: "https://example.com/old"},
+				Response: Response{Status: 301, Content: Content{MimeType: "text/html", Text: "moved"}}},
+			{StartedDateTime: "2024-01-02T10:00:04.000Z", Request: Request{URL: "https://example.com/empty"},
+				Response: Response{Status: 204}},
+			{StartedDateTime: "2024-01-02T10:00:05.000Z", Request: Request{URL: "https://example.com/app.css"},
+				Response: Response{Status: 200, Content: Content{MimeType: "text/css", Text: "body { color: blue }"}}},
+		},
+	}})
+
+	var buf bytes.Buffer
+	if err := MHTML(bufio.NewReader(bytes.NewReader(harData)), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Header.Get("Snapshot-Content-Location"); got != "https://example.com/" {
+		t.Errorf("got Snapshot-Content-Location %s", got)
+	}
+	if got := msg.Header.Get("Subject"); got != "Home" {
+		t.Errorf("got Subject %s", got)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/related" || params["type"] != "text/html" {
+		t.Fatalf("got Content-Type %s", msg.Header.Get("Content-Type"))
+	}
+
+	expected := []struct {
+		location, mimeType, encoding string
+		content                      []byte
+	}{
+		{"https://example.com/", "text/html; charset=utf-8", "quoted-printable", []byte("<html><p>café</p></html>")},
+		{"https://example.com/app.css", "text/css", "quoted-printable", []byte("body { color: red }")},
+		{"https://example.com/logo.png", "image/png", "base64", png},
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for i := 0; ; i++ {
+		// NextRawPart keeps the Content-Transfer-Encoding to check it
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			if i != len(expected) {
+				t.Errorf("got %d parts, want %d", i, len(expected))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i >= len(expected) {
+			t.Errorf("unexpected part %s", part.Header.Get("Content-Location"))
+			continue
+		}
+		want := expected[i]
+		if part.Header.Get("Content-Location") != want.location || part.Header.Get("Content-Type") != want.mimeType ||
+			part.Header.Get("Content-Transfer-Encoding") != want.encoding {
+			t.Errorf("%d: got part %v", i, part.Header)
+		}
+
+		var body io.Reader = part
+		if want.encoding == "base64" {
+			body = base64.NewDecoder(base64.StdEncoding, part)
+		} else {
+			body = quotedprintable.NewReader(part)
+		}
+		content, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(content, want.content) {
+			t.Errorf("%d: got content %q", i, content)
+		}
+	}
+
+	harData, _ = json.Marshal(Har{Log: Log{Entries: []Entry{{Request: Request{URL: "https://example.com/"}, Response: Response{Status: 204}}}}})
+	if err := MHTML(bufio.NewReader(bytes.NewReader(harData)), &buf); err == nil {
+		t.Error("expected an error without response content")
+	}
+}
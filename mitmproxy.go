@@ -0,0 +1,524 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// mitmproxyFlowVersion is the flow format version written by
+// HarToMitmproxy, that of mitmproxy 10. Newer mitmproxy releases migrate
+// older flows on load.
+const mitmproxyFlowVersion = 20
+
+// MitmproxyToHar converts a mitmproxy flow file (as written by
+// `mitmdump -w` or File > Save) to a Har. Only HTTP flows are converted;
+// WebSocket messages are kept as _webSocketMessages.
+func MitmproxyToHar(r io.Reader) (Har, error) {
+	br := bufio.NewReader(r)
+	connections := make(map[string]bool)
+
+	var entries []Entry
+	for {
+		v, err := readTnetstring(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Har{}, err
+		}
+
+		flow := tnetDict(v)
+		if t := tnetString(flow["type"]); t != "http" {
+			log.Debugf("Skipping %s flow", t)
+			continue
+		}
+		entries = append(entries, mitmproxyEntry(flow, connections))
+	}
+
+	return Har{
+		Log: Log{
+			Version: "1.2",
+			Creator: Creator{Name: "hargo", Comment: "converted from mitmproxy flows"},
+			Entries: entries,
+		},
+	}, nil
+}
+
+// MitmproxyToHarFile converts a mitmproxy flow file to a .har file written to w.
+func MitmproxyToHarFile(r io.Reader, w io.Writer) error {
+	har, err := MitmproxyToHar(r)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Converted %d mitmproxy flows", len(har.Log.Entries))
+	return Encode(w, har)
+}
+
+// HarToMitmproxy writes the entries of a Har as a mitmproxy flow file that
+// can be opened with `mitmproxy -r` or replayed with `mitmdump`.
+func HarToMitmproxy(har Har, w io.Writer) error {
+	for _, entry := range har.Log.Entries {
+		flow, err := mitmproxyFlow(entry)
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if err := writeTnetstring(&buf, flow); err != nil {
+			return err
+		}
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HarToMitmproxyFile converts a .har file to a mitmproxy flow file written to w.
+func HarToMitmproxyFile(r *bufio.Reader, w io.Writer) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Converted %d entries to mitmproxy flows", len(har.Log.Entries))
+	return HarToMitmproxy(har, w)
+}
+
+// isMitmproxy reports whether r holds mitmproxy flows, which start with the
+// length prefix of a tnetstring.
+func isMitmproxy(r *bufio.Reader) bool {
+	peek, _ := r.Peek(16)
+	i := bytes.IndexByte(peek, ':')
+	if i < 1 {
+		return false
+	}
+	_, err := strconv.Atoi(string(peek[:i]))
+	return err == nil
+}
+
+// mitmproxyEntry converts an HTTP flow to a HAR entry. connections tracks
+// the server connections seen so far, as connection setup is only counted
+// for the first request sent over a connection.
+func mitmproxyEntry(flow map[string]interface{}, connections map[string]bool) Entry {
+	req := tnetDict(flow["request"])
+	serverConn := tnetDict(flow["server_conn"])
+	clientConn := tnetDict(flow["client_conn"])
+
+	u := url.URL{Scheme: tnetString(req["scheme"]), Host: tnetString(req["host"])}
+	if port := tnetInt(req["port"]); port > 0 && !(u.Scheme == "http" && port == 80) && !(u.Scheme == "https" && port == 443) {
+		u.Host = net.JoinHostPort(u.Host, strconv.Itoa(port))
+	}
+	rawURL := u.String() + tnetString(req["path"])
+	if parsed, err := url.Parse(rawURL); err == nil {
+		u = *parsed
+	}
+
+	reqHeaders := mitmproxyHeaders(req["headers"])
+	reqHeader := nvpHeader(reqHeaders)
+	reqBody := tnetBytes(req["content"])
+
+	reqStart := tnetFloat(req["timestamp_start"])
+	reqEnd := tnetFloat(req["timestamp_end"])
+
+	entry := Entry{
+		Request: Request{
+			Method:      tnetString(req["method"]),
+			URL:         rawURL,
+			HTTPVersion: tnetString(req["http_version"]),
+			Cookies:     harCookies((&http.Request{Header: reqHeader}).Cookies()),
+			Headers:     reqHeaders,
+			QueryString: queryNVPs(u.Query()),
+			HeaderSize:  -1,
+			BodySize:    len(reqBody),
+		},
+		Timings: PageTimings{Blocked: -1, DNS: -1, Connect: -1, Ssl: -1, Send: mitmproxyPhase(reqStart, reqEnd)},
+		Comment: tnetString(flow["comment"]),
+	}
+	if len(reqBody) > 0 {
+		entry.Request.PostData = PostData{MimeType: reqHeader.Get("Content-Type"), Text: string(reqBody)}
+	}
+
+	started := reqStart
+	if id := tnetString(serverConn["id"]); id != "" && !connections[id] {
+		connections[id] = true
+		start := tnetFloat(serverConn["timestamp_start"])
+		tcp := tnetFloat(serverConn["timestamp_tcp_setup"])
+		tls := tnetFloat(serverConn["timestamp_tls_setup"])
+		if start > 0 && tcp >= start {
+			// HAR includes the TLS handshake in the connect time
+			entry.Timings.Connect = mitmproxyPhase(start, math.Max(tcp, tls))
+			if tls >= tcp {
+				entry.Timings.Ssl = mitmproxyPhase(tcp, tls)
+			}
+			started = math.Min(start, reqStart)
+		}
+	}
+	// timestamps are float seconds, round off their representation error
+	entry.StartedDateTime = epochSeconds(started).Round(time.Microsecond).UTC().Format(harDateTimeLayout)
+	if peer := tnetList(serverConn["peername"]); len(peer) > 0 {
+		entry.ServerIPAddress = tnetString(peer[0])
+	}
+	if peer := tnetList(clientConn["peername"]); len(peer) > 1 {
+		entry.Connection = strconv.Itoa(tnetInt(peer[1]))
+	}
+
+	if resp, ok := flow["response"].(map[string]interface{}); ok {
+		respStart := tnetFloat(resp["timestamp_start"])
+		respEnd := tnetFloat(resp["timestamp_end"])
+		entry.Timings.Wait = mitmproxyPhase(reqEnd, respStart)
+		entry.Timings.Receive = mitmproxyPhase(respStart, respEnd)
+
+		respHeaders := mitmproxyHeaders(resp["headers"])
+		respHeader := nvpHeader(respHeaders)
+		raw := tnetBytes(resp["content"])
+		body := raw
+		if decoded, err := decodeContentEncoding(respHeader.Get("Content-Encoding"), raw); err == nil {
+			body = decoded
+		}
+
+		mimeType := respHeader.Get("Content-Type")
+		content := Content{Size: len(body), Compression: len(body) - len(raw), MimeType: mimeType}
+		if isTextMimeType(mimeType) && utf8.Valid(body) {
+			content.Text = string(body)
+		} else if len(body) > 0 {
+			content.Text = base64.StdEncoding.EncodeToString(body)
+			content.Encoding = "base64"
+		}
+
+		entry.Response = Response{
+			Status:      tnetInt(resp["status_code"]),
+			StatusText:  tnetString(resp["reason"]),
+			HTTPVersion: tnetString(resp["http_version"]),
+			Cookies:     harCookies((&http.Response{Header: respHeader}).Cookies()),
+			Headers:     respHeaders,
+			Content:     content,
+			RedirectURL: respHeader.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    len(raw),
+		}
+	} else {
+		entry.Response = Response{HeadersSize: -1, BodySize: -1}
+		if flowErr, ok := flow["error"].(map[string]interface{}); ok {
+			entry.Response.Comment = tnetString(flowErr["msg"])
+		}
+	}
+
+	if ws, ok := flow["websocket"].(map[string]interface{}); ok {
+		for _, m := range tnetList(ws["messages"]) {
+			fields := tnetList(m)
+			if len(fields) < 4 {
+				continue
+			}
+			msg := WebSocketMessage{Type: "receive", Opcode: tnetInt(fields[0]), Time: tnetFloat(fields[3])}
+			if fromClient, _ := fields[2].(bool); fromClient {
+				msg.Type = "send"
+			}
+			if data := tnetBytes(fields[1]); msg.Opcode == OpcodeText {
+				msg.Data = string(data)
+			} else {
+				msg.Data = base64.StdEncoding.EncodeToString(data)
+			}
+			entry.WebSocketMessages = append(entry.WebSocketMessages, msg)
+		}
+	}
+
+	entry.Time = float32(positive(entry.Timings.Connect) + positive(entry.Timings.Send) +
+		positive(entry.Timings.Wait) + positive(entry.Timings.Receive))
+
+	return entry
+}
+
+// mitmproxyFlow converts a HAR entry to a mitmproxy HTTP flow.
+func mitmproxyFlow(entry Entry) (map[string]interface{}, error) {
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return nil, err
+	}
+	started, err := parseStartedDateTime(entry.StartedDateTime)
+	if err != nil {
+		return nil, err
+	}
+
+	port := 80
+	if u.Scheme == "https" || u.Scheme == "wss" {
+		port = 443
+	}
+	if p, err := strconv.Atoi(u.Port()); err == nil {
+		port = p
+	}
+	tls := u.Scheme == "https" || u.Scheme == "wss"
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+
+	// reconstruct the timestamps of each phase from the entry timings
+	t := float64(started.UnixNano()) / 1e9
+	connStart := t + positive(entry.Timings.Blocked)/1000 + positive(entry.Timings.DNS)/1000
+	tlsSetup := connStart + positive(entry.Timings.Connect)/1000
+	tcpSetup := tlsSetup - positive(entry.Timings.Ssl)/1000
+	reqStart := tlsSetup
+	reqEnd := reqStart + positive(entry.Timings.Send)/1000
+	respStart := reqEnd + positive(entry.Timings.Wait)/1000
+	respEnd := respStart + positive(entry.Timings.Receive)/1000
+
+	path := u.RequestURI()
+	if u.Opaque != "" {
+		path = u.Opaque
+	}
+
+	var reqContent interface{}
+	if entry.Request.PostData.Text != "" {
+		reqContent = []byte(entry.Request.PostData.Text)
+	} else if entry.Request.BodySize <= 0 {
+		reqContent = []byte{}
+	}
+
+	request := map[string]interface{}{
+		"host":            u.Hostname(),
+		"port":            port,
+		"method":          []byte(entry.Request.Method),
+		"scheme":          []byte(scheme),
+		"authority":       []byte{},
+		"path":            []byte(path),
+		"http_version":    []byte(mitmproxyHTTPVersion(entry.Request.HTTPVersion)),
+		"headers":         mitmproxyHeaderList(entry.Request.Headers),
+		"content":         reqContent,
+		"trailers":        nil,
+		"timestamp_start": reqStart,
+		"timestamp_end":   reqEnd,
+	}
+
+	serverPeer := interface{}(nil)
+	if entry.ServerIPAddress != "" {
+		serverPeer = []interface{}{entry.ServerIPAddress, port}
+	}
+	clientPort := 0
+	if p, err := strconv.Atoi(entry.Connection); err == nil {
+		clientPort = p
+	}
+	var sni interface{}
+	if tls {
+		sni = u.Hostname()
+	}
+
+	flow := map[string]interface{}{
+		"version":           mitmproxyFlowVersion,
+		"type":              "http",
+		"id":                newUUID(),
+		"error":             nil,
+		"intercepted":       false,
+		"is_replay":         nil,
+		"marked":            "",
+		"metadata":          map[string]interface{}{},
+		"comment":           entry.Comment,
+		"timestamp_created": t,
+		"client_conn": map[string]interface{}{
+			"id":                  newUUID(),
+			"peername":            []interface{}{"127.0.0.1", clientPort},
+			"sockname":            []interface{}{"127.0.0.1", 8080},
+			"state":               0,
+			"error":               nil,
+			"tls":                 tls,
+			"certificate_list":    []interface{}{},
+			"alpn":                nil,
+			"alpn_offers":         []interface{}{},
+			"cipher":              nil,
+			"cipher_list":         []interface{}{},
+			"sni":                 sni,
+			"timestamp_start":     t,
+			"timestamp_end":       nil,
+			"timestamp_tls_setup": nil,
+			"tls_version":         nil,
+			"mitmcert":            nil,
+			"proxy_mode":          "regular",
+		},
+		"server_conn": map[string]interface{}{
+			"id":                  newUUID(),
+			"address":             []interface{}{u.Hostname(), port},
+			"peername":            serverPeer,
+			"sockname":            nil,
+			"state":               0,
+			"error":               nil,
+			"tls":                 tls,
+			"certificate_list":    []interface{}{},
+			"alpn":                nil,
+			"alpn_offers":         []interface{}{},
+			"cipher":              nil,
+			"cipher_list":         []interface{}{},
+			"sni":                 sni,
+			"timestamp_start":     connStart,
+			"timestamp_end":       nil,
+			"timestamp_tcp_setup": tcpSetup,
+			"timestamp_tls_setup": mitmproxyOptional(tls, tlsSetup),
+			"tls_version":         nil,
+			"via":                 nil,
+		},
+		"request":   request,
+		"response":  nil,
+		"websocket": nil,
+	}
+
+	if entry.Response.Status > 0 {
+		headers := entry.Response.Headers
+		body, err := entry.DecodedBody()
+		if err != nil {
+			return nil, err
+		}
+		body, headers = mitmproxyEncode(body, headers)
+
+		flow["response"] = map[string]interface{}{
+			"http_version":    []byte(mitmproxyHTTPVersion(entry.Response.HTTPVersion)),
+			"status_code":     entry.Response.Status,
+			"reason":          []byte(entry.Response.StatusText),
+			"headers":         mitmproxyHeaderList(headers),
+			"content":         body,
+			"trailers":        nil,
+			"timestamp_start": respStart,
+			"timestamp_end":   respEnd,
+		}
+	} else {
+		msg := entry.Response.Comment
+		if msg == "" {
+			msg = "no response recorded"
+		}
+		flow["error"] = map[string]interface{}{"msg": msg, "timestamp": respEnd}
+	}
+
+	if len(entry.WebSocketMessages) > 0 {
+		var messages []interface{}
+		for _, m := range entry.WebSocketMessages {
+			data := []byte(m.Data)
+			if m.Opcode != OpcodeText {
+				if decoded, err := base64.StdEncoding.DecodeString(m.Data); err == nil {
+					data = decoded
+				}
+			}
+			messages = append(messages, []interface{}{m.Opcode, data, m.Type == "send", m.Time})
+		}
+		flow["websocket"] = map[string]interface{}{
+			"messages":         messages,
+			"closed_by_client": nil,
+			"close_code":       nil,
+			"close_reason":     nil,
+			"timestamp_end":    nil,
+		}
+	}
+
+	return flow, nil
+}
+
+// mitmproxyHeaders converts a list of [name, value] pairs.
+func mitmproxyHeaders(v interface{}) []NVP {
+	var headers []NVP
+	for _, h := range tnetList(v) {
+		if pair := tnetList(h); len(pair) == 2 {
+			headers = append(headers, NVP{Name: tnetString(pair[0]), Value: tnetString(pair[1])})
+		}
+	}
+	return headers
+}
+
+// mitmproxyHeaderList converts headers to a list of [name, value] pairs,
+// leaving out HTTP/2 pseudo-headers.
+func mitmproxyHeaderList(headers []NVP) []interface{} {
+	list := []interface{}{}
+	for _, h := range headers {
+		if strings.HasPrefix(h.Name, ":") {
+			continue
+		}
+		list = append(list, []interface{}{[]byte(h.Name), []byte(h.Value)})
+	}
+	return list
+}
+
+// mitmproxyEncode returns the body as it was sent on the wire. mitmproxy
+// stores bodies encoded, so a decoded HAR body is compressed again to match
+// its Content-Encoding header; encodings that cannot be reproduced are
+// removed from the headers instead.
+func mitmproxyEncode(body []byte, headers []NVP) ([]byte, []NVP) {
+	encoding := strings.ToLower(strings.TrimSpace(recordedHeader(headers, "Content-Encoding")))
+
+	var buf bytes.Buffer
+	switch encoding {
+	case "":
+		return body, headers
+	case "gzip", "x-gzip":
+		zw := gzip.NewWriter(&buf)
+		zw.Write(body)
+		zw.Close()
+		return buf.Bytes(), headers
+	case "deflate":
+		zw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+		zw.Write(body)
+		zw.Close()
+		return buf.Bytes(), headers
+	}
+
+	var kept []NVP
+	for _, h := range headers {
+		if !strings.EqualFold(h.Name, "Content-Encoding") && !strings.EqualFold(h.Name, "Content-Length") {
+			kept = append(kept, h)
+		}
+	}
+	return body, kept
+}
+
+// mitmproxyHTTPVersion maps HAR protocol names to those used by mitmproxy.
+func mitmproxyHTTPVersion(version string) string {
+	switch strings.ToLower(version) {
+	case "", "unknown":
+		return "HTTP/1.1"
+	case "h2", "http/2", "http/2.0":
+		return "HTTP/2.0"
+	case "h3", "http/3", "http/3.0":
+		return "HTTP/3"
+	default:
+		return version
+	}
+}
+
+// mitmproxyPhase returns the duration between two timestamps in
+// milliseconds, or -1 if one of them is missing.
+func mitmproxyPhase(start, end float64) float64 {
+	if start <= 0 || end < start {
+		return -1
+	}
+	return math.Round((end-start)*1e6) / 1e3
+}
+
+// mitmproxyOptional returns v if ok, otherwise nil.
+func mitmproxyOptional(ok bool, v float64) interface{} {
+	if !ok {
+		return nil
+	}
+	return v
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,98 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestTnetstringRoundTrip(t *testing.T) {
+	value := map[string]interface{}{
+		"bytes":  []byte("GET"),
+		"string": "héllo",
+		"int":    int64(-42),
+		"float":  1.5,
+		"true":   true,
+		"none":   nil,
+		"list":   []interface{}{int64(1), []byte("a"), []interface{}{}},
+		"dict":   map[string]interface{}{"k": "v"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeTnetstring(&buf, value); err != nil {
+		t.Fatalf("writeTnetstring failed: %v", err)
+	}
+	decoded, err := readTnetstring(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatalf("readTnetstring failed: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, value) {
+		t.Errorf("round trip mismatch:\n%#v\n%#v", decoded, value)
+	}
+}
+
+func TestMitmproxyRoundTrip(t *testing.T) {
+	har := Har{Log: Log{Version: "1.2", Entries: []Entry{
+		{
+			StartedDateTime: "2024-01-02T03:04:05.000Z",
+			Request: Request{
+				Method:      "POST",
+				URL:         "https://api.example.com:8443/v1/items?page=2",
+				HTTPVersion: "HTTP/1.1",
+				Headers:     []NVP{{Name: "Content-Type", Value: "application/json"}},
+				PostData:    PostData{MimeType: "application/json", Text: `{"name":"x"}`},
+			},
+			Response: Response{
+				Status:      201,
+				StatusText:  "Created",
+				HTTPVersion: "HTTP/1.1",
+				Headers:     []NVP{{Name: "Content-Type", Value: "application/json"}, {Name: "Content-Encoding", Value: "gzip"}},
+				Content:     Content{MimeType: "application/json", Text: `{"id":7}`},
+			},
+			Timings:         PageTimings{Blocked: -1, DNS: -1, Connect: 30, Ssl: 20, Send: 1, Wait: 50, Receive: 4},
+			ServerIPAddress: "10.1.2.3",
+			Connection:      "51234",
+		},
+		{
+			StartedDateTime: "2024-01-02T03:04:06.000Z",
+			Request:         Request{Method: "GET", URL: "http://example.com/broken", HTTPVersion: "HTTP/1.1"},
+			Response:        Response{Comment: "connection refused"},
+		},
+	}}}
+
+	var buf bytes.Buffer
+	if err := HarToMitmproxy(har, &buf); err != nil {
+		t.Fatalf("HarToMitmproxy failed: %v", err)
+	}
+
+	// flow files are read wherever a .har file is expected
+	converted, err := Decode(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(converted.Log.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(converted.Log.Entries))
+	}
+
+	e := converted.Log.Entries[0]
+	if e.StartedDateTime != "2024-01-02T03:04:05.000Z" {
+		t.Errorf("unexpected startedDateTime %s", e.StartedDateTime)
+	}
+	if e.Request.URL != har.Log.Entries[0].Request.URL || e.Request.PostData.Text != `{"name":"x"}` {
+		t.Errorf("unexpected request %s %q", e.Request.URL, e.Request.PostData.Text)
+	}
+	if e.Response.Status != 201 || e.Response.StatusText != "Created" || e.Response.Content.Text != `{"id":7}` {
+		t.Errorf("unexpected response %+v", e.Response)
+	}
+	if e.Timings.Connect != 30 || e.Timings.Ssl != 20 || e.Timings.Send != 1 || e.Timings.Wait != 50 || e.Timings.Receive != 4 {
+		t.Errorf("unexpected timings %+v", e.Timings)
+	}
+	if e.ServerIPAddress != "10.1.2.3" || e.Connection != "51234" {
+		t.Errorf("unexpected connection %s %s", e.ServerIPAddress, e.Connection)
+	}
+
+	if failed := converted.Log.Entries[1]; failed.Response.Status != 0 || failed.Response.Comment != "connection refused" {
+		t.Errorf("unexpected failed response %+v", failed.Response)
+	}
+}
@@ -0,0 +1,351 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// MockServer is an http.Handler that answers requests with the responses
+// recorded in a .har file.
+type MockServer struct {
+	// StreamChunks replays streamed response bodies chunk by chunk, waiting
+	// the recorded delay before each chunk.
+	StreamChunks bool
+	// Matchers, if set, select the recorded entry served for a request:
+	// the first entry with the request's method that all matchers accept.
+	// Without matchers, requests are matched by request URI, falling back
+	// to the path alone.
+	Matchers []RequestMatcher
+	// Fallback handles requests without a recorded response, e.g. a proxy
+	// to the origin. If nil, they get a 404.
+	Fallback http.Handler
+	// LatencyScale reproduces the recorded wait and receive timings,
+	// multiplied by the scale: the response headers are sent after the wait
+	// time, the body after the receive time. Zero disables the delays.
+	LatencyScale float64
+	// Sequential serves the responses of a request recorded more than once
+	// in recorded order, e.g. a polled endpoint that eventually returns
+	// ready, repeating the last one when all have been served. Otherwise
+	// the first response is always served.
+	Sequential bool
+
+	entries map[string][]Entry
+	ordered []Entry
+
+	mu     sync.Mutex
+	served map[string]int
+}
+
+// NewMockServer returns a MockServer for the entries of a Har. When the same
+// request was recorded more than once, the first response is served unless
+// Sequential is set.
+func NewMockServer(har Har) *MockServer {
+	m := &MockServer{entries: make(map[string][]Entry), served: make(map[string]int)}
+
+	for _, entry := range har.Log.Entries {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			log.Warnf("Skipping entry with invalid URL %s", entry.Request.URL)
+			continue
+		}
+		m.ordered = append(m.ordered, entry)
+		keys := []string{mockKey(entry.Request.Method, u.RequestURI())}
+		if path := mockKey(entry.Request.Method, u.Path); path != keys[0] {
+			keys = append(keys, path)
+		}
+		for _, key := range keys {
+			m.entries[key] = append(m.entries[key], entry)
+		}
+	}
+
+	return m
+}
+
+// mockKey identifies a recorded request by method and request URI.
+func mockKey(method string, requestURI string) string {
+	return method + " " + requestURI
+}
+
+// match returns the recorded entry for r.
+func (m *MockServer) match(r *http.Request) (Entry, bool) {
+	if len(m.Matchers) == 0 {
+		key := mockKey(r.Method, r.URL.RequestURI())
+		if _, ok := m.entries[key]; !ok {
+			key = mockKey(r.Method, r.URL.Path)
+		}
+		return m.next(key, m.entries[key])
+	}
+
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			log.Debugf("Cannot read body of %s: %v", r.URL.RequestURI(), err)
+		}
+		// the fallback may still need the body
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	// the sequence is identified by the first matching entry
+	key := ""
+	var candidates []Entry
+entries:
+	for i, entry := range m.ordered {
+		if entry.Request.Method != r.Method {
+			continue
+		}
+		for _, matcher := range m.Matchers {
+			if !matcher.Match(r, body, entry.Request) {
+				continue entries
+			}
+		}
+		if !m.Sequential {
+			return entry, true
+		}
+		if key == "" {
+			key = strconv.Itoa(i)
+		}
+		candidates = append(candidates, entry)
+	}
+	return m.next(key, candidates)
+}
+
+// next returns the entry of candidates to serve for the sequence key: the
+// first one, or with Sequential the one following the last served.
+func (m *MockServer) next(key string, candidates []Entry) (Entry, bool) {
+	if len(candidates) == 0 {
+		return Entry{}, false
+	}
+	if !m.Sequential || len(candidates) == 1 {
+		return candidates[0], true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := m.served[key]
+	if n < len(candidates)-1 {
+		m.served[key] = n + 1
+	}
+	return candidates[n], true
+}
+
+// ServeHTTP looks up the recorded entry for the request and writes its
+// response, or passes the request to the Fallback handler.
+func (m *MockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	entry, ok := m.match(r)
+	if !ok && m.Fallback != nil {
+		log.Infof("%s %s -> fallback", r.Method, r.URL.RequestURI())
+		m.Fallback.ServeHTTP(w, r)
+		return
+	}
+	if !ok {
+		log.Warnf("%s %s -> no recorded response", r.Method, r.URL.RequestURI())
+		http.NotFound(w, r)
+		return
+	}
+
+	status := entry.Response.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	log.Infof("%s %s -> %d", r.Method, r.URL.RequestURI(), status)
+
+	chunks, err := entry.BodyChunks()
+	if err != nil {
+		log.Errorf("Failed to decode content for %s: %v", entry.Request.URL, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, h := range entry.Response.Headers {
+		if skipMockHeader(h.Name) {
+			continue
+		}
+		w.Header().Add(h.Name, h.Value)
+	}
+	flusher, canFlush := w.(http.Flusher)
+	stream := m.StreamChunks && canFlush && entry.IsStreamed()
+
+	if !sleepContext(r.Context(), m.latency(entry.Timings.Wait)) {
+		return
+	}
+	w.WriteHeader(status)
+
+	// streamed bodies are paced by their chunk delays instead
+	if receive := m.latency(entry.Timings.Receive); receive > 0 && !stream {
+		if canFlush {
+			flusher.Flush()
+		}
+		if !sleepContext(r.Context(), receive) {
+			return
+		}
+	}
+
+	for _, chunk := range chunks {
+		if stream {
+			time.Sleep(chunk.Delay)
+		}
+		if _, err := w.Write(chunk.Data); err != nil {
+			log.Debugf("Client went away during %s: %v", r.URL.RequestURI(), err)
+			return
+		}
+		if stream {
+			flusher.Flush()
+		}
+	}
+}
+
+// latency scales a recorded timing in milliseconds by LatencyScale. Timings
+// of -1, i.e. not available, give no delay.
+func (m *MockServer) latency(ms float64) time.Duration {
+	if m.LatencyScale <= 0 || ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms * m.LatencyScale * float64(time.Millisecond))
+}
+
+// sleepContext waits for d, returning false if ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// skipMockHeader reports whether a recorded response header must not be
+// replayed, either because it is an HTTP/2 pseudo header or because it
+// describes the original encoding of a body that is served decoded.
+func skipMockHeader(name string) bool {
+	if strings.HasPrefix(name, ":") {
+		return true
+	}
+	switch strings.ToLower(name) {
+	case "status", "content-length", "content-encoding", "transfer-encoding", "connection", "keep-alive":
+		return true
+	}
+	return false
+}
+
+// NewOriginProxy returns a reverse proxy to origin, e.g.
+// https://api.example.com, for use as a MockServer Fallback.
+func NewOriginProxy(origin string) (http.Handler, error) {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("origin %q must be an absolute URL", origin)
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Host = u.Host
+	}
+	return proxy, nil
+}
+
+// MockOptions controls the server started by MockWithOptions.
+type MockOptions struct {
+	// StreamChunks replays streamed bodies chunk by chunk.
+	StreamChunks bool
+	// MetricsAddr exposes Prometheus metrics of the served requests if set.
+	MetricsAddr string
+	// Matchers select the recorded entries, see MockServer.
+	Matchers []RequestMatcher
+	// LatencyScale reproduces the recorded timings, see MockServer.
+	LatencyScale float64
+	// Sequential serves repeated requests in recorded order.
+	Sequential bool
+	// Origin is the URL unmatched requests are proxied to. If empty, they
+	// get a 404.
+	Origin string
+	// TLS serves HTTPS with the certificate of CertFile and KeyFile, or a
+	// generated self-signed one, written to WriteCertFile if set. A
+	// CertFile implies TLS.
+	TLS           bool
+	CertFile      string
+	KeyFile       string
+	WriteCertFile string
+	// HTTP2 is HTTP2Auto, HTTP2On or HTTP2Off. Empty means HTTP2Auto.
+	HTTP2 string
+}
+
+// Mock serves the responses recorded in a .har file on addr. If metricsAddr
+// is set, Prometheus metrics of the served requests are exposed on it.
+func Mock(r *bufio.Reader, addr string, streamChunks bool, metricsAddr string) error {
+	return MockWithOptions(r, addr, MockOptions{StreamChunks: streamChunks, MetricsAddr: metricsAddr})
+}
+
+// MockWithOptions is like Mock, with the matching of requests and the
+// handling of unmatched ones set by opts.
+func MockWithOptions(r *bufio.Reader, addr string, opts MockOptions) error {
+	// only proxying unmatched requests to the origin reaches the network
+	if opts.Origin != "" {
+		if err := requireNetwork("mock"); err != nil {
+			return err
+		}
+	}
+
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	m := NewMockServer(har)
+	m.StreamChunks = opts.StreamChunks
+	m.Matchers = opts.Matchers
+	m.LatencyScale = opts.LatencyScale
+	m.Sequential = opts.Sequential
+	if opts.Origin != "" {
+		if m.Fallback, err = NewOriginProxy(opts.Origin); err != nil {
+			return err
+		}
+	}
+
+	var handler http.Handler = m
+	if opts.MetricsAddr != "" {
+		metrics := NewMetrics("mock")
+		handler = metrics.Instrument(m)
+		ctx, stopMetrics := context.WithCancel(context.Background())
+		defer stopMetrics()
+		if err := ServeMetrics(ctx, opts.MetricsAddr, metrics); err != nil {
+			return err
+		}
+	}
+
+	srv, err := newMockHTTPServer(har, addr, handler, opts)
+	if err != nil {
+		return err
+	}
+
+	if srv.TLSConfig != nil {
+		log.Infof("Serving %d recorded entries on https://%s", len(har.Log.Entries), addr)
+		return srv.ListenAndServeTLS("", "")
+	}
+	log.Infof("Serving %d recorded entries on %s", len(har.Log.Entries), addr)
+	return srv.ListenAndServe()
+}
@@ -0,0 +1,178 @@
+package hargo
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createMockHAR() Har {
+	return Har{
+		Log: Log{
+			Entries: []Entry{
+				{
+					Request: Request{Method: "GET", URL: "https://example.com/api/items?id=1"},
+					Response: Response{
+						Status:  200,
+						Headers: []NVP{{Name: "Content-Type", Value: "application/json"}, {Name: "Content-Encoding", Value: "gzip"}},
+						Content: Content{MimeType: "application/json", Text: `{"id":1}`},
+					},
+				},
+				{
+					Request: Request{Method: "GET", URL: "https://example.com/events"},
+					Response: Response{
+						Status:  200,
+						Content: Content{MimeType: "text/event-stream", Text: "data: one\n\ndata: two\n\n"},
+					},
+					Chunks: []Chunk{{Ts: 100, Bytes: 11}, {Ts: 110, Bytes: 11}},
+				},
+			},
+		},
+	}
+}
+
+func TestMockServer(t *testing.T) {
+	m := NewMockServer(createMockHAR())
+	m.StreamChunks = true
+	server := httptest.NewServer(m)
+	defer server.Close()
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/api/items?id=1", 200, `{"id":1}`},
+		{"/api/items", 200, `{"id":1}`},
+		{"/events", 200, "data: one\n\ndata: two\n\n"},
+		{"/missing", 404, "404 page not found\n"},
+	}
+
+	for _, test := range tests {
+		resp, err := http.Get(server.URL + test.path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", test.path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != test.status || string(body) != test.body {
+			t.Errorf("GET %s = %d %q, expected %d %q", test.path, resp.StatusCode, body, test.status, test.body)
+		}
+		if resp.Header.Get("Content-Encoding") != "" {
+			t.Errorf("GET %s replayed Content-Encoding for a decoded body", test.path)
+		}
+	}
+}
+
+func TestBodyChunks(t *testing.T) {
+	entry := createMockHAR().Log.Entries[1]
+
+	chunks, err := entry.BodyChunks()
+	if err != nil {
+		t.Fatalf("BodyChunks failed: %v", err)
+	}
+	if len(chunks) != 2 || string(chunks[0].Data) != "data: one\n\n" || string(chunks[1].Data) != "data: two\n\n" {
+		t.Fatalf("unexpected chunks: %q", chunks)
+	}
+	if chunks[1].Delay.Milliseconds() != 10 {
+		t.Errorf("second chunk delay = %v, expected 10ms", chunks[1].Delay)
+	}
+}
+
+func TestMockMetrics(t *testing.T) {
+	metrics := NewMetrics("mock")
+	server := httptest.NewServer(metrics.Instrument(NewMockServer(createMockHAR())))
+	defer server.Close()
+
+	for _, path := range []string{"/api/items?id=1", "/api/items", "/missing"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+
+	for _, expected := range []string{
+		`hargo_mock_requests_total{status="200"} 2`,
+		`hargo_mock_requests_total{status="404"} 1`,
+		`hargo_mock_bytes_total 35`,
+		`hargo_mock_request_duration_seconds_bucket{le="+Inf"} 3`,
+		`hargo_mock_request_duration_seconds_count 3`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("metrics missing %q:\n%s", expected, out)
+		}
+	}
+}
+
+func TestMockLatency(t *testing.T) {
+	har := createMockHAR()
+	har.Log.Entries[0].Timings = PageTimings{Wait: 100, Receive: 60}
+
+	m := NewMockServer(har)
+	m.LatencyScale = 0.5
+	server := httptest.NewServer(m)
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := http.Get(server.URL + "/api/items?id=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := time.Since(start)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	total := time.Since(start)
+
+	if string(body) != `{"id":1}` {
+		t.Errorf("body = %q", body)
+	}
+	if headers < 50*time.Millisecond {
+		t.Errorf("headers after %v, expected the scaled wait of 50ms", headers)
+	}
+	if total < 80*time.Millisecond {
+		t.Errorf("body after %v, expected the scaled wait and receive of 80ms", total)
+	}
+}
+
+func TestMockSequential(t *testing.T) {
+	har := Har{Log: Log{Entries: []Entry{
+		{Request: Request{Method: "GET", URL: "https://example.com/job/1"}, Response: Response{Status: 202, Content: Content{Text: "pending"}}},
+		{Request: Request{Method: "GET", URL: "https://example.com/other"}, Response: Response{Status: 200, Content: Content{Text: "other"}}},
+		{Request: Request{Method: "GET", URL: "https://example.com/job/1"}, Response: Response{Status: 202, Content: Content{Text: "running"}}},
+		{Request: Request{Method: "GET", URL: "https://example.com/job/1"}, Response: Response{Status: 200, Content: Content{Text: "ready"}}},
+	}}}
+
+	for _, withMatchers := range []bool{false, true} {
+		for _, sequential := range []bool{false, true} {
+			m := NewMockServer(har)
+			m.Sequential = sequential
+			if withMatchers {
+				m.Matchers = []RequestMatcher{PathMatcher{}}
+			}
+
+			var got []string
+			for i := 0; i < 4; i++ {
+				rec := httptest.NewRecorder()
+				m.ServeHTTP(rec, httptest.NewRequest("GET", "/job/1", nil))
+				got = append(got, rec.Body.String())
+			}
+
+			expected := "pending pending pending pending"
+			if sequential {
+				expected = "pending running ready ready"
+			}
+			if strings.Join(got, " ") != expected {
+				t.Errorf("matchers %v, sequential %v: got %q, expected %q", withMatchers, sequential, got, expected)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package hargo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RequestMatcher decides whether a request received by the MockServer
+// matches a recorded request. Methods are always compared by the server.
+type RequestMatcher interface {
+	Match(r *http.Request, body []byte, recorded Request) bool
+}
+
+// RequestMatcherFunc adapts a function to the RequestMatcher interface.
+type RequestMatcherFunc func(r *http.Request, body []byte, recorded Request) bool
+
+// Match calls f.
+func (f RequestMatcherFunc) Match(r *http.Request, body []byte, recorded Request) bool {
+	return f(r, body, recorded)
+}
+
+// ExactURLMatcher matches requests with the recorded path and query string.
+type ExactURLMatcher struct{}
+
+// Match implements RequestMatcher.
+func (ExactURLMatcher) Match(r *http.Request, body []byte, recorded Request) bool {
+	u, err := url.Parse(recorded.URL)
+	return err == nil && u.RequestURI() == r.URL.RequestURI()
+}
+
+// PathMatcher matches requests with the recorded path, whatever their query
+// string.
+type PathMatcher struct{}
+
+// Match implements RequestMatcher.
+func (PathMatcher) Match(r *http.Request, body []byte, recorded Request) bool {
+	u, err := url.Parse(recorded.URL)
+	return err == nil && u.Path == r.URL.Path
+}
+
+// QueryMatcher matches requests with the recorded path and query
+// parameters, in any order, except for the Ignore parameters, e.g. cache
+// busters or timestamps.
+type QueryMatcher struct {
+	Ignore []string
+}
+
+// Match implements RequestMatcher.
+func (m QueryMatcher) Match(r *http.Request, body []byte, recorded Request) bool {
+	u, err := url.Parse(recorded.URL)
+	if err != nil || u.Path != r.URL.Path {
+		return false
+	}
+	return m.params(u.Query()) == m.params(r.URL.Query())
+}
+
+// params encodes the query parameters that are not ignored.
+func (m QueryMatcher) params(query url.Values) string {
+	for _, name := range m.Ignore {
+		query.Del(name)
+	}
+	// Encode sorts by name and keeps the order of repeated values
+	return query.Encode()
+}
+
+// HeaderMatcher matches requests that carry the recorded values of the
+// named headers. Other headers are not compared.
+type HeaderMatcher struct {
+	Names []string
+}
+
+// Match implements RequestMatcher.
+func (m HeaderMatcher) Match(r *http.Request, body []byte, recorded Request) bool {
+	for _, name := range m.Names {
+		if r.Header.Get(name) != recordedHeader(recorded.Headers, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// JSONBodyMatcher matches requests whose JSON body has the structure of the
+// recorded one: the same keys and value types, values may differ. Requests
+// without a recorded body match on an empty body only, non-JSON bodies must
+// be equal.
+type JSONBodyMatcher struct{}
+
+// Match implements RequestMatcher.
+func (JSONBodyMatcher) Match(r *http.Request, body []byte, recorded Request) bool {
+	text := recorded.PostData.Text
+	var expected, got interface{}
+	if json.Unmarshal([]byte(text), &expected) != nil || json.Unmarshal(body, &got) != nil {
+		return text == string(body)
+	}
+	return len(jsonStructureDiff("$", expected, got)) == 0
+}
+
+// ParseMockMatchers returns the matchers for a URL strategy, exact, path or
+// query, combined with header and JSON body matching. ignoreParams selects
+// the query strategy if strategy is empty.
+func ParseMockMatchers(strategy string, ignoreParams []string, headers []string, jsonBody bool) ([]RequestMatcher, error) {
+	if strategy == "" && len(ignoreParams) > 0 {
+		strategy = "query"
+	}
+
+	var matchers []RequestMatcher
+	switch strings.ToLower(strategy) {
+	case "", "exact":
+		if len(ignoreParams) > 0 {
+			return nil, fmt.Errorf("ignored query parameters require the query strategy")
+		}
+		matchers = append(matchers, ExactURLMatcher{})
+	case "path":
+		matchers = append(matchers, PathMatcher{})
+	case "query":
+		matchers = append(matchers, QueryMatcher{Ignore: ignoreParams})
+	default:
+		return nil, fmt.Errorf("unknown match strategy %q", strategy)
+	}
+
+	if len(headers) > 0 {
+		matchers = append(matchers, HeaderMatcher{Names: headers})
+	}
+	if jsonBody {
+		matchers = append(matchers, JSONBodyMatcher{})
+	}
+	return matchers, nil
+}
@@ -0,0 +1,108 @@
+package hargo
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func createMatchHAR() Har {
+	return Har{
+		Log: Log{
+			Entries: []Entry{
+				{
+					Request: Request{
+						Method:  "GET",
+						URL:     "https://example.com/search?q=shoes&page=1&_=123",
+						Headers: []NVP{{Name: "Accept", Value: "application/json"}},
+					},
+					Response: Response{Status: 200, Content: Content{Text: "json results"}},
+				},
+				{
+					Request: Request{
+						Method:  "GET",
+						URL:     "https://example.com/search?q=shoes&page=1&_=123",
+						Headers: []NVP{{Name: "Accept", Value: "text/html"}},
+					},
+					Response: Response{Status: 200, Content: Content{Text: "html results"}},
+				},
+				{
+					Request: Request{
+						Method:   "POST",
+						URL:      "https://example.com/orders",
+						PostData: PostData{MimeType: "application/json", Text: `{"item":"a","qty":1}`},
+					},
+					Response: Response{Status: 201, Content: Content{Text: "created"}},
+				},
+			},
+		},
+	}
+}
+
+func TestMockMatchers(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "origin "+r.URL.RequestURI())
+	}))
+	defer origin.Close()
+
+	tests := []struct {
+		name     string
+		strategy string
+		ignore   []string
+		headers  []string
+		jsonBody bool
+		method   string
+		path     string
+		accept   string
+		body     string
+		expected string
+	}{
+		{"exact", "exact", nil, nil, false, "GET", "/search?q=shoes&page=1&_=123", "", "", "json results"},
+		{"exact other query", "exact", nil, nil, false, "GET", "/search?q=shoes&page=1&_=456", "", "", "origin /search?q=shoes&page=1&_=456"},
+		{"path", "path", nil, nil, false, "GET", "/search?q=boots", "", "", "json results"},
+		{"ignored param reordered", "", []string{"_"}, nil, false, "GET", "/search?page=1&_=456&q=shoes", "", "", "json results"},
+		{"other param", "", []string{"_"}, nil, false, "GET", "/search?q=boots&page=1", "", "", "origin /search?q=boots&page=1"},
+		{"header", "path", nil, []string{"Accept"}, false, "GET", "/search", "text/html", "", "html results"},
+		{"missing header", "path", nil, []string{"Accept"}, false, "GET", "/search", "", "", "origin /search"},
+		{"json structure", "path", nil, nil, true, "POST", "/orders", "", `{"qty":5,"item":"b"}`, "created"},
+		{"json structure differs", "path", nil, nil, true, "POST", "/orders", "", `{"item":"b"}`, "origin /orders"},
+		{"method differs", "path", nil, nil, false, "PUT", "/orders", "", "", "origin /orders"},
+	}
+
+	for _, test := range tests {
+		matchers, err := ParseMockMatchers(test.strategy, test.ignore, test.headers, test.jsonBody)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		m := NewMockServer(createMatchHAR())
+		m.Matchers = matchers
+		if m.Fallback, err = NewOriginProxy(origin.URL); err != nil {
+			t.Fatal(err)
+		}
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+		m.ServeHTTP(rec, req)
+
+		if got := rec.Body.String(); got != test.expected {
+			t.Errorf("%s: got %q, expected %q", test.name, got, test.expected)
+		}
+	}
+}
+
+func TestParseMockMatchersErrors(t *testing.T) {
+	if _, err := ParseMockMatchers("fuzzy", nil, nil, false); err == nil {
+		t.Error("unknown strategy accepted")
+	}
+	if _, err := ParseMockMatchers("exact", []string{"_"}, nil, false); err == nil {
+		t.Error("ignored parameters accepted with exact matching")
+	}
+	if _, err := NewOriginProxy("example.com"); err == nil {
+		t.Error("relative origin accepted")
+	}
+}
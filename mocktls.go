@@ -0,0 +1,178 @@
+package hargo
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HTTP/2 modes of the mock server.
+const (
+	// HTTP2Auto offers HTTP/2 if any response was recorded over HTTP/2 or
+	// the capture has no HTTP versions.
+	HTTP2Auto = "auto"
+	// HTTP2On always offers HTTP/2.
+	HTTP2On = "on"
+	// HTTP2Off serves HTTP/1.1 only.
+	HTTP2Off = "off"
+)
+
+// isHTTP2 reports whether a recorded httpVersion is HTTP/2 or later, e.g.
+// "h2", "HTTP/2.0" or "h3".
+func isHTTP2(version string) bool {
+	p := normalizeProtocol(version)
+	return p == "h2" || p == "h3"
+}
+
+// recordedHTTP2 reports whether HTTP/2 should be offered in HTTP2Auto mode.
+func recordedHTTP2(har Har) bool {
+	versions := false
+	for _, entry := range har.Log.Entries {
+		if isHTTP2(entry.Response.HTTPVersion) {
+			return true
+		}
+		if entry.Response.HTTPVersion != "" {
+			versions = true
+		}
+	}
+	return !versions
+}
+
+// mockHosts returns the host names of the recorded requests, plus the local
+// addresses, for the certificate of the mock server.
+func mockHosts(har Har) []string {
+	seen := map[string]bool{"localhost": true, "127.0.0.1": true, "::1": true}
+	for _, entry := range har.Log.Entries {
+		if u, err := url.Parse(entry.Request.URL); err == nil && u.Hostname() != "" {
+			seen[u.Hostname()] = true
+		}
+	}
+
+	hosts := make([]string, 0, len(seen))
+	for host := range seen {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// GenerateCertificate returns a self-signed certificate, valid for a year,
+// for the given host names and IP addresses.
+func GenerateCertificate(hosts []string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"hargo mock"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// writeCertificatePEM writes the certificate of cert to path, so clients
+// can be told to trust it.
+func writeCertificatePEM(path string, cert tls.Certificate) error {
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0644)
+}
+
+// mockTLSConfig returns the TLS settings of the mock server for opts, or nil
+// if it serves plain HTTP.
+func mockTLSConfig(har Har, opts MockOptions) (*tls.Config, error) {
+	if !opts.TLS && opts.CertFile == "" {
+		return nil, nil
+	}
+
+	var cert tls.Certificate
+	var err error
+	if opts.CertFile != "" {
+		if cert, err = tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile); err != nil {
+			return nil, err
+		}
+	} else {
+		if cert, err = GenerateCertificate(mockHosts(har)); err != nil {
+			return nil, err
+		}
+		log.Infof("Generated a self-signed certificate for %s", strings.Join(cert.Leaf.DNSNames, ", "))
+		if opts.WriteCertFile != "" {
+			if err := writeCertificatePEM(opts.WriteCertFile, cert); err != nil {
+				return nil, err
+			}
+			log.Infof("Wrote the certificate to %s", opts.WriteCertFile)
+		}
+	}
+
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}
+
+// newMockHTTPServer returns the server for handler, with TLS and HTTP/2 set
+// up as selected by opts.
+func newMockHTTPServer(har Har, addr string, handler http.Handler, opts MockOptions) (*http.Server, error) {
+	srv := &http.Server{Addr: addr, Handler: handler}
+
+	tlsConfig, err := mockTLSConfig(har, opts)
+	if err != nil {
+		return nil, err
+	}
+	srv.TLSConfig = tlsConfig
+
+	switch opts.HTTP2 {
+	case "", HTTP2Auto:
+		if !recordedHTTP2(har) {
+			// a non-nil empty map disables HTTP/2
+			srv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+		}
+	case HTTP2On:
+		if tlsConfig == nil {
+			return nil, fmt.Errorf("HTTP/2 requires TLS")
+		}
+	case HTTP2Off:
+		srv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	default:
+		return nil, fmt.Errorf("unknown HTTP/2 mode %q", opts.HTTP2)
+	}
+
+	return srv, nil
+}
@@ -0,0 +1,83 @@
+package hargo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMockTLS(t *testing.T) {
+	tests := []struct {
+		version  string
+		mode     string
+		expected string
+	}{
+		{"h2", HTTP2Auto, "HTTP/2.0"},
+		{"HTTP/1.1", HTTP2Auto, "HTTP/1.1"},
+		{"", HTTP2Auto, "HTTP/2.0"},
+		{"HTTP/1.1", HTTP2On, "HTTP/2.0"},
+		{"h2", HTTP2Off, "HTTP/1.1"},
+	}
+
+	for _, test := range tests {
+		har := createMockHAR()
+		har.Log.Entries[0].Response.HTTPVersion = test.version
+		certFile := filepath.Join(t.TempDir(), "mock.pem")
+
+		srv, err := newMockHTTPServer(har, "", NewMockServer(har), MockOptions{TLS: true, WriteCertFile: certFile, HTTP2: test.mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go srv.ServeTLS(l, "", "")
+
+		pemData, err := os.ReadFile(certFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots := x509.NewCertPool()
+		roots.AppendCertsFromPEM(pemData)
+		client := http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots},
+			ForceAttemptHTTP2: true,
+		}}
+
+		// the certificate is valid for the recorded host as well
+		if err := srv.TLSConfig.Certificates[0].Leaf.VerifyHostname("example.com"); err != nil {
+			t.Error(err)
+		}
+
+		resp, err := client.Get("https://" + l.Addr().String() + "/api/items?id=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		srv.Close()
+
+		if string(body) != `{"id":1}` {
+			t.Errorf("body = %q", body)
+		}
+		if resp.Proto != test.expected {
+			t.Errorf("recorded %q with HTTP/2 %s: served %s, expected %s", test.version, test.mode, resp.Proto, test.expected)
+		}
+	}
+}
+
+func TestMockTLSErrors(t *testing.T) {
+	har := createMockHAR()
+	if _, err := newMockHTTPServer(har, "", NewMockServer(har), MockOptions{HTTP2: HTTP2On}); err == nil {
+		t.Error("HTTP/2 accepted without TLS")
+	}
+	if _, err := newMockHTTPServer(har, "", NewMockServer(har), MockOptions{TLS: true, HTTP2: "maybe"}); err == nil {
+		t.Error("unknown HTTP/2 mode accepted")
+	}
+}
@@ -0,0 +1,156 @@
+package hargo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FormPart is a field or file of a multipart/form-data request body.
+type FormPart struct {
+	Name string
+	// FileName is set for uploaded files.
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+// IsMultipart reports whether the request body is multipart/form-data.
+func (r Request) IsMultipart() bool {
+	mimeType := r.PostData.MimeType
+	if mimeType == "" {
+		mimeType = recordedHeader(r.Headers, "Content-Type")
+	}
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// MultipartParts returns the parts of a multipart/form-data request body,
+// parsed from the posted text with the boundary of its MIME type or
+// Content-Type header, or taken from its params if the text was not
+// recorded.
+func MultipartParts(req Request) ([]FormPart, error) {
+	if req.PostData.Text == "" {
+		var parts []FormPart
+		for _, p := range req.PostData.Params {
+			parts = append(parts, FormPart{Name: p.Name, FileName: p.FileName, ContentType: p.ContentType, Data: []byte(p.Value)})
+		}
+		return parts, nil
+	}
+
+	boundary := ""
+	for _, mimeType := range []string{req.PostData.MimeType, recordedHeader(req.Headers, "Content-Type")} {
+		if _, params, err := mime.ParseMediaType(mimeType); err == nil && params["boundary"] != "" {
+			boundary = params["boundary"]
+			break
+		}
+	}
+	if boundary == "" {
+		return nil, fmt.Errorf("no multipart boundary")
+	}
+
+	var parts []FormPart
+	reader := multipart.NewReader(strings.NewReader(req.PostData.Text), boundary)
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return parts, err
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return parts, err
+		}
+		parts = append(parts, FormPart{
+			Name:        part.FormName(),
+			FileName:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Data:        data,
+		})
+	}
+}
+
+// partManifestEntry describes one part in parts.json.
+type partManifestEntry struct {
+	Name        string `json:"name"`
+	FileName    string `json:"fileName,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Size        int    `json:"size"`
+	Path        string `json:"path"`
+}
+
+// writeMultipartParts writes each part of the multipart request body of
+// entry i to its own file in a directory in the uploads directory of
+// outdir: files by their file name, fields as <name>.txt. parts.json in the
+// same directory lists the parts in order.
+func writeMultipartParts(outdir string, i int, entry Entry, target extractTarget, paths *pathAllocator, modTime time.Time) ([]ManifestEntry, error) {
+	parts, err := MultipartParts(entry.Request)
+	if len(parts) == 0 {
+		return nil, err
+	}
+	if err != nil {
+		// keep the parts read before the body was cut off
+		log.Warnf("Multipart body of %s is incomplete: %v", entry.Request.URL, err)
+	}
+
+	dir := paths.allocateDir(filepath.Join(outdir, "uploads", entryFilename(entry.Request.URL, strconv.Itoa(i))))
+	if err := target.mkdirAll(dir); err != nil {
+		return nil, err
+	}
+	partsPath := paths.allocate(filepath.Join(dir, "parts.json"))
+
+	var manifest []ManifestEntry
+	partManifest := make([]partManifestEntry, 0, len(parts))
+	for _, part := range parts {
+		filename, mimeType := part.FileName, part.ContentType
+		if filename == "" {
+			filename = part.Name + ".txt"
+			if mimeType == "" {
+				mimeType = "text/plain"
+			}
+		}
+		// browsers may send the client's full path, e.g. C:\fakepath\a.png
+		filename = path.Base(strings.ReplaceAll(filename, "\\", "/"))
+		partPath := paths.allocate(filepath.Join(dir, sanitizeFilename(filename)))
+		if err := target.writeFile(partPath, part.Data, modTime); err != nil {
+			return manifest, err
+		}
+
+		manifest = append(manifest, ManifestEntry{
+			OriginalURL:   entry.Request.URL,
+			ExtractedPath: partPath,
+			MimeType:      mimeType,
+			Size:          len(part.Data),
+			Method:        entry.Request.Method,
+			Status:        entry.Response.Status,
+			Sanitized:     filepath.Base(partPath) != filename,
+		})
+		partManifest = append(partManifest, partManifestEntry{
+			Name:        part.Name,
+			FileName:    part.FileName,
+			ContentType: part.ContentType,
+			Size:        len(part.Data),
+			Path:        filepath.Base(partPath),
+		})
+	}
+
+	data, err := json.MarshalIndent(partManifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	if err := target.writeFile(partsPath, append(data, '\n'), modTime); err != nil {
+		return manifest, err
+	}
+	return manifest, nil
+}
@@ -0,0 +1,88 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func multipartRequest(t *testing.T) Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	w.WriteField("title", "holiday")
+	file, _ := w.CreateFormFile("photo", `C:\fakepath\beach.png`)
+	file.Write([]byte("\x00PNG"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return Request{Method: "POST", URL: "https://example.com/upload",
+		PostData: PostData{MimeType: w.FormDataContentType(), Text: body.String()}}
+}
+
+func TestMultipartParts(t *testing.T) {
+	req := multipartRequest(t)
+	if !req.IsMultipart() {
+		t.Fatal("expected a multipart request")
+	}
+
+	parts, err := MultipartParts(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || parts[0].Name != "title" || string(parts[0].Data) != "holiday" {
+		t.Fatalf("unexpected parts %+v", parts)
+	}
+	if parts[1].Name != "photo" || parts[1].ContentType != "application/octet-stream" || len(parts[1].Data) != 4 {
+		t.Errorf("unexpected file part %+v", parts[1])
+	}
+
+	params := Request{PostData: PostData{MimeType: "multipart/form-data", Params: []PostParam{{Name: "a", Value: "1"}}}}
+	if parts, err := MultipartParts(params); err != nil || len(parts) != 1 || string(parts[0].Data) != "1" {
+		t.Errorf("unexpected parts from params %+v: %v", parts, err)
+	}
+
+	req.PostData.MimeType = "multipart/form-data"
+	if _, err := MultipartParts(req); err == nil {
+		t.Error("expected an error without a boundary")
+	}
+}
+
+func TestExtractMultipartParts(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	long := multipartRequest(t)
+	long.URL = "https://example.com/" + strings.Repeat("a", 300)
+	harData, _ := json.Marshal(Har{Log: Log{Entries: []Entry{
+		{Request: multipartRequest(t), Response: Response{Status: 201}},
+		{Request: long, Response: Response{Status: 201}},
+	}}})
+	if err := ExtractWithOptions(bufio.NewReader(bytes.NewReader(harData)), ExtractOptions{MultipartParts: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	dirs, _ := filepath.Glob(filepath.Join("hargo-extract-*", "uploads", "example.com_upload-0"))
+	if len(dirs) != 1 {
+		t.Fatal("expected an uploads directory")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dirs[0], "title.txt")); string(data) != "holiday" {
+		t.Errorf("unexpected field %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dirs[0], "beach.png")); len(data) != 4 {
+		t.Errorf("unexpected file %q", data)
+	}
+	data, _ := os.ReadFile(filepath.Join(dirs[0], "parts.json"))
+	if !strings.Contains(string(data), `"path": "beach.png"`) {
+		t.Errorf("unexpected parts.json %s", data)
+	}
+
+	// long URLs are shortened to the longest file name, keeping the index
+	dirs, _ = filepath.Glob(filepath.Join("hargo-extract-*", "uploads", "*-1"))
+	if len(dirs) != 1 || len(filepath.Base(dirs[0])) != maxFilenameLength {
+		t.Errorf("got uploads directories %v", dirs)
+	}
+}
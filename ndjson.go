@@ -0,0 +1,134 @@
+package hargo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// EntriesWriter writes HAR entries as newline-delimited JSON, one entry per
+// line, ready to be piped into jq, DuckDB or a log pipeline.
+type EntriesWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewEntriesWriter returns an EntriesWriter writing to w. Call Flush once
+// all entries are written.
+func NewEntriesWriter(w io.Writer) *EntriesWriter {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	return &EntriesWriter{w: bw, enc: enc}
+}
+
+// Write writes a single entry as one line of JSON.
+func (ew *EntriesWriter) Write(entry Entry) error {
+	return ew.enc.Encode(entry)
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (ew *EntriesWriter) Flush() error {
+	return ew.w.Flush()
+}
+
+// DumpNDJSON writes every entry of a .har file to w as newline-delimited
+// JSON. Entries are streamed in file order, so the whole document is never
+// held in memory.
+func DumpNDJSON(r *bufio.Reader, w io.Writer) error {
+	ew := NewEntriesWriter(w)
+	if err := forEachEntry(r, ew.Write); err != nil {
+		return err
+	}
+	return ew.Flush()
+}
+
+// forEachEntry calls fn for each entry of a .har file, streaming it, or of
+// a converted Charles session or mitmproxy flow file.
+func forEachEntry(r *bufio.Reader, fn func(Entry) error) error {
+	if !isCharles(r) && !isMitmproxy(r) {
+		return StreamEntries(r, fn)
+	}
+
+	har, err := readHar(r)
+	if err != nil {
+		return err
+	}
+	for _, entry := range har.Log.Entries {
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StreamEntries decodes the entries of a .har file one at a time, calling fn
+// for each of them in file order. Other members of the document are skipped.
+func StreamEntries(r io.Reader, fn func(Entry) error) error {
+	dec := json.NewDecoder(r)
+
+	return streamObject(dec, func(key string) error {
+		if key != "log" {
+			return skipValue(dec)
+		}
+		return streamObject(dec, func(key string) error {
+			if key != "entries" {
+				return skipValue(dec)
+			}
+			if err := expectDelim(dec, '['); err != nil {
+				return err
+			}
+			for dec.More() {
+				var entry Entry
+				if err := dec.Decode(&entry); err != nil {
+					return err
+				}
+				if err := fn(entry); err != nil {
+					return err
+				}
+			}
+			return expectDelim(dec, ']')
+		})
+	})
+}
+
+// streamObject reads a JSON object, calling member for each key; member
+// must consume the value.
+func streamObject(dec *json.Decoder, member func(key string) error) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := t.(string)
+		if !ok {
+			return fmt.Errorf("unexpected %v, expected object key", t)
+		}
+		if err := member(key); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// expectDelim reads the next token, which must be the delimiter d.
+func expectDelim(dec *json.Decoder, d json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t != d {
+		return fmt.Errorf("unexpected %v, expected %v", t, d)
+	}
+	return nil
+}
+
+// skipValue consumes the next JSON value.
+func skipValue(dec *json.Decoder) error {
+	var v json.RawMessage
+	return dec.Decode(&v)
+}
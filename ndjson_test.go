@@ -0,0 +1,65 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDumpNDJSON(t *testing.T) {
+	file, err := os.Open("test/golang.org.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	har, err := Decode(NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Seek(0, io.SeekStart)
+
+	var out bytes.Buffer
+	if err := DumpNDJSON(NewReader(file), &out); err != nil {
+		t.Fatalf("DumpNDJSON failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != len(har.Log.Entries) {
+		t.Fatalf("expected %d lines, got %d", len(har.Log.Entries), len(lines))
+	}
+	urls := make(map[string]bool)
+	for _, entry := range har.Log.Entries {
+		urls[entry.Request.URL] = true
+	}
+	for _, line := range lines {
+		var entry Entry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid line %q: %v", line, err)
+		}
+		if !urls[entry.Request.URL] {
+			t.Errorf("unexpected entry %s", entry.Request.URL)
+		}
+	}
+}
+
+func TestStreamEntriesSkipsOtherMembers(t *testing.T) {
+	doc := `{"comment": {"entries": [1]}, "log": {"pages": [{"id": "p"}], "entries": [
+		{"request": {"url": "http://a/"}}, {"request": {"url": "http://b/"}}], "creator": {"name": "x"}}}`
+
+	var urls []string
+	err := StreamEntries(bufio.NewReader(strings.NewReader(doc)), func(e Entry) error {
+		urls = append(urls, e.Request.URL)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamEntries failed: %v", err)
+	}
+	if strings.Join(urls, " ") != "http://a/ http://b/" {
+		t.Errorf("unexpected entries %v", urls)
+	}
+}
@@ -0,0 +1,172 @@
+package hargo
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+)
+
+// Normalize repairs the ordering and page timings of a Har in place:
+//   - entries are sorted chronologically by startedDateTime, keeping the
+//     original order for equal timestamps; entries with unparsable
+//     timestamps go last, in their original order
+//   - pages are sorted the same way, so pagerefs appear in the order the
+//     pages were visited
+//   - a page whose startedDateTime is later than its first entry is moved back
+//     to that entry, shifting onContentLoad/onLoad so the events keep their
+//     absolute time
+//   - a missing onLoad (-1 or 0) is recomputed as the end of the page's last
+//     entry, relative to the page start
+//   - the optional comment fields of the creator, pages, entries and their
+//     responses, contents, caches and timings are written as empty strings
+//     when absent, for tools that expect every field of the spec
+func Normalize(har *Har) {
+	entries := har.Log.Entries
+	keys := make([]startedKey, len(entries))
+	for i, entry := range entries {
+		keys[i] = newStartedKey(entry.StartedDateTime)
+	}
+	sortByStarted(keys, func(i, j int) { entries[i], entries[j] = entries[j], entries[i] })
+
+	pages := har.Log.Pages
+	keys = make([]startedKey, len(pages))
+	for i, page := range pages {
+		keys[i] = newStartedKey(page.StartedDateTime)
+	}
+	sortByStarted(keys, func(i, j int) { pages[i], pages[j] = pages[j], pages[i] })
+
+	for i := range pages {
+		normalizePage(&pages[i], entries)
+	}
+
+	fillComments(har)
+}
+
+// startedKey is the sort key of a startedDateTime: its time, or invalid.
+type startedKey struct {
+	t     time.Time
+	valid bool
+}
+
+func newStartedKey(s string) startedKey {
+	t, err := parseStartedDateTime(s)
+	return startedKey{t, err == nil}
+}
+
+// before orders valid times chronologically, before all invalid ones.
+func (k startedKey) before(o startedKey) bool {
+	if k.valid != o.valid {
+		return k.valid
+	}
+	return k.valid && k.t.Before(o.t)
+}
+
+// sortByStarted stably sorts keys, calling swap to move the elements they
+// belong to along with them.
+func sortByStarted(keys []startedKey, swap func(i, j int)) {
+	sort.Stable(startedKeys{keys, swap})
+}
+
+type startedKeys struct {
+	keys []startedKey
+	swap func(i, j int)
+}
+
+func (s startedKeys) Len() int           { return len(s.keys) }
+func (s startedKeys) Less(i, j int) bool { return s.keys[i].before(s.keys[j]) }
+func (s startedKeys) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.swap(i, j)
+}
+
+// emptyComment is the JSON of an empty comment field.
+var emptyComment = json.RawMessage(`""`)
+
+// fillComments adds an empty comment field to the objects that omit it
+// when it is empty. The field is written from Extras, see marshalExtras.
+func fillComments(har *Har) {
+	fill := func(comment string, extras *map[string]json.RawMessage) {
+		if comment != "" {
+			return
+		}
+		if *extras == nil {
+			*extras = make(map[string]json.RawMessage)
+		}
+		if _, ok := (*extras)["comment"]; !ok {
+			(*extras)["comment"] = emptyComment
+		}
+	}
+
+	fill(har.Log.Creator.Comment, &har.Log.Creator.Extras)
+	for i := range har.Log.Pages {
+		page := &har.Log.Pages[i]
+		fill(page.Comment, &page.Extras)
+	}
+	for i := range har.Log.Entries {
+		entry := &har.Log.Entries[i]
+		fill(entry.Comment, &entry.Extras)
+		fill(entry.Response.Comment, &entry.Response.Extras)
+		fill(entry.Response.Content.Comment, &entry.Response.Content.Extras)
+		fill(entry.Cache.Comment, &entry.Cache.Extras)
+		fill(entry.Timings.Comment, &entry.Timings.Extras)
+	}
+}
+
+// normalizePage aligns a page's start time and onLoad timing with the entries
+// that reference it.
+func normalizePage(page *Page, entries []Entry) {
+	var first, last time.Time
+	for _, entry := range entries {
+		if entry.Pageref != page.ID {
+			continue
+		}
+		start, err := parseStartedDateTime(entry.StartedDateTime)
+		if err != nil {
+			continue
+		}
+		end := start.Add(time.Duration(float64(entry.Time) * float64(time.Millisecond)))
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+		if last.IsZero() || end.After(last) {
+			last = end
+		}
+	}
+
+	if first.IsZero() {
+		return
+	}
+
+	pageStart, err := parseStartedDateTime(page.StartedDateTime)
+	if err != nil || first.Before(pageStart) {
+		if err == nil {
+			shift := float64(pageStart.Sub(first)) / float64(time.Millisecond)
+			if page.PageTiming.OnContentLoad > 0 {
+				page.PageTiming.OnContentLoad += shift
+			}
+			if page.PageTiming.OnLoad > 0 {
+				page.PageTiming.OnLoad += shift
+			}
+		}
+		pageStart = first
+		page.StartedDateTime = first.Format(time.RFC3339Nano)
+	}
+
+	if page.PageTiming.OnLoad <= 0 {
+		page.PageTiming.OnLoad = float64(last.Sub(pageStart)) / float64(time.Millisecond)
+	}
+}
+
+// NormalizeFile normalizes a .har file and writes the result to w.
+func NormalizeFile(r *bufio.Reader, w io.Writer) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	Normalize(&har)
+
+	return Encode(w, har)
+}
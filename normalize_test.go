@@ -0,0 +1,110 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeOrder(t *testing.T) {
+	har := Har{Log: Log{Entries: []Entry{
+		{StartedDateTime: "bad", Request: Request{URL: "bad-1"}},
+		{StartedDateTime: "2024-01-02T10:00:02.000Z", Request: Request{URL: "third"}},
+		{StartedDateTime: "2024-01-02T11:00:00.000+02:00", Request: Request{URL: "first"}},
+		{StartedDateTime: "", Request: Request{URL: "bad-2"}},
+		{StartedDateTime: "2024-01-02T10:00:01.000Z", Request: Request{URL: "second"}},
+		{StartedDateTime: "2024-01-02T10:00:01.000Z", Request: Request{URL: "second-tie"}},
+		{StartedDateTime: "a-bad", Request: Request{URL: "bad-3"}},
+	}}}
+
+	Normalize(&har)
+
+	var urls []string
+	for _, entry := range har.Log.Entries {
+		urls = append(urls, entry.Request.URL)
+	}
+	// unparsable timestamps go last, in their original order, whatever
+	// they compare to as strings
+	if got := strings.Join(urls, ","); got != "first,second,second-tie,third,bad-1,bad-2,bad-3" {
+		t.Errorf("got order %s", got)
+	}
+}
+
+func TestNormalizePages(t *testing.T) {
+	har := Har{Log: Log{
+		Pages: []Page{
+			{ID: "page_2", StartedDateTime: "2024-01-02T10:01:00.000Z", PageTiming: PageTiming{OnLoad: -1}},
+			{ID: "page_1", StartedDateTime: "2024-01-02T10:00:00.500Z", PageTiming: PageTiming{OnContentLoad: 100, OnLoad: 300}},
+		},
+		Entries: []Entry{
+			{Pageref: "page_1", StartedDateTime: "2024-01-02T10:00:00.000Z", Time: 50},
+			{Pageref: "page_2", StartedDateTime: "2024-01-02T10:01:00.100Z", Time: 400},
+			{Pageref: "page_2", StartedDateTime: "2024-01-02T10:01:00.200Z", Time: 100},
+		},
+	}}
+
+	Normalize(&har)
+
+	page1, page2 := har.Log.Pages[0], har.Log.Pages[1]
+	if page1.ID != "page_1" || page2.ID != "page_2" {
+		t.Fatalf("got pages %s, %s", page1.ID, page2.ID)
+	}
+	// moved back 500ms to its first entry, keeping the absolute event times
+	if page1.StartedDateTime != "2024-01-02T10:00:00Z" || page1.PageTiming.OnContentLoad != 600 || page1.PageTiming.OnLoad != 800 {
+		t.Errorf("got page_1 %+v", page1)
+	}
+	// onLoad recomputed as the end of the last entry to finish
+	if page2.StartedDateTime != "2024-01-02T10:01:00.000Z" || page2.PageTiming.OnLoad != 500 {
+		t.Errorf("got page_2 %+v", page2)
+	}
+}
+
+func TestNormalizeComments(t *testing.T) {
+	har := Har{Log: Log{
+		Pages:   []Page{{ID: "page_1", Comment: "kept"}},
+		Entries: []Entry{{Pageref: "page_1", StartedDateTime: "2024-01-02T10:00:00.000Z"}},
+	}}
+
+	var buf bytes.Buffer
+	harData, _ := json.Marshal(har)
+	if err := NormalizeFile(bufio.NewReader(bytes.NewReader(harData)), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var normalized struct {
+		Log struct {
+			Creator map[string]json.RawMessage   `json:"creator"`
+			Pages   []map[string]json.RawMessage `json:"pages"`
+			Entries []struct {
+				Comment  *string `json:"comment"`
+				Response struct {
+					Comment *string `json:"comment"`
+					Content struct {
+						Comment *string `json:"comment"`
+					} `json:"content"`
+				} `json:"response"`
+				Cache struct {
+					Comment *string `json:"comment"`
+				} `json:"cache"`
+				Timings struct {
+					Comment *string `json:"comment"`
+				} `json:"timings"`
+			} `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &normalized); err != nil {
+		t.Fatal(err)
+	}
+	if string(normalized.Log.Creator["comment"]) != `""` || string(normalized.Log.Pages[0]["comment"]) != `"kept"` {
+		t.Errorf("got %s", buf.String())
+	}
+	entry := normalized.Log.Entries[0]
+	for _, comment := range []*string{entry.Comment, entry.Response.Comment, entry.Response.Content.Comment, entry.Cache.Comment, entry.Timings.Comment} {
+		if comment == nil || *comment != "" {
+			t.Errorf("missing comment in %s", buf.String())
+			break
+		}
+	}
+}
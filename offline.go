@@ -0,0 +1,36 @@
+package hargo
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrOffline is returned by operations that would need network access while
+// offline mode is enabled.
+var ErrOffline = errors.New("network access is disabled in offline mode")
+
+var offline atomic.Bool
+
+// SetOffline enables or disables offline mode. While enabled, every
+// operation that would make a network call (fetching resources, replaying,
+// load testing, writing to InfluxDB) fails with ErrOffline instead, so hargo
+// can be used in air-gapped environments with the guarantee that nothing
+// leaves the machine.
+func SetOffline(enabled bool) {
+	offline.Store(enabled)
+}
+
+// Offline reports whether offline mode is enabled.
+func Offline() bool {
+	return offline.Load()
+}
+
+// requireNetwork returns an error wrapping ErrOffline if offline mode is
+// enabled. op names the operation in the error message.
+func requireNetwork(op string) error {
+	if Offline() {
+		return fmt.Errorf("%s: %w", op, ErrOffline)
+	}
+	return nil
+}
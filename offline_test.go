@@ -0,0 +1,41 @@
+package hargo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOffline(t *testing.T) {
+	SetOffline(true)
+	defer SetOffline(false)
+
+	err := Replay(bufio.NewReader(strings.NewReader(createTestHAR())), Config{})
+	if !errors.Is(err, ErrOffline) {
+		t.Errorf("Replay: expected ErrOffline, got %v", err)
+	}
+
+	err = Fetch(bufio.NewReader(strings.NewReader(createTestHAR())))
+	if !errors.Is(err, ErrOffline) {
+		t.Errorf("Fetch: expected ErrOffline, got %v", err)
+	}
+
+	err = MockWithOptions(bufio.NewReader(strings.NewReader(createTestHAR())), "localhost:0", MockOptions{Origin: "https://example.com"})
+	if !errors.Is(err, ErrOffline) {
+		t.Errorf("Mock: expected ErrOffline, got %v", err)
+	}
+
+	_, err = Coordinate(context.Background(), []string{"http://localhost:7070"}, Har{}, 1, time.Second, Config{}, false, "secret")
+	if !errors.Is(err, ErrOffline) {
+		t.Errorf("Coordinate: expected ErrOffline, got %v", err)
+	}
+
+	err = Daemon(context.Background(), []Job{{HarFile: "foo.har"}}, url.URL{}, false, false)
+	if !errors.Is(err, ErrOffline) {
+		t.Errorf("Daemon: expected ErrOffline, got %v", err)
+	}
+}
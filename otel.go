@@ -0,0 +1,432 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultOTLPEndpoint is the OTLP/HTTP traces endpoint of a local
+// OpenTelemetry collector, Jaeger or Tempo.
+const DefaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+
+// OTLPOptions control the export of a .har file as OpenTelemetry traces.
+type OTLPOptions struct {
+	// Endpoint is the OTLP/HTTP traces URL, DefaultOTLPEndpoint if empty.
+	Endpoint string
+	// ServiceName is the service.name of the spans, "browser" if empty.
+	ServiceName string
+	// Headers are sent with the export, e.g. for authentication.
+	Headers map[string]string
+	// Correlate joins request spans to the backend with every trace context
+	// EntryTraceContext finds, not only the traceparent request header:
+	// spans adopt trace ids propagated in other formats, link to traces
+	// started by the backend and carry the request ids as attributes.
+	Correlate bool
+}
+
+// Span kinds and status codes of OTLP.
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindClient   = 3
+	otlpStatusError      = 2
+)
+
+// The OTLP JSON encoding of spans, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding.
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Links             []otlpLink     `json:"links,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpLink struct {
+	TraceID string `json:"traceId"`
+	SpanID  string `json:"spanId"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func otlpString(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &value}}
+}
+
+func otlpInt(key string, value int64) otlpKeyValue {
+	s := strconv.FormatInt(value, 10)
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{IntValue: &s}}
+}
+
+func otlpDouble(key string, value float64) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{DoubleValue: &value}}
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// traceparentPattern matches a W3C traceparent header, capturing the trace
+// and parent ids.
+var traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$`)
+
+// HarToOTLP converts a .har file to OpenTelemetry traces in the OTLP JSON
+// encoding: a trace per page with a span covering the page load, and a
+// client span per request with its timing phases as events. Entries
+// without a page share a trace. Requests that carried a traceparent header
+// keep its trace and span id, so the spans of the backend that received
+// them are their children; they link to their page span instead.
+func HarToOTLP(har Har, serviceName string) ([]byte, error) {
+	return HarToOTLPWithOptions(har, OTLPOptions{ServiceName: serviceName})
+}
+
+// HarToOTLPWithOptions converts a .har file to OpenTelemetry traces like
+// HarToOTLP, with the service name and correlation of opts. The endpoint
+// and headers are only used by ExportTraces.
+func HarToOTLPWithOptions(har Har, opts OTLPOptions) ([]byte, error) {
+	serviceName := opts.ServiceName
+	if serviceName == "" {
+		serviceName = "browser"
+	}
+
+	type group struct {
+		page    *Page
+		entries []int
+	}
+	var groups []*group
+	byPage := make(map[string]*group)
+	for i := range har.Log.Pages {
+		g := &group{page: &har.Log.Pages[i]}
+		groups = append(groups, g)
+		byPage[har.Log.Pages[i].ID] = g
+	}
+	for i, entry := range har.Log.Entries {
+		g := byPage[entry.Pageref]
+		if g == nil {
+			g = &group{}
+			groups = append(groups, g)
+			byPage[entry.Pageref] = g
+		}
+		g.entries = append(g.entries, i)
+	}
+
+	var spans []otlpSpan
+	skipped := 0
+	for _, g := range groups {
+		root := otlpSpan{TraceID: randomID(16), SpanID: randomID(8), Kind: otlpSpanKindInternal}
+		var start, end time.Time
+		if g.page != nil {
+			root.Name = g.page.Title
+			root.Attributes = append(root.Attributes, otlpString("har.page.id", g.page.ID))
+			start, _ = parseStartedDateTime(g.page.StartedDateTime)
+		}
+
+		var children []otlpSpan
+		for _, i := range g.entries {
+			entry := har.Log.Entries[i]
+			span, entryStart, entryEnd, ok := entrySpan(i, entry, root, opts.Correlate)
+			if !ok {
+				skipped++
+				continue
+			}
+			if start.IsZero() || entryStart.Before(start) {
+				start = entryStart
+			}
+			if entryEnd.After(end) {
+				end = entryEnd
+			}
+			if root.Name == "" {
+				root.Name = entry.Request.URL
+			}
+			children = append(children, span)
+		}
+		if start.IsZero() {
+			continue
+		}
+
+		if g.page != nil {
+			for _, mark := range []struct {
+				name string
+				ms   float64
+			}{{"onContentLoad", g.page.PageTiming.OnContentLoad}, {"onLoad", g.page.PageTiming.OnLoad}} {
+				if mark.ms <= 0 {
+					continue
+				}
+				t := start.Add(time.Duration(mark.ms * float64(time.Millisecond)))
+				root.Events = append(root.Events, otlpEvent{TimeUnixNano: otlpTime(t), Name: mark.name})
+				if t.After(end) {
+					end = t
+				}
+			}
+		}
+		if end.Before(start) {
+			end = start
+		}
+		root.StartTimeUnixNano = otlpTime(start)
+		root.EndTimeUnixNano = otlpTime(end)
+
+		spans = append(spans, root)
+		spans = append(spans, children...)
+	}
+	if skipped > 0 {
+		log.Warnf("Skipped %d entries without a valid startedDateTime", skipped)
+	}
+
+	return json.Marshal(otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{otlpString("service.name", serviceName)}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/mrichman/hargo"},
+			Spans: spans,
+		}},
+	}}})
+}
+
+// entrySpan returns the client span of the entry at index below the span of
+// its page, with its start and end. With correlate, the span is joined to
+// the trace context of the entry.
+func entrySpan(index int, entry Entry, page otlpSpan, correlate bool) (otlpSpan, time.Time, time.Time, bool) {
+	start, err := parseStartedDateTime(entry.StartedDateTime)
+	if err != nil {
+		return otlpSpan{}, start, start, false
+	}
+	end := start.Add(time.Duration(float64(entry.Time) * float64(time.Millisecond)))
+
+	req := entry.Request
+	span := otlpSpan{
+		TraceID:           page.TraceID,
+		SpanID:            randomID(8),
+		ParentSpanID:      page.SpanID,
+		Name:              req.Method,
+		Kind:              otlpSpanKindClient,
+		StartTimeUnixNano: otlpTime(start),
+		EndTimeUnixNano:   otlpTime(end),
+	}
+	if m := traceparentPattern.FindStringSubmatch(strings.ToLower(recordedHeader(req.Headers, "traceparent"))); m != nil {
+		span.TraceID, span.SpanID, span.ParentSpanID = m[1], m[2], ""
+		span.Links = []otlpLink{{TraceID: page.TraceID, SpanID: page.SpanID}}
+	}
+	if correlate {
+		tc, _ := EntryTraceContext(index, entry)
+		correlateSpan(&span, tc, page)
+	}
+
+	span.Attributes = append(span.Attributes,
+		otlpString("http.request.method", req.Method),
+		otlpString("url.full", req.URL))
+	if u, err := url.Parse(req.URL); err == nil {
+		span.Name = strings.TrimSpace(req.Method + " " + u.Host + u.Path)
+		span.Attributes = append(span.Attributes, otlpString("server.address", u.Hostname()))
+		if port, err := strconv.Atoi(u.Port()); err == nil {
+			span.Attributes = append(span.Attributes, otlpInt("server.port", int64(port)))
+		}
+	}
+	if status := entry.Response.Status; status > 0 {
+		span.Attributes = append(span.Attributes, otlpInt("http.response.status_code", int64(status)))
+		if status >= 400 {
+			span.Status = otlpStatus{Code: otlpStatusError, Message: fmt.Sprintf("HTTP %d", status)}
+		}
+	} else {
+		span.Status = otlpStatus{Code: otlpStatusError, Message: "no response"}
+	}
+	if protocol := entry.NegotiatedProtocol(); protocol != "unknown" {
+		span.Attributes = append(span.Attributes, otlpString("network.protocol.name", "http"))
+		version := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(protocol), "http/"), "h")
+		span.Attributes = append(span.Attributes, otlpString("network.protocol.version", version))
+	}
+	if entry.Response.Content.Size > 0 {
+		span.Attributes = append(span.Attributes, otlpInt("http.response.body.size", int64(entry.Response.Content.Size)))
+	}
+	if entry.ServerIPAddress != "" {
+		span.Attributes = append(span.Attributes, otlpString("network.peer.address", entry.ServerIPAddress))
+	}
+	if entry.ResourceType != "" {
+		span.Attributes = append(span.Attributes, otlpString("har.resource_type", entry.ResourceType))
+	}
+	if mimeType := entry.Response.Content.MimeType; mimeType != "" {
+		span.Attributes = append(span.Attributes, otlpString("har.mime_type", mimeType))
+	}
+
+	span.Events = timingEvents(start, entry.Timings)
+	return span, start, end, true
+}
+
+// correlateSpan joins span to the backend trace of tc and adds its request
+// ids as attributes. A trace id sent by the client makes span the parent of
+// the backend spans, as with traceparent; a trace id returned by the
+// backend is linked.
+func correlateSpan(span *otlpSpan, tc TraceContext, page otlpSpan) {
+	for _, name := range tc.requestIDNames() {
+		span.Attributes = append(span.Attributes, otlpString("har.request_id."+name, tc.RequestIDs[name]))
+	}
+	if tc.TraceID == "" {
+		return
+	}
+	span.Attributes = append(span.Attributes, otlpString("har.trace_id", tc.TraceID), otlpString("har.trace_source", tc.source()))
+	if len(tc.TraceID) != 32 || len(tc.SpanID) != 16 {
+		return
+	}
+	if !tc.Response {
+		span.TraceID, span.SpanID, span.ParentSpanID = tc.TraceID, tc.SpanID, ""
+		span.Links = []otlpLink{{TraceID: page.TraceID, SpanID: page.SpanID}}
+		return
+	}
+	span.Links = append(span.Links, otlpLink{TraceID: tc.TraceID, SpanID: tc.SpanID})
+}
+
+// timingEvents marks the start of every timing phase of a request, with
+// its duration in milliseconds. The TLS handshake is part of connect.
+func timingEvents(start time.Time, t PageTimings) []otlpEvent {
+	var events []otlpEvent
+	at := start
+	for _, phase := range []struct {
+		name string
+		ms   float64
+	}{{"blocked", t.Blocked}, {"dns", t.DNS}, {"connect", t.Connect}, {"send", t.Send}, {"wait", t.Wait}, {"receive", t.Receive}} {
+		if phase.ms <= 0 {
+			continue
+		}
+		d := time.Duration(phase.ms * float64(time.Millisecond))
+		events = append(events, otlpEvent{TimeUnixNano: otlpTime(at), Name: phase.name,
+			Attributes: []otlpKeyValue{otlpDouble("duration_ms", phase.ms)}})
+		if phase.name == "connect" && t.Ssl > 0 && t.Ssl <= phase.ms {
+			ssl := at.Add(d - time.Duration(t.Ssl*float64(time.Millisecond)))
+			events = append(events, otlpEvent{TimeUnixNano: otlpTime(ssl), Name: "ssl",
+				Attributes: []otlpKeyValue{otlpDouble("duration_ms", t.Ssl)}})
+		}
+		at = at.Add(d)
+	}
+	return events
+}
+
+// randomID returns n random bytes in hex, as trace and span ids are
+// encoded in OTLP JSON.
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// HarToOTLPFile writes the OTLP JSON traces of a .har file to w.
+func HarToOTLPFile(r *bufio.Reader, w io.Writer, serviceName string) error {
+	return HarToOTLPFileWithOptions(r, w, OTLPOptions{ServiceName: serviceName})
+}
+
+// HarToOTLPFileWithOptions writes the OTLP JSON traces of a .har file to w
+// like HarToOTLPFile, with the service name and correlation of opts.
+func HarToOTLPFileWithOptions(r *bufio.Reader, w io.Writer, opts OTLPOptions) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	b, err := HarToOTLPWithOptions(har, opts)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// ExportTraces pushes the traces of a .har file to an OTLP/HTTP endpoint.
+func ExportTraces(ctx context.Context, r *bufio.Reader, opts OTLPOptions) error {
+	if err := requireNetwork("to-otel"); err != nil {
+		return err
+	}
+
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	b, err := HarToOTLPWithOptions(har, opts)
+	if err != nil {
+		return err
+	}
+
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultOTLPEndpoint
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range opts.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	log.Infof("Exported %d entries to %s", len(har.Log.Entries), endpoint)
+	return nil
+}
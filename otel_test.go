@@ -0,0 +1,179 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func decodeOTLP(t *testing.T, b []byte) []otlpSpan {
+	t.Helper()
+	var traces otlpTraces
+	if err := json.Unmarshal(b, &traces); err != nil {
+		t.Fatal(err)
+	}
+	if len(traces.ResourceSpans) != 1 || len(traces.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("got %+v", traces)
+	}
+	return traces.ResourceSpans[0].ScopeSpans[0].Spans
+}
+
+func attribute(span otlpSpan, key string) string {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			switch {
+			case kv.Value.StringValue != nil:
+				return *kv.Value.StringValue
+			case kv.Value.IntValue != nil:
+				return *kv.Value.IntValue
+			}
+		}
+	}
+	return ""
+}
+
+func TestHarToOTLP(t *testing.T) {
+	b, err := HarToOTLP(readTestHar(t, "otel.har"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := decodeOTLP(t, b)
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	page, cart, order := spans[0], spans[1], spans[2]
+
+	if page.Name != "Checkout" || page.ParentSpanID != "" || len(page.TraceID) != 32 || len(page.SpanID) != 16 {
+		t.Errorf("got page span %+v", page)
+	}
+	if page.StartTimeUnixNano != "1704189600000000000" || page.EndTimeUnixNano != "1704189600800000000" {
+		t.Errorf("got page span from %s to %s", page.StartTimeUnixNano, page.EndTimeUnixNano)
+	}
+	if len(page.Events) != 2 || page.Events[1].Name != "onLoad" {
+		t.Errorf("got page events %+v", page.Events)
+	}
+
+	if cart.Name != "GET shop.example.com:8443/cart" || cart.Kind != otlpSpanKindClient || cart.TraceID != page.TraceID || cart.ParentSpanID != page.SpanID {
+		t.Errorf("got cart span %+v", cart)
+	}
+	for key, want := range map[string]string{
+		"http.request.method":       "GET",
+		"server.address":            "shop.example.com",
+		"server.port":               "8443",
+		"http.response.status_code": "200",
+		"network.protocol.version":  "2",
+		"http.response.body.size":   "512",
+	} {
+		if got := attribute(cart, key); got != want {
+			t.Errorf("got %s %q, want %q", key, got, want)
+		}
+	}
+	var events []string
+	for _, e := range cart.Events {
+		events = append(events, e.Name)
+	}
+	if got := strings.Join(events, ","); got != "dns,connect,ssl,send,wait,receive" {
+		t.Errorf("got events %s", got)
+	}
+	// ssl ends with connect, 10ms + 40ms after the start
+	if ssl := cart.Events[2]; ssl.TimeUnixNano != "1704189600035000000" {
+		t.Errorf("got ssl event at %s", ssl.TimeUnixNano)
+	}
+	if cart.Status.Code != 0 {
+		t.Errorf("got cart status %+v", cart.Status)
+	}
+
+	if order.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || order.SpanID != "00f067aa0ba902b7" || order.ParentSpanID != "" {
+		t.Errorf("got order span %+v", order)
+	}
+	if len(order.Links) != 1 || order.Links[0].SpanID != page.SpanID {
+		t.Errorf("got order links %+v", order.Links)
+	}
+	if order.Status.Code != otlpStatusError || order.Status.Message != "HTTP 503" {
+		t.Errorf("got order status %+v", order.Status)
+	}
+}
+
+func TestExportTraces(t *testing.T) {
+	var body []byte
+	var auth string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer collector.Close()
+
+	data, err := os.ReadFile("test/otel.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := OTLPOptions{Endpoint: collector.URL + "/v1/traces", ServiceName: "web", Headers: map[string]string{"Authorization": "Bearer secret"}}
+	if err := ExportTraces(context.Background(), bufio.NewReader(bytes.NewReader(data)), opts); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("got Authorization %q", auth)
+	}
+	if spans := decodeOTLP(t, body); len(spans) != 3 {
+		t.Errorf("got %d spans", len(spans))
+	}
+
+	opts.Endpoint = collector.URL + "/wrong"
+	if err := ExportTraces(context.Background(), bufio.NewReader(bytes.NewReader(data)), opts); err == nil {
+		t.Error("expected an error for a rejected export")
+	}
+}
+
+func TestHarToOTLPCorrelate(t *testing.T) {
+	har := readTestHar(t, "propagation.har")
+
+	b, err := HarToOTLP(har, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := decodeOTLP(t, b)
+	if len(spans) != 3 || spans[1].TraceID != spans[0].TraceID || attribute(spans[1], "har.request_id.x-request-id") != "" {
+		t.Fatalf("got uncorrelated spans %+v", spans)
+	}
+
+	b, err = HarToOTLPWithOptions(har, OTLPOptions{Correlate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans = decodeOTLP(t, b)
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	root, propagated, started := spans[0], spans[1], spans[2]
+
+	if propagated.TraceID != "000000000000000080f198ee56343ba8" || propagated.SpanID != "e457b5a2e4d86bd1" || propagated.ParentSpanID != "" {
+		t.Errorf("got propagated span %+v", propagated)
+	}
+	if len(propagated.Links) != 1 || propagated.Links[0].SpanID != root.SpanID {
+		t.Errorf("got propagated links %+v", propagated.Links)
+	}
+	if got := attribute(propagated, "har.request_id.x-request-id"); got != "req-1" {
+		t.Errorf("got request id %q", got)
+	}
+	if got := attribute(propagated, "har.trace_source"); got != "request b3" {
+		t.Errorf("got trace source %q", got)
+	}
+
+	if started.TraceID != root.TraceID || started.ParentSpanID != root.SpanID {
+		t.Errorf("got backend started span %+v", started)
+	}
+	if len(started.Links) != 1 || started.Links[0].TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || started.Links[0].SpanID != "00f067aa0ba902b7" {
+		t.Errorf("got backend started links %+v", started.Links)
+	}
+}
@@ -0,0 +1,101 @@
+package hargo
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Pacing modes of a replay.
+const (
+	// PacingGaps waits the recorded gap between the start of a request and
+	// the previous one after the previous response arrived. It is the
+	// default.
+	PacingGaps = "gaps"
+	// PacingRecorded starts every request at its recorded offset from the
+	// first one, so slow responses do not delay the rest of the replay. A
+	// request due while the previous one is still running starts right
+	// after it.
+	PacingRecorded = "recorded"
+	// PacingNone sends the requests as fast as possible.
+	PacingNone = "none"
+)
+
+// replayPacer waits before each request of a replay as its pacing mode
+// requires.
+type replayPacer struct {
+	mode  string
+	speed float64
+	// starts are the recorded start times, zero where unknown.
+	starts []time.Time
+	// first and prev are the first and the previous request replayed, -1
+	// before any, as entries that cannot be replayed are skipped.
+	first, prev int
+	begin       time.Time
+}
+
+// newReplayPacer returns the pacer of entries for the Pacing and Speed of
+// cfg.
+func newReplayPacer(cfg Config, entries []Entry) (*replayPacer, error) {
+	p := &replayPacer{mode: cfg.Pacing, speed: cfg.Speed, first: -1, prev: -1}
+	switch p.mode {
+	case "":
+		p.mode = PacingGaps
+	case PacingGaps, PacingRecorded, PacingNone:
+	default:
+		return nil, fmt.Errorf("unknown pacing %q", cfg.Pacing)
+	}
+	if p.speed < 0 {
+		return nil, fmt.Errorf("invalid speed %g", cfg.Speed)
+	}
+	if p.speed == 0 {
+		p.speed = 1
+	}
+
+	p.starts = make([]time.Time, len(entries))
+	for i, entry := range entries {
+		p.starts[i], _ = parseStartedDateTime(entry.StartedDateTime)
+	}
+	return p, nil
+}
+
+// delay returns how long to wait before request i, as of now. Gaps and
+// offsets are those to the previous and the first request replayed.
+func (p *replayPacer) delay(i int, now time.Time) time.Duration {
+	prev := p.prev
+	p.prev = i
+	if p.first < 0 {
+		p.first, p.begin = i, now
+	}
+	if p.mode == PacingNone || i == p.first || p.starts[i].IsZero() {
+		return 0
+	}
+
+	switch p.mode {
+	case PacingRecorded:
+		if p.starts[p.first].IsZero() {
+			return 0
+		}
+		offset := time.Duration(float64(p.starts[i].Sub(p.starts[p.first])) / p.speed)
+		return p.begin.Add(offset).Sub(now)
+	default:
+		if p.starts[prev].IsZero() {
+			return 0
+		}
+		return time.Duration(float64(p.starts[i].Sub(p.starts[prev])) / p.speed)
+	}
+}
+
+// wait blocks until request i is due, returning early with the error of
+// ctx if it is done first.
+func (p *replayPacer) wait(ctx context.Context, i int) error {
+	if d := p.delay(i, time.Now()); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+	}
+	return ctx.Err()
+}
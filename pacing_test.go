@@ -0,0 +1,90 @@
+package hargo
+
+import (
+	"bufio"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReplayPacer(t *testing.T) {
+	entries := []Entry{
+		{StartedDateTime: "2020-01-01T00:00:00.000Z"},
+		{StartedDateTime: "2020-01-01T00:00:01.000Z"},
+		{StartedDateTime: "2020-01-01T00:00:03.000Z"},
+		{StartedDateTime: "invalid"},
+	}
+	begin := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		pacing   string
+		speed    float64
+		expected []time.Duration
+	}{
+		// the previous response took 500ms each time
+		{"", 0, []time.Duration{0, time.Second, 2 * time.Second, 0}},
+		{PacingGaps, 2, []time.Duration{0, 500 * time.Millisecond, time.Second, 0}},
+		{PacingRecorded, 1, []time.Duration{0, 500 * time.Millisecond, 1500 * time.Millisecond, 0}},
+		{PacingRecorded, 2, []time.Duration{0, 0, 500 * time.Millisecond, 0}},
+		{PacingNone, 1, []time.Duration{0, 0, 0, 0}},
+	}
+	for _, test := range tests {
+		p, err := newReplayPacer(Config{Pacing: test.pacing, Speed: test.speed}, entries)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := begin
+		for i, expected := range test.expected {
+			d := p.delay(i, now)
+			if d < 0 {
+				d = 0
+			}
+			if d != expected {
+				t.Errorf("%s x%g: delay before %d = %v, expected %v", test.pacing, test.speed, i, d, expected)
+			}
+			now = now.Add(d + 500*time.Millisecond)
+		}
+	}
+
+	for _, cfg := range []Config{{Pacing: "fast"}, {Speed: -1}} {
+		if _, err := newReplayPacer(cfg, entries); err == nil {
+			t.Errorf("%+v accepted", cfg)
+		}
+	}
+}
+
+func TestReplayPacerSkippedFirst(t *testing.T) {
+	file, err := os.Open("test/websocket.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	har, err := Decode(bufio.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := append(har.Log.Entries, Entry{StartedDateTime: "2024-01-02T10:00:03.000Z"})
+	begin := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// entry 0 is a WebSocket, which replays skip
+	tests := []struct {
+		pacing   string
+		expected []time.Duration
+	}{
+		{PacingGaps, []time.Duration{0, 2 * time.Second}},
+		{PacingRecorded, []time.Duration{0, 1500 * time.Millisecond}},
+	}
+	for _, test := range tests {
+		p, err := newReplayPacer(Config{Pacing: test.pacing}, entries)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := begin
+		for i, expected := range test.expected {
+			if d := p.delay(i+1, now); d != expected {
+				t.Errorf("%s: delay before %d = %v, expected %v", test.pacing, i+1, d, expected)
+			}
+			now = now.Add(expected + 500*time.Millisecond)
+		}
+	}
+}
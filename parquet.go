@@ -0,0 +1,302 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"net/url"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Parquet physical types, repetition types and converted types used by
+// the exporter (see parquet-format's parquet.thrift).
+const (
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired = 0
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+)
+
+// Thrift compact protocol field types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// parquetColumn is a column of the flattened entry table.
+type parquetColumn struct {
+	name      string
+	kind      int
+	converted int
+	// value returns the column value of an entry: a string, int64 or float64
+	// matching kind.
+	value func(e Entry) interface{}
+}
+
+// parquetColumns is the schema written by WriteParquet. Missing numeric
+// values are -1, as in HAR.
+var parquetColumns = []parquetColumn{
+	{"started", parquetInt64, parquetTimestampMillis, func(e Entry) interface{} {
+		t, err := parseStartedDateTime(e.StartedDateTime)
+		if err != nil {
+			return int64(0)
+		}
+		return t.UnixMilli()
+	}},
+	{"url", parquetByteArray, parquetUTF8, func(e Entry) interface{} { return e.Request.URL }},
+	{"host", parquetByteArray, parquetUTF8, func(e Entry) interface{} { return entryURL(e).Host }},
+	{"path", parquetByteArray, parquetUTF8, func(e Entry) interface{} { return entryURL(e).Path }},
+	{"method", parquetByteArray, parquetUTF8, func(e Entry) interface{} { return e.Request.Method }},
+	{"status", parquetInt64, -1, func(e Entry) interface{} { return int64(e.Response.Status) }},
+	{"mime_type", parquetByteArray, parquetUTF8, func(e Entry) interface{} { return e.Response.Content.MimeType }},
+	{"http_version", parquetByteArray, parquetUTF8, func(e Entry) interface{} { return e.Response.HTTPVersion }},
+	{"server_ip", parquetByteArray, parquetUTF8, func(e Entry) interface{} { return e.ServerIPAddress }},
+	{"request_headers_size", parquetInt64, -1, func(e Entry) interface{} { return int64(e.Request.HeaderSize) }},
+	{"request_body_size", parquetInt64, -1, func(e Entry) interface{} { return int64(e.Request.BodySize) }},
+	{"response_headers_size", parquetInt64, -1, func(e Entry) interface{} { return int64(e.Response.HeadersSize) }},
+	{"response_body_size", parquetInt64, -1, func(e Entry) interface{} { return int64(e.Response.BodySize) }},
+	{"content_size", parquetInt64, -1, func(e Entry) interface{} { return int64(e.Response.Content.Size) }},
+	{"time", parquetDouble, -1, func(e Entry) interface{} { return float64(e.Time) }},
+	{"blocked", parquetDouble, -1, func(e Entry) interface{} { return e.Timings.Blocked }},
+	{"dns", parquetDouble, -1, func(e Entry) interface{} { return e.Timings.DNS }},
+	{"connect", parquetDouble, -1, func(e Entry) interface{} { return e.Timings.Connect }},
+	{"ssl", parquetDouble, -1, func(e Entry) interface{} { return e.Timings.Ssl }},
+	{"send", parquetDouble, -1, func(e Entry) interface{} { return e.Timings.Send }},
+	{"wait", parquetDouble, -1, func(e Entry) interface{} { return e.Timings.Wait }},
+	{"receive", parquetDouble, -1, func(e Entry) interface{} { return e.Timings.Receive }},
+}
+
+// entryURL parses the request URL of an entry, returning an empty URL if
+// it is invalid.
+func entryURL(e Entry) *url.URL {
+	u, err := url.Parse(e.Request.URL)
+	if err != nil {
+		return &url.URL{}
+	}
+	return u
+}
+
+// ToParquet writes flattened metadata of every entry of a .har file (URL,
+// method, status, MIME type, sizes, timing phases and start time) to w as
+// a Parquet file, for analysis in DuckDB, Spark or pandas.
+func ToParquet(r *bufio.Reader, w io.Writer) error {
+	var entries []Entry
+	err := forEachEntry(r, func(e Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Writing %d entries to Parquet", len(entries))
+	return WriteParquet(w, entries)
+}
+
+// WriteParquet writes the flattened entries to w as an uncompressed Parquet
+// file with a single row group and PLAIN encoded columns.
+func WriteParquet(w io.Writer, entries []Entry) error {
+	var file bytes.Buffer
+	file.WriteString("PAR1")
+
+	type chunk struct {
+		offset int64
+		size   int64
+	}
+	chunks := make([]chunk, len(parquetColumns))
+
+	for i, col := range parquetColumns {
+		var values bytes.Buffer
+		for _, e := range entries {
+			switch v := col.value(e).(type) {
+			case string:
+				binary.Write(&values, binary.LittleEndian, uint32(len(v)))
+				values.WriteString(v)
+			case int64:
+				binary.Write(&values, binary.LittleEndian, v)
+			case float64:
+				binary.Write(&values, binary.LittleEndian, math.Float64bits(v))
+			}
+		}
+
+		var header thriftWriter
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(values.Len()))
+		header.i32(3, int32(values.Len()))
+		header.beginStruct(5)
+		header.i32(1, int32(len(entries)))
+		header.i32(2, 0) // PLAIN
+		header.i32(3, 3) // RLE
+		header.i32(4, 3) // RLE
+		header.endStruct()
+		header.stop()
+
+		chunks[i] = chunk{offset: int64(file.Len()), size: int64(header.buf.Len() + values.Len())}
+		file.Write(header.buf.Bytes())
+		file.Write(values.Bytes())
+	}
+
+	var meta thriftWriter
+	meta.i32(1, 1)
+	meta.listHeader(2, thriftStruct, len(parquetColumns)+1)
+	meta.begin()
+	meta.binary(4, "har_entry")
+	meta.i32(5, int32(len(parquetColumns)))
+	meta.stop()
+	meta.end()
+	for _, col := range parquetColumns {
+		meta.begin()
+		meta.i32(1, int32(col.kind))
+		meta.i32(3, parquetRequired)
+		meta.binary(4, col.name)
+		if col.converted >= 0 {
+			meta.i32(6, int32(col.converted))
+		}
+		meta.stop()
+		meta.end()
+	}
+	meta.i64(3, int64(len(entries)))
+
+	var total int64
+	for _, c := range chunks {
+		total += c.size
+	}
+	meta.listHeader(4, thriftStruct, 1)
+	meta.begin()
+	meta.listHeader(1, thriftStruct, len(parquetColumns))
+	for i, col := range parquetColumns {
+		meta.begin()
+		meta.i64(2, chunks[i].offset)
+		meta.beginStruct(3)
+		meta.i32(1, int32(col.kind))
+		meta.listHeader(2, thriftI32, 2)
+		meta.varint(0) // PLAIN
+		meta.varint(3) // RLE
+		meta.listHeader(3, thriftBinary, 1)
+		meta.rawBinary(col.name)
+		meta.i32(4, 0) // UNCOMPRESSED
+		meta.i64(5, int64(len(entries)))
+		meta.i64(6, chunks[i].size)
+		meta.i64(7, chunks[i].size)
+		meta.i64(9, chunks[i].offset)
+		meta.endStruct()
+		meta.stop()
+		meta.end()
+	}
+	meta.i64(2, total)
+	meta.i64(3, int64(len(entries)))
+	meta.stop()
+	meta.end()
+	meta.binary(6, "hargo")
+	meta.stop()
+
+	file.Write(meta.buf.Bytes())
+	binary.Write(&file, binary.LittleEndian, uint32(meta.buf.Len()))
+	file.WriteString("PAR1")
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// thriftWriter encodes structs with the Thrift compact protocol, which
+// Parquet uses for its page headers and file metadata.
+type thriftWriter struct {
+	buf bytes.Buffer
+	// last holds the id of the previous field of each open struct.
+	last  []int16
+	field int16
+}
+
+// fieldHeader writes the header of field id, delta encoding its id.
+func (t *thriftWriter) fieldHeader(id int16, kind byte) {
+	if delta := id - t.field; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		t.buf.WriteByte(kind)
+		t.varint(int64(id))
+	}
+	t.field = id
+}
+
+func (t *thriftWriter) varint(v int64) {
+	// zigzag encoding
+	u := uint64(v<<1) ^ uint64(v>>63)
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], u)
+	t.buf.Write(b[:n])
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.fieldHeader(id, thriftBinary)
+	t.rawBinary(s)
+}
+
+func (t *thriftWriter) rawBinary(s string) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], uint64(len(s)))
+	t.buf.Write(b[:n])
+	t.buf.WriteString(s)
+}
+
+// listHeader starts list field id of size elements of the given type.
+func (t *thriftWriter) listHeader(id int16, elem byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elem)
+		return
+	}
+	t.buf.WriteByte(0xf0 | elem)
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], uint64(size))
+	t.buf.Write(b[:n])
+}
+
+// beginStruct starts struct field id.
+func (t *thriftWriter) beginStruct(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.begin()
+}
+
+// endStruct terminates a struct started by beginStruct.
+func (t *thriftWriter) endStruct() {
+	t.stop()
+	t.end()
+}
+
+// begin starts a nested struct, e.g. a list element.
+func (t *thriftWriter) begin() {
+	t.last = append(t.last, t.field)
+	t.field = 0
+}
+
+// end returns to the enclosing struct. The nested struct must already be
+// terminated by stop.
+func (t *thriftWriter) end() {
+	t.field = t.last[len(t.last)-1]
+	t.last = t.last[:len(t.last)-1]
+}
+
+// stop terminates the current struct.
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
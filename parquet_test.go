@@ -0,0 +1,147 @@
+package hargo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// thriftReader decodes Thrift compact protocol structs into maps of field
+// id to value, enough to check the Parquet metadata written by WriteParquet.
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (t *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(t.data[t.pos:])
+	t.pos += n
+	return v
+}
+
+func (t *thriftReader) zigzag() int64 {
+	u := t.uvarint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (t *thriftReader) value(kind byte) (interface{}, error) {
+	switch kind {
+	case 1, 2:
+		return kind == 1, nil
+	case 5, 6:
+		return t.zigzag(), nil
+	case 8:
+		n := int(t.uvarint())
+		if t.pos+n > len(t.data) {
+			return nil, errors.New("binary out of range")
+		}
+		s := string(t.data[t.pos : t.pos+n])
+		t.pos += n
+		return s, nil
+	case 9:
+		header := t.data[t.pos]
+		t.pos++
+		size, elem := int(header>>4), header&0x0f
+		if size == 15 {
+			size = int(t.uvarint())
+		}
+		var list []interface{}
+		for i := 0; i < size; i++ {
+			v, err := t.value(elem)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case 12:
+		return t.structure()
+	default:
+		return nil, errors.New("unexpected type")
+	}
+}
+
+func (t *thriftReader) structure() (map[int16]interface{}, error) {
+	fields := make(map[int16]interface{})
+	var id int16
+	for {
+		if t.pos >= len(t.data) {
+			return nil, errors.New("unterminated struct")
+		}
+		header := t.data[t.pos]
+		t.pos++
+		if header == 0 {
+			return fields, nil
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(t.zigzag())
+		}
+		v, err := t.value(header & 0x0f)
+		if err != nil {
+			return nil, err
+		}
+		fields[id] = v
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	entries := []Entry{
+		{StartedDateTime: "2024-01-02T03:04:05.678Z", Request: Request{Method: "GET", URL: "https://example.com/a?x=1"}, Response: Response{Status: 200}, Timings: PageTimings{Wait: 12.5}},
+		{StartedDateTime: "2024-01-02T03:04:06.000Z", Request: Request{Method: "POST", URL: "https://api.example.com/b"}, Response: Response{Status: 404}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, entries); err != nil {
+		t.Fatalf("WriteParquet failed: %v", err)
+	}
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatal("missing Parquet magic")
+	}
+
+	length := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{data: data[len(data)-8-length : len(data)-8]}
+	meta, err := footer.structure()
+	if err != nil {
+		t.Fatalf("invalid file metadata: %v", err)
+	}
+	if footer.pos != length {
+		t.Errorf("file metadata is %d bytes, footer says %d", footer.pos, length)
+	}
+	if meta[3] != int64(2) {
+		t.Errorf("expected 2 rows, got %v", meta[3])
+	}
+	if schema := meta[2].([]interface{}); len(schema) != len(parquetColumns)+1 {
+		t.Errorf("expected %d schema elements, got %d", len(parquetColumns)+1, len(schema))
+	}
+
+	rowGroup := meta[4].([]interface{})[0].(map[int16]interface{})
+	columns := rowGroup[1].([]interface{})
+	for i, c := range columns {
+		chunk := c.(map[int16]interface{})[3].(map[int16]interface{})
+		offset := int(chunk[9].(int64))
+		page := &thriftReader{data: data[offset:]}
+		header, err := page.structure()
+		if err != nil {
+			t.Fatalf("invalid page header of %s: %v", parquetColumns[i].name, err)
+		}
+		if n := header[5].(map[int16]interface{})[1]; n != int64(2) {
+			t.Errorf("expected 2 values in %s, got %v", parquetColumns[i].name, n)
+		}
+		size := int(header[3].(int64))
+		if int64(page.pos+size) != chunk[7].(int64) {
+			t.Errorf("chunk size of %s does not match its page", parquetColumns[i].name)
+		}
+
+		if parquetColumns[i].name == "host" {
+			values := data[offset+page.pos : offset+page.pos+size]
+			n := binary.LittleEndian.Uint32(values)
+			if host := string(values[4 : 4+n]); host != "example.com" {
+				t.Errorf("unexpected first host %q", host)
+			}
+		}
+	}
+}
@@ -0,0 +1,698 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Link-layer header types of pcap captures supported by PcapToHar.
+const (
+	linkTypeNull     = 0
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeLoop     = 108
+	linkTypeLinuxSLL = 113
+	linkTypeSLL2     = 276
+)
+
+// ErrNotPcap is returned when the input is neither a pcap nor a pcapng file.
+var ErrNotPcap = errors.New("not a pcap or pcapng capture")
+
+// packet is a captured frame with its capture timestamp.
+type packet struct {
+	ts       time.Time
+	linkType int
+	data     []byte
+}
+
+// tcpSegment is the payload of a TCP packet in one direction of a connection.
+type tcpSegment struct {
+	ts   time.Time
+	seq  uint32
+	syn  bool
+	data []byte
+}
+
+// tcpFlow is one direction of a TCP connection.
+type tcpFlow struct {
+	src, dst net.TCPAddr
+	segments []tcpSegment
+}
+
+// streamMark maps a byte offset of a reassembled stream to the time the
+// segment starting at that offset was captured.
+type streamMark struct {
+	offset int
+	ts     time.Time
+}
+
+// tcpStream is the reassembled payload of a tcpFlow.
+type tcpStream struct {
+	data  []byte
+	marks []streamMark
+	syn   time.Time
+}
+
+// timeRange returns the earliest and latest capture times of the segments
+// carrying the bytes [start, end) of the stream.
+func (s tcpStream) timeRange(start, end int) (first, last time.Time) {
+	i := sort.Search(len(s.marks), func(i int) bool { return s.marks[i].offset > start })
+	if i > 0 {
+		i--
+	}
+	for ; i < len(s.marks); i++ {
+		if s.marks[i].offset >= end && !first.IsZero() {
+			break
+		}
+		ts := s.marks[i].ts
+		if first.IsZero() || ts.Before(first) {
+			first = ts
+		}
+		if ts.After(last) {
+			last = ts
+		}
+	}
+	return first, last
+}
+
+// PcapToHar reads a pcap or pcapng capture, reassembles its TCP streams and
+// converts the HTTP/1.x exchanges found in them to a Har. Encrypted (TLS)
+// traffic cannot be decoded and is skipped.
+func PcapToHar(r io.Reader) (Har, error) {
+	packets, err := readPackets(bufio.NewReader(r))
+	if err != nil {
+		return Har{}, err
+	}
+
+	flows := make(map[string]*tcpFlow)
+	var order []string
+
+	for _, p := range packets {
+		src, dst, seg, ok := decodeTCP(p)
+		if !ok {
+			continue
+		}
+		key := src.String() + ">" + dst.String()
+		flow, exists := flows[key]
+		if !exists {
+			flow = &tcpFlow{src: src, dst: dst}
+			flows[key] = flow
+			order = append(order, key)
+		}
+		flow.segments = append(flow.segments, seg)
+	}
+
+	var entries []Entry
+	done := make(map[string]bool)
+
+	for _, key := range order {
+		if done[key] {
+			continue
+		}
+		flow := flows[key]
+		reverse := flows[flow.dst.String()+">"+flow.src.String()]
+		done[key] = true
+		if reverse == nil {
+			continue
+		}
+		done[flow.dst.String()+">"+flow.src.String()] = true
+
+		client, server := flow, reverse
+		if !looksLikeRequest(reassemble(client).data) {
+			client, server = server, client
+		}
+		connEntries, err := httpExchanges(client, server)
+		if err != nil {
+			log.Debugf("Skipping TCP connection %s: %v", key, err)
+		}
+		entries = append(entries, connEntries...)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime < entries[j].StartedDateTime
+	})
+
+	return Har{
+		Log: Log{
+			Version: "1.2",
+			Creator: Creator{Name: "hargo"},
+			Entries: entries,
+		},
+	}, nil
+}
+
+// PcapToHarFile converts a pcap or pcapng capture to a .har file written to w.
+func PcapToHarFile(r io.Reader, w io.Writer) error {
+	har, err := PcapToHar(r)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Converted %d HTTP exchanges", len(har.Log.Entries))
+	return Encode(w, har)
+}
+
+// readPackets reads all frames of a pcap or pcapng capture.
+func readPackets(r *bufio.Reader) ([]packet, error) {
+	magic, err := r.Peek(4)
+	if err != nil {
+		return nil, ErrNotPcap
+	}
+
+	switch {
+	case bytes.Equal(magic, []byte{0x0a, 0x0d, 0x0d, 0x0a}):
+		return readPcapNG(r)
+	case bytes.Equal(magic, []byte{0xd4, 0xc3, 0xb2, 0xa1}), bytes.Equal(magic, []byte{0x4d, 0x3c, 0xb2, 0xa1}):
+		return readPcap(r, binary.LittleEndian)
+	case bytes.Equal(magic, []byte{0xa1, 0xb2, 0xc3, 0xd4}), bytes.Equal(magic, []byte{0xa1, 0xb2, 0x3c, 0x4d}):
+		return readPcap(r, binary.BigEndian)
+	default:
+		return nil, ErrNotPcap
+	}
+}
+
+// readPcap reads a classic libpcap file.
+func readPcap(r io.Reader, order binary.ByteOrder) ([]packet, error) {
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	nanos := order.Uint32(header[0:4]) == 0xa1b23c4d
+	linkType := int(order.Uint32(header[20:24]) & 0xffff)
+
+	var packets []packet
+	record := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(r, record); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return packets, nil
+			}
+			return packets, err
+		}
+		sec := int64(order.Uint32(record[0:4]))
+		frac := int64(order.Uint32(record[4:8]))
+		length := order.Uint32(record[8:12])
+		if length > 256<<20 {
+			return packets, fmt.Errorf("invalid packet length %d", length)
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return packets, nil
+		}
+
+		if !nanos {
+			frac *= 1000
+		}
+		packets = append(packets, packet{ts: time.Unix(sec, frac), linkType: linkType, data: data})
+	}
+}
+
+// pcapngInterface holds the per-interface settings of a pcapng section.
+type pcapngInterface struct {
+	linkType int
+	// tsUnit is the duration of one timestamp tick.
+	tsUnit float64
+}
+
+// readPcapNG reads a pcapng file.
+func readPcapNG(r io.Reader) ([]packet, error) {
+	var (
+		packets    []packet
+		order      binary.ByteOrder = binary.LittleEndian
+		interfaces []pcapngInterface
+	)
+
+	head := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, head); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return packets, nil
+			}
+			return packets, err
+		}
+
+		blockType := binary.LittleEndian.Uint32(head[0:4])
+		if blockType == 0x0a0d0d0a {
+			// the section header defines the byte order of everything after it
+			bom := make([]byte, 4)
+			if _, err := io.ReadFull(r, bom); err != nil {
+				return packets, err
+			}
+			if bytes.Equal(bom, []byte{0x1a, 0x2b, 0x3c, 0x4d}) {
+				order = binary.BigEndian
+			} else {
+				order = binary.LittleEndian
+			}
+			length := order.Uint32(head[4:8])
+			if length < 16 || length > 256<<20 {
+				return packets, fmt.Errorf("invalid pcapng section length %d", length)
+			}
+			if _, err := io.CopyN(io.Discard, r, int64(length)-12); err != nil {
+				return packets, err
+			}
+			interfaces = nil
+			continue
+		}
+
+		blockType = order.Uint32(head[0:4])
+		length := order.Uint32(head[4:8])
+		if length < 12 || length > 256<<20 {
+			return packets, fmt.Errorf("invalid pcapng block length %d", length)
+		}
+		body := make([]byte, length-8)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return packets, nil
+		}
+		body = body[:len(body)-4]
+
+		switch blockType {
+		case 1: // interface description
+			if len(body) < 8 {
+				continue
+			}
+			iface := pcapngInterface{linkType: int(order.Uint16(body[0:2])), tsUnit: 1e-6}
+			for opts := body[8:]; len(opts) >= 4; {
+				code, optLen := order.Uint16(opts[0:2]), int(order.Uint16(opts[2:4]))
+				if code == 0 || 4+optLen > len(opts) {
+					break
+				}
+				if code == 9 && optLen >= 1 {
+					res := opts[4]
+					if res&0x80 == 0 {
+						iface.tsUnit = math.Pow10(-int(res))
+					} else {
+						iface.tsUnit = math.Pow(2, -float64(res&0x7f))
+					}
+				}
+				opts = opts[4+(optLen+3)&^3:]
+			}
+			interfaces = append(interfaces, iface)
+		case 6: // enhanced packet
+			if len(body) < 20 {
+				continue
+			}
+			id := int(order.Uint32(body[0:4]))
+			if id >= len(interfaces) {
+				continue
+			}
+			ticks := uint64(order.Uint32(body[4:8]))<<32 | uint64(order.Uint32(body[8:12]))
+			capLen := int(order.Uint32(body[12:16]))
+			if 20+capLen > len(body) {
+				continue
+			}
+			seconds := float64(ticks) * interfaces[id].tsUnit
+			sec, frac := math.Modf(seconds)
+			packets = append(packets, packet{
+				ts:       time.Unix(int64(sec), int64(frac*1e9)),
+				linkType: interfaces[id].linkType,
+				data:     body[20 : 20+capLen],
+			})
+		case 3: // simple packet, without timestamp
+			if len(body) < 4 || len(interfaces) == 0 {
+				continue
+			}
+			packets = append(packets, packet{linkType: interfaces[0].linkType, data: body[4:]})
+		}
+	}
+}
+
+// decodeTCP extracts the TCP segment of a captured frame.
+func decodeTCP(p packet) (src net.TCPAddr, dst net.TCPAddr, seg tcpSegment, ok bool) {
+	data := p.data
+	var ethertype uint16
+
+	switch p.linkType {
+	case linkTypeEthernet:
+		if len(data) < 14 {
+			return
+		}
+		ethertype = binary.BigEndian.Uint16(data[12:14])
+		data = data[14:]
+		for ethertype == 0x8100 || ethertype == 0x88a8 {
+			if len(data) < 4 {
+				return
+			}
+			ethertype = binary.BigEndian.Uint16(data[2:4])
+			data = data[4:]
+		}
+	case linkTypeLinuxSLL:
+		if len(data) < 16 {
+			return
+		}
+		ethertype = binary.BigEndian.Uint16(data[14:16])
+		data = data[16:]
+	case linkTypeSLL2:
+		if len(data) < 20 {
+			return
+		}
+		ethertype = binary.BigEndian.Uint16(data[0:2])
+		data = data[20:]
+	case linkTypeNull, linkTypeLoop:
+		if len(data) < 4 {
+			return
+		}
+		data = data[4:]
+		ethertype = ipEthertype(data)
+	case linkTypeRaw, 12, 14:
+		ethertype = ipEthertype(data)
+	default:
+		return
+	}
+
+	var payload []byte
+	switch ethertype {
+	case 0x0800:
+		if len(data) < 20 || data[0]>>4 != 4 {
+			return
+		}
+		ihl := int(data[0]&0x0f) * 4
+		total := int(binary.BigEndian.Uint16(data[2:4]))
+		if data[9] != 6 || ihl < 20 || total > len(data) || ihl > total {
+			return
+		}
+		// fragments are not reassembled
+		if binary.BigEndian.Uint16(data[6:8])&0x3fff != 0 {
+			return
+		}
+		src.IP, dst.IP = net.IP(data[12:16]), net.IP(data[16:20])
+		payload = data[ihl:total]
+	case 0x86dd:
+		if len(data) < 40 || data[0]>>4 != 6 || data[6] != 6 {
+			return
+		}
+		length := int(binary.BigEndian.Uint16(data[4:6]))
+		if 40+length > len(data) {
+			length = len(data) - 40
+		}
+		src.IP, dst.IP = net.IP(data[8:24]), net.IP(data[24:40])
+		payload = data[40 : 40+length]
+	default:
+		return
+	}
+
+	if len(payload) < 20 {
+		return
+	}
+	offset := int(payload[12]>>4) * 4
+	if offset < 20 || offset > len(payload) {
+		return
+	}
+	flags := payload[13]
+
+	src.Port = int(binary.BigEndian.Uint16(payload[0:2]))
+	dst.Port = int(binary.BigEndian.Uint16(payload[2:4]))
+	seg = tcpSegment{
+		ts:   p.ts,
+		seq:  binary.BigEndian.Uint32(payload[4:8]),
+		syn:  flags&0x02 != 0,
+		data: payload[offset:],
+	}
+	return src, dst, seg, true
+}
+
+// ipEthertype guesses the ethertype of a raw IP packet from its version.
+func ipEthertype(data []byte) uint16 {
+	if len(data) == 0 {
+		return 0
+	}
+	switch data[0] >> 4 {
+	case 4:
+		return 0x0800
+	case 6:
+		return 0x86dd
+	default:
+		return 0
+	}
+}
+
+// reassemble orders the segments of a flow by sequence number, dropping
+// retransmitted data. Reassembly stops at the first gap.
+func reassemble(flow *tcpFlow) tcpStream {
+	var stream tcpStream
+	if len(flow.segments) == 0 {
+		return stream
+	}
+
+	base := flow.segments[0].seq
+	for _, seg := range flow.segments {
+		if seg.syn {
+			base = seg.seq + 1
+			stream.syn = seg.ts
+			break
+		}
+	}
+
+	type placed struct {
+		offset int64
+		seg    tcpSegment
+	}
+	var segments []placed
+	for _, seg := range flow.segments {
+		if len(seg.data) == 0 {
+			continue
+		}
+		segments = append(segments, placed{offset: int64(int32(seg.seq - base)), seg: seg})
+	}
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].offset < segments[j].offset })
+
+	var next int64
+	if len(segments) > 0 && segments[0].offset > 0 && stream.syn.IsZero() {
+		// capture started mid-stream
+		next = segments[0].offset
+	}
+	start := next
+
+	for _, s := range segments {
+		end := s.offset + int64(len(s.seg.data))
+		if end <= next {
+			continue
+		}
+		if s.offset > next {
+			break
+		}
+		stream.marks = append(stream.marks, streamMark{offset: int(next - start), ts: s.seg.ts})
+		stream.data = append(stream.data, s.seg.data[next-s.offset:]...)
+		next = end
+	}
+
+	return stream
+}
+
+// looksLikeRequest reports whether a stream starts with an HTTP request line.
+func looksLikeRequest(data []byte) bool {
+	line := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		line = data[:i]
+	}
+	fields := strings.Fields(string(line))
+	return len(fields) == 3 && strings.HasPrefix(fields[2], "HTTP/1.") && httpMethod(fields[0])
+}
+
+// httpMethod reports whether s is an HTTP method token.
+func httpMethod(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+// httpExchanges parses the HTTP/1.x requests sent by client and the
+// responses sent by server and pairs them in order.
+func httpExchanges(client *tcpFlow, server *tcpFlow) ([]Entry, error) {
+	reqStream, respStream := reassemble(client), reassemble(server)
+	if !looksLikeRequest(reqStream.data) {
+		return nil, errors.New("no HTTP/1.x requests (encrypted or non-HTTP traffic)")
+	}
+
+	reqCount := &countingReader{r: bytes.NewReader(reqStream.data)}
+	reqReader := bufio.NewReader(reqCount)
+	respCount := &countingReader{r: bytes.NewReader(respStream.data)}
+	respReader := bufio.NewReader(respCount)
+
+	var entries []Entry
+	for {
+		reqStart := reqCount.n - reqReader.Buffered()
+		req, err := http.ReadRequest(reqReader)
+		if err != nil {
+			if err == io.EOF {
+				return entries, nil
+			}
+			return entries, err
+		}
+		reqBody, _ := io.ReadAll(req.Body)
+		req.Body.Close()
+		reqEnd := reqCount.n - reqReader.Buffered()
+
+		var (
+			resp               *http.Response
+			respBody           []byte
+			respStart, respEnd int
+		)
+		for {
+			respStart = respCount.n - respReader.Buffered()
+			resp, err = http.ReadResponse(respReader, req)
+			if err != nil {
+				resp = nil
+				break
+			}
+			respBody, _ = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			respEnd = respCount.n - respReader.Buffered()
+			// skip interim responses such as 100 Continue
+			if resp.StatusCode >= 200 || resp.StatusCode == http.StatusSwitchingProtocols {
+				break
+			}
+		}
+
+		entry := pcapEntry(req, reqBody, resp, respBody, client)
+
+		started, sent := reqStream.timeRange(reqStart, reqEnd)
+		timings := PageTimings{DNS: -1, Connect: -1, Ssl: -1}
+		timings.Send = milliseconds(sent.Sub(started))
+		if len(entries) == 0 && !reqStream.syn.IsZero() && reqStream.syn.Before(started) {
+			timings.Connect = milliseconds(started.Sub(reqStream.syn))
+			started = reqStream.syn
+		}
+		if resp != nil {
+			first, last := respStream.timeRange(respStart, respEnd)
+			timings.Wait = math.Max(0, milliseconds(first.Sub(sent)))
+			timings.Receive = math.Max(0, milliseconds(last.Sub(first)))
+		}
+		entry.StartedDateTime = started.Format(harDateTimeLayout)
+		entry.Timings = timings
+		entry.Time = float32(positive(timings.Connect) + timings.Send + timings.Wait + timings.Receive)
+
+		entries = append(entries, entry)
+
+		if resp == nil || resp.StatusCode == http.StatusSwitchingProtocols {
+			return entries, nil
+		}
+	}
+}
+
+// pcapEntry builds an Entry from a parsed request and response.
+func pcapEntry(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, client *tcpFlow) Entry {
+	host := req.Host
+	if host == "" {
+		host = net.JoinHostPort(client.dst.IP.String(), strconv.Itoa(client.dst.Port))
+	}
+	u := *req.URL
+	if u.Scheme == "" {
+		u.Scheme = "http"
+	}
+	if u.Host == "" {
+		u.Host = host
+	}
+
+	entry := Entry{
+		Request: Request{
+			Method:      req.Method,
+			URL:         u.String(),
+			HTTPVersion: req.Proto,
+			Cookies:     harCookies(req.Cookies()),
+			Headers:     headerNVPs(req.Header),
+			QueryString: queryNVPs(u.Query()),
+			HeaderSize:  -1,
+			BodySize:    len(reqBody),
+		},
+		ServerIPAddress: client.dst.IP.String(),
+		Connection:      strconv.Itoa(client.src.Port),
+	}
+	// net/http moves the Host header to req.Host
+	if req.Header.Get("Host") == "" && req.Host != "" {
+		entry.Request.Headers = append([]NVP{{Name: "Host", Value: req.Host}}, entry.Request.Headers...)
+	}
+	if len(reqBody) > 0 {
+		entry.Request.PostData = PostData{MimeType: req.Header.Get("Content-Type"), Text: string(reqBody)}
+	}
+
+	if resp == nil {
+		entry.Response = Response{Status: 0, HeadersSize: -1, BodySize: -1, Comment: "no response captured"}
+		return entry
+	}
+
+	bodySize := len(respBody)
+	body := respBody
+	if decoded, err := decodeContentEncoding(resp.Header.Get("Content-Encoding"), respBody); err == nil {
+		body = decoded
+	}
+
+	mimeType := resp.Header.Get("Content-Type")
+	content := Content{Size: len(body), Compression: len(body) - bodySize, MimeType: mimeType}
+	if isTextMimeType(mimeType) {
+		content.Text = string(body)
+	} else if len(body) > 0 {
+		content.Text = base64.StdEncoding.EncodeToString(body)
+		content.Encoding = "base64"
+	}
+
+	entry.Response = Response{
+		Status:      resp.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode))),
+		HTTPVersion: resp.Proto,
+		Cookies:     harCookies(resp.Cookies()),
+		Headers:     headerNVPs(resp.Header),
+		Content:     content,
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    bodySize,
+	}
+	// net/http removes Transfer-Encoding from the header map
+	if len(resp.TransferEncoding) > 0 {
+		entry.Response.Headers = append(entry.Response.Headers, NVP{Name: "Transfer-Encoding", Value: strings.Join(resp.TransferEncoding, ", ")})
+	}
+
+	return entry
+}
+
+// decodeContentEncoding undoes gzip or deflate content encoding.
+func decodeContentEncoding(encoding string, body []byte) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "":
+		return body, nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(zr)
+	case "deflate":
+		return io.ReadAll(flate.NewReader(bytes.NewReader(body)))
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %s", encoding)
+	}
+}
@@ -0,0 +1,126 @@
+package hargo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// pcapWriter builds a little-endian classic pcap of Ethernet/IPv4/TCP frames.
+type pcapWriter struct {
+	buf bytes.Buffer
+	ts  time.Time
+}
+
+func newPcapWriter() *pcapWriter {
+	w := &pcapWriter{ts: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:4], 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(header[4:6], 2)
+	binary.LittleEndian.PutUint16(header[6:8], 4)
+	binary.LittleEndian.PutUint32(header[16:20], 65535)
+	binary.LittleEndian.PutUint32(header[20:24], linkTypeEthernet)
+	w.buf.Write(header)
+	return w
+}
+
+func (w *pcapWriter) segment(after time.Duration, src, dst string, sport, dport int, seq uint32, flags byte, payload string) {
+	w.ts = w.ts.Add(after)
+
+	tcp := make([]byte, 20)
+	binary.BigEndian.PutUint16(tcp[0:2], uint16(sport))
+	binary.BigEndian.PutUint16(tcp[2:4], uint16(dport))
+	binary.BigEndian.PutUint32(tcp[4:8], seq)
+	tcp[12] = 5 << 4
+	tcp[13] = flags
+	tcp = append(tcp, payload...)
+
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(tcp)))
+	ip[8] = 64
+	ip[9] = 6
+	copy(ip[12:16], net.ParseIP(src).To4())
+	copy(ip[16:20], net.ParseIP(dst).To4())
+
+	frame := make([]byte, 14)
+	binary.BigEndian.PutUint16(frame[12:14], 0x0800)
+	frame = append(frame, ip...)
+	frame = append(frame, tcp...)
+
+	record := make([]byte, 16)
+	binary.LittleEndian.PutUint32(record[0:4], uint32(w.ts.Unix()))
+	binary.LittleEndian.PutUint32(record[4:8], uint32(w.ts.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(record[8:12], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(record[12:16], uint32(len(frame)))
+	w.buf.Write(record)
+	w.buf.Write(frame)
+}
+
+func TestPcapToHar(t *testing.T) {
+	const (
+		syn = 0x02
+		ack = 0x10
+		psh = 0x08
+	)
+	client, server := "10.0.0.1", "10.0.0.2"
+	req1a := "GET /index.html?q=1 HTTP/1.1\r\nHost: example.com\r\n"
+	req1b := "Accept: */*\r\n\r\n"
+	resp1 := "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n"
+	req2 := "POST /api HTTP/1.1\r\nHost: example.com\r\nContent-Type: application/json\r\nContent-Length: 7\r\n\r\n{\"a\":1}"
+	resp2 := "HTTP/1.1 201 Created\r\nContent-Length: 0\r\n\r\n"
+
+	w := newPcapWriter()
+	w.segment(0, client, server, 50000, 80, 1000, syn, "")
+	w.segment(10*time.Millisecond, server, client, 80, 50000, 5000, syn|ack, "")
+	w.segment(time.Millisecond, client, server, 50000, 80, 1001, ack, "")
+	// second half of the request arrives first, then the first half twice
+	w.segment(time.Millisecond, client, server, 50000, 80, 1001+uint32(len(req1a)), ack|psh, req1b)
+	w.segment(time.Millisecond, client, server, 50000, 80, 1001, ack|psh, req1a)
+	w.segment(time.Millisecond, client, server, 50000, 80, 1001, ack|psh, req1a)
+	w.segment(20*time.Millisecond, server, client, 80, 50000, 5001, ack|psh, resp1[:40])
+	w.segment(5*time.Millisecond, server, client, 80, 50000, 5001+40, ack|psh, resp1[40:])
+	w.segment(time.Millisecond, client, server, 50000, 80, 1001+uint32(len(req1a+req1b)), ack|psh, req2)
+	w.segment(30*time.Millisecond, server, client, 80, 50000, 5001+uint32(len(resp1)), ack|psh, resp2)
+
+	har, err := PcapToHar(&w.buf)
+	if err != nil {
+		t.Fatalf("PcapToHar failed: %v", err)
+	}
+	if len(har.Log.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(har.Log.Entries))
+	}
+
+	first := har.Log.Entries[0]
+	if first.Request.URL != "http://example.com/index.html?q=1" {
+		t.Errorf("unexpected URL %s", first.Request.URL)
+	}
+	if first.Response.Status != 200 || first.Response.Content.Text != "hello" {
+		t.Errorf("unexpected response %d %q", first.Response.Status, first.Response.Content.Text)
+	}
+	if first.ServerIPAddress != server || first.Connection != "50000" {
+		t.Errorf("unexpected connection %s %s", first.ServerIPAddress, first.Connection)
+	}
+	if first.Timings.Connect != 12 || first.Timings.Send != 1 || first.Timings.Wait != 21 || first.Timings.Receive != 5 {
+		t.Errorf("unexpected timings %+v", first.Timings)
+	}
+	if first.StartedDateTime != "2024-01-02T03:04:05.000Z" {
+		t.Errorf("unexpected startedDateTime %s", first.StartedDateTime)
+	}
+
+	second := har.Log.Entries[1]
+	if second.Request.Method != "POST" || second.Request.PostData.Text != `{"a":1}` {
+		t.Errorf("unexpected request %s %q", second.Request.Method, second.Request.PostData.Text)
+	}
+	if second.Response.Status != 201 || second.Timings.Wait != 30 {
+		t.Errorf("unexpected response %d after %vms", second.Response.Status, second.Timings.Wait)
+	}
+}
+
+func TestPcapToHarRejectsOtherFiles(t *testing.T) {
+	if _, err := PcapToHar(bytes.NewReader([]byte("not a capture"))); err != ErrNotPcap {
+		t.Errorf("expected ErrNotPcap, got %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package hargo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// PageMetric summarizes the load performance of one page. Times are in
+// milliseconds since the page started loading; -1 if not available.
+type PageMetric struct {
+	ID              string  `json:"id"`
+	Title           string  `json:"title"`
+	URL             string  `json:"url"`
+	StartedDateTime string  `json:"startedDateTime"`
+	OnContentLoad   float64 `json:"onContentLoad"`
+	OnLoad          float64 `json:"onLoad"`
+	// TTFB is the time until the first byte of the main document arrived.
+	TTFB float64 `json:"ttfb"`
+	// FullyLoaded is the time the last request of the page finished.
+	FullyLoaded float64 `json:"fullyLoaded"`
+	ByteCount
+	// Blocking lists the render-blocking scripts and stylesheets requested
+	// before the page's content loaded, slowest first.
+	Blocking []BlockingResource `json:"blocking"`
+}
+
+// BlockingResource is a script or stylesheet delaying the first render.
+type BlockingResource struct {
+	URL    string  `json:"url"`
+	Type   string  `json:"type"`
+	Offset float64 `json:"offset"`
+	Time   float64 `json:"time"`
+}
+
+// PageMetrics computes per-page metrics: onContentLoad and onLoad from the
+// pages array, request and byte counts, time to first byte of the main
+// document (the first entry of the page), and the render-blocking resources.
+// Entries without a page, as in captures from tools without page grouping,
+// form a page of their own.
+func PageMetrics(har Har) []PageMetric {
+	var metrics []*PageMetric
+	byID := make(map[string]*PageMetric)
+	starts := make(map[string]time.Time)
+
+	for _, page := range har.Log.Pages {
+		m := &PageMetric{
+			ID:              page.ID,
+			Title:           page.Title,
+			StartedDateTime: page.StartedDateTime,
+			OnContentLoad:   timingOrUnknown(page.PageTiming.OnContentLoad),
+			OnLoad:          timingOrUnknown(page.PageTiming.OnLoad),
+			TTFB:            -1,
+		}
+		if t, err := parseStartedDateTime(page.StartedDateTime); err == nil {
+			starts[page.ID] = t
+		}
+		metrics = append(metrics, m)
+		byID[page.ID] = m
+	}
+
+	for _, entry := range har.Log.Entries {
+		m := byID[entry.Pageref]
+		if m == nil {
+			m = &PageMetric{ID: entry.Pageref, Title: entry.Request.URL, OnContentLoad: -1, OnLoad: -1, TTFB: -1}
+			metrics = append(metrics, m)
+			byID[entry.Pageref] = m
+		}
+
+		started, err := parseStartedDateTime(entry.StartedDateTime)
+		if _, ok := starts[entry.Pageref]; !ok && err == nil {
+			starts[entry.Pageref] = started
+			m.StartedDateTime = entry.StartedDateTime
+		}
+		offset := 0.0
+		if err == nil {
+			offset = milliseconds(started.Sub(starts[entry.Pageref]))
+		}
+
+		m.add(requestBytes(entry.Request), responseBytes(entry.Response))
+		if end := offset + float64(entry.Time); end > m.FullyLoaded {
+			m.FullyLoaded = end
+		}
+
+		if m.URL == "" {
+			m.URL = entry.Request.URL
+			t := entry.Timings
+			m.TTFB = offset + positive(t.Blocked) + positive(t.DNS) + positive(t.Connect) + positive(t.Send) + positive(t.Wait)
+			continue
+		}
+
+		if kind := blockingType(entry); kind != "" && (m.OnContentLoad < 0 || offset < m.OnContentLoad) {
+			m.Blocking = append(m.Blocking, BlockingResource{URL: entry.Request.URL, Type: kind, Offset: offset, Time: float64(entry.Time)})
+		}
+	}
+
+	result := make([]PageMetric, 0, len(metrics))
+	for _, m := range metrics {
+		sort.SliceStable(m.Blocking, func(i, j int) bool {
+			return m.Blocking[i].Time > m.Blocking[j].Time
+		})
+		result = append(result, *m)
+	}
+
+	return result
+}
+
+// timingOrUnknown normalizes missing page timings to -1.
+func timingOrUnknown(t float64) float64 {
+	if t <= 0 {
+		return -1
+	}
+	return t
+}
+
+// blockingType returns "script" or "stylesheet" for resources that block
+// rendering, "" otherwise.
+func blockingType(e Entry) string {
+	switch e.ResourceType {
+	case "script", "stylesheet":
+		return e.ResourceType
+	case "":
+	default:
+		return ""
+	}
+	mimeType := strings.ToLower(e.Response.Content.MimeType)
+	switch {
+	case strings.Contains(mimeType, "javascript"), strings.Contains(mimeType, "ecmascript"):
+		return "script"
+	case strings.Contains(mimeType, "text/css"):
+		return "stylesheet"
+	}
+	return ""
+}
+
+// Perf prints the performance metrics of every page of a .har file to w,
+// with at most top blocking resources per page.
+func Perf(r *bufio.Reader, w io.Writer, top int) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, m := range PageMetrics(har) {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "Page\t%s\t\n", m.Title)
+		fmt.Fprintf(tw, "URL\t%s\t\n", m.URL)
+		fmt.Fprintf(tw, "Started\t%s\t\n", m.StartedDateTime)
+		fmt.Fprintf(tw, "TTFB (ms)\t%s\t\n", formatTiming(m.TTFB))
+		fmt.Fprintf(tw, "onContentLoad (ms)\t%s\t\n", formatTiming(m.OnContentLoad))
+		fmt.Fprintf(tw, "onLoad (ms)\t%s\t\n", formatTiming(m.OnLoad))
+		fmt.Fprintf(tw, "Fully loaded (ms)\t%s\t\n", formatTiming(m.FullyLoaded))
+		fmt.Fprintf(tw, "Requests\t%d\t\n", m.Requests)
+		fmt.Fprintf(tw, "Sent (bytes)\t%d\t\n", m.Sent)
+		fmt.Fprintf(tw, "Received (bytes)\t%d\t\n", m.Received)
+
+		if len(m.Blocking) > 0 && top > 0 {
+			fmt.Fprintln(tw)
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", "Blocking resource", "Type", "Start (ms)", "Time (ms)")
+			for j, b := range m.Blocking {
+				if j == top {
+					break
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", shortURL(b.URL), b.Type, formatTiming(b.Offset), formatTiming(b.Time))
+			}
+		}
+	}
+
+	return tw.Flush()
+}
+
+// PerfHTML writes the performance metrics of every page of a .har file to w
+// as a self-contained HTML report, with at most top blocking resources per
+// page.
+func PerfHTML(r *bufio.Reader, w io.Writer, top int) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	return WriteAnalysisReport(w, PerfReport(reportTitle(har), PageMetrics(har), top))
+}
+
+// PerfReport returns the HTML report of page metrics, with at most top
+// blocking resources per page.
+func PerfReport(title string, metrics []PageMetric, top int) AnalysisReport {
+	report := AnalysisReport{Title: title, Figures: []ReportFigure{{"pages", strconv.Itoa(len(metrics))}}}
+
+	loaded := ReportChart{Title: "Fully loaded (ms)"}
+	pages := ReportSection{
+		Title:   "Pages",
+		Columns: []string{"Page", "URL", "TTFB (ms)", "onContentLoad (ms)", "onLoad (ms)", "Fully loaded (ms)", "Requests", "Sent (bytes)", "Received (bytes)"},
+		Numeric: []bool{false, false, true, true, true, true, true, true, true},
+	}
+	blocking := ReportSection{
+		Title:   "Blocking resources",
+		Columns: []string{"Page", "URL", "Type", "Start (ms)", "Time (ms)"},
+		Numeric: []bool{false, false, false, true, true},
+	}
+
+	for _, m := range metrics {
+		loaded.Bars = append(loaded.Bars, ReportBar{Label: m.Title, Value: m.FullyLoaded, Text: formatTiming(m.FullyLoaded)})
+		pages.Rows = append(pages.Rows, []string{
+			m.Title, m.URL,
+			formatTiming(m.TTFB), formatTiming(m.OnContentLoad), formatTiming(m.OnLoad), formatTiming(m.FullyLoaded),
+			strconv.Itoa(m.Requests), strconv.FormatInt(m.Sent, 10), strconv.FormatInt(m.Received, 10),
+		})
+		for j, b := range m.Blocking {
+			if j == top {
+				break
+			}
+			blocking.Rows = append(blocking.Rows, []string{m.Title, b.URL, b.Type, formatTiming(b.Offset), formatTiming(b.Time)})
+		}
+	}
+
+	report.Charts = append(report.Charts, loaded)
+	report.Sections = append(report.Sections, pages)
+	if len(blocking.Rows) > 0 {
+		report.Sections = append(report.Sections, blocking)
+	}
+	return report
+}
+
+// formatTiming formats a time in ms, "-" if unknown.
+func formatTiming(t float64) string {
+	if t < 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f", t)
+}
@@ -0,0 +1,110 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPageMetrics(t *testing.T) {
+	entry := func(pageref, started, url, mimeType string, time float32, timings PageTimings) Entry {
+		return Entry{
+			Pageref:         pageref,
+			StartedDateTime: started,
+			Time:            time,
+			Request:         Request{URL: url, HeaderSize: 100, BodySize: 0},
+			Response:        Response{HeadersSize: 200, BodySize: 1000, Content: Content{MimeType: mimeType}},
+			Timings:         timings,
+		}
+	}
+
+	har := Har{Log: Log{
+		Pages: []Page{{
+			ID:              "page_1",
+			Title:           "Example",
+			StartedDateTime: "2024-01-02T10:00:00.000Z",
+			PageTiming:      PageTiming{OnContentLoad: 400, OnLoad: 900},
+		}},
+		Entries: []Entry{
+			entry("page_1", "2024-01-02T10:00:00.010Z", "https://example.com/", "text/html", 150,
+				PageTimings{Blocked: 5, DNS: 10, Connect: 30, Send: 1, Wait: 54, Receive: 50}),
+			entry("page_1", "2024-01-02T10:00:00.200Z", "https://example.com/app.js", "application/javascript", 300, PageTimings{Wait: 300}),
+			entry("page_1", "2024-01-02T10:00:00.210Z", "https://example.com/style.css", "text/css", 80, PageTimings{Wait: 80}),
+			entry("page_1", "2024-01-02T10:00:00.500Z", "https://example.com/late.js", "application/javascript", 100, PageTimings{Wait: 100}),
+			entry("page_1", "2024-01-02T10:00:00.220Z", "https://example.com/logo.png", "image/png", 700, PageTimings{Wait: 700}),
+			entry("", "2024-01-02T11:00:00.000Z", "https://api.example.com/ping", "application/json", 20, PageTimings{Wait: 20}),
+		},
+	}}
+
+	metrics := PageMetrics(har)
+	if len(metrics) != 2 {
+		t.Fatalf("expected 2 pages, got %d", len(metrics))
+	}
+
+	m := metrics[0]
+	if m.URL != "https://example.com/" || m.OnContentLoad != 400 || m.OnLoad != 900 {
+		t.Errorf("unexpected page %+v", m)
+	}
+	if m.TTFB != 110 {
+		t.Errorf("TTFB = %v, expected 110", m.TTFB)
+	}
+	if m.FullyLoaded != 920 {
+		t.Errorf("FullyLoaded = %v, expected 920", m.FullyLoaded)
+	}
+	if m.Requests != 5 || m.Sent != 500 || m.Received != 6000 {
+		t.Errorf("unexpected byte count %+v", m.ByteCount)
+	}
+	if len(m.Blocking) != 2 || m.Blocking[0].URL != "https://example.com/app.js" || m.Blocking[1].Type != "stylesheet" {
+		t.Errorf("unexpected blocking resources %+v", m.Blocking)
+	}
+
+	if other := metrics[1]; other.ID != "" || other.OnLoad != -1 || other.TTFB != 20 || other.Requests != 1 {
+		t.Errorf("unexpected page without pageref %+v", other)
+	}
+}
+
+func TestPerf(t *testing.T) {
+	data, err := json.Marshal(Har{Log: Log{Entries: []Entry{{
+		StartedDateTime: "2024-01-02T10:00:00.000Z",
+		Request:         Request{URL: "https://example.com/"},
+		Timings:         PageTimings{Wait: 42},
+		Time:            42,
+	}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := Perf(bufio.NewReader(bytes.NewReader(data)), &out, 5); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"TTFB (ms)           42", "onLoad (ms)         -", "Requests            1"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("output does not contain %q:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestPerfHTML(t *testing.T) {
+	data, err := json.Marshal(Har{Log: Log{Entries: []Entry{{
+		StartedDateTime: "2024-01-02T10:00:00.000Z",
+		Request:         Request{URL: "https://example.com/"},
+		Timings:         PageTimings{Wait: 42},
+		Time:            42,
+	}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := PerfHTML(bufio.NewReader(bytes.NewReader(data)), &out, 5); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"<h2>Fully loaded (ms)</h2>", "<td class=\"num\">42</td>", "https://example.com/"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("output does not contain %q", expected)
+		}
+	}
+}
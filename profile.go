@@ -0,0 +1,211 @@
+package hargo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// LoadProfile shapes the request rate of a load test. RPS alone keeps a
+// constant rate; together with RampUp and RampDown the rate ramps up from
+// zero, holds and ramps down again; with Steps it rises to RPS in that many
+// equal steps; with SpikeRPS it jumps from RPS to SpikeRPS for
+// SpikeDuration after SpikeAt. Stages describe any other shape. Durations
+// are strings like "30s" or "2m". A zero profile does not limit the rate.
+type LoadProfile struct {
+	RPS           float64     `json:"rps"`
+	RampUp        string      `json:"rampUp"`
+	RampDown      string      `json:"rampDown"`
+	Steps         int         `json:"steps"`
+	SpikeRPS      float64     `json:"spikeRps"`
+	SpikeAt       string      `json:"spikeAt"`
+	SpikeDuration string      `json:"spikeDuration"`
+	Stages        []LoadStage `json:"stages"`
+}
+
+// LoadStage changes the request rate linearly from the target of the
+// previous stage, or zero, to RPS over Duration. A stage without a
+// duration sets the rate at once.
+type LoadStage struct {
+	Duration string  `json:"duration"`
+	RPS      float64 `json:"rps"`
+}
+
+// loadStage is a LoadStage with its duration parsed.
+type loadStage struct {
+	duration time.Duration
+	rps      float64
+}
+
+// LoadProfileFile reads a load profile from a JSON file or a YAML file
+// using block mappings and sequences only:
+//
+//	rps: 100
+//	rampUp: 30s
+//	rampDown: 10s
+//
+// or
+//
+//	stages:
+//	  - duration: 1m
+//	    rps: 50
+//	  - duration: 30s
+//	    rps: 0
+func LoadProfileFile(path string) (LoadProfile, error) {
+	var profile LoadProfile
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return profile, err
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		if b, err = yamlToJSON(b, &profile); err != nil {
+			return profile, fmt.Errorf("invalid profile %s: %v", path, err)
+		}
+	}
+
+	if err := json.Unmarshal(b, &profile); err != nil {
+		return profile, fmt.Errorf("invalid profile %s: %v", path, err)
+	}
+	return profile, nil
+}
+
+// stages returns the stages of p for a load test running for total.
+func (p LoadProfile) stages(total time.Duration) ([]loadStage, error) {
+	durations := make(map[string]time.Duration)
+	for name, value := range map[string]string{"rampUp": p.RampUp, "rampDown": p.RampDown, "spikeAt": p.SpikeAt, "spikeDuration": p.SpikeDuration} {
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid %s %q", name, value)
+		}
+		durations[name] = d
+	}
+	if p.RPS < 0 || p.SpikeRPS < 0 || p.Steps < 0 {
+		return nil, fmt.Errorf("invalid load profile %+v", p)
+	}
+
+	ramp := p.RampUp != "" || p.RampDown != ""
+	spike := p.SpikeRPS > 0 || p.SpikeAt != "" || p.SpikeDuration != ""
+	shapes := 0
+	for _, set := range []bool{ramp, p.Steps > 0, spike, len(p.Stages) > 0} {
+		if set {
+			shapes++
+		}
+	}
+	if shapes > 1 {
+		return nil, fmt.Errorf("load profile combines several shapes")
+	}
+	if len(p.Stages) == 0 && p.RPS == 0 && shapes > 0 {
+		return nil, fmt.Errorf("load profile needs rps")
+	}
+
+	switch {
+	case len(p.Stages) > 0:
+		stages := make([]loadStage, len(p.Stages))
+		for i, s := range p.Stages {
+			if s.RPS < 0 {
+				return nil, fmt.Errorf("invalid rps %g of stage %d", s.RPS, i+1)
+			}
+			stages[i].rps = s.RPS
+			if s.Duration != "" {
+				d, err := time.ParseDuration(s.Duration)
+				if err != nil || d < 0 {
+					return nil, fmt.Errorf("invalid duration %q of stage %d", s.Duration, i+1)
+				}
+				stages[i].duration = d
+			}
+		}
+		return stages, nil
+	case ramp:
+		up, down := durations["rampUp"], durations["rampDown"]
+		hold := total - up - down
+		if hold < 0 {
+			hold = 0
+		}
+		return []loadStage{{up, p.RPS}, {hold, p.RPS}, {down, 0}}, nil
+	case p.Steps > 0:
+		var stages []loadStage
+		step := total / time.Duration(p.Steps)
+		for i := 1; i <= p.Steps; i++ {
+			rps := p.RPS * float64(i) / float64(p.Steps)
+			stages = append(stages, loadStage{0, rps}, loadStage{step, rps})
+		}
+		return stages, nil
+	case spike:
+		if p.SpikeRPS == 0 || p.SpikeDuration == "" {
+			return nil, fmt.Errorf("spike needs spikeRps and spikeDuration")
+		}
+		return []loadStage{
+			{0, p.RPS}, {durations["spikeAt"], p.RPS},
+			{0, p.SpikeRPS}, {durations["spikeDuration"], p.SpikeRPS},
+			{0, p.RPS},
+		}, nil
+	case p.RPS > 0:
+		return []loadStage{{0, p.RPS}}, nil
+	}
+	return nil, nil
+}
+
+// scaled returns p with all its rates multiplied by factor, e.g. for the
+// share of one of several load agents.
+func (p LoadProfile) scaled(factor float64) LoadProfile {
+	p.RPS *= factor
+	p.SpikeRPS *= factor
+	stages := make([]LoadStage, len(p.Stages))
+	for i, s := range p.Stages {
+		stages[i] = LoadStage{Duration: s.Duration, RPS: s.RPS * factor}
+	}
+	if p.Stages != nil {
+		p.Stages = stages
+	}
+	return p
+}
+
+// rateAt returns the requests per second due at elapsed into stages. After
+// the last stage its rate is kept.
+func rateAt(stages []loadStage, elapsed time.Duration) float64 {
+	rps := 0.0
+	for _, s := range stages {
+		if elapsed < s.duration {
+			return rps + (s.rps-rps)*float64(elapsed)/float64(s.duration)
+		}
+		elapsed -= s.duration
+		rps = s.rps
+	}
+	return rps
+}
+
+// profileTick is how often the rate of a load profile is evaluated.
+const profileTick = 10 * time.Millisecond
+
+// paceLoad sends a token on tokens for every request the stages allow until
+// ctx is done.
+func paceLoad(ctx context.Context, stages []loadStage, tokens chan<- struct{}) {
+	ticker := time.NewTicker(profileTick)
+	defer ticker.Stop()
+
+	start := time.Now()
+	credit := 0.0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			credit += rateAt(stages, now.Sub(start)) * profileTick.Seconds()
+		}
+		for ; credit >= 1; credit-- {
+			select {
+			case <-ctx.Done():
+				return
+			case tokens <- struct{}{}:
+			}
+		}
+	}
+}
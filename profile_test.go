@@ -0,0 +1,108 @@
+package hargo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadProfileRate(t *testing.T) {
+	total := 100 * time.Second
+	tests := []struct {
+		profile  LoadProfile
+		expected map[time.Duration]float64
+	}{
+		{LoadProfile{}, map[time.Duration]float64{0: 0, 50 * time.Second: 0}},
+		{LoadProfile{RPS: 10}, map[time.Duration]float64{0: 10, 99 * time.Second: 10}},
+		{
+			LoadProfile{RPS: 100, RampUp: "10s", RampDown: "20s"},
+			map[time.Duration]float64{0: 0, 5 * time.Second: 50, 50 * time.Second: 100, 90 * time.Second: 50, 100 * time.Second: 0},
+		},
+		{
+			LoadProfile{RPS: 40, Steps: 4},
+			map[time.Duration]float64{0: 10, 24 * time.Second: 10, 25 * time.Second: 20, 99 * time.Second: 40},
+		},
+		{
+			LoadProfile{RPS: 10, SpikeRPS: 100, SpikeAt: "30s", SpikeDuration: "5s"},
+			map[time.Duration]float64{0: 10, 30 * time.Second: 100, 34 * time.Second: 100, 35 * time.Second: 10},
+		},
+		{
+			LoadProfile{Stages: []LoadStage{{Duration: "10s", RPS: 20}, {RPS: 5}, {Duration: "10s", RPS: 15}}},
+			map[time.Duration]float64{5 * time.Second: 10, 10 * time.Second: 5, 15 * time.Second: 10, 60 * time.Second: 15},
+		},
+	}
+	for _, test := range tests {
+		stages, err := test.profile.stages(total)
+		if err != nil {
+			t.Fatalf("%+v: %v", test.profile, err)
+		}
+		for elapsed, expected := range test.expected {
+			if got := rateAt(stages, elapsed); got != expected {
+				t.Errorf("%+v: rate at %v = %g, expected %g", test.profile, elapsed, got, expected)
+			}
+		}
+	}
+
+	for _, p := range []LoadProfile{
+		{RampUp: "10s"},
+		{RPS: 10, RampUp: "soon"},
+		{RPS: 10, RampUp: "10s", Steps: 2},
+		{RPS: 10, SpikeAt: "10s"},
+		{Stages: []LoadStage{{Duration: "-1s", RPS: 1}}},
+	} {
+		if _, err := p.stages(total); err == nil {
+			t.Errorf("%+v accepted", p)
+		}
+	}
+}
+
+func TestLoadProfileFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		content  string
+		expected LoadProfile
+	}{
+		{
+			"# ramp\nrps: 200\nrampUp: 1m   # warm up\nrampDown: '30s'\n",
+			LoadProfile{RPS: 200, RampUp: "1m", RampDown: "30s"},
+		},
+		{
+			"stages:\n  - duration: 1m\n    rps: 50\n  - duration: 30s\n    rps: 0\n",
+			LoadProfile{Stages: []LoadStage{{Duration: "1m", RPS: 50}, {Duration: "30s", RPS: 0}}},
+		},
+		{
+			"stages:\n- duration: 10s\n  rps: 5\nsteps: 2\n",
+			LoadProfile{Steps: 2, Stages: []LoadStage{{Duration: "10s", RPS: 5}}},
+		},
+		{
+			`{"rps": 10, "steps": 3}`,
+			LoadProfile{RPS: 10, Steps: 3},
+		},
+	}
+	for i, test := range tests {
+		path := filepath.Join(dir, "profile.yaml")
+		if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		profile, err := LoadProfileFile(path)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(profile, test.expected) {
+			t.Errorf("%d: got %+v, expected %+v", i, profile, test.expected)
+		}
+	}
+
+	for _, content := range []string{"rps 10\n", "  rps: 10\n", "stages:\n  - duration: 1m\n      rps: 5\n"} {
+		path := filepath.Join(dir, "invalid.yaml")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadProfileFile(path); err == nil {
+			t.Errorf("%q accepted", content)
+		}
+	}
+}
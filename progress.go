@@ -0,0 +1,85 @@
+package hargo
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Progress reports how far a long-running operation such as Extract got.
+type Progress struct {
+	// Entries is the number of .har entries processed so far, out of Total.
+	Entries int
+	Total   int
+	// Files and Bytes count the files written and their size.
+	Files int
+	Bytes int64
+	// Errors counts entries or files that could not be processed.
+	Errors  int
+	Elapsed time.Duration
+}
+
+// ProgressFunc receives progress after every processed entry. It is called
+// synchronously from the goroutine performing the work.
+type ProgressFunc func(Progress)
+
+// Done reports whether all entries have been processed.
+func (p Progress) Done() bool {
+	return p.Entries >= p.Total
+}
+
+// ETA estimates the time remaining from the average time per entry so far,
+// or returns 0 if it cannot be estimated yet.
+func (p Progress) ETA() time.Duration {
+	if p.Entries == 0 || p.Done() {
+		return 0
+	}
+	return p.Elapsed / time.Duration(p.Entries) * time.Duration(p.Total-p.Entries)
+}
+
+// progressInterval limits how often ProgressBar redraws.
+const progressInterval = 100 * time.Millisecond
+
+// ProgressBar returns a ProgressFunc rendering a single line progress bar
+// with an ETA to w, typically a terminal's stderr. The line is redrawn in
+// place at most every 100ms and ended with a newline when done.
+func ProgressBar(w io.Writer, width int) ProgressFunc {
+	var last time.Time
+	return func(p Progress) {
+		if !p.Done() && time.Since(last) < progressInterval {
+			return
+		}
+		last = time.Now()
+
+		filled := width
+		if p.Total > 0 {
+			filled = width * p.Entries / p.Total
+		}
+		bar := strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+
+		line := fmt.Sprintf("\r[%s] %d/%d entries, %d files, %s", bar, p.Entries, p.Total, p.Files, formatBytes(p.Bytes))
+		if p.Errors > 0 {
+			line += fmt.Sprintf(", %d errors", p.Errors)
+		}
+		if p.Done() {
+			fmt.Fprintf(w, "%s in %s\n", line, p.Elapsed.Round(time.Millisecond))
+		} else {
+			fmt.Fprintf(w, "%s, ETA %s ", line, p.ETA().Round(time.Second))
+		}
+	}
+}
+
+// formatBytes formats n with a binary unit, e.g. 1.5 MiB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,83 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressETA(t *testing.T) {
+	p := Progress{Entries: 25, Total: 100, Elapsed: 10 * time.Second}
+	if eta := p.ETA(); eta != 30*time.Second {
+		t.Errorf("ETA = %s, expected 30s", eta)
+	}
+	if eta := (Progress{Total: 100}).ETA(); eta != 0 {
+		t.Errorf("ETA without progress = %s, expected 0", eta)
+	}
+	if !(Progress{Entries: 100, Total: 100}).Done() {
+		t.Error("expected progress to be done")
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, expected := range map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1536:            "1.5 KiB",
+		5 * 1024 * 1024: "5.0 MiB",
+	} {
+		if got := formatBytes(n); got != expected {
+			t.Errorf("formatBytes(%d) = %s, expected %s", n, got, expected)
+		}
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	var out bytes.Buffer
+	bar := ProgressBar(&out, 10)
+	bar(Progress{Entries: 5, Total: 10, Files: 4, Bytes: 2048, Errors: 1, Elapsed: time.Second})
+	// throttled
+	bar(Progress{Entries: 6, Total: 10, Elapsed: time.Second})
+	bar(Progress{Entries: 10, Total: 10, Files: 9, Bytes: 4096, Elapsed: 2 * time.Second})
+
+	expected := "\r[=====     ] 5/10 entries, 4 files, 2.0 KiB, 1 errors, ETA 1s " +
+		"\r[==========] 10/10 entries, 9 files, 4.0 KiB in 2s\n"
+	if out.String() != expected {
+		t.Errorf("unexpected output:\n%q\nexpected:\n%q", out.String(), expected)
+	}
+}
+
+func TestExtractProgress(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	harData, _ := json.Marshal(Har{Log: Log{Entries: []Entry{
+		{Request: Request{Method: "GET", URL: "https://example.com/a.txt"},
+			Response: Response{Status: 200, Content: Content{MimeType: "text/plain", Text: "abc"}}},
+		{Request: Request{Method: "GET", URL: "https://example.com/empty"}},
+		{Request: Request{Method: "GET", URL: "https://example.com/bad.png"},
+			Response: Response{Status: 200, Content: Content{MimeType: "image/png", Text: "!", Encoding: "base64"}}},
+		{Request: Request{Method: "GET", URL: "https://example.com/b.txt"},
+			Response: Response{Status: 200, Content: Content{MimeType: "text/plain", Text: "de"}}},
+	}}})
+
+	var reports []Progress
+	opts := ExtractOptions{Progress: func(p Progress) { reports = append(reports, p) }}
+	if err := ExtractWithOptions(bufio.NewReader(strings.NewReader(string(harData))), opts); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	if len(reports) != 4 {
+		t.Fatalf("expected 4 progress reports, got %+v", reports)
+	}
+	for i, p := range reports {
+		if p.Entries != i+1 || p.Total != 4 {
+			t.Errorf("report %d: %+v", i, p)
+		}
+	}
+	if last := reports[3]; last.Files != 2 || last.Bytes != 5 || last.Errors != 1 || !last.Done() {
+		t.Errorf("unexpected final progress %+v", last)
+	}
+}
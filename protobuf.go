@@ -0,0 +1,702 @@
+package hargo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Protocol buffer wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// protoValue is a field of an encoded protocol buffer message. Varint and
+// fixed size values are in num, length-delimited ones in data.
+type protoValue struct {
+	number   int
+	wireType int
+	num      uint64
+	data     []byte
+}
+
+var errProtoTruncated = errors.New("truncated protocol buffer")
+
+// consumeVarint decodes a varint from the start of b and returns it with
+// its length.
+func consumeVarint(b []byte) (uint64, int, error) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * uint(i))
+		if b[i] < 0x80 {
+			return v, i + 1, nil
+		}
+	}
+	return 0, 0, errProtoTruncated
+}
+
+// parseProtoMessage splits an encoded message into its fields, in the
+// order they were encoded. Groups are not supported.
+func parseProtoMessage(b []byte) ([]protoValue, error) {
+	var values []protoValue
+	for len(b) > 0 {
+		tag, n, err := consumeVarint(b)
+		if err != nil {
+			return nil, err
+		}
+		b = b[n:]
+		v := protoValue{number: int(tag >> 3), wireType: int(tag & 7)}
+		if v.number <= 0 || v.number > 1<<29-1 {
+			return nil, fmt.Errorf("invalid field number %d", v.number)
+		}
+		switch v.wireType {
+		case wireVarint:
+			if v.num, n, err = consumeVarint(b); err != nil {
+				return nil, err
+			}
+		case wireFixed64:
+			if len(b) < 8 {
+				return nil, errProtoTruncated
+			}
+			v.num, n = binary.LittleEndian.Uint64(b), 8
+		case wireFixed32:
+			if len(b) < 4 {
+				return nil, errProtoTruncated
+			}
+			v.num, n = uint64(binary.LittleEndian.Uint32(b)), 4
+		case wireBytes:
+			size, m, err := consumeVarint(b)
+			if err != nil {
+				return nil, err
+			}
+			if size > uint64(len(b)-m) {
+				return nil, errProtoTruncated
+			}
+			v.data, n = b[m:m+int(size)], m+int(size)
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d", v.wireType)
+		}
+		b = b[n:]
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// Field types and labels of FieldDescriptorProto.
+const (
+	protoDouble   = 1
+	protoFloat    = 2
+	protoInt64    = 3
+	protoUint64   = 4
+	protoInt32    = 5
+	protoFixed64  = 6
+	protoFixed32  = 7
+	protoBool     = 8
+	protoString   = 9
+	protoGroup    = 10
+	protoMessage  = 11
+	protoBytes    = 12
+	protoUint32   = 13
+	protoEnum     = 14
+	protoSfixed32 = 15
+	protoSfixed64 = 16
+	protoSint32   = 17
+	protoSint64   = 18
+
+	protoRepeated = 3
+)
+
+// ProtoRegistry holds the message, enum and service definitions of a
+// compiled descriptor set, to decode messages to JSON.
+type ProtoRegistry struct {
+	messages map[string]*protoMessageDesc
+	enums    map[string]map[int32]string
+	// methods maps the path of a gRPC method, /package.Service/Method, to
+	// the full names of its input and output messages.
+	methods map[string][2]string
+}
+
+type protoMessageDesc struct {
+	name     string
+	fields   map[int]*protoFieldDesc
+	mapEntry bool
+}
+
+type protoFieldDesc struct {
+	name     string
+	jsonName string
+	number   int
+	label    int
+	typ      int
+	typeName string
+}
+
+// LoadProtoDescriptors reads a FileDescriptorSet, as written by
+// protoc --descriptor_set_out=api.pb --include_imports api.proto.
+func LoadProtoDescriptors(path string) (*ProtoRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	reg, err := ParseProtoDescriptors(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set %s: %v", path, err)
+	}
+	return reg, nil
+}
+
+// ParseProtoDescriptors parses an encoded FileDescriptorSet.
+func ParseProtoDescriptors(data []byte) (*ProtoRegistry, error) {
+	reg := &ProtoRegistry{
+		messages: make(map[string]*protoMessageDesc),
+		enums:    make(map[string]map[int32]string),
+		methods:  make(map[string][2]string),
+	}
+	files, err := parseProtoMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.number != 1 || file.wireType != wireBytes {
+			continue
+		}
+		if err := reg.addFile(file.data); err != nil {
+			return nil, err
+		}
+	}
+	return reg, nil
+}
+
+// addFile adds the definitions of a FileDescriptorProto.
+func (reg *ProtoRegistry) addFile(data []byte) error {
+	fields, err := parseProtoMessage(data)
+	if err != nil {
+		return err
+	}
+	var pkg string
+	for _, f := range fields {
+		if f.number == 2 && f.wireType == wireBytes {
+			pkg = string(f.data)
+		}
+	}
+	for _, f := range fields {
+		if f.wireType != wireBytes {
+			continue
+		}
+		switch f.number {
+		case 4:
+			err = reg.addMessage(pkg, f.data)
+		case 5:
+			err = reg.addEnum(pkg, f.data)
+		case 6:
+			err = reg.addService(pkg, f.data)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// qualify joins a scope and a name to a full name without leading dot.
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// addMessage adds a DescriptorProto and its nested types within scope.
+func (reg *ProtoRegistry) addMessage(scope string, data []byte) error {
+	fields, err := parseProtoMessage(data)
+	if err != nil {
+		return err
+	}
+	msg := &protoMessageDesc{fields: make(map[int]*protoFieldDesc)}
+	for _, f := range fields {
+		if f.number == 1 && f.wireType == wireBytes {
+			msg.name = qualify(scope, string(f.data))
+		}
+	}
+	reg.messages[msg.name] = msg
+
+	for _, f := range fields {
+		if f.wireType != wireBytes {
+			continue
+		}
+		switch f.number {
+		case 2:
+			field, err := parseProtoField(f.data)
+			if err != nil {
+				return err
+			}
+			msg.fields[field.number] = field
+		case 3:
+			err = reg.addMessage(msg.name, f.data)
+		case 4:
+			err = reg.addEnum(msg.name, f.data)
+		case 7:
+			// MessageOptions.map_entry
+			options, err := parseProtoMessage(f.data)
+			if err != nil {
+				return err
+			}
+			for _, o := range options {
+				if o.number == 7 && o.wireType == wireVarint {
+					msg.mapEntry = o.num != 0
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseProtoField parses a FieldDescriptorProto.
+func parseProtoField(data []byte) (*protoFieldDesc, error) {
+	fields, err := parseProtoMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	field := &protoFieldDesc{}
+	for _, f := range fields {
+		switch {
+		case f.number == 1 && f.wireType == wireBytes:
+			field.name = string(f.data)
+		case f.number == 3 && f.wireType == wireVarint:
+			field.number = int(f.num)
+		case f.number == 4 && f.wireType == wireVarint:
+			field.label = int(f.num)
+		case f.number == 5 && f.wireType == wireVarint:
+			field.typ = int(f.num)
+		case f.number == 6 && f.wireType == wireBytes:
+			field.typeName = strings.TrimPrefix(string(f.data), ".")
+		case f.number == 10 && f.wireType == wireBytes:
+			field.jsonName = string(f.data)
+		}
+	}
+	if field.jsonName == "" {
+		field.jsonName = protoJSONName(field.name)
+	}
+	return field, nil
+}
+
+// protoJSONName converts a field name to lowerCamelCase as protoc does.
+func protoJSONName(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// addEnum adds an EnumDescriptorProto within scope.
+func (reg *ProtoRegistry) addEnum(scope string, data []byte) error {
+	fields, err := parseProtoMessage(data)
+	if err != nil {
+		return err
+	}
+	var name string
+	values := make(map[int32]string)
+	for _, f := range fields {
+		if f.wireType != wireBytes {
+			continue
+		}
+		switch f.number {
+		case 1:
+			name = string(f.data)
+		case 2:
+			value, err := parseProtoMessage(f.data)
+			if err != nil {
+				return err
+			}
+			var valueName string
+			var number int32
+			for _, v := range value {
+				switch {
+				case v.number == 1 && v.wireType == wireBytes:
+					valueName = string(v.data)
+				case v.number == 2 && v.wireType == wireVarint:
+					number = int32(v.num)
+				}
+			}
+			if _, ok := values[number]; !ok {
+				values[number] = valueName
+			}
+		}
+	}
+	reg.enums[qualify(scope, name)] = values
+	return nil
+}
+
+// addService adds the methods of a ServiceDescriptorProto of package pkg.
+func (reg *ProtoRegistry) addService(pkg string, data []byte) error {
+	fields, err := parseProtoMessage(data)
+	if err != nil {
+		return err
+	}
+	var service string
+	var methods [][]protoValue
+	for _, f := range fields {
+		if f.wireType != wireBytes {
+			continue
+		}
+		switch f.number {
+		case 1:
+			service = qualify(pkg, string(f.data))
+		case 2:
+			method, err := parseProtoMessage(f.data)
+			if err != nil {
+				return err
+			}
+			methods = append(methods, method)
+		}
+	}
+	for _, method := range methods {
+		var name string
+		var types [2]string
+		for _, m := range method {
+			if m.wireType != wireBytes {
+				continue
+			}
+			switch m.number {
+			case 1:
+				name = string(m.data)
+			case 2:
+				types[0] = strings.TrimPrefix(string(m.data), ".")
+			case 3:
+				types[1] = strings.TrimPrefix(string(m.data), ".")
+			}
+		}
+		reg.methods["/"+service+"/"+name] = types
+	}
+	return nil
+}
+
+// Method returns the full names of the input and output messages of the
+// gRPC method at path, e.g. /helloworld.Greeter/SayHello.
+func (reg *ProtoRegistry) Method(path string) (input, output string, ok bool) {
+	if reg == nil {
+		return "", "", false
+	}
+	types, ok := reg.methods[path]
+	return types[0], types[1], ok
+}
+
+// DecodeJSON decodes an encoded message of the named type to JSON, using
+// the proto3 JSON mapping: lowerCamelCase field names, 64-bit integers as
+// strings, bytes as base64 and enums by name. Unknown fields are keyed by
+// their number.
+func (reg *ProtoRegistry) DecodeJSON(messageType string, data []byte) (json.RawMessage, error) {
+	msg, ok := reg.messages[strings.TrimPrefix(messageType, ".")]
+	if !ok {
+		return nil, fmt.Errorf("unknown message type %s", messageType)
+	}
+	v, err := reg.decodeMessage(msg, data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// orderedObject is a JSON object keeping the order of its keys.
+type orderedObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newOrderedObject() *orderedObject {
+	return &orderedObject{values: make(map[string]interface{})}
+}
+
+// set sets key to v, or appends v to the array at key if repeated.
+func (o *orderedObject) set(key string, v interface{}, repeated bool) {
+	old, exists := o.values[key]
+	if !exists {
+		o.keys = append(o.keys, key)
+	}
+	if !repeated {
+		o.values[key] = v
+		return
+	}
+	list, _ := old.([]interface{})
+	o.values[key] = append(list, v)
+}
+
+// MarshalJSON encodes the object with its keys in order.
+func (o *orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := marshalUnescaped(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := marshalUnescaped(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// decodeMessage decodes data as msg, with the fields ordered by number.
+func (reg *ProtoRegistry) decodeMessage(msg *protoMessageDesc, data []byte) (*orderedObject, error) {
+	values, err := parseProtoMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(values, func(i, j int) bool { return values[i].number < values[j].number })
+
+	obj := newOrderedObject()
+	for _, v := range values {
+		field, ok := msg.fields[v.number]
+		if !ok {
+			obj.set(strconv.Itoa(v.number), schemalessValue(v), true)
+			continue
+		}
+		if entry, ok := reg.messages[field.typeName]; ok && entry.mapEntry && field.label == protoRepeated {
+			if err := reg.decodeMapEntry(obj, field, entry, v); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		repeated := field.label == protoRepeated
+		// repeated scalars are packed in a single length-delimited field
+		if v.wireType == wireBytes && isPackable(field.typ) {
+			packed, err := unpackProtoValues(field.typ, v.data)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %v", field.name, err)
+			}
+			for _, p := range packed {
+				obj.set(field.jsonName, reg.scalarValue(field, p), repeated)
+			}
+			continue
+		}
+		value, err := reg.fieldValue(field, v)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", field.name, err)
+		}
+		obj.set(field.jsonName, value, repeated)
+	}
+	return obj, nil
+}
+
+// decodeMapEntry adds an entry of a map field to obj.
+func (reg *ProtoRegistry) decodeMapEntry(obj *orderedObject, field *protoFieldDesc, entry *protoMessageDesc, v protoValue) error {
+	decoded, err := reg.decodeMessage(entry, v.data)
+	if err != nil {
+		return fmt.Errorf("field %s: %v", field.name, err)
+	}
+	m, ok := obj.values[field.jsonName].(*orderedObject)
+	if !ok {
+		m = newOrderedObject()
+		obj.set(field.jsonName, m, false)
+	}
+	key := ""
+	if k, ok := entry.fields[1]; ok {
+		switch kv := decoded.values[k.jsonName].(type) {
+		case string:
+			key = kv
+		case nil:
+		default:
+			b, _ := json.Marshal(kv)
+			key = string(b)
+		}
+	}
+	var value interface{}
+	if vf, ok := entry.fields[2]; ok {
+		value = decoded.values[vf.jsonName]
+	}
+	m.set(key, value, false)
+	return nil
+}
+
+// fieldValue converts a field that is not packed to its JSON value.
+func (reg *ProtoRegistry) fieldValue(field *protoFieldDesc, v protoValue) (interface{}, error) {
+	switch field.typ {
+	case protoMessage:
+		msg, ok := reg.messages[field.typeName]
+		if !ok {
+			return schemalessValue(v), nil
+		}
+		return reg.decodeMessage(msg, v.data)
+	case protoString:
+		return string(v.data), nil
+	case protoBytes:
+		return base64.StdEncoding.EncodeToString(v.data), nil
+	case protoGroup:
+		return nil, fmt.Errorf("groups are not supported")
+	}
+	if v.wireType == wireBytes {
+		return schemalessValue(v), nil
+	}
+	return reg.scalarValue(field, v.num), nil
+}
+
+// isPackable reports whether fields of type typ can be packed.
+func isPackable(typ int) bool {
+	switch typ {
+	case protoString, protoBytes, protoMessage, protoGroup:
+		return false
+	}
+	return true
+}
+
+// unpackProtoValues splits a packed field of type typ into its values.
+func unpackProtoValues(typ int, data []byte) ([]uint64, error) {
+	var values []uint64
+	for len(data) > 0 {
+		switch typ {
+		case protoDouble, protoFixed64, protoSfixed64:
+			if len(data) < 8 {
+				return nil, errProtoTruncated
+			}
+			values = append(values, binary.LittleEndian.Uint64(data))
+			data = data[8:]
+		case protoFloat, protoFixed32, protoSfixed32:
+			if len(data) < 4 {
+				return nil, errProtoTruncated
+			}
+			values = append(values, uint64(binary.LittleEndian.Uint32(data)))
+			data = data[4:]
+		default:
+			v, n, err := consumeVarint(data)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+			data = data[n:]
+		}
+	}
+	return values, nil
+}
+
+// scalarValue converts the bits of a numeric, bool or enum field to its
+// JSON value.
+func (reg *ProtoRegistry) scalarValue(field *protoFieldDesc, n uint64) interface{} {
+	switch field.typ {
+	case protoDouble:
+		return protoFloatValue(math.Float64frombits(n))
+	case protoFloat:
+		return protoFloatValue(float64(math.Float32frombits(uint32(n))))
+	case protoInt64, protoSfixed64:
+		return strconv.FormatInt(int64(n), 10)
+	case protoUint64, protoFixed64:
+		return strconv.FormatUint(n, 10)
+	case protoSint64:
+		return strconv.FormatInt(int64(n>>1)^-int64(n&1), 10)
+	case protoInt32, protoSfixed32:
+		return int32(n)
+	case protoUint32, protoFixed32:
+		return uint32(n)
+	case protoSint32:
+		return int32(uint32(n)>>1) ^ -int32(n&1)
+	case protoBool:
+		return n != 0
+	case protoEnum:
+		if name, ok := reg.enums[field.typeName][int32(n)]; ok {
+			return name
+		}
+		return int32(n)
+	}
+	return n
+}
+
+// protoFloatValue returns f, or its name if JSON has no number for it.
+func protoFloatValue(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return f
+}
+
+// DecodeProtoSchemaless decodes a message without its definition to JSON
+// keyed by field number. Varints and fixed size values are unsigned
+// integers, length-delimited fields printable strings, nested messages or
+// else base64 bytes; the encoding cannot tell them apart reliably.
+func DecodeProtoSchemaless(data []byte) (json.RawMessage, error) {
+	values, err := parseProtoMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(schemalessObject(values))
+}
+
+// schemalessObject converts the fields of a message of unknown type.
+func schemalessObject(values []protoValue) *orderedObject {
+	obj := newOrderedObject()
+	for _, v := range values {
+		obj.set(strconv.Itoa(v.number), schemalessValue(v), true)
+	}
+	// fields occurring once are not arrays
+	for key, value := range obj.values {
+		if list := value.([]interface{}); len(list) == 1 {
+			obj.values[key] = list[0]
+		}
+	}
+	return obj
+}
+
+// schemalessValue converts a field of a message of unknown type.
+func schemalessValue(v protoValue) interface{} {
+	switch v.wireType {
+	case wireBytes:
+		if isPrintable(v.data) {
+			return string(v.data)
+		}
+		if nested, err := parseProtoMessage(v.data); err == nil && len(nested) > 0 {
+			return schemalessObject(nested)
+		}
+		return base64.StdEncoding.EncodeToString(v.data)
+	}
+	return v.num
+}
+
+// isPrintable reports whether data is UTF-8 text without control
+// characters other than whitespace.
+func isPrintable(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
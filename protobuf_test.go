@@ -0,0 +1,168 @@
+package hargo
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// pbVarint encodes a varint field.
+func pbVarint(number int, v uint64) []byte {
+	b := binary.AppendUvarint(nil, uint64(number)<<3|wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+// pbBytes encodes a length-delimited field.
+func pbBytes(number int, data []byte) []byte {
+	b := binary.AppendUvarint(nil, uint64(number)<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func pbString(number int, s string) []byte {
+	return pbBytes(number, []byte(s))
+}
+
+func pbJoin(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// pbField encodes a FieldDescriptorProto.
+func pbField(name string, number, label, typ int, typeName string) []byte {
+	b := pbJoin(pbString(1, name), pbVarint(3, uint64(number)), pbVarint(4, uint64(label)), pbVarint(5, uint64(typ)))
+	if typeName != "" {
+		b = append(b, pbString(6, typeName)...)
+	}
+	return b
+}
+
+// testDescriptorSet encodes the descriptor set of
+//
+//	package demo;
+//	enum Kind { UNKNOWN = 0; FAST = 1; }
+//	message HelloRequest {
+//	  string user_name = 1;
+//	  repeated int32 ids = 2;
+//	  Kind kind = 3;
+//	  map<string, int64> counts = 4;
+//	}
+//	message HelloReply { string message = 1; sint32 delta = 2; }
+//	service Greeter { rpc SayHello(HelloRequest) returns (HelloReply); }
+func testDescriptorSet() []byte {
+	countsEntry := pbJoin(
+		pbString(1, "CountsEntry"),
+		pbBytes(2, pbField("key", 1, 1, protoString, "")),
+		pbBytes(2, pbField("value", 2, 1, protoInt64, "")),
+		pbBytes(7, pbVarint(7, 1)))
+	request := pbJoin(
+		pbString(1, "HelloRequest"),
+		pbBytes(2, pbField("user_name", 1, 1, protoString, "")),
+		pbBytes(2, pbField("ids", 2, protoRepeated, protoInt32, "")),
+		pbBytes(2, pbField("kind", 3, 1, protoEnum, ".demo.Kind")),
+		pbBytes(2, pbField("counts", 4, protoRepeated, protoMessage, ".demo.HelloRequest.CountsEntry")),
+		pbBytes(3, countsEntry))
+	reply := pbJoin(
+		pbString(1, "HelloReply"),
+		pbBytes(2, pbField("message", 1, 1, protoString, "")),
+		pbBytes(2, pbField("delta", 2, 1, protoSint32, "")))
+	kind := pbJoin(
+		pbString(1, "Kind"),
+		pbBytes(2, pbJoin(pbString(1, "UNKNOWN"), pbVarint(2, 0))),
+		pbBytes(2, pbJoin(pbString(1, "FAST"), pbVarint(2, 1))))
+	service := pbJoin(
+		pbString(1, "Greeter"),
+		pbBytes(2, pbJoin(pbString(1, "SayHello"), pbString(2, ".demo.HelloRequest"), pbString(3, ".demo.HelloReply"))))
+	file := pbJoin(
+		pbString(1, "demo.proto"),
+		pbString(2, "demo"),
+		pbBytes(4, request),
+		pbBytes(4, reply),
+		pbBytes(5, kind),
+		pbBytes(6, service))
+	return pbBytes(1, file)
+}
+
+func TestProtoRegistry(t *testing.T) {
+	reg, err := ParseProtoDescriptors(testDescriptorSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	input, output, ok := reg.Method("/demo.Greeter/SayHello")
+	if !ok || input != "demo.HelloRequest" || output != "demo.HelloReply" {
+		t.Errorf("got method %s %s %v", input, output, ok)
+	}
+	if _, _, ok := reg.Method("/demo.Greeter/Missing"); ok {
+		t.Error("expected an unknown method")
+	}
+
+	tests := []struct {
+		messageType string
+		data        []byte
+		expected    string
+	}{
+		{"demo.HelloRequest", pbJoin(
+			pbString(1, "ann"),
+			pbBytes(2, []byte{1, 2, 0x96, 0x01}),
+			pbVarint(3, 1),
+			pbBytes(4, pbJoin(pbString(1, "a"), pbVarint(2, 7))),
+			pbBytes(4, pbJoin(pbString(1, "b"), pbVarint(2, 1<<40))),
+			pbVarint(9, 5)),
+			`{"userName":"ann","ids":[1,2,150],"kind":"FAST","counts":{"a":"7","b":"1099511627776"},"9":[5]}`},
+		// fields are ordered by number, unknown enum values are numbers
+		{".demo.HelloRequest", pbJoin(pbVarint(3, 4), pbVarint(2, 3), pbVarint(2, 4)), `{"ids":[3,4],"kind":4}`},
+		{"demo.HelloReply", pbJoin(pbString(1, "hi"), pbVarint(2, 3)), `{"message":"hi","delta":-2}`},
+		{"demo.HelloReply", nil, `{}`},
+	}
+
+	for i, test := range tests {
+		got, err := reg.DecodeJSON(test.messageType, test.data)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if string(got) != test.expected {
+			t.Errorf("%d: got %s, want %s", i, got, test.expected)
+		}
+	}
+
+	if _, err := reg.DecodeJSON("demo.Missing", nil); err == nil {
+		t.Error("expected an error for an unknown message type")
+	}
+	if _, err := reg.DecodeJSON("demo.HelloReply", []byte{0x0a, 5, 'h'}); err == nil {
+		t.Error("expected an error for a truncated message")
+	}
+}
+
+func TestDecodeProtoSchemaless(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		expected string
+	}{
+		{pbJoin(pbString(1, "hello"), pbVarint(2, 300)), `{"1":"hello","2":300}`},
+		{pbJoin(pbVarint(1, 1), pbVarint(1, 2)), `{"1":[1,2]}`},
+		{pbBytes(3, pbJoin(pbVarint(1, 7), pbString(2, "x"))), `{"3":{"1":7,"2":"x"}}`},
+		{pbBytes(1, []byte{0xff, 0x00}), `{"1":"/wA="}`},
+		{[]byte{0x0d, 1, 0, 0, 0}, `{"1":1}`},
+		{nil, `{}`},
+	}
+
+	for i, test := range tests {
+		got, err := DecodeProtoSchemaless(test.data)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if string(got) != test.expected {
+			t.Errorf("%d: got %s, want %s", i, got, test.expected)
+		}
+	}
+
+	for i, data := range [][]byte{{0x08}, {0x0a, 3, 'a'}, {0x00, 1}, {0x0b}} {
+		if _, err := DecodeProtoSchemaless(data); err == nil {
+			t.Errorf("%d: expected an error for %x", i, data)
+		}
+	}
+}
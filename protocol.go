@@ -0,0 +1,31 @@
+package hargo
+
+import "strings"
+
+// normalizeProtocol maps the protocol and httpVersion names used by
+// browsers and proxies to ALPN names: http/1.0, http/1.1, h2 and h3.
+// Unknown names are lowercased, empty ones stay empty.
+func normalizeProtocol(name string) string {
+	p := strings.ToLower(strings.TrimSpace(name))
+	switch {
+	case p == "":
+		return ""
+	case p == "h2" || p == "h2c" || strings.HasPrefix(p, "http/2"):
+		return "h2"
+	case p == "h3" || strings.HasPrefix(p, "h3-") || strings.HasPrefix(p, "http/3") || p == "quic":
+		return "h3"
+	}
+	return p
+}
+
+// NegotiatedProtocol returns the application protocol of the entry as an
+// ALPN name (http/1.1, h2, h3...), taken from its _protocol field or else
+// from the httpVersion of its response or request, or "unknown".
+func (e Entry) NegotiatedProtocol() string {
+	for _, name := range []string{e.Protocol, e.Response.HTTPVersion, e.Request.HTTPVersion} {
+		if p := normalizeProtocol(name); p != "" {
+			return p
+		}
+	}
+	return "unknown"
+}
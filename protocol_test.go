@@ -0,0 +1,60 @@
+package hargo
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestNegotiatedProtocol(t *testing.T) {
+	tests := []struct {
+		entry    Entry
+		expected string
+	}{
+		{Entry{Protocol: "h3-29", Response: Response{HTTPVersion: "HTTP/1.1"}}, "h3"},
+		{Entry{Response: Response{HTTPVersion: "HTTP/2.0"}}, "h2"},
+		{Entry{Response: Response{HTTPVersion: "h2"}}, "h2"},
+		{Entry{Response: Response{HTTPVersion: "http/3"}}, "h3"},
+		{Entry{Request: Request{HTTPVersion: "HTTP/1.0"}}, "http/1.0"},
+		{Entry{Protocol: "QUIC"}, "h3"},
+		{Entry{}, "unknown"},
+	}
+	for _, test := range tests {
+		if got := test.entry.NegotiatedProtocol(); got != test.expected {
+			t.Errorf("%+v: got %s, expected %s", test.entry, got, test.expected)
+		}
+	}
+}
+
+func TestProtocolStats(t *testing.T) {
+	input := `{"log":{"version":"1.2","creator":{"name":"test"},"entries":[
+		{"_protocol":"h2","_http2_stream_id":3,"request":{"method":"GET","url":"https://a.example/","httpVersion":"h2","headersSize":40,"_headersUncompressedSize":120,"bodySize":0},"response":{"status":200,"httpVersion":"h2","headersSize":30,"bodySize":100,"content":{"size":100}}},
+		{"_protocol":"h2","request":{"method":"GET","url":"https://a.example/b","headersSize":10,"bodySize":0},"response":{"status":200,"headersSize":10,"bodySize":50,"content":{"size":50}}},
+		{"request":{"method":"GET","url":"http://b.example/","httpVersion":"HTTP/1.1","headersSize":20,"bodySize":0},"response":{"status":200,"httpVersion":"HTTP/1.1","headersSize":20,"bodySize":10,"content":{"size":10}}}
+	]}}`
+
+	har, err := Decode(bufio.NewReader(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := har.Log.Entries[0]
+	if entry.StreamID != 3 || entry.Request.HeadersUncompressedSize != 120 || len(entry.Extras) != 0 {
+		t.Errorf("protocol metadata not decoded: %+v", entry)
+	}
+
+	stats := ComputeStats(har)
+	if c := stats.ByProtocol["h2"]; c == nil || c.Requests != 2 || c.Sent != 50 || c.Received != 190 {
+		t.Errorf("h2 = %+v", c)
+	}
+	if c := stats.ByProtocol["http/1.1"]; c == nil || c.Requests != 1 {
+		t.Errorf("http/1.1 = %+v", c)
+	}
+
+	var out strings.Builder
+	if err := Stats(bufio.NewReader(strings.NewReader(input)), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Protocol") || !strings.Contains(out.String(), "h2 ") {
+		t.Errorf("stats output lacks the protocol table:\n%s", out.String())
+	}
+}
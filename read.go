@@ -10,9 +10,16 @@ import (
 
 // ReadStream reads the har file as a stream and puts the entries
 // on a chan for consumption. When the end of a file is reached it
-// will start over until the stop signal is given.
+// will start over until the stop signal is given. WebSocket connections
+// are not read.
 // https://golang.org/pkg/encoding/json/#example_Decoder_Decode_stream
 func ReadStream(file *os.File, entries chan Entry, stop chan bool) {
+	ReadStreamWithSelection(file, Selection{}, entries, stop)
+}
+
+// ReadStreamWithSelection is ReadStream reading only the entries selected
+// by selection.
+func ReadStreamWithSelection(file *os.File, selection Selection, entries chan Entry, stop chan bool) {
 	for {
 		r := NewReader(file)
 
@@ -41,13 +48,13 @@ func ReadStream(file *os.File, entries chan Entry, stop chan bool) {
 		}
 
 		// read entries
-		for decoder.More() {
+		for i := 0; decoder.More(); i++ {
 			var e Entry
 			err := decoder.Decode(&e)
 			if err != nil {
 				log.Fatal(err)
 			}
-			if len(e.Request.URL) > 0 {
+			if len(e.Request.URL) > 0 && !e.IsWebSocket() && selection.Includes(i, e) {
 				entries <- e
 			}
 
@@ -0,0 +1,288 @@
+package hargo
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// hopHeaders are the headers of a single connection, which a proxy must not
+// forward.
+var hopHeaders = []string{
+	"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate",
+	"Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// Recorder is an http.Handler that passes requests through to their origin
+// and appends each exchange to a Journal.
+//
+// With a Target it acts as a reverse proxy for that origin. Otherwise it is
+// a forward proxy for plain HTTP; HTTPS requests sent through CONNECT are
+// tunnelled without being recorded.
+type Recorder struct {
+	// Target is the origin requests are sent to, e.g.
+	// https://api.example.com. If nil, requests must have absolute URLs.
+	Target *url.URL
+	// Journal receives the recorded entries.
+	Journal *Journal
+	// Transport sends the requests, http.DefaultTransport if nil.
+	Transport http.RoundTripper
+	// Bodies limits the request and response bodies stored.
+	Bodies BodyPolicy
+}
+
+// ServeHTTP forwards r and records the exchange.
+func (rec *Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		rec.tunnel(w, r)
+		return
+	}
+
+	out, body, err := rec.outgoing(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	transport := rec.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	entry, respBody, resp, err := captureEntry(client, out)
+	if err != nil {
+		log.Errorf("%s %s -> %v", r.Method, out.URL, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	// record the request as the client sent it, apart from its target
+	recorded := r.Clone(r.Context())
+	recorded.URL = out.URL
+	entry.Request = NewRequest(recorded, body)
+	rec.Bodies.applyRequest(&entry)
+	rec.Bodies.applyResponse(&entry, respBody)
+	recordProtocol(&entry, resp)
+	if err := rec.Journal.Append(entry); err != nil {
+		log.Errorf("Cannot record %s: %v", out.URL, err)
+	}
+	log.Infof("%s %s -> %d", r.Method, out.URL, resp.StatusCode)
+	emit(Event{Type: EventEntryFetched, Phase: "record", URL: out.URL.String(), Method: r.Method,
+		Status: resp.StatusCode, Bytes: int64(len(respBody))})
+
+	for name, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(name, v)
+		}
+	}
+	removeHopHeaders(w.Header())
+	// the body has been read in full, and decoded if the transport did so
+	w.Header().Set("Content-Length", strconv.Itoa(len(respBody)))
+	w.WriteHeader(resp.StatusCode)
+	w.Write(respBody)
+}
+
+// recordProtocol sets the negotiated protocol of entry and its header sizes:
+// for HTTP/1.x as sent, for HTTP/2 the uncompressed size, as the transport
+// does not expose the size of the HPACK encoded headers, nor stream IDs.
+func recordProtocol(entry *Entry, resp *http.Response) {
+	entry.Protocol = normalizeProtocol(resp.Proto)
+	if resp.TLS != nil && resp.TLS.NegotiatedProtocol != "" {
+		entry.Protocol = normalizeProtocol(resp.TLS.NegotiatedProtocol)
+	}
+	entry.Request.HTTPVersion = resp.Proto
+
+	reqSize, respSize := int(requestHeaderBytes(entry.Request)), int(responseHeaderBytes(entry.Response))
+	if isHTTP1(resp.Proto) {
+		entry.Request.HeaderSize, entry.Response.HeadersSize = reqSize, respSize
+	} else {
+		entry.Request.HeadersUncompressedSize, entry.Response.HeadersUncompressedSize = reqSize, respSize
+	}
+}
+
+// outgoing returns the request to send to the origin for r, and the body of
+// r.
+func (rec *Recorder) outgoing(r *http.Request) (*http.Request, []byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target := *r.URL
+	if rec.Target != nil {
+		target.Scheme = rec.Target.Scheme
+		target.Host = rec.Target.Host
+		target.Path = rec.Target.Path + r.URL.Path
+		if r.URL.RawPath != "" {
+			target.RawPath = rec.Target.Path + r.URL.RawPath
+		}
+	} else if !r.URL.IsAbs() {
+		return nil, nil, fmt.Errorf("not a proxy request: %s", r.URL)
+	}
+
+	out, err := http.NewRequestWithContext(r.Context(), r.Method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	out.Header = r.Header.Clone()
+	removeHopHeaders(out.Header)
+	// let the transport negotiate compression and decode the body, so it
+	// is recorded readable
+	out.Header.Del("Accept-Encoding")
+	if len(body) == 0 {
+		out.Body = http.NoBody
+		out.GetBody = nil
+	}
+	return out, body, nil
+}
+
+// tunnel connects the client to the host of a CONNECT request.
+func (rec *Recorder) tunnel(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "tunnelling not supported", http.StatusInternalServerError)
+		return
+	}
+	upstream, err := net.DialTimeout("tcp", r.Host, 30*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	log.Infof("CONNECT %s -> tunnelled, not recorded", r.Host)
+	conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+
+	go func() {
+		// bytes the client sent after the CONNECT request are buffered
+		buf.Reader.WriteTo(upstream)
+		io.Copy(upstream, conn)
+		upstream.Close()
+	}()
+	io.Copy(conn, upstream)
+	conn.Close()
+}
+
+// removeHopHeaders removes the headers of a single connection from h,
+// including those listed in its Connection header.
+func removeHopHeaders(h http.Header) {
+	for _, v := range h.Values("Connection") {
+		for _, name := range splitHeaderList(v) {
+			h.Del(name)
+		}
+	}
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+}
+
+// splitHeaderList splits a comma-separated header value.
+func splitHeaderList(v string) []string {
+	var names []string
+	for _, name := range strings.Split(v, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// RecordOptions controls the proxy started by Record.
+type RecordOptions struct {
+	// Target is the origin of a reverse proxy; a forward proxy if empty.
+	Target string
+	// Rotation sets when the capture is split into several .har files.
+	Rotation JournalOptions
+	// Bodies limits the request and response bodies stored.
+	Bodies BodyPolicy
+	// InsecureSkipVerify skips TLS certificate verification of the origin.
+	InsecureSkipVerify bool
+	// MetricsAddr exposes Prometheus metrics of the proxied requests if set.
+	MetricsAddr string
+}
+
+// Record runs a recording proxy on addr until ctx is done, writing the
+// captured entries to harPath through a Journal.
+func Record(ctx context.Context, addr string, harPath string, opts RecordOptions) error {
+	if err := requireNetwork("record"); err != nil {
+		return err
+	}
+
+	rec := &Recorder{
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify},
+			ForceAttemptHTTP2: true,
+		},
+		Bodies: opts.Bodies,
+	}
+	if opts.Target != "" {
+		u, err := url.Parse(opts.Target)
+		if err != nil {
+			return err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("target %q must be an absolute URL", opts.Target)
+		}
+		rec.Target = u
+	}
+
+	journal, err := OpenJournal(harPath, opts.Rotation)
+	if err != nil {
+		return err
+	}
+	rec.Journal = journal
+
+	var handler http.Handler = rec
+	if opts.MetricsAddr != "" {
+		metrics := NewMetrics("record")
+		handler = metrics.Instrument(rec)
+		metricsCtx, stopMetrics := context.WithCancel(ctx)
+		defer stopMetrics()
+		if err := ServeMetrics(metricsCtx, opts.MetricsAddr, metrics); err != nil {
+			journal.Close()
+			return err
+		}
+	}
+
+	srv := &http.Server{Addr: addr, Handler: handler}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+
+	emitPhase(EventPhaseStarted, "record")
+	defer emitPhase(EventPhaseFinished, "record")
+	log.Infof("Recording on %s to %s", addr, harPath)
+
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = srv.Shutdown(shutdown)
+		cancel()
+	}
+
+	if closeErr := journal.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
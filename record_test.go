@@ -0,0 +1,189 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readHarFile(t *testing.T, path string) Har {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	har, err := Decode(bufio.NewReader(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return har
+}
+
+func TestRecorder(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Connection", "X-Hop")
+		w.Header().Set("X-Hop", "1")
+		io.WriteString(w, r.Method+" "+r.URL.RequestURI()+" "+string(b))
+	}))
+	defer origin.Close()
+
+	harPath := filepath.Join(t.TempDir(), "capture.har")
+	journal, err := OpenJournal(harPath, JournalOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, _ := url.Parse(origin.URL + "/api")
+	proxy := httptest.NewServer(&Recorder{Target: target, Journal: journal})
+	defer proxy.Close()
+
+	resp, err := http.Post(proxy.URL+"/items?id=1", "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "POST /api/items?id=1 hello" {
+		t.Errorf("proxied body = %q", body)
+	}
+	if resp.Header.Get("X-Hop") != "" {
+		t.Error("hop-by-hop header forwarded")
+	}
+
+	// the entry is on disk before the capture is closed
+	data, err := os.ReadFile(JournalPath(harPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(data, []byte("\n")); n != 1 {
+		t.Errorf("journal has %d lines, expected 1", n)
+	}
+
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(JournalPath(harPath)); !os.IsNotExist(err) {
+		t.Error("journal not removed on close")
+	}
+
+	har := readHarFile(t, harPath)
+	if len(har.Log.Entries) != 1 {
+		t.Fatalf("recorded %d entries", len(har.Log.Entries))
+	}
+	entry := har.Log.Entries[0]
+	if entry.Request.URL != origin.URL+"/api/items?id=1" || entry.Request.PostData.Text != "hello" {
+		t.Errorf("recorded request %s %q", entry.Request.URL, entry.Request.PostData.Text)
+	}
+	if entry.Response.Status != 200 || entry.Response.Content.Text != string(body) {
+		t.Errorf("recorded response %d %q", entry.Response.Status, entry.Response.Content.Text)
+	}
+	if entry.Protocol != "http/1.1" || entry.Request.HeaderSize <= 0 || entry.Response.HeadersSize <= 0 {
+		t.Errorf("recorded protocol %q, header sizes %d and %d", entry.Protocol, entry.Request.HeaderSize, entry.Response.HeadersSize)
+	}
+}
+
+func TestJournalRotation(t *testing.T) {
+	dir := t.TempDir()
+	harPath := filepath.Join(dir, "capture.har")
+
+	journal, err := OpenJournal(harPath, JournalOptions{MaxSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []string{"https://example.com/1", "https://example.com/2"} {
+		if err := journal.Append(Entry{Request: Request{Method: "GET", URL: u}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expected := range map[string]string{"capture.har": "https://example.com/1", "capture-1.har": "https://example.com/2"} {
+		har := readHarFile(t, filepath.Join(dir, path))
+		if len(har.Log.Entries) != 1 || har.Log.Entries[0].Request.URL != expected {
+			t.Errorf("%s: unexpected entries %+v", path, har.Log.Entries)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "capture-2.har")); !os.IsNotExist(err) {
+		t.Error("empty journal promoted on close")
+	}
+}
+
+func TestRecoverJournal(t *testing.T) {
+	harPath := filepath.Join(t.TempDir(), "capture.har")
+
+	// a crash left a complete entry and part of the next one
+	torn := `{"request":{"method":"GET","url":"https://example.com/1"},"response":{"status":200}}` + "\n" +
+		`{"request":{"method":"GET","url":"https://exa`
+	if err := os.WriteFile(JournalPath(harPath), []byte(torn), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	journal, err := OpenJournal(harPath, JournalOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	har := readHarFile(t, harPath)
+	if len(har.Log.Entries) != 1 || har.Log.Entries[0].Request.URL != "https://example.com/1" {
+		t.Errorf("recovered entries %+v", har.Log.Entries)
+	}
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if path, n, err := RecoverJournal(harPath); path != "" || n != 0 || err != nil {
+		t.Errorf("RecoverJournal without journal = %q, %d, %v", path, n, err)
+	}
+}
+
+func TestBodyPolicy(t *testing.T) {
+	policy := BodyPolicy{OmitOver: 10, Truncate: 4, OmitMimeTypes: []string{"video/*", "font/woff2"}}
+	if policy.IsZero() || !(BodyPolicy{}).IsZero() {
+		t.Error("IsZero")
+	}
+
+	tests := []struct {
+		mimeType string
+		body     string
+		text     string
+		encoding string
+		comment  string
+	}{
+		{"text/plain", "abc", "abc", "", ""},
+		{"text/plain", "abcdef", "abcd", "", "hargo: body truncated to 4 of 6 bytes"},
+		{"image/png", "abcdef", "YWJjZA==", "base64", "hargo: body truncated to 4 of 6 bytes"},
+		{"text/plain", "abcdefghijk", "", "", "hargo: 11 byte body omitted, over 10 bytes"},
+		{"video/mp4", "abc", "", "", "hargo: 3 byte video/mp4 body omitted"},
+		{"font/woff2; charset=binary", "abc", "", "", "hargo: 3 byte font/woff2; charset=binary body omitted"},
+		{"font/woff", "abc", "YWJj", "base64", ""},
+	}
+	for _, test := range tests {
+		entry := Entry{
+			Request:  Request{PostData: PostData{MimeType: test.mimeType, Text: test.body}},
+			Response: NewResponse(&http.Response{StatusCode: 200, Header: http.Header{"Content-Type": {test.mimeType}}}, []byte(test.body)),
+		}
+		policy.applyRequest(&entry)
+		policy.applyResponse(&entry, []byte(test.body))
+
+		content := entry.Response.Content
+		if content.Text != test.text || content.Encoding != test.encoding || content.Comment != test.comment {
+			t.Errorf("%s %q: stored %q %q %q, expected %q %q %q", test.mimeType, test.body,
+				content.Text, content.Encoding, content.Comment, test.text, test.encoding, test.comment)
+		}
+		if content.Size != len(test.body) {
+			t.Errorf("%s %q: size %d", test.mimeType, test.body, content.Size)
+		}
+		if entry.Request.PostData.Comment != test.comment {
+			t.Errorf("%s %q: request comment %q", test.mimeType, test.body, entry.Request.PostData.Comment)
+		}
+	}
+}
@@ -0,0 +1,585 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Redaction strategies: drop removes the value, or the whole header,
+// parameter, cookie or field; hash replaces it with a keyed hash, so equal
+// values can still be correlated; mask replaces its characters with *.
+const (
+	RedactDrop = "drop"
+	RedactHash = "hash"
+	RedactMask = "mask"
+)
+
+// RedactionRule redacts the headers, query parameters, cookies or body
+// fields with a name, or the secrets found by a detector of hargo scan.
+// Exactly one of them is set. Names are case insensitive and may contain
+// wildcards, e.g. X-Api-*.
+type RedactionRule struct {
+	Header string `json:"header"`
+	Query  string `json:"query"`
+	Cookie string `json:"cookie"`
+	// Field is a member of JSON bodies at any depth, or a parameter of
+	// form bodies.
+	Field string `json:"field"`
+	// Detector is the name of a built-in secret detector, e.g. jwt, or
+	// "all" for every one.
+	Detector string `json:"detector"`
+	// Action is drop, hash or mask.
+	Action string `json:"action"`
+}
+
+// RedactionProfile is a named set of redaction rules, kept in a file to be
+// shared across a team and applied by any command.
+type RedactionProfile struct {
+	Name string `json:"name"`
+	// Salt keys the hashes, so values cannot be recovered by hashing
+	// guesses without it.
+	Salt  string          `json:"salt"`
+	Rules []RedactionRule `json:"rules"`
+}
+
+// RedactionProfileDir is where named redaction profiles are looked up, as
+// <name>.yaml, <name>.yml or <name>.json.
+var RedactionProfileDir = filepath.Join(".hargo", "redact")
+
+// LoadRedactionProfile reads a redaction profile from a JSON or YAML file,
+// or the profile of that name in RedactionProfileDir:
+//
+//	name: team
+//	salt: "s3cret"
+//	rules:
+//	  - header: Authorization
+//	    action: drop
+//	  - query: api_key
+//	    action: hash
+//	  - field: password
+//	    action: mask
+//	  - detector: all
+//	    action: mask
+func LoadRedactionProfile(nameOrPath string) (*RedactionProfile, error) {
+	p := nameOrPath
+	if _, err := os.Stat(p); os.IsNotExist(err) && !strings.ContainsAny(nameOrPath, `/\.`) {
+		for _, ext := range []string{".yaml", ".yml", ".json"} {
+			if _, err := os.Stat(filepath.Join(RedactionProfileDir, nameOrPath+ext)); err == nil {
+				p = filepath.Join(RedactionProfileDir, nameOrPath+ext)
+				break
+			}
+		}
+	}
+
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		if b, err = yamlToJSON(b, &RedactionProfile{}); err != nil {
+			return nil, fmt.Errorf("invalid redaction profile %s: %v", p, err)
+		}
+	}
+
+	profile := &RedactionProfile{}
+	if err := json.Unmarshal(b, profile); err != nil {
+		return nil, fmt.Errorf("invalid redaction profile %s: %v", p, err)
+	}
+	if err := profile.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid redaction profile %s: %v", p, err)
+	}
+	return profile, nil
+}
+
+// Validate checks that every rule has one name or detector and a known
+// action.
+func (p *RedactionProfile) Validate() error {
+	detectors := make(map[string]bool)
+	for _, d := range DefaultSecretDetectors() {
+		detectors[d.Name] = true
+	}
+	for i, rule := range p.Rules {
+		set := 0
+		for _, name := range []string{rule.Header, rule.Query, rule.Cookie, rule.Field, rule.Detector} {
+			if name != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return fmt.Errorf("rule %d: expected one of header, query, cookie, field or detector", i+1)
+		}
+		switch rule.Action {
+		case RedactDrop, RedactHash, RedactMask:
+		default:
+			return fmt.Errorf("rule %d: invalid action %q, expected drop, hash or mask", i+1, rule.Action)
+		}
+		if rule.Detector != "" && rule.Detector != "all" && !detectors[rule.Detector] {
+			return fmt.Errorf("rule %d: unknown detector %q", i+1, rule.Detector)
+		}
+		for _, name := range []string{rule.Header, rule.Query, rule.Cookie, rule.Field} {
+			if _, err := path.Match(name, ""); err != nil {
+				return fmt.Errorf("rule %d: invalid name %q", i+1, name)
+			}
+		}
+	}
+	return nil
+}
+
+// redactName returns the action of the last rule whose name, as selected
+// by field, matches name.
+func (p *RedactionProfile) redactName(field func(RedactionRule) string, name string) (string, bool) {
+	name = strings.ToLower(name)
+	for i := len(p.Rules) - 1; i >= 0; i-- {
+		pattern := strings.ToLower(field(p.Rules[i]))
+		if pattern == "" {
+			continue
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return p.Rules[i].Action, true
+		}
+	}
+	return "", false
+}
+
+func headerRule(r RedactionRule) string { return r.Header }
+func queryRule(r RedactionRule) string  { return r.Query }
+func cookieRule(r RedactionRule) string { return r.Cookie }
+func fieldRule(r RedactionRule) string  { return r.Field }
+
+// replace returns value redacted with action, which must not be drop.
+func (p *RedactionProfile) replace(action, value string) string {
+	switch action {
+	case RedactHash:
+		if value == "" {
+			return ""
+		}
+		mac := hmac.New(sha256.New, []byte(p.Salt))
+		mac.Write([]byte(value))
+		return "sha256:" + hex.EncodeToString(mac.Sum(nil))[:16]
+	case RedactMask:
+		return strings.Repeat("*", utf8.RuneCountInString(value))
+	}
+	return ""
+}
+
+// Redact applies the profile to every entry of har.
+func (p *RedactionProfile) Redact(har *Har) {
+	for i := range har.Log.Entries {
+		p.RedactEntry(&har.Log.Entries[i])
+	}
+}
+
+// RedactEntry applies the profile to the URL, headers, cookies and bodies
+// of an entry. JSON bodies with redacted fields are written compactly. A
+// nil profile changes nothing.
+func (p *RedactionProfile) RedactEntry(e *Entry) {
+	if p == nil {
+		return
+	}
+	req, resp := &e.Request, &e.Response
+
+	req.URL = p.redactURL(req.URL)
+	req.QueryString = p.redactPairs(req.QueryString, queryRule)
+	req.Headers = p.redactHeaders(req.Headers)
+	resp.Headers = p.redactHeaders(resp.Headers)
+	req.Cookies = p.redactCookies(req.Cookies)
+	resp.Cookies = p.redactCookies(resp.Cookies)
+
+	req.PostData.Text = p.redactBody(req.PostData.MimeType, req.PostData.Text)
+	params := req.PostData.Params[:0]
+	for _, param := range req.PostData.Params {
+		if action, ok := p.redactName(fieldRule, param.Name); ok {
+			if action == RedactDrop {
+				continue
+			}
+			param.Value = p.replace(action, param.Value)
+		}
+		params = append(params, param)
+	}
+	req.PostData.Params = params
+
+	if content := &resp.Content; content.Text != "" {
+		body, err := decodeContent(*content)
+		if err == nil {
+			if redacted := p.redactBody(content.MimeType, string(body)); redacted != string(body) {
+				content.Text, content.Size = redacted, len(redacted)
+				if content.Encoding == "base64" {
+					content.Text = base64.StdEncoding.EncodeToString([]byte(redacted))
+				}
+			}
+		}
+	}
+
+	if p.hasDetectors() {
+		req.URL = p.redactSecrets(req.URL)
+		for i := range req.Headers {
+			req.Headers[i].Value = p.redactSecrets(req.Headers[i].Value)
+		}
+		for i := range resp.Headers {
+			resp.Headers[i].Value = p.redactSecrets(resp.Headers[i].Value)
+		}
+		req.PostData.Text = p.redactSecrets(req.PostData.Text)
+		if resp.Content.Encoding != "base64" && isTextMimeType(resp.Content.MimeType) {
+			resp.Content.Text = p.redactSecrets(resp.Content.Text)
+		}
+	}
+}
+
+// redactPairs redacts name/value pairs named by the rules selected by
+// field.
+func (p *RedactionProfile) redactPairs(pairs []NVP, field func(RedactionRule) string) []NVP {
+	kept := pairs[:0]
+	for _, pair := range pairs {
+		if action, ok := p.redactName(field, pair.Name); ok {
+			if action == RedactDrop {
+				continue
+			}
+			pair.Value = p.replace(action, pair.Value)
+		}
+		kept = append(kept, pair)
+	}
+	return kept
+}
+
+// redactHeaders redacts headers, and the cookies in Cookie and Set-Cookie
+// headers.
+func (p *RedactionProfile) redactHeaders(headers []NVP) []NVP {
+	headers = p.redactPairs(headers, headerRule)
+	kept := headers[:0]
+	for _, h := range headers {
+		switch strings.ToLower(h.Name) {
+		case "cookie":
+			h.Value = p.redactCookieHeader(h.Value, "; ")
+		case "set-cookie":
+			// only the first pair is the cookie, the rest are attributes
+			cookie, attributes, _ := strings.Cut(h.Value, ";")
+			if cookie = p.redactCookieHeader(cookie, ""); cookie == "" {
+				continue
+			}
+			if attributes != "" {
+				cookie += ";" + attributes
+			}
+			h.Value = cookie
+		}
+		if h.Value == "" && strings.EqualFold(h.Name, "cookie") {
+			continue
+		}
+		kept = append(kept, h)
+	}
+	return kept
+}
+
+// redactCookieHeader redacts the name=value pairs of a Cookie header,
+// joined by sep.
+func (p *RedactionProfile) redactCookieHeader(value, sep string) string {
+	var pairs []string
+	changed := false
+	for _, pair := range strings.Split(value, ";") {
+		name, v, _ := strings.Cut(strings.TrimSpace(pair), "=")
+		if action, ok := p.redactName(cookieRule, name); ok {
+			changed = true
+			if action == RedactDrop {
+				continue
+			}
+			pair = name + "=" + p.replace(action, v)
+		}
+		pairs = append(pairs, strings.TrimSpace(pair))
+	}
+	if !changed {
+		return value
+	}
+	return strings.Join(pairs, sep)
+}
+
+// redactCookies redacts cookies.
+func (p *RedactionProfile) redactCookies(cookies []Cookie) []Cookie {
+	kept := cookies[:0]
+	for _, c := range cookies {
+		if action, ok := p.redactName(cookieRule, c.Name); ok {
+			if action == RedactDrop {
+				continue
+			}
+			c.Value = p.replace(action, c.Value)
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
+
+// redactURL redacts the query parameters of a URL.
+func (p *RedactionProfile) redactURL(rawURL string) string {
+	base, query, ok := strings.Cut(rawURL, "?")
+	if !ok {
+		return rawURL
+	}
+	query = p.redactQuery(query, queryRule)
+	if query == "" {
+		return base
+	}
+	return base + "?" + query
+}
+
+// redactQuery redacts the parameters of a query string or form body named
+// by the rules selected by field, keeping the others as they are.
+func (p *RedactionProfile) redactQuery(query string, field func(RedactionRule) string) string {
+	var pairs []string
+	changed := false
+	for _, pair := range strings.Split(query, "&") {
+		rawName, rawValue, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(rawName)
+		if err != nil {
+			name = rawName
+		}
+		if action, ok := p.redactName(field, name); ok && pair != "" {
+			changed = true
+			if action == RedactDrop {
+				continue
+			}
+			value, err := url.QueryUnescape(rawValue)
+			if err != nil {
+				value = rawValue
+			}
+			pair = rawName + "=" + url.QueryEscape(p.replace(action, value))
+		}
+		pairs = append(pairs, pair)
+	}
+	if !changed {
+		return query
+	}
+	return strings.Join(pairs, "&")
+}
+
+// redactBody redacts the fields of a JSON or form body.
+func (p *RedactionProfile) redactBody(mimeType, body string) string {
+	if body == "" {
+		return body
+	}
+	mediaType, _, _ := mime.ParseMediaType(mimeType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		return p.redactQuery(body, fieldRule)
+	case strings.Contains(mediaType, "json") || mediaType == "" && json.Valid([]byte(body)):
+		dec := json.NewDecoder(strings.NewReader(body))
+		dec.UseNumber()
+		v, err := decodeOrderedJSON(dec)
+		if err != nil {
+			return body
+		}
+		if _, err := dec.Token(); err != io.EOF {
+			return body
+		}
+		if !p.redactJSON(v) {
+			return body
+		}
+		redacted, err := marshalUnescaped(v)
+		if err != nil {
+			return body
+		}
+		return string(redacted)
+	}
+	return body
+}
+
+// redactJSON redacts the members of objects in v named by field rules, and
+// reports whether it changed anything.
+func (p *RedactionProfile) redactJSON(v interface{}) bool {
+	changed := false
+	switch v := v.(type) {
+	case *orderedObject:
+		keys := v.keys[:0:0]
+		for _, key := range v.keys {
+			action, ok := p.redactName(fieldRule, key)
+			switch {
+			case !ok:
+				changed = p.redactJSON(v.values[key]) || changed
+			case action == RedactDrop:
+				delete(v.values, key)
+				changed = true
+				continue
+			default:
+				v.values[key] = p.replaceJSON(action, v.values[key])
+				changed = true
+			}
+			keys = append(keys, key)
+		}
+		v.keys = keys
+	case []interface{}:
+		for _, item := range v {
+			changed = p.redactJSON(item) || changed
+		}
+	}
+	return changed
+}
+
+// replaceJSON redacts a JSON value. Objects and arrays are replaced as a
+// whole, by the hash or mask of their encoding.
+func (p *RedactionProfile) replaceJSON(action string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return p.replace(action, v)
+	case json.Number:
+		return p.replace(action, v.String())
+	}
+	encoded, _ := marshalUnescaped(v)
+	return p.replace(action, string(encoded))
+}
+
+// hasDetectors reports whether the profile has detector rules.
+func (p *RedactionProfile) hasDetectors() bool {
+	for _, rule := range p.Rules {
+		if rule.Detector != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// redactSecrets redacts the secrets found in value by the detectors of the
+// rules.
+func (p *RedactionProfile) redactSecrets(value string) string {
+	if value == "" {
+		return value
+	}
+	for _, rule := range p.Rules {
+		if rule.Detector == "" {
+			continue
+		}
+		for _, d := range DefaultSecretDetectors() {
+			if rule.Detector != "all" && rule.Detector != d.Name {
+				continue
+			}
+			value = d.Pattern.ReplaceAllStringFunc(value, func(match string) string {
+				secret := match
+				if m := d.Pattern.FindStringSubmatch(match); len(m) > 1 && m[1] != "" {
+					secret = m[1]
+				}
+				if d.Valid != nil && !d.Valid(secret) {
+					return match
+				}
+				return strings.Replace(match, secret, p.replace(rule.Action, secret), 1)
+			})
+		}
+	}
+	return value
+}
+
+// decodeOrderedJSON decodes the next value of dec, keeping the order of
+// object members.
+func decodeOrderedJSON(dec *json.Decoder) (interface{}, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case json.Delim('{'):
+		obj := newOrderedObject()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeOrderedJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.set(key.(string), v, false)
+		}
+		return obj, expectDelim(dec, '}')
+	case json.Delim('['):
+		list := []interface{}{}
+		for dec.More() {
+			v, err := decodeOrderedJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, expectDelim(dec, ']')
+	}
+	return t, nil
+}
+
+// marshalUnescaped encodes v as JSON without escaping <, > and &.
+func marshalUnescaped(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// RedactReader returns a reader of the .har file read from r with profile
+// applied, for commands that read a .har file. r is returned as is if
+// profile is nil.
+func RedactReader(r *bufio.Reader, profile *RedactionProfile) (*bufio.Reader, error) {
+	if profile == nil {
+		return r, nil
+	}
+	har, err := readHar(r)
+	if err != nil {
+		return nil, err
+	}
+	profile.Redact(&har)
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(har); err != nil {
+		return nil, err
+	}
+	return bufio.NewReader(&buf), nil
+}
+
+// redactWriter buffers a .har file to write it redacted when closed.
+type redactWriter struct {
+	w       io.Writer
+	profile *RedactionProfile
+	buf     bytes.Buffer
+}
+
+func (rw *redactWriter) Write(p []byte) (int, error) {
+	return rw.buf.Write(p)
+}
+
+// Close redacts the buffered .har file and writes it indented.
+func (rw *redactWriter) Close() error {
+	har, err := readHar(bufio.NewReader(&rw.buf))
+	if err != nil {
+		return err
+	}
+	rw.profile.Redact(&har)
+	return Encode(rw.w, har)
+}
+
+// nopWriteCloser adds a Close method doing nothing to a writer.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// RedactWriter returns a writer for commands that write a .har file, which
+// writes it to w with profile applied when closed. The .har file is held
+// in memory until then. Without profile, writes go to w directly.
+func RedactWriter(w io.Writer, profile *RedactionProfile) io.WriteCloser {
+	if profile == nil {
+		return nopWriteCloser{w}
+	}
+	return &redactWriter{w: w, profile: profile}
+}
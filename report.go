@@ -0,0 +1,325 @@
+package hargo
+
+import (
+	"bufio"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The report template and its CSS/JS are embedded so reports are a single
+// self-contained HTML file without CDN references, viewable offline.
+//
+//go:embed assets/report.html assets/analysis.html assets/report.css assets/report.js
+var reportAssets embed.FS
+
+// ReportSection is a sortable table in an HTML report.
+type ReportSection struct {
+	Title   string
+	Columns []string
+	// Numeric marks the columns that are right-aligned and sorted as numbers.
+	Numeric []bool
+	Rows    [][]string
+}
+
+type waterfallRow struct {
+	Index       int
+	Method      string
+	URL         string
+	Status      int
+	StatusClass int
+	MimeType    string
+	Size        int
+	Time        float64
+	Offset      float64
+	Left        string
+	Width       string
+}
+
+type galleryImage struct {
+	URL  string
+	Src  template.URL
+	Size int
+}
+
+type reportData struct {
+	Title     string
+	Generated string
+	Creator   string
+	Requests  int
+	Sent      int64
+	Received  int64
+	Duration  float64
+	Sections  []ReportSection
+	Waterfall []waterfallRow
+	Images    []galleryImage
+	CSS       template.CSS
+	JS        template.JS
+}
+
+// Report writes a self-contained HTML report of a .har file to w.
+func Report(r *bufio.Reader, w io.Writer) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	return WriteReport(w, har)
+}
+
+// WriteReport writes a self-contained HTML report for a Har to w: a traffic
+// summary, per-domain and per-type tables, a request waterfall and a gallery
+// of the recorded images. Additional sections are appended after the
+// built-in tables.
+func WriteReport(w io.Writer, har Har, sections ...ReportSection) error {
+	css, js, err := reportStyle()
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.ParseFS(reportAssets, "assets/report.html")
+	if err != nil {
+		return err
+	}
+
+	stats := ComputeStats(har)
+
+	data := reportData{
+		Title:     reportTitle(har),
+		Generated: time.Now().Format(time.RFC1123),
+		Creator:   strings.TrimSpace(har.Log.Creator.Name + " " + har.Log.Creator.Version),
+		Requests:  stats.Total.Requests,
+		Sent:      stats.Total.Sent,
+		Received:  stats.Total.Received,
+		CSS:       css,
+		JS:        js,
+	}
+
+	data.Sections = append(data.Sections,
+		byteCountSection("Domains", "Domain", stats.ByDomain),
+		byteCountSection("Content types", "Type", stats.ByType),
+		byteCountSection("Protocols", "Protocol", stats.ByProtocol))
+	data.Sections = append(data.Sections, sections...)
+
+	data.Waterfall, data.Duration = waterfall(har.Log.Entries)
+	data.Images = gallery(har.Log.Entries)
+
+	return tmpl.Execute(w, data)
+}
+
+// reportStyle returns the embedded CSS and JS shared by all reports.
+func reportStyle() (template.CSS, template.JS, error) {
+	css, err := reportAssets.ReadFile("assets/report.css")
+	if err != nil {
+		return "", "", err
+	}
+	js, err := reportAssets.ReadFile("assets/report.js")
+	if err != nil {
+		return "", "", err
+	}
+	return template.CSS(css), template.JS(js), nil
+}
+
+// AnalysisReport is the content of a self-contained HTML report of an
+// analysis or a load test: key figures, bar charts and sortable tables.
+type AnalysisReport struct {
+	Title    string
+	Figures  []ReportFigure
+	Charts   []ReportChart
+	Sections []ReportSection
+}
+
+// ReportFigure is a key figure shown at the top of an AnalysisReport.
+type ReportFigure struct {
+	Label string
+	Value string
+}
+
+// ReportChart is a horizontal bar chart in an AnalysisReport.
+type ReportChart struct {
+	Title string
+	Bars  []ReportBar
+}
+
+// ReportBar is a bar of a ReportChart. Text is shown next to the bar,
+// whose length is proportional to Value.
+type ReportBar struct {
+	Label string
+	Value float64
+	Text  string
+}
+
+// Width returns the length of a bar of value in percent of the longest.
+func (c ReportChart) Width(value float64) string {
+	longest := 0.0
+	for _, b := range c.Bars {
+		longest = math.Max(longest, b.Value)
+	}
+	if longest <= 0 || value <= 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%.2f", value/longest*100)
+}
+
+type analysisData struct {
+	AnalysisReport
+	Generated string
+	CSS       template.CSS
+	JS        template.JS
+}
+
+// WriteAnalysisReport writes report as a self-contained HTML file to w.
+func WriteAnalysisReport(w io.Writer, report AnalysisReport) error {
+	css, js, err := reportStyle()
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.ParseFS(reportAssets, "assets/analysis.html")
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(w, analysisData{
+		AnalysisReport: report,
+		Generated:      time.Now().Format(time.RFC1123),
+		CSS:            css,
+		JS:             js,
+	})
+}
+
+// WriteAnalysisReportFile writes report as an HTML file to path.
+func WriteAnalysisReportFile(path string, report AnalysisReport) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = WriteAnalysisReport(file, report)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// reportTitle uses the first page title, falling back to the first URL.
+func reportTitle(har Har) string {
+	if len(har.Log.Pages) > 0 && har.Log.Pages[0].Title != "" {
+		return har.Log.Pages[0].Title
+	}
+	if len(har.Log.Entries) > 0 {
+		return har.Log.Entries[0].Request.URL
+	}
+	return "HAR report"
+}
+
+// byteCountSection renders a ByteCount map as a report table.
+func byteCountSection(title string, column string, counts map[string]*ByteCount) ReportSection {
+	section := ReportSection{
+		Title:   title,
+		Columns: []string{column, "Requests", "Sent (bytes)", "Received (bytes)"},
+		Numeric: []bool{false, true, true, true},
+	}
+
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return counts[keys[i]].Received > counts[keys[j]].Received
+	})
+
+	for _, k := range keys {
+		c := counts[k]
+		section.Rows = append(section.Rows, []string{
+			k,
+			strconv.Itoa(c.Requests),
+			strconv.FormatInt(c.Sent, 10),
+			strconv.FormatInt(c.Received, 10),
+		})
+	}
+
+	return section
+}
+
+// waterfall positions every entry on a common timeline starting at the
+// earliest entry and returns the rows with the total duration in ms.
+func waterfall(entries []Entry) ([]waterfallRow, float64) {
+	var first, last time.Time
+	starts := make([]time.Time, len(entries))
+
+	for i, entry := range entries {
+		t, err := parseStartedDateTime(entry.StartedDateTime)
+		if err != nil {
+			continue
+		}
+		starts[i] = t
+		end := t.Add(time.Duration(float64(entry.Time) * float64(time.Millisecond)))
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		if end.After(last) {
+			last = end
+		}
+	}
+
+	total := float64(last.Sub(first)) / float64(time.Millisecond)
+
+	rows := make([]waterfallRow, 0, len(entries))
+	for i, entry := range entries {
+		row := waterfallRow{
+			Index:       i,
+			Method:      entry.Request.Method,
+			URL:         entry.Request.URL,
+			Status:      entry.Response.Status,
+			StatusClass: entry.Response.Status / 100,
+			MimeType:    entry.Response.Content.MimeType,
+			Size:        entry.Response.Content.Size,
+			Time:        float64(entry.Time),
+			Left:        "0",
+			Width:       "0",
+		}
+
+		if !starts[i].IsZero() && total > 0 {
+			row.Offset = float64(starts[i].Sub(first)) / float64(time.Millisecond)
+			row.Left = fmt.Sprintf("%.2f", row.Offset/total*100)
+			row.Width = fmt.Sprintf("%.2f", row.Time/total*100)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, total
+}
+
+// gallery inlines the recorded images as data URIs.
+func gallery(entries []Entry) []galleryImage {
+	var images []galleryImage
+	seen := make(map[string]bool)
+
+	for _, entry := range entries {
+		mimeType := strings.ToLower(strings.TrimSpace(strings.Split(entry.Response.Content.MimeType, ";")[0]))
+		if !strings.HasPrefix(mimeType, "image/") || entry.Response.Content.Text == "" || seen[entry.Request.URL] {
+			continue
+		}
+		seen[entry.Request.URL] = true
+
+		data, err := entry.DecodedBody()
+		if err != nil {
+			continue
+		}
+
+		images = append(images, galleryImage{
+			URL:  entry.Request.URL,
+			Src:  template.URL("data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)),
+			Size: len(data),
+		})
+	}
+
+	return images
+}
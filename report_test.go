@@ -0,0 +1,93 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteAnalysisReport(t *testing.T) {
+	report := AnalysisReport{
+		Title:   "<Results>",
+		Figures: []ReportFigure{{"requests", "3"}},
+		Charts: []ReportChart{{Title: "Sizes", Bars: []ReportBar{
+			{Label: "a", Value: 50, Text: "50 B"},
+			{Label: "b", Value: 200, Text: "200 B"},
+		}}},
+		Sections: []ReportSection{{Title: "Rows", Columns: []string{"Name", "Count"}, Numeric: []bool{false, true}, Rows: [][]string{{"x", "1"}}}},
+	}
+
+	var out bytes.Buffer
+	if err := WriteAnalysisReport(&out, report); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"<h1>&lt;Results&gt;</h1>",
+		"<b>3</b>requests",
+		`style="width: 25.00%"`,
+		`style="width: 100.00%"`,
+		`<td class="num">1</td>`,
+		"table.sortable",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("output does not contain %q", expected)
+		}
+	}
+	if strings.Contains(out.String(), "<script src=") {
+		t.Error("report references an external script")
+	}
+}
+
+func TestStatsHTML(t *testing.T) {
+	data, err := json.Marshal(Har{Log: Log{Entries: []Entry{{
+		Request:  Request{Method: "GET", URL: "https://example.com/", HeaderSize: 100},
+		Response: Response{HeadersSize: 200, BodySize: 1800, Content: Content{MimeType: "text/html"}},
+	}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := StatsHTML(bufio.NewReader(bytes.NewReader(data)), &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"<h2>Received by domain</h2>", "example.com", "2.0 KiB", "<h2>Protocols</h2>"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("output does not contain %q", expected)
+		}
+	}
+}
+
+func TestLoadReport(t *testing.T) {
+	latencies := NewLatencyHistogram()
+	for _, d := range []time.Duration{3 * time.Millisecond, 4 * time.Millisecond, 15 * time.Millisecond, 20 * time.Second} {
+		latencies.Record(d)
+	}
+	report := LoadReport("Load test of foo.har", LoadResult{Requests: 5, Failures: 1, Latencies: latencies})
+
+	if report.Figures[3].Value != "20.00%" {
+		t.Errorf("got error rate %s", report.Figures[3].Value)
+	}
+
+	distribution := report.Sections[1]
+	want := [][]string{
+		{"2ms - 5ms", "2", "50.00"},
+		{"5ms - 10ms", "0", "0.00"},
+		{"10ms - 20ms", "1", "25.00"},
+	}
+	for i, row := range want {
+		if strings.Join(distribution.Rows[i], ",") != strings.Join(row, ",") {
+			t.Errorf("row %d: got %v, want %v", i, distribution.Rows[i], row)
+		}
+	}
+	if last := distribution.Rows[len(distribution.Rows)-1]; last[0] != ">= 10s" || last[1] != "1" {
+		t.Errorf("got last row %v", last)
+	}
+
+	if len(report.Charts[0].Bars) != 7 || report.Charts[0].Bars[6].Label != "max" {
+		t.Errorf("got percentile chart %+v", report.Charts[0])
+	}
+}
@@ -0,0 +1,111 @@
+package hargo
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// hashingTarget records the SHA-256 of every file written to a target for
+// the manifest. When resuming an extraction, files that already exist
+// with the same content are not written again.
+type hashingTarget struct {
+	extractTarget
+	hashes map[string]string
+	// previous maps the paths of the manifest of a resumed extraction to
+	// their hashes; resume is set when resuming, even without a manifest.
+	previous map[string]string
+	resume   bool
+	kept     int
+}
+
+func newHashingTarget(target extractTarget) *hashingTarget {
+	return &hashingTarget{extractTarget: target, hashes: make(map[string]string)}
+}
+
+func (t *hashingTarget) writeFile(path string, data []byte, modTime time.Time) error {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	t.hashes[filepath.Clean(path)] = hash
+	if t.resume && t.unchanged(path, hash, int64(len(data))) {
+		t.kept++
+		return nil
+	}
+	return t.extractTarget.writeFile(path, data, modTime)
+}
+
+// unchanged reports whether the file at path already has the content with
+// the hash, trusting the manifest of the resumed extraction if the file
+// has the expected size, and hashing the file otherwise.
+func (t *hashingTarget) unchanged(path, hash string, size int64) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != size {
+		return false
+	}
+	if previous, ok := t.previous[filepath.Clean(path)]; ok {
+		return previous == hash
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return false
+	}
+	return hex.EncodeToString(h.Sum(nil)) == hash
+}
+
+// addHashes sets the SHA256 of the manifest entries of written files.
+func (t *hashingTarget) addHashes(manifest []ManifestEntry) {
+	for i := range manifest {
+		manifest[i].SHA256 = t.hashes[filepath.Clean(manifest[i].ExtractedPath)]
+	}
+}
+
+// readManifestHashes reads the extracted paths and their hashes from an
+// extraction_manifest.csv. Manifests written before hashes were recorded
+// yield no hashes.
+func readManifestHashes(manifestPath string) (map[string]string, error) {
+	file, err := os.Open(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %v", manifestPath, err)
+	}
+	hashes := make(map[string]string)
+	if len(records) == 0 {
+		return hashes, nil
+	}
+	pathColumn, hashColumn := -1, -1
+	for i, name := range records[0] {
+		switch name {
+		case "Extracted Path":
+			pathColumn = i
+		case "SHA-256":
+			hashColumn = i
+		}
+	}
+	if pathColumn < 0 {
+		return nil, fmt.Errorf("invalid manifest %s: no Extracted Path column", manifestPath)
+	}
+	if hashColumn < 0 {
+		return hashes, nil
+	}
+	for _, record := range records[1:] {
+		if pathColumn < len(record) && hashColumn < len(record) && record[hashColumn] != "" {
+			hashes[filepath.Clean(record[pathColumn])] = record[hashColumn]
+		}
+	}
+	return hashes, nil
+}
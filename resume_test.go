@@ -0,0 +1,90 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractResume(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	extract := func(opts ExtractOptions) ExtractResult {
+		t.Helper()
+		result, err := ExtractContext(context.Background(), bufio.NewReader(strings.NewReader(createTestHAR())), opts)
+		if err != nil {
+			t.Fatalf("Extract failed: %v", err)
+		}
+		return result
+	}
+
+	first := extract(ExtractOptions{})
+	if len(first.Manifest) < 3 || first.Kept != 0 {
+		t.Fatalf("unexpected result %+v", first)
+	}
+	for _, m := range first.Manifest {
+		if len(m.SHA256) != 64 {
+			t.Errorf("%s: got hash %q", m.ExtractedPath, m.SHA256)
+		}
+	}
+
+	// an interrupted extraction left a truncated and a missing file
+	truncated, missing := first.Manifest[0], first.Manifest[1]
+	os.WriteFile(truncated.ExtractedPath, []byte("trunc"), 0644)
+	os.Remove(missing.ExtractedPath)
+
+	resumed := extract(ExtractOptions{Resume: first.OutputDir})
+	if resumed.OutputDir != first.OutputDir || resumed.Written != first.Written || resumed.Kept != first.Written-2 {
+		t.Errorf("unexpected result %+v", resumed)
+	}
+	for _, m := range []ManifestEntry{truncated, missing} {
+		content, err := os.ReadFile(m.ExtractedPath)
+		if err != nil || len(content) != m.Size {
+			t.Errorf("%s not restored: %v", m.ExtractedPath, err)
+		}
+	}
+	if dirs, _ := filepath.Glob("./hargo-extract-*"); len(dirs) != 1 {
+		t.Errorf("expected no new output directory, got %v", dirs)
+	}
+
+	// without a manifest the files themselves are hashed
+	os.Remove(filepath.Join(first.OutputDir, "extraction_manifest.csv"))
+	if resumed := extract(ExtractOptions{Resume: first.OutputDir}); resumed.Kept != first.Written {
+		t.Errorf("expected all %d files to be kept, got %d", first.Written, resumed.Kept)
+	}
+
+	for _, opts := range []ExtractOptions{
+		{Resume: filepath.Join(first.OutputDir, "missing")},
+		{Resume: first.OutputDir, Archive: &bytes.Buffer{}, ArchiveFormat: ArchiveZip},
+	} {
+		if _, err := ExtractContext(context.Background(), bufio.NewReader(strings.NewReader(createTestHAR())), opts); err == nil {
+			t.Errorf("%+v: expected an error", opts)
+		}
+	}
+}
+
+func TestReadManifestHashes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "extraction_manifest.csv")
+
+	os.WriteFile(path, []byte("Original URL,Extracted Path,SHA-256\nhttps://example.com/,./out/index.html,abc\nhttps://example.com/x,out/x,\n"), 0644)
+	hashes, err := readManifestHashes(path)
+	if err != nil || len(hashes) != 1 || hashes[filepath.Join("out", "index.html")] != "abc" {
+		t.Errorf("got %v, %v", hashes, err)
+	}
+
+	// manifests of older versions have no hashes
+	os.WriteFile(path, []byte("Original URL,Extracted Path,Sanitized\nhttps://example.com/,out/index.html,false\n"), 0644)
+	if hashes, err := readManifestHashes(path); err != nil || len(hashes) != 0 {
+		t.Errorf("got %v, %v", hashes, err)
+	}
+
+	os.WriteFile(path, []byte("URL\nhttps://example.com/\n"), 0644)
+	if _, err := readManifestHashes(path); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,173 @@
+package hargo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Failure policies of replays and load tests.
+const (
+	// FailureContinue carries on after failed requests. It is the default.
+	FailureContinue = "continue"
+	// FailureAbort stops at the first failed request.
+	FailureAbort = "abort"
+)
+
+// RetryPolicy resends requests that fail with a transport error or get one
+// of the listed statuses.
+type RetryPolicy struct {
+	// Count is how often a request is resent at most.
+	Count int `json:"count"`
+	// Backoff is the delay before the first retry in milliseconds. It
+	// doubles with every further retry, up to maxRetryBackoff.
+	Backoff float64 `json:"backoff"`
+	// Statuses are the response statuses that are retried, e.g. 502 and
+	// 503. A request still getting one after its last retry has failed.
+	Statuses []int `json:"statuses"`
+}
+
+// retryable reports whether a response with status is retried.
+func (p RetryPolicy) retryable(status int) bool {
+	for _, s := range p.Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// maxRetryBackoff bounds the delay before a retry, which would otherwise
+// overflow after a few dozen doublings.
+const maxRetryBackoff = 30 * time.Second
+
+// delay returns how long to wait before the given retry, counted from 0.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := p.Backoff * float64(time.Millisecond)
+	if d >= float64(maxRetryBackoff) {
+		return maxRetryBackoff
+	}
+	backoff := time.Duration(d)
+	for ; retry > 0 && backoff > 0 && backoff < maxRetryBackoff; retry-- {
+		backoff <<= 1
+	}
+	if backoff > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+	return backoff
+}
+
+// doWithRetry sends req with client and resends it as policy says, calling
+// onRetry before every retry. The body of req is replayed with GetBody.
+func doWithRetry(ctx context.Context, client *http.Client, req *http.Request, policy RetryPolicy, onRetry func()) (*http.Response, error) {
+	for retry := 0; ; retry++ {
+		resp, err := client.Do(req)
+		if retry >= policy.Count || ctx.Err() != nil || (err == nil && !policy.retryable(resp.StatusCode)) {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if d := policy.delay(retry); d > 0 {
+			timer := time.NewTimer(d)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		}
+
+		next := req.Clone(req.Context())
+		if req.GetBody != nil {
+			next.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+		req = next
+		onRetry()
+	}
+}
+
+// failureTracker counts the requests, retries and failures of a replay or
+// load test and decides when its failure policy aborts it. It is safe for
+// concurrent use.
+type failureTracker struct {
+	mu       sync.Mutex
+	limit    int
+	requests int
+	retries  int
+	failures int
+}
+
+// newFailureTracker returns the tracker for the OnFailure and MaxFailures
+// settings of cfg.
+func newFailureTracker(cfg Config) (*failureTracker, error) {
+	if cfg.Retry.Count < 0 || cfg.Retry.Backoff < 0 {
+		return nil, fmt.Errorf("invalid retry policy %+v", cfg.Retry)
+	}
+	if cfg.MaxFailures < 0 {
+		return nil, fmt.Errorf("invalid maximum of failures %d", cfg.MaxFailures)
+	}
+
+	t := &failureTracker{limit: cfg.MaxFailures}
+	switch cfg.OnFailure {
+	case "", FailureContinue:
+	case FailureAbort:
+		t.limit = 1
+	default:
+		return nil, fmt.Errorf("unknown failure policy %q", cfg.OnFailure)
+	}
+	return t, nil
+}
+
+// request counts a request sent, not counting its retries.
+func (t *failureTracker) request() {
+	t.mu.Lock()
+	t.requests++
+	t.mu.Unlock()
+}
+
+// retry counts a retry.
+func (t *failureTracker) retry() {
+	t.mu.Lock()
+	t.retries++
+	t.mu.Unlock()
+}
+
+// fail counts a failed request and returns an error once the failure policy
+// aborts.
+func (t *failureTracker) fail() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failures++
+	if t.limit > 0 && t.failures >= t.limit {
+		return fmt.Errorf("aborted after %d failed requests", t.failures)
+	}
+	return nil
+}
+
+// summary describes the counts for the final report.
+func (t *failureTracker) summary() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return requestSummary(t.requests, t.retries, t.failures)
+}
+
+// result returns the counts as the LoadResult with latencies.
+func (t *failureTracker) result(latencies *LatencyHistogram) LoadResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return LoadResult{Requests: t.requests, Retries: t.retries, Failures: t.failures, Latencies: latencies}
+}
+
+// requestSummary describes request counts for the final report.
+func requestSummary(requests, retries, failures int) string {
+	return fmt.Sprintf("Requests: %d sent, %d retried, %d failed", requests, retries, failures)
+}
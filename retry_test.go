@@ -0,0 +1,143 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDoWithRetry(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if len(bodies) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		policy   RetryPolicy
+		status   int
+		requests int
+	}{
+		{RetryPolicy{}, 503, 1},
+		{RetryPolicy{Count: 5}, 503, 1},
+		{RetryPolicy{Count: 1, Statuses: []int{503}}, 503, 2},
+		{RetryPolicy{Count: 5, Backoff: 1, Statuses: []int{503}}, 200, 3},
+	}
+	for _, test := range tests {
+		bodies = nil
+		retries := 0
+		req, _ := http.NewRequest("POST", server.URL, strings.NewReader("payload"))
+		resp, err := doWithRetry(context.Background(), http.DefaultClient, req, test.policy, func() { retries++ })
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.status || len(bodies) != test.requests || retries != test.requests-1 {
+			t.Errorf("%+v: status %d after %d requests and %d retries", test.policy, resp.StatusCode, len(bodies), retries)
+		}
+		for _, b := range bodies {
+			if b != "payload" {
+				t.Errorf("%+v: body %q", test.policy, b)
+			}
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		policy   RetryPolicy
+		retry    int
+		expected time.Duration
+	}{
+		{RetryPolicy{}, 3, 0},
+		{RetryPolicy{Backoff: 100}, 0, 100 * time.Millisecond},
+		{RetryPolicy{Backoff: 100}, 3, 800 * time.Millisecond},
+		{RetryPolicy{Backoff: 100}, 10, maxRetryBackoff},
+		// shifts that would overflow are clamped too
+		{RetryPolicy{Backoff: 100}, 70, maxRetryBackoff},
+		{RetryPolicy{Backoff: 1e300}, 0, maxRetryBackoff},
+	}
+	for _, test := range tests {
+		if got := test.policy.delay(test.retry); got != test.expected {
+			t.Errorf("%+v, retry %d: got %v, want %v", test.policy, test.retry, got, test.expected)
+		}
+	}
+}
+
+func TestFailureTracker(t *testing.T) {
+	tests := []struct {
+		cfg   Config
+		abort int
+	}{
+		{Config{}, 0},
+		{Config{OnFailure: FailureContinue}, 0},
+		{Config{OnFailure: FailureAbort}, 1},
+		{Config{MaxFailures: 3}, 3},
+	}
+	for _, test := range tests {
+		tracker, err := newFailureTracker(test.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		abort := 0
+		for i := 1; i <= 5 && abort == 0; i++ {
+			if tracker.fail() != nil {
+				abort = i
+			}
+		}
+		if abort != test.abort {
+			t.Errorf("%+v: aborted after %d failures, expected %d", test.cfg, abort, test.abort)
+		}
+	}
+
+	for _, cfg := range []Config{{OnFailure: "retry"}, {MaxFailures: -1}, {Retry: RetryPolicy{Count: -1}}} {
+		if _, err := newFailureTracker(cfg); err == nil {
+			t.Errorf("%+v accepted", cfg)
+		}
+	}
+}
+
+func TestReplayAbortOnFailure(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	entry := Entry{
+		StartedDateTime: "2020-01-01T00:00:00.000Z",
+		Request:         Request{Method: "GET", URL: "https://example.com/"},
+		Response:        Response{Status: 200},
+	}
+	har := Har{Log: Log{Entries: []Entry{entry, entry, entry}}}
+
+	var h bytes.Buffer
+	if err := json.NewEncoder(&h).Encode(har); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{
+		Rewrites:    []Rewrite{{BaseURL: server.URL}},
+		Retry:       RetryPolicy{Count: 1, Statuses: []int{502}},
+		MaxFailures: 2,
+	}
+	err := Replay(bufio.NewReader(&h), cfg)
+	if err == nil || !strings.Contains(err.Error(), "aborted after 2 failed requests") {
+		t.Errorf("err = %v", err)
+	}
+	if requests != 4 {
+		t.Errorf("server got %d requests, expected 4", requests)
+	}
+}
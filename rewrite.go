@@ -0,0 +1,78 @@
+package hargo
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Rewrite redirects matching requests to a different base URL and/or path
+// prefix, so production-shaped traffic can be replayed against another
+// environment.
+type Rewrite struct {
+	// Host restricts the rule to requests for this host (name or
+	// name:port). An empty Host matches every request.
+	Host string `json:"host"`
+	// PathPrefix restricts the rule to request paths starting with this
+	// prefix, which is replaced by NewPathPrefix.
+	PathPrefix    string `json:"pathPrefix"`
+	NewPathPrefix string `json:"newPathPrefix"`
+	// BaseURL replaces the scheme and host of matching requests. A path in
+	// BaseURL is prepended to the request path.
+	BaseURL string `json:"baseUrl"`
+}
+
+// apply rewrites u in place and reports whether the rule matched.
+func (rw Rewrite) apply(u *url.URL) (bool, error) {
+	if rw.Host != "" && !strings.EqualFold(rw.Host, u.Host) && !strings.EqualFold(rw.Host, u.Hostname()) {
+		return false, nil
+	}
+
+	if rw.PathPrefix != "" {
+		if !strings.HasPrefix(u.Path, rw.PathPrefix) {
+			return false, nil
+		}
+		u.Path = rw.NewPathPrefix + strings.TrimPrefix(u.Path, rw.PathPrefix)
+		u.RawPath = ""
+	}
+
+	if rw.BaseURL != "" {
+		base, err := url.Parse(rw.BaseURL)
+		if err != nil {
+			return false, err
+		}
+		u.Scheme = base.Scheme
+		u.Host = base.Host
+		if p := strings.TrimRight(base.Path, "/"); p != "" {
+			u.Path = p + u.Path
+			u.RawPath = ""
+		}
+	}
+
+	return true, nil
+}
+
+// applyRequestRules rewrites the URL and headers of a request according to
+// the Rewrites, SetHeaders and RemoveHeaders of a Config. With LiveCookies
+// the recorded Cookie header is dropped before SetHeaders apply.
+func applyRequestRules(req *http.Request, cfg Config) error {
+	for _, rw := range cfg.Rewrites {
+		_, err := rw.apply(req.URL)
+		if err != nil {
+			return err
+		}
+	}
+	req.Host = req.URL.Host
+
+	if cfg.LiveCookies {
+		req.Header.Del("Cookie")
+	}
+	for _, name := range cfg.RemoveHeaders {
+		req.Header.Del(name)
+	}
+	for name, value := range cfg.SetHeaders {
+		req.Header.Set(name, value)
+	}
+
+	return nil
+}
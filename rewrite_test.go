@@ -0,0 +1,107 @@
+package hargo
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestRewriteApply(t *testing.T) {
+	tests := []struct {
+		rule     Rewrite
+		url      string
+		matched  bool
+		expected string
+	}{
+		{Rewrite{BaseURL: "http://localhost:8080"}, "https://example.com/api/users?id=1", true, "http://localhost:8080/api/users?id=1"},
+		{Rewrite{BaseURL: "https://staging.example.com/v2/"}, "https://example.com/users", true, "https://staging.example.com/v2/users"},
+		{Rewrite{Host: "example.com", BaseURL: "http://localhost"}, "https://EXAMPLE.com:443/", true, "http://localhost/"},
+		{Rewrite{Host: "example.com:8443", BaseURL: "http://localhost"}, "https://example.com:8443/", true, "http://localhost/"},
+		{Rewrite{Host: "example.com", BaseURL: "http://localhost"}, "https://cdn.example.com/app.js", false, "https://cdn.example.com/app.js"},
+		{Rewrite{PathPrefix: "/api/v1/", NewPathPrefix: "/api/v2/"}, "https://example.com/api/v1/users", true, "https://example.com/api/v2/users"},
+		{Rewrite{PathPrefix: "/api/v1/", NewPathPrefix: "/api/v2/"}, "https://example.com/static/app.js", false, "https://example.com/static/app.js"},
+		{Rewrite{Host: "example.com", PathPrefix: "/api", NewPathPrefix: "", BaseURL: "http://api.local:9000"}, "https://example.com/api/users", true, "http://api.local:9000/users"},
+		// prefixes match the decoded path
+		{Rewrite{PathPrefix: "/a/b", NewPathPrefix: "/c"}, "https://example.com/a%2Fb/d", true, "https://example.com/c/d"},
+	}
+
+	for i, test := range tests {
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		matched, err := test.rule.apply(u)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if matched != test.matched || u.String() != test.expected {
+			t.Errorf("%d: got %v %s, want %v %s", i, matched, u, test.matched, test.expected)
+		}
+	}
+
+	u, _ := url.Parse("https://example.com/")
+	if _, err := (Rewrite{BaseURL: "http://[::1"}).apply(u); err == nil {
+		t.Error("expected an error for an invalid base URL")
+	}
+}
+
+func TestApplyRequestRules(t *testing.T) {
+	tests := []struct {
+		cfg      Config
+		url      string
+		headers  map[string]string
+		expected map[string]string
+		host     string
+	}{
+		{
+			Config{Rewrites: []Rewrite{{Host: "example.com", BaseURL: "http://localhost:8080"}}},
+			"https://example.com/", map[string]string{"Accept": "*/*"},
+			map[string]string{"Accept": "*/*"}, "localhost:8080",
+		},
+		{
+			// rules apply in order, each to the result of the previous one
+			Config{Rewrites: []Rewrite{{PathPrefix: "/v1", NewPathPrefix: "/v2"}, {PathPrefix: "/v2", BaseURL: "http://v2.local"}}},
+			"https://example.com/v1/users", nil, nil, "v2.local",
+		},
+		{
+			Config{RemoveHeaders: []string{"x-debug"}, SetHeaders: map[string]string{"Authorization": "Bearer new"}},
+			"https://example.com/", map[string]string{"X-Debug": "1", "Authorization": "Bearer old"},
+			map[string]string{"X-Debug": "", "Authorization": "Bearer new"}, "example.com",
+		},
+		{
+			// set headers apply after the recorded cookies are dropped
+			Config{LiveCookies: true, SetHeaders: map[string]string{"Cookie": "session=override"}},
+			"https://example.com/", map[string]string{"Cookie": "session=recorded"},
+			map[string]string{"Cookie": "session=override"}, "example.com",
+		},
+		{
+			Config{LiveCookies: true},
+			"https://example.com/", map[string]string{"Cookie": "session=recorded"},
+			map[string]string{"Cookie": ""}, "example.com",
+		},
+	}
+
+	for i, test := range tests {
+		req, err := http.NewRequest("GET", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = "recorded.example.com"
+		for name, value := range test.headers {
+			req.Header.Set(name, value)
+		}
+		if err := applyRequestRules(req, test.cfg); err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if req.Host != test.host || req.URL.Host != test.host {
+			t.Errorf("%d: got host %s, URL %s", i, req.Host, req.URL)
+		}
+		for name, value := range test.expected {
+			if got := req.Header.Get(name); got != value {
+				t.Errorf("%d: got %s %q, want %q", i, name, got, value)
+			}
+		}
+	}
+}
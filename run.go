@@ -2,15 +2,37 @@ package hargo
 
 import (
 	"bufio"
-	"crypto/tls"
+	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/cookiejar"
+	"os"
+	"strings"
 	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
 // Run executes all entries in .har file
 func Run(r *bufio.Reader, ignoreHarCookies bool, insecureSkipVerify bool) error {
+	return Replay(r, Config{IgnoreHarCookies: ignoreHarCookies, InsecureSkipVerify: insecureSkipVerify})
+}
+
+// Replay executes all entries in .har file using the settings of a scenario
+// Config
+func Replay(r *bufio.Reader, cfg Config) error {
+	return ReplayContext(context.Background(), r, cfg)
+}
+
+// ReplayContext is like Replay, but stops when ctx is done, aborting the
+// request in progress. With cfg.Capture set the replayed traffic is written
+// to a new .har file, and with cfg.Results its request counts and latencies
+// to a result file, also when assertions fail or the replay is stopped.
+func ReplayContext(ctx context.Context, r *bufio.Reader, cfg Config) (err error) {
+	if err := requireNetwork("run"); err != nil {
+		return err
+	}
 
 	har, err := Decode(r)
 
@@ -18,7 +40,30 @@ func Run(r *bufio.Reader, ignoreHarCookies bool, insecureSkipVerify bool) error
 		return err
 	}
 
-	check(err)
+	correlator, err := NewCorrelator(cfg.Correlations)
+	if err != nil {
+		return err
+	}
+	correlator.SetVariables(dataRow(cfg.Data, 0))
+
+	pacer, err := newReplayPacer(cfg, har.Log.Entries)
+	if err != nil {
+		return err
+	}
+
+	tracker, err := newFailureTracker(cfg)
+	if err != nil {
+		return err
+	}
+
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Auth, err = cfg.authProvider(); err != nil {
+		return err
+	}
 
 	jar, _ := cookiejar.New(nil)
 
@@ -27,48 +72,178 @@ func Run(r *bufio.Reader, ignoreHarCookies bool, insecureSkipVerify bool) error
 			r.URL.Opaque = r.URL.Path
 			return nil
 		},
-		Jar: jar,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureSkipVerify},
-		},
+		Jar:       jar,
+		Transport: transport,
 	}
 
 	if len(har.Log.Entries) == 0 {
 		return nil
 	}
 
-	first, _ := time.Parse("2006-01-02T15:04:05.000Z", har.Log.Entries[0].StartedDateTime)
+	emitPhase(EventPhaseStarted, "replay")
+	defer emitPhase(EventPhaseFinished, "replay")
+	defer func() { fmt.Println(tracker.summary()) }()
+
+	latencies := NewLatencyHistogram()
+	if cfg.Results != "" {
+		defer func() {
+			if resultsErr := tracker.result(latencies).WriteFile(cfg.Results); err == nil {
+				err = resultsErr
+			}
+		}()
+	}
+
+	var capture *Builder
+	if cfg.Capture != "" {
+		capture = NewHar()
+		defer func() {
+			if captureErr := writeCapture(cfg.Capture, capture.Har()); err == nil {
+				err = captureErr
+			}
+		}()
+	}
+
+	var results []AssertionResult
+	failed := 0
+
+	for i, entry := range har.Log.Entries {
+		if entry.IsWebSocket() {
+			log.Debugf("Skipping WebSocket connection %s", entry.Request.URL)
+			continue
+		}
+
+		if err := pacer.wait(ctx, i); err != nil {
+			return err
+		}
+
+		req, err := EntryToRequest(&entry, cfg.IgnoreHarCookies || cfg.LiveCookies)
+
+		if err != nil {
+			return err
+		}
+		req = req.WithContext(ctx)
 
-	for _, entry := range har.Log.Entries {
+		err = correlator.Apply(req)
 
-		st, _ := time.Parse("2006-01-02T15:04:05.000Z", entry.StartedDateTime)
-		diffst := st.Sub(first)
-		if diffst > 0 {
-			time.Sleep(diffst * time.Nanosecond)
+		if err != nil {
+			return err
 		}
-		first = st
 
-		req, err := EntryToRequest(&entry, ignoreHarCookies)
+		err = applyRequestRules(req, cfg)
 
 		if err != nil {
 			return err
 		}
 
-		check(err)
+		applyAuth(ctx, req, entry, cfg)
+
+		if !cfg.LiveCookies {
+			jar.SetCookies(req.URL, req.Cookies())
+		}
+
+		var timer RoundTripTimer
+		var reqBody []byte
+		if capture != nil {
+			reqBody = requestBody(req)
+			req = timer.Trace(req)
+		}
 
-		jar.SetCookies(req.URL, req.Cookies())
+		tracker.request()
+		startTime := time.Now()
+		resp, err := doWithRetry(ctx, &client, req, cfg.Retry, tracker.retry)
+		latency := time.Since(startTime)
 
-		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Error(err)
+			emit(Event{Type: EventError, Phase: "replay", Index: i, URL: req.URL.String(), Method: req.Method, Latency: latency, Err: err})
+			if err := tracker.fail(); err != nil {
+				return err
+			}
+			continue
+		}
 
-		check(err)
+		latencies.Record(latency)
+		emit(Event{Type: EventRequestReplayed, Phase: "replay", Index: i, URL: req.URL.String(),
+			Method: req.Method, Status: resp.StatusCode, Latency: latency})
 
-		fmt.Printf("[%s,%v] URL: %s\n", entry.Request.Method, resp.StatusCode, entry.Request.URL)
+		fmt.Printf("[%s,%v] URL: %s\n", req.Method, resp.StatusCode, req.URL)
 
-		if resp != nil {
-			resp.Body.Close()
+		var body []byte
+		if capture != nil || needsBody(cfg.Assertions) || correlator.NeedsBody() {
+			body, err = io.ReadAll(resp.Body)
+			if err != nil {
+				log.Error(err)
+			}
 		}
 
+		resp.Body.Close()
+
+		if capture != nil {
+			timer.Done()
+			timings := timer.Timings()
+			capture.AddEntry(Entry{
+				StartedDateTime: timer.Started().Format(harDateTimeLayout),
+				Time:            totalTime(timings),
+				Request:         NewRequest(req, reqBody),
+				Response:        NewResponse(resp, body),
+				Timings:         timings,
+				ServerIPAddress: timer.ServerIPAddress(),
+			})
+		}
+
+		correlator.Extract(entry, resp, body)
+
+		result, err := checkAssertions(i, entry, resp, body, cfg.Assertions)
+
+		if err != nil {
+			return err
+		}
+
+		requestFailed := cfg.Retry.retryable(resp.StatusCode)
+
+		if result != nil {
+			results = append(results, *result)
+			if !result.Passed {
+				failed++
+				requestFailed = true
+				emit(Event{Type: EventAssertionFailed, Phase: "replay", Index: i, URL: req.URL.String(),
+					Method: req.Method, Status: resp.StatusCode, Message: strings.Join(result.Failures, "; ")})
+			}
+		}
+
+		if requestFailed {
+			if err := tracker.fail(); err != nil {
+				if len(cfg.Assertions) > 0 {
+					WriteAssertionReport(os.Stdout, results)
+				}
+				return err
+			}
+		}
+	}
+
+	if len(cfg.Assertions) > 0 {
+		WriteAssertionReport(os.Stdout, results)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d entries failed assertions", failed, len(results))
 	}
 
 	return nil
 }
+
+// writeCapture writes the traffic captured by a replay to path.
+func writeCapture(path string, har Har) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = Encode(file, har)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplayCapture(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "live "+r.URL.Path)
+	}))
+	defer server.Close()
+
+	har := Har{Log: Log{Entries: []Entry{
+		{
+			StartedDateTime: "2020-01-01T00:00:00.000Z",
+			Request:         Request{Method: "GET", URL: "https://example.com/a"},
+			Response:        Response{Status: 200, Content: Content{Text: "recorded a"}},
+		},
+		{
+			StartedDateTime: "2020-01-01T00:00:00.000Z",
+			Request: Request{
+				Method:   "POST",
+				URL:      "https://example.com/b",
+				PostData: PostData{MimeType: "text/plain", Text: "hello"},
+			},
+			Response: Response{Status: 200, Content: Content{Text: "recorded b"}},
+		},
+	}}}
+
+	var h bytes.Buffer
+	if err := json.NewEncoder(&h).Encode(har); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "replayed.har")
+	cfg := Config{Rewrites: []Rewrite{{BaseURL: server.URL}}, Capture: path}
+	if err := Replay(bufio.NewReader(&h), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	captured, err := Decode(bufio.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := captured.Log.Entries
+	if len(entries) != 2 {
+		t.Fatalf("captured %d entries", len(entries))
+	}
+	for i, path := range []string{"/a", "/b"} {
+		entry := entries[i]
+		if entry.Request.URL != server.URL+path {
+			t.Errorf("entry %d: URL = %s", i, entry.Request.URL)
+		}
+		if entry.Response.Content.Text != "live "+path {
+			t.Errorf("entry %d: body = %q", i, entry.Response.Content.Text)
+		}
+		if _, err := parseStartedDateTime(entry.StartedDateTime); err != nil {
+			t.Errorf("entry %d: startedDateTime %q: %v", i, entry.StartedDateTime, err)
+		}
+	}
+	if entries[1].Request.PostData.Text != "hello" {
+		t.Errorf("request body = %q", entries[1].Request.PostData.Text)
+	}
+}
@@ -0,0 +1,190 @@
+package hargo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxFilenameLength is the longest file name, in bytes, most file systems
+// accept.
+const maxFilenameLength = 255
+
+// windowsReservedNames cannot be used as file names on Windows, with or
+// without an extension.
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizeFilename turns one path segment into a file name valid on Linux,
+// macOS and Windows: separators, characters Windows rejects, control
+// characters and invalid UTF-8 become _, trailing dots and spaces are
+// removed, reserved device names are prefixed with _ and long names are
+// shortened, keeping their extension. "." and ".." become _.
+func sanitizeFilename(name string) string {
+	name = strings.ToValidUTF8(name, "_")
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "_"
+	}
+
+	base := strings.ToUpper(name)
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	if windowsReservedNames[strings.TrimRight(base, " ")] {
+		name = "_" + name
+	}
+
+	if len(name) > maxFilenameLength {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		stem := name[:maxFilenameLength-len(ext)]
+		for !utf8.ValidString(stem) {
+			stem = stem[:len(stem)-1]
+		}
+		name = stem + ext
+	}
+
+	return name
+}
+
+// entryFilename names a file of an entry after the host and path of its
+// URL followed by -suffix. The URL is shortened to keep the suffix, e.g.
+// the entry index, which makes names unique.
+func entryFilename(rawURL, suffix string) string {
+	name := suffix
+	if u, err := url.Parse(rawURL); err == nil {
+		stem := strings.Trim(unsafeFilenameChars.ReplaceAllString(u.Host+u.Path, "_"), "_")
+		if max := maxFilenameLength - len(suffix) - 1; len(stem) > max {
+			stem = stem[:max]
+		}
+		if stem != "" {
+			name = stem + "-" + suffix
+		}
+	}
+	return sanitizeFilename(name)
+}
+
+// sanitizeURLPath maps a decoded URL path to a relative file path. Dot
+// segments are resolved like browsers do, never above the root, both / and
+// \ separate segments, and every segment is sanitized. The result is empty
+// for the root path.
+func sanitizeURLPath(urlPath string) string {
+	var segments []string
+	for _, segment := range strings.FieldsFunc(urlPath, func(r rune) bool { return r == '/' || r == '\\' }) {
+		switch segment {
+		case ".":
+		case "..":
+			if len(segments) > 0 {
+				segments = segments[:len(segments)-1]
+			}
+		default:
+			segments = append(segments, sanitizeFilename(segment))
+		}
+	}
+	return filepath.Join(segments...)
+}
+
+// safeJoin joins rel to root and returns an error if the result would be
+// outside root.
+func safeJoin(root, rel string) (string, error) {
+	joined := filepath.Join(root, rel)
+	r, err := filepath.Rel(root, joined)
+	if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" {
+		return "", fmt.Errorf("path %s escapes %s", rel, root)
+	}
+	return joined, nil
+}
+
+// withQuery adds a query string to the file name at the end of filePath,
+// before its extension, as a short hash (QueryHash) or sanitized
+// (QuerySanitized). Other modes and empty queries leave filePath unchanged.
+func withQuery(filePath, rawQuery, mode string) string {
+	if rawQuery == "" {
+		return filePath
+	}
+
+	var suffix string
+	switch mode {
+	case QueryHash:
+		sum := sha256.Sum256([]byte(rawQuery))
+		suffix = "_" + hex.EncodeToString(sum[:4])
+	case QuerySanitized:
+		query, err := url.QueryUnescape(rawQuery)
+		if err != nil {
+			query = rawQuery
+		}
+		suffix = "_" + query
+	default:
+		return filePath
+	}
+
+	dir, name := filepath.Split(filePath)
+	ext := filepath.Ext(name)
+	return dir + sanitizeFilename(strings.TrimSuffix(name, ext)+suffix+ext)
+}
+
+// pathAllocator hands out unique file paths, so that no extracted file
+// overwrites another. Paths are compared case-insensitively, as on the
+// default file systems of Windows and macOS.
+type pathAllocator struct {
+	files map[string]bool
+	dirs  map[string]bool
+}
+
+func newPathAllocator() *pathAllocator {
+	return &pathAllocator{files: make(map[string]bool), dirs: make(map[string]bool)}
+}
+
+// allocate returns filePath, or if it is taken by a file or directory, the
+// first free name with _1, _2, ... added before the extension. A directory
+// in the path that is taken by a file is renamed the same way.
+func (a *pathAllocator) allocate(filePath string) string {
+	dir := a.allocateDir(filepath.Dir(filePath))
+	name := filepath.Base(filePath)
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+
+	candidate := filepath.Join(dir, name)
+	for i := 1; a.files[pathKey(candidate)] || a.dirs[pathKey(candidate)]; i++ {
+		candidate = filepath.Join(dir, stem+"_"+strconv.Itoa(i)+ext)
+	}
+	a.files[pathKey(candidate)] = true
+	return candidate
+}
+
+// allocateDir returns dir, or a renamed directory if a file took its name.
+func (a *pathAllocator) allocateDir(dir string) string {
+	if dir == "." || dir == filepath.Dir(dir) || a.dirs[pathKey(dir)] {
+		return dir
+	}
+
+	parent := a.allocateDir(filepath.Dir(dir))
+	name := filepath.Base(dir)
+	candidate := filepath.Join(parent, name)
+	for i := 1; a.files[pathKey(candidate)]; i++ {
+		candidate = filepath.Join(parent, name+"_"+strconv.Itoa(i))
+	}
+	a.dirs[pathKey(candidate)] = true
+	return candidate
+}
+
+func pathKey(p string) string {
+	return strings.ToLower(filepath.Clean(p))
+}
@@ -0,0 +1,99 @@
+package hargo
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	for name, expected := range map[string]string{
+		"image.png":       "image.png",
+		"":                "_",
+		".":               "_",
+		"..":              "_",
+		"a/b":             "a_b",
+		`a\b`:             "a_b",
+		"what?:*.txt":     "what___.txt",
+		"tab\there":       "tab_here",
+		"trailing. . ":    "trailing",
+		"CON":             "_CON",
+		"con.txt":         "_con.txt",
+		"com1.tar.gz":     "_com1.tar.gz",
+		"console.log":     "console.log",
+		"bad\xffutf8":     "bad_utf8",
+		"ünïcødé-名前.json": "ünïcødé-名前.json",
+	} {
+		if got := sanitizeFilename(name); got != expected {
+			t.Errorf("sanitizeFilename(%q) = %q, expected %q", name, got, expected)
+		}
+	}
+
+	long := sanitizeFilename(strings.Repeat("é", 200) + ".json")
+	if len(long) > maxFilenameLength || !strings.HasSuffix(long, "é.json") {
+		t.Errorf("long name not shortened correctly: %d bytes, %q", len(long), long[len(long)-10:])
+	}
+}
+
+func TestSanitizeURLPath(t *testing.T) {
+	for urlPath, expected := range map[string]string{
+		"/":                        "",
+		"/a/b/c.js":                "a/b/c.js",
+		"/../../etc/passwd":        "etc/passwd",
+		"/a/../../b":               "b",
+		"/a/./b//c":                "a/b/c",
+		`/a\..\..\windows\win.ini`: "windows/win.ini",
+		"/C:/x":                    "C_/x",
+		"/a/b?/nul":                "a/b_/_nul",
+	} {
+		if got := filepath.ToSlash(sanitizeURLPath(urlPath)); got != expected {
+			t.Errorf("sanitizeURLPath(%q) = %q, expected %q", urlPath, got, expected)
+		}
+	}
+}
+
+func TestSafeJoin(t *testing.T) {
+	root := filepath.Join("out", "dir")
+	if p, err := safeJoin(root, filepath.Join("example.com", "a.js")); err != nil || p != filepath.Join(root, "example.com", "a.js") {
+		t.Errorf("safeJoin = %s, %v", p, err)
+	}
+	for _, rel := range []string{"..", filepath.Join("..", "x"), filepath.Join("a", "..", "..", "x")} {
+		if _, err := safeJoin(root, rel); err == nil {
+			t.Errorf("safeJoin(%s) should fail", rel)
+		}
+	}
+}
+
+func TestWithQuery(t *testing.T) {
+	for _, test := range []struct {
+		path, query, mode, expected string
+	}{
+		{"api/items", "id=1", "", "api/items"},
+		{"api/items", "", QueryHash, "api/items"},
+		{"api/items", "id=1", QueryHash, "api/items_d9fc91d4"},
+		{"api/items.json", "id=1&q=a%2Fb", QuerySanitized, "api/items_id=1&q=a_b.json"},
+		{"items", "q=what?", QuerySanitized, "items_q=what_"},
+	} {
+		if got := filepath.ToSlash(withQuery(filepath.FromSlash(test.path), test.query, test.mode)); got != test.expected {
+			t.Errorf("withQuery(%s, %s, %s) = %s, expected %s", test.path, test.query, test.mode, got, test.expected)
+		}
+	}
+}
+
+func TestPathAllocator(t *testing.T) {
+	a := newPathAllocator()
+	for _, test := range []struct{ path, expected string }{
+		{"out/example.com/app.js", "out/example.com/app.js"},
+		{"out/example.com/app.js", "out/example.com/app_1.js"},
+		{"out/example.com/APP.js", "out/example.com/APP_2.js"},
+		{"out/example.com/docs", "out/example.com/docs"},
+		{"out/example.com/docs/a.js", "out/example.com/docs_1/a.js"},
+		{"out/example.com/docs/b.js", "out/example.com/docs_1/b.js"},
+		{"out/example.com/docs_1", "out/example.com/docs_1_1"},
+		{"out/example.com", "out/example_1.com"},
+	} {
+		if got := filepath.ToSlash(a.allocate(filepath.FromSlash(test.path))); got != test.expected {
+			t.Errorf("allocate(%s) = %s, expected %s", test.path, got, test.expected)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Score categories.
+const (
+	ScoreCaching     = "caching"
+	ScoreCompression = "compression"
+	ScoreRequests    = "requests"
+	ScorePayload     = "payload"
+	ScoreRedirects   = "redirects"
+)
+
+// Thresholds of the linear scores: a page scores 100 up to the good value
+// and 0 from the poor value on.
+const (
+	goodRequestsPerPage  = 50
+	poorRequestsPerPage  = 150
+	goodBytesPerPage     = 1600 << 10
+	poorBytesPerPage     = 5000 << 10
+	poorRedirectsPerPage = 4
+	// minCompressibleSize is the size below which compressing a response
+	// is not worth it.
+	minCompressibleSize = 1400
+	// largeAssetSize is the size from which assets are recommended to be
+	// made smaller when the payload is too heavy.
+	largeAssetSize = 100 << 10
+)
+
+// ScoreCategory is the score of one aspect of a .har file, from 0 to 100.
+type ScoreCategory struct {
+	Name   string `json:"name"`
+	Score  int    `json:"score"`
+	Grade  string `json:"grade"`
+	Detail string `json:"detail"`
+}
+
+// Recommendation is a concrete change improving the score of a category.
+type Recommendation struct {
+	Category string `json:"category"`
+	Text     string `json:"text"`
+	// Savings is the estimated number of bytes saved, if known.
+	Savings int64 `json:"savings,omitempty"`
+}
+
+// ScoreReport is a quick, Lighthouse-style summary of a .har file.
+type ScoreReport struct {
+	Score           int              `json:"score"`
+	Grade           string           `json:"grade"`
+	Categories      []ScoreCategory  `json:"categories"`
+	Recommendations []Recommendation `json:"recommendations"`
+}
+
+// Grade returns the letter grade of a score: A from 90, B from 80, C from
+// 70, D from 60 and F below.
+func Grade(score int) string {
+	switch {
+	case score >= 90:
+		return "A"
+	case score >= 80:
+		return "B"
+	case score >= 70:
+		return "C"
+	case score >= 60:
+		return "D"
+	}
+	return "F"
+}
+
+// ScoreHar scores caching, compression, request count, payload weight and
+// redirects of a .har file with simple heuristics and collects the
+// recommendations that would improve them, worst category first:
+//   - caching is the share of the bytes of cacheable GET responses a repeat
+//     view saves, see AnalyzeCaching
+//   - compression is the share of the bytes of text responses that cannot
+//     be saved by compressing them with gzip
+//   - requests, payload and redirects fall linearly from 100 to 0 between
+//     a good and a poor number of requests, bytes received and redirects
+//     per page
+//
+// The overall score is the mean of the categories.
+func ScoreHar(har Har) ScoreReport {
+	pages := len(har.Log.Pages)
+	if pages == 0 {
+		pages = 1
+	}
+
+	var report ScoreReport
+	var recommendations [][]Recommendation
+	add := func(name string, score float64, detail string, recs []Recommendation) {
+		s := int(math.Round(math.Max(0, math.Min(100, score))))
+		report.Categories = append(report.Categories, ScoreCategory{Name: name, Score: s, Grade: Grade(s), Detail: detail})
+		sort.SliceStable(recs, func(i, j int) bool { return recs[i].Savings > recs[j].Savings })
+		recommendations = append(recommendations, recs)
+	}
+
+	caching := AnalyzeCaching(har, CachingOptions{})
+	var recs []Recommendation
+	for _, o := range caching.Offenders() {
+		recs = append(recs, Recommendation{Category: ScoreCaching, Savings: o.Bytes - o.Saved,
+			Text: fmt.Sprintf("Cache %s for longer (%s lost on repeat views)", shortURL(o.URL), formatBytes(o.Bytes-o.Saved))})
+	}
+	add(ScoreCaching, ratioScore(caching.Saved, caching.Bytes),
+		fmt.Sprintf("%s of %s reused on a repeat view", formatBytes(caching.Saved), formatBytes(caching.Bytes)), recs)
+
+	var compressible, wasted int64
+	recs = nil
+	for _, entry := range har.Log.Entries {
+		size, saving := compressionSaving(entry)
+		compressible += size
+		wasted += saving
+		if saving > 0 {
+			recs = append(recs, Recommendation{Category: ScoreCompression, Savings: saving,
+				Text: fmt.Sprintf("Compress %s (%s smaller with gzip)", shortURL(entry.Request.URL), formatBytes(saving))})
+		}
+	}
+	add(ScoreCompression, ratioScore(compressible-wasted, compressible),
+		fmt.Sprintf("%s of %s of text could be saved", formatBytes(wasted), formatBytes(compressible)), recs)
+
+	stats := ComputeStats(har)
+	recs = nil
+	perPage := float64(stats.Total.Requests) / float64(pages)
+	if perPage > goodRequestsPerPage {
+		domain, most := "", 0
+		for d, c := range stats.ByDomain {
+			if c.Requests > most || c.Requests == most && d < domain {
+				domain, most = d, c.Requests
+			}
+		}
+		recs = append(recs, Recommendation{Category: ScoreRequests,
+			Text: fmt.Sprintf("Bundle or drop requests: %.0f per page, %d of them to %s", perPage, most, domain)})
+	}
+	add(ScoreRequests, linearScore(perPage, goodRequestsPerPage, poorRequestsPerPage),
+		fmt.Sprintf("%.0f requests per page", perPage), recs)
+
+	recs = nil
+	bytesPerPage := float64(stats.Total.Received) / float64(pages)
+	if bytesPerPage > goodBytesPerPage {
+		for _, entry := range har.Log.Entries {
+			if size := responseBytes(entry.Response); size >= largeAssetSize {
+				recs = append(recs, Recommendation{Category: ScorePayload, Savings: size,
+					Text: fmt.Sprintf("Reduce or defer %s (%s)", shortURL(entry.Request.URL), formatBytes(size))})
+			}
+		}
+	}
+	add(ScorePayload, linearScore(bytesPerPage, goodBytesPerPage, poorBytesPerPage),
+		fmt.Sprintf("%s per page", formatBytes(int64(bytesPerPage))), recs)
+
+	recs = nil
+	for _, entry := range har.Log.Entries {
+		if location := redirectLocation(entry); location != "" {
+			recs = append(recs, Recommendation{Category: ScoreRedirects,
+				Text: fmt.Sprintf("Avoid the redirect from %s to %s", shortURL(entry.Request.URL), shortURL(location))})
+		}
+	}
+	redirects := float64(len(recs)) / float64(pages)
+	add(ScoreRedirects, linearScore(redirects, 0, poorRedirectsPerPage),
+		fmt.Sprintf("%.1f redirects per page", redirects), recs)
+
+	// worst categories first, keeping their order on ties
+	order := make([]int, len(report.Categories))
+	total := 0
+	for i, c := range report.Categories {
+		order[i] = i
+		total += c.Score
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return report.Categories[order[i]].Score < report.Categories[order[j]].Score
+	})
+	for _, i := range order {
+		report.Recommendations = append(report.Recommendations, recommendations[i]...)
+	}
+	report.Score = int(math.Round(float64(total) / float64(len(report.Categories))))
+	report.Grade = Grade(report.Score)
+	return report
+}
+
+// ratioScore returns part of total in percent, or 100 if total is zero.
+func ratioScore(part, total int64) float64 {
+	if total <= 0 {
+		return 100
+	}
+	return float64(part) / float64(total) * 100
+}
+
+// linearScore falls from 100 at good to 0 at poor.
+func linearScore(value, good, poor float64) float64 {
+	if value <= good {
+		return 100
+	}
+	return (poor - value) / (poor - good) * 100
+}
+
+// compressionSaving returns the bytes received for an uncompressed text
+// response and the bytes gzip would save, estimated at 70% if the body was
+// not recorded. Both are zero for other responses.
+func compressionSaving(entry Entry) (int64, int64) {
+	resp := entry.Response
+	switch getTypeDirectory(resp.Content.MimeType) {
+	case "html", "css", "javascript", "json", "text":
+	default:
+		mimeType := strings.ToLower(resp.Content.MimeType)
+		if !strings.Contains(mimeType, "xml") && !strings.Contains(mimeType, "svg") {
+			return 0, 0
+		}
+	}
+	size := responseBytes(resp)
+	if size < minCompressibleSize {
+		return 0, 0
+	}
+	if resp.Content.Compression > 0 || recordedHeader(resp.Headers, "Content-Encoding") != "" {
+		return size, 0
+	}
+
+	body, err := decodeContent(resp.Content)
+	if err != nil || len(body) == 0 {
+		return size, size * 7 / 10
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(body)
+	zw.Close()
+	if saving := int64(len(body) - buf.Len()); saving > 0 {
+		return size, saving
+	}
+	return size, 0
+}
+
+// redirectLocation returns the target of a redirect response, resolved
+// against the request URL, or "" for other responses.
+func redirectLocation(entry Entry) string {
+	switch entry.Response.Status {
+	case 301, 302, 303, 307, 308:
+	default:
+		return ""
+	}
+	location := entry.Response.RedirectURL
+	if location == "" {
+		location = recordedHeader(entry.Response.Headers, "Location")
+	}
+	if location == "" {
+		return ""
+	}
+	if base, err := url.Parse(entry.Request.URL); err == nil {
+		if ref, err := base.Parse(location); err == nil {
+			return ref.String()
+		}
+	}
+	return location
+}
+
+// Score prints the scores and grades of a .har file and its top
+// recommendations to w.
+func Score(r *bufio.Reader, w io.Writer, top int) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	report := ScoreHar(har)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Score\t%s (%d)\t\n\n", report.Grade, report.Score)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", "Category", "Score", "Grade", "Detail")
+	for _, c := range report.Categories {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t\n", c.Name, c.Score, c.Grade, c.Detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(report.Recommendations) > 0 && top > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Recommendations")
+		for i, rec := range report.Recommendations {
+			if i == top {
+				break
+			}
+			fmt.Fprintf(w, "%d. [%s] %s\n", i+1, rec.Category, rec.Text)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGrade(t *testing.T) {
+	for score, want := range map[int]string{100: "A", 90: "A", 89: "B", 75: "C", 60: "D", 59: "F", 0: "F"} {
+		if got := Grade(score); got != want {
+			t.Errorf("%d: got %s, want %s", score, got, want)
+		}
+	}
+}
+
+func TestScoreHar(t *testing.T) {
+	html := strings.Repeat("<p>hello</p>", 250)
+	har := Har{Log: Log{
+		Pages: []Page{{ID: "page_1"}},
+		Entries: []Entry{
+			{Request: Request{Method: "GET", URL: "http://example.com/old"},
+				Response: Response{Status: 301, HeadersSize: 100, BodySize: 0, Headers: []NVP{{Name: "Location", Value: "/"}}}},
+			{Request: Request{Method: "GET", URL: "http://example.com/"},
+				Response: Response{Status: 200, HeadersSize: 100, BodySize: -1, Headers: []NVP{{Name: "Cache-Control", Value: "no-store"}},
+					Content: Content{MimeType: "text/html", Size: len(html), Text: html}}},
+			{Request: Request{Method: "GET", URL: "http://example.com/app.js"},
+				Response: Response{Status: 200, HeadersSize: 100, BodySize: 6900, Content: Content{MimeType: "application/javascript"},
+					Headers: []NVP{{Name: "Cache-Control", Value: "max-age=31536000"}, {Name: "Content-Encoding", Value: "br"}}}},
+		},
+	}}
+
+	report := ScoreHar(har)
+
+	var got []string
+	for _, c := range report.Categories {
+		got = append(got, c.Name+" "+c.Grade)
+	}
+	// the html is neither cached nor compressed, unlike the script, and one
+	// redirect costs a quarter of the score
+	if expected := "caching D, compression C, requests A, payload A, redirects C"; strings.Join(got, ", ") != expected {
+		t.Errorf("got %s, want %s", strings.Join(got, ", "), expected)
+	}
+	if c := report.Categories[0]; c.Score != 69 || c.Detail != "6.8 KiB of 10.0 KiB reused on a repeat view" {
+		t.Errorf("got %+v", c)
+	}
+	if c := report.Categories[1]; c.Score != 71 || c.Detail != "2.9 KiB of 9.9 KiB of text could be saved" {
+		t.Errorf("got %+v", c)
+	}
+	if c := report.Categories[4]; c.Score != 75 || c.Detail != "1.0 redirects per page" {
+		t.Errorf("got %+v", c)
+	}
+	if report.Score != 83 || report.Grade != "B" {
+		t.Errorf("got %d %s", report.Score, report.Grade)
+	}
+
+	// the worst category comes first
+	var recs []string
+	for _, rec := range report.Recommendations {
+		recs = append(recs, rec.Text)
+	}
+	expected := []string{
+		"Cache http://example.com/ for longer (3.0 KiB lost on repeat views)",
+		"Cache http://example.com/old for longer (100 B lost on repeat views)",
+		"Compress http://example.com/ (2.9 KiB smaller with gzip)",
+		"Avoid the redirect from http://example.com/old to http://example.com/",
+	}
+	if strings.Join(recs, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(recs, "\n"), strings.Join(expected, "\n"))
+	}
+
+	data, _ := json.Marshal(har)
+	var out bytes.Buffer
+	if err := Score(bufio.NewReader(bytes.NewReader(data)), &out, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "1. [caching] Cache http://example.com/ ") || strings.Contains(out.String(), "2. ") {
+		t.Errorf("got %s", out.String())
+	}
+}
+
+func TestScoreHeavyPage(t *testing.T) {
+	var entries []Entry
+	for i := 0; i < 100; i++ {
+		entries = append(entries, Entry{Request: Request{Method: "POST", URL: "https://api.example.com/"},
+			Response: Response{Status: 200, HeadersSize: 0, BodySize: 35 << 10, Content: Content{MimeType: "image/png"}}})
+	}
+	report := ScoreHar(Har{Log: Log{Entries: entries}})
+	if c := report.Categories[2]; c.Score != 50 || c.Detail != "100 requests per page" {
+		t.Errorf("got %+v", c)
+	}
+	if c := report.Categories[3]; c.Score != 44 || c.Detail != "3.4 MiB per page" {
+		t.Errorf("got %+v", c)
+	}
+	// assets below 100 KiB are not worth reporting one by one
+	if len(report.Recommendations) != 1 || report.Recommendations[0].Text != "Bundle or drop requests: 100 per page, 100 of them to api.example.com" {
+		t.Errorf("got %+v", report.Recommendations)
+	}
+}
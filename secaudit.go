@@ -0,0 +1,263 @@
+package hargo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Severities of security findings, from most to least severe.
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+)
+
+var severityRank = map[string]int{SeverityHigh: 3, SeverityMedium: 2, SeverityLow: 1}
+
+// minHSTSMaxAge is the HSTS max-age (180 days) below which the policy is
+// reported as weak.
+const minHSTSMaxAge = 180 * 24 * 60 * 60
+
+// SecurityFinding is a missing or weak security control found in a .har file.
+type SecurityFinding struct {
+	// Entry is the index of the entry in the .har file.
+	Entry    int    `json:"entry"`
+	URL      string `json:"url"`
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// SecAudit inspects every HTML document response for missing or weak
+// security headers (Content-Security-Policy, Strict-Transport-Security,
+// X-Frame-Options, X-Content-Type-Options and Referrer-Policy) and reports
+// subresources loaded over plain HTTP by HTTPS pages (mixed content).
+// Findings are ordered by severity, then by entry.
+func SecAudit(har Har) []SecurityFinding {
+	var findings []SecurityFinding
+	secureDocuments := make(map[string]bool)
+
+	for i, entry := range har.Log.Entries {
+		if isDocument(entry) {
+			if strings.HasPrefix(entry.Request.URL, "https:") {
+				secureDocuments[entry.Pageref] = true
+			}
+			for _, f := range auditHeaders(entry) {
+				f.Entry, f.URL = i, entry.Request.URL
+				findings = append(findings, f)
+			}
+			continue
+		}
+
+		if secureDocuments[entry.Pageref] && strings.HasPrefix(entry.Request.URL, "http:") {
+			f := SecurityFinding{Entry: i, URL: entry.Request.URL, Check: "mixed-content"}
+			if isActiveContent(entry) {
+				f.Severity, f.Message = SeverityHigh, "active content (scripts, styles, frames, requests) loaded over HTTP by an HTTPS page"
+			} else {
+				f.Severity, f.Message = SeverityMedium, "passive content (images, media) loaded over HTTP by an HTTPS page"
+			}
+			findings = append(findings, f)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityRank[findings[i].Severity] > severityRank[findings[j].Severity]
+	})
+
+	return findings
+}
+
+// isDocument reports whether an entry is an HTML document (page or frame).
+func isDocument(e Entry) bool {
+	if e.ResourceType != "" {
+		return e.ResourceType == "document" || e.ResourceType == "iframe"
+	}
+	return e.Response.Status >= 200 && e.Response.Status < 300 &&
+		strings.Contains(strings.ToLower(e.Response.Content.MimeType), "html")
+}
+
+// isActiveContent reports whether a subresource can change the page, which
+// browsers block when it is loaded insecurely.
+func isActiveContent(e Entry) bool {
+	switch e.ResourceType {
+	case "image", "media":
+		return false
+	case "":
+	default:
+		return true
+	}
+	mimeType := strings.ToLower(e.Response.Content.MimeType)
+	return !(strings.HasPrefix(mimeType, "image/") || strings.HasPrefix(mimeType, "audio/") || strings.HasPrefix(mimeType, "video/"))
+}
+
+// auditHeaders checks the security headers of a document response.
+func auditHeaders(e Entry) []SecurityFinding {
+	var findings []SecurityFinding
+	add := func(check, severity, message string) {
+		findings = append(findings, SecurityFinding{Check: check, Severity: severity, Message: message})
+	}
+	headers := e.Response.Headers
+
+	if strings.HasPrefix(e.Request.URL, "http:") {
+		add("https", SeverityHigh, "document served over plain HTTP")
+	}
+
+	csp := recordedHeader(headers, "Content-Security-Policy")
+	directives := parseCSP(csp)
+	switch {
+	case csp == "" && recordedHeader(headers, "Content-Security-Policy-Report-Only") != "":
+		add("csp", SeverityLow, "Content-Security-Policy is only report-only")
+	case csp == "":
+		add("csp", SeverityMedium, "missing Content-Security-Policy")
+	default:
+		scripts, ok := directives["script-src"]
+		if !ok {
+			scripts, ok = directives["default-src"]
+		}
+		switch {
+		case !ok:
+			add("csp", SeverityMedium, "Content-Security-Policy does not restrict scripts (no script-src or default-src)")
+		case containsSource(scripts, "*") || containsSource(scripts, "http:") || containsSource(scripts, "https:"):
+			add("csp", SeverityMedium, "Content-Security-Policy allows scripts from any host")
+		case containsSource(scripts, "'unsafe-inline'") && !hasNonceOrHash(scripts):
+			add("csp", SeverityLow, "Content-Security-Policy allows inline scripts ('unsafe-inline')")
+		}
+		if containsSource(scripts, "'unsafe-eval'") {
+			add("csp", SeverityLow, "Content-Security-Policy allows eval ('unsafe-eval')")
+		}
+	}
+
+	if strings.HasPrefix(e.Request.URL, "https:") {
+		hsts := recordedHeader(headers, "Strict-Transport-Security")
+		if hsts == "" {
+			add("hsts", SeverityMedium, "missing Strict-Transport-Security")
+		} else if maxAge := hstsMaxAge(hsts); maxAge < minHSTSMaxAge {
+			add("hsts", SeverityLow, fmt.Sprintf("Strict-Transport-Security max-age %d is shorter than 180 days", maxAge))
+		}
+	}
+
+	_, frameAncestors := directives["frame-ancestors"]
+	switch xfo := strings.ToUpper(strings.TrimSpace(recordedHeader(headers, "X-Frame-Options"))); {
+	case xfo == "" && !frameAncestors:
+		add("x-frame-options", SeverityMedium, "missing X-Frame-Options (or CSP frame-ancestors), page can be framed (clickjacking)")
+	case xfo != "" && xfo != "DENY" && xfo != "SAMEORIGIN" && !frameAncestors:
+		add("x-frame-options", SeverityLow, "invalid X-Frame-Options "+xfo)
+	}
+
+	if xcto := recordedHeader(headers, "X-Content-Type-Options"); !strings.EqualFold(strings.TrimSpace(xcto), "nosniff") {
+		add("x-content-type-options", SeverityLow, "missing X-Content-Type-Options: nosniff")
+	}
+
+	switch rp := strings.ToLower(recordedHeader(headers, "Referrer-Policy")); {
+	case rp == "":
+		add("referrer-policy", SeverityLow, "missing Referrer-Policy")
+	case strings.Contains(rp, "unsafe-url"):
+		add("referrer-policy", SeverityMedium, "Referrer-Policy unsafe-url leaks full URLs to other origins")
+	case strings.Contains(rp, "no-referrer-when-downgrade"):
+		add("referrer-policy", SeverityLow, "Referrer-Policy no-referrer-when-downgrade leaks full URLs to other origins")
+	}
+
+	return findings
+}
+
+// parseCSP splits a Content-Security-Policy into its directives. The first
+// occurrence of a directive wins, as in browsers.
+func parseCSP(csp string) map[string][]string {
+	directives := make(map[string][]string)
+	for _, d := range strings.Split(csp, ";") {
+		fields := strings.Fields(d)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.ToLower(fields[0])
+		if _, ok := directives[name]; !ok {
+			directives[name] = fields[1:]
+		}
+	}
+	return directives
+}
+
+func containsSource(sources []string, source string) bool {
+	for _, s := range sources {
+		if strings.EqualFold(s, source) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNonceOrHash reports whether a source list uses nonces or hashes,
+// which make browsers ignore 'unsafe-inline'.
+func hasNonceOrHash(sources []string) bool {
+	for _, s := range sources {
+		s = strings.ToLower(s)
+		if strings.HasPrefix(s, "'nonce-") || strings.HasPrefix(s, "'sha256-") ||
+			strings.HasPrefix(s, "'sha384-") || strings.HasPrefix(s, "'sha512-") {
+			return true
+		}
+	}
+	return false
+}
+
+// hstsMaxAge returns the max-age directive of a Strict-Transport-Security
+// header, 0 if it is missing or invalid.
+func hstsMaxAge(hsts string) int {
+	for _, d := range strings.Split(hsts, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+		if strings.EqualFold(strings.TrimSpace(name), "max-age") {
+			maxAge, _ := strconv.Atoi(strings.Trim(strings.TrimSpace(value), `"`))
+			return maxAge
+		}
+	}
+	return 0
+}
+
+// SecAuditFile prints the security findings of a .har file to w and
+// reports whether none of them is at least as severe as failOn. An empty
+// failOn never fails.
+func SecAuditFile(r *bufio.Reader, w io.Writer, failOn string) (bool, error) {
+	har, err := Decode(r)
+	if err != nil {
+		return false, err
+	}
+	if failOn != "" && severityRank[failOn] == 0 {
+		return false, fmt.Errorf("unknown severity %s", failOn)
+	}
+
+	findings := SecAudit(har)
+	counts := make(map[string]int)
+	passed := true
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", "Severity", "Check", "URL", "Finding")
+	for _, f := range findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", f.Severity, f.Check, shortURL(f.URL), f.Message)
+		counts[f.Severity]++
+		if failOn != "" && severityRank[f.Severity] >= severityRank[failOn] {
+			passed = false
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return false, err
+	}
+
+	fmt.Fprintf(w, "\n%d findings: %d high, %d medium, %d low\n", len(findings),
+		counts[SeverityHigh], counts[SeverityMedium], counts[SeverityLow])
+	return passed, nil
+}
+
+// shortURL strips the query string of a URL for display.
+func shortURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return rawURL
+	}
+	u.RawQuery = ""
+	return u.String() + "?…"
+}
@@ -0,0 +1,96 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSecAudit(t *testing.T) {
+	document := func(url string, headers ...NVP) Entry {
+		return Entry{
+			Pageref:  "page_1",
+			Request:  Request{URL: url},
+			Response: Response{Status: 200, Headers: headers, Content: Content{MimeType: "text/html; charset=utf-8"}},
+		}
+	}
+	resource := func(url, mimeType string) Entry {
+		return Entry{Pageref: "page_1", Request: Request{URL: url}, Response: Response{Status: 200, Content: Content{MimeType: mimeType}}}
+	}
+
+	secure := document("https://example.com/",
+		NVP{Name: "content-security-policy", Value: "default-src 'self'; frame-ancestors 'none'"},
+		NVP{Name: "strict-transport-security", Value: "max-age=31536000; includeSubDomains"},
+		NVP{Name: "x-content-type-options", Value: "nosniff"},
+		NVP{Name: "referrer-policy", Value: "strict-origin-when-cross-origin"})
+	if findings := SecAudit(Har{Log: Log{Entries: []Entry{secure}}}); len(findings) != 0 {
+		t.Errorf("expected no findings, got %+v", findings)
+	}
+
+	weak := document("https://example.com/weak",
+		NVP{Name: "Content-Security-Policy", Value: "script-src 'self' 'unsafe-inline' 'unsafe-eval'"},
+		NVP{Name: "Strict-Transport-Security", Value: "max-age=3600"},
+		NVP{Name: "X-Frame-Options", Value: "ALLOW-FROM https://other.example"},
+		NVP{Name: "Referrer-Policy", Value: "unsafe-url"})
+	har := Har{Log: Log{Entries: []Entry{
+		weak,
+		resource("http://cdn.example.com/app.js", "application/javascript"),
+		resource("http://cdn.example.com/logo.png", "image/png"),
+		resource("https://cdn.example.com/style.css", "text/css"),
+		document("http://plain.example.com/"),
+	}}}
+
+	var got []string
+	for _, f := range SecAudit(har) {
+		got = append(got, f.Severity+" "+f.Check+" "+f.URL)
+	}
+	expected := []string{
+		"high mixed-content http://cdn.example.com/app.js",
+		"high https http://plain.example.com/",
+		"medium referrer-policy https://example.com/weak",
+		"medium mixed-content http://cdn.example.com/logo.png",
+		"medium csp http://plain.example.com/",
+		"medium x-frame-options http://plain.example.com/",
+		"low csp https://example.com/weak",
+		"low csp https://example.com/weak",
+		"low hsts https://example.com/weak",
+		"low x-frame-options https://example.com/weak",
+		"low x-content-type-options https://example.com/weak",
+		"low x-content-type-options http://plain.example.com/",
+		"low referrer-policy http://plain.example.com/",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("findings:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestSecAuditFile(t *testing.T) {
+	har := Har{Log: Log{Entries: []Entry{{
+		Request:  Request{URL: "https://example.com/"},
+		Response: Response{Status: 200, Content: Content{MimeType: "text/html"}},
+	}}}}
+	data, err := json.Marshal(har)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for failOn, expected := range map[string]bool{"": true, "high": true, "medium": false, "low": false} {
+		var out bytes.Buffer
+		passed, err := SecAuditFile(bufio.NewReader(bytes.NewReader(data)), &out, failOn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if passed != expected {
+			t.Errorf("fail-on %q: passed = %v, expected %v", failOn, passed, expected)
+		}
+		if !strings.Contains(out.String(), "5 findings: 0 high, 3 medium, 2 low") {
+			t.Errorf("unexpected summary:\n%s", out.String())
+		}
+	}
+
+	if _, err := SecAuditFile(bufio.NewReader(bytes.NewReader(data)), &bytes.Buffer{}, "critical"); err == nil {
+		t.Error("expected error for unknown severity")
+	}
+}
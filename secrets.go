@@ -0,0 +1,196 @@
+package hargo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+)
+
+// SecretDetector finds one kind of sensitive data. If Pattern has a capture
+// group, the first group is the secret and the rest of the match is context
+// (e.g. the "Bearer " prefix).
+type SecretDetector struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Valid optionally rejects false positives, e.g. by checksum.
+	Valid func(secret string) bool
+}
+
+// DefaultSecretDetectors returns the built-in detectors: JSON Web Tokens,
+// AWS access key IDs and secret keys, bearer tokens, email addresses and
+// credit card numbers (Luhn checked).
+func DefaultSecretDetectors() []SecretDetector {
+	return []SecretDetector{
+		{Name: "jwt", Pattern: regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)},
+		{Name: "aws-access-key", Pattern: regexp.MustCompile(`\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`)},
+		{Name: "aws-secret-key", Pattern: regexp.MustCompile(`(?i)aws.{0,20}secret.{0,20}?["'=:\s]+([A-Za-z0-9/+]{40})\b`)},
+		{Name: "bearer-token", Pattern: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]{8,}=*)`)},
+		{Name: "email", Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+		{Name: "credit-card", Pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), Valid: luhnValid},
+	}
+}
+
+// ParseSecretRule parses a user detector of the form name=regexp.
+func ParseSecretRule(rule string) (SecretDetector, error) {
+	name, pattern, ok := strings.Cut(rule, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || pattern == "" {
+		return SecretDetector{}, fmt.Errorf("invalid rule %q, expected name=regexp", rule)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return SecretDetector{}, fmt.Errorf("invalid rule %s: %v", name, err)
+	}
+	return SecretDetector{Name: name, Pattern: re}, nil
+}
+
+// LoadSecretRules reads user detectors, one name=regexp rule per line.
+// Blank lines and lines starting with # are ignored.
+func LoadSecretRules(r io.Reader) ([]SecretDetector, error) {
+	var detectors []SecretDetector
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		d, err := ParseSecretRule(line)
+		if err != nil {
+			return nil, err
+		}
+		detectors = append(detectors, d)
+	}
+	return detectors, scanner.Err()
+}
+
+// SecretMatch locates sensitive data in a .har file. Start and End are the
+// byte offsets of the secret in the value at Location; for base64 encoded
+// response content they refer to the decoded text.
+type SecretMatch struct {
+	// Entry is the index of the entry in the .har file.
+	Entry    int    `json:"entry"`
+	Detector string `json:"detector"`
+	// Location is the field containing the secret, e.g. request.url,
+	// request.headers[Authorization] or response.content.text.
+	Location string `json:"location"`
+	Value    string `json:"value"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+// ScanSecrets runs the detectors over the URLs, headers, cookies, query
+// strings, post data and text response content of every entry.
+func ScanSecrets(har Har, detectors []SecretDetector) []SecretMatch {
+	var matches []SecretMatch
+	for i, e := range har.Log.Entries {
+		matches = append(matches, scanEntrySecrets(i, e, detectors)...)
+	}
+	return matches
+}
+
+// scanEntrySecrets returns the secrets found in entry i.
+func scanEntrySecrets(i int, e Entry, detectors []SecretDetector) []SecretMatch {
+	var matches []SecretMatch
+	scan := func(location, value string) {
+		for _, d := range detectors {
+			for _, m := range d.Pattern.FindAllStringSubmatchIndex(value, -1) {
+				start, end := m[0], m[1]
+				if len(m) >= 4 && m[2] >= 0 {
+					start, end = m[2], m[3]
+				}
+				secret := value[start:end]
+				if d.Valid != nil && !d.Valid(secret) {
+					continue
+				}
+				matches = append(matches, SecretMatch{Entry: i, Detector: d.Name, Location: location, Value: secret, Start: start, End: end})
+			}
+		}
+	}
+
+	scan("request.url", e.Request.URL)
+	for _, h := range e.Request.Headers {
+		scan("request.headers["+h.Name+"]", h.Value)
+	}
+	for _, c := range e.Request.Cookies {
+		scan("request.cookies["+c.Name+"]", c.Value)
+	}
+	for _, q := range e.Request.QueryString {
+		scan("request.queryString["+q.Name+"]", q.Value)
+	}
+	scan("request.postData.text", e.Request.PostData.Text)
+	for _, p := range e.Request.PostData.Params {
+		scan("request.postData.params["+p.Name+"]", p.Value)
+	}
+	for _, h := range e.Response.Headers {
+		scan("response.headers["+h.Name+"]", h.Value)
+	}
+	for _, c := range e.Response.Cookies {
+		scan("response.cookies["+c.Name+"]", c.Value)
+	}
+	if e.Response.Content.Text != "" && isTextMimeType(e.Response.Content.MimeType) {
+		if body, err := e.DecodedBody(); err == nil {
+			scan("response.content.text", string(body))
+		}
+	}
+
+	return matches
+}
+
+// luhnValid reports whether the digits of a card number pass the Luhn
+// checksum.
+func luhnValid(number string) bool {
+	sum, n := 0, 0
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+// maskSecret hides all but the first four characters of a secret.
+func maskSecret(secret string) string {
+	if len(secret) <= 8 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:4] + strings.Repeat("*", 8) + fmt.Sprintf(" (%d chars)", len(secret))
+}
+
+// ScanSecretsFile prints the secrets found in a .har file to w, masked, and
+// returns the number of matches.
+func ScanSecretsFile(r *bufio.Reader, w io.Writer, detectors []SecretDetector) (int, error) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", "Entry", "Detector", "Location", "Match")
+
+	count, i := 0, 0
+	err := forEachEntry(r, func(e Entry) error {
+		for _, m := range scanEntrySecrets(i, e, detectors) {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t\n", m.Entry, m.Detector, m.Location, maskSecret(m.Value))
+			count++
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := tw.Flush(); err != nil {
+		return 0, err
+	}
+
+	fmt.Fprintf(w, "\n%d matches in %d entries\n", count, i)
+	return count, nil
+}
@@ -0,0 +1,132 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Selection restricts the entries commands operate on to a time window
+// and/or a range of entries. The zero Selection selects everything.
+type Selection struct {
+	// From and To bound the startedDateTime of selected entries, inclusive.
+	// A zero time leaves that side open.
+	From, To time.Time
+	// First and Last are the 1-based positions of the first and last
+	// selected entries in the .har file, inclusive. Zero leaves that side
+	// open.
+	First, Last int
+}
+
+// ParseSelection parses the --from and --to timestamps (RFC 3339, as in
+// startedDateTime) and an --entries range like "100-250", "100-" or "-250".
+// Empty arguments leave that part of the selection open.
+func ParseSelection(from, to, entries string) (Selection, error) {
+	var s Selection
+	var err error
+	if from != "" {
+		if s.From, err = parseStartedDateTime(from); err != nil {
+			return s, fmt.Errorf("invalid --from time %q: %v", from, err)
+		}
+	}
+	if to != "" {
+		if s.To, err = parseStartedDateTime(to); err != nil {
+			return s, fmt.Errorf("invalid --to time %q: %v", to, err)
+		}
+	}
+	if !s.From.IsZero() && !s.To.IsZero() && s.To.Before(s.From) {
+		return s, fmt.Errorf("--to %s is before --from %s", to, from)
+	}
+	if entries != "" {
+		if s.First, s.Last, err = parseEntryRange(entries); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// parseEntryRange parses "first-last", "first-", "-last" or a single
+// position into 1-based positions, where 0 is open.
+func parseEntryRange(s string) (first, last int, err error) {
+	lo, hi, found := strings.Cut(strings.TrimSpace(s), "-")
+	if !found {
+		hi = lo
+	}
+	if lo != "" {
+		if first, err = strconv.Atoi(lo); err != nil || first < 1 {
+			return 0, 0, fmt.Errorf("invalid entry range %q", s)
+		}
+	}
+	if hi != "" {
+		if last, err = strconv.Atoi(hi); err != nil || last < 1 {
+			return 0, 0, fmt.Errorf("invalid entry range %q", s)
+		}
+	}
+	if lo == "" && hi == "" || last > 0 && last < first {
+		return 0, 0, fmt.Errorf("invalid entry range %q", s)
+	}
+	return first, last, nil
+}
+
+// IsZero reports whether s selects every entry.
+func (s Selection) IsZero() bool {
+	return s == Selection{}
+}
+
+// Includes reports whether the entry at the 0-based index i of the .har file
+// is selected. Entries with an invalid startedDateTime are not selected by
+// a time window.
+func (s Selection) Includes(i int, entry Entry) bool {
+	if s.First > 0 && i+1 < s.First || s.Last > 0 && i+1 > s.Last {
+		return false
+	}
+	if s.From.IsZero() && s.To.IsZero() {
+		return true
+	}
+	t, err := parseStartedDateTime(entry.StartedDateTime)
+	if err != nil {
+		return false
+	}
+	return !(!s.From.IsZero() && t.Before(s.From) || !s.To.IsZero() && t.After(s.To))
+}
+
+// Apply removes the entries of har not selected by s, and the pages no
+// longer referenced by any entry.
+func (s Selection) Apply(har *Har) {
+	if s.IsZero() {
+		return
+	}
+	entries := make([]Entry, 0, len(har.Log.Entries))
+	for i, entry := range har.Log.Entries {
+		if s.Includes(i, entry) {
+			entries = append(entries, entry)
+		}
+	}
+	har.Log.Entries = entries
+	har.Log.Pages = referencedPages(har.Log.Pages, entries)
+}
+
+// SelectReader returns a reader of the .har file read from r keeping only
+// the entries selected by s, for commands that read a .har file. Charles
+// sessions and mitmproxy flow files are converted first. r is returned as
+// is if s selects every entry.
+func SelectReader(r *bufio.Reader, s Selection) (*bufio.Reader, error) {
+	if s.IsZero() {
+		return r, nil
+	}
+	har, err := readHar(r)
+	if err != nil {
+		return nil, err
+	}
+	s.Apply(&har)
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(har); err != nil {
+		return nil, err
+	}
+	return bufio.NewReader(&buf), nil
+}
@@ -0,0 +1,68 @@
+package hargo
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestParseSelection(t *testing.T) {
+	s, err := ParseSelection("2024-01-02T10:00:00Z", "", "100-250")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.From.IsZero() || !s.To.IsZero() || s.First != 100 || s.Last != 250 {
+		t.Errorf("unexpected selection %+v", s)
+	}
+
+	for _, entries := range []string{"5-", "-5", "5"} {
+		if _, err := ParseSelection("", "", entries); err != nil {
+			t.Errorf("%s: %v", entries, err)
+		}
+	}
+	for _, entries := range []string{"-", "0-3", "x", "5-2"} {
+		if _, err := ParseSelection("", "", entries); err == nil {
+			t.Errorf("%s: expected an error", entries)
+		}
+	}
+	if _, err := ParseSelection("2024-01-02T10:00:00Z", "2024-01-01T10:00:00Z", ""); err == nil {
+		t.Error("expected an error for --to before --from")
+	}
+}
+
+func TestSelectReader(t *testing.T) {
+	const har = `{"log": {"version": "1.2", "pages": [{"id": "p1"}, {"id": "p2"}], "entries": [
+		{"pageref": "p1", "startedDateTime": "2024-01-02T10:00:00Z", "request": {"url": "https://example.com/1"}},
+		{"pageref": "p1", "startedDateTime": "2024-01-02T10:01:00Z", "request": {"url": "https://example.com/2"}},
+		{"pageref": "p2", "startedDateTime": "2024-01-02T10:02:00Z", "request": {"url": "https://example.com/3"}},
+		{"pageref": "p2", "startedDateTime": "2024-01-02T10:03:00Z", "request": {"url": "https://example.com/4"}}]}}`
+
+	s, _ := ParseSelection("2024-01-02T10:01:00Z", "", "-3")
+	r, err := SelectReader(bufio.NewReader(strings.NewReader(har)), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Log.Entries) != 2 || decoded.Log.Entries[0].Request.URL != "https://example.com/2" ||
+		decoded.Log.Entries[1].Request.URL != "https://example.com/3" {
+		t.Errorf("unexpected entries %+v", decoded.Log.Entries)
+	}
+	if len(decoded.Log.Pages) != 2 {
+		t.Errorf("unexpected pages %+v", decoded.Log.Pages)
+	}
+
+	r, _ = SelectReader(bufio.NewReader(strings.NewReader(har)), Selection{First: 3})
+	decoded, _ = Decode(r)
+	if len(decoded.Log.Entries) != 2 || len(decoded.Log.Pages) != 1 || decoded.Log.Pages[0].ID != "p2" {
+		t.Errorf("unexpected selection %+v", decoded.Log)
+	}
+
+	// the selection no longer applies to every decode
+	decoded, _ = Decode(bufio.NewReader(strings.NewReader(har)))
+	if len(decoded.Log.Entries) != 4 {
+		t.Errorf("unexpected entries %+v", decoded.Log.Entries)
+	}
+}
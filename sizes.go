@@ -0,0 +1,96 @@
+package hargo
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// FixSizes recomputes the size fields of every entry from the recorded
+// content where they are unknown (-1) or inconsistent with it, and returns
+// the changes made:
+//   - content.size is the length of the decoded response text
+//   - bodySize is 0 for 304 responses, the content size for responses
+//     without Content-Encoding, or the Content-Length header if unknown
+//   - content.compression is the difference between content size and
+//     body size of encoded responses
+//   - a request's bodySize is the length of its posted data
+//   - headersSize, if unknown, is computed from the recorded headers of
+//     HTTP/1.x messages. HTTP/2 and HTTP/3 compress headers, so their size
+//     cannot be derived and stays -1.
+func FixSizes(har *Har) []Repair {
+	var repairs []Repair
+	for i := range har.Log.Entries {
+		path := fmt.Sprintf("log.entries[%d]", i)
+		fixRequestSizes(&har.Log.Entries[i].Request, path+".request", &repairs)
+		fixResponseSizes(&har.Log.Entries[i].Response, path+".response", &repairs)
+	}
+	return repairs
+}
+
+// fixSize sets *field to want and records a repair if they differ.
+func fixSize(field *int, want int, path string, repairs *[]Repair) {
+	if *field == want {
+		return
+	}
+	*repairs = append(*repairs, Repair{path, fmt.Sprintf("%d corrected to %d", *field, want)})
+	*field = want
+}
+
+// isHTTP1 reports whether version is HTTP/1.0 or HTTP/1.1, whose headers
+// are sent uncompressed.
+func isHTTP1(version string) bool {
+	return strings.HasPrefix(strings.ToUpper(version), "HTTP/1.")
+}
+
+func fixRequestSizes(req *Request, path string, repairs *[]Repair) {
+	if req.HeaderSize < 0 && isHTTP1(req.HTTPVersion) {
+		fixSize(&req.HeaderSize, int(requestHeaderBytes(*req)), path+".headersSize", repairs)
+	}
+
+	body := req.PostData.Text
+	if body == "" && len(req.PostData.Params) > 0 {
+		form := url.Values{}
+		for _, p := range req.PostData.Params {
+			form.Add(p.Name, p.Value)
+		}
+		body = form.Encode()
+	}
+	if body != "" || req.BodySize < 0 {
+		fixSize(&req.BodySize, len(body), path+".bodySize", repairs)
+	}
+}
+
+func fixResponseSizes(resp *Response, path string, repairs *[]Repair) {
+	if resp.HeadersSize < 0 && isHTTP1(resp.HTTPVersion) {
+		fixSize(&resp.HeadersSize, int(responseHeaderBytes(*resp)), path+".headersSize", repairs)
+	}
+
+	if resp.Content.Text != "" {
+		if body, err := decodeContent(resp.Content); err == nil {
+			fixSize(&resp.Content.Size, len(body), path+".content.size", repairs)
+		}
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(recordedHeader(resp.Headers, "Content-Encoding")))
+	encoded := encoding != "" && encoding != "identity"
+
+	switch {
+	case resp.Status == 304:
+		fixSize(&resp.BodySize, 0, path+".bodySize", repairs)
+	case !encoded && (resp.Content.Text != "" || resp.BodySize < 0):
+		fixSize(&resp.BodySize, resp.Content.Size, path+".bodySize", repairs)
+	case resp.BodySize < 0:
+		if length, err := strconv.Atoi(recordedHeader(resp.Headers, "Content-Length")); err == nil && length >= 0 {
+			fixSize(&resp.BodySize, length, path+".bodySize", repairs)
+		}
+	}
+
+	switch {
+	case !encoded:
+		fixSize(&resp.Content.Compression, 0, path+".content.compression", repairs)
+	case resp.BodySize >= 0 && resp.Content.Size >= resp.BodySize:
+		fixSize(&resp.Content.Compression, resp.Content.Size-resp.BodySize, path+".content.compression", repairs)
+	}
+}
@@ -0,0 +1,56 @@
+package hargo
+
+import "testing"
+
+func TestFixSizes(t *testing.T) {
+	har := Har{Log: Log{Entries: []Entry{
+		{
+			Request: Request{Method: "POST", URL: "https://example.com/form", HTTPVersion: "HTTP/1.1",
+				Headers:    []NVP{{Name: "Host", Value: "example.com"}},
+				HeaderSize: -1, BodySize: -1,
+				PostData: PostData{MimeType: "application/x-www-form-urlencoded", Params: []PostParam{{Name: "a", Value: "1"}}}},
+			Response: Response{Status: 200, HTTPVersion: "HTTP/1.1", HeadersSize: -1, BodySize: -1,
+				Headers: []NVP{{Name: "Content-Type", Value: "text/plain"}},
+				Content: Content{Size: 0, Text: "hello", Compression: 3}},
+		},
+		{
+			Request: Request{Method: "GET", URL: "https://example.com/app.js", HTTPVersion: "h2", HeaderSize: -1, BodySize: -1},
+			Response: Response{Status: 200, HTTPVersion: "h2", HeadersSize: -1, BodySize: -1,
+				Headers: []NVP{{Name: "content-encoding", Value: "gzip"}, {Name: "content-length", Value: "4"}},
+				Content: Content{Size: 10, Text: "YWJjZGVmZ2hpag==", Encoding: "base64"}},
+		},
+		{
+			Request:  Request{Method: "GET", URL: "https://example.com/", HTTPVersion: "HTTP/1.1", HeaderSize: 10, BodySize: 0},
+			Response: Response{Status: 304, HTTPVersion: "HTTP/1.1", HeadersSize: 20, BodySize: -1},
+		},
+	}}}
+
+	repairs := FixSizes(&har)
+	if len(repairs) == 0 {
+		t.Fatal("expected repairs")
+	}
+
+	form := har.Log.Entries[0]
+	if form.Request.BodySize != 3 || form.Request.HeaderSize <= 0 {
+		t.Errorf("unexpected request sizes %d %d", form.Request.BodySize, form.Request.HeaderSize)
+	}
+	if form.Response.Content.Size != 5 || form.Response.BodySize != 5 || form.Response.Content.Compression != 0 || form.Response.HeadersSize <= 0 {
+		t.Errorf("unexpected response sizes %+v", form.Response)
+	}
+
+	gzipped := har.Log.Entries[1]
+	if gzipped.Request.BodySize != 0 || gzipped.Request.HeaderSize != -1 || gzipped.Response.HeadersSize != -1 {
+		t.Errorf("unexpected h2 sizes %+v", gzipped)
+	}
+	if gzipped.Response.BodySize != 4 || gzipped.Response.Content.Compression != 6 {
+		t.Errorf("unexpected encoded sizes %d %d", gzipped.Response.BodySize, gzipped.Response.Content.Compression)
+	}
+
+	if notModified := har.Log.Entries[2]; notModified.Response.BodySize != 0 || notModified.Request.HeaderSize != 10 {
+		t.Errorf("unexpected 304 sizes %+v", notModified)
+	}
+
+	if again := FixSizes(&har); len(again) != 0 {
+		t.Errorf("expected no repairs on second pass, got %v", again)
+	}
+}
@@ -0,0 +1,183 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+)
+
+// SniffContentType determines the media type of a body from its content,
+// like browsers do: by the magic bytes of images, fonts, media, archives
+// and PDF documents, and by the markup of HTML and XML documents. Bodies
+// that are valid JSON objects or arrays are application/json, other text
+// text/plain and other binary data application/octet-stream. The result
+// has no parameters.
+func SniffContentType(body []byte) string {
+	switch {
+	case len(body) >= 12 && string(body[4:8]) == "ftyp" && (string(body[8:12]) == "avif" || string(body[8:12]) == "avis"):
+		return "image/avif"
+	case bytes.HasPrefix(body, []byte("II*\x00")), bytes.HasPrefix(body, []byte("MM\x00*")):
+		return "image/tiff"
+	}
+
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")))
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return "application/json"
+	}
+
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(body))
+	if sniffed == "text/xml" && bytes.Contains(body[:min(len(body), 512)], []byte("<svg")) {
+		return "image/svg+xml"
+	}
+	return sniffed
+}
+
+// mediaTypeAliases maps nonstandard media types to the ones
+// SniffContentType returns.
+var mediaTypeAliases = map[string]string{
+	"image/jpg":                "image/jpeg",
+	"image/pjpeg":              "image/jpeg",
+	"image/x-png":              "image/png",
+	"image/x-ms-bmp":           "image/bmp",
+	"image/vnd.microsoft.icon": "image/x-icon",
+	"application/xml":          "text/xml",
+	"application/gzip":         "application/x-gzip",
+	"application/x-zip":        "application/zip",
+	"audio/wav":                "audio/wave",
+	"audio/x-wav":              "audio/wave",
+	"audio/mp3":                "audio/mpeg",
+}
+
+// isTextMediaType reports whether a declared media type is textual.
+func isTextMediaType(mediaType string) bool {
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	for _, s := range []string{"json", "javascript", "ecmascript", "xml", "graphql", "x-www-form-urlencoded"} {
+		if strings.Contains(mediaType, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// contentTypesAgree reports whether a body of the sniffed media type may
+// be served with the declared one. Generic sniffed types agree with any
+// declared type of their kind, and binary formats with generic binary
+// types like application/octet-stream.
+func contentTypesAgree(declared, sniffed string) bool {
+	mediaType, _, err := mime.ParseMediaType(declared)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(declared))
+	}
+	if alias, ok := mediaTypeAliases[mediaType]; ok {
+		mediaType = alias
+	}
+	if mediaType == sniffed {
+		return true
+	}
+
+	switch sniffed {
+	case "text/plain":
+		return isTextMediaType(mediaType)
+	case "application/octet-stream":
+		return !isTextMediaType(mediaType)
+	case "application/json":
+		return strings.Contains(mediaType, "json") || strings.Contains(mediaType, "javascript") || mediaType == "text/plain"
+	case "text/html":
+		return strings.Contains(mediaType, "html")
+	case "text/xml", "image/svg+xml":
+		return strings.Contains(mediaType, "xml") || strings.Contains(mediaType, "svg")
+	}
+
+	// binary formats
+	switch mediaType {
+	case "application/octet-stream", "binary/octet-stream", "application/binary", "application/unknown":
+		return true
+	}
+	switch {
+	case getTypeDirectory(sniffed) == "fonts":
+		return getTypeDirectory(mediaType) == "fonts"
+	case sniffed == "application/ogg":
+		return strings.HasSuffix(mediaType, "/ogg")
+	case strings.HasPrefix(sniffed, "video/"), strings.HasPrefix(sniffed, "audio/"):
+		// webm and mp4 may hold either
+		_, subtype, _ := strings.Cut(sniffed, "/")
+		return mediaType == "video/"+subtype || mediaType == "audio/"+subtype
+	}
+	return false
+}
+
+// sniffedMimeType returns the media type of the response body of entry if
+// it disagrees with the declared one and is specific enough to be used
+// instead, or "".
+func sniffedMimeType(entry Entry) string {
+	body, err := entry.DecodedBody()
+	if err != nil || len(body) == 0 {
+		return ""
+	}
+	sniffed := SniffContentType(body)
+	if sniffed == "text/plain" || sniffed == "application/octet-stream" || contentTypesAgree(entry.Response.Content.MimeType, sniffed) {
+		return ""
+	}
+	return sniffed
+}
+
+// ContentTypeMismatch is a response whose declared MIME type disagrees
+// with its content.
+type ContentTypeMismatch struct {
+	// Entry is the index of the entry in the .har file.
+	Entry    int    `json:"entry"`
+	URL      string `json:"url"`
+	Declared string `json:"declared"`
+	Sniffed  string `json:"sniffed"`
+}
+
+// FindContentTypeMismatches sniffs the recorded response body of every
+// entry and returns those whose declared MIME type disagrees with it, e.g.
+// JSON served as text/html, or a PNG image served as image/jpeg. Responses
+// without a body or a declared type are skipped.
+func FindContentTypeMismatches(har Har) []ContentTypeMismatch {
+	var mismatches []ContentTypeMismatch
+	for i, entry := range har.Log.Entries {
+		content := entry.Response.Content
+		if strings.TrimSpace(content.MimeType) == "" || content.Text == "" {
+			continue
+		}
+		body, err := decodeContent(content)
+		if err != nil {
+			continue
+		}
+		if sniffed := SniffContentType(body); !contentTypesAgree(content.MimeType, sniffed) {
+			mismatches = append(mismatches, ContentTypeMismatch{Entry: i, URL: entry.Request.URL, Declared: content.MimeType, Sniffed: sniffed})
+		}
+	}
+	return mismatches
+}
+
+// Sniff prints the responses of a .har file whose declared MIME type
+// disagrees with their content to w, and returns how many there are.
+func Sniff(r *bufio.Reader, w io.Writer) (int, error) {
+	har, err := Decode(r)
+	if err != nil {
+		return 0, err
+	}
+
+	mismatches := FindContentTypeMismatches(har)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", "Entry", "Declared", "Sniffed", "URL")
+	for _, m := range mismatches {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t\n", m.Entry, m.Declared, m.Sniffed, shortURL(m.URL))
+	}
+	if err := tw.Flush(); err != nil {
+		return len(mismatches), err
+	}
+	_, err = fmt.Fprintf(w, "%d of %d responses with a mismatching content type\n", len(mismatches), len(har.Log.Entries))
+	return len(mismatches), err
+}
@@ -0,0 +1,129 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestSniffContentType(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected string
+	}{
+		{string(testPNG), "image/png"},
+		{"\xff\xd8\xff\xe0", "image/jpeg"},
+		{"\x00\x00\x00\x1cftypavif", "image/avif"},
+		{"wOF2\x00\x01", "font/woff2"},
+		{"%PDF-1.7", "application/pdf"},
+		{"\x1f\x8b\x08\x00", "application/x-gzip"},
+		{"\xef\xbb\xbf {\"a\": [1, 2]}\n", "application/json"},
+		{"[1, 2", "text/plain"},
+		{"<!DOCTYPE html><html></html>", "text/html"},
+		{"<?xml version=\"1.0\"?><rss/>", "text/xml"},
+		{"<?xml version=\"1.0\"?><svg xmlns=\"http://www.w3.org/2000/svg\"/>", "image/svg+xml"},
+		{"body { color: red }", "text/plain"},
+		{"\x00\x01\x02\x03", "application/octet-stream"},
+	}
+	for i, test := range tests {
+		if got := SniffContentType([]byte(test.body)); got != test.expected {
+			t.Errorf("%d: got %s, want %s", i, got, test.expected)
+		}
+	}
+}
+
+func TestContentTypesAgree(t *testing.T) {
+	tests := []struct {
+		declared, sniffed string
+		agree             bool
+	}{
+		{"image/png", "image/png", true},
+		{"IMAGE/JPG; q=1", "image/jpeg", true},
+		{"application/xml", "text/xml", true},
+		{"application/rss+xml", "text/xml", true},
+		{"application/javascript", "text/plain", true},
+		{"text/css", "text/plain", true},
+		{"application/vnd.api+json", "application/json", true},
+		{"application/javascript", "application/json", true},
+		{"application/octet-stream", "image/png", true},
+		{"application/font-woff", "font/woff", true},
+		{"audio/webm", "video/webm", true},
+		{"application/x-protobuf", "application/octet-stream", true},
+		{"text/html", "application/json", false},
+		{"application/json", "text/html", false},
+		{"image/jpeg", "image/webp", false},
+		{"image/png", "text/plain", false},
+		{"text/javascript", "application/x-gzip", false},
+		{"application/json", "application/octet-stream", false},
+	}
+	for i, test := range tests {
+		if got := contentTypesAgree(test.declared, test.sniffed); got != test.agree {
+			t.Errorf("%d: %s %s: got %v", i, test.declared, test.sniffed, got)
+		}
+	}
+}
+
+func TestFindContentTypeMismatches(t *testing.T) {
+	entry := func(url, mimeType, text, encoding string) Entry {
+		return Entry{Request: Request{Method: "GET", URL: url},
+			Response: Response{Status: 200, Content: Content{MimeType: mimeType, Text: text, Encoding: encoding}}}
+	}
+	har := Har{Log: Log{Entries: []Entry{
+		entry("https://example.com/api/user", "text/html; charset=utf-8", `{"name": "ann"}`, ""),
+		entry("https://example.com/logo.jpg", "image/jpeg", base64.StdEncoding.EncodeToString(testPNG), "base64"),
+		entry("https://example.com/app.js", "application/javascript", "console.log(1)", ""),
+		entry("https://example.com/empty", "text/html", "", ""),
+		entry("https://example.com/unknown", "", "<html></html>", ""),
+	}}}
+
+	mismatches := FindContentTypeMismatches(har)
+	got, _ := json.Marshal(mismatches)
+	expected := `[{"entry":0,"url":"https://example.com/api/user","declared":"text/html; charset=utf-8","sniffed":"application/json"},` +
+		`{"entry":1,"url":"https://example.com/logo.jpg","declared":"image/jpeg","sniffed":"image/png"}]`
+	if string(got) != expected {
+		t.Errorf("got %s, want %s", got, expected)
+	}
+
+	data, _ := json.Marshal(har)
+	var out bytes.Buffer
+	count, err := Sniff(bufio.NewReader(bytes.NewReader(data)), &out)
+	if err != nil || count != 2 || !strings.Contains(out.String(), "2 of 5 responses with a mismatching content type") {
+		t.Errorf("got %d, %v: %s", count, err, out.String())
+	}
+}
+
+func TestExtractSniffTypes(t *testing.T) {
+	defer cleanupExtractDirs()
+
+	har := Har{Log: Log{Entries: []Entry{
+		{Request: Request{Method: "GET", URL: "https://example.com/api/user"},
+			Response: Response{Status: 200, Content: Content{MimeType: "text/html", Text: `{"name":"ann"}`}}},
+		{Request: Request{Method: "GET", URL: "https://example.com/"},
+			Response: Response{Status: 200, Content: Content{MimeType: "text/html", Text: "<p>hi</p>"}}},
+	}}}
+	data, _ := json.Marshal(har)
+
+	result, err := ExtractContext(context.Background(), bufio.NewReader(bytes.NewReader(data)), ExtractOptions{SortByType: true, SniffTypes: true, PrettyJSON: true})
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	var paths []string
+	for _, m := range result.Manifest {
+		rel, _ := filepath.Rel(result.OutputDir, m.ExtractedPath)
+		paths = append(paths, filepath.ToSlash(rel)+" "+m.MimeType)
+	}
+	if expected := "json/user.json text/html, html/page.html text/html"; strings.Join(paths, ", ") != expected {
+		t.Errorf("got %s, want %s", strings.Join(paths, ", "), expected)
+	}
+	if content, _ := os.ReadFile(result.Manifest[0].ExtractedPath); string(content) != "{\n  \"name\": \"ann\"\n}\n" {
+		t.Errorf("got %q", content)
+	}
+}
@@ -0,0 +1,278 @@
+package hargo
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// s3UnsignedPayload is the payload hash of requests without a body to sign.
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// IsRemoteSource reports whether source is an http://, https:// or s3://
+// URL rather than a local path.
+func IsRemoteSource(source string) bool {
+	for _, scheme := range []string{"http://", "https://", "s3://"} {
+		if strings.HasPrefix(source, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenSource opens a .har file for reading: a local path, an http:// or
+// https:// URL, or an object in S3 given as s3://bucket/key. Remote files
+// are streamed while they are read rather than downloaded first.
+//
+// S3 requests are signed with the credentials of the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables, or of
+// the AWS_PROFILE (default) profile of the shared credentials file, and
+// sent unsigned for public buckets without either. The region is taken
+// from AWS_REGION or AWS_DEFAULT_REGION, and AWS_ENDPOINT_URL_S3 or
+// AWS_ENDPOINT_URL selects an S3 compatible service.
+func OpenSource(ctx context.Context, source string) (io.ReadCloser, error) {
+	if !IsRemoteSource(source) {
+		file, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		return file, nil
+	}
+	if err := requireNetwork("download " + source); err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(source, "s3://") {
+		return openS3Source(ctx, source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	return downloadSource(source, req)
+}
+
+// downloadSource sends req and returns the body of a successful response.
+func downloadSource(source string, req *http.Request) (io.ReadCloser, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("cannot download %s: %s", source, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// s3Credentials are AWS credentials for signing S3 requests.
+type s3Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// openS3Source streams the object of an s3://bucket/key URL.
+func openS3Source(ctx context.Context, source string) (io.ReadCloser, error) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(source, "s3://"), "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("invalid S3 URL %s, expected s3://bucket/key", source)
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	creds, signed := s3EnvironmentCredentials()
+
+	req, err := s3ObjectRequest(ctx, bucket, key, region)
+	if err != nil {
+		return nil, err
+	}
+	if signed {
+		signS3Request(req, creds, region, s3UnsignedPayload, time.Now())
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// buckets in another region than configured name theirs
+	if bucketRegion := resp.Header.Get("X-Amz-Bucket-Region"); resp.StatusCode != http.StatusOK && bucketRegion != "" && bucketRegion != region {
+		resp.Body.Close()
+		if req, err = s3ObjectRequest(ctx, bucket, key, bucketRegion); err != nil {
+			return nil, err
+		}
+		if signed {
+			signS3Request(req, creds, bucketRegion, s3UnsignedPayload, time.Now())
+		}
+		return downloadSource(source, req)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("cannot download %s: %s", source, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// s3ObjectRequest returns a GET request of an S3 object, addressed
+// virtual-hosted style on AWS and path style on custom endpoints and for
+// bucket names with dots, which do not match the certificate of AWS.
+func s3ObjectRequest(ctx context.Context, bucket, key, region string) (*http.Request, error) {
+	var u *url.URL
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_S3")
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	switch {
+	case endpoint != "":
+		base, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid S3 endpoint %s: %v", endpoint, err)
+		}
+		u = &url.URL{Scheme: base.Scheme, Host: base.Host, Path: base.Path + "/" + bucket + "/" + key}
+	case strings.Contains(bucket, "."):
+		u = &url.URL{Scheme: "https", Host: "s3." + region + ".amazonaws.com", Path: "/" + bucket + "/" + key}
+	default:
+		u = &url.URL{Scheme: "https", Host: bucket + ".s3." + region + ".amazonaws.com", Path: "/" + key}
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	return http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+}
+
+// s3EscapePath percent-encodes everything but unreserved characters and
+// slashes, as S3 expects in signed paths.
+func s3EscapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3EnvironmentCredentials returns the credentials of the environment
+// variables or the shared credentials file, and whether there are any.
+func s3EnvironmentCredentials() (s3Credentials, bool) {
+	creds := s3Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID != "" && creds.SecretAccessKey != "" {
+		return creds, true
+	}
+
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return creds, false
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	creds, err := readSharedCredentials(path, profile)
+	return creds, err == nil && creds.AccessKeyID != "" && creds.SecretAccessKey != ""
+}
+
+// readSharedCredentials reads the keys of a profile of an AWS shared
+// credentials file.
+func readSharedCredentials(path, profile string) (s3Credentials, error) {
+	var creds s3Credentials
+	file, err := os.Open(path)
+	if err != nil {
+		return creds, err
+	}
+	defer file.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok || section != profile {
+			continue
+		}
+		switch strings.TrimSpace(name) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			creds.SessionToken = strings.TrimSpace(value)
+		}
+	}
+	return creds, scanner.Err()
+}
+
+// signS3Request adds an AWS Signature Version 4 authorization to a request
+// whose canonical query is its raw query, with the hash of its payload.
+func signS3Request(req *http.Request, creds s3Credentials, region, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	headers := []string{"host:" + req.URL.Host, "x-amz-content-sha256:" + payloadHash, "x-amz-date:" + amzDate}
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+		headers = append(headers, "x-amz-security-token:"+creds.SessionToken)
+	}
+	var names []string
+	for _, h := range headers {
+		name, _, _ := strings.Cut(h, ":")
+		names = append(names, name)
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		strings.Join(headers, "\n") + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{day, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,237 @@
+package hargo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// sourceMap is the part of a source map (revision 3) needed to recover the
+// original sources.
+type sourceMap struct {
+	Version        int       `json:"version"`
+	SourceRoot     string    `json:"sourceRoot"`
+	Sources        []string  `json:"sources"`
+	SourcesContent []*string `json:"sourcesContent"`
+}
+
+// sourceMappingURLComment matches the //# sourceMappingURL= comment at the
+// end of a script, and the deprecated //@ form.
+var sourceMappingURLComment = regexp.MustCompile(`(?m)^[ \t]*//[#@][ \t]*sourceMappingURL=([^\s'"]+)[ \t]*$`)
+
+// isJavaScript reports whether entry is a script, by MIME type or URL.
+func isJavaScript(entry Entry) bool {
+	if strings.Contains(strings.ToLower(entry.Response.Content.MimeType), "javascript") {
+		return true
+	}
+	if u, err := url.Parse(entry.Request.URL); err == nil {
+		switch strings.ToLower(path.Ext(u.Path)) {
+		case ".js", ".mjs", ".cjs":
+			return true
+		}
+	}
+	return false
+}
+
+// sourceMapURL returns the URL of the source map of a script, from its
+// SourceMap (or X-SourceMap) header or its last sourceMappingURL comment,
+// resolved against the script's URL. Data URIs are returned unchanged.
+func sourceMapURL(entry Entry, body []byte) string {
+	ref := recordedHeader(entry.Response.Headers, "SourceMap")
+	if ref == "" {
+		ref = recordedHeader(entry.Response.Headers, "X-SourceMap")
+	}
+	if ref == "" {
+		matches := sourceMappingURLComment.FindAllSubmatch(body, -1)
+		if len(matches) == 0 {
+			return ""
+		}
+		ref = string(matches[len(matches)-1][1])
+	}
+	if strings.HasPrefix(ref, "data:") {
+		return ref
+	}
+	return resolveReference(entry.Request.URL, ref)
+}
+
+// resolveReference resolves ref against base and drops the fragment, or
+// returns "" if either is not a valid URL.
+func resolveReference(base, ref string) string {
+	b, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	resolved := b.ResolveReference(r)
+	resolved.Fragment = ""
+	return resolved.String()
+}
+
+// decodeDataURI returns the content of a data: URI.
+func decodeDataURI(uri string) ([]byte, error) {
+	meta, data, found := strings.Cut(strings.TrimPrefix(uri, "data:"), ",")
+	if !found {
+		return nil, fmt.Errorf("invalid data URI")
+	}
+	if strings.HasSuffix(strings.ToLower(meta), ";base64") {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	decoded, err := url.PathUnescape(data)
+	return []byte(decoded), err
+}
+
+// sourceMapIndex finds the recorded responses of source maps and sources
+// by URL.
+type sourceMapIndex map[string]Entry
+
+// newSourceMapIndex indexes the entries of har with response content.
+func newSourceMapIndex(har Har) sourceMapIndex {
+	index := make(sourceMapIndex)
+	for _, entry := range har.Log.Entries {
+		if entry.Response.Content.Text == "" {
+			continue
+		}
+		if u := resolveReference(entry.Request.URL, ""); u != "" {
+			index[u] = entry
+		}
+	}
+	return index
+}
+
+// content returns the recorded body of rawURL, or of a data: URI.
+func (index sourceMapIndex) content(rawURL string) ([]byte, bool) {
+	if strings.HasPrefix(rawURL, "data:") {
+		data, err := decodeDataURI(rawURL)
+		return data, err == nil
+	}
+	entry, ok := index[rawURL]
+	if !ok {
+		return nil, false
+	}
+	data, err := entry.DecodedBody()
+	return data, err == nil
+}
+
+// referencedSourceMaps returns the URLs of the recorded source maps that
+// scripts in har refer to. They are extracted next to their scripts.
+func referencedSourceMaps(har Har, index sourceMapIndex) map[string]bool {
+	maps := make(map[string]bool)
+	for _, entry := range har.Log.Entries {
+		if !isJavaScript(entry) {
+			continue
+		}
+		body, err := entry.DecodedBody()
+		if err != nil {
+			continue
+		}
+		if mapURL := sourceMapURL(entry, body); mapURL != "" {
+			if _, ok := index[mapURL]; ok {
+				maps[mapURL] = true
+			}
+		}
+	}
+	return maps
+}
+
+// sourcePath maps a source of a source map to a relative file path, e.g.
+// webpack:///./src/app.js to webpack/src/app.js and
+// https://example.com/src/app.ts to example.com/src/app.ts.
+func sourcePath(source string) string {
+	u, err := url.Parse(source)
+	if err != nil {
+		return sanitizeURLPath(source)
+	}
+	prefix := u.Host
+	if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		prefix = filepath.Join(u.Scheme, u.Host)
+	}
+	return filepath.Join(sanitizeURLPath(prefix), sanitizeURLPath(u.Path))
+}
+
+// writeSourceMap writes the source map of the script extracted to
+// scriptPath next to it, as <script>.map, and if expand is set the original
+// sources it contains or refers to into outdir/sources. Sources without
+// content in the map are looked up in the .har file.
+func writeSourceMap(outdir, scriptPath string, entry Entry, body []byte, index sourceMapIndex, expand bool,
+	target extractTarget, paths *pathAllocator, modTime time.Time) ([]ManifestEntry, error) {
+	mapURL := sourceMapURL(entry, body)
+	if mapURL == "" {
+		return nil, nil
+	}
+	data, ok := index.content(mapURL)
+	if !ok {
+		return nil, nil
+	}
+
+	// sources of inline maps are relative to the script
+	originalURL := mapURL
+	if strings.HasPrefix(mapURL, "data:") {
+		originalURL = entry.Request.URL
+	}
+	mapPath := paths.allocate(scriptPath + ".map")
+	if err := target.writeFile(mapPath, data, modTime); err != nil {
+		return nil, err
+	}
+	manifest := []ManifestEntry{{
+		OriginalURL:   originalURL,
+		ExtractedPath: mapPath,
+		MimeType:      "application/json",
+		Size:          len(data),
+		Method:        entry.Request.Method,
+		Status:        entry.Response.Status,
+	}}
+	if !expand {
+		return manifest, nil
+	}
+
+	var m sourceMap
+	if err := json.Unmarshal(bytes.TrimPrefix(data, []byte(")]}'")), &m); err != nil {
+		return manifest, fmt.Errorf("invalid source map %s: %v", originalURL, err)
+	}
+	sourcesDir := filepath.Join(outdir, "sources")
+	for i, source := range m.Sources {
+		sourceURL := resolveReference(originalURL, m.SourceRoot+source)
+
+		var content []byte
+		if i < len(m.SourcesContent) && m.SourcesContent[i] != nil {
+			content = []byte(*m.SourcesContent[i])
+		} else if content, ok = index.content(sourceURL); !ok {
+			continue
+		}
+
+		rel := sourcePath(sourceURL)
+		if rel == "" {
+			continue
+		}
+		sourceFile, err := safeJoin(sourcesDir, rel)
+		if err != nil {
+			return manifest, err
+		}
+		sourceFile = paths.allocate(sourceFile)
+		if err := target.mkdirAll(filepath.Dir(sourceFile)); err != nil {
+			return manifest, err
+		}
+		if err := target.writeFile(sourceFile, content, modTime); err != nil {
+			return manifest, err
+		}
+		manifest = append(manifest, ManifestEntry{
+			OriginalURL:   sourceURL,
+			ExtractedPath: sourceFile,
+			MimeType:      "text/plain",
+			Size:          len(content),
+			Method:        entry.Request.Method,
+			Status:        entry.Response.Status,
+		})
+	}
+	return manifest, nil
+}
@@ -0,0 +1,150 @@
+package hargo
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SplitMode selects how Split partitions the entries of a .har file.
+type SplitMode string
+
+const (
+	// SplitByPage creates one .har file per page (pageref).
+	SplitByPage SplitMode = "page"
+	// SplitByDomain creates one .har file per request host.
+	SplitByDomain SplitMode = "domain"
+	// SplitByTime creates one .har file per time window.
+	SplitByTime SplitMode = "time"
+)
+
+// Split partitions a Har into multiple Har objects keyed by pageref, domain,
+// or time bucket (of the given window size). Each part keeps the log's
+// creator and browser, and only the pages referenced by its entries.
+func Split(har Har, mode SplitMode, window time.Duration) (map[string]Har, error) {
+	if mode == SplitByTime && window <= 0 {
+		return nil, fmt.Errorf("time window must be positive")
+	}
+
+	groups := make(map[string][]Entry)
+
+	for _, entry := range har.Log.Entries {
+		var key string
+
+		switch mode {
+		case SplitByPage:
+			key = entry.Pageref
+			if key == "" {
+				key = "no-page"
+			}
+		case SplitByDomain:
+			key = "unknown"
+			if u, err := url.Parse(entry.Request.URL); err == nil && u.Hostname() != "" {
+				key = u.Hostname()
+			}
+		case SplitByTime:
+			t, err := parseStartedDateTime(entry.StartedDateTime)
+			if err != nil {
+				log.Warnf("Skipping entry with invalid startedDateTime %q", entry.StartedDateTime)
+				continue
+			}
+			key = t.UTC().Truncate(window).Format("20060102T150405Z")
+		default:
+			return nil, fmt.Errorf("unknown split mode: %s", mode)
+		}
+
+		groups[key] = append(groups[key], entry)
+	}
+
+	parts := make(map[string]Har, len(groups))
+	for key, entries := range groups {
+		part := Har{Log: har.Log}
+		part.Log.Entries = entries
+		part.Log.Pages = referencedPages(har.Log.Pages, entries)
+		parts[key] = part
+	}
+
+	return parts, nil
+}
+
+// referencedPages returns the pages referenced by the given entries, in their
+// original order.
+func referencedPages(pages []Page, entries []Entry) []Page {
+	refs := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Pageref != "" {
+			refs[entry.Pageref] = true
+		}
+	}
+
+	var result []Page
+	for _, page := range pages {
+		if refs[page.ID] {
+			result = append(result, page)
+		}
+	}
+	return result
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// SplitFile splits a .har file and writes each part to outdir as
+// <prefix>-<key>.har. Keys that are the same once made safe for file names,
+// like "page 1" and "page_1", get a sequence number: <prefix>-<key>_2.har.
+func SplitFile(r *bufio.Reader, mode SplitMode, window time.Duration, outdir string, prefix string) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	parts, err := Split(har, mode, window)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(outdir, 0777)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(parts))
+	for key := range parts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	used := make(map[string]bool)
+	for _, key := range keys {
+		base := prefix + "-" + strings.Trim(unsafeFilenameChars.ReplaceAllString(key, "_"), "_")
+		name := base + ".har"
+		for n := 2; used[strings.ToLower(name)]; n++ {
+			name = base + "_" + strconv.Itoa(n) + ".har"
+		}
+		used[strings.ToLower(name)] = true
+		path := filepath.Join(outdir, name)
+
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+
+		err = Encode(file, parts[key])
+		file.Close()
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Wrote %s [%d entries] for %s", path, len(parts[key].Log.Entries), key)
+	}
+
+	return nil
+}
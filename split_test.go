@@ -0,0 +1,95 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSplit(t *testing.T) {
+	har := readTestHar(t, "split.har")
+	tests := []struct {
+		mode     SplitMode
+		expected map[string]int
+	}{
+		{SplitByPage, map[string]int{"page 1": 1, "page_1": 2, "no-page": 1}},
+		{SplitByDomain, map[string]int{"example.com": 2, "cdn.example.com": 1, "unknown": 1}},
+		{SplitByTime, map[string]int{"20240102T100000Z": 2, "20240102T100100Z": 1}},
+	}
+
+	for _, test := range tests {
+		parts, err := Split(har, test.mode, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(parts) != len(test.expected) {
+			t.Errorf("%s: got %d parts, want %d", test.mode, len(parts), len(test.expected))
+		}
+		for key, n := range test.expected {
+			if got := len(parts[key].Log.Entries); got != n {
+				t.Errorf("%s: got %d entries for %s, want %d", test.mode, got, key, n)
+			}
+		}
+	}
+
+	parts, _ := Split(har, SplitByPage, 0)
+	if pages := parts["page_1"].Log.Pages; len(pages) != 1 || pages[0].ID != "page_1" || parts["page_1"].Log.Creator.Name != "test" {
+		t.Errorf("got part %+v", parts["page_1"].Log)
+	}
+
+	if _, err := Split(har, SplitByTime, 0); err == nil {
+		t.Error("expected an error for a zero time window")
+	}
+	if _, err := Split(har, "size", 0); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestSplitFile(t *testing.T) {
+	harData, err := os.ReadFile("test/split.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outdir := t.TempDir()
+
+	if err := SplitFile(bufio.NewReader(bytes.NewReader(harData)), SplitByPage, 0, outdir, "split"); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(outdir, "*.har"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range files {
+		files[i] = filepath.Base(files[i])
+	}
+	sort.Strings(files)
+	// "page 1" and "page_1" are both written as page_1
+	expected := []string{"split-no-page.har", "split-page_1.har", "split-page_1_2.har"}
+	if len(files) != len(expected) {
+		t.Fatalf("got files %v, want %v", files, expected)
+	}
+	for i := range files {
+		if files[i] != expected[i] {
+			t.Errorf("got files %v, want %v", files, expected)
+			break
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(outdir, "split-page_1_2.har"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var part Har
+	if err := json.Unmarshal(data, &part); err != nil {
+		t.Fatal(err)
+	}
+	if len(part.Log.Entries) != 2 || part.Log.Entries[0].Pageref != "page_1" {
+		t.Errorf("got entries %+v", part.Log.Entries)
+	}
+}
@@ -0,0 +1,304 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SQLiteCommand is the sqlite3 command line shell used to create and query
+// databases.
+var SQLiteCommand = "sqlite3"
+
+// sqliteSchema creates the normalized tables written by WriteSQL.
+const sqliteSchema = `CREATE TABLE entries (
+  id INTEGER PRIMARY KEY,
+  pageref TEXT,
+  started TEXT,
+  started_ms INTEGER,
+  method TEXT,
+  url TEXT,
+  host TEXT,
+  path TEXT,
+  http_version TEXT,
+  status INTEGER,
+  status_text TEXT,
+  mime_type TEXT,
+  request_headers_size INTEGER,
+  request_body_size INTEGER,
+  response_headers_size INTEGER,
+  response_body_size INTEGER,
+  content_size INTEGER,
+  time REAL,
+  server_ip TEXT,
+  connection TEXT
+);
+CREATE TABLE headers (
+  entry_id INTEGER REFERENCES entries(id),
+  direction TEXT,
+  name TEXT,
+  value TEXT
+);
+CREATE TABLE cookies (
+  entry_id INTEGER REFERENCES entries(id),
+  direction TEXT,
+  name TEXT,
+  value TEXT,
+  path TEXT,
+  domain TEXT,
+  expires TEXT,
+  http_only INTEGER,
+  secure INTEGER
+);
+CREATE TABLE query_params (
+  entry_id INTEGER REFERENCES entries(id),
+  name TEXT,
+  value TEXT
+);
+CREATE TABLE timings (
+  entry_id INTEGER PRIMARY KEY REFERENCES entries(id),
+  blocked REAL,
+  dns REAL,
+  connect REAL,
+  ssl REAL,
+  send REAL,
+  wait REAL,
+  receive REAL
+);
+CREATE INDEX headers_entry ON headers(entry_id);
+CREATE INDEX headers_name ON headers(name COLLATE NOCASE);
+CREATE INDEX cookies_entry ON cookies(entry_id);
+CREATE INDEX query_params_entry ON query_params(entry_id);
+`
+
+// sqliteMagic starts every SQLite database file.
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+// ToSQLite loads the entries of a .har file into a new SQLite database at
+// dbPath, with the tables entries, headers, cookies, query_params and
+// timings. It requires the sqlite3 command line shell.
+func ToSQLite(r *bufio.Reader, dbPath string) error {
+	if _, err := os.Stat(dbPath); err == nil {
+		return fmt.Errorf("database %s already exists", dbPath)
+	}
+
+	pr, pw := io.Pipe()
+	written := make(chan error, 1)
+	go func() {
+		err := writeSQLEntries(r, pw)
+		pw.CloseWithError(err)
+		written <- err
+	}()
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(SQLiteCommand, "-bail", dbPath)
+	cmd.Stdin = pr
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	// unblock the writer if sqlite3 exited early
+	pr.Close()
+	writeErr := <-written
+
+	if err != nil {
+		os.Remove(dbPath)
+		return fmt.Errorf("%s: %v %s", SQLiteCommand, err, strings.TrimSpace(stderr.String()))
+	}
+	// sqlite3 rolls back the unfinished transaction when the script ends early
+	if writeErr != nil {
+		os.Remove(dbPath)
+		return writeErr
+	}
+
+	return nil
+}
+
+// writeSQLEntries streams the entries read from r as a SQL script.
+func writeSQLEntries(r *bufio.Reader, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	sw := &sqlWriter{w: bw}
+	if err := sw.begin(); err != nil {
+		return err
+	}
+	if err := forEachEntry(r, sw.entry); err != nil {
+		return err
+	}
+	if err := sw.commit(); err != nil {
+		return err
+	}
+	log.Infof("Loaded %d entries", sw.id)
+	return bw.Flush()
+}
+
+// WriteSQL writes a SQL script creating the hargo tables and inserting the
+// entries, to be run by sqlite3 (or adapted to other databases).
+func WriteSQL(w io.Writer, entries []Entry) error {
+	sw := &sqlWriter{w: w}
+	if err := sw.begin(); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := sw.entry(e); err != nil {
+			return err
+		}
+	}
+	return sw.commit()
+}
+
+// sqlWriter writes INSERT statements for entries, numbering them from 1.
+type sqlWriter struct {
+	w  io.Writer
+	id int
+}
+
+func (s *sqlWriter) begin() error {
+	_, err := io.WriteString(s.w, "BEGIN;\n"+sqliteSchema)
+	return err
+}
+
+func (s *sqlWriter) commit() error {
+	_, err := io.WriteString(s.w, "COMMIT;\n")
+	return err
+}
+
+func (s *sqlWriter) entry(e Entry) error {
+	s.id++
+	u := entryURL(e)
+
+	var startedMs interface{}
+	if t, err := parseStartedDateTime(e.StartedDateTime); err == nil {
+		startedMs = t.UnixMilli()
+	}
+
+	var b strings.Builder
+	sqlInsert(&b, "entries", s.id, e.Pageref, e.StartedDateTime, startedMs, e.Request.Method, e.Request.URL,
+		u.Host, u.Path, e.Request.HTTPVersion, e.Response.Status, e.Response.StatusText, e.Response.Content.MimeType,
+		e.Request.HeaderSize, e.Request.BodySize, e.Response.HeadersSize, e.Response.BodySize, e.Response.Content.Size,
+		float64(e.Time), e.ServerIPAddress, e.Connection)
+	for _, h := range e.Request.Headers {
+		sqlInsert(&b, "headers", s.id, "request", h.Name, h.Value)
+	}
+	for _, h := range e.Response.Headers {
+		sqlInsert(&b, "headers", s.id, "response", h.Name, h.Value)
+	}
+	for _, c := range e.Request.Cookies {
+		sqlInsert(&b, "cookies", s.id, "request", c.Name, c.Value, c.Path, c.Domain, c.Expires, c.HTTPOnly, c.Secure)
+	}
+	for _, c := range e.Response.Cookies {
+		sqlInsert(&b, "cookies", s.id, "response", c.Name, c.Value, c.Path, c.Domain, c.Expires, c.HTTPOnly, c.Secure)
+	}
+	for _, q := range e.Request.QueryString {
+		sqlInsert(&b, "query_params", s.id, q.Name, q.Value)
+	}
+	t := e.Timings
+	sqlInsert(&b, "timings", s.id, t.Blocked, t.DNS, t.Connect, t.Ssl, t.Send, t.Wait, t.Receive)
+
+	_, err := io.WriteString(s.w, b.String())
+	return err
+}
+
+// sqlInsert appends an INSERT statement of the values into table.
+func sqlInsert(b *strings.Builder, table string, values ...interface{}) {
+	b.WriteString("INSERT INTO ")
+	b.WriteString(table)
+	b.WriteString(" VALUES(")
+	for i, v := range values {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(sqlLiteral(v))
+	}
+	b.WriteString(");\n")
+}
+
+// sqlLiteral formats a value as a SQL literal.
+func sqlLiteral(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		// the shell reads statements as text, NUL cannot be represented
+		return "'" + strings.ReplaceAll(strings.ReplaceAll(v, "\x00", ""), "'", "''") + "'"
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	default:
+		return sqlLiteral(fmt.Sprint(v))
+	}
+}
+
+// QueryOptions control the output of Query.
+type QueryOptions struct {
+	// Mode is a sqlite3 output mode: column, csv, json, markdown, ...
+	Mode string
+}
+
+// Query runs a SQL query against a database created by ToSQLite and writes
+// the result to w. path may also be a .har file (or any capture hargo
+// reads), which is loaded into a temporary database first.
+func Query(path string, query string, w io.Writer, opts QueryOptions) error {
+	dbPath := path
+	if !isSQLite(path) {
+		dir, err := os.MkdirTemp("", "hargo-query-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		dbPath = filepath.Join(dir, "har.db")
+		if err := ToSQLite(NewReader(file), dbPath); err != nil {
+			return err
+		}
+	}
+
+	mode := opts.Mode
+	if mode == "" {
+		mode = "column"
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(SQLiteCommand, "-bail", "-header", "-"+mode, "-readonly", dbPath, query)
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v %s", SQLiteCommand, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// isSQLite reports whether the file at path is a SQLite database.
+func isSQLite(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	header := make([]byte, len(sqliteMagic))
+	if _, err := io.ReadFull(file, header); err != nil {
+		return false
+	}
+	return bytes.Equal(header, sqliteMagic)
+}
@@ -0,0 +1,50 @@
+package hargo
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestToSQLiteAndQuery(t *testing.T) {
+	if _, err := exec.LookPath(SQLiteCommand); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+
+	file, err := os.Open("test/golang.org.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	db := filepath.Join(t.TempDir(), "golang.db")
+	if err := ToSQLite(NewReader(file), db); err != nil {
+		t.Fatalf("ToSQLite failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	query := "SELECT count(*) AS n, count(DISTINCT e.host) > 0 AS hosts FROM entries e JOIN timings t ON t.entry_id = e.id"
+	if err := Query(db, query, &out, QueryOptions{Mode: "csv"}); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "n,hosts\n32,1" {
+		t.Errorf("unexpected result %q", got)
+	}
+
+	// captures are loaded into a temporary database
+	out.Reset()
+	query = "SELECT value FROM headers WHERE direction = 'request' AND name = ':authority' LIMIT 1"
+	if err := Query("test/golang.org.har", query, &out, QueryOptions{Mode: "list"}); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "value\ngolang.org" {
+		t.Errorf("unexpected result %q", got)
+	}
+
+	if err := ToSQLite(NewReader(strings.NewReader("{}")), db); err == nil {
+		t.Error("expected an error for an existing database")
+	}
+}
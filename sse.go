@@ -0,0 +1,146 @@
+package hargo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ServerSentEvent is a single event of a text/event-stream response.
+type ServerSentEvent struct {
+	ID    string `json:"id,omitempty"`
+	Event string `json:"event,omitempty"`
+	Data  string `json:"data"`
+	Retry int    `json:"retry,omitempty"`
+}
+
+// IsEventStream reports whether the entry is a Server-Sent Events stream,
+// either by its MIME type or by Chrome's eventsource resource type.
+func (e Entry) IsEventStream() bool {
+	return strings.Contains(strings.ToLower(e.Response.Content.MimeType), "text/event-stream") ||
+		e.ResourceType == "eventsource"
+}
+
+// ParseEvents parses a text/event-stream body into its events. Comments and
+// blocks without data are skipped, multiple data lines are joined by
+// newlines, as an EventSource would dispatch them.
+func ParseEvents(body []byte) []ServerSentEvent {
+	var (
+		events  []ServerSentEvent
+		current ServerSentEvent
+		data    []string
+		hasData bool
+	)
+
+	body = bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n"))
+	body = bytes.ReplaceAll(body, []byte("\r"), []byte("\n"))
+
+	for _, line := range strings.Split(string(body), "\n") {
+		if line == "" {
+			if hasData {
+				current.Data = strings.Join(data, "\n")
+				events = append(events, current)
+			}
+			current = ServerSentEvent{ID: current.ID}
+			data, hasData = nil, false
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch field {
+		case "data":
+			data = append(data, value)
+			hasData = true
+		case "event":
+			current.Event = value
+		case "id":
+			current.ID = value
+		case "retry":
+			if retry, err := strconv.Atoi(value); err == nil {
+				current.Retry = retry
+			}
+		}
+	}
+
+	if hasData {
+		current.Data = strings.Join(data, "\n")
+		events = append(events, current)
+	}
+
+	return events
+}
+
+// splitEventsDir returns the directory for the events of the body extracted
+// to fullPath: <name>_events next to it, shortened to the longest file name.
+func splitEventsDir(fullPath string, paths *pathAllocator) string {
+	const suffix = "_events"
+	name := strings.TrimSuffix(filepath.Base(fullPath), filepath.Ext(fullPath))
+	if max := maxFilenameLength - len(suffix); len(name) > max {
+		name = name[:max]
+		for !utf8.ValidString(name) {
+			name = name[:len(name)-1]
+		}
+	}
+	return paths.allocateDir(filepath.Join(filepath.Dir(fullPath), sanitizeFilename(name+suffix)))
+}
+
+// writeEventFiles writes the data of every event in an event-stream body to
+// its own file in dir, named after its sequence number and event type. JSON
+// payloads get a .json extension.
+func writeEventFiles(dir string, entry Entry, body []byte, target extractTarget, modTime time.Time) ([]ManifestEntry, error) {
+	events := ParseEvents(body)
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	if err := target.mkdirAll(dir); err != nil {
+		return nil, err
+	}
+
+	var manifest []ManifestEntry
+	for i, event := range events {
+		name := event.Event
+		if name == "" {
+			name = "message"
+		}
+
+		ext, mimeType := ".txt", "text/plain"
+		if json.Valid([]byte(event.Data)) {
+			ext, mimeType = ".json", "application/json"
+		}
+
+		// the type is shortened to keep the sequence number and extension
+		prefix := fmt.Sprintf("%04d-", i+1)
+		name = unsafeFilenameChars.ReplaceAllString(name, "_")
+		if max := maxFilenameLength - len(prefix) - len(ext); len(name) > max {
+			name = name[:max]
+		}
+		path := filepath.Join(dir, sanitizeFilename(prefix+name+ext))
+		if err := target.writeFile(path, []byte(event.Data), modTime); err != nil {
+			return manifest, err
+		}
+
+		manifest = append(manifest, ManifestEntry{
+			OriginalURL:   entry.Request.URL,
+			ExtractedPath: path,
+			MimeType:      mimeType,
+			Size:          len(event.Data),
+			Method:        entry.Request.Method,
+			Status:        entry.Response.Status,
+		})
+	}
+
+	return manifest, nil
+}
@@ -0,0 +1,232 @@
+package hargo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// ByteCount accumulates request counts and transferred bytes, split by
+// direction.
+type ByteCount struct {
+	Requests int   `json:"requests"`
+	Sent     int64 `json:"sent"`     // request headers + body
+	Received int64 `json:"received"` // response headers + body
+}
+
+func (b *ByteCount) add(sent, received int64) {
+	b.Requests++
+	b.Sent += sent
+	b.Received += received
+}
+
+// HarStats summarizes the traffic contained in a .har file.
+type HarStats struct {
+	Total      ByteCount             `json:"total"`
+	ByDomain   map[string]*ByteCount `json:"byDomain"`
+	ByType     map[string]*ByteCount `json:"byType"`
+	ByProtocol map[string]*ByteCount `json:"byProtocol"`
+}
+
+// ComputeStats accounts request bytes sent and response bytes received per
+// domain, per content type and per protocol. Sizes come from
+// headersSize/bodySize and fall back to estimates based on the recorded
+// headers and content when the exporter did not provide them.
+func ComputeStats(har Har) HarStats {
+	stats := HarStats{
+		ByDomain:   make(map[string]*ByteCount),
+		ByType:     make(map[string]*ByteCount),
+		ByProtocol: make(map[string]*ByteCount),
+	}
+
+	for _, entry := range har.Log.Entries {
+		sent := requestBytes(entry.Request)
+		received := responseBytes(entry.Response)
+
+		domain := "unknown"
+		if u, err := url.Parse(entry.Request.URL); err == nil && u.Hostname() != "" {
+			domain = u.Hostname()
+		}
+		contentType := getTypeDirectory(entry.Response.Content.MimeType)
+
+		if stats.ByDomain[domain] == nil {
+			stats.ByDomain[domain] = &ByteCount{}
+		}
+		if stats.ByType[contentType] == nil {
+			stats.ByType[contentType] = &ByteCount{}
+		}
+		protocol := entry.NegotiatedProtocol()
+		if stats.ByProtocol[protocol] == nil {
+			stats.ByProtocol[protocol] = &ByteCount{}
+		}
+
+		stats.Total.add(sent, received)
+		stats.ByDomain[domain].add(sent, received)
+		stats.ByType[contentType].add(sent, received)
+		stats.ByProtocol[protocol].add(sent, received)
+	}
+
+	return stats
+}
+
+// Stats prints traffic statistics for a .har file to w.
+func Stats(r *bufio.Reader, w io.Writer) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	stats := ComputeStats(har)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	writeByteCounts(tw, "Domain", stats.ByDomain, stats.Total)
+	fmt.Fprintln(tw)
+	writeByteCounts(tw, "Type", stats.ByType, stats.Total)
+	fmt.Fprintln(tw)
+	writeByteCounts(tw, "Protocol", stats.ByProtocol, stats.Total)
+	return tw.Flush()
+}
+
+// StatsHTML writes the traffic statistics of a .har file to w as a
+// self-contained HTML report.
+func StatsHTML(r *bufio.Reader, w io.Writer) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	return WriteAnalysisReport(w, StatsReport(reportTitle(har), ComputeStats(har)))
+}
+
+// StatsReport returns the HTML report of stats.
+func StatsReport(title string, stats HarStats) AnalysisReport {
+	return AnalysisReport{
+		Title: title,
+		Figures: []ReportFigure{
+			{"requests", strconv.Itoa(stats.Total.Requests)},
+			{"bytes received", strconv.FormatInt(stats.Total.Received, 10)},
+			{"bytes sent", strconv.FormatInt(stats.Total.Sent, 10)},
+		},
+		Charts: []ReportChart{
+			byteCountChart("Received by domain", stats.ByDomain),
+			byteCountChart("Received by content type", stats.ByType),
+		},
+		Sections: []ReportSection{
+			byteCountSection("Domains", "Domain", stats.ByDomain),
+			byteCountSection("Content types", "Type", stats.ByType),
+			byteCountSection("Protocols", "Protocol", stats.ByProtocol),
+		},
+	}
+}
+
+// reportChartBars is the number of bars of a chart of many keys, like
+// domains.
+const reportChartBars = 10
+
+// byteCountChart charts the received bytes of the largest counts.
+func byteCountChart(title string, counts map[string]*ByteCount) ReportChart {
+	chart := ReportChart{Title: title}
+	for _, row := range byteCountSection("", "", counts).Rows {
+		if len(chart.Bars) == reportChartBars {
+			break
+		}
+		received := counts[row[0]].Received
+		chart.Bars = append(chart.Bars, ReportBar{Label: row[0], Value: float64(received), Text: formatBytes(received)})
+	}
+	return chart
+}
+
+// writeByteCounts writes one table row per key, largest total first,
+// followed by the overall total.
+func writeByteCounts(w io.Writer, title string, counts map[string]*ByteCount, total ByteCount) {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := counts[keys[i]], counts[keys[j]]
+		if a.Sent+a.Received != b.Sent+b.Received {
+			return a.Sent+a.Received > b.Sent+b.Received
+		}
+		return keys[i] < keys[j]
+	})
+
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", title, "Requests", "Sent (bytes)", "Received (bytes)")
+	for _, k := range keys {
+		c := counts[k]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t\n", k, c.Requests, c.Sent, c.Received)
+	}
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t\n", "Total", total.Requests, total.Sent, total.Received)
+}
+
+// requestBytes returns the number of bytes sent for a request: headersSize
+// plus bodySize, estimated from the recorded request line, headers and post
+// data where those are unavailable (-1).
+func requestBytes(req Request) int64 {
+	headers := requestHeaderBytes(req)
+
+	body := int64(req.BodySize)
+	if body < 0 {
+		body = int64(len(req.PostData.Text))
+	}
+
+	return headers + body
+}
+
+// responseBytes returns the number of bytes received for a response:
+// headersSize plus bodySize, falling back to the content size minus any
+// compression savings where the body size is unknown.
+func responseBytes(resp Response) int64 {
+	headers := responseHeaderBytes(resp)
+
+	body := int64(resp.BodySize)
+	if body < 0 {
+		body = int64(resp.Content.Size - resp.Content.Compression)
+		if body < 0 {
+			body = 0
+		}
+	}
+
+	return headers + body
+}
+
+// requestHeaderBytes returns the size of the request line and headers of a
+// request, estimated from the recorded headers if headersSize is -1.
+func requestHeaderBytes(req Request) int64 {
+	if req.HeaderSize >= 0 {
+		return int64(req.HeaderSize)
+	}
+	u, err := url.Parse(req.URL)
+	uri := req.URL
+	if err == nil {
+		uri = u.RequestURI()
+	}
+	return int64(len(req.Method)+len(uri)+len(req.HTTPVersion)) + 4 + headerListSize(req.Headers)
+}
+
+// responseHeaderBytes returns the size of the status line and headers of a
+// response, estimated from the recorded headers if headersSize is -1.
+func responseHeaderBytes(resp Response) int64 {
+	if resp.HeadersSize >= 0 {
+		return int64(resp.HeadersSize)
+	}
+	return int64(len(resp.HTTPVersion)+len(resp.StatusText)) + 7 + headerListSize(resp.Headers)
+}
+
+// headerListSize estimates the on-the-wire size of a header block, including
+// the terminating empty line. HTTP/2 pseudo headers are ignored.
+func headerListSize(headers []NVP) int64 {
+	size := int64(2)
+	for _, h := range headers {
+		if strings.HasPrefix(h.Name, ":") {
+			continue
+		}
+		size += int64(len(h.Name) + len(h.Value) + 4)
+	}
+	return size
+}
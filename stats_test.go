@@ -0,0 +1,70 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDecodeHeadersSize(t *testing.T) {
+	harData := `{"log":{"entries":[{"startedDateTime":"2024-01-02T10:00:00.000Z",
+		"request":{"method":"GET","url":"https://example.com/","headersSize":321,"bodySize":0},
+		"response":{"status":200,"headersSize":123,"bodySize":10}}]}}`
+
+	har, err := Decode(bufio.NewReader(strings.NewReader(harData)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := har.Log.Entries[0]
+	if entry.Request.HeaderSize != 321 || entry.Response.HeadersSize != 123 {
+		t.Errorf("got request headersSize %d, response headersSize %d", entry.Request.HeaderSize, entry.Response.HeadersSize)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, har); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"headersSize": 321`) || strings.Contains(buf.String(), `"headerSize"`) {
+		t.Errorf("got %s", buf.String())
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	har := Har{Log: Log{Entries: []Entry{
+		{
+			Request:  Request{Method: "GET", URL: "https://example.com/", HTTPVersion: "HTTP/1.1", HeaderSize: 100, BodySize: 0},
+			Response: Response{Status: 200, HTTPVersion: "HTTP/1.1", HeadersSize: 200, BodySize: 1000, Content: Content{MimeType: "text/html"}},
+		},
+		{
+			// sizes unknown: estimated from the headers, post data and content
+			Request: Request{Method: "POST", URL: "https://api.example.com/v1?x=1", HTTPVersion: "HTTP/1.1", HeaderSize: -1, BodySize: -1,
+				Headers: []NVP{{Name: "Accept", Value: "*/*"}, {Name: ":authority", Value: "api.example.com"}}, PostData: PostData{Text: "hello"}},
+			Response: Response{Status: 201, HTTPVersion: "HTTP/1.1", StatusText: "Created", HeadersSize: -1, BodySize: -1,
+				Content: Content{MimeType: "application/json", Size: 500, Compression: 200}},
+		},
+	}}}
+
+	stats := ComputeStats(har)
+	// "POST" + "/v1?x=1" + "HTTP/1.1" + 4, "Accept: */*\r\n", "\r\n"
+	sent := int64(100 + (4 + 7 + 8 + 4 + 13 + 2) + 5)
+	// "HTTP/1.1" + "Created" + 7, "\r\n", 500 - 200
+	received := int64(200 + 1000 + (8 + 7 + 7 + 2) + 300)
+	if stats.Total.Requests != 2 || stats.Total.Sent != sent || stats.Total.Received != received {
+		t.Errorf("got total %+v, want %d sent, %d received", stats.Total, sent, received)
+	}
+	if c := stats.ByDomain["api.example.com"]; c == nil || c.Requests != 1 || c.Received != 324 {
+		t.Errorf("got api.example.com %+v", c)
+	}
+	if len(stats.ByType) != 2 || len(stats.ByDomain) != 2 {
+		t.Errorf("got types %v, domains %v", stats.ByType, stats.ByDomain)
+	}
+
+	var buf bytes.Buffer
+	if err := Stats(bufio.NewReader(strings.NewReader(`{"log":{"entries":[]}}`)), &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Total") {
+		t.Errorf("got %s", buf.String())
+	}
+}
@@ -0,0 +1,237 @@
+package hargo
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+//go:embed assets/trackers.txt
+var defaultTrackers string
+
+// Blocklist is a set of tracker domains. A domain also matches all of its
+// subdomains.
+type Blocklist map[string]bool
+
+// DefaultBlocklist returns the small list of common advertising and
+// analytics domains bundled with hargo.
+func DefaultBlocklist() Blocklist {
+	list, _ := LoadBlocklist(strings.NewReader(defaultTrackers))
+	return list
+}
+
+// LoadBlocklist reads a domain blocklist. It understands the domain rules
+// of EasyList-style filter lists (||example.com^), hosts files
+// (0.0.0.0 example.com) and plain lists of domains; comments, element
+// hiding, exception and path rules are ignored.
+func LoadBlocklist(r io.Reader) (Blocklist, error) {
+	list := make(Blocklist)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "!") || strings.HasPrefix(line, "#") ||
+			strings.HasPrefix(line, "[") || strings.HasPrefix(line, "@@") || strings.Contains(line, "##") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "||") {
+			line = strings.TrimPrefix(line, "||")
+			if i := strings.IndexByte(line, '$'); i >= 0 {
+				line = line[:i]
+			}
+			line = strings.TrimSuffix(line, "^")
+		} else if fields := strings.Fields(line); len(fields) >= 2 && net.ParseIP(fields[0]) != nil {
+			line = fields[1]
+		}
+
+		domain := strings.ToLower(strings.TrimSuffix(line, "."))
+		if domain == "" || strings.ContainsAny(domain, "/*^|$ ") || !strings.Contains(domain, ".") {
+			continue
+		}
+		list[domain] = true
+	}
+
+	return list, scanner.Err()
+}
+
+// Match returns the blocklist entry matching host or one of its parent
+// domains, or "" if the host is not listed.
+func (b Blocklist) Match(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for {
+		if b[host] {
+			return host
+		}
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			return ""
+		}
+		host = host[i+1:]
+	}
+}
+
+// PartyDomain accounts the traffic to one host.
+type PartyDomain struct {
+	Host       string `json:"host"`
+	Site       string `json:"site"`
+	ThirdParty bool   `json:"thirdParty"`
+	// Tracker is the matching blocklist entry.
+	Tracker string `json:"tracker,omitempty"`
+	ByteCount
+}
+
+// ThirdPartyReport splits the traffic of a .har file into first-party and
+// third-party requests and accounts requests to known trackers.
+type ThirdPartyReport struct {
+	// Sites of the pages, i.e. the registrable domain of their documents.
+	Sites      []string              `json:"sites"`
+	FirstParty ByteCount             `json:"firstParty"`
+	ThirdParty ByteCount             `json:"thirdParty"`
+	Trackers   map[string]*ByteCount `json:"trackers"`
+	Domains    []*PartyDomain        `json:"domains"`
+}
+
+// ThirdPartyOptions control how entries are classified.
+type ThirdPartyOptions struct {
+	// Site overrides the first-party site, which is otherwise taken from
+	// the first request of each page.
+	Site string
+	// Blocklist of tracker domains, the bundled list if nil.
+	Blocklist Blocklist
+}
+
+// AnalyzeThirdParties classifies every entry as first-party or third-party
+// relative to the site of its page and matches third-party hosts against
+// the blocklist. Hosts are compared by registrable domain (eTLD+1), so
+// static.example.com is first-party to www.example.com.
+func AnalyzeThirdParties(har Har, opts ThirdPartyOptions) ThirdPartyReport {
+	blocklist := opts.Blocklist
+	if blocklist == nil {
+		blocklist = DefaultBlocklist()
+	}
+
+	report := ThirdPartyReport{Trackers: make(map[string]*ByteCount)}
+	pageSites := make(map[string]string)
+	domains := make(map[string]*PartyDomain)
+
+	if opts.Site != "" {
+		report.Sites = append(report.Sites, registrableDomain(opts.Site))
+	}
+
+	for _, entry := range har.Log.Entries {
+		host := "unknown"
+		if u, err := url.Parse(entry.Request.URL); err == nil && u.Hostname() != "" {
+			host = strings.ToLower(u.Hostname())
+		}
+		site := registrableDomain(host)
+
+		pageSite := registrableDomain(opts.Site)
+		if opts.Site == "" {
+			var ok bool
+			if pageSite, ok = pageSites[entry.Pageref]; !ok {
+				pageSite = site
+				pageSites[entry.Pageref] = site
+				report.Sites = append(report.Sites, site)
+			}
+		}
+
+		d := domains[host]
+		if d == nil {
+			d = &PartyDomain{Host: host, Site: site}
+			domains[host] = d
+			report.Domains = append(report.Domains, d)
+		}
+		// a host is third-party if it is on any of the pages
+		if site != pageSite && !d.ThirdParty {
+			d.ThirdParty = true
+			d.Tracker = blocklist.Match(host)
+		}
+
+		sent, received := requestBytes(entry.Request), responseBytes(entry.Response)
+		d.add(sent, received)
+		if site == pageSite {
+			report.FirstParty.add(sent, received)
+			continue
+		}
+		report.ThirdParty.add(sent, received)
+		if tracker := blocklist.Match(host); tracker != "" {
+			if report.Trackers[tracker] == nil {
+				report.Trackers[tracker] = &ByteCount{}
+			}
+			report.Trackers[tracker].add(sent, received)
+		}
+	}
+
+	sort.SliceStable(report.Domains, func(i, j int) bool {
+		return report.Domains[i].Received > report.Domains[j].Received
+	})
+
+	return report
+}
+
+// registrableDomain returns the eTLD+1 of a host, or the host itself for IP
+// addresses and single-label names.
+func registrableDomain(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	if site, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return site
+	}
+	return host
+}
+
+// ThirdParties prints the first-party/third-party split of a .har file,
+// the traffic per host and per tracker to w.
+func ThirdParties(r *bufio.Reader, w io.Writer, opts ThirdPartyOptions) error {
+	har, err := Decode(r)
+	if err != nil {
+		return err
+	}
+
+	report := AnalyzeThirdParties(har, opts)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Site\t%s\t\n\n", strings.Join(report.Sites, ", "))
+
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", "Party", "Requests", "Sent (bytes)", "Received (bytes)")
+	fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t\n", "First-party", report.FirstParty.Requests, report.FirstParty.Sent, report.FirstParty.Received)
+	fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t\n", "Third-party", report.ThirdParty.Requests, report.ThirdParty.Sent, report.ThirdParty.Received)
+	fmt.Fprintln(tw)
+
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t\n", "Host", "Party", "Tracker", "Requests", "Sent (bytes)", "Received (bytes)")
+	for _, d := range report.Domains {
+		party := "first"
+		if d.ThirdParty {
+			party = "third"
+		}
+		tracker := d.Tracker
+		if tracker == "" {
+			tracker = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t\n", d.Host, party, tracker, d.Requests, d.Sent, d.Received)
+	}
+
+	if len(report.Trackers) > 0 {
+		fmt.Fprintln(tw)
+		var total ByteCount
+		for _, c := range report.Trackers {
+			total.Requests += c.Requests
+			total.Sent += c.Sent
+			total.Received += c.Received
+		}
+		writeByteCounts(tw, "Tracker", report.Trackers, total)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,65 @@
+package hargo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadBlocklist(t *testing.T) {
+	list, err := LoadBlocklist(strings.NewReader(`! comment
+||ads.example^
+||metrics.example^$third-party
+@@||ads.example/allowed^
+example.org##.banner
+||example.net/pixel.gif
+0.0.0.0 tracker.example
+plain.example
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for host, expected := range map[string]string{
+		"ads.example":          "ads.example",
+		"cdn.ads.example":      "ads.example",
+		"metrics.example":      "metrics.example",
+		"tracker.example":      "tracker.example",
+		"plain.example":        "plain.example",
+		"example.net":          "",
+		"notads.example":       "",
+		"www.google-analytics": "",
+	} {
+		if got := list.Match(host); got != expected {
+			t.Errorf("Match(%s) = %q, expected %q", host, got, expected)
+		}
+	}
+}
+
+func TestAnalyzeThirdParties(t *testing.T) {
+	entry := func(pageref, url string) Entry {
+		return Entry{Pageref: pageref, Request: Request{URL: url, HeaderSize: 100, BodySize: 0}, Response: Response{HeadersSize: 100, BodySize: 1000}}
+	}
+	har := Har{Log: Log{Entries: []Entry{
+		entry("page_1", "https://www.example.co.uk/"),
+		entry("page_1", "https://static.example.co.uk/app.js"),
+		entry("page_1", "https://www.google-analytics.com/collect"),
+		entry("page_1", "https://fonts.gstatic.com/font.woff2"),
+		entry("page_2", "https://shop.example.com/"),
+		entry("page_2", "https://www.example.co.uk/logo.png"),
+	}}}
+
+	report := AnalyzeThirdParties(har, ThirdPartyOptions{})
+
+	if strings.Join(report.Sites, ",") != "example.co.uk,example.com" {
+		t.Errorf("unexpected sites %v", report.Sites)
+	}
+	if report.FirstParty.Requests != 3 || report.ThirdParty.Requests != 3 {
+		t.Errorf("expected 3 first-party and 3 third-party requests, got %d and %d", report.FirstParty.Requests, report.ThirdParty.Requests)
+	}
+	if c := report.Trackers["google-analytics.com"]; c == nil || c.Requests != 1 || c.Received != 1100 {
+		t.Errorf("unexpected tracker counts %+v", report.Trackers)
+	}
+	if len(report.Trackers) != 1 {
+		t.Errorf("expected 1 tracker, got %d", len(report.Trackers))
+	}
+}
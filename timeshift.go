@@ -0,0 +1,59 @@
+package hargo
+
+import "time"
+
+// CaptureStart returns the earliest startedDateTime of the pages and
+// entries of a Har, and false if none of them can be parsed.
+func CaptureStart(har Har) (time.Time, bool) {
+	var start time.Time
+	found := false
+	visit := func(s string) {
+		t, err := parseStartedDateTime(s)
+		if err == nil && (!found || t.Before(start)) {
+			start, found = t, true
+		}
+	}
+	for _, page := range har.Log.Pages {
+		visit(page.StartedDateTime)
+	}
+	for _, entry := range har.Log.Entries {
+		visit(entry.StartedDateTime)
+	}
+	return start, found
+}
+
+// TimeShift moves the startedDateTime of every page and entry of a Har in
+// place so the capture begins at start, keeping the offsets between them,
+// and returns the shift applied. Timestamps are written in the time zone
+// of start, so shifting to time.Unix(0, 0).UTC() hides both when and where
+// a capture was made, and shifting two captures to the same start aligns
+// them for a diff. Unparsable timestamps are left as they are, as are
+// dates in headers and cookies.
+func TimeShift(har *Har, start time.Time) time.Duration {
+	first, ok := CaptureStart(*har)
+	if !ok {
+		return 0
+	}
+	shift := start.Sub(first)
+	move := func(s *string) {
+		if t, err := parseStartedDateTime(*s); err == nil {
+			*s = formatStartedDateTime(t.Add(shift).In(start.Location()))
+		}
+	}
+	for i := range har.Log.Pages {
+		move(&har.Log.Pages[i].StartedDateTime)
+	}
+	for i := range har.Log.Entries {
+		move(&har.Log.Entries[i].StartedDateTime)
+	}
+	return shift
+}
+
+// formatStartedDateTime formats t with millisecond precision like browsers
+// do, or with the full precision of t if it has sub-millisecond digits.
+func formatStartedDateTime(t time.Time) string {
+	if t.Nanosecond()%int(time.Millisecond) != 0 {
+		return t.Format(time.RFC3339Nano)
+	}
+	return t.Format(harDateTimeLayout)
+}
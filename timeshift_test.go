@@ -0,0 +1,57 @@
+package hargo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeShift(t *testing.T) {
+	har := Har{Log: Log{
+		Pages: []Page{{ID: "page_1", StartedDateTime: "2024-01-02T12:00:00.500+02:00"}},
+		Entries: []Entry{
+			{StartedDateTime: "2024-01-02T10:00:01.250Z"},
+			{StartedDateTime: "2024-01-02T10:00:00.000Z"},
+			{StartedDateTime: "2024-01-02T10:00:02.0000015Z"},
+			{StartedDateTime: "bad"},
+		},
+	}}
+
+	if start, ok := CaptureStart(har); !ok || !start.Equal(time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("got start %v %v", start, ok)
+	}
+
+	shift := TimeShift(&har, time.Unix(0, 0).UTC())
+	if expected := -time.Duration(time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC).UnixNano()); shift != expected {
+		t.Errorf("got shift %v, want %v", shift, expected)
+	}
+	tests := []struct {
+		got, expected string
+	}{
+		{har.Log.Pages[0].StartedDateTime, "1970-01-01T00:00:00.500Z"},
+		{har.Log.Entries[0].StartedDateTime, "1970-01-01T00:00:01.250Z"},
+		{har.Log.Entries[1].StartedDateTime, "1970-01-01T00:00:00.000Z"},
+		{har.Log.Entries[2].StartedDateTime, "1970-01-01T00:00:02.0000015Z"},
+		{har.Log.Entries[3].StartedDateTime, "bad"},
+	}
+	for i, test := range tests {
+		if test.got != test.expected {
+			t.Errorf("%d: got %s, want %s", i, test.got, test.expected)
+		}
+	}
+
+	// shifting again to the same start changes nothing
+	if shift := TimeShift(&har, time.Unix(0, 0).UTC()); shift != 0 || har.Log.Entries[0].StartedDateTime != "1970-01-01T00:00:01.250Z" {
+		t.Errorf("got shift %v, %s", shift, har.Log.Entries[0].StartedDateTime)
+	}
+
+	// timestamps are written in the time zone of the start
+	TimeShift(&har, time.Date(2025, 6, 1, 9, 0, 0, 0, time.FixedZone("", -5*3600)))
+	if got := har.Log.Entries[0].StartedDateTime; got != "2025-06-01T09:00:01.250-05:00" {
+		t.Errorf("got %s", got)
+	}
+
+	empty := Har{Log: Log{Entries: []Entry{{StartedDateTime: "bad"}}}}
+	if _, ok := CaptureStart(empty); ok || TimeShift(&empty, time.Now()) != 0 {
+		t.Error("expected no start")
+	}
+}
@@ -0,0 +1,220 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// tnetstrings (https://tnetstrings.info) are the serialization format of
+// mitmproxy flow files. Values decode to map[string]interface{},
+// []interface{}, []byte (byte strings), string (unicode strings), int64,
+// float64, bool or nil.
+
+// maxTnetstringLength bounds the size of a single value to guard against
+// corrupt input.
+const maxTnetstringLength = 1 << 30
+
+// readTnetstring reads the next value from r. It returns io.EOF when r is
+// exhausted before a new value starts.
+func readTnetstring(r *bufio.Reader) (interface{}, error) {
+	prefix, err := r.ReadSlice(':')
+	if err != nil {
+		if err == io.EOF && len(bytes.TrimSpace(prefix)) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("tnetstring: invalid length prefix: %w", err)
+	}
+	length, err := strconv.Atoi(string(bytes.TrimSpace(prefix[:len(prefix)-1])))
+	if err != nil || length < 0 || length > maxTnetstringLength {
+		return nil, errors.New("tnetstring: invalid length prefix")
+	}
+
+	data := make([]byte, length+1)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("tnetstring: truncated value: %w", err)
+	}
+	return parseTnetstring(data[:length], data[length])
+}
+
+// parseTnetstring parses the payload of a value of the given type.
+func parseTnetstring(data []byte, kind byte) (interface{}, error) {
+	switch kind {
+	case ',':
+		return data, nil
+	case ';':
+		return string(data), nil
+	case '#':
+		return strconv.ParseInt(string(data), 10, 64)
+	case '^':
+		return strconv.ParseFloat(string(data), 64)
+	case '!':
+		return string(data) == "true", nil
+	case '~':
+		return nil, nil
+	case ']':
+		list := []interface{}{}
+		r := bufio.NewReader(bytes.NewReader(data))
+		for {
+			v, err := readTnetstring(r)
+			if err == io.EOF {
+				return list, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+	case '}':
+		dict := map[string]interface{}{}
+		r := bufio.NewReader(bytes.NewReader(data))
+		for {
+			k, err := readTnetstring(r)
+			if err == io.EOF {
+				return dict, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			v, err := readTnetstring(r)
+			if err != nil {
+				return nil, err
+			}
+			dict[tnetString(k)] = v
+		}
+	default:
+		return nil, fmt.Errorf("tnetstring: unknown type %q", kind)
+	}
+}
+
+// writeTnetstring appends the encoding of v to buf. Dictionary keys are
+// written as unicode strings in sorted order.
+func writeTnetstring(buf *bytes.Buffer, v interface{}) error {
+	var (
+		payload []byte
+		kind    byte
+	)
+
+	switch v := v.(type) {
+	case nil:
+		kind = '~'
+	case []byte:
+		payload, kind = v, ','
+	case string:
+		payload, kind = []byte(v), ';'
+	case int:
+		payload, kind = strconv.AppendInt(nil, int64(v), 10), '#'
+	case int64:
+		payload, kind = strconv.AppendInt(nil, v, 10), '#'
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return errors.New("tnetstring: cannot encode non-finite float")
+		}
+		payload, kind = strconv.AppendFloat(nil, v, 'f', -1, 64), '^'
+		if !bytes.ContainsAny(payload, ".e") {
+			payload = append(payload, ".0"...)
+		}
+	case bool:
+		payload, kind = strconv.AppendBool(nil, v), '!'
+	case []interface{}:
+		var items bytes.Buffer
+		for _, item := range v {
+			if err := writeTnetstring(&items, item); err != nil {
+				return err
+			}
+		}
+		payload, kind = items.Bytes(), ']'
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var items bytes.Buffer
+		for _, k := range keys {
+			if err := writeTnetstring(&items, k); err != nil {
+				return err
+			}
+			if err := writeTnetstring(&items, v[k]); err != nil {
+				return err
+			}
+		}
+		payload, kind = items.Bytes(), '}'
+	default:
+		return fmt.Errorf("tnetstring: cannot encode %T", v)
+	}
+
+	buf.WriteString(strconv.Itoa(len(payload)))
+	buf.WriteByte(':')
+	buf.Write(payload)
+	buf.WriteByte(kind)
+	return nil
+}
+
+// tnetString returns a byte or unicode string value as a string.
+func tnetString(v interface{}) string {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	default:
+		return ""
+	}
+}
+
+// tnetBytes returns a byte or unicode string value as bytes.
+func tnetBytes(v interface{}) []byte {
+	switch v := v.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	default:
+		return nil
+	}
+}
+
+// tnetFloat returns a numeric value as a float64, or -1 if it is missing.
+func tnetFloat(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case int64:
+		return float64(v)
+	default:
+		return -1
+	}
+}
+
+// tnetInt returns a numeric value as an int.
+func tnetInt(v interface{}) int {
+	switch v := v.(type) {
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// tnetDict returns a dictionary value, or an empty one.
+func tnetDict(v interface{}) map[string]interface{} {
+	if d, ok := v.(map[string]interface{}); ok {
+		return d
+	}
+	return map[string]interface{}{}
+}
+
+// tnetList returns a list value, or nil.
+func tnetList(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
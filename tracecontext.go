@@ -0,0 +1,259 @@
+package hargo
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// TraceContext is the distributed tracing context recorded for an entry,
+// which joins it to the logs and traces of the backend that served it.
+type TraceContext struct {
+	// Entry is the 0-based position of the entry in the .har file as
+	// recorded, before Decode sorts entries by startedDateTime.
+	Entry  int    `json:"entry"`
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+	// TraceID and SpanID are in the 32 and 16 hex digit W3C format, or as
+	// recorded where they cannot be converted.
+	TraceID string `json:"traceId,omitempty"`
+	SpanID  string `json:"spanId,omitempty"`
+	// Source is the header the trace id was read from.
+	Source string `json:"source,omitempty"`
+	// Response is set if Source is a response header: the trace was started
+	// by the backend rather than propagated by the client.
+	Response bool `json:"response,omitempty"`
+	// RequestIDs are the values of request id headers like X-Request-ID by
+	// lower case header name.
+	RequestIDs map[string]string `json:"requestIds,omitempty"`
+}
+
+// traceHeaders are the headers carrying trace ids, in order of preference.
+var traceHeaders = []string{"traceparent", "traceresponse", "b3", "x-b3-traceid", "uber-trace-id", "x-amzn-trace-id", "x-cloud-trace-context"}
+
+// requestIDHeaders are the headers carrying request ids.
+var requestIDHeaders = []string{"x-request-id", "x-correlation-id", "request-id", "x-amz-request-id", "x-amz-cf-id", "cf-ray"}
+
+var (
+	hexTraceID  = regexp.MustCompile(`^[0-9a-f]{16}([0-9a-f]{16})?$`)
+	hexSpanID   = regexp.MustCompile(`^[0-9a-f]{16}$`)
+	xrayTraceID = regexp.MustCompile(`^1-([0-9a-f]{8})-([0-9a-f]{24})$`)
+)
+
+// EntryTraceContext returns the trace context of an entry, preferring the
+// request headers to the response headers, and whether it has any.
+func EntryTraceContext(index int, entry Entry) (TraceContext, bool) {
+	tc := TraceContext{Entry: index, Method: entry.Request.Method, URL: entry.Request.URL, Status: entry.Response.Status}
+
+	for _, side := range []struct {
+		headers  []NVP
+		response bool
+	}{{entry.Request.Headers, false}, {entry.Response.Headers, true}} {
+		for _, name := range requestIDHeaders {
+			if v := strings.TrimSpace(recordedHeader(side.headers, name)); v != "" {
+				if tc.RequestIDs == nil {
+					tc.RequestIDs = make(map[string]string)
+				}
+				if _, ok := tc.RequestIDs[name]; !ok {
+					tc.RequestIDs[name] = v
+				}
+			}
+		}
+		if tc.TraceID != "" {
+			continue
+		}
+		for _, name := range traceHeaders {
+			if traceID, spanID, ok := parseTraceHeader(name, side.headers); ok {
+				tc.TraceID, tc.SpanID, tc.Source, tc.Response = traceID, spanID, name, side.response
+				break
+			}
+		}
+	}
+
+	return tc, tc.TraceID != "" || len(tc.RequestIDs) > 0
+}
+
+// parseTraceHeader reads the trace and span id from the named header.
+func parseTraceHeader(name string, headers []NVP) (traceID, spanID string, ok bool) {
+	value := strings.ToLower(strings.TrimSpace(recordedHeader(headers, name)))
+	if value == "" {
+		return "", "", false
+	}
+
+	switch name {
+	case "traceparent", "traceresponse":
+		// version-traceid-spanid-flags
+		if m := traceparentPattern.FindStringSubmatch(value); m != nil {
+			return m[1], m[2], true
+		}
+	case "b3":
+		// traceid-spanid[-sampled[-parentspanid]]
+		parts := strings.Split(value, "-")
+		if len(parts) >= 2 && hexTraceID.MatchString(parts[0]) && hexSpanID.MatchString(parts[1]) {
+			return padTraceID(parts[0]), parts[1], true
+		}
+	case "x-b3-traceid":
+		spanID := strings.ToLower(strings.TrimSpace(recordedHeader(headers, "x-b3-spanid")))
+		if hexTraceID.MatchString(value) {
+			if !hexSpanID.MatchString(spanID) {
+				spanID = ""
+			}
+			return padTraceID(value), spanID, true
+		}
+	case "uber-trace-id":
+		// traceid:spanid:parentspanid:flags, with leading zeros omitted
+		parts := strings.Split(strings.ReplaceAll(value, "%3a", ":"), ":")
+		if len(parts) == 4 && len(parts[0]) <= 32 && len(parts[1]) <= 16 && isHex(parts[0]) && isHex(parts[1]) {
+			return fmt.Sprintf("%032s", parts[0]), fmt.Sprintf("%016s", parts[1]), true
+		}
+	case "x-amzn-trace-id":
+		// Root=1-epoch-id;Parent=spanid;Sampled=1
+		for _, field := range strings.Split(value, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(field), "=")
+			switch k {
+			case "root":
+				if m := xrayTraceID.FindStringSubmatch(v); m != nil {
+					traceID = m[1] + m[2]
+				}
+			case "parent":
+				if hexSpanID.MatchString(v) {
+					spanID = v
+				}
+			}
+		}
+		return traceID, spanID, traceID != ""
+	case "x-cloud-trace-context":
+		// traceid/decimal spanid;o=1
+		trace, _, _ := strings.Cut(value, ";")
+		trace, span, _ := strings.Cut(trace, "/")
+		if len(trace) == 32 && isHex(trace) {
+			if id, err := strconv.ParseUint(span, 10, 64); err == nil {
+				spanID = fmt.Sprintf("%016x", id)
+			}
+			return trace, spanID, true
+		}
+	}
+	return "", "", false
+}
+
+// padTraceID widens a 64-bit trace id to 128 bits.
+func padTraceID(id string) string {
+	return fmt.Sprintf("%032s", id)
+}
+
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// TraceContexts returns the trace contexts of all entries of har that have
+// one, numbered by their position in har.Log.Entries.
+func TraceContexts(har Har) []TraceContext {
+	var contexts []TraceContext
+	for i, entry := range har.Log.Entries {
+		if tc, ok := EntryTraceContext(i, entry); ok {
+			contexts = append(contexts, tc)
+		}
+	}
+	return contexts
+}
+
+// TraceCorrelation writes the trace contexts of a .har file to w, as a
+// table, or with format "csv" or "json" for joining with backend logs.
+// Entries are numbered in the order of the file, which Decode does not keep.
+func TraceCorrelation(r *bufio.Reader, w io.Writer, format string) error {
+	return TraceCorrelationWithSelection(r, w, format, Selection{})
+}
+
+// TraceCorrelationWithSelection is TraceCorrelation writing only the trace
+// contexts of the entries selected by selection, still numbered in the
+// order of the whole file.
+func TraceCorrelationWithSelection(r *bufio.Reader, w io.Writer, format string, selection Selection) error {
+	har, err := readHar(r)
+	if err != nil {
+		return err
+	}
+
+	var contexts []TraceContext
+	for _, tc := range TraceContexts(har) {
+		if selection.Includes(tc.Entry, har.Log.Entries[tc.Entry]) {
+			contexts = append(contexts, tc)
+		}
+	}
+	switch format {
+	case "", "text":
+	case "json":
+		if contexts == nil {
+			contexts = []TraceContext{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(contexts)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"entry", "method", "url", "status", "trace_id", "span_id", "source", "request_ids"})
+		for _, tc := range contexts {
+			cw.Write([]string{strconv.Itoa(tc.Entry), tc.Method, tc.URL, strconv.Itoa(tc.Status), tc.TraceID, tc.SpanID, tc.source(), tc.requestIDs()})
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unknown format %s", format)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", "#", "Method", "URL", "Status", "Trace ID", "Span ID", "Source", "Request IDs")
+	for _, tc := range contexts {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t\n", tc.Entry, tc.Method, shortURL(tc.URL), tc.Status, tc.TraceID, tc.SpanID, tc.source(), tc.requestIDs())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%d of %d entries carry trace context\n", len(contexts), len(har.Log.Entries))
+	return err
+}
+
+// source describes the header of the trace id with its direction.
+func (tc TraceContext) source() string {
+	switch {
+	case tc.Source == "":
+		return ""
+	case tc.Response:
+		return "response " + tc.Source
+	}
+	return "request " + tc.Source
+}
+
+// requestIDNames returns the names of the request id headers, sorted.
+func (tc TraceContext) requestIDNames() []string {
+	names := make([]string, 0, len(tc.RequestIDs))
+	for name := range tc.RequestIDs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// requestIDs formats the request ids as name=value pairs.
+func (tc TraceContext) requestIDs() string {
+	names := tc.requestIDNames()
+	for i, name := range names {
+		names[i] = name + "=" + tc.RequestIDs[name]
+	}
+	return strings.Join(names, " ")
+}
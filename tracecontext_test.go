@@ -0,0 +1,118 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEntryTraceContext(t *testing.T) {
+	tests := []struct {
+		request, response []NVP
+		traceID, spanID   string
+		source            string
+		fromResponse      bool
+	}{
+		{[]NVP{{Name: "traceparent", Value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}, nil,
+			"4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", "traceparent", false},
+		{[]NVP{{Name: "b3", Value: "80f198ee56343ba8-e457b5a2e4d86bd1-1"}}, nil,
+			"000000000000000080f198ee56343ba8", "e457b5a2e4d86bd1", "b3", false},
+		{[]NVP{{Name: "X-B3-TraceId", Value: "463ac35c9f6413ad48485a3953bb6124"}, {Name: "X-B3-SpanId", Value: "a2fb4a1d1a96d312"}}, nil,
+			"463ac35c9f6413ad48485a3953bb6124", "a2fb4a1d1a96d312", "x-b3-traceid", false},
+		{[]NVP{{Name: "uber-trace-id", Value: "5cf4a2b1%3A1e2f%3A0%3A1"}}, nil,
+			"0000000000000000000000005cf4a2b1", "0000000000001e2f", "uber-trace-id", false},
+		{nil, []NVP{{Name: "X-Amzn-Trace-Id", Value: "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"}},
+			"5759e988bd862e3fe1be46a994272793", "53995c3f42cd8ad8", "x-amzn-trace-id", true},
+		{[]NVP{{Name: "X-Cloud-Trace-Context", Value: "105445aa7843bc8bf206b12000100000/1;o=1"}}, nil,
+			"105445aa7843bc8bf206b12000100000", "0000000000000001", "x-cloud-trace-context", false},
+		// the request is preferred to the response
+		{[]NVP{{Name: "b3", Value: "80f198ee56343ba8-e457b5a2e4d86bd1"}},
+			[]NVP{{Name: "traceresponse", Value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}},
+			"000000000000000080f198ee56343ba8", "e457b5a2e4d86bd1", "b3", false},
+		{[]NVP{{Name: "traceparent", Value: "not a traceparent"}}, nil, "", "", "", false},
+	}
+
+	for i, test := range tests {
+		entry := Entry{Request: Request{Headers: test.request}, Response: Response{Headers: test.response}}
+		tc, ok := EntryTraceContext(i, entry)
+		if ok != (test.traceID != "") {
+			t.Errorf("%d: got ok %v", i, ok)
+		}
+		if tc.Entry != i || tc.TraceID != test.traceID || tc.SpanID != test.spanID || tc.Source != test.source || tc.Response != test.fromResponse {
+			t.Errorf("%d: got %+v", i, tc)
+		}
+	}
+
+	entry := Entry{
+		Request:  Request{Headers: []NVP{{Name: "X-Request-ID", Value: "req-1"}}},
+		Response: Response{Headers: []NVP{{Name: "x-request-id", Value: "req-2"}, {Name: "CF-Ray", Value: "8a1b2c3d4e5f-AMS"}}},
+	}
+	tc, ok := EntryTraceContext(0, entry)
+	if !ok || tc.TraceID != "" {
+		t.Errorf("got %+v, %v", tc, ok)
+	}
+	if got := tc.requestIDs(); got != "cf-ray=8a1b2c3d4e5f-AMS x-request-id=req-1" {
+		t.Errorf("got request ids %s", got)
+	}
+}
+
+func TestTraceCorrelation(t *testing.T) {
+	data, err := os.ReadFile("test/traces.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := TraceCorrelation(bufio.NewReader(bytes.NewReader(data)), &buf, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var contexts []TraceContext
+	if err := json.Unmarshal(buf.Bytes(), &contexts); err != nil {
+		t.Fatal(err)
+	}
+	// entries keep their position in the file although they are not sorted
+	if len(contexts) != 2 || contexts[0].Entry != 0 || contexts[0].TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+		contexts[1].Entry != 2 || contexts[1].RequestIDs["x-request-id"] != "abc" {
+		t.Errorf("got %+v", contexts)
+	}
+
+	buf.Reset()
+	if err := TraceCorrelation(bufio.NewReader(bytes.NewReader(data)), &buf, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[1][6] != "request traceparent" || records[2][7] != "x-request-id=abc" {
+		t.Errorf("got %v", records)
+	}
+
+	buf.Reset()
+	if err := TraceCorrelation(bufio.NewReader(bytes.NewReader(data)), &buf, "text"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "2 of 3 entries carry trace context") {
+		t.Errorf("got %s", buf.String())
+	}
+
+	if err := TraceCorrelation(bufio.NewReader(bytes.NewReader(data)), &buf, "xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+
+	buf.Reset()
+	if err := TraceCorrelationWithSelection(bufio.NewReader(bytes.NewReader(data)), &buf, "json", Selection{First: 2}); err != nil {
+		t.Fatal(err)
+	}
+	contexts = nil
+	if err := json.Unmarshal(buf.Bytes(), &contexts); err != nil {
+		t.Fatal(err)
+	}
+	if len(contexts) != 1 || contexts[0].Entry != 2 {
+		t.Errorf("got %+v", contexts)
+	}
+}
@@ -0,0 +1,127 @@
+package hargo
+
+import (
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	ca := filepath.Join(dir, "ca.pem")
+	err := os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	transport, err := newTransport(Config{Transport: TransportOptions{
+		MaxIdleConnsPerHost: 50,
+		DisableKeepAlives:   true,
+		SessionResumption:   true,
+		RootCAs:             []string{ca},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.MaxIdleConnsPerHost != 50 || !transport.DisableKeepAlives || transport.TLSClientConfig.ClientSessionCache == nil {
+		t.Errorf("options not applied: %+v", transport)
+	}
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	transport, _ = newTransport(Config{})
+	if _, err := (&http.Client{Transport: transport}).Get(server.URL); err == nil {
+		t.Error("untrusted certificate accepted")
+	}
+
+	for _, o := range []TransportOptions{
+		{RootCAs: []string{empty}},
+		{RootCAs: []string{filepath.Join(dir, "missing.pem")}},
+		{ClientCert: ca},
+		{MaxConnsPerHost: -1},
+	} {
+		if _, err := newTransport(Config{Transport: o}); err == nil {
+			t.Errorf("%+v accepted", o)
+		}
+	}
+}
+
+func TestTransportProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String()+" "+r.Header.Get("Proxy-Authorization"))
+	}))
+	defer proxy.Close()
+
+	proxyURL := strings.Replace(proxy.URL, "http://", "http://user:secret@", 1)
+	transport, err := newTransport(Config{Transport: TransportOptions{Proxy: proxyURL, NoProxy: []string{".internal.test"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get("http://www.example.test/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if _, err := client.Get("http://api.internal.test/"); err == nil {
+		t.Error("request for a no-proxy host reached the proxy")
+	}
+
+	want := []string{"http://www.example.test/page Basic dXNlcjpzZWNyZXQ="}
+	if !reflect.DeepEqual(proxied, want) {
+		t.Errorf("proxied %q, want %q", proxied, want)
+	}
+
+	for _, p := range []string{"ftp://proxy:21", "http://"} {
+		if _, err := newTransport(Config{Transport: TransportOptions{Proxy: p}}); err == nil {
+			t.Errorf("proxy %q accepted", p)
+		}
+	}
+}
+
+func TestTransportResolve(t *testing.T) {
+	var hosts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+	}))
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	transport, err := newTransport(Config{Transport: TransportOptions{Resolve: []string{"www.example.test:" + port + ":127.0.0.1"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := (&http.Client{Transport: transport}).Get("http://WWW.example.test:" + port + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want := []string{"WWW.example.test:" + port}; !reflect.DeepEqual(hosts, want) {
+		t.Errorf("hosts %q, want %q", hosts, want)
+	}
+
+	for _, entry := range []string{"www.example.test:443", "www.example.test:443:staging", ":443:10.0.0.1"} {
+		if _, err := newTransport(Config{Transport: TransportOptions{Resolve: []string{entry}}}); err == nil {
+			t.Errorf("%q accepted", entry)
+		}
+	}
+}
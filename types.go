@@ -1,6 +1,9 @@
 package hargo
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 /*
 HTTP Archive (HAR) format
@@ -10,6 +13,8 @@ https://w3c.github.io/web-performance/specs/HAR/Overview.html
 // Har is a container type for deserialization
 type Har struct {
 	Log Log `json:"log"`
+	// Fields hargo does not model. See extras.go.
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // Log represents the root of the exported data. This object MUST be present and its name MUST be "log".
@@ -37,6 +42,8 @@ type Log struct {
 	// application should always make sure the array is sorted (if required for
 	// the import).
 	Comment string `json:"comment"`
+
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // Creator contains information about the log creator application
@@ -47,6 +54,8 @@ type Creator struct {
 	Version string `json:"version"`
 	// Optional. A comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // Browser that created the log
@@ -57,6 +66,8 @@ type Browser struct {
 	Version string `json:"version"`
 	// Optional. A comment provided by the user or the browser.
 	Comment string `json:"comment"`
+
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // Page object for every exported web page and one <entry> object for every HTTP request.
@@ -77,9 +88,11 @@ type Page struct {
 	// Page title.
 	Title string `json:"title"`
 	// Detailed timing info about page load.
-	PageTiming PageTiming `json:"pageTiming"`
+	PageTiming PageTiming `json:"pageTimings"`
 	// (new in 1.2) A comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // PageTiming describes timings for various events (states) fired during the page load.
@@ -90,13 +103,15 @@ type PageTiming struct {
 	// request.
 	// Depeding on the browser, onContentLoad property represents DOMContentLoad
 	// event or document.readyState == interactive.
-	OnContentLoad int `json:"onContentLoad"`
+	OnContentLoad float64 `json:"onContentLoad"`
 	// Page is loaded (onLoad event fired). Number of milliseconds since page
 	// load started (page.startedDateTime). Use -1 if the timing does not apply
 	// to the current request.
-	OnLoad int `json:"onLoad"`
+	OnLoad float64 `json:"onLoad"`
 	// (new in 1.2) A comment provided by the user or the application.
 	Comment string `json:"comment"`
+
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // Entry is a unique, optional Reference to the parent page.
@@ -116,7 +131,7 @@ type Entry struct {
 	// Info about cache usage.
 	Cache Cache `json:"cache"`
 	// Detailed timing info about request/response round trip.
-	PageTimings PageTimings `json:"pageTimings"`
+	Timings PageTimings `json:"timings"`
 	// optional (new in 1.2) IP address of the server that was connected
 	// (result of DNS resolution).
 	ServerIPAddress string `json:"serverIPAddress,omitempty"`
@@ -129,6 +144,67 @@ type Entry struct {
 	Connection string `json:"connection,omitempty"`
 	// (new in 1.2) A comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+	// optional (community enhancement) Arrival time and size of each chunk
+	// of a streamed response body, used by WebPageTest.
+	Chunks []Chunk `json:"_chunks,omitempty"`
+	// optional (Chrome extension) What caused the request to be sent.
+	Initiator *Initiator `json:"_initiator,omitempty"`
+	// optional (Chrome extension) How the browser classified the resource
+	// (document, script, fetch, eventsource, ...).
+	ResourceType string `json:"_resourceType,omitempty"`
+	// optional (Chrome extension) Frames exchanged over a WebSocket
+	// connection opened by this request.
+	WebSocketMessages []WebSocketMessage `json:"_webSocketMessages,omitempty"`
+	// optional (Chrome extension) Network priority of the request
+	// (VeryHigh, High, Medium, Low, VeryLow).
+	Priority string `json:"_priority,omitempty"`
+	// optional (Chrome extension) Cache the response was served from,
+	// "memory" or "disk". Leave out this field for network responses.
+	FromCache string `json:"_fromCache,omitempty"`
+	// optional (WebPageTest extension) Application protocol negotiated for
+	// the connection: http/1.1, h2, h3...
+	Protocol string `json:"_protocol,omitempty"`
+	// optional (WebPageTest extension) ID of the HTTP/2 or HTTP/3 stream
+	// that carried the request.
+	StreamID int `json:"_http2_stream_id,omitempty"`
+
+	Extras map[string]json.RawMessage `json:"-"`
+}
+
+// WebSocketMessage is a frame sent or received over a WebSocket connection,
+// as recorded by Chrome.
+type WebSocketMessage struct {
+	// Direction of the frame: "send" or "receive".
+	Type string `json:"type"`
+	// Time the frame was sent or received, in seconds since the epoch.
+	Time float64 `json:"time"`
+	// WebSocket opcode: 1 for text frames, 2 for binary frames.
+	Opcode int `json:"opcode"`
+	// Payload of the frame. Binary payloads are base64 encoded.
+	Data string `json:"data"`
+}
+
+// Initiator describes what caused a request, as recorded by Chrome.
+type Initiator struct {
+	// Type of initiator: parser, script, preload, redirect, other, ...
+	Type string `json:"type"`
+	// optional - URL of the document or script that initiated the request.
+	URL string `json:"url,omitempty"`
+	// optional - Line number in the initiating document or script.
+	LineNumber *int `json:"lineNumber,omitempty"`
+	// optional - JavaScript stack trace of script initiators, kept verbatim.
+	Stack json.RawMessage `json:"stack,omitempty"`
+
+	Extras map[string]json.RawMessage `json:"-"`
+}
+
+// Chunk records a piece of a streamed response body as it arrived.
+type Chunk struct {
+	// Arrival time in milliseconds. Only the differences between the chunks
+	// of an entry are meaningful.
+	Ts float64 `json:"ts"`
+	// Number of bytes received in this chunk.
+	Bytes int `json:"bytes"`
 }
 
 // Request contains detailed info about performed request.
@@ -150,12 +226,18 @@ type Request struct {
 	// Total number of bytes from the start of the HTTP request message until
 	// (and including) the double CRLF before the body. Set to -1 if the info
 	// is not available.
-	HeaderSize int `json:"headerSize"`
+	HeaderSize int `json:"headersSize"`
+	// optional (community enhancement) Size of the headers before HPACK or
+	// QPACK compression. For HTTP/2 and HTTP/3 headersSize is the compressed
+	// size.
+	HeadersUncompressedSize int `json:"_headersUncompressedSize,omitempty"`
 	// Size of the request body (POST data payload) in bytes. Set to -1 if the
 	// info is not available.
 	BodySize int `json:"bodySize"`
 	// (new in 1.2) A comment provided by the user or the application.
 	Comment string `json:"comment"`
+
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // Response contains detailed info about the response.
@@ -182,12 +264,27 @@ type Response struct {
 	// browser are not included in this number, but they appear in the list of
 	// header objects.
 	HeadersSize int `json:"headersSize"`
+	// optional (community enhancement) Size of the headers before HPACK or
+	// QPACK compression. For HTTP/2 and HTTP/3 headersSize is the compressed
+	// size.
+	HeadersUncompressedSize int `json:"_headersUncompressedSize,omitempty"`
 	// Size of the received response body in bytes. Set to zero in case of
 	// responses coming from the cache (304). Set to -1 if the info is not
 	// available.
 	BodySize int `json:"bodySize"`
 	// optional (new in 1.2) A comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+	// optional (Chrome extension) Bytes received over the network for the
+	// response, including headers and after content encoding.
+	TransferSize int `json:"_transferSize,omitempty"`
+	// optional (Chrome extension) Network error of a failed request, e.g.
+	// net::ERR_CONNECTION_REFUSED.
+	Error string `json:"_error,omitempty"`
+	// optional (Chrome extension) True if a service worker handled the
+	// request.
+	FetchedViaServiceWorker bool `json:"_fetchedViaServiceWorker,omitempty"`
+
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // Cookie contains list of all cookies (used in <request> and <response> objects).
@@ -210,6 +307,8 @@ type Cookie struct {
 	Secure bool `json:"secure,omitempty"`
 	// optional (new in 1.2) A comment provided by the user or the application.
 	Comment bool `json:"comment,omitempty"`
+
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // NVP is simply a name/value pair with a comment
@@ -217,6 +316,8 @@ type NVP struct {
 	Name    string `json:"name"`
 	Value   string `json:"value"`
 	Comment string `json:"comment,omitempty"`
+
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // PostData describes posted data, if any (embedded in <request> object).
@@ -230,6 +331,8 @@ type PostData struct {
 	// optional (new in 1.2) A comment provided by the user or the
 	// application.
 	Comment string `json:"comment,omitempty"`
+
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // PostParam is a list of posted parameters, if any (embedded in <postData> object).
@@ -244,6 +347,8 @@ type PostParam struct {
 	ContentType string `json:"contentType,omitempty"`
 	// optional (new in 1.2) A comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // Content describes details about response content (embedded in <response> object).
@@ -275,6 +380,8 @@ type Content struct {
 	// optional (community enhancement) A path to an attached file containing this content
 	// used by Playwright
 	File string `json:"_file,omitempty"`
+
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // Cache contains info about a request coming from browser cache.
@@ -287,6 +394,8 @@ type Cache struct {
 	AfterRequest CacheObject `json:"afterRequest,omitempty"`
 	// optional (new in 1.2) A comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // CacheObject is used by both beforeRequest and afterRequest
@@ -301,33 +410,39 @@ type CacheObject struct {
 	HitCount int `json:"hitCount"`
 	// optional (new in 1.2) A comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // PageTimings describes various phases within request-response round trip.
 // All times are specified in milliseconds.
 type PageTimings struct {
-	Blocked int `json:"blocked,omitempty"`
+	Blocked float64 `json:"blocked,omitempty"`
 	// optional - Time spent in a queue waiting for a network connection. Use -1
 	// if the timing does not apply to the current request.
-	DNS int `json:"dns,omitempty"`
+	DNS float64 `json:"dns,omitempty"`
 	// optional - DNS resolution time. The time required to resolve a host name.
 	// Use -1 if the timing does not apply to the current request.
-	Connect int `json:"connect,omitempty"`
+	Connect float64 `json:"connect,omitempty"`
 	// optional - Time required to create TCP connection. Use -1 if the timing
 	// does not apply to the current request.
-	Send int `json:"send"`
+	Send float64 `json:"send"`
 	// Time required to send HTTP request to the server.
-	Wait int `json:"wait"`
+	Wait float64 `json:"wait"`
 	// Waiting for a response from the server.
-	Receive int `json:"receive"`
+	Receive float64 `json:"receive"`
 	// Time required to read entire response from the server (or cache).
-	Ssl int `json:"ssl,omitempty"`
+	Ssl float64 `json:"ssl,omitempty"`
 	// optional (new in 1.2) - Time required for SSL/TLS negotiation. If this
 	// field is defined then the time is also included in the connect field (to
 	// ensure backward compatibility with HAR 1.1). Use -1 if the timing does not
 	// apply to the current request.
 	Comment string `json:"comment,omitempty"`
 	// optional (new in 1.2) - A comment provided by the user or the application.
+	BlockedQueueing float64 `json:"_blocked_queueing,omitempty"`
+	// optional (Chrome extension) - Part of blocked spent queueing in the
+	// browser.
+	Extras map[string]json.RawMessage `json:"-"`
 }
 
 // TestResult contains results for an individual HTTP request
@@ -0,0 +1,214 @@
+package hargo
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The web UI is a single page without dependencies, so it works offline.
+//
+//go:embed assets/ui.html assets/ui.css assets/ui.js
+var uiAssets embed.FS
+
+// uiEntry is the summary of an entry listed by the web UI.
+type uiEntry struct {
+	Index    int     `json:"index"`
+	Method   string  `json:"method"`
+	URL      string  `json:"url"`
+	Status   int     `json:"status"`
+	MimeType string  `json:"mimeType"`
+	Size     int     `json:"size"`
+	Time     float64 `json:"time"`
+	// Offset is the start of the entry in milliseconds after the first.
+	Offset  float64 `json:"offset"`
+	Pageref string  `json:"pageref,omitempty"`
+	// Decoder is the name of the body decoder applying to the entry.
+	Decoder string `json:"decoder,omitempty"`
+}
+
+// UIOptions controls the web UI.
+type UIOptions struct {
+	// Protos decodes the messages of gRPC calls with their types instead
+	// of without schema.
+	Protos *ProtoRegistry
+	// Decoders are used in addition to the DefaultBodyDecoders to show
+	// binary response bodies.
+	Decoders *BodyDecoders
+}
+
+// UIHandler returns the HTTP handler of the web UI browsing har: the page
+// itself at /, the entry list at /api/entries, the full entry n at
+// /api/entries/n and its decoded response body at /api/entries/n/body.
+func UIHandler(har Har) http.Handler {
+	return UIHandlerWithOptions(har, UIOptions{})
+}
+
+// UIHandlerWithOptions is UIHandler with options. Response bodies a body
+// decoder applies to, like the messages of gRPC calls, are served decoded
+// at /api/entries/n/decoded.
+func UIHandlerWithOptions(har Har, opts UIOptions) http.Handler {
+	decoders := DefaultBodyDecoders(opts.Protos).merge(opts.Decoders)
+	entries := make([]uiEntry, len(har.Log.Entries))
+	var first time.Time
+	for _, entry := range har.Log.Entries {
+		if t, err := parseStartedDateTime(entry.StartedDateTime); err == nil && (first.IsZero() || t.Before(first)) {
+			first = t
+		}
+	}
+	for i, entry := range har.Log.Entries {
+		entries[i] = uiEntry{Index: i, Method: entry.Request.Method, URL: entry.Request.URL, Status: entry.Response.Status,
+			MimeType: entry.Response.Content.MimeType, Size: entry.Response.Content.Size, Time: float64(entry.Time), Pageref: entry.Pageref}
+		entries[i].Decoder, _, _ = decoders.Lookup(entry)
+		if t, err := parseStartedDateTime(entry.StartedDateTime); err == nil {
+			entries[i].Offset = float64(t.Sub(first)) / float64(time.Millisecond)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		page, err := uiAssets.ReadFile("assets/ui.html")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+	mux.HandleFunc("GET /ui.js", func(w http.ResponseWriter, r *http.Request) {
+		serveUIAsset(w, "assets/ui.js", "text/javascript; charset=utf-8")
+	})
+	mux.HandleFunc("GET /ui.css", func(w http.ResponseWriter, r *http.Request) {
+		serveUIAsset(w, "assets/ui.css", "text/css; charset=utf-8")
+	})
+	mux.HandleFunc("GET /api/entries", func(w http.ResponseWriter, r *http.Request) {
+		writeUIJSON(w, map[string]interface{}{"title": reportTitle(har), "pages": har.Log.Pages, "entries": entries})
+	})
+	mux.HandleFunc("GET /api/entries/{index}", func(w http.ResponseWriter, r *http.Request) {
+		if entry, ok := uiEntryAt(w, r, har); ok {
+			writeUIJSON(w, entry)
+		}
+	})
+	mux.HandleFunc("GET /api/entries/{index}/body", func(w http.ResponseWriter, r *http.Request) {
+		entry, ok := uiEntryAt(w, r, har)
+		if !ok {
+			return
+		}
+		body, err := entry.DecodedBody()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		// recorded pages and scripts must not run with the UI's origin
+		w.Header().Set("Content-Type", entry.Response.Content.MimeType)
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Write(body)
+	})
+	mux.HandleFunc("GET /api/entries/{index}/decoded", func(w http.ResponseWriter, r *http.Request) {
+		entry, ok := uiEntryAt(w, r, har)
+		if !ok {
+			return
+		}
+		name, decoded, err := decoders.Decode(entry)
+		switch {
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		case name == "":
+			http.Error(w, "no decoder for "+entry.Response.Content.MimeType, http.StatusUnprocessableEntity)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Write(decoded)
+	})
+	return mux
+}
+
+// serveUIAsset writes an embedded file of the web UI.
+func serveUIAsset(w http.ResponseWriter, name, contentType string) {
+	data, err := uiAssets.ReadFile(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(data)
+}
+
+// uiEntryAt returns the entry of the index path value of r, or responds
+// with 404 if there is none.
+func uiEntryAt(w http.ResponseWriter, r *http.Request, har Har) (Entry, bool) {
+	i, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || i < 0 || i >= len(har.Log.Entries) {
+		http.NotFound(w, r)
+		return Entry{}, false
+	}
+	return har.Log.Entries[i], true
+}
+
+// writeUIJSON responds with v as JSON.
+func writeUIJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+// loopbackHosts rejects requests for host names other than localhost and
+// loopback addresses, so web pages cannot read the UI through DNS
+// rebinding.
+func loopbackHosts(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			http.Error(w, "forbidden host", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ServeUI serves the web UI browsing har on addr until ctx is done. On a
+// loopback address only requests for localhost are answered.
+func ServeUI(ctx context.Context, addr string, har Har) error {
+	return ServeUIWithOptions(ctx, addr, har, UIOptions{})
+}
+
+// ServeUIWithOptions is ServeUI with options.
+func ServeUIWithOptions(ctx context.Context, addr string, har Har, opts UIOptions) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("web UI: %v", err)
+	}
+	handler := UIHandlerWithOptions(har, opts)
+	if tcp, ok := ln.Addr().(*net.TCPAddr); ok && tcp.IP.IsLoopback() {
+		handler = loopbackHosts(handler)
+	}
+	srv := &http.Server{Handler: handler}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	log.Infof("Browse %d entries on http://%s/", len(har.Log.Entries), ln.Addr())
+	if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package hargo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUIHandler(t *testing.T) {
+	har := Har{Log: Log{Entries: []Entry{
+		{StartedDateTime: "2024-01-02T10:00:00.000Z", Time: 100, Request: Request{Method: "GET", URL: "https://example.com/"},
+			Response: Response{Status: 200, Content: Content{MimeType: "text/html", Size: 21, Text: "<script>x()</script>"}}},
+		{StartedDateTime: "2024-01-02T10:00:00.250Z", Time: 50, Request: Request{Method: "GET", URL: "https://example.com/logo.png"},
+			Response: Response{Status: 200, Content: Content{MimeType: "image/png", Size: 3, Text: base64.StdEncoding.EncodeToString([]byte{1, 2, 3}), Encoding: "base64"}}},
+	}}}
+	server := httptest.NewServer(UIHandler(har))
+	defer server.Close()
+
+	get := func(path string) (*http.Response, string) {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	if resp, body := get("/"); resp.StatusCode != 200 || !strings.Contains(body, "ui.js") {
+		t.Errorf("got %d %s", resp.StatusCode, body)
+	}
+	if resp, _ := get("/ui.js"); resp.StatusCode != 200 || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/javascript") {
+		t.Errorf("got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	_, body := get("/api/entries")
+	var list struct {
+		Title   string
+		Entries []uiEntry
+	}
+	if err := json.Unmarshal([]byte(body), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Title != "https://example.com/" || len(list.Entries) != 2 || list.Entries[1].Offset != 250 || list.Entries[1].MimeType != "image/png" {
+		t.Errorf("got %+v", list)
+	}
+
+	_, body = get("/api/entries/1")
+	var entry Entry
+	if err := json.Unmarshal([]byte(body), &entry); err != nil || entry.Request.URL != "https://example.com/logo.png" {
+		t.Errorf("got %v %+v", err, entry)
+	}
+	if resp, body := get("/api/entries/1/body"); body != "\x01\x02\x03" || resp.Header.Get("Content-Type") != "image/png" {
+		t.Errorf("got %q %s", body, resp.Header.Get("Content-Type"))
+	}
+	// recorded scripts do not run with the origin of the UI
+	if resp, _ := get("/api/entries/0/body"); resp.Header.Get("Content-Security-Policy") != "sandbox" {
+		t.Errorf("got headers %v", resp.Header)
+	}
+	for _, path := range []string{"/api/entries/2", "/api/entries/-1/body", "/api/entries/x"} {
+		if resp, _ := get(path); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestLoopbackHosts(t *testing.T) {
+	handler := loopbackHosts(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tests := []struct {
+		host   string
+		status int
+	}{
+		{"localhost:8088", 200},
+		{"127.0.0.1:8088", 200},
+		{"[::1]:8088", 200},
+		{"localhost", 200},
+		{"attacker.example.com:8088", 403},
+		{"10.0.0.1", 403},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = test.host
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != test.status {
+			t.Errorf("%s: got %d, want %d", test.host, w.Code, test.status)
+		}
+	}
+}
+
+func TestUIHandlerDecoded(t *testing.T) {
+	reg, err := ParseProtoDescriptors(testDescriptorSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	har := Har{Log: Log{Entries: []Entry{
+		grpcTestEntry("application/grpc-web+proto", "application/grpc-web+proto",
+			grpcFrameBytes(0, pbString(1, "ann")), grpcFrameBytes(0, pbString(1, "hi")), nil),
+		{Request: Request{Method: "GET", URL: "https://example.com/"}, Response: Response{Status: 200, Content: Content{MimeType: "text/html"}}},
+	}}}
+	handler := UIHandlerWithOptions(har, UIOptions{Protos: reg})
+
+	tests := []struct {
+		path     string
+		status   int
+		expected string
+	}{
+		{"/api/entries/0/decoded", 200, `"userName": "ann"`},
+		{"/api/entries/1/decoded", http.StatusUnprocessableEntity, ""},
+		{"/api/entries/2/decoded", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != test.status || !strings.Contains(w.Body.String(), test.expected) {
+			t.Errorf("%s: got %d %s", test.path, w.Code, w.Body.String())
+		}
+	}
+}
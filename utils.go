@@ -3,12 +3,15 @@ package hargo
 import (
 	"bufio"
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"sort"
 	"strings"
+	"time"
 
 	"golang.org/x/net/http/httpguts"
 
@@ -17,22 +20,12 @@ import (
 
 // Decode reads from a reader and returns Har object
 func Decode(r *bufio.Reader) (Har, error) {
-	dec := json.NewDecoder(r)
-	var har Har
-	err := dec.Decode(&har)
+	har, err := readHar(r)
 
 	if err != nil {
 		log.Error(err)
 	}
 
-	// Delete ws:// entries as they block execution
-	for i, entry := range har.Log.Entries {
-		if strings.HasPrefix(entry.Request.URL, "ws://") {
-			har.Log.Entries[i] = har.Log.Entries[len(har.Log.Entries)-1]
-			har.Log.Entries = har.Log.Entries[:len(har.Log.Entries)-1]
-		}
-	}
-
 	// Sort the entries by StartedDateTime to ensure they will be processed
 	// in the same order as they happened
 	sort.Slice(har.Log.Entries, func(i, j int) bool {
@@ -42,7 +35,41 @@ func Decode(r *bufio.Reader) (Har, error) {
 	return har, err
 }
 
-// EntryToRequest converts a HAR entry type to an http.Request
+// readHar decodes a .har file, or converts a Charles session or mitmproxy
+// flow file.
+func readHar(r *bufio.Reader) (Har, error) {
+	if isCharles(r) {
+		return CharlesToHar(r)
+	}
+	if isMitmproxy(r) {
+		return MitmproxyToHar(r)
+	}
+
+	if Lenient() {
+		har, repairs, err := DecodeLenient(r)
+		for _, repair := range repairs {
+			log.Warn("Repaired ", repair)
+		}
+		return har, err
+	}
+
+	dec := json.NewDecoder(r)
+	var har Har
+	err := dec.Decode(&har)
+	return har, err
+}
+
+// Encode writes a Har object to a writer as indented JSON
+func Encode(w io.Writer, har Har) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(har)
+}
+
+// EntryToRequest converts a HAR entry type to an http.Request. Headers that
+// are invalid or managed by net/http are dropped, the Host header becomes
+// req.Host, and unless ignoreHarCookies is set the recorded cookies are
+// sent.
 func EntryToRequest(entry *Entry, ignoreHarCookies bool) (*http.Request, error) {
 	body := ""
 
@@ -56,10 +83,18 @@ func EntryToRequest(entry *Entry, ignoreHarCookies bool) (*http.Request, error)
 		body = form.Encode()
 	}
 
-	req, _ := http.NewRequest(entry.Request.Method, entry.Request.URL, bytes.NewBuffer([]byte(body)))
+	req, err := http.NewRequest(entry.Request.Method, entry.Request.URL, bytes.NewBuffer([]byte(body)))
+	if err != nil {
+		return nil, err
+	}
 
 	for _, h := range entry.Request.Headers {
-		if httpguts.ValidHeaderFieldName(h.Name) && httpguts.ValidHeaderFieldValue(h.Value) && h.Name != "Cookie" {
+		switch {
+		case strings.EqualFold(h.Name, "Host") || h.Name == ":authority":
+			req.Host = h.Value
+		case strings.EqualFold(h.Name, "Content-Length"):
+			// computed from the body
+		case httpguts.ValidHeaderFieldName(h.Name) && httpguts.ValidHeaderFieldValue(h.Value) && h.Name != "Cookie":
 			req.Header.Add(h.Name, h.Value)
 		}
 	}
@@ -74,6 +109,33 @@ func EntryToRequest(entry *Entry, ignoreHarCookies bool) (*http.Request, error)
 	return req, nil
 }
 
+// ResponseToEntry records resp and its request as an Entry with the given
+// timings, which started when the timings say the request was sent. The
+// response body is read and replaced, so resp can still be consumed; the
+// request body is recorded if resp.Request.GetBody is set.
+func ResponseToEntry(resp *http.Response, timings PageTimings) (Entry, error) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return Entry{}, err
+	}
+
+	total := totalTime(timings)
+	entry := Entry{
+		StartedDateTime: time.Now().Add(-time.Duration(float64(total) * float64(time.Millisecond))).Format(harDateTimeLayout),
+		Time:            total,
+		Response:        NewResponse(resp, body),
+		Timings:         timings,
+	}
+
+	if resp.Request != nil {
+		entry.Request = NewRequest(resp.Request, requestBody(resp.Request))
+	}
+
+	return entry, nil
+}
+
 func check(err error) {
 	if err != nil {
 		log.Error(err)
@@ -97,3 +159,41 @@ func NewReader(r io.Reader) *bufio.Reader {
 	}
 	return buf
 }
+
+// startedDateTimeLayouts are the timestamp formats seen in the
+// startedDateTime fields of real-world .har files.
+var startedDateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.000Z0700",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05.000",
+	"2006-01-02T15:04:05",
+}
+
+// parseStartedDateTime parses an ISO 8601 startedDateTime value.
+func parseStartedDateTime(s string) (time.Time, error) {
+	var err error
+	for _, layout := range startedDateTimeLayouts {
+		var t time.Time
+		t, err = time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// epochSeconds converts fractional seconds since the epoch to time.Time.
+func epochSeconds(t float64) time.Time {
+	sec, frac := math.Modf(t)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
+
+// decodeContent returns the response body recorded in a Content object,
+// decoding it if it was stored base64 encoded.
+func decodeContent(content Content) ([]byte, error) {
+	if content.Encoding == "base64" {
+		return base64.StdEncoding.DecodeString(content.Text)
+	}
+	return []byte(content.Text), nil
+}
@@ -0,0 +1,118 @@
+package hargo
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// readTestHar reads a .har file of the test directory, keeping the order of
+// its entries like the commands do.
+func readTestHar(t *testing.T, name string) Har {
+	t.Helper()
+	f, err := os.Open(filepath.Join("test", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	har, err := readHar(bufio.NewReader(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return har
+}
+
+var tableCells = regexp.MustCompile(`\s{2,}`)
+
+// tableRows splits the rows of a table written with tabwriter, up to the
+// first empty line, into their cells.
+func tableRows(s string) [][]string {
+	var rows [][]string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		rows = append(rows, tableCells.Split(line, -1))
+	}
+	return rows
+}
+
+func TestEntryToRequest(t *testing.T) {
+	entry := Entry{Request: Request{
+		Method: "POST",
+		URL:    "https://example.com/api?x=1",
+		Headers: []NVP{
+			{Name: ":authority", Value: "api.example.com"},
+			{Name: "Content-Type", Value: "application/json"},
+			{Name: "Content-Length", Value: "999"},
+			{Name: "Cookie", Value: "stale=1"},
+			{Name: "Bad Header", Value: "x"},
+		},
+		Cookies:  []Cookie{{Name: "session", Value: "abc"}},
+		PostData: PostData{MimeType: "application/json", Text: `{"a":1}`},
+	}}
+
+	req, err := EntryToRequest(&entry, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Host != "api.example.com" || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected request %+v", req)
+	}
+	if req.Header.Get("Content-Length") != "" || req.ContentLength != 7 || req.Header.Get("Bad Header") != "" {
+		t.Errorf("unexpected headers %v, length %d", req.Header, req.ContentLength)
+	}
+	if req.Header.Get("Cookie") != "session=abc" {
+		t.Errorf("unexpected cookies %s", req.Header.Get("Cookie"))
+	}
+
+	if req, err := EntryToRequest(&entry, true); err != nil || req.Header.Get("Cookie") != "" {
+		t.Errorf("cookies not ignored: %v", err)
+	}
+
+	entry.Request.URL = "://bad"
+	if _, err := EntryToRequest(&entry, false); err == nil {
+		t.Error("expected an error for an invalid URL")
+	}
+}
+
+func TestResponseToEntry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("PUT", server.URL+"/items/1", strings.NewReader("item"))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := ResponseToEntry(resp, PageTimings{DNS: -1, Connect: -1, Send: 1, Wait: 10, Receive: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Time != 13 || entry.StartedDateTime == "" {
+		t.Errorf("unexpected timing %g at %s", entry.Time, entry.StartedDateTime)
+	}
+	if entry.Request.Method != "PUT" || entry.Request.PostData.Text != "item" {
+		t.Errorf("unexpected request %+v", entry.Request)
+	}
+	if entry.Response.Status != 201 || entry.Response.StatusText != "Created" || entry.Response.Content.Text != "created" {
+		t.Errorf("unexpected response %+v", entry.Response)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "created" {
+		t.Errorf("response body not restored: %q", body)
+	}
+}
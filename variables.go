@@ -0,0 +1,76 @@
+package hargo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadDataSet reads the rows of variables used by {{name}} placeholders in
+// replayed requests. CSV files name the variables in their header row, JSON
+// files contain an array of objects. Other JSON values than strings are
+// used in their JSON form.
+func LoadDataSet(path string) ([]map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var rows []map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err := dec.Decode(&rows); err != nil {
+			return nil, fmt.Errorf("invalid data set %s: %v", path, err)
+		}
+		data := make([]map[string]string, 0, len(rows))
+		for _, row := range rows {
+			vars := make(map[string]string, len(row))
+			for name, v := range row {
+				vars[name] = jsonPathString(v)
+			}
+			data = append(data, vars)
+		}
+		return data, nil
+	}
+
+	r := csv.NewReader(bytes.NewReader(b))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid data set %s: %v", path, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	data := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		vars := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(record) {
+				vars[name] = record[i]
+			}
+		}
+		data = append(data, vars)
+	}
+	return data, nil
+}
+
+// dataRow returns the variables of virtual user n, cycling through data.
+func dataRow(data []map[string]string, n int) map[string]string {
+	if len(data) == 0 {
+		return nil
+	}
+	return data[n%len(data)]
+}
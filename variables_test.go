@@ -0,0 +1,109 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadDataSet(t *testing.T) {
+	dir := t.TempDir()
+
+	csvPath := filepath.Join(dir, "users.csv")
+	if err := os.WriteFile(csvPath, []byte("\ufeffuser, id\nalice,1\nbob\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	jsonPath := filepath.Join(dir, "users.json")
+	if err := os.WriteFile(jsonPath, []byte(`[{"user":"alice","id":1},{"user":"bob","id":2.5,"admin":true}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		expected []map[string]string
+	}{
+		{csvPath, []map[string]string{{"user": "alice", "id": "1"}, {"user": "bob"}}},
+		{jsonPath, []map[string]string{{"user": "alice", "id": "1"}, {"user": "bob", "id": "2.5", "admin": "true"}}},
+	}
+	for _, test := range tests {
+		data, err := LoadDataSet(test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(data, test.expected) {
+			t.Errorf("%s: got %v, want %v", filepath.Base(test.path), data, test.expected)
+		}
+	}
+
+	if row := dataRow(tests[0].expected, 3); row["user"] != "bob" {
+		t.Errorf("dataRow(3) = %v", row)
+	}
+	if row := dataRow(nil, 3); row != nil {
+		t.Errorf("dataRow of empty data set = %v", row)
+	}
+}
+
+func TestLoadConfigDataFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "users.csv"), []byte("user\nbob\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "scenario.json")
+	if err := os.WriteFile(path, []byte(`{"data": [{"user": "alice"}], "dataFile": "users.csv"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]string{{"user": "alice"}, {"user": "bob"}}
+	if !reflect.DeepEqual(cfg.Data, expected) {
+		t.Errorf("got %v, want %v", cfg.Data, expected)
+	}
+}
+
+func TestReplayVariables(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = append(received, r.URL.RequestURI()+" "+r.Header.Get("X-User")+" "+string(b))
+	}))
+	defer server.Close()
+
+	har := Har{Log: Log{Entries: []Entry{{
+		StartedDateTime: "2020-01-01T00:00:00.000Z",
+		Request: Request{
+			Method:   "POST",
+			URL:      "https://example.com/users/{{id}}?unknown={{missing}}",
+			Headers:  []NVP{{Name: "X-User", Value: "{{user}}"}},
+			PostData: PostData{MimeType: "text/plain", Text: "user={{user}}"},
+		},
+		Response: Response{Status: 200},
+	}}}}
+
+	var h bytes.Buffer
+	if err := json.NewEncoder(&h).Encode(har); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{
+		Rewrites: []Rewrite{{BaseURL: server.URL}},
+		Data:     []map[string]string{{"user": "alice", "id": "7"}, {"user": "bob", "id": "8"}},
+	}
+	if err := Replay(bufio.NewReader(&h), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"/users/7?unknown={{missing}} alice user=alice"}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("received %q, want %q", received, expected)
+	}
+}
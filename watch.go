@@ -0,0 +1,109 @@
+package hargo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// WatchOptions controls WatchDir.
+type WatchOptions struct {
+	// Interval is how often the directory is scanned, 2 seconds if zero.
+	Interval time.Duration
+	// Existing also processes the .har files that are already in the
+	// directory when watching starts.
+	Existing bool
+}
+
+// watchedFile is what WatchDir knows about a file between two scans.
+type watchedFile struct {
+	size    int64
+	modTime time.Time
+}
+
+// WatchDir calls process with the path of every .har file dropped into
+// dir, in name order, until ctx is done. The directory is scanned every
+// interval rather than subscribed to, so it may also be a network share.
+// A file is processed once its size and modification time stayed the same
+// for an interval, so files still being copied are not read early, and
+// again if it is replaced later. Errors of process are logged and watching
+// continues.
+func WatchDir(ctx context.Context, dir string, opts WatchOptions, process func(ctx context.Context, path string) error) error {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	seen, err := scanHarFiles(dir)
+	if err != nil {
+		return err
+	}
+	processed := make(map[string]watchedFile)
+	if !opts.Existing {
+		for path, file := range seen {
+			processed[path] = file
+		}
+	}
+	log.Infof("Watching %s for new .har files", dir)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Infof("Stopped watching %s", dir)
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := scanHarFiles(dir)
+		if err != nil {
+			log.Warnf("Cannot scan %s: %v", dir, err)
+			continue
+		}
+		var stable []string
+		for path, file := range current {
+			if previous, ok := seen[path]; ok && previous == file && processed[path] != file {
+				stable = append(stable, path)
+			}
+		}
+		sort.Strings(stable)
+		for _, path := range stable {
+			if ctx.Err() != nil {
+				break
+			}
+			processed[path] = current[path]
+			log.Infof("Processing %s", path)
+			if err := process(ctx, path); err != nil {
+				log.Errorf("Processing %s failed: %v", path, err)
+			}
+		}
+		seen = current
+	}
+}
+
+// scanHarFiles returns the .har files directly in dir.
+func scanHarFiles(dir string) (map[string]watchedFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]watchedFile)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || strings.HasPrefix(name, ".") || !strings.EqualFold(filepath.Ext(name), ".har") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files[filepath.Join(dir, name)] = watchedFile{size: info.Size(), modTime: info.ModTime()}
+	}
+	return files, nil
+}
@@ -0,0 +1,73 @@
+package hargo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchDir(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "old.har"), []byte("{}"), 0644)
+
+	var mu sync.Mutex
+	var processed []string
+	watch := func(opts WatchOptions, drop func()) []string {
+		t.Helper()
+		mu.Lock()
+		processed = nil
+		mu.Unlock()
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- WatchDir(ctx, dir, opts, func(ctx context.Context, path string) error {
+				mu.Lock()
+				defer mu.Unlock()
+				processed = append(processed, filepath.Base(path))
+				return errors.New("errors are logged")
+			})
+		}()
+		time.Sleep(30 * time.Millisecond)
+		drop()
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("WatchDir failed: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return processed
+	}
+
+	got := watch(WatchOptions{Interval: 10 * time.Millisecond}, func() {
+		os.WriteFile(filepath.Join(dir, "b.har"), []byte("{}"), 0644)
+		os.WriteFile(filepath.Join(dir, "a.HAR"), []byte("{}"), 0644)
+		os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("-"), 0644)
+		os.WriteFile(filepath.Join(dir, ".partial.har"), []byte("{"), 0644)
+		os.Mkdir(filepath.Join(dir, "dir.har"), 0755)
+	})
+	if len(got) != 2 || got[0] != "a.HAR" || got[1] != "b.har" {
+		t.Errorf("got %v, want [a.HAR b.har]", got)
+	}
+
+	got = watch(WatchOptions{Interval: 10 * time.Millisecond, Existing: true}, func() {})
+	if len(got) != 3 || got[2] != "old.har" {
+		t.Errorf("got %v, want all 3 files", got)
+	}
+
+	// replaced files are processed again
+	got = watch(WatchOptions{Interval: 10 * time.Millisecond}, func() {
+		os.WriteFile(filepath.Join(dir, "old.har"), []byte(`{"log": {}}`), 0644)
+	})
+	if len(got) != 1 || got[0] != "old.har" {
+		t.Errorf("got %v, want [old.har]", got)
+	}
+
+	if err := WatchDir(context.Background(), filepath.Join(dir, "missing"), WatchOptions{}, nil); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
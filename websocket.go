@@ -0,0 +1,86 @@
+package hargo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// WebSocket opcodes of recorded messages.
+const (
+	OpcodeText   = 1
+	OpcodeBinary = 2
+)
+
+// TranscriptMessage is one line of a WebSocket transcript.
+type TranscriptMessage struct {
+	Direction string `json:"direction"`
+	Opcode    int    `json:"opcode"`
+	Time      string `json:"time"`
+	Payload   string `json:"payload"`
+	// Encoding is "base64" for binary payloads.
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// IsWebSocket reports whether the entry opened a WebSocket connection.
+func (e Entry) IsWebSocket() bool {
+	if len(e.WebSocketMessages) > 0 || e.ResourceType == "websocket" {
+		return true
+	}
+	return e.Response.Status == 101 && strings.EqualFold(recordedHeader(e.Response.Headers, "Upgrade"), "websocket")
+}
+
+// Transcript converts the recorded WebSocket messages of an entry to
+// transcript lines in the recorded order.
+func (e Entry) Transcript() []TranscriptMessage {
+	transcript := make([]TranscriptMessage, 0, len(e.WebSocketMessages))
+
+	for _, m := range e.WebSocketMessages {
+		t := TranscriptMessage{
+			Direction: m.Type,
+			Opcode:    m.Opcode,
+			Time:      epochSeconds(m.Time).UTC().Format(time.RFC3339Nano),
+			Payload:   m.Data,
+		}
+		if m.Opcode == OpcodeBinary {
+			t.Encoding = "base64"
+		}
+		transcript = append(transcript, t)
+	}
+
+	return transcript
+}
+
+// writeWebSocketTranscript writes the messages of entry i as JSONL to the
+// websockets directory of outdir.
+func writeWebSocketTranscript(outdir string, i int, entry Entry, target extractTarget, modTime time.Time) (ManifestEntry, error) {
+	dir := filepath.Join(outdir, "websockets")
+	if err := target.mkdirAll(dir); err != nil {
+		return ManifestEntry{}, err
+	}
+
+	path := filepath.Join(dir, entryFilename(entry.Request.URL, fmt.Sprintf("%d.jsonl", i)))
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, m := range entry.Transcript() {
+		if err := enc.Encode(m); err != nil {
+			return ManifestEntry{}, err
+		}
+	}
+	if err := target.writeFile(path, buf.Bytes(), modTime); err != nil {
+		return ManifestEntry{}, err
+	}
+
+	return ManifestEntry{
+		OriginalURL:   entry.Request.URL,
+		ExtractedPath: path,
+		MimeType:      "application/x-ndjson",
+		Size:          buf.Len(),
+		Method:        entry.Request.Method,
+		Status:        entry.Response.Status,
+	}, nil
+}
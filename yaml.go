@@ -0,0 +1,263 @@
+package hargo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// yamlPlain is an unquoted YAML scalar. Whether it is a string, a number
+// or a bool depends on the field it is decoded into, so salt: 20240101
+// stays a string and rps: 50 becomes a number.
+type yamlPlain string
+
+// yamlToJSON converts the subset of YAML used by profile, budget, lint and
+// redaction files to JSON for json.Unmarshal into v: a block mapping of
+// scalars and block sequences of scalars or flat mappings. Scalars are
+// plain, single quoted or double quoted with JSON-style escapes.
+func yamlToJSON(b []byte, v interface{}) ([]byte, error) {
+	settings, err := parseSimpleYAML(b)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(yamlValue(settings, reflect.TypeOf(v)))
+}
+
+// parseSimpleYAML parses YAML into maps, slices, quoted strings and
+// yamlPlain scalars.
+func parseSimpleYAML(b []byte) (map[string]interface{}, error) {
+	settings := make(map[string]interface{})
+	var list []interface{}
+	var listKey string
+	var item map[string]interface{}
+	itemIndent := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		content := strings.TrimLeft(line, " ")
+		if content == "" || content == "---" || strings.HasPrefix(content, "#") {
+			continue
+		}
+		indent := len(line) - len(content)
+
+		listItem := listKey != "" && (content == "-" || strings.HasPrefix(content, "- "))
+		if indent == 0 && !listItem {
+			key, value, ok := yamlKeyValue(content)
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected key: value", n)
+			}
+			if value == "" {
+				list, listKey, item = []interface{}{}, key, nil
+				settings[key] = list
+				continue
+			}
+			listKey = ""
+			scalar, err := yamlScalar(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			settings[key] = scalar
+			continue
+		}
+
+		if listKey == "" {
+			return nil, fmt.Errorf("line %d: unexpected indentation", n)
+		}
+		var err error
+		if listItem {
+			content = strings.TrimSpace(strings.TrimPrefix(content, "-"))
+			item = nil
+			var scalar interface{}
+			if key, value, ok := yamlKeyValue(content); ok {
+				scalar, err = yamlScalar(value)
+				item = map[string]interface{}{key: scalar}
+				itemIndent = len(line) - len(strings.TrimLeft(line[indent+1:], " "))
+				list = append(list, item)
+			} else {
+				scalar, err = yamlScalar(content)
+				list = append(list, scalar)
+			}
+		} else if key, value, ok := yamlKeyValue(content); ok && item != nil && indent == itemIndent {
+			item[key], err = yamlScalar(value)
+		} else {
+			return nil, fmt.Errorf("line %d: unexpected %q", n, content)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		settings[listKey] = list
+	}
+	return settings, scanner.Err()
+}
+
+// yamlKeyValue splits "key: value" or "key:", with a value of only a
+// comment counting as none. Quoted scalars are not keys.
+func yamlKeyValue(s string) (string, string, bool) {
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'") {
+		return "", "", false
+	}
+	i := strings.Index(s, ":")
+	if i <= 0 || (i+1 < len(s) && s[i+1] != ' ') {
+		return "", "", false
+	}
+	value := strings.TrimSpace(s[i+1:])
+	if strings.HasPrefix(value, "#") {
+		value = ""
+	}
+	return strings.TrimSpace(s[:i]), value, true
+}
+
+// yamlScalar parses a plain or quoted scalar followed by an optional
+// comment. Quoted scalars are strings; plain ones are returned as
+// yamlPlain.
+func yamlScalar(s string) (interface{}, error) {
+	var value, rest string
+	switch {
+	case strings.HasPrefix(s, `"`):
+		end := 1
+		for ; end < len(s) && s[end] != '"'; end++ {
+			if s[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(s) {
+			return nil, fmt.Errorf("unterminated string %s", s)
+		}
+		// YAML allows escaping the slash, Go does not
+		unquoted, err := strconv.Unquote(strings.ReplaceAll(s[:end+1], `\/`, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", s[:end+1])
+		}
+		value, rest = unquoted, s[end+1:]
+	case strings.HasPrefix(s, "'"):
+		end := 1
+		for ; end < len(s); end++ {
+			if s[end] == '\'' {
+				if end+1 < len(s) && s[end+1] == '\'' {
+					end++
+					continue
+				}
+				break
+			}
+		}
+		if end >= len(s) {
+			return nil, fmt.Errorf("unterminated string %s", s)
+		}
+		value, rest = strings.ReplaceAll(s[1:end], "''", "'"), s[end+1:]
+	default:
+		if i := strings.Index(s, " #"); i >= 0 {
+			s = s[:i]
+		}
+		return yamlPlain(strings.TrimSpace(s)), nil
+	}
+
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return nil, fmt.Errorf("unexpected %q after string", rest)
+	}
+	return value, nil
+}
+
+var jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// yamlValue converts parsed YAML to values json.Marshal writes as the JSON
+// type of t. Plain scalars are strings for string fields, numbers and bools
+// for numeric and bool fields, and guessed where t is not known.
+func yamlValue(v interface{}, t reflect.Type) interface{} {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = yamlValue(value, yamlFieldType(t, key))
+		}
+		return m
+	case []interface{}:
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+		list := make([]interface{}, len(v))
+		for i, value := range v {
+			list[i] = yamlValue(value, elem)
+		}
+		return list
+	case yamlPlain:
+		return yamlPlainValue(string(v), t)
+	}
+	return v
+}
+
+// yamlPlainValue converts a plain scalar for a value of type t.
+func yamlPlainValue(s string, t reflect.Type) interface{} {
+	number := func() interface{} {
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			return json.Number(s)
+		}
+		return s
+	}
+	switch {
+	case t == nil || t.Kind() == reflect.Interface:
+		if s == "true" || s == "false" {
+			return s == "true"
+		}
+		return number()
+	case reflect.PtrTo(t).Implements(jsonUnmarshaler):
+		// e.g. sizes and status lists accept numbers and strings
+		return number()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return s
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return number()
+	}
+	// json.Unmarshal reports the mismatch
+	return s
+}
+
+// yamlFieldType returns the type of the value of key in a struct or map of
+// type t, nil if unknown. Struct fields are matched by their JSON name,
+// ignoring case like encoding/json.
+func yamlFieldType(t reflect.Type, key string) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem()
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				if ft := yamlFieldType(field.Type, key); ft != nil {
+					return ft
+				}
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" || !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if strings.EqualFold(name, key) {
+				return field.Type
+			}
+		}
+	}
+	return nil
+}
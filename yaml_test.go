@@ -0,0 +1,94 @@
+package hargo
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestYAMLToJSON(t *testing.T) {
+	type item struct {
+		Name  string  `json:"name"`
+		Count int     `json:"count"`
+		Ratio float64 `json:"ratio"`
+		On    bool    `json:"on"`
+	}
+	type settings struct {
+		ID    string      `json:"id"`
+		Size  ByteSize    `json:"size"`
+		Tags  []string    `json:"tags"`
+		Items []item      `json:"items"`
+		Any   interface{} `json:"any"`
+	}
+
+	tests := []struct {
+		content  string
+		expected settings
+	}{
+		{"id: 404\nsize: 2048\n", settings{ID: "404", Size: 2048}},
+		{"id: 2024.01\nsize: 1KiB # a comment\n", settings{ID: "2024.01", Size: 1024}},
+		{`id: "\\.json$"`, settings{ID: `\.json$`}},
+		{`id: "/app #main" # the app`, settings{ID: "/app #main"}},
+		{`id: "tab\there \"quoted\" é \/"`, settings{ID: "tab\there \"quoted\" é /"}},
+		{`id: 'it''s #1'`, settings{ID: "it's #1"}},
+		{"id: a#b\n", settings{ID: "a#b"}},
+		{"tags:\n  - 10\n  - \"x: y\"\n  - true\n", settings{Tags: []string{"10", "x: y", "true"}}},
+		{"items: # the items\n  - name: 007\n    count: 3\n    ratio: 0.5\n    on: true\n",
+			settings{Items: []item{{Name: "007", Count: 3, Ratio: 0.5, On: true}}}},
+		{"any: 1.5\n", settings{Any: 1.5}},
+		{"any: true\n", settings{Any: true}},
+		{"any: '1.5'\n", settings{Any: "1.5"}},
+	}
+	for i, test := range tests {
+		var got settings
+		b, err := yamlToJSON([]byte(test.content), &got)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Errorf("%d: %s: %v", i, b, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%d: got %+v, expected %+v", i, got, test.expected)
+		}
+	}
+
+	for _, content := range []string{`id: "open`, `id: 'open`, `id: "\q"`, `id: "a" b`, "items:\n  - count: many\n"} {
+		var got settings
+		b, err := yamlToJSON([]byte(content), &got)
+		if err == nil {
+			err = json.Unmarshal(b, &got)
+		}
+		if err == nil {
+			t.Errorf("%q accepted", content)
+		}
+	}
+}
+
+func TestYAMLFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "lint.yaml")
+	os.WriteFile(path, []byte("rules:\n  - id: 404\n    url: \"\\\\.json$\"\n    deny: true\n"), 0644)
+	rules, err := LoadLintRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	har := Har{Log: Log{Entries: []Entry{
+		{Request: Request{Method: "GET", URL: "https://example.com/a.json"}},
+		{Request: Request{Method: "GET", URL: "https://example.com/ajson"}},
+	}}}
+	if violations := Lint(har, rules); len(violations) != 1 || violations[0].Rule != "404" || violations[0].Entry != 0 {
+		t.Errorf("got %+v", violations)
+	}
+
+	path = filepath.Join(dir, "team.yaml")
+	os.WriteFile(path, []byte("salt: 20240101\nrules:\n  - header: Authorization\n    action: drop\n"), 0644)
+	if profile, err := LoadRedactionProfile(path); err != nil || profile.Salt != "20240101" {
+		t.Errorf("got %+v, %v", profile, err)
+	}
+}